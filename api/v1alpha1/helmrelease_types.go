@@ -23,20 +23,42 @@ type HelmReleaseSpec struct {
 	// +kubebuilder:validation:Required
 	Chart string `json:"chart"`
 
-	// RepoURL is the URL of the Helm chart repository.
+	// RepoURL is the URL of the Helm chart repository: an http(s):// chart
+	// repository, or an oci:// registry path the chart is stored under.
 	// +kubebuilder:validation:Required
 	RepoURL string `json:"repoURL"`
 
-	// Version is the version of the Helm chart to deploy.
+	// Version is the version of the Helm chart to deploy: an exact semantic
+	// version or a semver constraint such as ">=1.2.0 <2.0.0" or "~1.4".
 	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MaxLength=128
+	// +kubebuilder:validation:XValidation:rule="self.matches('^v?[0-9]+([.][0-9]+){0,2}(-[0-9A-Za-z.-]+)?([+][0-9A-Za-z.-]+)?$') || (self.matches('^[0-9A-Za-z.+*~^<>=!|, -]+$') && self.matches('[*~^<>=|xX]'))",message="version must be a semantic version or a semver constraint"
 	Version string `json:"version"`
 
+	// AllowPrerelease lets a version constraint resolve to pre-release chart
+	// versions such as 1.2.0-rc.1, like helm install --devel. An exact
+	// pre-release version is always accepted.
+	// +optional
+	AllowPrerelease bool `json:"allowPrerelease,omitempty"`
+
+	// ChartDigest pins the chart to a sha256 digest: the manifest digest for
+	// an oci:// repository, the archive digest listed in index.yaml
+	// otherwise. The downloaded chart is verified against it and must still
+	// match Version, so a re-pushed tag cannot change what is deployed.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^sha256:[a-f0-9]{64}$`
+	ChartDigest string `json:"chartDigest,omitempty"`
+
 	// TargetNamespace is the Kubernetes namespace where the Helm release will be installed.
 	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:XValidation:rule="self.matches('^[a-z0-9]([-a-z0-9]*[a-z0-9])?$')",message="targetNamespace must be a DNS-1123 label"
 	TargetNamespace string `json:"targetNamespace"`
 
 	// ReleaseName overrides the Helm release name. Defaults to metadata.name.
 	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MaxLength=53
+	// +kubebuilder:validation:XValidation:rule="self.matches('^[a-z0-9]([-a-z0-9]*[a-z0-9])?([.][a-z0-9]([-a-z0-9]*[a-z0-9])?)*$')",message="releaseName must be a DNS-1123 subdomain"
 	// +optional
 	ReleaseName string `json:"releaseName,omitempty"`
 
@@ -44,6 +66,722 @@ type HelmReleaseSpec struct {
 	// +kubebuilder:validation:Optional
 	// +optional
 	Values *apiextensionsv1.JSON `json:"values,omitempty"`
+
+	// ValuesFrom reads values from ConfigMaps and Secrets in the
+	// HelmRelease's namespace. They are merged in order, and spec.values on
+	// top. A missing object or key is waited for, as when an
+	// ExternalSecret has not synced yet, unless the reference is optional.
+	// +optional
+	ValuesFrom []ValuesReference `json:"valuesFrom,omitempty"`
+
+	// ValuesTemplate is YAML values with ${VAR} placeholders, replaced by
+	// the variables of substituteFrom. ${VAR:=default} gives a default and
+	// $${VAR} a literal ${VAR}. The result is merged over valuesFrom, with
+	// spec.values on top, so one HelmRelease can be reused across
+	// environments that each provide their own variables.
+	// +optional
+	ValuesTemplate string `json:"valuesTemplate,omitempty"`
+
+	// SubstituteFrom lists the ConfigMaps and Secrets in the HelmRelease's
+	// namespace whose keys are the variables of valuesTemplate. Later
+	// entries override earlier ones. A missing object is waited for, like a
+	// valuesFrom source, unless the reference is optional.
+	// +optional
+	SubstituteFrom []SubstituteReference `json:"substituteFrom,omitempty"`
+
+	// TTL is how long the release lives after the HelmRelease is created. Once
+	// it elapses the controller deletes the HelmRelease, which uninstalls the
+	// chart. Intended for short-lived preview environments.
+	// +kubebuilder:validation:Optional
+	// +optional
+	TTL *metav1.Duration `json:"ttl,omitempty"`
+
+	// PostRenderers transform the rendered manifests, in order, before they are
+	// applied.
+	// +kubebuilder:validation:Optional
+	// +optional
+	PostRenderers []PostRenderer `json:"postRenderers,omitempty"`
+
+	// WasmModules are sandboxed WebAssembly modules run over the rendered
+	// manifests after PostRenderers, in addition to any modules the operator
+	// applies globally.
+	// +kubebuilder:validation:Optional
+	// +optional
+	WasmModules []WasmModule `json:"wasmModules,omitempty"`
+
+	// ProxyURL is the HTTP proxy used to download the chart, overriding the
+	// operator's proxy configuration. Use "direct" to bypass any proxy.
+	// +kubebuilder:validation:Optional
+	// +optional
+	ProxyURL string `json:"proxyURL,omitempty"`
+
+	// RegistrySecretRef is a Secret in the HelmRelease's namespace with the
+	// username and password keys to log in to the registry of an oci://
+	// repoURL. Without it the operator's registry credentials are used.
+	// +optional
+	RegistrySecretRef *LocalSecretReference `json:"registrySecretRef,omitempty"`
+
+	// RepoSecretRef is a Secret in the HelmRelease's namespace with
+	// credentials for a private http(s) chart repository: username and
+	// password for basic auth, ca.crt to trust a private CA, and tls.crt and
+	// tls.key for a client certificate. All keys are optional.
+	// +optional
+	RepoSecretRef *LocalSecretReference `json:"repoSecretRef,omitempty"`
+
+	// InsecureSkipTLSVerify skips verifying the chart repository's
+	// certificate.
+	// +optional
+	InsecureSkipTLSVerify bool `json:"insecureSkipTLSVerify,omitempty"`
+
+	// Verify requires the chart to be signed: its provenance file must
+	// verify against a keyring before it is installed or upgraded. Its
+	// probes smoke-test the release after it is deployed.
+	// +optional
+	Verify *VerifySpec `json:"verify,omitempty"`
+
+	// ServiceAccountName is a ServiceAccount in the HelmRelease's namespace
+	// that Helm impersonates to install, upgrade and uninstall the release,
+	// so the release can only create what the ServiceAccount may. Defaults
+	// to the operator's --default-service-account; without either, Helm
+	// runs with the operator's own permissions.
+	// +optional
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+
+	// KubeConfig deploys the release to another cluster. Helm installs,
+	// upgrades, checks and uninstalls it there; the HelmRelease stays in
+	// this cluster.
+	// +optional
+	KubeConfig *KubeConfigReference `json:"kubeConfig,omitempty"`
+
+	// Interval is how often a ready release is reconciled again without a
+	// change to the HelmRelease, re-verifying its inputs and, with a
+	// driftPolicy, its live objects. The operator's update and drift check
+	// intervals apply when they are shorter.
+	// +optional
+	Interval *metav1.Duration `json:"interval,omitempty"`
+
+	// DriftPolicy controls what happens when the live objects of the release
+	// no longer match the manifests Helm applied. Correct re-applies the
+	// release, Warn only reports the drift, Ignore (the default) skips
+	// detection.
+	// +kubebuilder:validation:Enum=Correct;Warn;Ignore
+	// +optional
+	DriftPolicy DriftPolicy `json:"driftPolicy,omitempty"`
+
+	// DriftDetection configures drift detection. A mode set here takes
+	// precedence over driftPolicy.
+	// +optional
+	DriftDetection *DriftDetectionSpec `json:"driftDetection,omitempty"`
+
+	// AllowRelocation permits changing releaseName, targetNamespace or
+	// kubeConfig after the release is installed. The old Helm release is
+	// uninstalled and a new one installed, so workloads are recreated.
+	// Without it such changes are rejected.
+	// +optional
+	AllowRelocation bool `json:"allowRelocation,omitempty"`
+
+	// Force takes over a Helm release that another tool, such as Flux or
+	// Argo CD, marks as its own. Without it such a release is left alone and
+	// the ManagedByOtherTool condition is set.
+	// +optional
+	Force bool `json:"force,omitempty"`
+
+	// Suspend stops the operator from installing, upgrading or correcting
+	// drift in the release until it is set back to false. Deleting the
+	// HelmRelease and ttl expiry still uninstall it.
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+
+	// RBAC configures permissions the operator provisions for the release.
+	// +optional
+	RBAC *RBACSpec `json:"rbac,omitempty"`
+
+	// DependsOn lists HelmReleases this release depends on, such as the chart
+	// installing the CRDs it uses. Dependencies must not form a cycle.
+	// +optional
+	DependsOn []DependencyReference `json:"dependsOn,omitempty"`
+
+	// ClusterSelector restricts the release to clusters it is compatible
+	// with. On other clusters it is skipped and reported through the
+	// ClusterCompatible condition.
+	// +optional
+	ClusterSelector *ClusterSelector `json:"clusterSelector,omitempty"`
+
+	// Wait makes installs and upgrades succeed only once the release's
+	// resources are ready, as helm --wait does. Without it they succeed as
+	// soon as the manifests are applied.
+	// +optional
+	Wait *WaitSpec `json:"wait,omitempty"`
+
+	// Install configures the first install of the release. Its settings
+	// take precedence over wait for installs.
+	// +optional
+	Install *InstallSpec `json:"install,omitempty"`
+
+	// Upgrade configures upgrades of the release.
+	// +optional
+	Upgrade *UpgradeSpec `json:"upgrade,omitempty"`
+
+	// Uninstall configures the uninstall of the release when the
+	// HelmRelease is deleted or relocated.
+	// +optional
+	Uninstall *UninstallSpec `json:"uninstall,omitempty"`
+
+	// MaxHistory limits the Helm revisions kept for the release; upgrades
+	// delete the oldest revision Secrets beyond it, as helm upgrade
+	// --history-max does. 0 keeps all. Defaulted by the operator's
+	// --max-history.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxHistory *int `json:"maxHistory,omitempty"`
+
+	// MaxRetries is the number of times a failed install or upgrade of the
+	// same generation is retried, with exponential backoff, before the
+	// release is given up on with Stalled=True. Changing the spec starts a
+	// new round of retries. Unset retries forever.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxRetries *int `json:"maxRetries,omitempty"`
+
+	// HookTimeout bounds each chart hook, such as a database migration Job.
+	// A hook that runs longer fails the install or upgrade. Defaults to
+	// wait.timeout when waiting, and to no limit otherwise.
+	// +optional
+	HookTimeout *metav1.Duration `json:"hookTimeout,omitempty"`
+
+	// SkipSchemaValidation skips validating values against the chart's
+	// values.schema.json, for charts whose schema rejects valid values.
+	// +optional
+	SkipSchemaValidation bool `json:"skipSchemaValidation,omitempty"`
+
+	// DisableOpenAPIValidation skips validating the rendered manifests
+	// against the Kubernetes OpenAPI schema, as helm
+	// --disable-openapi-validation does.
+	// +optional
+	DisableOpenAPIValidation bool `json:"disableOpenAPIValidation,omitempty"`
+
+	// SkipCRDs skips installing the CRDs in the chart's crds/ directory, as
+	// helm install --skip-crds does, for charts whose CRDs are managed
+	// separately. Helm never upgrades them either way.
+	// +optional
+	SkipCRDs bool `json:"skipCRDs,omitempty"`
+
+	// DisableHooks skips the chart's hooks on install and upgrade, as
+	// helm --no-hooks does. Delete hooks are controlled by
+	// uninstall.disableHooks.
+	// +optional
+	DisableHooks bool `json:"disableHooks,omitempty"`
+
+	// CreateNamespace creates the target namespace when it does not
+	// exist. The namespace is labelled with the HelmRelease and
+	// deleted with it; namespaces that already exist are left alone.
+	// +optional
+	CreateNamespace bool `json:"createNamespace,omitempty"`
+
+	// UpgradeGates are external checks, such as a change-management
+	// system's "window open" endpoint, that must all pass before the
+	// release is upgraded. Installs are not gated.
+	// +optional
+	UpgradeGates []UpgradeGate `json:"upgradeGates,omitempty"`
+}
+
+// WaitSpec configures waiting for a release's resources.
+type WaitSpec struct {
+	// Timeout bounds the wait for resources and Jobs. Defaults to 5m.
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+
+	// WaitForJobs also waits for the release's Jobs to complete, as
+	// helm --wait-for-jobs does.
+	// +optional
+	WaitForJobs bool `json:"waitForJobs,omitempty"`
+}
+
+// InstallSpec configures the first install of a release.
+type InstallSpec struct {
+	// Wait makes the install succeed only once the release's resources are
+	// ready, as helm install --wait does.
+	// +optional
+	Wait bool `json:"wait,omitempty"`
+
+	// WaitForJobs also waits for the release's Jobs to complete. Implies
+	// wait.
+	// +optional
+	WaitForJobs bool `json:"waitForJobs,omitempty"`
+
+	// Timeout bounds the wait for resources and Jobs. Defaults to
+	// spec.wait.timeout, or 5m.
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+
+	// Atomic uninstalls the release when the install fails, as
+	// helm install --atomic does, so the retry starts from scratch instead
+	// of upgrading a broken release. Implies wait.
+	// +optional
+	Atomic bool `json:"atomic,omitempty"`
+}
+
+// UpgradeSpec configures upgrades of a release.
+// +kubebuilder:validation:XValidation:rule="!(has(self.resetValues) && self.resetValues && has(self.reuseValues) && self.reuseValues)",message="resetValues and reuseValues are mutually exclusive"
+type UpgradeSpec struct {
+	// Force replaces resources that cannot be patched, such as those whose
+	// immutable fields changed, by deleting and recreating them, as
+	// helm upgrade --force does.
+	// +optional
+	Force bool `json:"force,omitempty"`
+
+	// CleanupOnFail deletes the resources a failed upgrade created, as
+	// helm upgrade --cleanup-on-fail does.
+	// +optional
+	CleanupOnFail bool `json:"cleanupOnFail,omitempty"`
+
+	// ResetValues discards the values of the previous revision and uses
+	// only the chart defaults and the release's values.
+	// +optional
+	ResetValues bool `json:"resetValues,omitempty"`
+
+	// ReuseValues merges the release's values over those of the previous
+	// revision, keeping values set on the release outside the operator,
+	// such as with helm upgrade --set.
+	// +optional
+	ReuseValues bool `json:"reuseValues,omitempty"`
+}
+
+// UninstallSpec configures uninstalls of a release.
+type UninstallSpec struct {
+	// KeepHistory keeps the release's revision Secrets, marked as
+	// uninstalled, as helm uninstall --keep-history does.
+	// +optional
+	KeepHistory bool `json:"keepHistory,omitempty"`
+
+	// DisableHooks skips the chart's pre-delete and post-delete hooks.
+	// +optional
+	DisableHooks bool `json:"disableHooks,omitempty"`
+
+	// Wait waits until the release's resources are deleted, as
+	// helm uninstall --wait does.
+	// +optional
+	Wait bool `json:"wait,omitempty"`
+
+	// Timeout bounds each hook and the wait for deletion. Defaults to 5m
+	// when waiting, and to spec.hookTimeout otherwise.
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+}
+
+// ClusterSelector describes the clusters a release may be installed on.
+// +kubebuilder:object:generate=true
+type ClusterSelector struct {
+	// MinKubeVersion is the lowest Kubernetes version allowed, e.g. "1.27".
+	// +optional
+	// +kubebuilder:validation:Pattern=`^v?[0-9]+(\.[0-9]+){1,2}$`
+	MinKubeVersion string `json:"minKubeVersion,omitempty"`
+
+	// MaxKubeVersion is the highest Kubernetes version allowed; "1.29"
+	// allows every 1.29 patch release.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^v?[0-9]+(\.[0-9]+){1,2}$`
+	MaxKubeVersion string `json:"maxKubeVersion,omitempty"`
+
+	// RequiredAPIGroups must all be served by the cluster. An entry is an
+	// API group such as "monitoring.coreos.com" or a group version such as
+	// "gateway.networking.k8s.io/v1".
+	// +optional
+	RequiredAPIGroups []string `json:"requiredAPIGroups,omitempty"`
+}
+
+// DependencyReference names a HelmRelease another release depends on.
+type DependencyReference struct {
+	// Name of the HelmRelease.
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+
+	// Namespace of the HelmRelease. Defaults to the namespace of the
+	// HelmRelease declaring the dependency.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// RBACSpec configures per-release RBAC provisioning.
+// +kubebuilder:object:generate=true
+type RBACSpec struct {
+	// AutoProvision creates a Role and RoleBinding in the target namespace
+	// granting the operator access to exactly the namespaced resource kinds
+	// the chart renders, so the operator does not need broad permissions in
+	// that namespace. Both are deleted when the release is uninstalled.
+	// +optional
+	AutoProvision bool `json:"autoProvision,omitempty"`
+}
+
+// VerifySpec configures the provenance check of the chart and the smoke
+// tests run after it is deployed.
+type VerifySpec struct {
+	// SecretRef is a Secret in the HelmRelease's namespace holding the
+	// public keyring the chart must be signed with. Without it the chart's
+	// provenance is not checked.
+	// +optional
+	SecretRef *KeyringSecretRef `json:"secretRef,omitempty"`
+
+	// Probes are run after every install and upgrade. The release becomes
+	// Ready only once all of them pass.
+	// +optional
+	Probes []Probe `json:"probes,omitempty"`
+
+	// ProbeTimeout bounds how long the probes are retried after a deploy
+	// before the release fails. Defaults to 2m.
+	// +optional
+	ProbeTimeout *metav1.Duration `json:"probeTimeout,omitempty"`
+}
+
+// Probe is a smoke test of a deployed release. Exactly one of http and tcp
+// is set.
+type Probe struct {
+	// Name identifies the probe in status.probes.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// HTTP sends a GET request.
+	// +optional
+	HTTP *HTTPProbe `json:"http,omitempty"`
+
+	// TCP opens a connection.
+	// +optional
+	TCP *TCPProbe `json:"tcp,omitempty"`
+}
+
+// HTTPProbe checks the response to a GET request.
+type HTTPProbe struct {
+	// URL to fetch, such as the release's Ingress. Either url or service
+	// and port are set.
+	// +kubebuilder:validation:Pattern=`^https?://`
+	// +optional
+	URL string `json:"url,omitempty"`
+
+	// Service is a Service in the target namespace, fetched over plain
+	// HTTP at http://<service>.<targetNamespace>.svc:<port><path>.
+	// +optional
+	Service string `json:"service,omitempty"`
+
+	// Port of the Service.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	Port int32 `json:"port,omitempty"`
+
+	// Path requested from the Service. Defaults to "/".
+	// +optional
+	Path string `json:"path,omitempty"`
+
+	// ExpectedStatus is the status code the response must have. Defaults
+	// to 200.
+	// +optional
+	ExpectedStatus int `json:"expectedStatus,omitempty"`
+
+	// ExpectedBody is a string the response body must contain.
+	// +optional
+	ExpectedBody string `json:"expectedBody,omitempty"`
+}
+
+// TCPProbe checks that a port accepts connections.
+type TCPProbe struct {
+	// Service is a Service in the target namespace. Either service or host
+	// is set.
+	// +optional
+	Service string `json:"service,omitempty"`
+
+	// Host to connect to.
+	// +optional
+	Host string `json:"host,omitempty"`
+
+	// Port to connect to.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port int32 `json:"port"`
+}
+
+// KeyringSecretRef selects the keyring in a Secret.
+type KeyringSecretRef struct {
+	// Name of the Secret.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Key holding the keyring, as exported by gpg --export. Defaults to
+	// "pubring.gpg".
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
+// LocalSecretReference names a Secret in the HelmRelease's namespace.
+type LocalSecretReference struct {
+	// Name of the Secret.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+}
+
+// KubeConfigReference locates the kubeconfig of a remote cluster.
+type KubeConfigReference struct {
+	// SecretRef is a Secret in the HelmRelease's namespace holding the
+	// kubeconfig.
+	SecretRef KubeConfigSecretRef `json:"secretRef"`
+}
+
+// KubeConfigSecretRef selects a key of a Secret.
+type KubeConfigSecretRef struct {
+	// Name of the Secret.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Key holding the kubeconfig. Defaults to "value".
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
+// UpgradeGate is an HTTP check that must pass before an upgrade. It passes
+// when a GET of URL returns 200 and, if ExpectedBody is set, a body
+// containing it.
+type UpgradeGate struct {
+	// Name identifies the gate in the UpgradeGates condition.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// URL is fetched with a GET request.
+	// +kubebuilder:validation:Pattern=`^https?://`
+	URL string `json:"url"`
+
+	// ExpectedBody is a string the response body must contain, such as
+	// "open".
+	// +optional
+	ExpectedBody string `json:"expectedBody,omitempty"`
+
+	// Timeout bounds the request. Defaults to 10s.
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+
+	// FailurePolicy decides the outcome when the gate cannot be checked:
+	// the request fails, times out or gets a 5xx response. Closed (the
+	// default) holds the upgrade back, Open lets it proceed.
+	// +kubebuilder:validation:Enum=Open;Closed
+	// +optional
+	FailurePolicy GateFailurePolicy `json:"failurePolicy,omitempty"`
+}
+
+// GateFailurePolicy selects how an upgrade gate that cannot be checked is
+// treated.
+type GateFailurePolicy string
+
+const (
+	// GateFailOpen lets the upgrade proceed.
+	GateFailOpen GateFailurePolicy = "Open"
+	// GateFailClosed holds the upgrade back.
+	GateFailClosed GateFailurePolicy = "Closed"
+)
+
+// DriftPolicy selects how drift between the release and the cluster is handled.
+type DriftPolicy string
+
+const (
+	// DriftPolicyCorrect upgrades the release to restore drifted objects.
+	DriftPolicyCorrect DriftPolicy = "Correct"
+	// DriftPolicyWarn reports drift through the Drifted condition, an event
+	// and a metric, without changing the cluster.
+	DriftPolicyWarn DriftPolicy = "Warn"
+	// DriftPolicyIgnore disables drift detection.
+	DriftPolicyIgnore DriftPolicy = "Ignore"
+)
+
+// DriftDetectionSpec configures how the live objects of a release are
+// compared with its manifests.
+type DriftDetectionSpec struct {
+	// Mode is disabled, warn to report drift through the Drifted condition,
+	// an event and a metric, or correct to also re-apply the release.
+	// +kubebuilder:validation:Enum=disabled;warn;correct
+	// +optional
+	Mode DriftDetectionMode `json:"mode,omitempty"`
+}
+
+// DriftDetectionMode is the mode of spec.driftDetection.
+type DriftDetectionMode string
+
+const (
+	// DriftDetectionDisabled skips drift detection.
+	DriftDetectionDisabled DriftDetectionMode = "disabled"
+	// DriftDetectionWarn reports drift without changing the cluster.
+	DriftDetectionWarn DriftDetectionMode = "warn"
+	// DriftDetectionCorrect re-applies the release to restore drifted
+	// objects.
+	DriftDetectionCorrect DriftDetectionMode = "correct"
+)
+
+// PostRenderer transforms rendered chart manifests before they are applied.
+// +kubebuilder:object:generate=true
+type PostRenderer struct {
+	// Exec runs a binary with the rendered manifests on stdin and reads the
+	// transformed manifests from stdout, like helm's --post-renderer flag.
+	// +optional
+	Exec *ExecPostRenderer `json:"exec,omitempty"`
+
+	// Kustomize applies patches to the rendered manifests, for small changes
+	// such as adding labels or a sidecar without forking the chart. When
+	// Exec is set as well, it runs first.
+	// +optional
+	Kustomize *KustomizePostRenderer `json:"kustomize,omitempty"`
+}
+
+// ExecPostRenderer runs a binary shipped in the operator image as a post-renderer.
+// +kubebuilder:object:generate=true
+type ExecPostRenderer struct {
+	// Command is the binary to run, as an absolute path or a name looked up in
+	// $PATH. It must be listed in the operator's --allowed-post-renderers flag.
+	// +kubebuilder:validation:Required
+	Command string `json:"command"`
+
+	// Args are passed to the command.
+	// +optional
+	Args []string `json:"args,omitempty"`
+}
+
+// KustomizePostRenderer patches rendered manifests like kustomize's
+// patchesStrategicMerge and patchesJson6902.
+// +kubebuilder:object:generate=true
+type KustomizePostRenderer struct {
+	// PatchesStrategicMerge are partial objects merged into the rendered
+	// object with the same apiVersion, kind, metadata.name and, if the patch
+	// sets one, metadata.namespace. Built-in kinds are merged by their patch
+	// strategy, so containers are matched by name; other kinds are merged as
+	// JSON merge patches. A patch that matches no object fails the release.
+	// +optional
+	PatchesStrategicMerge []apiextensionsv1.JSON `json:"patchesStrategicMerge,omitempty"`
+
+	// PatchesJSON6902 are RFC 6902 JSON patches, each applied to the rendered
+	// objects its target selects.
+	// +optional
+	PatchesJSON6902 []JSON6902Patch `json:"patchesJson6902,omitempty"`
+}
+
+// JSON6902Patch is a JSON patch and the objects it applies to.
+// +kubebuilder:object:generate=true
+type JSON6902Patch struct {
+	// Target selects the objects to patch. It must select at least one.
+	Target PatchTarget `json:"target"`
+
+	// Patch is the list of operations.
+	// +kubebuilder:validation:MinItems=1
+	Patch []JSON6902 `json:"patch"`
+}
+
+// PatchTarget selects rendered objects. Empty fields match any value.
+// +kubebuilder:object:generate=true
+type PatchTarget struct {
+	// +optional
+	Group string `json:"group,omitempty"`
+	// +optional
+	Version string `json:"version,omitempty"`
+	// +optional
+	Kind string `json:"kind,omitempty"`
+	// +optional
+	Name string `json:"name,omitempty"`
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// JSON6902 is one operation of an RFC 6902 JSON patch.
+// +kubebuilder:object:generate=true
+type JSON6902 struct {
+	// +kubebuilder:validation:Enum=add;remove;replace;move;copy;test
+	Op string `json:"op"`
+	// Path is a JSON pointer, such as /spec/template/metadata/labels/team.
+	Path string `json:"path"`
+	// From is the source of move and copy.
+	// +optional
+	From string `json:"from,omitempty"`
+	// Value is the value of add, replace and test.
+	// +optional
+	Value *apiextensionsv1.JSON `json:"value,omitempty"`
+}
+
+// WasmMode selects how a WasmModule's output is used.
+type WasmMode string
+
+const (
+	// WasmModeTransform replaces the manifests with the module's stdout.
+	WasmModeTransform WasmMode = "Transform"
+	// WasmModePolicy rejects the release when the module exits non-zero; its
+	// stdout is reported as the reason.
+	WasmModePolicy WasmMode = "Policy"
+)
+
+// WasmModule references a WASI command module that receives the rendered
+// manifests on stdin.
+// +kubebuilder:object:generate=true
+type WasmModule struct {
+	// Name identifies the module in status messages.
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+
+	// ConfigMapRef locates the module binary in a ConfigMap in the
+	// HelmRelease's namespace. Modules cannot be pulled from OCI
+	// registries; copy them into a ConfigMap instead.
+	// +kubebuilder:validation:Required
+	ConfigMapRef ConfigMapKeyRef `json:"configMapRef"`
+
+	// Mode is Transform or Policy.
+	// +kubebuilder:validation:Enum=Transform;Policy
+	// +kubebuilder:default=Transform
+	// +optional
+	Mode WasmMode `json:"mode,omitempty"`
+}
+
+// ValuesReference selects Helm values stored in a ConfigMap or Secret in the
+// HelmRelease's namespace.
+// +kubebuilder:object:generate=true
+type ValuesReference struct {
+	// Kind is ConfigMap or Secret.
+	// +kubebuilder:validation:Enum=ConfigMap;Secret
+	Kind string `json:"kind"`
+
+	// Name of the ConfigMap or Secret.
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+
+	// ValuesKey is the key holding the values as YAML or JSON. Defaults to
+	// "values.yaml".
+	// +optional
+	ValuesKey string `json:"valuesKey,omitempty"`
+
+	// Optional skips the reference while the object or key is missing
+	// instead of waiting for it.
+	// +optional
+	Optional bool `json:"optional,omitempty"`
+}
+
+// SubstituteReference names a ConfigMap or Secret holding variables for
+// spec.valuesTemplate.
+// +kubebuilder:object:generate=true
+type SubstituteReference struct {
+	// Kind is ConfigMap or Secret.
+	// +kubebuilder:validation:Enum=ConfigMap;Secret
+	Kind string `json:"kind"`
+
+	// Name of the ConfigMap or Secret.
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+
+	// Optional skips the reference while the object is missing instead of
+	// waiting for it.
+	// +optional
+	Optional bool `json:"optional,omitempty"`
+}
+
+// ConfigMapKeyRef selects a key of a ConfigMap in the HelmRelease's namespace.
+// +kubebuilder:object:generate=true
+type ConfigMapKeyRef struct {
+	// Name of the ConfigMap.
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+
+	// Key within the ConfigMap's binaryData. Defaults to "module.wasm".
+	// +optional
+	Key string `json:"key,omitempty"`
 }
 
 // HelmReleaseStatus defines the observed state of HelmRelease.
@@ -60,10 +798,40 @@ type HelmReleaseStatus struct {
 	// +listMapKey=type
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
 
-	// DeployedVersion is the chart version currently deployed.
+	// DeployedVersion is the chart version currently deployed, which
+	// spec.version resolved to.
 	// +optional
 	DeployedVersion string `json:"deployedVersion,omitempty"`
 
+	// AppVersion is the appVersion of the deployed chart.
+	// +optional
+	AppVersion string `json:"appVersion,omitempty"`
+
+	// ChartDigest is the sha256 digest of the deployed chart: the manifest
+	// digest for an oci:// repository, the archive digest otherwise.
+	// +optional
+	ChartDigest string `json:"chartDigest,omitempty"`
+
+	// LatestVersion is the newest stable chart version published in the
+	// repository, as of the last update check.
+	// +optional
+	LatestVersion string `json:"latestVersion,omitempty"`
+
+	// ReleaseName is the name of the Helm release last deployed by the
+	// controller.
+	// +optional
+	ReleaseName string `json:"releaseName,omitempty"`
+
+	// ReleaseNamespace is the namespace of the Helm release last deployed by
+	// the controller.
+	// +optional
+	ReleaseNamespace string `json:"releaseNamespace,omitempty"`
+
+	// ReleaseKubeConfig is the spec.kubeConfig the Helm release was last
+	// deployed with; empty for this cluster.
+	// +optional
+	ReleaseKubeConfig *KubeConfigReference `json:"releaseKubeConfig,omitempty"`
+
 	// HelmRevision is the Helm release revision number.
 	// +optional
 	HelmRevision int `json:"helmRevision,omitempty"`
@@ -75,6 +843,192 @@ type HelmReleaseStatus struct {
 	// ObservedGeneration is the last generation the controller successfully reconciled.
 	// +optional
 	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// ExpiresAt is when the HelmRelease will be deleted because its TTL elapsed.
+	// +optional
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+
+	// LastApplied describes the inputs of the last successful install or
+	// upgrade. The release is upgraded only when the desired inputs differ.
+	// +optional
+	LastApplied *AppliedSpec `json:"lastApplied,omitempty"`
+
+	// MissingPermissions lists the API requests the operator was denied
+	// during the last failed reconcile. Cleared on success.
+	// +optional
+	MissingPermissions []PermissionRule `json:"missingPermissions,omitempty"`
+
+	// LastDeployTrigger records what caused the last successful install or
+	// upgrade. The same information is in the Helm revision's description.
+	// +optional
+	LastDeployTrigger *DeployTrigger `json:"lastDeployTrigger,omitempty"`
+
+	// ConsecutiveFailures counts the reconciles that failed since the last
+	// successful one.
+	// +optional
+	ConsecutiveFailures int `json:"consecutiveFailures,omitempty"`
+
+	// Failures counts the failed attempts at the current generation since
+	// the last success. It sets the retry backoff and is compared with
+	// spec.maxRetries. Unlike consecutiveFailures it restarts when the spec
+	// changes.
+	// +optional
+	Failures int `json:"failures,omitempty"`
+
+	// LastFailureTime is when the release last failed.
+	// +optional
+	LastFailureTime *metav1.Time `json:"lastFailureTime,omitempty"`
+
+	// LastAttempt describes the most recent reconcile, successful or not.
+	// Unlike phase and conditions it changes on every attempt.
+	// +optional
+	LastAttempt *LastAttempt `json:"lastAttempt,omitempty"`
+
+	// LastKnownGoodSpec is the spec of the last generation that reconciled
+	// to Ready. POST /api/helmreleases/revert-spec restores it, undoing a
+	// bad change to the HelmRelease itself rather than only rolling back
+	// the Helm release.
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +optional
+	LastKnownGoodSpec *apiextensionsv1.JSON `json:"lastKnownGoodSpec,omitempty"`
+
+	// Probes holds the results of spec.verify.probes from the last time
+	// they ran.
+	// +optional
+	Probes []ProbeResult `json:"probes,omitempty"`
+
+	// History lists the newest revisions of the Helm release, newest first,
+	// as recorded after each install, upgrade or rollback. The number kept
+	// is capped by the operator's --status-history flag.
+	// +optional
+	History []RevisionStatus `json:"history,omitempty"`
+}
+
+// RevisionStatus describes one revision of the Helm release.
+type RevisionStatus struct {
+	// Revision is the Helm revision number.
+	Revision int `json:"revision"`
+
+	// ChartVersion is the version of the chart the revision deployed.
+	// +optional
+	ChartVersion string `json:"chartVersion,omitempty"`
+
+	// Status is the Helm status of the revision, such as deployed,
+	// superseded or failed.
+	Status string `json:"status"`
+
+	// DeployedAt is when Helm deployed the revision.
+	// +optional
+	DeployedAt *metav1.Time `json:"deployedAt,omitempty"`
+
+	// Description is Helm's description of the revision.
+	// +optional
+	Description string `json:"description,omitempty"`
+}
+
+// ProbeResult is the outcome of one of spec.verify.probes.
+type ProbeResult struct {
+	// Name of the probe.
+	Name string `json:"name"`
+
+	// Passed reports whether the probe succeeded.
+	Passed bool `json:"passed"`
+
+	// Message describes the response, or why the probe failed.
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// CheckedAt is when the probe last ran.
+	CheckedAt metav1.Time `json:"checkedAt"`
+}
+
+// LastAttempt describes a single reconcile of a HelmRelease.
+// +kubebuilder:object:generate=true
+type LastAttempt struct {
+	// Operation is Install or Upgrade when Helm ran, Uninstall when the
+	// release was being deleted, and Reconcile otherwise.
+	// +kubebuilder:validation:Enum=Install;Upgrade;Uninstall;Reconcile
+	Operation string `json:"operation"`
+
+	// StartedAt is when the reconcile started.
+	StartedAt metav1.Time `json:"startedAt"`
+
+	// Duration of the reconcile.
+	Duration metav1.Duration `json:"duration"`
+
+	// Outcome is Succeeded or Failed.
+	// +kubebuilder:validation:Enum=Succeeded;Failed
+	Outcome string `json:"outcome"`
+
+	// ErrorClass classifies a failure: Transient for errors that retrying
+	// may fix, Forbidden when the operator lacked permissions, Permanent
+	// otherwise.
+	// +kubebuilder:validation:Enum=Transient;Forbidden;Permanent
+	// +optional
+	ErrorClass string `json:"errorClass,omitempty"`
+
+	// Error is the failure message.
+	// +optional
+	Error string `json:"error,omitempty"`
+}
+
+// DeployTrigger describes the cause of a Helm install or upgrade.
+// +kubebuilder:object:generate=true
+type DeployTrigger struct {
+	// Cause is Install, SpecChange, DriftCorrection or Retry.
+	Cause string `json:"cause"`
+
+	// User who last changed the HelmRelease through the web UI, from the
+	// helm.example.com/triggered-by annotation.
+	// +optional
+	User string `json:"user,omitempty"`
+
+	// CommitSHA of the GitOps commit that produced the spec, from the
+	// helm.example.com/commit-sha annotation.
+	// +optional
+	CommitSHA string `json:"commitSHA,omitempty"`
+
+	// CIJobURL of the pipeline that produced the spec, from the
+	// helm.example.com/ci-job-url annotation.
+	// +optional
+	CIJobURL string `json:"ciJobURL,omitempty"`
+
+	// Time the operation started.
+	Time metav1.Time `json:"time"`
+}
+
+// PermissionRule is a permission the operator lacked.
+// +kubebuilder:object:generate=true
+type PermissionRule struct {
+	// APIGroup of the resource; empty for the core group.
+	APIGroup string `json:"apiGroup"`
+
+	// Resource is the plural resource name, with a subresource if any.
+	Resource string `json:"resource"`
+
+	// Verbs that were denied.
+	Verbs []string `json:"verbs"`
+
+	// Namespace of the denied requests; empty at cluster scope.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// AppliedSpec is a snapshot of what the controller handed to Helm.
+// +kubebuilder:object:generate=true
+type AppliedSpec struct {
+	// Chart is the chart name.
+	Chart string `json:"chart"`
+
+	// RepoURL is the repository the chart was fetched from, after rewrites.
+	RepoURL string `json:"repoURL"`
+
+	// Version is the requested chart version.
+	Version string `json:"version"`
+
+	// Digest is a SHA-256 over the chart, repository, version, resolved values
+	// and post-rendering configuration.
+	Digest string `json:"digest"`
 }
 
 // HelmRelease is the Schema for the helmreleases API.
@@ -86,6 +1040,11 @@ type HelmReleaseStatus struct {
 // +kubebuilder:printcolumn:name="Version",type=string,JSONPath=`.spec.version`
 // +kubebuilder:printcolumn:name="Namespace",type=string,JSONPath=`.spec.targetNamespace`
 // +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Suspended",type=boolean,JSONPath=`.spec.suspend`
+// +kubebuilder:printcolumn:name="Update",type=string,JSONPath=`.status.conditions[?(@.type=="UpdateAvailable")].status`
+// +kubebuilder:printcolumn:name="Latest",type=string,JSONPath=`.status.latestVersion`,priority=1
+// +kubebuilder:printcolumn:name="Deployed",type=string,JSONPath=`.status.deployedVersion`,priority=1
+// +kubebuilder:printcolumn:name="App Version",type=string,JSONPath=`.status.appVersion`,priority=1
 // +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 type HelmRelease struct {
 	metav1.TypeMeta   `json:",inline"`
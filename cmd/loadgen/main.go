@@ -0,0 +1,181 @@
+// Command loadgen creates synthetic HelmReleases and measures how quickly the
+// operator reconciles them to Ready. Run it against an operator started with
+// --helm-backend=fake so the numbers reflect the controller rather than Helm:
+//
+//	go run . --helm-backend=fake --fake-helm-latency=200ms --leader-elect=false &
+//	go run ./cmd/loadgen --count=500 --namespace=loadgen
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const loadgenLabel = "helm.example.com/loadgen"
+
+func main() {
+	var (
+		count       int
+		namespace   string
+		concurrency int
+		timeout     time.Duration
+		keep        bool
+	)
+	flag.IntVar(&count, "count", 100, "Number of HelmReleases to create.")
+	flag.StringVar(&namespace, "namespace", "loadgen", "Namespace for the synthetic HelmReleases; created if missing.")
+	flag.IntVar(&concurrency, "concurrency", 10, "Parallel create requests.")
+	flag.DurationVar(&timeout, "timeout", 10*time.Minute, "How long to wait for every release to become Ready.")
+	flag.BoolVar(&keep, "keep", false, "Leave the HelmReleases in place instead of deleting them afterwards.")
+	flag.Parse()
+
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = helmv1alpha1.AddToScheme(scheme)
+
+	c, err := client.New(ctrl.GetConfigOrDie(), client.Options{Scheme: scheme})
+	if err != nil {
+		fail(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := c.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}); err != nil && !apierrors.IsAlreadyExists(err) {
+		fail(err)
+	}
+
+	runID := time.Now().Format("150405")
+	created := make(map[string]time.Time, count)
+	start := time.Now()
+	if err := createReleases(ctx, c, namespace, runID, count, concurrency, created); err != nil {
+		fail(err)
+	}
+	fmt.Printf("created %d HelmReleases in %s\n", count, time.Since(start).Round(time.Millisecond))
+
+	latencies, err := waitReady(ctx, c, namespace, runID, created)
+	elapsed := time.Since(start)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "loadgen: %v (%d/%d ready)\n", err, len(latencies), count)
+	}
+	report(latencies, elapsed)
+
+	if !keep {
+		err := c.DeleteAllOf(context.Background(), &helmv1alpha1.HelmRelease{},
+			client.InNamespace(namespace), client.MatchingLabels{loadgenLabel: runID})
+		if err != nil {
+			fail(err)
+		}
+	}
+}
+
+// createReleases creates count HelmReleases, recording each one's creation time.
+func createReleases(ctx context.Context, c client.Client, namespace, runID string, count, concurrency int, created map[string]time.Time) error {
+	type result struct {
+		name string
+		at   time.Time
+		err  error
+	}
+	names := make(chan string)
+	results := make(chan result)
+	for w := 0; w < concurrency; w++ {
+		go func() {
+			for name := range names {
+				hr := &helmv1alpha1.HelmRelease{
+					ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: map[string]string{loadgenLabel: runID}},
+					Spec: helmv1alpha1.HelmReleaseSpec{
+						Chart:           "loadgen",
+						RepoURL:         "https://charts.example.invalid",
+						Version:         "1.0.0",
+						TargetNamespace: namespace,
+					},
+				}
+				at := time.Now()
+				results <- result{name: name, at: at, err: c.Create(ctx, hr)}
+			}
+		}()
+	}
+	go func() {
+		for i := 0; i < count; i++ {
+			names <- fmt.Sprintf("loadgen-%s-%d", runID, i)
+		}
+		close(names)
+	}()
+
+	var firstErr error
+	for i := 0; i < count; i++ {
+		r := <-results
+		if r.err != nil && firstErr == nil {
+			firstErr = r.err
+		}
+		created[r.name] = r.at
+	}
+	return firstErr
+}
+
+// waitReady polls until every created release is Ready and returns the
+// create-to-Ready latency of each.
+func waitReady(ctx context.Context, c client.Client, namespace, runID string, created map[string]time.Time) ([]time.Duration, error) {
+	ready := map[string]time.Duration{}
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	for len(ready) < len(created) {
+		var list helmv1alpha1.HelmReleaseList
+		if err := c.List(ctx, &list, client.InNamespace(namespace), client.MatchingLabels{loadgenLabel: runID}); err != nil {
+			return values(ready), err
+		}
+		// Status timestamps have second precision, so latency is measured
+		// from our own observations, accurate to the polling interval.
+		now := time.Now()
+		for _, hr := range list.Items {
+			if _, done := ready[hr.Name]; done || hr.Status.Phase != helmv1alpha1.PhaseReady {
+				continue
+			}
+			ready[hr.Name] = now.Sub(created[hr.Name])
+		}
+		select {
+		case <-ctx.Done():
+			return values(ready), ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return values(ready), nil
+}
+
+func report(latencies []time.Duration, elapsed time.Duration) {
+	if len(latencies) == 0 {
+		fmt.Println("no releases became Ready")
+		return
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	pct := func(p float64) time.Duration {
+		return latencies[int(p*float64(len(latencies)-1))].Round(time.Millisecond)
+	}
+	fmt.Printf("ready:       %d in %s\n", len(latencies), elapsed.Round(time.Millisecond))
+	fmt.Printf("throughput:  %.1f releases/s\n", float64(len(latencies))/elapsed.Seconds())
+	fmt.Printf("latency:     p50=%s p90=%s p99=%s max=%s\n", pct(0.50), pct(0.90), pct(0.99), pct(1))
+}
+
+func values(m map[string]time.Duration) []time.Duration {
+	out := make([]time.Duration, 0, len(m))
+	for _, d := range m {
+		out = append(out, d)
+	}
+	return out
+}
+
+func fail(err error) {
+	fmt.Fprintf(os.Stderr, "loadgen: %v\n", err)
+	os.Exit(1)
+}
@@ -0,0 +1,10 @@
+// Package crd embeds the operator's CustomResourceDefinitions so that it can
+// install them itself with --install-crds.
+package crd
+
+import "embed"
+
+// Bases holds the generated CRD manifests, one per file.
+//
+//go:embed bases/*.yaml
+var Bases embed.FS
@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"github.com/example/helm-operator/notify"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// DefaultTeamLabel is the HelmRelease label naming the team that owns it.
+const DefaultTeamLabel = "team"
+
+// alertTimeout bounds the delivery of one alert.
+const alertTimeout = 30 * time.Second
+
+// ReleaseAlerts notifies the team owning a release when it fails or starts
+// to drift, so each team gets the alerts of its own releases only. Alerts
+// are sent in the background and do not delay reconciles.
+type ReleaseAlerts struct {
+	Routes *notify.Router
+	// TeamLabel is the HelmRelease label naming its team. Defaults to
+	// DefaultTeamLabel.
+	TeamLabel string
+}
+
+// failed alerts that release has turned Failed.
+func (a *ReleaseAlerts) failed(release *helmv1alpha1.HelmRelease, err error) {
+	a.send(release, fmt.Sprintf("HelmRelease %s/%s failed", release.Namespace, release.Name),
+		fmt.Sprintf("%s %s: %v", release.Spec.Chart, release.Spec.Version, err))
+}
+
+// drifted alerts that the live objects of release no longer match its
+// manifests.
+func (a *ReleaseAlerts) drifted(release *helmv1alpha1.HelmRelease, drift string, correcting bool) {
+	body := drift
+	if correcting {
+		body += "\nThe operator is re-applying the release."
+	}
+	a.send(release, fmt.Sprintf("HelmRelease %s/%s drifted", release.Namespace, release.Name), body)
+}
+
+func (a *ReleaseAlerts) send(release *helmv1alpha1.HelmRelease, subject, body string) {
+	if a == nil {
+		return
+	}
+	team := release.Labels[a.teamLabel()]
+	n := a.Routes.For(team)
+	if n == nil {
+		return
+	}
+	msg := notify.Message{Subject: subject, Body: strings.TrimSpace(body)}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), alertTimeout)
+		defer cancel()
+		if err := n.Notify(ctx, msg); err != nil {
+			ctrl.Log.WithName("alerts").Error(err, "Sending alert", "team", team, "subject", subject)
+		}
+	}()
+}
+
+func (a *ReleaseAlerts) teamLabel() string {
+	if a.TeamLabel != "" {
+		return a.TeamLabel
+	}
+	return DefaultTeamLabel
+}
@@ -0,0 +1,57 @@
+package controllers_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/example/helm-operator/controllers"
+	"github.com/example/helm-operator/notify"
+)
+
+// recordingNotifier keeps the subjects of the messages it receives.
+type recordingNotifier struct {
+	mu       sync.Mutex
+	subjects []string
+}
+
+func (n *recordingNotifier) Notify(_ context.Context, msg notify.Message) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.subjects = append(n.subjects, msg.Subject)
+	return nil
+}
+
+func (n *recordingNotifier) received() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]string(nil), n.subjects...)
+}
+
+var _ = Describe("Team alerts", func() {
+	ctx := context.Background()
+
+	It("alerts the team named by the release's label when it fails", func() {
+		payments, platform := &recordingNotifier{}, &recordingNotifier{}
+		mock := &MockHelmClient{InstallErr: errors.New("chart hooks failed")}
+		cancel := startManager(mock, func(r *controllers.HelmReleaseReconciler) {
+			r.Alerts = &controllers.ReleaseAlerts{Routes: &notify.Router{
+				Teams:   map[string]notify.Notifier{"payments": payments},
+				Default: platform,
+			}}
+		})
+		defer cancel()
+
+		hr := makeHR("test-alerts-failed")
+		hr.Labels = map[string]string{"team": "payments"}
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+		Eventually(payments.received).WithTimeout(timeout).WithPolling(polling).
+			Should(ConsistOf("HelmRelease default/test-alerts-failed failed"))
+		Expect(platform.received()).To(BeEmpty())
+	})
+})
@@ -0,0 +1,82 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+)
+
+// desiredApply snapshots what reconciling the release would hand to Helm.
+// values must already be parsed and rewritten.
+func desiredApply(release *helmv1alpha1.HelmRelease, repoURL string, values map[string]interface{}) (*helmv1alpha1.AppliedSpec, error) {
+	// encoding/json sorts map keys, so equal inputs always hash the same.
+	data, err := json.Marshal(struct {
+		Chart         string                      `json:"chart"`
+		RepoURL       string                      `json:"repoURL"`
+		Version       string                      `json:"version"`
+		Values        map[string]interface{}      `json:"values"`
+		PostRenderers []helmv1alpha1.PostRenderer `json:"postRenderers,omitempty"`
+		WasmModules   []helmv1alpha1.WasmModule   `json:"wasmModules,omitempty"`
+		// AllowPrerelease can change what a constraint resolves to.
+		AllowPrerelease bool   `json:"allowPrerelease,omitempty"`
+		ChartDigest     string `json:"chartDigest,omitempty"`
+	}{release.Spec.Chart, repoURL, release.Spec.Version, values, release.Spec.PostRenderers, release.Spec.WasmModules,
+		release.Spec.AllowPrerelease, release.Spec.ChartDigest})
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	return &helmv1alpha1.AppliedSpec{
+		Chart:   release.Spec.Chart,
+		RepoURL: repoURL,
+		Version: release.Spec.Version,
+		Digest:  hex.EncodeToString(sum[:]),
+	}, nil
+}
+
+// recordLastKnownGood stores the spec of a release that just reconciled to
+// Ready as status.lastKnownGoodSpec.
+func recordLastKnownGood(release *helmv1alpha1.HelmRelease) error {
+	data, err := json.Marshal(release.Spec)
+	if err != nil {
+		return err
+	}
+	release.Status.LastKnownGoodSpec = &apiextensionsv1.JSON{Raw: data}
+	return nil
+}
+
+// needsApply reports whether the deployed release is out of date with
+// desired. Releases deployed before LastApplied was recorded fall back to
+// comparing generations.
+func needsApply(release *helmv1alpha1.HelmRelease, desired *helmv1alpha1.AppliedSpec) bool {
+	if release.Status.LastApplied == nil {
+		return release.Status.ObservedGeneration != release.Generation || release.Status.Phase == helmv1alpha1.PhaseFailed
+	}
+	return release.Status.LastApplied.Digest != desired.Digest
+}
+
+// retryAfterFailure returns how long to wait before retrying a release that
+// failed for its current generation, or 0 if it may be retried now. The wait
+// grows with status.failures; see failureBackoff.
+func retryAfterFailure(release *helmv1alpha1.HelmRelease) time.Duration {
+	if release.Status.Phase != helmv1alpha1.PhaseFailed || release.Status.ObservedGeneration != release.Generation {
+		return 0
+	}
+	ready := meta.FindStatusCondition(release.Status.Conditions, "Ready")
+	if ready == nil || ready.Reason == reasonDependencyCycle {
+		return 0
+	}
+	since := ready.LastTransitionTime.Time
+	if t := release.Status.LastFailureTime; t != nil {
+		since = t.Time
+	}
+	if wait := failureBackoff(release.Status.Failures) - time.Since(since); wait > 0 {
+		return wait
+	}
+	return 0
+}
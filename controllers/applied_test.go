@@ -0,0 +1,54 @@
+package controllers_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Applied spec tracking", func() {
+	ctx := context.Background()
+
+	It("does not upgrade for spec changes that do not affect the release", func() {
+		mock := &MockHelmClient{ReleaseExistsResult: true}
+		cancel := startManager(mock)
+		defer cancel()
+
+		hr := makeHR("test-applied-noop")
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+		Eventually(func(g Gomega) {
+			fetched, err := getHR(ctx, hr.Name)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(fetched.Status.Phase).To(Equal(helmv1alpha1.PhaseReady))
+			g.Expect(fetched.Status.LastApplied).NotTo(BeNil())
+			g.Expect(fetched.Status.LastApplied.Version).To(Equal("1.0.0"))
+			g.Expect(fetched.Status.LastApplied.Digest).NotTo(BeEmpty())
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+
+		mock.mu.Lock()
+		mock.UpgradeCalled = false
+		mock.mu.Unlock()
+
+		fetched, err := getHR(ctx, hr.Name)
+		Expect(err).NotTo(HaveOccurred())
+		patch := client.MergeFrom(fetched.DeepCopy())
+		fetched.Spec.DriftPolicy = helmv1alpha1.DriftPolicyIgnore
+		Expect(k8sClient.Patch(ctx, fetched, patch)).To(Succeed())
+
+		Eventually(func(g Gomega) {
+			latest, err := getHR(ctx, hr.Name)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(latest.Status.ObservedGeneration).To(Equal(latest.Generation))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+
+		mock.mu.Lock()
+		defer mock.mu.Unlock()
+		Expect(mock.UpgradeCalled).To(BeFalse())
+	})
+})
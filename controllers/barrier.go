@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	upgradeWaitSeconds = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "helm_operator_namespace_upgrade_wait_seconds",
+		Help:    "Time upgrades spent queued behind another upgrade in the same target namespace.",
+		Buckets: []float64{0.01, 0.1, 1, 5, 15, 30, 60, 120, 300, 600},
+	})
+	upgradesWaiting = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "helm_operator_namespace_upgrades_waiting",
+		Help: "Number of upgrades currently queued behind another upgrade in their target namespace.",
+	})
+)
+
+func init() {
+	ctrlmetrics.Registry.MustRegister(upgradeWaitSeconds, upgradesWaiting)
+}
+
+// NamespaceBarrier lets at most one upgrade run per target namespace, so
+// releases sharing a namespace do not compete for its resources or flood its
+// admission webhooks. Upgrades in different namespaces still run in parallel,
+// up to MaxConcurrentReconciles.
+//
+// A nil *NamespaceBarrier lets every upgrade through.
+type NamespaceBarrier struct {
+	mu    sync.Mutex
+	slots map[string]chan struct{}
+}
+
+// Enter blocks until no other upgrade runs in namespace, or ctx is done, and
+// returns the function that lets the next one in.
+func (b *NamespaceBarrier) Enter(ctx context.Context, namespace string) (func(), error) {
+	if b == nil {
+		return func() {}, nil
+	}
+	b.mu.Lock()
+	if b.slots == nil {
+		b.slots = map[string]chan struct{}{}
+	}
+	slot, ok := b.slots[namespace]
+	if !ok {
+		slot = make(chan struct{}, 1)
+		b.slots[namespace] = slot
+	}
+	b.mu.Unlock()
+
+	start := time.Now()
+	select {
+	case slot <- struct{}{}:
+	default:
+		upgradesWaiting.Inc()
+		defer upgradesWaiting.Dec()
+		select {
+		case slot <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	upgradeWaitSeconds.Observe(time.Since(start).Seconds())
+	return func() { <-slot }, nil
+}
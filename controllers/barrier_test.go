@@ -0,0 +1,51 @@
+package controllers_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/example/helm-operator/controllers"
+)
+
+var _ = Describe("Namespace upgrade barrier", func() {
+	ctx := context.Background()
+
+	It("lets one upgrade per namespace run at a time", func() {
+		barrier := &controllers.NamespaceBarrier{}
+		leave, err := barrier.Enter(ctx, "shop")
+		Expect(err).NotTo(HaveOccurred())
+
+		entered := make(chan func())
+		go func() {
+			defer GinkgoRecover()
+			next, err := barrier.Enter(ctx, "shop")
+			Expect(err).NotTo(HaveOccurred())
+			entered <- next
+		}()
+		Consistently(entered).WithTimeout(500 * time.Millisecond).ShouldNot(Receive())
+
+		other, err := barrier.Enter(ctx, "billing")
+		Expect(err).NotTo(HaveOccurred())
+		other()
+
+		leave()
+		var next func()
+		Eventually(entered).WithTimeout(timeout).Should(Receive(&next))
+		next()
+	})
+
+	It("stops waiting when the context is cancelled", func() {
+		barrier := &controllers.NamespaceBarrier{}
+		leave, err := barrier.Enter(ctx, "shop")
+		Expect(err).NotTo(HaveOccurred())
+		defer leave()
+
+		waitCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+		defer cancel()
+		_, err = barrier.Enter(waitCtx, "shop")
+		Expect(err).To(MatchError(context.DeadlineExceeded))
+	})
+})
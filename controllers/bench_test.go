@@ -0,0 +1,77 @@
+package controllers_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"github.com/example/helm-operator/controllers"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// The benchmarks use the controller-runtime fake client instead of envtest;
+// run them without the Ginkgo suite:
+//
+//	go test -run='^$' -bench=. -benchmem ./controllers/
+
+// BenchmarkReconcileSteadyState measures a reconcile of a release that is
+// already deployed and up to date, the most common case on a busy cluster.
+func BenchmarkReconcileSteadyState(b *testing.B) {
+	benchmarkReconcile(b, nil)
+}
+
+// BenchmarkReconcileLargeValues is the steady state with a large values tree.
+func BenchmarkReconcileLargeValues(b *testing.B) {
+	values := map[string]interface{}{}
+	for i := 0; i < 200; i++ {
+		values[fmt.Sprintf("component%d", i)] = map[string]interface{}{
+			"image":     map[string]interface{}{"repository": "registry.example.com/app", "tag": "1.0.0"},
+			"resources": map[string]interface{}{"limits": map[string]interface{}{"cpu": "500m", "memory": "256Mi"}},
+			"replicas":  3,
+		}
+	}
+	raw, err := json.Marshal(values)
+	if err != nil {
+		b.Fatal(err)
+	}
+	benchmarkReconcile(b, raw)
+}
+
+func benchmarkReconcile(b *testing.B, values []byte) {
+	s := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(s)
+	_ = helmv1alpha1.AddToScheme(s)
+
+	hr := makeHR("bench")
+	hr.Finalizers = []string{"helm.example.com/finalizer"}
+	hr.Generation = 1
+	if values != nil {
+		hr.Spec.Values = &apiextensionsv1.JSON{Raw: values}
+	}
+	c := fake.NewClientBuilder().WithScheme(s).WithObjects(hr).WithStatusSubresource(hr).Build()
+
+	helm := &controllers.FakeHelmClient{}
+	r := &controllers.HelmReleaseReconciler{Client: c, Scheme: s, HelmClient: helm}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: hr.Name, Namespace: hr.Namespace}}
+	ctx := context.Background()
+
+	// The first reconcile installs; later ones find the release up to date.
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := r.Reconcile(ctx, req); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
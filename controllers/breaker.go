@@ -0,0 +1,258 @@
+package controllers
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"github.com/prometheus/client_golang/prometheus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const conditionRepositoryDegraded = "RepositoryDegraded"
+
+// Breaker states reported by RepositoryBreakers.Snapshot.
+const (
+	BreakerClosed   = "Closed"
+	BreakerOpen     = "Open"
+	BreakerHalfOpen = "HalfOpen"
+)
+
+var (
+	breakerOpenGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "helm_operator_repository_breaker_open",
+		Help: "Whether the circuit breaker for a chart repository is open (1) or closed (0).",
+	}, []string{"repository"})
+	breakerTripsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "helm_operator_repository_breaker_trips_total",
+		Help: "Number of times the circuit breaker for a chart repository has tripped.",
+	}, []string{"repository"})
+)
+
+func init() {
+	ctrlmetrics.Registry.MustRegister(breakerOpenGauge, breakerTripsTotal)
+}
+
+// RepositoryBreakers holds one circuit breaker per chart repository. When
+// Threshold install/upgrade failures against the same repository happen
+// within Window, the breaker opens and releases from that repository are not
+// attempted until the cooldown has passed. The first attempt after the
+// cooldown is a probe: success closes the breaker, failure re-opens it with
+// the cooldown doubled, up to MaxCooldown.
+//
+// A nil *RepositoryBreakers allows everything.
+type RepositoryBreakers struct {
+	Threshold   int
+	Window      time.Duration
+	Cooldown    time.Duration
+	MaxCooldown time.Duration
+
+	mu    sync.Mutex
+	repos map[string]*repoBreaker
+}
+
+type repoBreaker struct {
+	failures []time.Time // within Window, oldest first
+	trips    int         // consecutive trips without a success
+	openedAt time.Time
+	retryAt  time.Time
+	probing  bool
+}
+
+// RepositoryState is a point-in-time view of one repository's breaker.
+type RepositoryState struct {
+	URL            string     `json:"url"`
+	State          string     `json:"state"`
+	RecentFailures int        `json:"recentFailures"`
+	OpenedAt       *time.Time `json:"openedAt,omitempty"`
+	RetryAt        *time.Time `json:"retryAt,omitempty"`
+}
+
+// NewRepositoryBreakers returns breakers that open after threshold failures
+// within window and stay open for cooldown (doubling up to 16x on repeated trips).
+func NewRepositoryBreakers(threshold int, window, cooldown time.Duration) *RepositoryBreakers {
+	return &RepositoryBreakers{
+		Threshold:   threshold,
+		Window:      window,
+		Cooldown:    cooldown,
+		MaxCooldown: 16 * cooldown,
+	}
+}
+
+// Allow reports whether a Helm operation against repo may run now. If not, it
+// returns how long to wait before asking again. probe reports that the
+// operation is the half-open breaker's only attempt: its caller must report
+// the outcome with RecordSuccess or RecordFailure, or call AbandonProbe if
+// it stops before running Helm, or no other release is let through.
+func (b *RepositoryBreakers) Allow(repo string) (ok bool, wait time.Duration, probe bool) {
+	if b == nil || repo == "" {
+		return true, 0, false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	rb := b.repos[repo]
+	if rb == nil || rb.retryAt.IsZero() {
+		return true, 0, false
+	}
+	now := time.Now()
+	if wait := rb.retryAt.Sub(now); wait > 0 {
+		return false, wait, false
+	}
+	if rb.probing {
+		// Another release is already probing; check back shortly.
+		return false, b.Cooldown, false
+	}
+	rb.probing = true
+	return true, 0, true
+}
+
+// AbandonProbe hands back the probe Allow gave out for repo when it ended
+// without an outcome, so the next release may probe.
+func (b *RepositoryBreakers) AbandonProbe(repo string) {
+	if b == nil || repo == "" {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if rb := b.repos[repo]; rb != nil {
+		rb.probing = false
+	}
+}
+
+// RecordSuccess closes the breaker for repo.
+func (b *RepositoryBreakers) RecordSuccess(repo string) {
+	if b == nil || repo == "" {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if rb := b.repos[repo]; rb != nil {
+		delete(b.repos, repo)
+		if !rb.retryAt.IsZero() {
+			breakerOpenGauge.WithLabelValues(repo).Set(0)
+		}
+	}
+}
+
+// RecordFailure counts a failed operation against repo, opening the breaker
+// once Threshold failures fall within Window.
+func (b *RepositoryBreakers) RecordFailure(repo string) {
+	if b == nil || repo == "" {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.repos == nil {
+		b.repos = map[string]*repoBreaker{}
+	}
+	rb := b.repos[repo]
+	if rb == nil {
+		rb = &repoBreaker{}
+		b.repos[repo] = rb
+	}
+	now := time.Now()
+	rb.failures = append(pruneBefore(rb.failures, now.Add(-b.Window)), now)
+
+	switch {
+	case rb.probing:
+		rb.probing = false
+	case !rb.retryAt.IsZero():
+		// Failures reported by attempts started before the breaker opened.
+		return
+	case len(rb.failures) < b.Threshold:
+		return
+	}
+
+	rb.trips++
+	cooldown := b.Cooldown << (rb.trips - 1)
+	if b.MaxCooldown > 0 && (cooldown > b.MaxCooldown || cooldown <= 0) {
+		cooldown = b.MaxCooldown
+	}
+	rb.openedAt = now
+	rb.retryAt = now.Add(cooldown)
+	breakerOpenGauge.WithLabelValues(repo).Set(1)
+	breakerTripsTotal.WithLabelValues(repo).Inc()
+}
+
+// Snapshot returns the state of every repository with recent failures.
+func (b *RepositoryBreakers) Snapshot() []RepositoryState {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	states := make([]RepositoryState, 0, len(b.repos))
+	for repo, rb := range b.repos {
+		rb.failures = pruneBefore(rb.failures, now.Add(-b.Window))
+		s := RepositoryState{URL: repo, State: BreakerClosed, RecentFailures: len(rb.failures)}
+		if !rb.retryAt.IsZero() {
+			openedAt, retryAt := rb.openedAt, rb.retryAt
+			s.OpenedAt, s.RetryAt = &openedAt, &retryAt
+			s.State = BreakerOpen
+			if !now.Before(retryAt) {
+				s.State = BreakerHalfOpen
+			}
+		}
+		states = append(states, s)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].URL < states[j].URL })
+	return states
+}
+
+// RecentFailures returns the number of failures recorded for repo within Window.
+func (b *RepositoryBreakers) RecentFailures(repo string) int {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if rb := b.repos[repo]; rb != nil {
+		return len(rb.failures)
+	}
+	return 0
+}
+
+// pruneBefore drops the timestamps older than cutoff.
+func pruneBefore(ts []time.Time, cutoff time.Time) []time.Time {
+	i := 0
+	for i < len(ts) && ts[i].Before(cutoff) {
+		i++
+	}
+	return ts[i:]
+}
+
+// setRepositoryDegraded records that the release is waiting for its
+// repository's circuit breaker. Like setFailedStatus it returns nil so the
+// caller can requeue without an error.
+func (r *HelmReleaseReconciler) setRepositoryDegraded(release *helmv1alpha1.HelmRelease, repoURL string, wait time.Duration) error {
+	setCondition(release, metav1.Condition{
+		Type:   conditionRepositoryDegraded,
+		Status: metav1.ConditionTrue,
+		Reason: "CircuitOpen",
+		Message: fmt.Sprintf("%d recent failures against %s; next attempt in %s",
+			r.Breakers.RecentFailures(repoURL), repoURL, wait.Round(time.Second)),
+		ObservedGeneration: release.Generation,
+	})
+	return nil
+}
+
+// clearRepositoryDegraded flips an existing RepositoryDegraded condition to
+// False once an operation against the repository succeeds.
+func clearRepositoryDegraded(release *helmv1alpha1.HelmRelease) {
+	for _, c := range release.Status.Conditions {
+		if c.Type == conditionRepositoryDegraded && c.Status != metav1.ConditionFalse {
+			setCondition(release, metav1.Condition{
+				Type:               conditionRepositoryDegraded,
+				Status:             metav1.ConditionFalse,
+				Reason:             "CircuitClosed",
+				Message:            "Repository is reachable",
+				ObservedGeneration: release.Generation,
+			})
+			return
+		}
+	}
+}
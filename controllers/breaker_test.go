@@ -0,0 +1,105 @@
+package controllers_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"github.com/example/helm-operator/controllers"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("RepositoryBreakers", func() {
+	ctx := context.Background()
+
+	It("stops attempting releases from a failing repository", func() {
+		mock := &MockHelmClient{InstallErr: errors.New("repo unavailable")}
+		breakers := controllers.NewRepositoryBreakers(1, time.Minute, time.Hour)
+		cancel := startManager(mock, func(r *controllers.HelmReleaseReconciler) {
+			r.Breakers = breakers
+		})
+		defer cancel()
+
+		first := makeHR("test-breaker-first")
+		first.Spec.RepoURL = "https://down.example.com"
+		Expect(k8sClient.Create(ctx, first)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, first) })
+
+		Eventually(func(g Gomega) {
+			states := breakers.Snapshot()
+			g.Expect(states).To(HaveLen(1))
+			g.Expect(states[0].URL).To(Equal("https://down.example.com"))
+			g.Expect(states[0].State).To(Equal(controllers.BreakerOpen))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+
+		second := makeHR("test-breaker-second")
+		second.Spec.RepoURL = "https://down.example.com"
+		Expect(k8sClient.Create(ctx, second)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, second) })
+
+		Eventually(func(g Gomega) {
+			fetched, err := getHR(ctx, second.Name)
+			g.Expect(err).NotTo(HaveOccurred())
+			cond := findCondition(fetched, "RepositoryDegraded")
+			g.Expect(cond).NotTo(BeNil())
+			g.Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+
+		mock.mu.Lock()
+		defer mock.mu.Unlock()
+		Expect(mock.InstallArgs.ReleaseName).To(Equal(first.Name))
+	})
+
+	It("lets another release probe when the probing one returns before Helm runs", func() {
+		mock := &MockHelmClient{InstallErr: errors.New("repo unavailable")}
+		breakers := controllers.NewRepositoryBreakers(1, time.Minute, time.Second)
+		cancel := startManager(mock, func(r *controllers.HelmReleaseReconciler) {
+			r.Breakers = breakers
+		})
+		defer cancel()
+
+		failing := makeHR("test-breaker-probe-failing")
+		failing.Spec.RepoURL = "https://flaky.example.com"
+		Expect(k8sClient.Create(ctx, failing)).To(Succeed())
+		Eventually(func(g Gomega) {
+			states := breakers.Snapshot()
+			g.Expect(states).To(HaveLen(1))
+			g.Expect(states[0].State).To(Equal(controllers.BreakerOpen))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+		Expect(k8sClient.Delete(ctx, failing)).To(Succeed())
+
+		// Without an RBAC subject, autoProvision fails after the breaker
+		// let the release through.
+		early := makeHR("test-breaker-probe-early")
+		early.Spec.RepoURL = "https://flaky.example.com"
+		early.Spec.RBAC = &helmv1alpha1.RBACSpec{AutoProvision: true}
+		Expect(k8sClient.Create(ctx, early)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, early) })
+		Eventually(func(g Gomega) {
+			fetched, err := getHR(ctx, early.Name)
+			g.Expect(err).NotTo(HaveOccurred())
+			cond := findCondition(fetched, "Ready")
+			g.Expect(cond).NotTo(BeNil())
+			g.Expect(cond.Message).To(ContainSubstring("--rbac-service-account"))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+
+		mock.mu.Lock()
+		mock.InstallErr = nil
+		mock.mu.Unlock()
+		next := makeHR("test-breaker-probe-next")
+		next.Spec.RepoURL = "https://flaky.example.com"
+		Expect(k8sClient.Create(ctx, next)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, next) })
+
+		Eventually(func(g Gomega) {
+			fetched, err := getHR(ctx, next.Name)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(fetched.Status.Phase).To(Equal(helmv1alpha1.PhaseReady))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+		Expect(breakers.Snapshot()).To(BeEmpty())
+	})
+})
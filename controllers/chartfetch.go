@@ -0,0 +1,299 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/downloader"
+	"helm.sh/helm/v3/pkg/getter"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// DefaultUserAgent identifies the operator's requests to chart repositories.
+const DefaultUserAgent = "helm-operator (+https://github.com/example/helm-operator)"
+
+// DownloadConfig controls the HTTP behaviour of index and chart downloads.
+type DownloadConfig struct {
+	// UserAgent is sent with every request. Defaults to DefaultUserAgent.
+	UserAgent string
+	// Timeout bounds a single request. Zero means Helm's default.
+	Timeout time.Duration
+	// Retries is how many times a failed request is retried.
+	Retries int
+	// RetryBackoff is the delay before the first retry; it doubles on each
+	// further attempt.
+	RetryBackoff time.Duration
+}
+
+func (c DownloadConfig) userAgent() string {
+	if c.UserAgent == "" {
+		return DefaultUserAgent
+	}
+	return c.UserAgent
+}
+
+// ChartFetchOptions holds per-release settings for downloading the chart.
+type ChartFetchOptions struct {
+	// ProxyURL, when set, sends all chart traffic of the release through this
+	// proxy instead of the operator's proxy configuration.
+	ProxyURL string
+	// AllowPrerelease lets version constraints match pre-release versions.
+	AllowPrerelease bool
+	// ChartDigest, when set, is the sha256 digest the chart must have.
+	ChartDigest string
+	// Credentials, when set, authenticate to an http(s) chart repository.
+	Credentials *RepoCredentials
+	// RegistryUsername and RegistryPassword, when set, log in to the
+	// registry of an oci:// repository instead of using the operator's
+	// registry credentials.
+	RegistryUsername string
+	RegistryPassword string
+	// Keyring, when set, is the public keyring the chart's provenance file
+	// must verify against.
+	Keyring []byte
+}
+
+// chartFetchOptions derives the chart download settings from the spec,
+// reading the registry credentials of spec.registrySecretRef.
+func (r *HelmReleaseReconciler) chartFetchOptions(ctx context.Context, release *helmv1alpha1.HelmRelease) (ChartFetchOptions, error) {
+	opts := ChartFetchOptions{
+		ProxyURL:        release.Spec.ProxyURL,
+		AllowPrerelease: release.Spec.AllowPrerelease,
+		ChartDigest:     release.Spec.ChartDigest,
+	}
+	creds, err := r.repoCredentials(ctx, release)
+	if err != nil {
+		return opts, err
+	}
+	opts.Credentials = creds
+	if opts.Keyring, err = r.keyring(ctx, release); err != nil {
+		return opts, err
+	}
+	ref := release.Spec.RegistrySecretRef
+	if ref == nil {
+		return opts, nil
+	}
+	var secret corev1.Secret
+	if err := r.Get(ctx, types.NamespacedName{Namespace: release.Namespace, Name: ref.Name}, &secret); err != nil {
+		return opts, fmt.Errorf("reading registry Secret %s: %w", ref.Name, err)
+	}
+	opts.RegistryUsername, opts.RegistryPassword = string(secret.Data["username"]), string(secret.Data["password"])
+	if opts.RegistryUsername == "" || opts.RegistryPassword == "" {
+		return opts, fmt.Errorf("registry Secret %s needs the keys username and password", ref.Name)
+	}
+	return opts, nil
+}
+
+// validateRepoURL returns an error unless repoURL is a chart repository or
+// registry URL Helm can pull from.
+func validateRepoURL(repoURL string) error {
+	u, err := url.Parse(repoURL)
+	if err != nil {
+		return fmt.Errorf("invalid repoURL: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https":
+	case "oci":
+		if u.Host == "" {
+			return fmt.Errorf("invalid repoURL %q: an oci:// URL needs a registry host", repoURL)
+		}
+		if u.RawQuery != "" || u.Fragment != "" {
+			return fmt.Errorf("invalid repoURL %q: an oci:// URL cannot have a query or fragment; set the chart version in spec.version", repoURL)
+		}
+	default:
+		return fmt.Errorf("invalid repoURL %q: the scheme must be http, https or oci", repoURL)
+	}
+	return nil
+}
+
+// loadChart resolves chartName in repoURL, downloads it and loads it. It
+// also returns the chart's digest.
+func (h *HelmClient) loadChart(ctx context.Context, chartName, repoURL, version string, opts ChartFetchOptions) (*chart.Chart, string, error) {
+	dest, err := os.MkdirTemp("", "helm-operator-chart-")
+	if err != nil {
+		return nil, "", err
+	}
+	defer os.RemoveAll(dest)
+
+	path, digest, err := h.downloadChart(ctx, chartName, repoURL, version, opts, dest)
+	if err != nil {
+		return nil, "", err
+	}
+	c, err := loader.Load(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("loading chart: %w", err)
+	}
+	return c, digest, nil
+}
+
+// LoadChart downloads and loads a chart, for inspecting it without
+// installing it.
+func (h *HelmClient) LoadChart(ctx context.Context, chartName, repoURL, version string, opts ChartFetchOptions) (*chart.Chart, error) {
+	c, _, err := h.loadChart(ctx, chartName, repoURL, version, opts)
+	return c, err
+}
+
+// deployedChart describes the loaded chart c with the given digest.
+func deployedChart(c *chart.Chart, digest string) *DeployedChart {
+	return &DeployedChart{Version: c.Metadata.Version, AppVersion: c.Metadata.AppVersion, Digest: digest}
+}
+
+// downloadChart resolves chartName in repoURL and downloads the archive into
+// dest, returning its path and digest. It replaces action.ChartPathOptions.LocateChart
+// so that the operator controls the HTTP transport used for index and
+// tarball requests.
+func (h *HelmClient) downloadChart(ctx context.Context, chartName, repoURL, version string, opts ChartFetchOptions, dest string) (string, string, error) {
+	settings := cli.New()
+	getters, err := h.getters(ctx, repoURL, opts)
+	if err != nil {
+		return "", "", err
+	}
+
+	if opts.AllowPrerelease {
+		version = prereleaseConstraint(version)
+	}
+	if strings.HasPrefix(repoURL, "oci://") {
+		return h.downloadOCIChart(chartName, repoURL, version, opts, dest)
+	}
+
+	ref := chartName
+	if repoURL != "" {
+		ref, err = h.findChartInIndex(ctx, repoURL, chartName, version, opts)
+		if err != nil {
+			return "", "", fmt.Errorf("locating chart: %w", err)
+		}
+	}
+
+	// With a keyring the provenance file is downloaded next to the archive
+	// and checked by verifyChart, which also fails when there is none.
+	verify := downloader.VerifyNever
+	if opts.Keyring != nil {
+		verify = downloader.VerifyLater
+	}
+	dl := downloader.ChartDownloader{
+		Out:              io.Discard,
+		Verify:           verify,
+		Getters:          getters,
+		RepositoryConfig: settings.RepositoryConfig,
+		RepositoryCache:  settings.RepositoryCache,
+	}
+	path, _, err := dl.DownloadTo(ref, version, dest)
+	if err != nil {
+		return "", "", fmt.Errorf("downloading chart: %w", err)
+	}
+	if opts.Keyring != nil {
+		if err := verifyChart(path, opts.Keyring); err != nil {
+			return "", "", err
+		}
+	}
+	digest, err := archiveDigest(path)
+	if err != nil {
+		return "", "", err
+	}
+	if opts.ChartDigest != "" && digest != opts.ChartDigest {
+		return "", "", fmt.Errorf("chart archive has digest %s, want %s", digest, opts.ChartDigest)
+	}
+	return path, digest, nil
+}
+
+// constraintVersion matches the versions in a semver constraint, with any
+// pre-release suffix.
+var constraintVersion = regexp.MustCompile(`v?[0-9]+(\.[0-9xX*]+){0,2}(-[0-9A-Za-z.-]+)?`)
+
+// prereleaseConstraint rewrites a semver constraint so it also matches
+// pre-release versions. Constraints only match pre-releases when one of their
+// bounds has a pre-release part, so "-0", the lowest possible one, is added
+// to every bound without one: ">=1.2.0 <2.0.0" becomes ">=1.2.0-0 <2.0.0-0".
+// Exact versions and wildcard bounds are left as they are.
+func prereleaseConstraint(version string) string {
+	if !strings.ContainsAny(version, "<>=~^|, ") {
+		return version // exact version
+	}
+	return constraintVersion.ReplaceAllStringFunc(version, func(v string) string {
+		if strings.Contains(v, "-") || strings.ContainsAny(v, "xX*") {
+			return v
+		}
+		return v + "-0"
+	})
+}
+
+// getters returns the Helm getter providers used for chart downloads from
+// repoURL. Retries stop once ctx is done.
+func (h *HelmClient) getters(ctx context.Context, repoURL string, opts ChartFetchOptions) (getter.Providers, error) {
+	transport, err := h.repoTransport(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := h.Download
+	return getter.Providers{{
+		Schemes: []string{"http", "https"},
+		New: func(o ...getter.Option) (getter.Getter, error) {
+			o = append(o, getter.WithTransport(transport), getter.WithUserAgent(cfg.userAgent()))
+			if cfg.Timeout > 0 {
+				o = append(o, getter.WithTimeout(cfg.Timeout))
+			}
+			if c := opts.Credentials; c != nil && c.Username != "" {
+				// Helm sends the credentials only to the host of the URL
+				// given, so archives hosted elsewhere do not receive them.
+				o = append(o, getter.WithURL(repoURL), getter.WithBasicAuth(c.Username, c.Password))
+			}
+			g, err := getter.NewHTTPGetter(o...)
+			if err != nil {
+				return nil, err
+			}
+			return &retryGetter{Getter: g, ctx: ctx, retries: cfg.Retries, backoff: cfg.RetryBackoff}, nil
+		},
+	}}, nil
+}
+
+// retryGetter retries failed downloads with exponential backoff, so a flaky
+// repository does not fail the reconcile on the first error. Helm's getters
+// take no context, so the reconcile's is kept to stop retrying when it ends.
+type retryGetter struct {
+	getter.Getter
+	ctx     context.Context
+	retries int
+	backoff time.Duration
+}
+
+func (g *retryGetter) Get(url string, options ...getter.Option) (*bytes.Buffer, error) {
+	backoff := g.backoff
+	for attempt := 0; ; attempt++ {
+		buf, err := g.Getter.Get(url, options...)
+		if err == nil || attempt >= g.retries {
+			return buf, err
+		}
+		ctrl.Log.WithName("chart-fetch").V(1).Info("Retrying download", "url", url, "attempt", attempt+1, "error", err.Error())
+		if !waitBackoff(g.ctx, backoff) {
+			return nil, err
+		}
+		backoff *= 2
+	}
+}
+
+// waitBackoff waits for d and reports whether it did, or returns false as
+// soon as ctx is done.
+func waitBackoff(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,164 @@
+package controllers_test
+
+import (
+	"context"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"github.com/example/helm-operator/controllers"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Chart fetching", func() {
+	ctx := context.Background()
+
+	It("passes spec.proxyURL to Helm", func() {
+		mock := &MockHelmClient{}
+		cancel := startManager(mock)
+		defer cancel()
+
+		hr := makeHR("test-proxy-url")
+		hr.Spec.ProxyURL = "http://proxy.team-a.internal:3128"
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+		Eventually(func(g Gomega) {
+			mock.mu.Lock()
+			args := mock.InstallArgs
+			mock.mu.Unlock()
+			g.Expect(args.Opts.Fetch.ProxyURL).To(Equal("http://proxy.team-a.internal:3128"))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+	})
+
+	It("passes spec.allowPrerelease to Helm", func() {
+		mock := &MockHelmClient{}
+		cancel := startManager(mock)
+		defer cancel()
+
+		hr := makeHR("test-allow-prerelease")
+		hr.Spec.Version = ">=1.2.0 <2.0.0"
+		hr.Spec.AllowPrerelease = true
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+		Eventually(func(g Gomega) {
+			mock.mu.Lock()
+			args := mock.InstallArgs
+			mock.mu.Unlock()
+			g.Expect(args.Version).To(Equal(">=1.2.0 <2.0.0"))
+			g.Expect(args.Opts.Fetch.AllowPrerelease).To(BeTrue())
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+	})
+
+	It("passes spec.chartDigest to Helm", func() {
+		mock := &MockHelmClient{}
+		cancel := startManager(mock)
+		defer cancel()
+
+		digest := "sha256:" + strings.Repeat("ab", 32)
+		hr := makeHR("test-chart-digest")
+		hr.Spec.RepoURL = "oci://ghcr.io/stefanprodan/charts"
+		hr.Spec.ChartDigest = digest
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+		Eventually(func(g Gomega) {
+			mock.mu.Lock()
+			args := mock.InstallArgs
+			mock.mu.Unlock()
+			g.Expect(args.Opts.Fetch.ChartDigest).To(Equal(digest))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+	})
+
+	It("records the deployed chart's version, appVersion and digest", func() {
+		digest := "sha256:" + strings.Repeat("cd", 32)
+		mock := &MockHelmClient{
+			InstallResult: &controllers.DeployedChart{Version: "1.4.2", AppVersion: "6.5.0", Digest: digest},
+		}
+		cancel := startManager(mock)
+		defer cancel()
+
+		hr := makeHR("test-deployed-chart")
+		hr.Spec.Version = "~1.4"
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+		Eventually(func(g Gomega) {
+			fetched, err := getHR(ctx, hr.Name)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(fetched.Status.DeployedVersion).To(Equal("1.4.2"))
+			g.Expect(fetched.Status.AppVersion).To(Equal("6.5.0"))
+			g.Expect(fetched.Status.ChartDigest).To(Equal(digest))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+	})
+
+	It("logs in to OCI registries with spec.registrySecretRef", func() {
+		mock := &MockHelmClient{}
+		cancel := startManager(mock)
+		defer cancel()
+
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "ghcr-pull", Namespace: "default"},
+			Data:       map[string][]byte{"username": []byte("bot"), "password": []byte("ghp_token")},
+		}
+		Expect(k8sClient.Create(ctx, secret)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, secret) })
+		hr := makeHR("test-registry-secret")
+		hr.Spec.RepoURL = "oci://ghcr.io/example/charts"
+		hr.Spec.RegistrySecretRef = &helmv1alpha1.LocalSecretReference{Name: "ghcr-pull"}
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+		Eventually(func(g Gomega) {
+			mock.mu.Lock()
+			args := mock.InstallArgs
+			mock.mu.Unlock()
+			g.Expect(args.RepoURL).To(Equal("oci://ghcr.io/example/charts"))
+			g.Expect(args.Opts.Fetch.RegistryUsername).To(Equal("bot"))
+			g.Expect(args.Opts.Fetch.RegistryPassword).To(Equal("ghp_token"))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+	})
+
+	It("fails releases whose repoURL scheme Helm cannot pull from", func() {
+		mock := &MockHelmClient{}
+		cancel := startManager(mock)
+		defer cancel()
+
+		hr := makeHR("test-repo-scheme")
+		hr.Spec.RepoURL = "s3://charts/stable"
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+		Eventually(func(g Gomega) {
+			fetched, err := getHR(ctx, hr.Name)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(fetched.Status.Phase).To(Equal(helmv1alpha1.PhaseFailed))
+			g.Expect(findCondition(fetched, "Ready").Message).To(ContainSubstring("the scheme must be http, https or oci"))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+		mock.mu.Lock()
+		defer mock.mu.Unlock()
+		Expect(mock.InstallCalled).To(BeFalse())
+	})
+
+	It("rejects a chartDigest that is not sha256", func() {
+		hr := makeHR("test-chart-digest-invalid")
+		hr.Spec.ChartDigest = "latest"
+		Expect(k8sClient.Create(ctx, hr)).NotTo(Succeed())
+	})
+
+	It("parses per-repository proxy overrides", func() {
+		overrides, err := controllers.ParseProxyOverrides("https://charts.partner.com/=http://proxy-b:3128, oci://ghcr.io/=direct")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(overrides).To(Equal([]controllers.ProxyOverride{
+			{Prefix: "https://charts.partner.com/", ProxyURL: "http://proxy-b:3128"},
+			{Prefix: "oci://ghcr.io/", ProxyURL: "direct"},
+		}))
+
+		_, err = controllers.ParseProxyOverrides("https://charts.partner.com/=not-a-url")
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,239 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// AnnotationClusterUpgrade, set to "true" on any Node or on the kube-system
+// Namespace, tells the operator a cluster upgrade is in progress.
+const AnnotationClusterUpgrade = "helm.example.com/cluster-upgrade-in-progress"
+
+const conditionUpgradesDeferred = "UpgradesDeferred"
+
+// minAPIRequestsForErrorRate is the number of API requests a check interval
+// needs before its error rate counts; a handful of failures while idle is
+// not duress.
+const minAPIRequestsForErrorRate = 20
+
+// ClusterDuress explains why upgrades are paused cluster-wide.
+type ClusterDuress struct {
+	// Reason is ClusterUpgradeInProgress, NodesNotReady or APIServerErrors.
+	Reason  string
+	Message string
+	Since   time.Time
+}
+
+// ClusterHealthMonitor is a controller-runtime Runnable that watches for
+// signs the cluster is under duress: a cluster upgrade announced with
+// AnnotationClusterUpgrade, too many NotReady nodes, or a high error rate of
+// the operator's own API server requests. While any holds, the reconciler
+// defers upgrades; installs and uninstalls go ahead.
+type ClusterHealthMonitor struct {
+	Client   client.Reader
+	Interval time.Duration
+
+	// MaxNotReadyNodes is the fraction of NotReady nodes above which
+	// upgrades pause. 0 disables the check.
+	MaxNotReadyNodes float64
+	// MaxAPIErrorRate is the fraction of API server requests failing with
+	// 5xx or 429 over one interval above which upgrades pause. 0 disables
+	// the check.
+	MaxAPIErrorRate float64
+
+	// Metrics is read for the API server request counts. Defaults to the
+	// controller-runtime metrics registry.
+	Metrics prometheus.Gatherer
+
+	mu           sync.Mutex
+	duress       *ClusterDuress
+	lastRequests float64
+	lastErrors   float64
+}
+
+// Start implements manager.Runnable.
+func (m *ClusterHealthMonitor) Start(ctx context.Context) error {
+	ticker := time.NewTicker(m.Interval)
+	defer ticker.Stop()
+	for {
+		m.check(ctx)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Duress returns why upgrades are paused, or nil.
+func (m *ClusterHealthMonitor) Duress() *ClusterDuress {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.duress == nil {
+		return nil
+	}
+	d := *m.duress
+	return &d
+}
+
+func (m *ClusterHealthMonitor) check(ctx context.Context) {
+	log := ctrl.Log.WithName("cluster-health")
+	reason, message, err := m.detect(ctx)
+	if err != nil {
+		// Keep the previous verdict rather than guess.
+		log.Error(err, "Checking cluster health")
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch {
+	case reason == "" && m.duress != nil:
+		log.Info("Cluster recovered, resuming upgrades", "after", time.Since(m.duress.Since).Round(time.Second))
+		m.duress = nil
+	case reason != "" && (m.duress == nil || m.duress.Reason != reason):
+		log.Info("Cluster under duress, pausing upgrades", "reason", reason, "message", message)
+		m.duress = &ClusterDuress{Reason: reason, Message: message, Since: time.Now()}
+	case reason != "":
+		m.duress.Message = message
+	}
+}
+
+// detect runs the detectors in order and returns the first that fires.
+func (m *ClusterHealthMonitor) detect(ctx context.Context) (reason, message string, err error) {
+	var nodes corev1.NodeList
+	if err := m.Client.List(ctx, &nodes); err != nil {
+		return "", "", fmt.Errorf("listing nodes: %w", err)
+	}
+	for _, node := range nodes.Items {
+		if strings.EqualFold(node.Annotations[AnnotationClusterUpgrade], "true") {
+			return "ClusterUpgradeInProgress", fmt.Sprintf("Node %s is annotated %s", node.Name, AnnotationClusterUpgrade), nil
+		}
+	}
+	var kubeSystem corev1.Namespace
+	if err := m.Client.Get(ctx, types.NamespacedName{Name: metav1.NamespaceSystem}, &kubeSystem); client.IgnoreNotFound(err) != nil {
+		return "", "", fmt.Errorf("reading namespace %s: %w", metav1.NamespaceSystem, err)
+	}
+	if strings.EqualFold(kubeSystem.Annotations[AnnotationClusterUpgrade], "true") {
+		return "ClusterUpgradeInProgress", fmt.Sprintf("Namespace %s is annotated %s", metav1.NamespaceSystem, AnnotationClusterUpgrade), nil
+	}
+
+	if m.MaxNotReadyNodes > 0 && len(nodes.Items) > 0 {
+		var notReady []string
+		for _, node := range nodes.Items {
+			if !nodeReady(&node) {
+				notReady = append(notReady, node.Name)
+			}
+		}
+		if fraction := float64(len(notReady)) / float64(len(nodes.Items)); fraction > m.MaxNotReadyNodes {
+			return "NodesNotReady", fmt.Sprintf("%d of %d nodes are NotReady (limit %.0f%%)",
+				len(notReady), len(nodes.Items), m.MaxNotReadyNodes*100), nil
+		}
+	}
+
+	if m.MaxAPIErrorRate > 0 {
+		requests, errors, err := m.apiRequests()
+		if err != nil {
+			return "", "", err
+		}
+		dRequests, dErrors := requests-m.lastRequests, errors-m.lastErrors
+		m.lastRequests, m.lastErrors = requests, errors
+		if dRequests >= minAPIRequestsForErrorRate {
+			if rate := dErrors / dRequests; rate > m.MaxAPIErrorRate {
+				return "APIServerErrors", fmt.Sprintf("%.0f%% of %.0f API server requests failed in the last %s (limit %.0f%%)",
+					rate*100, dRequests, m.Interval, m.MaxAPIErrorRate*100), nil
+			}
+		}
+	}
+	return "", "", nil
+}
+
+// apiRequests sums client-go's rest_client_requests_total: all requests,
+// and those that failed with a 5xx or 429.
+func (m *ClusterHealthMonitor) apiRequests() (total, failed float64, err error) {
+	gatherer := m.Metrics
+	if gatherer == nil {
+		gatherer = ctrlmetrics.Registry
+	}
+	families, err := gatherer.Gather()
+	if err != nil {
+		return 0, 0, fmt.Errorf("gathering metrics: %w", err)
+	}
+	for _, family := range families {
+		if family.GetName() != "rest_client_requests_total" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			value := metric.GetCounter().GetValue()
+			total += value
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "code" && (strings.HasPrefix(label.GetValue(), "5") || label.GetValue() == "429") {
+					failed += value
+				}
+			}
+		}
+	}
+	return total, failed, nil
+}
+
+func nodeReady(node *corev1.Node) bool {
+	for _, c := range node.Status.Conditions {
+		if c.Type == corev1.NodeReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// setUpgradesDeferred records that a pending upgrade waits for the cluster to
+// recover, emitting an event when the deferral starts or its reason changes.
+func (r *HelmReleaseReconciler) setUpgradesDeferred(release *helmv1alpha1.HelmRelease, duress *ClusterDuress) {
+	previous := meta.FindStatusCondition(release.Status.Conditions, conditionUpgradesDeferred)
+	announced := previous != nil && previous.Status == metav1.ConditionTrue && previous.Reason == duress.Reason
+	setCondition(release, metav1.Condition{
+		Type:               conditionUpgradesDeferred,
+		Status:             metav1.ConditionTrue,
+		Reason:             duress.Reason,
+		Message:            duress.Message,
+		ObservedGeneration: release.Generation,
+	})
+	if announced {
+		return
+	}
+	r.event(release, corev1.EventTypeWarning, "UpgradeDeferred",
+		fmt.Sprintf("Upgrade deferred while the cluster is under duress: %s", duress.Message))
+}
+
+// clearUpgradesDeferred sets UpgradesDeferred to False if an upgrade was
+// deferred, emitting an event.
+func (r *HelmReleaseReconciler) clearUpgradesDeferred(release *helmv1alpha1.HelmRelease) {
+	for _, c := range release.Status.Conditions {
+		if c.Type == conditionUpgradesDeferred && c.Status != metav1.ConditionFalse {
+			setCondition(release, metav1.Condition{
+				Type:               conditionUpgradesDeferred,
+				Status:             metav1.ConditionFalse,
+				Reason:             "ClusterHealthy",
+				Message:            "The cluster has recovered",
+				ObservedGeneration: release.Generation,
+			})
+			r.event(release, corev1.EventTypeNormal, "UpgradeResumed", "The cluster has recovered; resuming the deferred upgrade")
+			return
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package controllers_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/example/helm-operator/controllers"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Cluster health", func() {
+	ctx := context.Background()
+
+	// startMonitor runs a ClusterHealthMonitor and wires it into the
+	// reconciler started by startManager.
+	startMonitor := func(monitor *controllers.ClusterHealthMonitor) func(*controllers.HelmReleaseReconciler) {
+		monitor.Client = k8sClient
+		monitor.Interval = 100 * time.Millisecond
+		monitorCtx, cancel := context.WithCancel(ctx)
+		DeferCleanup(cancel)
+		go func() {
+			defer GinkgoRecover()
+			Expect(monitor.Start(monitorCtx)).To(Succeed())
+		}()
+		return func(r *controllers.HelmReleaseReconciler) { r.ClusterHealth = monitor }
+	}
+
+	upgraded := func(mock *MockHelmClient) func() bool {
+		return func() bool {
+			mock.mu.Lock()
+			defer mock.mu.Unlock()
+			return mock.UpgradeCalled
+		}
+	}
+
+	// installThenBump installs a release and bumps its version.
+	installThenBump := func(mock *MockHelmClient, name string) {
+		hr := makeHR(name)
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+		Eventually(func() bool {
+			mock.mu.Lock()
+			defer mock.mu.Unlock()
+			return mock.InstallCalled
+		}).WithTimeout(timeout).WithPolling(polling).Should(BeTrue())
+		mock.mu.Lock()
+		mock.ReleaseExistsResult = true
+		mock.mu.Unlock()
+
+		fetched, err := getHR(ctx, name)
+		Expect(err).NotTo(HaveOccurred())
+		patch := client.MergeFrom(fetched.DeepCopy())
+		fetched.Spec.Version = "2.0.0"
+		Expect(k8sClient.Patch(ctx, fetched, patch)).To(Succeed())
+	}
+
+	expectDeferred := func(name, reason string) {
+		Eventually(func(g Gomega) {
+			fetched, err := getHR(ctx, name)
+			g.Expect(err).NotTo(HaveOccurred())
+			cond := findCondition(fetched, "UpgradesDeferred")
+			g.Expect(cond).NotTo(BeNil())
+			g.Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+			g.Expect(cond.Reason).To(Equal(reason))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+	}
+
+	It("defers upgrades while a cluster upgrade is announced", func() {
+		node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{
+			Name:        "test-upgrading-node",
+			Annotations: map[string]string{controllers.AnnotationClusterUpgrade: "true"},
+		}}
+		Expect(k8sClient.Create(ctx, node)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, node) })
+
+		mock := &MockHelmClient{}
+		cancel := startManager(mock, startMonitor(&controllers.ClusterHealthMonitor{}))
+		defer cancel()
+
+		installThenBump(mock, "test-health-upgrade")
+		expectDeferred("test-health-upgrade", "ClusterUpgradeInProgress")
+		Consistently(upgraded(mock)).WithTimeout(time.Second).WithPolling(polling).Should(BeFalse())
+
+		patch := client.MergeFrom(node.DeepCopy())
+		delete(node.Annotations, controllers.AnnotationClusterUpgrade)
+		Expect(k8sClient.Patch(ctx, node, patch)).To(Succeed())
+
+		Eventually(upgraded(mock)).WithTimeout(timeout).WithPolling(polling).Should(BeTrue())
+		Eventually(func(g Gomega) {
+			fetched, err := getHR(ctx, "test-health-upgrade")
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(fetched.Status.DeployedVersion).To(Equal("2.0.0"))
+			cond := findCondition(fetched, "UpgradesDeferred")
+			g.Expect(cond).NotTo(BeNil())
+			g.Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+	})
+
+	It("defers upgrades while too many nodes are NotReady", func() {
+		// envtest runs no kubelet, so the node never reports Ready.
+		node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "test-notready-node"}}
+		Expect(k8sClient.Create(ctx, node)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, node) })
+
+		mock := &MockHelmClient{}
+		cancel := startManager(mock, startMonitor(&controllers.ClusterHealthMonitor{MaxNotReadyNodes: 0.5}))
+		defer cancel()
+
+		installThenBump(mock, "test-health-nodes")
+		expectDeferred("test-health-nodes", "NodesNotReady")
+		Expect(upgraded(mock)()).To(BeFalse())
+	})
+})
@@ -0,0 +1,132 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/discovery"
+)
+
+const conditionClusterCompatible = "ClusterCompatible"
+
+// requeueForClusterSelector is how often a skipped release re-checks the
+// cluster. Cluster upgrades and newly installed CRDs raise no event on the
+// HelmRelease.
+const requeueForClusterSelector = 10 * time.Minute
+
+// matchClusterSelector reports whether the cluster behind dc satisfies
+// selector, and if not, a condition reason and message explaining why.
+func matchClusterSelector(selector *helmv1alpha1.ClusterSelector, dc discovery.DiscoveryInterface) (bool, string, string, error) {
+	if selector.MinKubeVersion != "" || selector.MaxKubeVersion != "" {
+		info, err := dc.ServerVersion()
+		if err != nil {
+			return false, "", "", fmt.Errorf("getting Kubernetes version: %w", err)
+		}
+		// Vendor suffixes such as -eks-1234 or +k3s1 would make the version
+		// a pre-release and fail every constraint, so only x.y.z is kept.
+		server, err := semver.NewVersion(info.GitVersion)
+		if err != nil {
+			return false, "", "", fmt.Errorf("parsing Kubernetes version %q: %w", info.GitVersion, err)
+		}
+		version, _ := semver.NewVersion(fmt.Sprintf("%d.%d.%d", server.Major(), server.Minor(), server.Patch()))
+		for _, bound := range []struct{ op, version string }{
+			{">=", selector.MinKubeVersion},
+			{"<=", selector.MaxKubeVersion},
+		} {
+			if bound.version == "" {
+				continue
+			}
+			constraint, err := semver.NewConstraint(bound.op + bound.version)
+			if err != nil {
+				return false, "", "", fmt.Errorf("invalid clusterSelector version %q: %w", bound.version, err)
+			}
+			if !constraint.Check(version) {
+				return false, "KubeVersionMismatch",
+					fmt.Sprintf("Kubernetes %s does not satisfy %s%s", info.GitVersion, bound.op, bound.version), nil
+			}
+		}
+	}
+
+	if len(selector.RequiredAPIGroups) > 0 {
+		groups, err := dc.ServerGroups()
+		if err != nil {
+			return false, "", "", fmt.Errorf("listing API groups: %w", err)
+		}
+		served := map[string]bool{}
+		for _, g := range groups.Groups {
+			served[g.Name] = true
+			for _, v := range g.Versions {
+				served[v.GroupVersion] = true
+			}
+		}
+		var missing []string
+		for _, g := range selector.RequiredAPIGroups {
+			if !served[g] {
+				missing = append(missing, g)
+			}
+		}
+		if len(missing) > 0 {
+			return false, "APIGroupMissing",
+				fmt.Sprintf("The cluster does not serve %s", strings.Join(missing, ", ")), nil
+		}
+	}
+	return true, "", "", nil
+}
+
+// checkClusterSelector reports whether release may be installed or upgraded
+// on this cluster. A skipped release gets a ClusterCompatible=False
+// condition; one that matches again has it set back to True.
+func (r *HelmReleaseReconciler) checkClusterSelector(release *helmv1alpha1.HelmRelease) (bool, error) {
+	if release.Spec.ClusterSelector == nil {
+		clearClusterIncompatible(release)
+		return true, nil
+	}
+	if r.Discovery == nil {
+		return false, fmt.Errorf("spec.clusterSelector is set but the operator has no discovery client")
+	}
+	ok, reason, message, err := matchClusterSelector(release.Spec.ClusterSelector, r.Discovery)
+	if err != nil {
+		return false, err
+	}
+	if ok {
+		clearClusterIncompatible(release)
+		return true, nil
+	}
+	setCondition(release, metav1.Condition{
+		Type:               conditionClusterCompatible,
+		Status:             metav1.ConditionFalse,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: release.Generation,
+	})
+	setCondition(release, metav1.Condition{
+		Type:               "Ready",
+		Status:             metav1.ConditionFalse,
+		Reason:             "ClusterIncompatible",
+		Message:            "Skipped by spec.clusterSelector: " + message,
+		ObservedGeneration: release.Generation,
+	})
+	release.Status.ObservedGeneration = release.Generation
+	return false, nil
+}
+
+// clearClusterIncompatible sets ClusterCompatible to True if the release
+// was skipped.
+func clearClusterIncompatible(release *helmv1alpha1.HelmRelease) {
+	for _, c := range release.Status.Conditions {
+		if c.Type == conditionClusterCompatible && c.Status != metav1.ConditionTrue {
+			setCondition(release, metav1.Condition{
+				Type:               conditionClusterCompatible,
+				Status:             metav1.ConditionTrue,
+				Reason:             "SelectorMatched",
+				Message:            "The cluster satisfies spec.clusterSelector",
+				ObservedGeneration: release.Generation,
+			})
+			return
+		}
+	}
+}
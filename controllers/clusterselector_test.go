@@ -0,0 +1,100 @@
+package controllers_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"github.com/example/helm-operator/controllers"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/discovery"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Cluster selector", func() {
+	ctx := context.Background()
+
+	withDiscovery := func(r *controllers.HelmReleaseReconciler) {
+		r.Discovery = discovery.NewDiscoveryClientForConfigOrDie(cfg)
+	}
+
+	installed := func(mock *MockHelmClient, name string) func() []string {
+		return func() []string {
+			mock.mu.Lock()
+			defer mock.mu.Unlock()
+			return mock.Installed
+		}
+	}
+
+	expectSkipped := func(name, reason string) {
+		Eventually(func(g Gomega) {
+			fetched, err := getHR(ctx, name)
+			g.Expect(err).NotTo(HaveOccurred())
+			cond := findCondition(fetched, "ClusterCompatible")
+			g.Expect(cond).NotTo(BeNil())
+			g.Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+			g.Expect(cond.Reason).To(Equal(reason))
+			ready := findCondition(fetched, "Ready")
+			g.Expect(ready).NotTo(BeNil())
+			g.Expect(ready.Reason).To(Equal("ClusterIncompatible"))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+	}
+
+	It("skips a release until the cluster version matches", func() {
+		mock := &MockHelmClient{}
+		cancel := startManager(mock, withDiscovery)
+		defer cancel()
+
+		hr := makeHR("test-cluster-version")
+		hr.Spec.ClusterSelector = &helmv1alpha1.ClusterSelector{MinKubeVersion: "99.0"}
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+		expectSkipped(hr.Name, "KubeVersionMismatch")
+		Consistently(installed(mock, hr.Name)).WithTimeout(time.Second).WithPolling(polling).
+			ShouldNot(ContainElement(hr.Name))
+
+		fetched, err := getHR(ctx, hr.Name)
+		Expect(err).NotTo(HaveOccurred())
+		patch := client.MergeFrom(fetched.DeepCopy())
+		fetched.Spec.ClusterSelector = &helmv1alpha1.ClusterSelector{MinKubeVersion: "1.0", MaxKubeVersion: "99.0"}
+		Expect(k8sClient.Patch(ctx, fetched, patch)).To(Succeed())
+
+		Eventually(installed(mock, hr.Name)).WithTimeout(timeout).WithPolling(polling).Should(ContainElement(hr.Name))
+		Eventually(func(g Gomega) {
+			fetched, err := getHR(ctx, hr.Name)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(fetched.Status.Phase).To(Equal(helmv1alpha1.PhaseReady))
+			cond := findCondition(fetched, "ClusterCompatible")
+			g.Expect(cond).NotTo(BeNil())
+			g.Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+	})
+
+	It("skips a release when a required API group is not served", func() {
+		mock := &MockHelmClient{}
+		cancel := startManager(mock, withDiscovery)
+		defer cancel()
+
+		hr := makeHR("test-cluster-groups")
+		hr.Spec.ClusterSelector = &helmv1alpha1.ClusterSelector{
+			RequiredAPIGroups: []string{"helm.example.com/v1alpha1", "monitoring.example.invalid"},
+		}
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+		expectSkipped(hr.Name, "APIGroupMissing")
+		fetched, err := getHR(ctx, hr.Name)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(findCondition(fetched, "ClusterCompatible").Message).To(Equal("The cluster does not serve monitoring.example.invalid"))
+	})
+
+	It("rejects invalid Kubernetes versions", func() {
+		hr := makeHR("test-cluster-invalid")
+		hr.Spec.ClusterSelector = &helmv1alpha1.ClusterSelector{MinKubeVersion: ">=1.27"}
+		Expect(k8sClient.Create(ctx, hr)).NotTo(Succeed())
+	})
+})
@@ -0,0 +1,155 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Version is the operator's version, set at build time with
+// -ldflags "-X github.com/example/helm-operator/controllers.Version=v1.2.3".
+var Version = "dev"
+
+// HelmReleaseCRDName is the name of the HelmRelease CustomResourceDefinition.
+const HelmReleaseCRDName = "helmreleases.helm.example.com"
+
+const conditionCRDOutdated = "CRDOutdated"
+
+// CRDStatus describes the installed HelmRelease CRD as seen at startup.
+type CRDStatus struct {
+	// Versions are the API versions the CRD serves.
+	Versions []string `json:"versions"`
+	// StoredVersions are the versions objects have been stored in.
+	StoredVersions []string `json:"storedVersions"`
+	// GeneratedBy is the controller-gen version that produced the CRD, if
+	// recorded.
+	GeneratedBy string `json:"generatedBy,omitempty"`
+	// MissingFields lists the fields of this binary's HelmRelease type the
+	// CRD schema lacks, e.g. "spec.wait.timeout". The API server drops them
+	// silently, so the CRD must be upgraded along with the operator.
+	MissingFields []string `json:"missingFields,omitempty"`
+}
+
+// CheckCRD reads the installed HelmRelease CRD and compares its schema with
+// the HelmRelease type this binary was built with.
+func CheckCRD(ctx context.Context, reader client.Reader) (*CRDStatus, error) {
+	var crd apiextensionsv1.CustomResourceDefinition
+	if err := reader.Get(ctx, types.NamespacedName{Name: HelmReleaseCRDName}, &crd); err != nil {
+		return nil, fmt.Errorf("reading CRD %s: %w", HelmReleaseCRDName, err)
+	}
+	status := &CRDStatus{
+		StoredVersions: crd.Status.StoredVersions,
+		GeneratedBy:    crd.Annotations["controller-gen.kubebuilder.io/version"],
+	}
+	var schema *apiextensionsv1.JSONSchemaProps
+	for _, v := range crd.Spec.Versions {
+		if v.Served {
+			status.Versions = append(status.Versions, v.Name)
+		}
+		if v.Name == helmv1alpha1.GroupVersion.Version && v.Schema != nil {
+			schema = v.Schema.OpenAPIV3Schema
+		}
+	}
+	if schema == nil {
+		return nil, fmt.Errorf("CRD %s has no schema for %s", HelmReleaseCRDName, helmv1alpha1.GroupVersion.Version)
+	}
+	for top, t := range map[string]reflect.Type{
+		"spec":   reflect.TypeOf(helmv1alpha1.HelmReleaseSpec{}),
+		"status": reflect.TypeOf(helmv1alpha1.HelmReleaseStatus{}),
+	} {
+		prop, ok := schema.Properties[top]
+		if !ok {
+			status.MissingFields = append(status.MissingFields, top)
+			continue
+		}
+		status.MissingFields = append(status.MissingFields, missingFields(t, &prop, top)...)
+	}
+	sort.Strings(status.MissingFields)
+	return status, nil
+}
+
+var jsonMarshaler = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+// missingFields returns the JSON fields of t, below path, that schema does
+// not declare. Types with their own JSON encoding, such as durations and
+// free-form values, are not descended into.
+func missingFields(t reflect.Type, schema *apiextensionsv1.JSONSchemaProps, path string) []string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if schema.XPreserveUnknownFields != nil && *schema.XPreserveUnknownFields {
+		return nil
+	}
+	if t.Implements(jsonMarshaler) || reflect.PointerTo(t).Implements(jsonMarshaler) {
+		return nil
+	}
+	switch t.Kind() {
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 || schema.Items == nil || schema.Items.Schema == nil {
+			return nil
+		}
+		return missingFields(t.Elem(), schema.Items.Schema, path+"[]")
+	case reflect.Struct:
+	default:
+		return nil
+	}
+	var missing []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" || !f.IsExported() {
+			continue
+		}
+		if opts == "inline" || (name == "" && f.Anonymous) {
+			missing = append(missing, missingFields(f.Type, schema, path)...)
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		prop, ok := schema.Properties[name]
+		if !ok {
+			missing = append(missing, path+"."+name)
+			continue
+		}
+		missing = append(missing, missingFields(f.Type, &prop, path+"."+name)...)
+	}
+	return missing
+}
+
+// setCRDOutdated flags every release while the installed CRD lacks fields
+// of this binary: any of them may have been dropped from the release's spec.
+func (r *HelmReleaseReconciler) setCRDOutdated(release *helmv1alpha1.HelmRelease) {
+	if r.CRD == nil || len(r.CRD.MissingFields) == 0 {
+		for _, c := range release.Status.Conditions {
+			if c.Type == conditionCRDOutdated && c.Status != metav1.ConditionFalse {
+				setCondition(release, metav1.Condition{
+					Type:               conditionCRDOutdated,
+					Status:             metav1.ConditionFalse,
+					Reason:             "CRDUpToDate",
+					Message:            "The HelmRelease CRD matches the operator",
+					ObservedGeneration: release.Generation,
+				})
+				return
+			}
+		}
+		return
+	}
+	setCondition(release, metav1.Condition{
+		Type:   conditionCRDOutdated,
+		Status: metav1.ConditionTrue,
+		Reason: "MissingFields",
+		Message: fmt.Sprintf("The HelmRelease CRD is older than operator %s and drops %s; upgrade the CRD",
+			Version, strings.Join(r.CRD.MissingFields, ", ")),
+		ObservedGeneration: release.Generation,
+	})
+}
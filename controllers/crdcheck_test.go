@@ -0,0 +1,62 @@
+package controllers_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/example/helm-operator/controllers"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("CRD version skew", func() {
+	ctx := context.Background()
+
+	It("finds no missing fields in the CRD shipped with the operator", func() {
+		status, err := controllers.CheckCRD(ctx, k8sClient)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(status.Versions).To(ConsistOf("v1alpha1"))
+		Expect(status.MissingFields).To(BeEmpty())
+	})
+
+	It("reports fields an older CRD lacks", func() {
+		var crd apiextensionsv1.CustomResourceDefinition
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: controllers.HelmReleaseCRDName}, &crd)).To(Succeed())
+		spec := crd.Spec.Versions[0].Schema.OpenAPIV3Schema.Properties["spec"]
+		delete(spec.Properties, "hookTimeout")
+		wait := spec.Properties["wait"]
+		delete(wait.Properties, "waitForJobs")
+		spec.Properties["wait"] = wait
+		crd.Spec.Versions[0].Schema.OpenAPIV3Schema.Properties["spec"] = spec
+		crd.ResourceVersion = ""
+
+		status, err := controllers.CheckCRD(ctx, fake.NewClientBuilder().WithScheme(scheme).WithObjects(&crd).Build())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(status.MissingFields).To(Equal([]string{"spec.hookTimeout", "spec.wait.waitForJobs"}))
+	})
+
+	It("flags releases while the CRD is outdated", func() {
+		mock := &MockHelmClient{}
+		cancel := startManager(mock, func(r *controllers.HelmReleaseReconciler) {
+			r.CRD = &controllers.CRDStatus{MissingFields: []string{"spec.hookTimeout"}}
+		})
+		defer cancel()
+
+		hr := makeHR("test-crd-outdated")
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+		Eventually(func(g Gomega) {
+			fetched, err := getHR(ctx, "test-crd-outdated")
+			g.Expect(err).NotTo(HaveOccurred())
+			cond := findCondition(fetched, "CRDOutdated")
+			g.Expect(cond).NotTo(BeNil())
+			g.Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+			g.Expect(cond.Message).To(ContainSubstring("spec.hookTimeout"))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+	})
+})
@@ -0,0 +1,132 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+// AnnotationOperatorVersion records on installed CRDs the operator version
+// that applied them.
+const AnnotationOperatorVersion = "helm.example.com/operator-version"
+
+// crdFieldOwner is the server-side apply field manager of installed CRDs.
+const crdFieldOwner = "helm-operator"
+
+// CRDInstaller applies the operator's own CRDs at startup.
+type CRDInstaller struct {
+	Client client.Client
+	// CRDs holds the CRD manifests, as YAML files.
+	CRDs fs.FS
+	// Force takes over fields owned by other field managers, such as Helm
+	// for a chart's crds/. Without it such conflicts fail the install.
+	Force bool
+	// EstablishTimeout bounds the wait for the CRDs to be served. Defaults
+	// to 30s.
+	EstablishTimeout time.Duration
+}
+
+// Install server-side applies every CRD and waits until they are
+// established. A CRD last applied by a newer operator is left alone, so an
+// old replica in a rolling upgrade does not revert it.
+func (i *CRDInstaller) Install(ctx context.Context) error {
+	log := ctrl.Log.WithName("crd-installer")
+	files, err := fs.Glob(i.CRDs, "*.yaml")
+	if err != nil {
+		return err
+	}
+	var applied []string
+	for _, file := range files {
+		data, err := fs.ReadFile(i.CRDs, file)
+		if err != nil {
+			return err
+		}
+		crd := &apiextensionsv1.CustomResourceDefinition{}
+		if err := yaml.UnmarshalStrict(data, crd); err != nil {
+			return fmt.Errorf("decoding %s: %w", file, err)
+		}
+
+		var current apiextensionsv1.CustomResourceDefinition
+		err = i.Client.Get(ctx, types.NamespacedName{Name: crd.Name}, &current)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("reading CRD %s: %w", crd.Name, err)
+		}
+		if newer(current.Annotations[AnnotationOperatorVersion], Version) {
+			log.Info("Not applying CRD: it was applied by a newer operator", "crd", crd.Name,
+				"appliedBy", current.Annotations[AnnotationOperatorVersion], "version", Version)
+			continue
+		}
+
+		crd.APIVersion = apiextensionsv1.SchemeGroupVersion.String()
+		crd.Kind = "CustomResourceDefinition"
+		if crd.Annotations == nil {
+			crd.Annotations = map[string]string{}
+		}
+		crd.Annotations[AnnotationOperatorVersion] = Version
+		opts := []client.PatchOption{client.FieldOwner(crdFieldOwner)}
+		if i.Force {
+			opts = append(opts, client.ForceOwnership)
+		}
+		if err := i.Client.Patch(ctx, crd, client.Apply, opts...); err != nil {
+			if apierrors.IsConflict(err) {
+				return fmt.Errorf("applying CRD %s: fields are owned by another field manager; "+
+					"upgrade the CRD the same way it was installed or use --install-crds-force: %w", crd.Name, err)
+			}
+			return fmt.Errorf("applying CRD %s: %w", crd.Name, err)
+		}
+		log.Info("Applied CRD", "crd", crd.Name, "version", Version)
+		applied = append(applied, crd.Name)
+	}
+	return i.waitEstablished(ctx, applied)
+}
+
+// waitEstablished waits until the API server serves every named CRD.
+func (i *CRDInstaller) waitEstablished(ctx context.Context, names []string) error {
+	timeout := i.EstablishTimeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	for _, name := range names {
+		err := wait.PollUntilContextTimeout(ctx, time.Second, timeout, true, func(ctx context.Context) (bool, error) {
+			var crd apiextensionsv1.CustomResourceDefinition
+			if err := i.Client.Get(ctx, types.NamespacedName{Name: name}, &crd); err != nil {
+				return false, client.IgnoreNotFound(err)
+			}
+			for _, c := range crd.Status.Conditions {
+				if c.Type == apiextensionsv1.Established && c.Status == apiextensionsv1.ConditionTrue {
+					return true, nil
+				}
+			}
+			return false, nil
+		})
+		if err != nil {
+			return fmt.Errorf("waiting for CRD %s to be established: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// newer reports whether version a is newer than b. Versions that are not
+// semver, such as "dev" builds, are never newer nor older.
+func newer(a, b string) bool {
+	va, err := semver.NewVersion(strings.TrimPrefix(a, "v"))
+	if err != nil {
+		return false
+	}
+	vb, err := semver.NewVersion(strings.TrimPrefix(b, "v"))
+	if err != nil {
+		return false
+	}
+	return va.GreaterThan(vb)
+}
@@ -0,0 +1,74 @@
+package controllers_test
+
+import (
+	"context"
+	"io/fs"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/example/helm-operator/config/crd"
+	"github.com/example/helm-operator/controllers"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("CRD installation", func() {
+	ctx := context.Background()
+
+	installer := func(force bool) *controllers.CRDInstaller {
+		bases, err := fs.Sub(crd.Bases, "bases")
+		Expect(err).NotTo(HaveOccurred())
+		return &controllers.CRDInstaller{Client: k8sClient, CRDs: bases, Force: force}
+	}
+	installed := func() *apiextensionsv1.CustomResourceDefinition {
+		var c apiextensionsv1.CustomResourceDefinition
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: controllers.HelmReleaseCRDName}, &c)).To(Succeed())
+		return &c
+	}
+	withVersion := func(v string) {
+		previous := controllers.Version
+		controllers.Version = v
+		DeferCleanup(func() { controllers.Version = previous })
+	}
+	// Hands the CRD back to the operator's field manager for later specs.
+	AfterEach(func() { Expect(installer(true).Install(ctx)).To(Succeed()) })
+
+	It("applies the embedded CRDs and records the operator version", func() {
+		withVersion("0.5.0")
+		Expect(installer(false).Install(ctx)).To(Succeed())
+
+		c := installed()
+		Expect(c.Annotations).To(HaveKeyWithValue(controllers.AnnotationOperatorVersion, "0.5.0"))
+		Expect(c.ManagedFields).To(ContainElement(HaveField("Manager", "helm-operator")))
+	})
+
+	It("leaves a CRD applied by a newer operator alone", func() {
+		withVersion("0.5.0")
+		Expect(installer(true).Install(ctx)).To(Succeed())
+
+		withVersion("0.4.0")
+		Expect(installer(false).Install(ctx)).To(Succeed())
+		Expect(installed().Annotations).To(HaveKeyWithValue(controllers.AnnotationOperatorVersion, "0.5.0"))
+	})
+
+	It("reports conflicts with another field manager unless forced", func() {
+		withVersion("0.5.0")
+		other := &apiextensionsv1.CustomResourceDefinition{
+			TypeMeta: metav1.TypeMeta{APIVersion: "apiextensions.k8s.io/v1", Kind: "CustomResourceDefinition"},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        controllers.HelmReleaseCRDName,
+				Annotations: map[string]string{controllers.AnnotationOperatorVersion: "0.3.0"},
+			},
+		}
+		Expect(k8sClient.Patch(ctx, other, client.Apply, client.FieldOwner("kubectl"), client.ForceOwnership)).To(Succeed())
+
+		err := installer(false).Install(ctx)
+		Expect(err).To(MatchError(ContainSubstring("owned by another field manager")))
+
+		Expect(installer(true).Install(ctx)).To(Succeed())
+		Expect(installed().Annotations).To(HaveKeyWithValue(controllers.AnnotationOperatorVersion, "0.5.0"))
+	})
+})
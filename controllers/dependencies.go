@@ -0,0 +1,478 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+)
+
+const (
+	conditionDependencyCycle = "DependencyCycle"
+	// reasonDependencyCycle is the Ready reason of releases on a cycle. Unlike
+	// Helm failures they are not held back by retryAfterFailure.
+	reasonDependencyCycle = "DependencyCycle"
+
+	conditionDependencyNotReady = "DependencyNotReady"
+
+	// requeueForDependencies is how often a release waiting on the order of
+	// its dependencies is retried.
+	requeueForDependencies = 2 * time.Second
+	// requeueForDependencyReadiness is how often a release waiting for its
+	// dependencies to become Ready is retried. dependentsHandler requeues it
+	// as soon as one does; this only covers missed events.
+	requeueForDependencyReadiness = 30 * time.Second
+)
+
+// Dependencies returns the HelmReleases release names in spec.dependsOn,
+// defaulting each namespace to the release's own.
+func Dependencies(release *helmv1alpha1.HelmRelease) []types.NamespacedName {
+	deps := make([]types.NamespacedName, 0, len(release.Spec.DependsOn))
+	for _, ref := range release.Spec.DependsOn {
+		ns := ref.Namespace
+		if ns == "" {
+			ns = release.Namespace
+		}
+		deps = append(deps, types.NamespacedName{Namespace: ns, Name: ref.Name})
+	}
+	return deps
+}
+
+// DependencyCycles returns the cycles spec.dependsOn forms among releases.
+// Each cycle lists its releases in dependency order, starting from the one
+// that sorts first, so the same loop is reported the same way every time.
+// Dependencies on releases not in the list are ignored.
+func DependencyCycles(releases []helmv1alpha1.HelmRelease) [][]types.NamespacedName {
+	edges := make(map[types.NamespacedName][]types.NamespacedName, len(releases))
+	for i := range releases {
+		edges[client.ObjectKeyFromObject(&releases[i])] = Dependencies(&releases[i])
+	}
+	return findCycles(edges)
+}
+
+// DependencyCycleError describes cycle for validation messages.
+func DependencyCycleError(cycle []types.NamespacedName) string {
+	return "spec.dependsOn forms a cycle: " + cycleString(cycle)
+}
+
+// findCycles reports a cycle for every back edge a depth-first search of
+// edges finds, so every loop in the graph is reported at least once.
+func findCycles(edges map[types.NamespacedName][]types.NamespacedName) [][]types.NamespacedName {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[types.NamespacedName]int, len(edges))
+	seen := map[string]bool{}
+	var cycles [][]types.NamespacedName
+	var path []types.NamespacedName
+	var visit func(key types.NamespacedName)
+	visit = func(key types.NamespacedName) {
+		state[key] = visiting
+		path = append(path, key)
+		for _, dep := range edges[key] {
+			if _, ok := edges[dep]; !ok {
+				continue
+			}
+			switch state[dep] {
+			case unvisited:
+				visit(dep)
+			case visiting:
+				start := len(path) - 1
+				for path[start] != dep {
+					start--
+				}
+				cycle := canonicalCycle(path[start:])
+				if id := cycleString(cycle); !seen[id] {
+					seen[id] = true
+					cycles = append(cycles, cycle)
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[key] = done
+	}
+	for _, key := range sortedNames(edges) {
+		if state[key] == unvisited {
+			visit(key)
+		}
+	}
+	return cycles
+}
+
+// cycleThrough returns a cycle of edges that passes through key, or nil.
+func cycleThrough(edges map[types.NamespacedName][]types.NamespacedName, key types.NamespacedName) []types.NamespacedName {
+	// Breadth-first from key, so the shortest loop back to it is reported.
+	parent := map[types.NamespacedName]types.NamespacedName{}
+	queue := []types.NamespacedName{key}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, dep := range edges[current] {
+			if _, ok := edges[dep]; !ok {
+				continue
+			}
+			if dep == key {
+				cycle := []types.NamespacedName{current}
+				for cycle[0] != key {
+					cycle = append([]types.NamespacedName{parent[cycle[0]]}, cycle...)
+				}
+				return canonicalCycle(cycle)
+			}
+			if _, ok := parent[dep]; !ok {
+				parent[dep] = current
+				queue = append(queue, dep)
+			}
+		}
+	}
+	return nil
+}
+
+// canonicalCycle copies cycle rotated to start at its smallest member.
+func canonicalCycle(cycle []types.NamespacedName) []types.NamespacedName {
+	first := 0
+	for i := range cycle {
+		if cycle[i].String() < cycle[first].String() {
+			first = i
+		}
+	}
+	out := make([]types.NamespacedName, 0, len(cycle))
+	out = append(out, cycle[first:]...)
+	return append(out, cycle[:first]...)
+}
+
+// cycleString renders cycle as "a -> b -> a".
+func cycleString(cycle []types.NamespacedName) string {
+	parts := make([]string, 0, len(cycle)+1)
+	for _, key := range cycle {
+		parts = append(parts, key.String())
+	}
+	parts = append(parts, cycle[0].String())
+	return strings.Join(parts, " -> ")
+}
+
+func sortedNames(edges map[types.NamespacedName][]types.NamespacedName) []types.NamespacedName {
+	keys := make([]types.NamespacedName, 0, len(edges))
+	for key := range edges {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+	return keys
+}
+
+// dependencyNode is a HelmRelease in the dependencyGraph.
+type dependencyNode struct {
+	deps     []types.NamespacedName
+	deleting bool
+	// reconciled is set once the release has been reconciled since the
+	// operator started.
+	reconciled bool
+}
+
+// dependencyGraph caches the spec.dependsOn DAG of all HelmReleases. It is
+// filled from the informer cache on the first reconcile and kept current as
+// releases are reconciled and deleted. The zero value is ready to use.
+type dependencyGraph struct {
+	mu     sync.Mutex
+	loaded bool
+	nodes  map[types.NamespacedName]*dependencyNode
+}
+
+// load fills the graph with every HelmRelease in the cache, once.
+func (g *dependencyGraph) load(ctx context.Context, c client.Reader) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.loaded {
+		return nil
+	}
+	var list helmv1alpha1.HelmReleaseList
+	if err := c.List(ctx, &list); err != nil {
+		return fmt.Errorf("listing HelmReleases for dependency graph: %w", err)
+	}
+	if g.nodes == nil {
+		g.nodes = make(map[types.NamespacedName]*dependencyNode, len(list.Items))
+	}
+	for i := range list.Items {
+		g.setLocked(&list.Items[i])
+	}
+	g.loaded = true
+	return nil
+}
+
+// set records the current dependencies of release.
+func (g *dependencyGraph) set(release *helmv1alpha1.HelmRelease) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.nodes == nil {
+		g.nodes = map[types.NamespacedName]*dependencyNode{}
+	}
+	g.setLocked(release)
+}
+
+func (g *dependencyGraph) setLocked(release *helmv1alpha1.HelmRelease) {
+	key := client.ObjectKeyFromObject(release)
+	node := g.nodes[key]
+	if node == nil {
+		node = &dependencyNode{}
+		g.nodes[key] = node
+	}
+	node.deps = Dependencies(release)
+	node.deleting = !release.DeletionTimestamp.IsZero()
+}
+
+// remove drops a release that no longer exists.
+func (g *dependencyGraph) remove(key types.NamespacedName) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.nodes, key)
+}
+
+// markReconciled records that key has been reconciled since startup.
+func (g *dependencyGraph) markReconciled(key types.NamespacedName) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if node := g.nodes[key]; node != nil {
+		node.reconciled = true
+	}
+}
+
+// cycle returns a dependency cycle through key, or nil.
+func (g *dependencyGraph) cycle(key types.NamespacedName) []types.NamespacedName {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return cycleThrough(g.edgesLocked(), key)
+}
+
+// unreconciledDependencies returns the dependencies of key that have not
+// been reconciled since startup. Reconciling releases only after their
+// dependencies brings stacks up in topological order after a restart.
+func (g *dependencyGraph) unreconciledDependencies(key types.NamespacedName) []types.NamespacedName {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	node := g.nodes[key]
+	if node == nil {
+		return nil
+	}
+	var pending []types.NamespacedName
+	for _, dep := range node.deps {
+		if n := g.nodes[dep]; n != nil && !n.reconciled && dep != key {
+			pending = append(pending, dep)
+		}
+	}
+	return pending
+}
+
+// dependents returns the releases that depend on key, filtered by keep.
+func (g *dependencyGraph) dependents(key types.NamespacedName, keep func(types.NamespacedName, *dependencyNode) bool) []types.NamespacedName {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var out []types.NamespacedName
+	for _, other := range sortedNames(g.edgesLocked()) {
+		if other == key {
+			continue
+		}
+		for _, dep := range g.nodes[other].deps {
+			if dep == key && keep(other, g.nodes[other]) {
+				out = append(out, other)
+				break
+			}
+		}
+	}
+	return out
+}
+
+func (g *dependencyGraph) edgesLocked() map[types.NamespacedName][]types.NamespacedName {
+	edges := make(map[types.NamespacedName][]types.NamespacedName, len(g.nodes))
+	for key, node := range g.nodes {
+		edges[key] = node.deps
+	}
+	return edges
+}
+
+// reconcileDependencyCycle fails a release whose dependsOn forms a cycle.
+// Editing another release on the cycle requeues it through
+// dependentsHandler; it is also retried periodically.
+func (r *HelmReleaseReconciler) reconcileDependencyCycle(ctx context.Context, release *helmv1alpha1.HelmRelease, cycle []types.NamespacedName) (ctrl.Result, error) {
+	base := release.DeepCopy()
+	release.Status.ExpiresAt = ttlExpiry(release)
+	msg := DependencyCycleError(cycle)
+	release.Status.Phase = helmv1alpha1.PhaseFailed
+	release.Status.ObservedGeneration = release.Generation
+	setCondition(release, metav1.Condition{
+		Type:               "Ready",
+		Status:             metav1.ConditionFalse,
+		Reason:             reasonDependencyCycle,
+		Message:            msg,
+		ObservedGeneration: release.Generation,
+	})
+	setCondition(release, metav1.Condition{
+		Type:               conditionDependencyCycle,
+		Status:             metav1.ConditionTrue,
+		Reason:             "CycleDetected",
+		Message:            msg,
+		ObservedGeneration: release.Generation,
+	})
+	if err := r.patchStatus(ctx, release, base); err != nil {
+		return ctrl.Result{}, err
+	}
+	ctrl.LoggerFrom(ctx).Info("Dependency cycle, skipping", "cycle", cycleString(cycle))
+	return ctrl.Result{RequeueAfter: requeueOnFailure}, nil
+}
+
+// clearDependencyCycle sets DependencyCycle to False if it was reported.
+func clearDependencyCycle(release *helmv1alpha1.HelmRelease) {
+	for _, c := range release.Status.Conditions {
+		if c.Type == conditionDependencyCycle && c.Status != metav1.ConditionFalse {
+			setCondition(release, metav1.Condition{
+				Type:               conditionDependencyCycle,
+				Status:             metav1.ConditionFalse,
+				Reason:             "Acyclic",
+				Message:            "spec.dependsOn forms no cycle",
+				ObservedGeneration: release.Generation,
+			})
+			return
+		}
+	}
+}
+
+// notReadyDependencies describes the dependencies of release that do not
+// exist or are not in Phase Ready, as "namespace/name (phase)".
+func (r *HelmReleaseReconciler) notReadyDependencies(ctx context.Context, release *helmv1alpha1.HelmRelease) ([]string, error) {
+	key := client.ObjectKeyFromObject(release)
+	var pending []string
+	for _, dep := range Dependencies(release) {
+		if dep == key {
+			continue
+		}
+		var other helmv1alpha1.HelmRelease
+		if err := r.Get(ctx, dep, &other); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return nil, fmt.Errorf("reading dependency %s: %w", dep, err)
+			}
+			pending = append(pending, dep.String()+" (not found)")
+			continue
+		}
+		if other.Status.Phase != helmv1alpha1.PhaseReady {
+			phase := string(other.Status.Phase)
+			if phase == "" {
+				phase = "Pending"
+			}
+			pending = append(pending, fmt.Sprintf("%s (%s)", dep, phase))
+		}
+	}
+	return pending, nil
+}
+
+// reconcileDependencyNotReady holds back a release until its dependencies
+// are Ready. A release that is already Ready stays so; it is only not
+// upgraded in the meantime.
+func (r *HelmReleaseReconciler) reconcileDependencyNotReady(ctx context.Context, release *helmv1alpha1.HelmRelease, pending []string) (ctrl.Result, error) {
+	base := release.DeepCopy()
+	release.Status.ExpiresAt = ttlExpiry(release)
+	msg := "Waiting for dependencies to be Ready: " + strings.Join(pending, ", ")
+	setCondition(release, metav1.Condition{
+		Type:               conditionDependencyNotReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             "DependenciesNotReady",
+		Message:            msg,
+		ObservedGeneration: release.Generation,
+	})
+	if !meta.IsStatusConditionTrue(release.Status.Conditions, "Ready") {
+		setCondition(release, metav1.Condition{
+			Type:               "Ready",
+			Status:             metav1.ConditionFalse,
+			Reason:             conditionDependencyNotReady,
+			Message:            msg,
+			ObservedGeneration: release.Generation,
+		})
+	}
+	if err := r.patchStatus(ctx, release, base); err != nil {
+		return ctrl.Result{}, err
+	}
+	ctrl.LoggerFrom(ctx).V(1).Info("Waiting for dependencies to be Ready", "dependencies", pending)
+	return ctrl.Result{RequeueAfter: requeueForDependencyReadiness}, nil
+}
+
+// clearDependencyNotReady sets DependencyNotReady to False if it was
+// reported.
+func clearDependencyNotReady(release *helmv1alpha1.HelmRelease) {
+	for _, c := range release.Status.Conditions {
+		if c.Type == conditionDependencyNotReady && c.Status != metav1.ConditionFalse {
+			setCondition(release, metav1.Condition{
+				Type:               conditionDependencyNotReady,
+				Status:             metav1.ConditionFalse,
+				Reason:             "DependenciesReady",
+				Message:            "All dependencies are Ready",
+				ObservedGeneration: release.Generation,
+			})
+			return
+		}
+	}
+}
+
+// teardownDependents returns the dependents release must outlive while it is
+// being deleted: those being deleted too, and, when release's namespace is
+// terminating, every dependent in that namespace. Uninstalling them first
+// takes a stack down in reverse dependency order. Releases on a cycle with
+// release are not waited for, since that would never finish.
+func (r *HelmReleaseReconciler) teardownDependents(ctx context.Context, release *helmv1alpha1.HelmRelease) ([]types.NamespacedName, error) {
+	key := client.ObjectKeyFromObject(release)
+	var ns corev1.Namespace
+	if err := r.Get(ctx, types.NamespacedName{Name: release.Namespace}, &ns); client.IgnoreNotFound(err) != nil {
+		return nil, fmt.Errorf("reading namespace: %w", err)
+	}
+	terminating := !ns.DeletionTimestamp.IsZero()
+	cycle := r.deps.cycle(key)
+	return r.deps.dependents(key, func(other types.NamespacedName, node *dependencyNode) bool {
+		for _, c := range cycle {
+			if c == other {
+				return false
+			}
+		}
+		return node.deleting || terminating && other.Namespace == release.Namespace
+	}), nil
+}
+
+// dependentsHandler requeues the dependents of a HelmRelease whose spec
+// changed, so that a release rejected for a cycle is retried as soon as the
+// cycle is broken elsewhere, and of one that became Ready, so that releases
+// waiting for it proceed.
+func (r *HelmReleaseReconciler) dependentsHandler() handler.EventHandler {
+	return handler.Funcs{
+		UpdateFunc: func(_ context.Context, e event.UpdateEvent, q workqueue.RateLimitingInterface) {
+			release, ok := e.ObjectNew.(*helmv1alpha1.HelmRelease)
+			old, okOld := e.ObjectOld.(*helmv1alpha1.HelmRelease)
+			if !ok || !okOld {
+				return
+			}
+			switch {
+			case old.Generation != release.Generation:
+				// Update the graph now rather than when release is
+				// reconciled, which may happen after its dependents are.
+				r.deps.set(release)
+			case old.Status.Phase != helmv1alpha1.PhaseReady && release.Status.Phase == helmv1alpha1.PhaseReady:
+			default:
+				return
+			}
+			all := func(types.NamespacedName, *dependencyNode) bool { return true }
+			for _, key := range r.deps.dependents(client.ObjectKeyFromObject(release), all) {
+				q.Add(ctrl.Request{NamespacedName: key})
+			}
+		},
+	}
+}
@@ -0,0 +1,227 @@
+package controllers_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"github.com/example/helm-operator/controllers"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// indexOf returns the position of name in names, or -1.
+func indexOf(names []string, name string) int {
+	for i, n := range names {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
+
+var _ = Describe("Dependency cycles", func() {
+	release := func(ns, name string, deps ...helmv1alpha1.DependencyReference) helmv1alpha1.HelmRelease {
+		return helmv1alpha1.HelmRelease{
+			ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name},
+			Spec:       helmv1alpha1.HelmReleaseSpec{DependsOn: deps},
+		}
+	}
+
+	It("finds no cycle in a DAG or through missing releases", func() {
+		releases := []helmv1alpha1.HelmRelease{
+			release("apps", "web", helmv1alpha1.DependencyReference{Name: "db"}, helmv1alpha1.DependencyReference{Name: "crds", Namespace: "infra"}),
+			release("apps", "db", helmv1alpha1.DependencyReference{Name: "crds", Namespace: "infra"}),
+			release("infra", "crds", helmv1alpha1.DependencyReference{Name: "gone"}),
+		}
+		Expect(controllers.DependencyCycles(releases)).To(BeEmpty())
+	})
+
+	It("reports each loop once, starting from its first release", func() {
+		releases := []helmv1alpha1.HelmRelease{
+			release("apps", "web", helmv1alpha1.DependencyReference{Name: "db"}),
+			release("apps", "db", helmv1alpha1.DependencyReference{Name: "cache"}),
+			release("apps", "cache", helmv1alpha1.DependencyReference{Name: "web"}),
+			release("apps", "self", helmv1alpha1.DependencyReference{Name: "self"}),
+		}
+		cycles := controllers.DependencyCycles(releases)
+		Expect(cycles).To(ConsistOf(
+			[]types.NamespacedName{{Namespace: "apps", Name: "cache"}, {Namespace: "apps", Name: "web"}, {Namespace: "apps", Name: "db"}},
+			[]types.NamespacedName{{Namespace: "apps", Name: "self"}},
+		))
+		Expect(controllers.DependencyCycleError(cycles[0])).To(Equal(
+			"spec.dependsOn forms a cycle: apps/cache -> apps/web -> apps/db -> apps/cache"))
+	})
+})
+
+var _ = Describe("Dependency ordering", func() {
+	ctx := context.Background()
+
+	It("rejects releases on a cycle until it is broken", func() {
+		mock := &MockHelmClient{}
+		cancel := startManager(mock)
+		defer cancel()
+
+		first := makeHR("test-cycle-first")
+		first.Spec.DependsOn = []helmv1alpha1.DependencyReference{{Name: "test-cycle-second"}}
+		second := makeHR("test-cycle-second")
+		second.Spec.DependsOn = []helmv1alpha1.DependencyReference{{Name: "test-cycle-first"}}
+		for _, hr := range []*helmv1alpha1.HelmRelease{first, second} {
+			Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+			hr := hr
+			DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+		}
+
+		Eventually(func(g Gomega) {
+			for _, name := range []string{first.Name, second.Name} {
+				fetched, err := getHR(ctx, name)
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(fetched.Status.Phase).To(Equal(helmv1alpha1.PhaseFailed))
+				cond := findCondition(fetched, "DependencyCycle")
+				g.Expect(cond).NotTo(BeNil())
+				g.Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+				g.Expect(cond.Message).To(Equal("spec.dependsOn forms a cycle: default/test-cycle-first -> default/test-cycle-second -> default/test-cycle-first"))
+			}
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+		mock.mu.Lock()
+		Expect(mock.Installed).NotTo(ContainElement(first.Name))
+		Expect(mock.Installed).NotTo(ContainElement(second.Name))
+		mock.mu.Unlock()
+
+		fetched, err := getHR(ctx, second.Name)
+		Expect(err).NotTo(HaveOccurred())
+		patch := client.MergeFrom(fetched.DeepCopy())
+		fetched.Spec.DependsOn = nil
+		Expect(k8sClient.Patch(ctx, fetched, patch)).To(Succeed())
+
+		Eventually(func(g Gomega) {
+			for _, name := range []string{first.Name, second.Name} {
+				fetched, err := getHR(ctx, name)
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(fetched.Status.Phase).To(Equal(helmv1alpha1.PhaseReady))
+				cond := findCondition(fetched, "DependencyCycle")
+				g.Expect(cond).NotTo(BeNil())
+				g.Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+			}
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+	})
+
+	It("resyncs existing releases in dependency order on startup", func() {
+		names := []string{"test-startup-app", "test-startup-db", "test-startup-crds"}
+		for i, name := range names {
+			hr := makeHR(name)
+			// Already reconciled once, as after an operator restart.
+			hr.Finalizers = []string{"helm.example.com/finalizer"}
+			if i+1 < len(names) {
+				hr.Spec.DependsOn = []helmv1alpha1.DependencyReference{{Name: names[i+1]}}
+			}
+			Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+			DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+		}
+
+		mock := &MockHelmClient{}
+		cancel := startManager(mock)
+		defer cancel()
+
+		Eventually(func(g Gomega) {
+			mock.mu.Lock()
+			defer mock.mu.Unlock()
+			crds, db, app := indexOf(mock.Installed, names[2]), indexOf(mock.Installed, names[1]), indexOf(mock.Installed, names[0])
+			g.Expect(crds).To(BeNumerically(">=", 0))
+			g.Expect(db).To(BeNumerically(">", crds))
+			g.Expect(app).To(BeNumerically(">", db))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+	})
+
+	It("uninstalls dependents first when their namespace is deleted", func() {
+		mock := &MockHelmClient{}
+		cancel := startManager(mock)
+		defer cancel()
+
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-teardown"}}
+		Expect(k8sClient.Create(ctx, ns)).To(Succeed())
+		crds := makeHR("test-teardown-crds")
+		crds.Namespace = ns.Name
+		app := makeHR("test-teardown-app")
+		app.Namespace = ns.Name
+		app.Spec.DependsOn = []helmv1alpha1.DependencyReference{{Name: crds.Name}}
+		Expect(k8sClient.Create(ctx, crds)).To(Succeed())
+		Expect(k8sClient.Create(ctx, app)).To(Succeed())
+
+		Eventually(func(g Gomega) {
+			for _, hr := range []*helmv1alpha1.HelmRelease{crds, app} {
+				fetched := &helmv1alpha1.HelmRelease{}
+				g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hr), fetched)).To(Succeed())
+				g.Expect(fetched.Status.Phase).To(Equal(helmv1alpha1.PhaseReady))
+			}
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+
+		// envtest runs no namespace controller, so the namespace stays
+		// Terminating and its releases are deleted by hand.
+		Expect(k8sClient.Delete(ctx, ns)).To(Succeed())
+		Eventually(func(g Gomega) {
+			fetched := &corev1.Namespace{}
+			g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(ns), fetched)).To(Succeed())
+			g.Expect(fetched.DeletionTimestamp).NotTo(BeNil())
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+		time.Sleep(time.Second)
+		Expect(k8sClient.Delete(ctx, crds)).To(Succeed())
+		Consistently(func() []string {
+			mock.mu.Lock()
+			defer mock.mu.Unlock()
+			return mock.Uninstalled
+		}).WithTimeout(2 * time.Second).WithPolling(polling).ShouldNot(ContainElement(crds.Name))
+
+		Expect(k8sClient.Delete(ctx, app)).To(Succeed())
+		Eventually(func() []string {
+			mock.mu.Lock()
+			defer mock.mu.Unlock()
+			return mock.Uninstalled
+		}).WithTimeout(timeout).WithPolling(polling).Should(Equal([]string{app.Name, crds.Name}))
+	})
+
+	It("waits for its dependencies to be Ready", func() {
+		mock := &MockHelmClient{}
+		cancel := startManager(mock)
+		defer cancel()
+
+		app := makeHR("test-notready-app")
+		app.Spec.DependsOn = []helmv1alpha1.DependencyReference{{Name: "test-notready-crds"}}
+		Expect(k8sClient.Create(ctx, app)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, app) })
+
+		Eventually(func(g Gomega) {
+			fetched, err := getHR(ctx, app.Name)
+			g.Expect(err).NotTo(HaveOccurred())
+			cond := findCondition(fetched, "DependencyNotReady")
+			g.Expect(cond).NotTo(BeNil())
+			g.Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+			g.Expect(cond.Message).To(ContainSubstring("default/test-notready-crds (not found)"))
+			ready := findCondition(fetched, "Ready")
+			g.Expect(ready).NotTo(BeNil())
+			g.Expect(ready.Reason).To(Equal("DependencyNotReady"))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+		mock.mu.Lock()
+		Expect(mock.Installed).NotTo(ContainElement(app.Name))
+		mock.mu.Unlock()
+
+		crds := makeHR("test-notready-crds")
+		Expect(k8sClient.Create(ctx, crds)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, crds) })
+
+		Eventually(func(g Gomega) {
+			fetched, err := getHR(ctx, app.Name)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(fetched.Status.Phase).To(Equal(helmv1alpha1.PhaseReady))
+			g.Expect(findCondition(fetched, "DependencyNotReady").Status).To(Equal(metav1.ConditionFalse))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+		mock.mu.Lock()
+		defer mock.mu.Unlock()
+		Expect(indexOf(mock.Installed, app.Name)).To(BeNumerically(">", indexOf(mock.Installed, crds.Name)))
+	})
+})
@@ -0,0 +1,248 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"github.com/example/helm-operator/notify"
+	"k8s.io/apimachinery/pkg/api/meta"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DigestSchedule is when the fleet digest is sent: every day, or every week
+// on Weekday, at At past midnight UTC.
+type DigestSchedule struct {
+	Weekly  bool
+	Weekday time.Weekday
+	At      time.Duration
+}
+
+// ParseDigestSchedule parses "daily HH:MM" or "weekly <day> HH:MM", such as
+// "weekly mon 09:00". Times are UTC.
+func ParseDigestSchedule(s string) (DigestSchedule, error) {
+	fields := strings.Fields(strings.ToLower(s))
+	var schedule DigestSchedule
+	switch {
+	case len(fields) == 2 && fields[0] == "daily":
+	case len(fields) == 3 && fields[0] == "weekly":
+		schedule.Weekly = true
+		day, ok := weekdays[fields[1]]
+		if !ok {
+			return DigestSchedule{}, fmt.Errorf("digest schedule %q: unknown weekday %q", s, fields[1])
+		}
+		schedule.Weekday = day
+	default:
+		return DigestSchedule{}, fmt.Errorf("digest schedule %q: want \"daily HH:MM\" or \"weekly <day> HH:MM\"", s)
+	}
+	at, err := time.Parse("15:04", fields[len(fields)-1])
+	if err != nil {
+		return DigestSchedule{}, fmt.Errorf("digest schedule %q: invalid time %q", s, fields[len(fields)-1])
+	}
+	schedule.At = time.Duration(at.Hour())*time.Hour + time.Duration(at.Minute())*time.Minute
+	return schedule, nil
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// Period is the time between two digests.
+func (s DigestSchedule) Period() time.Duration {
+	if s.Weekly {
+		return 7 * 24 * time.Hour
+	}
+	return 24 * time.Hour
+}
+
+// String returns the schedule in ParseDigestSchedule's syntax.
+func (s DigestSchedule) String() string {
+	at := time.Time{}.Add(s.At).Format("15:04")
+	if s.Weekly {
+		return "weekly " + strings.ToLower(s.Weekday.String()[:3]) + " " + at
+	}
+	return "daily " + at
+}
+
+// Next returns the first scheduled time strictly after t.
+func (s DigestSchedule) Next(t time.Time) time.Time {
+	t = t.UTC()
+	next := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).Add(s.At)
+	if s.Weekly {
+		next = next.AddDate(0, 0, (int(s.Weekday)-int(next.Weekday())+7)%7)
+	}
+	for !next.After(t) {
+		next = next.Add(s.Period())
+	}
+	return next
+}
+
+// DigestEntry is one release listed in a digest.
+type DigestEntry struct {
+	Namespace string
+	Name      string
+	Chart     string
+	Version   string
+	// Detail explains why the release is listed.
+	Detail string
+	// Time is when a TTL expires; zero for other entries.
+	Time time.Time
+}
+
+// DigestReport is the data the digest template is executed with.
+type DigestReport struct {
+	GeneratedAt time.Time
+	// Period is "daily" or "weekly".
+	Period   string
+	Releases int
+
+	Failed         []DigestEntry
+	PendingUpdates []DigestEntry
+	Drifted        []DigestEntry
+	// Expiring lists releases whose TTL elapses before the next digest.
+	Expiring []DigestEntry
+}
+
+// DefaultDigestTemplate renders a DigestReport as plain text.
+const DefaultDigestTemplate = `Fleet status at {{ .GeneratedAt.Format "2006-01-02 15:04 MST" }}: {{ .Releases }} releases.
+{{ define "entries" }}{{ range . }}
+  - {{ .Namespace }}/{{ .Name }} ({{ .Chart }} {{ .Version }}){{ if not .Time.IsZero }} at {{ .Time.Format "2006-01-02 15:04 MST" }}{{ end }}{{ with .Detail }}: {{ . }}{{ end }}{{ end }}
+{{ end }}
+Failed releases: {{ len .Failed }}{{ template "entries" .Failed }}
+Pending updates: {{ len .PendingUpdates }}{{ template "entries" .PendingUpdates }}
+Drift warnings: {{ len .Drifted }}{{ template "entries" .Drifted }}
+TTL expirations before the next digest: {{ len .Expiring }}{{ template "entries" .Expiring }}`
+
+// FleetDigest is a controller-runtime Runnable that sends a digest of fleet
+// status to Notifier on Schedule: failed releases, pending chart updates,
+// drift warnings and TTLs about to expire. It runs only on the leader.
+type FleetDigest struct {
+	Client   client.Reader
+	Notifier notify.Notifier
+	Schedule DigestSchedule
+
+	// Template renders the message body from a DigestReport. Defaults to
+	// DefaultDigestTemplate.
+	Template *template.Template
+
+	// Now defaults to time.Now.
+	Now func() time.Time
+}
+
+// Start implements manager.Runnable.
+func (d *FleetDigest) Start(ctx context.Context) error {
+	log := ctrl.Log.WithName("fleet-digest")
+	for {
+		next := d.Schedule.Next(d.now())
+		log.V(1).Info("next digest scheduled", "at", next)
+		timer := time.NewTimer(next.Sub(d.now()))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+		if err := d.Send(ctx); err != nil {
+			log.Error(err, "sending fleet digest")
+		}
+	}
+}
+
+// Send builds a digest of the current fleet status and sends it.
+func (d *FleetDigest) Send(ctx context.Context) error {
+	report, err := d.Report(ctx)
+	if err != nil {
+		return err
+	}
+	msg, err := d.Render(report)
+	if err != nil {
+		return err
+	}
+	return d.Notifier.Notify(ctx, msg)
+}
+
+// Report gathers the releases a digest lists.
+func (d *FleetDigest) Report(ctx context.Context) (*DigestReport, error) {
+	var list helmv1alpha1.HelmReleaseList
+	if err := d.Client.List(ctx, &list); err != nil {
+		return nil, fmt.Errorf("listing HelmReleases: %w", err)
+	}
+	now := d.now()
+	period := "daily"
+	if d.Schedule.Weekly {
+		period = "weekly"
+	}
+	report := &DigestReport{GeneratedAt: now.UTC(), Period: period, Releases: len(list.Items)}
+	horizon := d.Schedule.Next(now)
+
+	for i := range list.Items {
+		hr := &list.Items[i]
+		entry := func(detail string) DigestEntry {
+			version := hr.Status.DeployedVersion
+			if version == "" {
+				version = hr.Spec.Version
+			}
+			return DigestEntry{Namespace: hr.Namespace, Name: hr.Name, Chart: hr.Spec.Chart, Version: version, Detail: detail}
+		}
+		if hr.Status.Phase == helmv1alpha1.PhaseFailed {
+			var detail string
+			if ready := meta.FindStatusCondition(hr.Status.Conditions, "Ready"); ready != nil {
+				detail = ready.Message
+			}
+			report.Failed = append(report.Failed, entry(detail))
+		}
+		if meta.IsStatusConditionTrue(hr.Status.Conditions, conditionUpdateAvailable) {
+			report.PendingUpdates = append(report.PendingUpdates, entry(hr.Status.LatestVersion+" is available"))
+		}
+		if meta.IsStatusConditionTrue(hr.Status.Conditions, conditionDrifted) {
+			report.Drifted = append(report.Drifted, entry(meta.FindStatusCondition(hr.Status.Conditions, conditionDrifted).Message))
+		}
+		if expiresAt := ttlExpiry(hr); expiresAt != nil && hr.DeletionTimestamp == nil && expiresAt.Time.Before(horizon) {
+			e := entry("")
+			e.Time = expiresAt.UTC()
+			report.Expiring = append(report.Expiring, e)
+		}
+	}
+
+	byName := func(entries []DigestEntry) {
+		sort.Slice(entries, func(i, j int) bool {
+			if entries[i].Namespace != entries[j].Namespace {
+				return entries[i].Namespace < entries[j].Namespace
+			}
+			return entries[i].Name < entries[j].Name
+		})
+	}
+	byName(report.Failed)
+	byName(report.PendingUpdates)
+	byName(report.Drifted)
+	sort.Slice(report.Expiring, func(i, j int) bool { return report.Expiring[i].Time.Before(report.Expiring[j].Time) })
+	return report, nil
+}
+
+// Render executes the digest template on report.
+func (d *FleetDigest) Render(report *DigestReport) (notify.Message, error) {
+	tmpl := d.Template
+	if tmpl == nil {
+		tmpl = template.Must(template.New("digest").Parse(DefaultDigestTemplate))
+	}
+	var body strings.Builder
+	if err := tmpl.Execute(&body, report); err != nil {
+		return notify.Message{}, fmt.Errorf("rendering digest: %w", err)
+	}
+	subject := fmt.Sprintf("Helm operator %s digest: %d failed, %d updates, %d drifted, %d expiring",
+		report.Period, len(report.Failed), len(report.PendingUpdates), len(report.Drifted), len(report.Expiring))
+	return notify.Message{Subject: subject, Body: body.String()}, nil
+}
+
+func (d *FleetDigest) now() time.Time {
+	if d.Now != nil {
+		return d.Now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,114 @@
+package controllers_test
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"github.com/example/helm-operator/controllers"
+	"github.com/example/helm-operator/notify"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type recordingNotifier struct {
+	mu       sync.Mutex
+	messages []notify.Message
+}
+
+func (n *recordingNotifier) Notify(_ context.Context, msg notify.Message) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.messages = append(n.messages, msg)
+	return nil
+}
+
+var _ = Describe("Fleet digest", func() {
+	ctx := context.Background()
+
+	It("parses schedules and computes the next run in UTC", func() {
+		daily, err := controllers.ParseDigestSchedule("daily 09:00")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(daily.String()).To(Equal("daily 09:00"))
+		now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC) // a Wednesday
+		Expect(daily.Next(now)).To(Equal(time.Date(2024, 5, 2, 9, 0, 0, 0, time.UTC)))
+
+		weekly, err := controllers.ParseDigestSchedule("weekly Mon 07:30")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(weekly.Next(now)).To(Equal(time.Date(2024, 5, 6, 7, 30, 0, 0, time.UTC)))
+		Expect(weekly.Next(time.Date(2024, 5, 6, 7, 30, 0, 0, time.UTC))).To(Equal(time.Date(2024, 5, 13, 7, 30, 0, 0, time.UTC)))
+
+		_, err = controllers.ParseDigestSchedule("hourly")
+		Expect(err).To(HaveOccurred())
+		_, err = controllers.ParseDigestSchedule("weekly funday 09:00")
+		Expect(err).To(MatchError(ContainSubstring("unknown weekday")))
+	})
+
+	It("lists failed, outdated, drifted and expiring releases", func() {
+		setStatus := func(name string, status helmv1alpha1.HelmReleaseStatus, ttl *metav1.Duration) {
+			hr := makeHR(name)
+			hr.Spec.TTL = ttl
+			Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+			DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+			for i := range status.Conditions {
+				status.Conditions[i].LastTransitionTime = metav1.Now()
+			}
+			hr.Status = status
+			Expect(k8sClient.Status().Update(ctx, hr)).To(Succeed())
+		}
+		condition := func(typ, message string) metav1.Condition {
+			return metav1.Condition{Type: typ, Status: metav1.ConditionTrue, Reason: "Test", Message: message}
+		}
+
+		setStatus("test-digest-failed", helmv1alpha1.HelmReleaseStatus{
+			Phase:      helmv1alpha1.PhaseFailed,
+			Conditions: []metav1.Condition{{Type: "Ready", Status: metav1.ConditionFalse, Reason: "InstallFailed", Message: "timed out"}},
+		}, nil)
+		setStatus("test-digest-update", helmv1alpha1.HelmReleaseStatus{
+			Phase:           helmv1alpha1.PhaseReady,
+			DeployedVersion: "1.0.0",
+			LatestVersion:   "1.2.0",
+			Conditions:      []metav1.Condition{condition("UpdateAvailable", "")},
+		}, nil)
+		setStatus("test-digest-drifted", helmv1alpha1.HelmReleaseStatus{
+			Phase:      helmv1alpha1.PhaseReady,
+			Conditions: []metav1.Condition{condition("Drifted", "Deployment/web changed")},
+		}, nil)
+		setStatus("test-digest-expiring", helmv1alpha1.HelmReleaseStatus{Phase: helmv1alpha1.PhaseReady},
+			&metav1.Duration{Duration: time.Hour})
+		setStatus("test-digest-long-ttl", helmv1alpha1.HelmReleaseStatus{Phase: helmv1alpha1.PhaseReady},
+			&metav1.Duration{Duration: 30 * 24 * time.Hour})
+
+		notifier := &recordingNotifier{}
+		digest := &controllers.FleetDigest{
+			Client:   k8sClient,
+			Notifier: notifier,
+			Schedule: controllers.DigestSchedule{At: 9 * time.Hour},
+		}
+		report, err := digest.Report(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		names := func(entries []controllers.DigestEntry) []string {
+			var out []string
+			for _, e := range entries {
+				out = append(out, e.Name)
+			}
+			return out
+		}
+		Expect(names(report.Failed)).To(ContainElement("test-digest-failed"))
+		Expect(names(report.PendingUpdates)).To(ContainElement("test-digest-update"))
+		Expect(names(report.Drifted)).To(ContainElement("test-digest-drifted"))
+		Expect(names(report.Expiring)).To(ContainElement("test-digest-expiring"))
+		Expect(names(report.Expiring)).NotTo(ContainElement("test-digest-long-ttl"))
+
+		Expect(digest.Send(ctx)).To(Succeed())
+		Expect(notifier.messages).To(HaveLen(1))
+		msg := notifier.messages[0]
+		Expect(msg.Subject).To(HavePrefix("Helm operator daily digest:"))
+		Expect(msg.Body).To(ContainSubstring("default/test-digest-failed (nginx 1.0.0): timed out"))
+		Expect(msg.Body).To(ContainSubstring("default/test-digest-update (nginx 1.0.0): 1.2.0 is available"))
+		Expect(msg.Body).To(ContainSubstring("default/test-digest-drifted (nginx 1.0.0): Deployment/web changed"))
+	})
+})
@@ -0,0 +1,259 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"helm.sh/helm/v3/pkg/action"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/resource"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const conditionDrifted = "Drifted"
+
+// maxDriftReported caps how many drifted objects are listed in the condition.
+const maxDriftReported = 5
+
+// DetectDrift compares the manifests of the deployed release with the live
+// objects and describes every object that is missing or differs. Only fields
+// set in the manifests are compared, so defaults and fields owned by other
+// controllers are not reported.
+func (h *HelmClient) DetectDrift(_ context.Context, releaseName, namespace string) ([]string, error) {
+	cfg, err := h.actionConfig(namespace)
+	if err != nil {
+		return nil, err
+	}
+	rel, err := action.NewGet(cfg).Run(releaseName)
+	if err != nil {
+		return nil, err
+	}
+	resources, err := cfg.KubeClient.Build(bytes.NewBufferString(rel.Manifest), false)
+	if err != nil {
+		return nil, fmt.Errorf("parsing release manifests: %w", err)
+	}
+
+	var drifted []string
+	err = resources.Visit(func(info *resource.Info, err error) error {
+		if err != nil {
+			return err
+		}
+		id := info.Mapping.GroupVersionKind.Kind + " " + info.Name
+		if info.Namespace != "" {
+			id = info.Mapping.GroupVersionKind.Kind + " " + info.Namespace + "/" + info.Name
+		}
+		live, err := resource.NewHelper(info.Client, info.Mapping).Get(info.Namespace, info.Name)
+		if apierrors.IsNotFound(err) {
+			drifted = append(drifted, id+": missing")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		desiredObj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(info.Object)
+		if err != nil {
+			return err
+		}
+		liveObj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(live)
+		if err != nil {
+			return err
+		}
+		if path := firstDifference(driftRelevant(desiredObj), liveObj, ""); path != "" {
+			drifted = append(drifted, id+": "+path+" changed")
+		}
+		return nil
+	})
+	return drifted, err
+}
+
+// driftRelevant drops the parts of a manifest that are expected to differ
+// from the live object: status and server-managed metadata.
+func driftRelevant(obj map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(obj))
+	for k, v := range obj {
+		switch k {
+		case "status":
+		case "metadata":
+			md, _ := v.(map[string]interface{})
+			kept := map[string]interface{}{}
+			for _, key := range []string{"labels", "annotations"} {
+				if val, ok := md[key]; ok {
+					kept[key] = val
+				}
+			}
+			out[k] = kept
+		default:
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// firstDifference returns the path of the first field set in desired whose
+// value differs in live, or "" when live contains everything in desired.
+// Lists of objects are compared item by item, matching items by name where
+// they have one, and extra live items are ignored: the API server and
+// admission webhooks add some, such as injected sidecars and the service
+// account token volume. Lists of plain values must match exactly.
+func firstDifference(desired, live interface{}, path string) string {
+	switch d := desired.(type) {
+	case map[string]interface{}:
+		l, ok := live.(map[string]interface{})
+		if !ok {
+			return orRoot(path)
+		}
+		for k, v := range d {
+			if p := firstDifference(v, l[k], path+"."+k); p != "" {
+				return p
+			}
+		}
+	case []interface{}:
+		l, ok := live.([]interface{})
+		if !ok || len(l) < len(d) || (len(l) != len(d) && !objectList(d)) {
+			return orRoot(path)
+		}
+		for i := range d {
+			if p := firstDifference(d[i], matchingItem(d[i], l, i), fmt.Sprintf("%s[%d]", path, i)); p != "" {
+				return p
+			}
+		}
+	case nil:
+	default:
+		// Numbers may decode as int64 on one side and float64 on the other.
+		if live == nil || fmt.Sprint(d) != fmt.Sprint(live) {
+			return orRoot(path)
+		}
+	}
+	return ""
+}
+
+// objectList reports whether every item of list is an object.
+func objectList(list []interface{}) bool {
+	for _, item := range list {
+		if _, ok := item.(map[string]interface{}); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// matchingItem returns the item of live with the name of the desired item,
+// or else the item at index i.
+func matchingItem(desired interface{}, live []interface{}, i int) interface{} {
+	if d, ok := desired.(map[string]interface{}); ok {
+		if name, ok := d["name"].(string); ok && name != "" {
+			for _, item := range live {
+				if l, ok := item.(map[string]interface{}); ok && l["name"] == name {
+					return l
+				}
+			}
+			return nil
+		}
+	}
+	return live[i]
+}
+
+func orRoot(path string) string {
+	if path == "" {
+		return "."
+	}
+	return strings.TrimPrefix(path, ".")
+}
+
+// checkDrift runs drift detection according to driftPolicy and records
+// the result in the Drifted condition, an event and a metric. It reports
+// whether the release should be re-applied to correct the drift.
+func (r *HelmReleaseReconciler) checkDrift(ctx context.Context, release *helmv1alpha1.HelmRelease, helm HelmClientInterface, releaseName string) bool {
+	policy := driftPolicy(release)
+	if r.DriftCheckInterval <= 0 || policy == helmv1alpha1.DriftPolicyIgnore {
+		meta.RemoveStatusCondition(&release.Status.Conditions, conditionDrifted)
+		r.Metrics.clear(metricReleaseDriftedObject, release)
+		return false
+	}
+
+	drifted, err := helm.DetectDrift(ctx, releaseName, release.Spec.TargetNamespace)
+	if err != nil {
+		ctrl.LoggerFrom(ctx).Info("Skipping drift detection", "error", err.Error())
+		return false
+	}
+	r.Metrics.set(metricReleaseDriftedObject, release, float64(len(drifted)))
+
+	if len(drifted) == 0 {
+		setCondition(release, metav1.Condition{
+			Type:               conditionDrifted,
+			Status:             metav1.ConditionFalse,
+			Reason:             "NoDrift",
+			Message:            "Live objects match the release manifests",
+			ObservedGeneration: release.Generation,
+		})
+		return false
+	}
+
+	reported := drifted
+	if len(reported) > maxDriftReported {
+		reported = append(reported[:maxDriftReported:maxDriftReported], fmt.Sprintf("and %d more", len(drifted)-maxDriftReported))
+	}
+	message := strings.Join(reported, "; ")
+	wasDrifted := meta.IsStatusConditionTrue(release.Status.Conditions, conditionDrifted)
+	setCondition(release, metav1.Condition{
+		Type:               conditionDrifted,
+		Status:             metav1.ConditionTrue,
+		Reason:             "DriftDetected",
+		Message:            message,
+		ObservedGeneration: release.Generation,
+	})
+
+	correct := policy == helmv1alpha1.DriftPolicyCorrect
+	if !wasDrifted {
+		r.Alerts.drifted(release, message, correct)
+	}
+	switch {
+	case correct:
+		r.event(release, corev1.EventTypeWarning, "DriftCorrected", "Re-applying release to correct drift: "+message)
+	case !wasDrifted:
+		r.event(release, corev1.EventTypeWarning, "DriftDetected", message)
+	}
+	return correct
+}
+
+// driftPolicy returns how drift of release is handled: as set by
+// spec.driftDetection.mode, or else by spec.driftPolicy.
+func driftPolicy(release *helmv1alpha1.HelmRelease) helmv1alpha1.DriftPolicy {
+	if dd := release.Spec.DriftDetection; dd != nil && dd.Mode != "" {
+		switch dd.Mode {
+		case helmv1alpha1.DriftDetectionWarn:
+			return helmv1alpha1.DriftPolicyWarn
+		case helmv1alpha1.DriftDetectionCorrect:
+			return helmv1alpha1.DriftPolicyCorrect
+		}
+		return helmv1alpha1.DriftPolicyIgnore
+	}
+	if release.Spec.DriftPolicy == "" {
+		return helmv1alpha1.DriftPolicyIgnore
+	}
+	return release.Spec.DriftPolicy
+}
+
+// event records a Kubernetes event on release when a recorder is configured.
+func (r *HelmReleaseReconciler) event(release *helmv1alpha1.HelmRelease, eventType, reason, message string) {
+	if r.Recorder != nil {
+		r.Recorder.Event(release, eventType, reason, message)
+	}
+}
+
+// nextCheck returns the shorter of two periodic check intervals, ignoring
+// disabled (zero) ones.
+func nextCheck(a, b time.Duration) time.Duration {
+	if a == 0 || (b > 0 && b < a) {
+		return b
+	}
+	return a
+}
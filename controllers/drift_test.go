@@ -0,0 +1,176 @@
+package controllers_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"github.com/example/helm-operator/controllers"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// driftManifest is the deployed manifest of the release in the list
+// comparison test: a Deployment with one container and no volumes.
+const driftManifest = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: drift-lists
+  namespace: default
+spec:
+  selector:
+    matchLabels: {app: drift-lists}
+  template:
+    metadata:
+      labels: {app: drift-lists}
+    spec:
+      containers:
+      - name: app
+        image: nginx:1.25
+        args: [--port, "8080"]
+`
+
+var _ = Describe("Drift detection", func() {
+	ctx := context.Background()
+
+	withDriftChecks := func(r *controllers.HelmReleaseReconciler) {
+		r.DriftCheckInterval = 200 * time.Millisecond
+	}
+
+	It("only reports drift with driftPolicy Warn", func() {
+		mock := &MockHelmClient{ReleaseExistsResult: true, DriftResult: []string{"Deployment web/nginx: spec.replicas changed"}}
+		cancel := startManager(mock, withDriftChecks)
+		defer cancel()
+
+		hr := makeHR("test-drift-warn")
+		hr.Spec.DriftPolicy = helmv1alpha1.DriftPolicyWarn
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+		Eventually(func(g Gomega) {
+			fetched, err := getHR(ctx, hr.Name)
+			g.Expect(err).NotTo(HaveOccurred())
+			cond := findCondition(fetched, "Drifted")
+			g.Expect(cond).NotTo(BeNil())
+			g.Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+			g.Expect(cond.Message).To(ContainSubstring("spec.replicas"))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+
+		// The spec upgrade happened before drift was checked; no further upgrades follow.
+		mock.mu.Lock()
+		mock.UpgradeCalled = false
+		mock.mu.Unlock()
+		Consistently(func() bool {
+			mock.mu.Lock()
+			defer mock.mu.Unlock()
+			return mock.UpgradeCalled
+		}).WithTimeout(time.Second).WithPolling(polling).Should(BeFalse())
+	})
+
+	It("re-applies the release with driftPolicy Correct", func() {
+		mock := &MockHelmClient{ReleaseExistsResult: true, DriftResult: []string{"ConfigMap web/settings: missing"}}
+		cancel := startManager(mock, withDriftChecks)
+		defer cancel()
+
+		hr := makeHR("test-drift-correct")
+		hr.Spec.DriftPolicy = helmv1alpha1.DriftPolicyCorrect
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+		Eventually(func(g Gomega) {
+			fetched, err := getHR(ctx, hr.Name)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(findCondition(fetched, "Drifted")).NotTo(BeNil())
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+
+		mock.mu.Lock()
+		mock.UpgradeCalled = false
+		mock.mu.Unlock()
+		Eventually(func() bool {
+			mock.mu.Lock()
+			defer mock.mu.Unlock()
+			return mock.UpgradeCalled
+		}).WithTimeout(timeout).WithPolling(polling).Should(BeTrue())
+	})
+
+	It("lets spec.driftDetection.mode override driftPolicy", func() {
+		mock := &MockHelmClient{ReleaseExistsResult: true, DriftResult: []string{"Service web/nginx: spec.ports changed"}}
+		cancel := startManager(mock, withDriftChecks)
+		defer cancel()
+
+		hr := makeHR("test-drift-mode")
+		hr.Spec.DriftPolicy = helmv1alpha1.DriftPolicyIgnore
+		hr.Spec.DriftDetection = &helmv1alpha1.DriftDetectionSpec{Mode: helmv1alpha1.DriftDetectionWarn}
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+		Eventually(func(g Gomega) {
+			fetched, err := getHR(ctx, hr.Name)
+			g.Expect(err).NotTo(HaveOccurred())
+			cond := findCondition(fetched, "Drifted")
+			g.Expect(cond).NotTo(BeNil())
+			g.Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+			g.Expect(cond.Reason).To(Equal("DriftDetected"))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+	})
+
+	It("ignores list items added in the cluster but not changed items", func() {
+		clientset, err := kubernetes.NewForConfig(cfg)
+		Expect(err).NotTo(HaveOccurred())
+		releases := storage.Init(driver.NewSecrets(clientset.CoreV1().Secrets(testNS)))
+		Expect(releases.Create(&release.Release{
+			Name: "drift-lists", Namespace: testNS, Version: 1, Manifest: driftManifest,
+			Info:  &release.Info{Status: release.StatusDeployed},
+			Chart: &chart.Chart{Metadata: &chart.Metadata{Name: "web", Version: "1.0.0"}},
+		})).To(Succeed())
+		DeferCleanup(func() { releases.Delete("drift-lists", 1) })
+
+		// An admission webhook injected a sidecar ahead of the chart's
+		// container, and a volume.
+		labels := map[string]string{"app": "drift-lists"}
+		live := &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "drift-lists", Namespace: testNS},
+			Spec: appsv1.DeploymentSpec{
+				Selector: &metav1.LabelSelector{MatchLabels: labels},
+				Template: corev1.PodTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{Labels: labels},
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{
+							{Name: "proxy", Image: "envoy:1.30"},
+							{Name: "app", Image: "nginx:1.25", Args: []string{"--port", "8080"}},
+						},
+						Volumes: []corev1.Volume{{Name: "proxy-certs", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}}},
+					},
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, live)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, live) })
+
+		hc := controllers.NewHelmClient(cfg)
+		Expect(hc.DetectDrift(ctx, "drift-lists", testNS)).To(BeEmpty())
+
+		patch := client.MergeFrom(live.DeepCopy())
+		live.Spec.Template.Spec.Containers[1].Args = append(live.Spec.Template.Spec.Containers[1].Args, "--debug")
+		Expect(k8sClient.Patch(ctx, live, patch)).To(Succeed())
+		Expect(hc.DetectDrift(ctx, "drift-lists", testNS)).To(ConsistOf(
+			"Deployment default/drift-lists: spec.template.spec.containers[0].args changed"))
+
+		patch = client.MergeFrom(live.DeepCopy())
+		live.Spec.Template.Spec.Containers[1].Args = []string{"--port", "8080"}
+		live.Spec.Template.Spec.Containers[1].Image = "nginx:1.26"
+		Expect(k8sClient.Patch(ctx, live, patch)).To(Succeed())
+		Expect(hc.DetectDrift(ctx, "drift-lists", testNS)).To(ConsistOf(
+			"Deployment default/drift-lists: spec.template.spec.containers[0].image changed"))
+	})
+})
@@ -0,0 +1,201 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Values of --secrets-encryption. Auto detects whether Secrets are
+// encrypted at rest; the others state it for clusters where that cannot be
+// detected, such as those with a managed control plane.
+const (
+	SecretsEncryptionAuto        = "auto"
+	SecretsEncryptionEncrypted   = "encrypted"
+	SecretsEncryptionUnencrypted = "unencrypted"
+)
+
+// Statuses of SecretsEncryption.
+const (
+	EncryptionStatusEncrypted   = "Encrypted"
+	EncryptionStatusUnencrypted = "Unencrypted"
+	EncryptionStatusUnknown     = "Unknown"
+)
+
+// encryptionProviderFlag is the kube-apiserver flag that configures
+// encryption at rest, with or without a KMS provider.
+const encryptionProviderFlag = "--encryption-provider-config"
+
+// SecretsEncryption is the outcome of an encryption at rest check.
+type SecretsEncryption struct {
+	// Status is Encrypted, Unencrypted or Unknown.
+	Status string `json:"status"`
+	// Source is "flag" when --secrets-encryption states the status, or
+	// "kube-apiserver" when it was read from the API server's flags.
+	Source  string `json:"source,omitempty"`
+	Message string `json:"message"`
+	// SensitiveReleases lists, as namespace/name, the HelmReleases with
+	// values from Secrets. Helm copies those values into its release
+	// Secrets.
+	SensitiveReleases []string `json:"sensitiveReleases,omitempty"`
+	// Warning is set when sensitive values are, or may be, stored
+	// unencrypted.
+	Warning   bool      `json:"warning"`
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+// EncryptionMonitor is a controller-runtime Runnable that checks whether the
+// Secrets Helm stores releases in are encrypted at rest, and warns when
+// values read from Secrets end up in unencrypted ones. The Kubernetes API
+// does not expose the encryption configuration; it is read from the flags
+// of the kube-apiserver Pods where the control plane runs them, as kubeadm
+// and kind do.
+type EncryptionMonitor struct {
+	// Client should read from the API server; listing Pods through a
+	// cache would watch every Pod in the cluster.
+	Client   client.Reader
+	Interval time.Duration
+	// Assume is one of the SecretsEncryption constants. Defaults to auto.
+	Assume string
+
+	mu     sync.Mutex
+	status *SecretsEncryption
+}
+
+// Start implements manager.Runnable.
+func (m *EncryptionMonitor) Start(ctx context.Context) error {
+	ticker := time.NewTicker(m.Interval)
+	defer ticker.Stop()
+	for {
+		m.check(ctx)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Status returns the latest check, or nil before the first.
+func (m *EncryptionMonitor) Status() *SecretsEncryption {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.status == nil {
+		return nil
+	}
+	s := *m.status
+	return &s
+}
+
+func (m *EncryptionMonitor) check(ctx context.Context) {
+	log := ctrl.Log.WithName("secrets-encryption")
+	status, err := m.detect(ctx)
+	if err != nil {
+		// Keep the previous verdict rather than guess.
+		log.Error(err, "Checking Secrets encryption at rest")
+		return
+	}
+	status.CheckedAt = time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if status.Warning && (m.status == nil || !m.status.Warning || m.status.Status != status.Status) {
+		log.Info("Values read from Secrets are stored in Helm release Secrets that may not be encrypted at rest",
+			"status", status.Status, "message", status.Message, "releases", status.SensitiveReleases)
+	}
+	m.status = status
+}
+
+func (m *EncryptionMonitor) detect(ctx context.Context) (*SecretsEncryption, error) {
+	var status *SecretsEncryption
+	switch m.Assume {
+	case SecretsEncryptionEncrypted:
+		status = &SecretsEncryption{Status: EncryptionStatusEncrypted, Source: "flag",
+			Message: "--secrets-encryption=encrypted states that Secrets are encrypted at rest"}
+	case SecretsEncryptionUnencrypted:
+		status = &SecretsEncryption{Status: EncryptionStatusUnencrypted, Source: "flag",
+			Message: "--secrets-encryption=unencrypted states that Secrets are not encrypted at rest"}
+	case "", SecretsEncryptionAuto:
+		var err error
+		if status, err = m.detectAPIServer(ctx); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("invalid --secrets-encryption %q", m.Assume)
+	}
+
+	var list helmv1alpha1.HelmReleaseList
+	if err := m.Client.List(ctx, &list); err != nil {
+		return nil, fmt.Errorf("listing HelmReleases: %w", err)
+	}
+	for _, hr := range list.Items {
+		if readsSecrets(&hr) {
+			status.SensitiveReleases = append(status.SensitiveReleases, hr.Namespace+"/"+hr.Name)
+		}
+	}
+	sort.Strings(status.SensitiveReleases)
+	status.Warning = status.Status != EncryptionStatusEncrypted && len(status.SensitiveReleases) > 0
+	return status, nil
+}
+
+// readsSecrets reports whether hr takes values or substitutions from a
+// Secret, which Helm then stores in its release Secrets.
+func readsSecrets(hr *helmv1alpha1.HelmRelease) bool {
+	for _, ref := range hr.Spec.ValuesFrom {
+		if ref.Kind == "Secret" {
+			return true
+		}
+	}
+	for _, ref := range hr.Spec.SubstituteFrom {
+		if ref.Kind == "Secret" {
+			return true
+		}
+	}
+	return false
+}
+
+// detectAPIServer reads the encryption configuration from the flags of the
+// kube-apiserver Pods in kube-system.
+func (m *EncryptionMonitor) detectAPIServer(ctx context.Context) (*SecretsEncryption, error) {
+	var pods corev1.PodList
+	if err := m.Client.List(ctx, &pods, client.InNamespace(metav1.NamespaceSystem),
+		client.MatchingLabels{"component": "kube-apiserver"}); err != nil {
+		return nil, fmt.Errorf("listing kube-apiserver pods: %w", err)
+	}
+	if len(pods.Items) == 0 {
+		return &SecretsEncryption{Status: EncryptionStatusUnknown,
+			Message: "The kube-apiserver does not run as a Pod in kube-system, as with managed control planes; set --secrets-encryption to state whether Secrets are encrypted at rest"}, nil
+	}
+	for _, pod := range pods.Items {
+		if !setsFlag(&pod, encryptionProviderFlag) {
+			return &SecretsEncryption{Status: EncryptionStatusUnencrypted, Source: "kube-apiserver",
+				Message: fmt.Sprintf("Pod %s/%s runs the kube-apiserver without %s", pod.Namespace, pod.Name, encryptionProviderFlag)}, nil
+		}
+	}
+	return &SecretsEncryption{Status: EncryptionStatusEncrypted, Source: "kube-apiserver",
+		Message: fmt.Sprintf("The kube-apiserver runs with %s", encryptionProviderFlag)}, nil
+}
+
+// setsFlag reports whether a container of pod passes flag on its command line.
+func setsFlag(pod *corev1.Pod, flag string) bool {
+	for _, c := range pod.Spec.Containers {
+		for _, arg := range append(append([]string{}, c.Command...), c.Args...) {
+			if arg == flag || strings.HasPrefix(arg, flag+"=") {
+				return true
+			}
+		}
+	}
+	return false
+}
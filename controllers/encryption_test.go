@@ -0,0 +1,84 @@
+package controllers_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"github.com/example/helm-operator/controllers"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Secrets encryption check", func() {
+	// check runs an EncryptionMonitor over objs and returns its first result.
+	check := func(assume string, objs ...client.Object) *controllers.SecretsEncryption {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(helmv1alpha1.AddToScheme(scheme)).To(Succeed())
+		monitor := &controllers.EncryptionMonitor{
+			Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build(),
+			Interval: time.Hour,
+			Assume:   assume,
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		DeferCleanup(cancel)
+		go func() {
+			defer GinkgoRecover()
+			Expect(monitor.Start(ctx)).To(Succeed())
+		}()
+		Eventually(monitor.Status).WithTimeout(timeout).WithPolling(polling).ShouldNot(BeNil())
+		return monitor.Status()
+	}
+
+	apiServer := func(args ...string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "kube-apiserver-control-plane", Namespace: "kube-system",
+				Labels: map[string]string{"component": "kube-apiserver"}},
+			Spec: corev1.PodSpec{Containers: []corev1.Container{{
+				Name: "kube-apiserver", Command: append([]string{"kube-apiserver"}, args...),
+			}}},
+		}
+	}
+
+	withSecretValues := func() *helmv1alpha1.HelmRelease {
+		hr := makeHR("uses-secret")
+		hr.Spec.ValuesFrom = []helmv1alpha1.ValuesReference{{Kind: "Secret", Name: "db"}}
+		return hr
+	}
+
+	It("warns when the kube-apiserver does not encrypt Secrets", func() {
+		status := check(controllers.SecretsEncryptionAuto, apiServer("--secure-port=6443"), withSecretValues(), makeHR("plain"))
+		Expect(status.Status).To(Equal(controllers.EncryptionStatusUnencrypted))
+		Expect(status.Source).To(Equal("kube-apiserver"))
+		Expect(status.SensitiveReleases).To(Equal([]string{testNS + "/uses-secret"}))
+		Expect(status.Warning).To(BeTrue())
+	})
+
+	It("detects an encryption provider configuration", func() {
+		status := check(controllers.SecretsEncryptionAuto,
+			apiServer("--encryption-provider-config=/etc/kubernetes/enc.yaml"), withSecretValues())
+		Expect(status.Status).To(Equal(controllers.EncryptionStatusEncrypted))
+		Expect(status.Warning).To(BeFalse())
+	})
+
+	It("reports Unknown when the kube-apiserver is not visible", func() {
+		status := check(controllers.SecretsEncryptionAuto, withSecretValues())
+		Expect(status.Status).To(Equal(controllers.EncryptionStatusUnknown))
+		Expect(status.Warning).To(BeTrue())
+	})
+
+	It("trusts --secrets-encryption over detection", func() {
+		status := check(controllers.SecretsEncryptionEncrypted, apiServer(), withSecretValues())
+		Expect(status.Status).To(Equal(controllers.EncryptionStatusEncrypted))
+		Expect(status.Source).To(Equal("flag"))
+		Expect(status.Warning).To(BeFalse())
+	})
+})
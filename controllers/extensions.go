@@ -0,0 +1,73 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"github.com/example/helm-operator/extensions"
+	"helm.sh/helm/v3/pkg/postrender"
+)
+
+// helmOperation performs the actual Helm install or upgrade with the values
+// and post-renderer produced by the extension hooks.
+type helmOperation func(values map[string]interface{}, postRenderer postrender.PostRenderer) error
+
+// withExtensions runs op between the configured extension hooks: PreRender
+// rewrites the values, PostRender is handed to Helm as a post-renderer after
+// any spec.postRenderers, and PreApply/PostApply bracket the operation itself.
+func (r *HelmReleaseReconciler) withExtensions(ctx context.Context, release *helmv1alpha1.HelmRelease, releaseName string,
+	values map[string]interface{}, op helmOperation) error {
+	rel := extensionRelease(release, releaseName)
+
+	values, err := r.Extensions.PreRender(ctx, rel, values)
+	if err != nil {
+		return fmt.Errorf("pre-render extension: %w", err)
+	}
+
+	postRenderer, err := r.buildPostRenderer(ctx, release, rel)
+	if err != nil {
+		return err
+	}
+
+	if err := r.Extensions.PreApply(ctx, rel); err != nil {
+		return fmt.Errorf("pre-apply extension: %w", err)
+	}
+	if err := op(values, postRenderer); err != nil {
+		return err
+	}
+	if err := r.Extensions.PostApply(ctx, rel); err != nil {
+		return fmt.Errorf("post-apply extension: %w", err)
+	}
+	return nil
+}
+
+// extensionRelease describes the HelmRelease to extension hooks.
+func extensionRelease(release *helmv1alpha1.HelmRelease, releaseName string) extensions.Release {
+	return extensions.Release{
+		Name:            release.Name,
+		Namespace:       release.Namespace,
+		ReleaseName:     releaseName,
+		TargetNamespace: release.Spec.TargetNamespace,
+		Chart:           release.Spec.Chart,
+		RepoURL:         release.Spec.RepoURL,
+		Version:         release.Spec.Version,
+	}
+}
+
+// extensionPostRenderer adapts an extension's PostRender hook to Helm's
+// postrender.PostRenderer interface.
+type extensionPostRenderer struct {
+	ctx context.Context
+	ext extensions.Extension
+	rel extensions.Release
+}
+
+func (p *extensionPostRenderer) Run(rendered *bytes.Buffer) (*bytes.Buffer, error) {
+	out, err := p.ext.PostRender(p.ctx, p.rel, rendered.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("post-render extension: %w", err)
+	}
+	return bytes.NewBuffer(out), nil
+}
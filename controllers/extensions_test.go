@@ -0,0 +1,121 @@
+package controllers_test
+
+import (
+	"context"
+	"sync"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"github.com/example/helm-operator/controllers"
+	"github.com/example/helm-operator/extensions"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// valuesExtension injects a value before rendering and records PostApply.
+type valuesExtension struct {
+	extensions.Base
+
+	mu      sync.Mutex
+	applied bool
+}
+
+func (e *valuesExtension) PreRender(_ context.Context, _ extensions.Release, values map[string]interface{}) (map[string]interface{}, error) {
+	values["injected"] = true
+	return values, nil
+}
+
+func (e *valuesExtension) PostApply(context.Context, extensions.Release) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.applied = true
+	return nil
+}
+
+var _ = Describe("Extensions", func() {
+	ctx := context.Background()
+
+	It("runs hooks around install and hands Helm a post-renderer", func() {
+		mock := &MockHelmClient{}
+		ext := &valuesExtension{}
+		cancel := startManager(mock, func(r *controllers.HelmReleaseReconciler) {
+			r.Extensions = extensions.Chain{ext}
+		})
+		defer cancel()
+
+		hr := makeHR("test-extensions")
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+		Eventually(func(g Gomega) {
+			mock.mu.Lock()
+			args := mock.InstallArgs
+			mock.mu.Unlock()
+			g.Expect(args.Values).To(HaveKeyWithValue("injected", true))
+			g.Expect(args.Opts.PostRenderer).NotTo(BeNil())
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+
+		Eventually(func(g Gomega) {
+			ext.mu.Lock()
+			applied := ext.applied
+			ext.mu.Unlock()
+			g.Expect(applied).To(BeTrue())
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+	})
+	Describe("spec.postRenderers", func() {
+		It("passes allowed exec post-renderers to Helm", func() {
+			mock := &MockHelmClient{}
+			cancel := startManager(mock, func(r *controllers.HelmReleaseReconciler) {
+				r.AllowedPostRenderers = []string{"cat"}
+			})
+			defer cancel()
+
+			hr := makeHR("test-postrender-allowed")
+			hr.Spec.PostRenderers = []helmv1alpha1.PostRenderer{{Exec: &helmv1alpha1.ExecPostRenderer{Command: "cat"}}}
+			Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+			DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+			Eventually(func(g Gomega) {
+				mock.mu.Lock()
+				args := mock.InstallArgs
+				mock.mu.Unlock()
+				g.Expect(args.Opts.PostRenderer).NotTo(BeNil())
+			}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+		})
+
+		It("fails the release when the command is not allowed", func() {
+			mock := &MockHelmClient{}
+			cancel := startManager(mock)
+			defer cancel()
+
+			hr := makeHR("test-postrender-denied")
+			hr.Spec.PostRenderers = []helmv1alpha1.PostRenderer{{Exec: &helmv1alpha1.ExecPostRenderer{Command: "kustomize"}}}
+			Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+			DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+			Eventually(func(g Gomega) {
+				fetched, err := getHR(ctx, hr.Name)
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(fetched.Status.Phase).To(Equal(helmv1alpha1.PhaseFailed))
+				cond := findCondition(fetched, "Ready")
+				g.Expect(cond).NotTo(BeNil())
+				g.Expect(cond.Message).To(ContainSubstring("not allowed"))
+			}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+
+			mock.mu.Lock()
+			defer mock.mu.Unlock()
+			Expect(mock.InstallCalled).To(BeFalse())
+		})
+	})
+})
+
+// findCondition returns the condition of the given type, or nil.
+func findCondition(hr *helmv1alpha1.HelmRelease, condType string) *metav1.Condition {
+	for i := range hr.Status.Conditions {
+		if hr.Status.Conditions[i].Type == condType {
+			return &hr.Status.Conditions[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// serverErrorStatus matches the HTTP 5xx and 429 statuses chart repositories
+// report in Helm's download errors, which carry them only as text.
+var serverErrorStatus = regexp.MustCompile(`\b(5[0-9][0-9]|429) [A-Z]`)
+
+// tolerateFailure reports whether a failure of a Ready release should leave
+// it Ready: the error is transient and the release has failed fewer than
+// FailureThreshold times in a row. A repository blip during a periodic
+// resync then emits a warning event instead of flipping Ready. A failure of
+// a new generation, or one that retrying will not fix, is never tolerated.
+func (r *HelmReleaseReconciler) tolerateFailure(release *helmv1alpha1.HelmRelease, err error) bool {
+	if release.Status.ConsecutiveFailures >= r.FailureThreshold || !isTransient(err) {
+		return false
+	}
+	ready := meta.FindStatusCondition(release.Status.Conditions, "Ready")
+	if ready == nil || ready.Status != metav1.ConditionTrue || ready.ObservedGeneration != release.Generation {
+		return false
+	}
+	r.event(release, corev1.EventTypeWarning, "TransientFailure",
+		fmt.Sprintf("Reconcile failed (%d of %d tolerated): %v", release.Status.ConsecutiveFailures, r.FailureThreshold-1, err))
+	return true
+}
+
+// isTransient reports whether err is likely to go away on retry: network
+// errors, timeouts and server-side errors of the API server or a chart
+// repository. Failing verification probes and immutable field changes are
+// not, whatever their cause.
+func isTransient(err error) bool {
+	var netErr net.Error
+	var probeErr *ProbeError
+	var immutableErr *ImmutableFieldError
+	switch {
+	case errors.As(err, &probeErr), errors.As(err, &immutableErr):
+		return false
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr):
+		return true
+	case apierrors.IsServerTimeout(err), apierrors.IsTimeout(err), apierrors.IsTooManyRequests(err),
+		apierrors.IsServiceUnavailable(err), apierrors.IsInternalError(err), apierrors.IsConflict(err):
+		return true
+	}
+	return serverErrorStatus.MatchString(err.Error())
+}
@@ -0,0 +1,78 @@
+package controllers_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/example/helm-operator/controllers"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Failure threshold", func() {
+	ctx := context.Background()
+
+	// readyThenFailing installs a release, then makes every further
+	// reconcile fail with err.
+	readyThenFailing := func(name string, err error) *MockHelmClient {
+		mock := &MockHelmClient{}
+		cancel := startManager(mock, func(r *controllers.HelmReleaseReconciler) {
+			r.FailureThreshold = 3
+		})
+		DeferCleanup(cancel)
+
+		hr := makeHR(name)
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+		Eventually(func(g Gomega) {
+			fetched, err := getHR(ctx, name)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(findCondition(fetched, "Ready")).To(HaveField("Status", metav1.ConditionTrue))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+
+		mock.mu.Lock()
+		mock.ReleaseExistsErr = err
+		mock.mu.Unlock()
+		return mock
+	}
+
+	// resync triggers a reconcile and waits until it has failed n times in a
+	// row, returning the Ready condition.
+	resync := func(name string, n int) *metav1.Condition {
+		fetched, err := getHR(ctx, name)
+		Expect(err).NotTo(HaveOccurred())
+		patch := client.MergeFrom(fetched.DeepCopy())
+		metav1.SetMetaDataAnnotation(&fetched.ObjectMeta, "test/resync", fmt.Sprint(n))
+		Expect(k8sClient.Patch(ctx, fetched, patch)).To(Succeed())
+
+		var ready *metav1.Condition
+		Eventually(func(g Gomega) {
+			fetched, err := getHR(ctx, name)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(fetched.Status.ConsecutiveFailures).To(Equal(n))
+			ready = findCondition(fetched, "Ready")
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+		return ready
+	}
+
+	It("keeps a Ready release Ready through transient failures below the threshold", func() {
+		readyThenFailing("test-failure-transient", apierrors.NewServiceUnavailable("etcdserver: leader changed"))
+
+		Expect(resync("test-failure-transient", 1).Status).To(Equal(metav1.ConditionTrue))
+		Expect(resync("test-failure-transient", 2).Status).To(Equal(metav1.ConditionTrue))
+		ready := resync("test-failure-transient", 3)
+		Expect(ready.Status).To(Equal(metav1.ConditionFalse))
+		Expect(ready.Message).To(ContainSubstring("leader changed"))
+	})
+
+	It("degrades on the first failure that is not transient", func() {
+		readyThenFailing("test-failure-permanent", errors.New("chart requires kubeVersion >=1.30.0"))
+
+		Expect(resync("test-failure-permanent", 1).Status).To(Equal(metav1.ConditionFalse))
+	})
+})
@@ -0,0 +1,209 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	helmtime "helm.sh/helm/v3/pkg/time"
+)
+
+// fakeHistory is the number of superseded revisions FakeHelmClient keeps
+// per release.
+const fakeHistory = 10
+
+// FakeHelmClient is an in-memory HelmClientInterface that records releases
+// without contacting a repository or the cluster. It backs the operator's
+// --helm-backend=fake mode, used with cmd/loadgen to measure reconcile
+// throughput independently of Helm.
+type FakeHelmClient struct {
+	// Latency is added to every Install, Upgrade and Uninstall to simulate
+	// chart download and apply time.
+	Latency time.Duration
+
+	mu       sync.Mutex
+	releases map[string]*release.Release
+	// superseded holds the earlier revisions of each release, newest first.
+	superseded map[string][]*release.Release
+}
+
+var _ HelmClientInterface = (*FakeHelmClient)(nil) // compile-time interface check
+
+func (f *FakeHelmClient) Install(ctx context.Context, releaseName, chartName, _, version, namespace string,
+	values map[string]interface{}, opts InstallOptions) (*DeployedChart, error) {
+	return f.store(ctx, releaseName, chartName, version, namespace, values, opts.Description)
+}
+
+func (f *FakeHelmClient) Upgrade(ctx context.Context, releaseName, chartName, _, version, namespace string,
+	values map[string]interface{}, opts UpgradeOptions) (*DeployedChart, error) {
+	return f.store(ctx, releaseName, chartName, version, namespace, values, opts.Description)
+}
+
+func (f *FakeHelmClient) Uninstall(ctx context.Context, releaseName, namespace string, _ UninstallOptions) error {
+	if err := f.sleep(ctx); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.releases, namespace+"/"+releaseName)
+	delete(f.superseded, namespace+"/"+releaseName)
+	return nil
+}
+
+func (f *FakeHelmClient) ReleaseExists(releaseName, namespace string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, ok := f.releases[namespace+"/"+releaseName]
+	return ok, nil
+}
+
+func (f *FakeHelmClient) GetRelease(releaseName, namespace string) (*release.Release, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	rel, ok := f.releases[namespace+"/"+releaseName]
+	if !ok {
+		return nil, driver.ErrReleaseNotFound
+	}
+	return rel, nil
+}
+
+// Lint reports no findings.
+func (f *FakeHelmClient) Lint(context.Context, string, string, string, map[string]interface{}, ChartFetchOptions) ([]string, error) {
+	return nil, nil
+}
+
+// LatestVersions reports no charts, so releases never show an update.
+func (f *FakeHelmClient) LatestVersions(context.Context, string, ChartFetchOptions) (map[string]string, error) {
+	return map[string]string{}, nil
+}
+
+// DetectDrift reports no drift; the fake backend creates no objects.
+func (f *FakeHelmClient) DetectDrift(context.Context, string, string) ([]string, error) {
+	return nil, nil
+}
+
+// ResourceKinds reports no kinds; the fake backend renders no charts.
+func (f *FakeHelmClient) ResourceKinds(context.Context, string, string, string, string, map[string]interface{}, ChartFetchOptions) ([]ResourceKind, error) {
+	return nil, nil
+}
+
+// Impact reports the ConfigMap of the chart LoadChart returns: created when
+// the release is not installed, modified when the version or values differ
+// from the stored release.
+func (f *FakeHelmClient) Impact(_ context.Context, releaseName, _, _, version, namespace string,
+	values map[string]interface{}, _ ChartFetchOptions) (*ImpactSummary, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	summary := &ImpactSummary{Objects: []ObjectImpact{}}
+	obj := ObjectImpact{APIVersion: "v1", Kind: "ConfigMap", Namespace: namespace, Name: releaseName}
+	rel, ok := f.releases[namespace+"/"+releaseName]
+	switch {
+	case !ok:
+		obj.Change = ChangeCreate
+	case rel.Chart.Metadata.Version != version || (len(rel.Config) > 0 || len(values) > 0) && !reflect.DeepEqual(rel.Config, values):
+		obj.Change, obj.Field = ChangeModify, "data"
+	default:
+		summary.Unchanged++
+		return summary, nil
+	}
+	summary.add(obj)
+	return summary, nil
+}
+
+// LoadChart returns a minimal chart with the requested name and version; the
+// fake backend downloads nothing.
+func (f *FakeHelmClient) LoadChart(_ context.Context, chartName, _, version string, _ ChartFetchOptions) (*chart.Chart, error) {
+	metadata := &chart.Metadata{APIVersion: chart.APIVersionV2, Name: chartName, Version: version, Type: "application"}
+	chartYAML := fmt.Sprintf("apiVersion: v2\nname: %s\nversion: %s\ntype: application\n", chartName, version)
+	valuesYAML := "replicaCount: 1\n"
+	template := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: {{ .Release.Name }}\n"
+	return &chart.Chart{
+		Metadata:  metadata,
+		Templates: []*chart.File{{Name: "templates/configmap.yaml", Data: []byte(template)}},
+		Values:    map[string]interface{}{"replicaCount": 1},
+		Raw: []*chart.File{
+			{Name: "Chart.yaml", Data: []byte(chartYAML)},
+			{Name: "values.yaml", Data: []byte(valuesYAML)},
+			{Name: "templates/configmap.yaml", Data: []byte(template)},
+		},
+	}, nil
+}
+
+// History returns the current revision and up to fakeHistory earlier ones,
+// newest first.
+func (f *FakeHelmClient) History(_ context.Context, releaseName, namespace string) ([]*release.Release, error) {
+	rel, err := f.GetRelease(releaseName, namespace)
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]*release.Release{rel}, f.superseded[namespace+"/"+releaseName]...), nil
+}
+
+// ListReleases returns every release the fake holds.
+func (f *FakeHelmClient) ListReleases(context.Context) ([]*release.Release, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	releases := make([]*release.Release, 0, len(f.releases))
+	for _, rel := range f.releases {
+		releases = append(releases, rel)
+	}
+	return releases, nil
+}
+
+func (f *FakeHelmClient) store(ctx context.Context, releaseName, chartName, version, namespace string,
+	values map[string]interface{}, description string) (*DeployedChart, error) {
+	if err := f.sleep(ctx); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.releases == nil {
+		f.releases = map[string]*release.Release{}
+	}
+	key := namespace + "/" + releaseName
+	revision := 1
+	if prev, ok := f.releases[key]; ok {
+		revision = prev.Version + 1
+		old := *prev
+		info := *prev.Info
+		info.Status = release.StatusSuperseded
+		old.Info = &info
+		if f.superseded == nil {
+			f.superseded = map[string][]*release.Release{}
+		}
+		f.superseded[key] = append([]*release.Release{&old}, f.superseded[key]...)
+		if len(f.superseded[key]) > fakeHistory {
+			f.superseded[key] = f.superseded[key][:fakeHistory]
+		}
+	}
+	f.releases[key] = &release.Release{
+		Name:      releaseName,
+		Namespace: namespace,
+		Version:   revision,
+		Config:    values,
+		Chart:     &chart.Chart{Metadata: &chart.Metadata{Name: chartName, Version: version}},
+		Info:      &release.Info{Status: release.StatusDeployed, Description: description, LastDeployed: helmtime.Now()},
+		Manifest: fmt.Sprintf("---\n# Source: %s/templates/configmap.yaml\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: %s\n  namespace: %s\n",
+			chartName, releaseName, namespace),
+	}
+	return &DeployedChart{Version: version}, nil
+}
+
+func (f *FakeHelmClient) sleep(ctx context.Context) error {
+	if f.Latency <= 0 {
+		return nil
+	}
+	select {
+	case <-time.After(f.Latency):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -2,11 +2,14 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"helm.sh/helm/v3/pkg/action"
-	"helm.sh/helm/v3/pkg/chart/loader"
-	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/postrender"
+	"helm.sh/helm/v3/pkg/release"
 	"helm.sh/helm/v3/pkg/storage/driver"
 	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/client-go/discovery"
@@ -20,18 +23,145 @@ import (
 // HelmClientInterface abstracts Helm operations so the reconciler can be tested
 // with a mock without requiring a real Helm/Kubernetes cluster.
 type HelmClientInterface interface {
-	Install(ctx context.Context, releaseName, chartName, repoURL, version, namespace string, values map[string]interface{}) error
-	Upgrade(ctx context.Context, releaseName, chartName, repoURL, version, namespace string, values map[string]interface{}) error
-	Uninstall(ctx context.Context, releaseName, namespace string) error
+	Install(ctx context.Context, releaseName, chartName, repoURL, version, namespace string, values map[string]interface{}, opts InstallOptions) (*DeployedChart, error)
+	Upgrade(ctx context.Context, releaseName, chartName, repoURL, version, namespace string, values map[string]interface{}, opts UpgradeOptions) (*DeployedChart, error)
+	Uninstall(ctx context.Context, releaseName, namespace string, opts UninstallOptions) error
 	ReleaseExists(releaseName, namespace string) (bool, error)
+	GetRelease(releaseName, namespace string) (*release.Release, error)
+	Lint(ctx context.Context, chartName, repoURL, version string, values map[string]interface{}, opts ChartFetchOptions) ([]string, error)
+	LatestVersions(ctx context.Context, repoURL string, opts ChartFetchOptions) (map[string]string, error)
+	DetectDrift(ctx context.Context, releaseName, namespace string) ([]string, error)
+	ResourceKinds(ctx context.Context, chartName, repoURL, version, namespace string, values map[string]interface{}, opts ChartFetchOptions) ([]ResourceKind, error)
+	Impact(ctx context.Context, releaseName, chartName, repoURL, version, namespace string, values map[string]interface{}, opts ChartFetchOptions) (*ImpactSummary, error)
+	History(ctx context.Context, releaseName, namespace string) ([]*release.Release, error)
+	ListReleases(ctx context.Context) ([]*release.Release, error)
+	LoadChart(ctx context.Context, chartName, repoURL, version string, opts ChartFetchOptions) (*chart.Chart, error)
 }
 
 var _ HelmClientInterface = (*HelmClient)(nil) // compile-time interface check
 
+// InstallOptions holds optional settings for HelmClient.Install.
+type InstallOptions struct {
+	// PostRenderer, when set, transforms the rendered manifests before they are applied.
+	PostRenderer postrender.PostRenderer
+
+	// Fetch controls how the chart is downloaded.
+	Fetch ChartFetchOptions
+
+	// Description is stored on the Helm revision.
+	Description string
+
+	// Wait controls waiting for resources and the hook timeout.
+	Wait WaitOptions
+
+	// Atomic uninstalls the release if the install fails. Implies waiting.
+	Atomic bool
+
+	// SkipSchemaValidation skips validating values against the chart's
+	// values.schema.json.
+	SkipSchemaValidation bool
+	// DisableOpenAPIValidation skips validating the rendered manifests
+	// against the Kubernetes OpenAPI schema.
+	DisableOpenAPIValidation bool
+
+	// SkipCRDs skips installing the chart's crds/ directory.
+	SkipCRDs bool
+	// DisableHooks skips the chart's hooks.
+	DisableHooks bool
+	// CreateNamespace creates the target namespace if it does not exist.
+	CreateNamespace bool
+
+	// Impersonate is the user Helm acts as, such as a ServiceAccount's
+	// "system:serviceaccount:<namespace>:<name>". Empty uses the operator's
+	// own identity.
+	Impersonate string
+}
+
+// UpgradeOptions holds optional settings for HelmClient.Upgrade.
+type UpgradeOptions struct {
+	// PostRenderer, when set, transforms the rendered manifests before they are applied.
+	PostRenderer postrender.PostRenderer
+
+	// Fetch controls how the chart is downloaded.
+	Fetch ChartFetchOptions
+
+	// Description is stored on the Helm revision.
+	Description string
+
+	// Wait controls waiting for resources and the hook timeout.
+	Wait WaitOptions
+
+	// MaxHistory, when set, overrides HelmClient.MaxHistory.
+	MaxHistory *int
+
+	// Force deletes and recreates resources that cannot be patched.
+	Force bool
+	// Recreate lists objects to delete before upgrading, so the upgrade
+	// creates them again. Used for objects whose immutable fields change,
+	// which Force alone cannot update.
+	Recreate []ObjectImpact
+	// CleanupOnFail deletes the resources a failed upgrade created.
+	CleanupOnFail bool
+	// ResetValues drops the previous revision's values; ReuseValues merges
+	// the new values over them.
+	ResetValues bool
+	ReuseValues bool
+
+	// SkipSchemaValidation skips validating values against the chart's
+	// values.schema.json.
+	SkipSchemaValidation bool
+	// DisableOpenAPIValidation skips validating the rendered manifests
+	// against the Kubernetes OpenAPI schema.
+	DisableOpenAPIValidation bool
+
+	// DisableHooks skips the chart's hooks.
+	DisableHooks bool
+
+	// Impersonate is the user Helm acts as; see InstallOptions.
+	Impersonate string
+}
+
+// UninstallOptions holds optional settings for HelmClient.Uninstall.
+type UninstallOptions struct {
+	// KeepHistory keeps the release's revisions, marked as uninstalled.
+	KeepHistory bool
+	// DisableHooks skips the chart's delete hooks.
+	DisableHooks bool
+	// Wait waits until the release's resources are deleted.
+	Wait bool
+	// Timeout bounds each hook and the wait; 0 means no limit.
+	Timeout time.Duration
+	// Impersonate is the user Helm acts as; see InstallOptions.
+	Impersonate string
+}
+
+// DeployedChart identifies the chart artifact an install or upgrade
+// deployed, which a version constraint or a re-pushed tag can change.
+type DeployedChart struct {
+	// Version is the chart version the version constraint resolved to.
+	Version string
+	// AppVersion is the appVersion from the chart's Chart.yaml.
+	AppVersion string
+	// Digest is the sha256 digest of the chart, in the form spec.chartDigest
+	// accepts.
+	Digest string
+}
+
 // HelmClient wraps helm.sh/helm/v3/pkg/action to provide install, upgrade,
 // uninstall, and release-existence checks against a Kubernetes cluster.
 type HelmClient struct {
 	restConfig *rest.Config
+
+	// Proxy configures the proxy used for chart downloads.
+	Proxy ProxyConfig
+	// Download configures timeouts, retries and the User-Agent of chart downloads.
+	Download DownloadConfig
+	// Indexes, when set, caches repository indexes between version
+	// resolutions and update checks.
+	Indexes *IndexCache
+	// MaxHistory limits the revisions kept per release; upgrades delete the
+	// oldest revision Secrets beyond it. 0 keeps all.
+	MaxHistory int
 }
 
 // NewHelmClient creates a HelmClient from the given REST config.
@@ -39,6 +169,14 @@ func NewHelmClient(cfg *rest.Config) *HelmClient {
 	return &HelmClient{restConfig: cfg}
 }
 
+// ForCluster returns a copy of c that deploys to the cluster of cfg, with
+// the same download settings and index cache.
+func (c *HelmClient) ForCluster(cfg *rest.Config) *HelmClient {
+	out := *c
+	out.restConfig = cfg
+	return &out
+}
+
 // restClientGetter implements genericclioptions.RESTClientGetter so that the
 // Helm action configuration can discover the cluster topology.
 type restClientGetter struct {
@@ -81,7 +219,19 @@ func (r *restClientGetter) ToRawKubeConfigLoader() clientcmd.ClientConfig {
 
 // actionConfig builds a Helm action.Configuration scoped to the given namespace.
 func (h *HelmClient) actionConfig(namespace string) (*action.Configuration, error) {
-	getter := &restClientGetter{restConfig: h.restConfig, namespace: namespace}
+	return h.actionConfigAs(namespace, "")
+}
+
+// actionConfigAs is actionConfig acting as user, or as the operator when
+// user is empty. Helm then reads and writes both the release storage and
+// the chart's objects with user's permissions.
+func (h *HelmClient) actionConfigAs(namespace, user string) (*action.Configuration, error) {
+	restConfig := h.restConfig
+	if user != "" {
+		restConfig = rest.CopyConfig(h.restConfig)
+		restConfig.Impersonate = rest.ImpersonationConfig{UserName: user}
+	}
+	getter := &restClientGetter{restConfig: restConfig, namespace: namespace}
 	cfg := new(action.Configuration)
 	if err := cfg.Init(getter, namespace, "secret", func(format string, v ...interface{}) {}); err != nil {
 		return nil, fmt.Errorf("initialising helm action config: %w", err)
@@ -90,83 +240,137 @@ func (h *HelmClient) actionConfig(namespace string) (*action.Configuration, erro
 }
 
 // Install performs a helm install for the given parameters.
-func (h *HelmClient) Install(ctx context.Context, releaseName, chartName, repoURL, version, namespace string, values map[string]interface{}) error {
-	cfg, err := h.actionConfig(namespace)
+func (h *HelmClient) Install(ctx context.Context, releaseName, chartName, repoURL, version, namespace string, values map[string]interface{}, opts InstallOptions) (*DeployedChart, error) {
+	cfg, err := h.actionConfigAs(namespace, opts.Impersonate)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	client := action.NewInstall(cfg)
 	client.ReleaseName = releaseName
 	client.Namespace = namespace
 	client.Version = version
-	client.ChartPathOptions.RepoURL = repoURL
+	client.PostRenderer = opts.PostRenderer
+	client.Description = opts.Description
+	client.Wait, client.WaitForJobs, client.Timeout = applyWait(cfg, opts.Wait)
+	client.Atomic = opts.Atomic
+	// ReleaseExists reports a release uninstalled with KeepHistory as gone;
+	// Replace lets the install reuse its name.
+	client.Replace = true
+	client.DisableOpenAPIValidation = opts.DisableOpenAPIValidation
+	client.SkipCRDs = opts.SkipCRDs
+	client.CreateNamespace = opts.CreateNamespace
+	client.DisableHooks = opts.DisableHooks
 
-	settings := cli.New()
-	chartPath, err := client.ChartPathOptions.LocateChart(chartName, settings)
+	chart, digest, err := h.loadChart(ctx, chartName, repoURL, version, opts.Fetch)
 	if err != nil {
-		return fmt.Errorf("locating chart: %w", err)
+		return nil, err
 	}
-	chart, err := loader.Load(chartPath)
-	if err != nil {
-		return fmt.Errorf("loading chart: %w", err)
+	if opts.SkipSchemaValidation {
+		dropSchemas(chart)
 	}
 
-	_, err = client.RunWithContext(ctx, chart, values)
-	return err
+	if _, err := client.RunWithContext(ctx, chart, values); err != nil {
+		return nil, err
+	}
+	return deployedChart(chart, digest), nil
 }
 
 // Upgrade performs a helm upgrade for the given parameters.
-func (h *HelmClient) Upgrade(ctx context.Context, releaseName, chartName, repoURL, version, namespace string, values map[string]interface{}) error {
-	cfg, err := h.actionConfig(namespace)
+func (h *HelmClient) Upgrade(ctx context.Context, releaseName, chartName, repoURL, version, namespace string, values map[string]interface{}, opts UpgradeOptions) (*DeployedChart, error) {
+	cfg, err := h.actionConfigAs(namespace, opts.Impersonate)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	client := action.NewUpgrade(cfg)
+	client.MaxHistory = h.MaxHistory
+	if opts.MaxHistory != nil {
+		client.MaxHistory = *opts.MaxHistory
+	}
 	client.Namespace = namespace
 	client.Version = version
-	client.ChartPathOptions.RepoURL = repoURL
+	client.PostRenderer = opts.PostRenderer
+	client.Description = opts.Description
+	client.Wait, client.WaitForJobs, client.Timeout = applyWait(cfg, opts.Wait)
+	client.DisableOpenAPIValidation = opts.DisableOpenAPIValidation
+	client.DisableHooks = opts.DisableHooks
+	client.Force = opts.Force
+	client.CleanupOnFail = opts.CleanupOnFail
+	client.ResetValues = opts.ResetValues
+	client.ReuseValues = opts.ReuseValues
 
-	settings := cli.New()
-	chartPath, err := client.ChartPathOptions.LocateChart(chartName, settings)
+	chart, digest, err := h.loadChart(ctx, chartName, repoURL, version, opts.Fetch)
 	if err != nil {
-		return fmt.Errorf("locating chart: %w", err)
+		return nil, err
 	}
-	chart, err := loader.Load(chartPath)
-	if err != nil {
-		return fmt.Errorf("loading chart: %w", err)
+	if opts.SkipSchemaValidation {
+		dropSchemas(chart)
+	}
+	if err := deleteObjects(cfg, opts.Recreate); err != nil {
+		return nil, fmt.Errorf("deleting objects to recreate: %w", err)
 	}
 
-	_, err = client.RunWithContext(ctx, releaseName, chart, values)
-	return err
+	if _, err := client.RunWithContext(ctx, releaseName, chart, values); err != nil {
+		return nil, err
+	}
+	return deployedChart(chart, digest), nil
 }
 
 // Uninstall removes the Helm release from the given namespace.
-func (h *HelmClient) Uninstall(_ context.Context, releaseName, namespace string) error {
-	cfg, err := h.actionConfig(namespace)
+func (h *HelmClient) Uninstall(_ context.Context, releaseName, namespace string, opts UninstallOptions) error {
+	cfg, err := h.actionConfigAs(namespace, opts.Impersonate)
 	if err != nil {
 		return err
 	}
 	client := action.NewUninstall(cfg)
+	client.KeepHistory = opts.KeepHistory
+	client.DisableHooks = opts.DisableHooks
+	client.Wait = opts.Wait
+	client.Timeout = opts.Timeout
 	_, err = client.Run(releaseName)
 	return err
 }
 
-// ReleaseExists returns true if a Helm release with the given name exists in the namespace.
+// ReleaseExists returns true if a Helm release with the given name exists in
+// the namespace. A release uninstalled with its history kept does not exist.
 func (h *HelmClient) ReleaseExists(releaseName, namespace string) (bool, error) {
 	cfg, err := h.actionConfig(namespace)
 	if err != nil {
 		return false, err
 	}
-	histClient := action.NewHistory(cfg)
-	histClient.Max = 1
-	_, err = histClient.Run(releaseName)
-	if err == driver.ErrReleaseNotFound {
+	last, err := cfg.Releases.Last(releaseName)
+	if errors.Is(err, driver.ErrReleaseNotFound) {
 		return false, nil
 	}
 	if err != nil {
 		return false, err
 	}
-	return true, nil
+	return last.Info.Status != release.StatusUninstalled, nil
+}
+
+// GetRelease returns the latest revision of the named Helm release.
+func (h *HelmClient) GetRelease(releaseName, namespace string) (*release.Release, error) {
+	cfg, err := h.actionConfig(namespace)
+	if err != nil {
+		return nil, err
+	}
+	return action.NewGet(cfg).Run(releaseName)
+}
+
+// ListReleases returns the latest revision of every release in the cluster
+// that is not uninstalled, whoever installed it.
+func (h *HelmClient) ListReleases(_ context.Context) ([]*release.Release, error) {
+	cfg, err := h.actionConfig("")
+	if err != nil {
+		return nil, err
+	}
+	list := action.NewList(cfg)
+	list.AllNamespaces = true
+	list.StateMask = action.ListAll &^ (action.ListUninstalled | action.ListUninstalling | action.ListSuperseded)
+	releases, err := list.Run()
+	if err != nil {
+		return nil, fmt.Errorf("listing releases: %w", err)
+	}
+	return releases, nil
 }
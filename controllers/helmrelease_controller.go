@@ -2,15 +2,30 @@ package controllers
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"time"
 
 	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"github.com/example/helm-operator/extensions"
+	"github.com/example/helm-operator/ownership"
+	"github.com/example/helm-operator/wasm"
+	"helm.sh/helm/v3/pkg/postrender"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
@@ -24,13 +39,124 @@ const (
 // +kubebuilder:rbac:groups=helm.example.com,resources=helmreleases,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=helm.example.com,resources=helmreleases/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=helm.example.com,resources=helmreleases/finalizers,verbs=update
-// +kubebuilder:rbac:groups="",resources=pods;services;configmaps;secrets;serviceaccounts,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups=coordination.k8s.io,resources=leases,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=pods;services;configmaps;secrets;serviceaccounts;namespaces,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=apps,resources=deployments;statefulsets;daemonsets,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=roles;rolebindings;clusterroles;clusterrolebindings,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=roles,verbs=escalate;bind
+// +kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch
+// +kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions,verbs=get;create;patch
+// +kubebuilder:rbac:groups=authorization.k8s.io,resources=subjectaccessreviews,verbs=create
+// +kubebuilder:rbac:groups="",resources=serviceaccounts,verbs=impersonate
 type HelmReleaseReconciler struct {
 	client.Client
 	Scheme     *runtime.Scheme
 	HelmClient HelmClientInterface
+
+	// Extensions are run around every install and upgrade. May be empty.
+	Extensions extensions.Chain
+
+	// AllowedPostRenderers lists the commands spec.postRenderers[].exec may run.
+	AllowedPostRenderers []string
+
+	// WasmRuntime runs spec.wasmModules and GlobalWasmModules. May be nil if
+	// neither is used.
+	WasmRuntime       wasm.Runtime
+	GlobalWasmModules []GlobalWasmModule
+
+	// Breakers stops install/upgrade attempts against chart repositories that
+	// are failing repeatedly. Nil disables the circuit breaker.
+	Breakers *RepositoryBreakers
+
+	// Rewrites are applied to spec.repoURL and to image references in values.
+	Rewrites RewriteRules
+
+	// MaxConcurrentReconciles defaults to 1.
+	MaxConcurrentReconciles int
+
+	// SkipLint disables running helm lint before installs and upgrades.
+	SkipLint bool
+
+	// UpdateCheckInterval is how often ready releases are checked for newer
+	// chart versions in their repository. Zero disables the check.
+	UpdateCheckInterval time.Duration
+
+	// DriftCheckInterval is how often releases with a driftPolicy of Correct
+	// or Warn are compared with the live cluster. Zero disables the check.
+	DriftCheckInterval time.Duration
+
+	// Recorder emits Kubernetes events for the HelmRelease. Optional.
+	Recorder record.EventRecorder
+
+	// Locker guards Helm operations with a per-release Lease. Optional.
+	Locker *ReleaseLocker
+
+	// UpgradeBarrier serializes upgrades per target namespace. Optional.
+	UpgradeBarrier *NamespaceBarrier
+
+	// ClusterHealth pauses upgrades while the cluster is under duress.
+	// Optional.
+	ClusterHealth *ClusterHealthMonitor
+
+	// RBACSubject is bound to the Roles provisioned for releases with
+	// spec.rbac.autoProvision; normally the operator's own ServiceAccount.
+	// Nil makes such releases fail.
+	RBACSubject *rbacv1.Subject
+
+	// Discovery reports the cluster's version and API groups for
+	// spec.clusterSelector. Nil makes releases with a selector fail.
+	Discovery discovery.DiscoveryInterface
+
+	// CRD is the installed HelmRelease CRD as checked at startup. Releases
+	// get a CRDOutdated condition while it lacks fields. Optional.
+	CRD *CRDStatus
+
+	// FailureThreshold is how many consecutive transient failures a Ready
+	// release tolerates before it turns Ready=False. 0 or 1 degrades on the
+	// first failure.
+	FailureThreshold int
+
+	// Alerts notifies the owning team when a release fails or drifts.
+	// Optional.
+	Alerts *ReleaseAlerts
+
+	// ReconcileLog keeps the latest attempts of each release for the web
+	// API. Optional.
+	ReconcileLog *ReconcileLog
+
+	// GateClient checks spec.upgradeGates. Defaults to http.DefaultClient.
+	GateClient *http.Client
+
+	// Metrics exports per-release gauges. Optional.
+	Metrics *ReleaseMetrics
+
+	// ProbeClient runs the HTTP probes of spec.verify. Defaults to
+	// http.DefaultClient.
+	ProbeClient *http.Client
+
+	// StatusHistory is the number of Helm revisions recorded in
+	// status.history. 0 records none.
+	StatusHistory int
+
+	// DefaultServiceAccount is the ServiceAccount Helm impersonates for
+	// releases without spec.serviceAccountName. Empty runs them with the
+	// operator's own permissions.
+	DefaultServiceAccount string
+
+	// TargetNamespaces lists the namespaces HelmReleases of any namespace
+	// may deploy into; "*" allows every namespace. A release may always
+	// deploy into its own namespace. Releases with spec.kubeConfig are not
+	// restricted: the kubeconfig's credentials are.
+	TargetNamespaces []string
+
+	// RemoteHelmClient returns a Helm client for the cluster of a release's
+	// spec.kubeConfig. Nil makes such releases fail.
+	RemoteHelmClient func(*rest.Config) HelmClientInterface
+
+	updates updateCache
+	deps    dependencyGraph
+	sources sourceWaiters
 }
 
 // Reconcile is the main reconciliation loop.
@@ -39,86 +165,388 @@ func (r *HelmReleaseReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 
 	var release helmv1alpha1.HelmRelease
 	if err := r.Get(ctx, req.NamespacedName, &release); err != nil {
+		if apierrors.IsNotFound(err) {
+			r.deps.remove(req.NamespacedName)
+			r.sources.done(req.NamespacedName)
+			r.ReconcileLog.forget(req.NamespacedName)
+		}
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
+	if err := r.deps.load(ctx, r.Client); err != nil {
+		return ctrl.Result{}, err
+	}
+	r.deps.set(&release)
 
 	// Handle deletion.
 	if !release.DeletionTimestamp.IsZero() {
+		r.deps.markReconciled(req.NamespacedName)
 		return r.reconcileDelete(ctx, &release)
 	}
 
 	// Add finalizer if not present.
 	if !controllerutil.ContainsFinalizer(&release, finalizerName) {
-		controllerutil.AddFinalizer(&release, finalizerName)
-		if err := r.Update(ctx, &release); err != nil {
+		if err := r.patchFinalizers(ctx, &release, func(hr *helmv1alpha1.HelmRelease) {
+			controllerutil.AddFinalizer(hr, finalizerName)
+		}); err != nil {
 			return ctrl.Result{}, fmt.Errorf("adding finalizer: %w", err)
 		}
 		log.Info("Added finalizer")
+		// Adding a finalizer does not bump the generation, so the resulting
+		// update event is filtered out; requeue explicitly.
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	// Ephemeral releases are deleted once their TTL elapses; the finalizer
+	// then uninstalls the chart through reconcileDelete.
+	if ttlExpired(&release) {
+		log.Info("TTL elapsed, deleting HelmRelease", "ttl", release.Spec.TTL.Duration)
+		if err := r.Delete(ctx, &release); client.IgnoreNotFound(err) != nil {
+			return ctrl.Result{}, fmt.Errorf("deleting expired release: %w", err)
+		}
 		return ctrl.Result{}, nil
 	}
 
-	return r.reconcileNormal(ctx, &release)
+	if cycle := r.deps.cycle(req.NamespacedName); cycle != nil {
+		r.deps.markReconciled(req.NamespacedName)
+		result, err := r.reconcileDependencyCycle(ctx, &release, cycle)
+		return requeueBeforeExpiry(&release, result), err
+	}
+
+	if release.Spec.Suspend {
+		r.deps.markReconciled(req.NamespacedName)
+		result, err := r.reconcileSuspended(ctx, &release)
+		return requeueBeforeExpiry(&release, result), err
+	}
+
+	// After a restart every release is resynced; dependencies go first.
+	if pending := r.deps.unreconciledDependencies(req.NamespacedName); len(pending) > 0 {
+		log.V(1).Info("Waiting for dependencies to be reconciled", "dependencies", pending)
+		return ctrl.Result{RequeueAfter: requeueForDependencies}, nil
+	}
+
+	notReady, err := r.notReadyDependencies(ctx, &release)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if len(notReady) > 0 {
+		r.deps.markReconciled(req.NamespacedName)
+		result, err := r.reconcileDependencyNotReady(ctx, &release, notReady)
+		return requeueBeforeExpiry(&release, result), err
+	}
+
+	result, err := r.reconcileNormal(ctx, &release)
+	r.deps.markReconciled(req.NamespacedName)
+	return requeueBeforeExpiry(&release, result), err
 }
 
-// reconcileNormal handles create and update operations.
+// reconcileNormal handles create and update operations. Status changes made
+// along the way are written once, as a single patch, when it returns.
 func (r *HelmReleaseReconciler) reconcileNormal(ctx context.Context, release *helmv1alpha1.HelmRelease) (ctrl.Result, error) {
+	base := release.DeepCopy()
+	trigger := reconcileTrigger(release)
+	beginAttempt(release, operationReconcile, time.Now())
+	result, err := r.reconcileRelease(ctx, release)
+	endAttempt(release, err, time.Now())
+	r.ReconcileLog.record(release, trigger)
+	r.Metrics.observe(release)
+	if patchErr := r.patchStatus(ctx, release, base); patchErr != nil {
+		if err == nil && result.IsZero() {
+			return ctrl.Result{}, fmt.Errorf("updating status: %w", patchErr)
+		}
+		ctrl.LoggerFrom(ctx).Error(patchErr, "Updating status")
+	}
+	return result, err
+}
+
+// reconcileRelease installs or upgrades the Helm release and records the
+// outcome on release.Status without writing it.
+func (r *HelmReleaseReconciler) reconcileRelease(ctx context.Context, release *helmv1alpha1.HelmRelease) (ctrl.Result, error) {
 	log := ctrl.LoggerFrom(ctx)
 
 	releaseName := release.Name
 	if release.Spec.ReleaseName != "" {
 		releaseName = release.Spec.ReleaseName
 	}
+	release.Status.ExpiresAt = ttlExpiry(release)
+	clearSuspended(release)
+	clearDependencyCycle(release)
+	clearDependencyNotReady(release)
+	setValidationRelaxed(release)
+	r.setCRDOutdated(release)
 
-	// If the release already failed for this generation of the spec, do not
-	// re-attempt the install immediately. A status update (e.g. from
-	// setFailedStatus) generates a new watch event that would otherwise cause
-	// an infinite tight reconcile loop. Wait for the requeue interval instead,
-	// so the Failed phase is stable and visible in the UI.
-	// A spec change increments generation and clears this gate automatically.
-	if release.Status.Phase == helmv1alpha1.PhaseFailed &&
-		release.Status.ObservedGeneration == release.Generation {
-		return ctrl.Result{RequeueAfter: requeueOnFailure}, nil
+	// A release whose clusterSelector does not match is neither installed
+	// nor upgraded; one already installed is left as it is.
+	if compatible, err := r.checkClusterSelector(release); err != nil {
+		return ctrl.Result{RequeueAfter: requeueOnFailure}, r.setFailedStatus(release, err)
+	} else if !compatible {
+		log.Info("Cluster does not match clusterSelector, skipping")
+		return ctrl.Result{RequeueAfter: requeueForClusterSelector}, nil
 	}
 
-	// Parse optional values.
-	values := map[string]interface{}{}
-	if release.Spec.Values != nil {
-		if err := json.Unmarshal(release.Spec.Values.Raw, &values); err != nil {
-			return ctrl.Result{}, r.setFailedStatus(ctx, release, fmt.Errorf("parsing values: %w", err))
-		}
+	// A release that used up spec.maxRetries is left alone until its spec
+	// changes.
+	if stalled(release) {
+		log.Info("Retries exhausted, waiting for a spec change", "failures", release.Status.Failures)
+		return ctrl.Result{}, nil
+	}
+	clearStalled(release)
+
+	// If the release failed for this generation of the spec less than its
+	// backoff ago, do not re-attempt it yet, so the Failed phase is stable
+	// and visible in the UI. After that the failed operation is retried. A
+	// spec change increments generation and clears this gate.
+	if wait := retryAfterFailure(release); wait > 0 {
+		return ctrl.Result{RequeueAfter: wait}, nil
+	}
+
+	helm, err := r.helmClientFor(ctx, release)
+	if err != nil {
+		return ctrl.Result{RequeueAfter: requeueOnFailure}, r.setFailedStatus(release, err)
+	}
+	if err := r.checkTargetNamespace(release); err != nil {
+		return ctrl.Result{}, r.setFailedStatus(release, err)
 	}
 
-	exists, err := r.HelmClient.ReleaseExists(releaseName, release.Spec.TargetNamespace)
+	relocating, err := relocation(release, releaseName)
 	if err != nil {
-		return ctrl.Result{RequeueAfter: requeueOnFailure}, r.setFailedStatus(ctx, release, err)
+		return ctrl.Result{RequeueAfter: requeueOnFailure}, r.setFailedStatus(release, err)
 	}
 
+	// Values from valuesFrom sources may be produced by other controllers,
+	// such as external-secrets, after the HelmRelease is created; wait for
+	// them rather than fail. Creating the missing object reconciles the
+	// release through sourceHandler.
+	values, err := r.composeValues(ctx, release)
+	var missing *missingValuesSourceError
+	if errors.As(err, &missing) {
+		log.Info("Waiting for a "+missing.field()+" source", "source", missing.Error())
+		r.sources.wait(client.ObjectKeyFromObject(release), missing.source())
+		r.setWaitingForValuesSource(release, missing)
+		return ctrl.Result{RequeueAfter: requeueForValuesSource}, nil
+	}
+	r.sources.done(client.ObjectKeyFromObject(release))
+	if err != nil {
+		return ctrl.Result{RequeueAfter: requeueOnFailure}, r.setFailedStatus(release, err)
+	}
+	clearWaitingForValuesSource(release)
+	r.Rewrites.RewriteValues(values)
+	repoURL := r.Rewrites.Rewrite(release.Spec.RepoURL)
+	if err := validateRepoURL(repoURL); err != nil {
+		return ctrl.Result{}, r.setFailedStatus(release, err)
+	}
+	fetch, err := r.chartFetchOptions(ctx, release)
+	if err != nil {
+		return ctrl.Result{RequeueAfter: requeueOnFailure}, r.setFailedStatus(release, err)
+	}
+
+	desired, err := desiredApply(release, repoURL, values)
+	if err != nil {
+		return ctrl.Result{}, r.setFailedStatus(release, fmt.Errorf("hashing release inputs: %w", err))
+	}
+
+	exists, err := helm.ReleaseExists(releaseName, release.Spec.TargetNamespace)
+	if err != nil {
+		return ctrl.Result{RequeueAfter: requeueOnFailure}, r.setFailedStatus(release, err)
+	}
+
+	// Helm runs only when the inputs differ from the last successful apply,
+	// so spec changes that do not affect the release (e.g. ttl) are no-ops
+	// and a failed upgrade is retried even though its generation was seen.
+	applying := !exists || relocating || needsApply(release, desired)
+	cause := CauseSpecChange
+	switch {
+	case !exists:
+		cause = CauseInstall
+	case release.Status.Phase == helmv1alpha1.PhaseFailed && release.Status.ObservedGeneration == release.Generation:
+		cause = CauseRetry
+	}
+	if !applying && r.checkDrift(ctx, release, helm, releaseName) {
+		log.Info("Correcting drift", "releaseName", releaseName)
+		applying = true
+		cause = CauseDriftCorrection
+	}
+	trigger := deployTrigger(release, cause)
+	if applying && exists && release.Spec.KubeConfig == nil {
+		// Upgrading a release Flux or Argo CD also upgrades would have the
+		// two fight over it.
+		if err := r.checkOtherTool(ctx, release, releaseName); err != nil {
+			return ctrl.Result{RequeueAfter: requeueOnFailure}, r.setFailedStatus(release, err)
+		}
+	}
+	if applying && exists {
+		if held, until, reason := releaseHold(release, time.Now()); held {
+			log.Info("Upgrade on hold, skipping", "reason", reason)
+			setHeld(release, reason)
+			if r.UpdateCheckInterval > 0 {
+				r.checkForUpdate(ctx, release, repoURL, fetch)
+			}
+			if until.IsZero() {
+				// Removing the annotation triggers a reconcile.
+				return ctrl.Result{RequeueAfter: r.UpdateCheckInterval}, nil
+			}
+			return ctrl.Result{RequeueAfter: time.Until(until)}, nil
+		}
+	}
+	clearHeld(release)
+	// ClusterHealth watches this cluster; it says nothing about the cluster
+	// of spec.kubeConfig.
+	if applying && exists && release.Spec.KubeConfig == nil {
+		if duress := r.ClusterHealth.Duress(); duress != nil {
+			log.Info("Cluster under duress, deferring upgrade", "reason", duress.Reason, "message", duress.Message)
+			r.setUpgradesDeferred(release, duress)
+			return ctrl.Result{RequeueAfter: r.ClusterHealth.Interval}, nil
+		}
+	}
+	r.clearUpgradesDeferred(release)
+	if applying && exists && !r.checkUpgradeGates(ctx, release) {
+		log.Info("Upgrade gates closed, deferring upgrade")
+		return ctrl.Result{RequeueAfter: requeueForUpgradeGates}, nil
+	}
+	// outcomeRecorded is set once Helm's result is reported to Breakers.
+	outcomeRecorded := false
+	if applying {
+		ok, wait, probe := r.Breakers.Allow(repoURL)
+		if !ok {
+			log.Info("Repository circuit breaker open, deferring", "repoURL", repoURL, "retryAfter", wait)
+			return ctrl.Result{RequeueAfter: wait}, r.setRepositoryDegraded(release, repoURL, wait)
+		}
+		if probe {
+			// Returning before Helm runs, e.g. on a held lock, must not keep
+			// every other release of the repository waiting.
+			defer func() {
+				if !outcomeRecorded {
+					r.Breakers.AbandonProbe(repoURL)
+				}
+			}()
+		}
+		locker, err := r.lockerFor(ctx, release.Namespace, release.Spec.KubeConfig)
+		if err != nil {
+			return ctrl.Result{RequeueAfter: requeueOnFailure}, r.setFailedStatus(release, err)
+		}
+		unlock, err := locker.Lock(ctx, release.Spec.TargetNamespace, releaseName)
+		var locked *LockedError
+		if errors.As(err, &locked) {
+			log.Info("Release locked by another client, deferring", "holder", locked.Holder)
+			setLockedByOther(release, locked)
+			return ctrl.Result{RequeueAfter: requeueWhenLocked}, nil
+		}
+		if err != nil {
+			return ctrl.Result{RequeueAfter: requeueOnFailure}, r.setFailedStatus(release, fmt.Errorf("acquiring release lock: %w", err))
+		}
+		defer unlock()
+		// The old Helm release is uninstalled only once the new location is
+		// locked, so no other client deploys there in between.
+		if relocating {
+			if err := r.relocate(ctx, release, helm, releaseName); errors.As(err, &locked) {
+				log.Info("Old release location locked by another client, deferring", "holder", locked.Holder)
+				setLockedByOther(release, locked)
+				return ctrl.Result{RequeueAfter: requeueWhenLocked}, nil
+			} else if err != nil {
+				return ctrl.Result{RequeueAfter: requeueOnFailure}, r.setFailedStatus(release, err)
+			}
+		}
+		clearLockedByOther(release)
+		if !r.SkipLint {
+			r.lintRelease(ctx, release, repoURL, values, fetch)
+		}
+		if err := r.ensureTargetNamespace(ctx, release); err != nil {
+			return ctrl.Result{RequeueAfter: requeueOnFailure}, r.setFailedStatus(release, err)
+		}
+		if err := r.provisionRBAC(ctx, release, releaseName, repoURL, values, fetch); err != nil {
+			return ctrl.Result{RequeueAfter: requeueOnFailure}, r.setFailedStatus(release, err)
+		}
+	}
+
+	var deployed *DeployedChart
 	if !exists {
 		log.Info("Installing Helm release", "releaseName", releaseName)
-		release.Status.Phase = helmv1alpha1.PhaseInstalling
-		_ = r.Status().Update(ctx, release)
-
-		if err := r.HelmClient.Install(ctx, releaseName, release.Spec.Chart, release.Spec.RepoURL,
-			release.Spec.Version, release.Spec.TargetNamespace, values); err != nil {
-			return ctrl.Result{RequeueAfter: requeueOnFailure}, r.setFailedStatus(ctx, release, err)
+		setAttemptOperation(release, operationInstall)
+		if err := r.withExtensions(ctx, release, releaseName, values, func(values map[string]interface{}, pr postrender.PostRenderer) error {
+			var err error
+			deployed, err = helm.Install(ctx, releaseName, release.Spec.Chart, repoURL,
+				release.Spec.Version, release.Spec.TargetNamespace, values,
+				InstallOptions{PostRenderer: pr, Fetch: fetch, Description: description(trigger), Wait: installWaitOptions(release),
+					Atomic:               release.Spec.Install != nil && release.Spec.Install.Atomic,
+					SkipSchemaValidation: release.Spec.SkipSchemaValidation, DisableOpenAPIValidation: release.Spec.DisableOpenAPIValidation,
+					SkipCRDs: release.Spec.SkipCRDs, DisableHooks: release.Spec.DisableHooks,
+					CreateNamespace: release.Spec.CreateNamespace, Impersonate: r.impersonatedUser(release)})
+			return err
+		}); err != nil {
+			r.Breakers.RecordFailure(repoURL)
+			outcomeRecorded = true
+			r.recordHistory(ctx, release, helm, releaseName)
+			return ctrl.Result{RequeueAfter: requeueOnFailure}, r.setFailedStatus(release, r.explainInstallError(ctx, release, err))
+		}
+	} else if applying {
+		start := time.Now()
+		leave, err := r.UpgradeBarrier.Enter(ctx, clusterKey(release)+release.Spec.TargetNamespace)
+		if err != nil {
+			return ctrl.Result{}, fmt.Errorf("waiting for upgrades in %s: %w", release.Spec.TargetNamespace, err)
+		}
+		defer leave()
+		if waited := time.Since(start); waited > time.Second {
+			log.Info("Waited for another upgrade in the target namespace", "waited", waited.Round(time.Millisecond))
+		}
+		recreate, err := r.checkImmutableFields(ctx, release, helm, releaseName, repoURL, values, fetch)
+		if err != nil {
+			return ctrl.Result{RequeueAfter: requeueOnFailure}, r.setFailedStatus(release, err)
 		}
-	} else if release.Status.ObservedGeneration != release.Generation {
 		log.Info("Upgrading Helm release", "releaseName", releaseName)
-		release.Status.Phase = helmv1alpha1.PhaseUpgrading
-		_ = r.Status().Update(ctx, release)
-
-		if err := r.HelmClient.Upgrade(ctx, releaseName, release.Spec.Chart, release.Spec.RepoURL,
-			release.Spec.Version, release.Spec.TargetNamespace, values); err != nil {
-			return ctrl.Result{RequeueAfter: requeueOnFailure}, r.setFailedStatus(ctx, release, err)
+		setAttemptOperation(release, operationUpgrade)
+		if err := r.withExtensions(ctx, release, releaseName, values, func(values map[string]interface{}, pr postrender.PostRenderer) error {
+			opts := UpgradeOptions{PostRenderer: pr, Fetch: fetch, Description: description(trigger), Wait: waitOptions(release),
+				SkipSchemaValidation: release.Spec.SkipSchemaValidation, DisableOpenAPIValidation: release.Spec.DisableOpenAPIValidation,
+				DisableHooks: release.Spec.DisableHooks, MaxHistory: release.Spec.MaxHistory, Impersonate: r.impersonatedUser(release),
+				Recreate: recreate}
+			if u := release.Spec.Upgrade; u != nil {
+				opts.Force, opts.CleanupOnFail = u.Force, u.CleanupOnFail
+				opts.ResetValues, opts.ReuseValues = u.ResetValues, u.ReuseValues
+			}
+			var err error
+			deployed, err = helm.Upgrade(ctx, releaseName, release.Spec.Chart, repoURL,
+				release.Spec.Version, release.Spec.TargetNamespace, values, opts)
+			return err
+		}); err != nil {
+			r.Breakers.RecordFailure(repoURL)
+			outcomeRecorded = true
+			r.recordHistory(ctx, release, helm, releaseName)
+			return ctrl.Result{RequeueAfter: requeueOnFailure}, r.setFailedStatus(release, err)
 		}
 	}
 
-	// Update status on success.
-	now := metav1.Now()
+	// Update status on success. Deployment details only change when Helm
+	// actually ran, so a steady-state reconcile leaves the status untouched.
+	if applying {
+		r.Breakers.RecordSuccess(repoURL)
+		outcomeRecorded = true
+		now := metav1.Now()
+		// Record the artifact Helm actually deployed: a version constraint
+		// or a re-pushed tag can resolve to a different chart next time.
+		release.Status.DeployedVersion = deployed.Version
+		release.Status.AppVersion = deployed.AppVersion
+		release.Status.ChartDigest = deployed.Digest
+		release.Status.LastDeployedAt = &now
+		release.Status.LastApplied = desired
+		release.Status.LastDeployTrigger = trigger
+		clearVerificationFailed(release)
+		r.recordHistory(ctx, release, helm, releaseName)
+	}
+
+	// The release is Ready only once its probes pass. LastApplied is
+	// already recorded, so a retry probes again without upgrading again.
+	if err := r.verifyProbes(ctx, release, applying); err != nil {
+		return ctrl.Result{RequeueAfter: requeueOnFailure}, r.setFailedStatus(release, err)
+	}
 	release.Status.Phase = helmv1alpha1.PhaseReady
-	release.Status.DeployedVersion = release.Spec.Version
-	release.Status.LastDeployedAt = &now
 	release.Status.ObservedGeneration = release.Generation
+	release.Status.ConsecutiveFailures = 0
+	release.Status.Failures = 0
+	if err := recordLastKnownGood(release); err != nil {
+		log.Error(err, "Recording the last known good spec")
+	}
+	release.Status.ReleaseName = releaseName
+	release.Status.ReleaseNamespace = release.Spec.TargetNamespace
+	release.Status.ReleaseKubeConfig = release.Spec.KubeConfig.DeepCopy()
 
 	setCondition(release, metav1.Condition{
 		Type:               "Ready",
@@ -134,12 +562,21 @@ func (r *HelmReleaseReconciler) reconcileNormal(ctx context.Context, release *he
 		Message:            "Helm release reconciliation complete",
 		ObservedGeneration: release.Generation,
 	})
-
-	if err := r.Status().Update(ctx, release); err != nil {
-		return ctrl.Result{}, fmt.Errorf("updating status: %w", err)
+	clearRepositoryDegraded(release)
+	clearMissingPermissions(release)
+	if r.UpdateCheckInterval > 0 {
+		r.checkForUpdate(ctx, release, repoURL, fetch)
 	}
+
 	log.Info("Reconciliation complete", "phase", release.Status.Phase)
-	return ctrl.Result{}, nil
+	next := r.UpdateCheckInterval
+	if driftPolicy(release) != helmv1alpha1.DriftPolicyIgnore {
+		next = nextCheck(next, r.DriftCheckInterval)
+	}
+	if release.Spec.Interval != nil {
+		next = nextCheck(next, release.Spec.Interval.Duration)
+	}
+	return ctrl.Result{RequeueAfter: next}, nil
 }
 
 // reconcileDelete handles CR deletion by uninstalling the Helm release.
@@ -154,29 +591,104 @@ func (r *HelmReleaseReconciler) reconcileDelete(ctx context.Context, release *he
 	if release.Spec.ReleaseName != "" {
 		releaseName = release.Spec.ReleaseName
 	}
+	releaseName, namespace := installedRelease(release, releaseName)
+	cluster := installedCluster(release)
 
+	dependents, err := r.teardownDependents(ctx, release)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if len(dependents) > 0 {
+		log.Info("Waiting for dependents to be uninstalled first", "dependents", dependents)
+		return ctrl.Result{RequeueAfter: requeueForDependencies}, nil
+	}
+
+	base := release.DeepCopy()
 	release.Status.Phase = helmv1alpha1.PhaseUninstalling
-	_ = r.Status().Update(ctx, release)
+	_ = r.patchStatus(ctx, release, base)
+
+	locker, err := r.lockerFor(ctx, release.Namespace, cluster)
+	if err != nil {
+		base := release.DeepCopy()
+		_ = r.setFailedStatus(release, err)
+		_ = r.patchStatus(ctx, release, base)
+		return ctrl.Result{RequeueAfter: requeueOnFailure}, nil
+	}
+	unlock, err := locker.Lock(ctx, namespace, releaseName)
+	var locked *LockedError
+	if errors.As(err, &locked) {
+		log.Info("Release locked by another client, deferring uninstall", "holder", locked.Holder)
+		base := release.DeepCopy()
+		setLockedByOther(release, locked)
+		_ = r.patchStatus(ctx, release, base)
+		return ctrl.Result{RequeueAfter: requeueWhenLocked}, nil
+	}
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("acquiring release lock: %w", err)
+	}
+	defer unlock()
 
 	log.Info("Uninstalling Helm release", "releaseName", releaseName)
-	if err := r.HelmClient.Uninstall(ctx, releaseName, release.Spec.TargetNamespace); err != nil {
-		return ctrl.Result{RequeueAfter: requeueOnFailure}, r.setFailedStatus(ctx, release, err)
+	start := time.Now()
+	helm, err := r.helmClientForCluster(ctx, release.Namespace, cluster)
+	if err == nil {
+		err = helm.Uninstall(ctx, releaseName, namespace, r.uninstallOptions(release))
+	}
+	if err != nil {
+		base := release.DeepCopy()
+		beginAttempt(release, operationUninstall, start)
+		_ = r.setFailedStatus(release, err)
+		endAttempt(release, err, time.Now())
+		r.ReconcileLog.record(release, TriggerDeletion)
+		_ = r.patchStatus(ctx, release, base)
+		return ctrl.Result{RequeueAfter: requeueOnFailure}, nil
 	}
 
-	controllerutil.RemoveFinalizer(release, finalizerName)
-	if err := r.Update(ctx, release); err != nil {
+	r.Metrics.forget(release)
+	if err := locker.Forget(ctx, namespace, releaseName); err != nil {
+		log.Error(err, "Deleting release lock")
+	}
+
+	// Objects the operator created for the release outside Helm.
+	if err := r.keepNamespacesInUse(ctx, release); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := ownership.Cleanup(ctx, r.Client, release, ownedTypes()...); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := r.patchFinalizers(ctx, release, func(hr *helmv1alpha1.HelmRelease) {
+		controllerutil.RemoveFinalizer(hr, finalizerName)
+	}); client.IgnoreNotFound(err) != nil {
 		return ctrl.Result{}, fmt.Errorf("removing finalizer: %w", err)
 	}
 	log.Info("Finalizer removed, deletion complete")
 	return ctrl.Result{}, nil
 }
 
+// ownedTypes lists the kinds of auxiliary objects the operator may create for
+// a HelmRelease; see package ownership.
+func ownedTypes() []client.ObjectList {
+	return []client.ObjectList{&corev1.NamespaceList{}, &corev1.ConfigMapList{}, &rbacv1.RoleList{}, &rbacv1.RoleBindingList{}}
+}
+
 // setFailedStatus records a failure condition and returns nil so callers can
 // return a non-zero RequeueAfter result without triggering the controller-runtime
 // warning about returning both a non-zero result and a non-nil error.
 // ObservedGeneration is set so that reconcileNormal can detect that a failure
 // has already been recorded for this generation and avoid a tight retry loop.
-func (r *HelmReleaseReconciler) setFailedStatus(ctx context.Context, release *helmv1alpha1.HelmRelease, err error) error {
+// A Ready release stays Ready through transient failures below
+// FailureThreshold; see tolerateFailure. The status is written by the caller.
+func (r *HelmReleaseReconciler) setFailedStatus(release *helmv1alpha1.HelmRelease, err error) error {
+	release.Status.ConsecutiveFailures++
+	recordFailure(release, time.Now())
+	failAttempt(release, err)
+	if r.tolerateFailure(release, err) {
+		return nil
+	}
+	if release.Status.Phase != helmv1alpha1.PhaseFailed {
+		r.Alerts.failed(release, err)
+	}
 	release.Status.Phase = helmv1alpha1.PhaseFailed
 	release.Status.ObservedGeneration = release.Generation
 	setCondition(release, metav1.Condition{
@@ -186,10 +698,43 @@ func (r *HelmReleaseReconciler) setFailedStatus(ctx context.Context, release *he
 		Message:            err.Error(),
 		ObservedGeneration: release.Generation,
 	})
-	_ = r.Status().Update(ctx, release)
+	setMissingPermissions(release, err)
+	setVerificationFailed(release, err)
+	r.stallIfExhausted(release, err)
 	return nil
 }
 
+// patchStatus writes the difference between base and release's status as a
+// merge patch. Nothing is sent when the status is unchanged. The controller is
+// the only writer of status, so the patch carries no resourceVersion and does
+// not fail when the UI or a webhook has modified the object in the meantime.
+func (r *HelmReleaseReconciler) patchStatus(ctx context.Context, release, base *helmv1alpha1.HelmRelease) error {
+	if equality.Semantic.DeepEqual(base.Status, release.Status) {
+		return nil
+	}
+	return r.Status().Patch(ctx, release, client.MergeFrom(base))
+}
+
+// patchFinalizers applies mutate to release and writes it with a merge patch.
+// A merge patch replaces the finalizer list as a whole, so the patch is
+// guarded by the resourceVersion; on conflict the latest copy is fetched and
+// mutate is applied again.
+func (r *HelmReleaseReconciler) patchFinalizers(ctx context.Context, release *helmv1alpha1.HelmRelease,
+	mutate func(*helmv1alpha1.HelmRelease)) error {
+	refetch := false
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if refetch {
+			if err := r.Get(ctx, client.ObjectKeyFromObject(release), release); err != nil {
+				return err
+			}
+		}
+		refetch = true
+		base := release.DeepCopy()
+		mutate(release)
+		return r.Patch(ctx, release, client.MergeFromWithOptions(base, client.MergeFromWithOptimisticLock{}))
+	})
+}
+
 // setCondition upserts a condition on the HelmRelease status.
 func setCondition(release *helmv1alpha1.HelmRelease, condition metav1.Condition) {
 	condition.LastTransitionTime = metav1.Now()
@@ -208,6 +753,10 @@ func setCondition(release *helmv1alpha1.HelmRelease, condition metav1.Condition)
 // SetupWithManager registers the controller with the manager.
 func (r *HelmReleaseReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&helmv1alpha1.HelmRelease{}).
+		For(&helmv1alpha1.HelmRelease{}, builder.WithPredicates(reconcilePredicate())).
+		Watches(&helmv1alpha1.HelmRelease{}, r.dependentsHandler()).
+		Watches(&corev1.ConfigMap{}, r.sourceHandler("ConfigMap"), builder.OnlyMetadata).
+		Watches(&corev1.Secret{}, r.sourceHandler("Secret"), builder.OnlyMetadata).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
 		Complete(r)
 }
@@ -4,16 +4,21 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 
 	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"github.com/example/helm-operator/controllers"
+	"github.com/example/helm-operator/ownership"
+	corev1 "k8s.io/api/core/v1"
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -38,6 +43,16 @@ func makeHR(name string) *helmv1alpha1.HelmRelease {
 	}
 }
 
+// allowAnyTarget lets releases deploy outside testNS.
+func allowAnyTarget(r *controllers.HelmReleaseReconciler) {
+	r.TargetNamespaces = []string{"*"}
+}
+
+// withDiscovery lets the reconciler list what a namespace holds.
+func withDiscovery(r *controllers.HelmReleaseReconciler) {
+	r.Discovery = discovery.NewDiscoveryClientForConfigOrDie(cfg)
+}
+
 func getHR(ctx context.Context, name string) (*helmv1alpha1.HelmRelease, error) {
 	hr := &helmv1alpha1.HelmRelease{}
 	err := k8sClient.Get(ctx, types.NamespacedName{Name: name, Namespace: testNS}, hr)
@@ -63,6 +78,32 @@ var _ = Describe("HelmReleaseReconciler", func() {
 				g.Expect(fetched.Finalizers).To(ContainElement("helm.example.com/finalizer"))
 			}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
 		})
+
+		It("keeps finalizers added by other controllers", func() {
+			mock := &MockHelmClient{}
+			cancel := startManager(mock)
+			defer cancel()
+
+			hr := makeHR("test-finalizer-foreign")
+			hr.Finalizers = []string{"example.com/other"}
+			Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+			DeferCleanup(func() {
+				k8sClient.Delete(ctx, hr)
+				fetched, err := getHR(ctx, hr.Name)
+				if err == nil {
+					patch := client.MergeFrom(fetched.DeepCopy())
+					fetched.Finalizers = nil
+					k8sClient.Patch(ctx, fetched, patch)
+				}
+			})
+
+			Eventually(func(g Gomega) {
+				fetched, err := getHR(ctx, hr.Name)
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(fetched.Finalizers).To(ConsistOf("example.com/other", "helm.example.com/finalizer"))
+				g.Expect(fetched.Status.Phase).To(Equal(helmv1alpha1.PhaseReady))
+			}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+		})
 	})
 
 	Describe("Install", func() {
@@ -128,6 +169,173 @@ var _ = Describe("HelmReleaseReconciler", func() {
 			}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
 		})
 
+		It("passes spec.skipCRDs and spec.disableHooks to Install", func() {
+			mock := &MockHelmClient{}
+			cancel := startManager(mock)
+			defer cancel()
+
+			hr := makeHR("test-install-skip-crds")
+			hr.Spec.SkipCRDs = true
+			hr.Spec.DisableHooks = true
+			Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+			DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+			Eventually(func(g Gomega) {
+				mock.mu.Lock()
+				opts := mock.InstallArgs.Opts
+				mock.mu.Unlock()
+				g.Expect(opts.SkipCRDs).To(BeTrue())
+				g.Expect(opts.DisableHooks).To(BeTrue())
+			}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+		})
+
+		It("records the spec of a Ready release as status.lastKnownGoodSpec", func() {
+			mock := &MockHelmClient{}
+			cancel := startManager(mock)
+			defer cancel()
+
+			hr := makeHR("test-last-known-good")
+			Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+			DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+			Eventually(func(g Gomega) {
+				fetched, err := getHR(ctx, hr.Name)
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(fetched.Status.Phase).To(Equal(helmv1alpha1.PhaseReady))
+				g.Expect(fetched.Status.LastKnownGoodSpec).NotTo(BeNil())
+				var spec helmv1alpha1.HelmReleaseSpec
+				g.Expect(json.Unmarshal(fetched.Status.LastKnownGoodSpec.Raw, &spec)).To(Succeed())
+				g.Expect(spec).To(Equal(fetched.Spec))
+			}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+		})
+
+		It("creates the target namespace with spec.createNamespace", func() {
+			mock := &MockHelmClient{}
+			cancel := startManager(mock, allowAnyTarget, withDiscovery)
+			defer cancel()
+
+			hr := makeHR("test-create-namespace")
+			hr.Spec.TargetNamespace = "test-created-ns"
+			hr.Spec.CreateNamespace = true
+			Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+			DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+			Eventually(func(g Gomega) {
+				ns := &corev1.Namespace{}
+				g.Expect(k8sClient.Get(ctx, client.ObjectKey{Name: "test-created-ns"}, ns)).To(Succeed())
+				g.Expect(ns.Labels).To(HaveKeyWithValue(ownership.LabelOwnerName, hr.Name))
+				mock.mu.Lock()
+				opts := mock.InstallArgs.Opts
+				mock.mu.Unlock()
+				g.Expect(opts.CreateNamespace).To(BeTrue())
+			}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+
+			// envtest runs no namespace controller, so deletion leaves the
+			// namespace Terminating.
+			Expect(k8sClient.Delete(ctx, hr)).To(Succeed())
+			Eventually(func(g Gomega) {
+				ns := &corev1.Namespace{}
+				g.Expect(k8sClient.Get(ctx, client.ObjectKey{Name: "test-created-ns"}, ns)).To(Succeed())
+				g.Expect(ns.DeletionTimestamp).NotTo(BeNil())
+			}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+		})
+
+		It("keeps a created target namespace that holds other objects", func() {
+			mock := &MockHelmClient{}
+			cancel := startManager(mock, allowAnyTarget, withDiscovery)
+			defer cancel()
+
+			hr := makeHR("test-keep-namespace")
+			hr.Spec.TargetNamespace = "test-kept-ns"
+			hr.Spec.CreateNamespace = true
+			Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+			DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+			Eventually(func(g Gomega) {
+				fetched, err := getHR(ctx, hr.Name)
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(fetched.Status.Phase).To(Equal(helmv1alpha1.PhaseReady))
+			}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+
+			other := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "not-from-the-release", Namespace: "test-kept-ns"}}
+			Expect(k8sClient.Create(ctx, other)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, hr)).To(Succeed())
+			Eventually(func() bool {
+				return apierrors.IsNotFound(k8sClient.Get(ctx, client.ObjectKeyFromObject(hr), &helmv1alpha1.HelmRelease{}))
+			}).WithTimeout(timeout).WithPolling(polling).Should(BeTrue())
+
+			ns := &corev1.Namespace{}
+			Expect(k8sClient.Get(ctx, client.ObjectKey{Name: "test-kept-ns"}, ns)).To(Succeed())
+			Expect(ns.DeletionTimestamp).To(BeNil())
+			Expect(ns.Labels).NotTo(HaveKey(ownership.LabelOwnerName))
+		})
+
+		It("leaves creating the namespace to Helm when it impersonates a ServiceAccount", func() {
+			mock := &MockHelmClient{}
+			cancel := startManager(mock, allowAnyTarget)
+			defer cancel()
+
+			hr := makeHR("test-impersonated-namespace")
+			hr.Spec.TargetNamespace = "test-impersonated-ns"
+			hr.Spec.CreateNamespace = true
+			hr.Spec.ServiceAccountName = "tenant-deployer"
+			Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+			DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+			Eventually(func(g Gomega) {
+				mock.mu.Lock()
+				opts := mock.InstallArgs.Opts
+				mock.mu.Unlock()
+				g.Expect(opts.CreateNamespace).To(BeTrue())
+				g.Expect(opts.Impersonate).To(Equal("system:serviceaccount:" + testNS + ":tenant-deployer"))
+			}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+			Expect(apierrors.IsNotFound(k8sClient.Get(ctx, client.ObjectKey{Name: "test-impersonated-ns"}, &corev1.Namespace{}))).To(BeTrue())
+		})
+
+		It("names a missing target namespace when the install fails", func() {
+			mock := &MockHelmClient{InstallErr: errors.New(`namespaces "test-missing-ns" not found`)}
+			cancel := startManager(mock, allowAnyTarget)
+			defer cancel()
+
+			hr := makeHR("test-missing-namespace")
+			hr.Spec.TargetNamespace = "test-missing-ns"
+			Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+			DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+			Eventually(func(g Gomega) {
+				fetched, err := getHR(ctx, hr.Name)
+				g.Expect(err).NotTo(HaveOccurred())
+				cond := findCondition(fetched, "Ready")
+				g.Expect(cond).NotTo(BeNil())
+				g.Expect(cond.Message).To(ContainSubstring("target namespace test-missing-ns does not exist; create it or set spec.createNamespace"))
+			}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+		})
+
+		It("refuses a target namespace the operator does not allow", func() {
+			mock := &MockHelmClient{}
+			cancel := startManager(mock, func(r *controllers.HelmReleaseReconciler) {
+				r.TargetNamespaces = []string{"team-a"}
+			})
+			defer cancel()
+
+			hr := makeHR("test-foreign-namespace")
+			hr.Spec.TargetNamespace = "kube-system"
+			Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+			DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+			Eventually(func(g Gomega) {
+				fetched, err := getHR(ctx, hr.Name)
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(fetched.Status.Phase).To(Equal(helmv1alpha1.PhaseFailed))
+				cond := findCondition(fetched, "Ready")
+				g.Expect(cond).NotTo(BeNil())
+				g.Expect(cond.Message).To(ContainSubstring("--allowed-target-namespaces"))
+			}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+
+			mock.mu.Lock()
+			defer mock.mu.Unlock()
+			Expect(mock.InstallCalled).To(BeFalse())
+		})
+
 		It("sets Phase=Failed with Ready=False condition on install error", func() {
 			mock := &MockHelmClient{InstallErr: errors.New("install failed")}
 			cancel := startManager(mock)
@@ -155,6 +363,38 @@ var _ = Describe("HelmReleaseReconciler", func() {
 	})
 
 	Describe("Upgrade", func() {
+		It("passes spec.upgrade to Helm", func() {
+			mock := &MockHelmClient{ReleaseExistsResult: true}
+			cancel := startManager(mock)
+			defer cancel()
+
+			hr := makeHR("test-upgrade-options")
+			hr.Spec.Upgrade = &helmv1alpha1.UpgradeSpec{Force: true, CleanupOnFail: true, ReuseValues: true}
+			hr.Spec.DisableHooks = true
+			maxHistory := 5
+			hr.Spec.MaxHistory = &maxHistory
+			Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+			DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+			Eventually(func(g Gomega) {
+				mock.mu.Lock()
+				opts := mock.UpgradeArgs.Opts
+				mock.mu.Unlock()
+				g.Expect(opts.Force).To(BeTrue())
+				g.Expect(opts.CleanupOnFail).To(BeTrue())
+				g.Expect(opts.ReuseValues).To(BeTrue())
+				g.Expect(opts.ResetValues).To(BeFalse())
+				g.Expect(opts.MaxHistory).To(HaveValue(Equal(5)))
+				g.Expect(opts.DisableHooks).To(BeTrue())
+			}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+		})
+
+		It("rejects resetValues together with reuseValues", func() {
+			hr := makeHR("test-upgrade-values-conflict")
+			hr.Spec.Upgrade = &helmv1alpha1.UpgradeSpec{ResetValues: true, ReuseValues: true}
+			Expect(k8sClient.Create(ctx, hr)).To(MatchError(ContainSubstring("mutually exclusive")))
+		})
+
 		It("upgrades when release exists and generation mismatches", func() {
 			// ReleaseExists=true → first real reconcile sees gen(1) != observedGen(0) → Upgrade
 			mock := &MockHelmClient{ReleaseExistsResult: true}
@@ -286,6 +526,32 @@ var _ = Describe("HelmReleaseReconciler", func() {
 			}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
 		})
 
+		It("passes spec.uninstall to Helm", func() {
+			mock := &MockHelmClient{}
+			cancel := startManager(mock)
+			defer cancel()
+
+			hr := makeHR("test-delete-options")
+			hr.Spec.Uninstall = &helmv1alpha1.UninstallSpec{KeepHistory: true, DisableHooks: true, Wait: true}
+			Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+
+			Eventually(func(g Gomega) {
+				mock.mu.Lock()
+				called := mock.InstallCalled
+				mock.mu.Unlock()
+				g.Expect(called).To(BeTrue())
+			}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+
+			Expect(k8sClient.Delete(ctx, hr)).To(Succeed())
+
+			Eventually(func(g Gomega) {
+				mock.mu.Lock()
+				opts := mock.UninstallArgs.Opts
+				mock.mu.Unlock()
+				g.Expect(opts).To(Equal(controllers.UninstallOptions{KeepHistory: true, DisableHooks: true, Wait: true, Timeout: 5 * time.Minute}))
+			}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+		})
+
 		It("keeps finalizer and sets Phase=Failed when Uninstall errors", func() {
 			mock := &MockHelmClient{UninstallErr: errors.New("uninstall failed")}
 			cancel := startManager(mock)
@@ -379,4 +645,61 @@ var _ = Describe("HelmReleaseReconciler", func() {
 			}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
 		})
 	})
+
+	Describe("TTL", func() {
+		It("records expiresAt and deletes the release once the TTL elapses", func() {
+			mock := &MockHelmClient{}
+			cancel := startManager(mock)
+			defer cancel()
+
+			hr := makeHR("test-ttl")
+			hr.Spec.TTL = &metav1.Duration{Duration: 3 * time.Second}
+			Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+
+			Eventually(func(g Gomega) {
+				fetched, err := getHR(ctx, hr.Name)
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(fetched.Status.ExpiresAt).NotTo(BeNil())
+				g.Expect(fetched.Status.ExpiresAt.Time).To(BeTemporally("~",
+					fetched.CreationTimestamp.Add(3*time.Second), time.Second))
+			}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+
+			Eventually(func(g Gomega) {
+				mock.mu.Lock()
+				called := mock.UninstallCalled
+				mock.mu.Unlock()
+				g.Expect(called).To(BeTrue())
+			}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+
+			Eventually(func(g Gomega) {
+				_, err := getHR(ctx, hr.Name)
+				g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+			}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+		})
+	})
+
+	Describe("Validation", func() {
+		It("accepts exact versions and semver constraints", func() {
+			for i, version := range []string{"1.0.0", "v2.3", "1.2.3-rc.1+build.5", ">=1.2.0 <2.0.0", "~1.4", "1.x", "*"} {
+				hr := makeHR(fmt.Sprintf("test-valid-version-%d", i))
+				hr.Spec.Version = version
+				Expect(k8sClient.Create(ctx, hr)).To(Succeed(), version)
+				Expect(k8sClient.Delete(ctx, hr)).To(Succeed())
+			}
+		})
+
+		It("rejects malformed versions, release names and namespaces", func() {
+			hr := makeHR("test-invalid-version")
+			hr.Spec.Version = "latest"
+			Expect(apierrors.IsInvalid(k8sClient.Create(ctx, hr))).To(BeTrue())
+
+			hr = makeHR("test-invalid-release-name")
+			hr.Spec.ReleaseName = "My_Release"
+			Expect(apierrors.IsInvalid(k8sClient.Create(ctx, hr))).To(BeTrue())
+
+			hr = makeHR("test-invalid-target-namespace")
+			hr.Spec.TargetNamespace = "team.a"
+			Expect(apierrors.IsInvalid(k8sClient.Create(ctx, hr))).To(BeTrue())
+		})
+	})
 })
@@ -0,0 +1,52 @@
+package controllers
+
+import (
+	"context"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// DefaultStatusHistory is the number of revisions kept in status.history
+// unless configured otherwise.
+const DefaultStatusHistory = 10
+
+// recordHistory copies the newest revisions of the Helm release into
+// status.history and status.helmRevision. It runs after every install or
+// upgrade, whether it succeeded or not, since failed revisions are part of
+// the history too. A history that cannot be read is logged and the previous
+// one kept.
+func (r *HelmReleaseReconciler) recordHistory(ctx context.Context, release *helmv1alpha1.HelmRelease, helm HelmClientInterface, releaseName string) {
+	if r.StatusHistory <= 0 {
+		release.Status.History = nil
+		return
+	}
+	revisions, err := helm.History(ctx, releaseName, release.Spec.TargetNamespace)
+	if err != nil {
+		ctrl.LoggerFrom(ctx).Error(err, "Reading the release history", "releaseName", releaseName)
+		return
+	}
+	if len(revisions) > r.StatusHistory {
+		revisions = revisions[:r.StatusHistory]
+	}
+	history := make([]helmv1alpha1.RevisionStatus, 0, len(revisions))
+	for _, rel := range revisions {
+		entry := helmv1alpha1.RevisionStatus{Revision: rel.Version}
+		if rel.Chart != nil && rel.Chart.Metadata != nil {
+			entry.ChartVersion = rel.Chart.Metadata.Version
+		}
+		if rel.Info != nil {
+			entry.Status = rel.Info.Status.String()
+			entry.Description = rel.Info.Description
+			if !rel.Info.LastDeployed.IsZero() {
+				entry.DeployedAt = &metav1.Time{Time: rel.Info.LastDeployed.Time}
+			}
+		}
+		history = append(history, entry)
+	}
+	release.Status.History = history
+	if len(history) > 0 {
+		release.Status.HelmRevision = history[0].Revision
+	}
+}
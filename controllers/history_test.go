@@ -0,0 +1,55 @@
+package controllers_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"github.com/example/helm-operator/controllers"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+	helmtime "helm.sh/helm/v3/pkg/time"
+)
+
+var _ = Describe("Status history", func() {
+	ctx := context.Background()
+
+	revision := func(version int, chartVersion string, status release.Status) *release.Release {
+		return &release.Release{
+			Version: version,
+			Chart:   &chart.Chart{Metadata: &chart.Metadata{Name: "nginx", Version: chartVersion}},
+			Info: &release.Info{Status: status, Description: "Upgrade complete",
+				LastDeployed: helmtime.Time{Time: time.Date(2024, 3, 1, 3, version, 0, 0, time.UTC)}},
+		}
+	}
+
+	It("records the newest revisions, capped at StatusHistory", func() {
+		mock := &MockHelmClient{ReleaseExistsResult: true, HistoryResult: []*release.Release{
+			revision(3, "1.0.0", release.StatusDeployed),
+			revision(2, "0.9.0", release.StatusFailed),
+			revision(1, "0.9.0", release.StatusSuperseded),
+		}}
+		cancel := startManager(mock, func(r *controllers.HelmReleaseReconciler) { r.StatusHistory = 2 })
+		defer cancel()
+
+		hr := makeHR("test-status-history")
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+		Eventually(func(g Gomega) {
+			fetched, err := getHR(ctx, hr.Name)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(fetched.Status.Phase).To(Equal(helmv1alpha1.PhaseReady))
+			g.Expect(fetched.Status.HelmRevision).To(Equal(3))
+			g.Expect(fetched.Status.History).To(HaveLen(2))
+			g.Expect(fetched.Status.History[0].Revision).To(Equal(3))
+			g.Expect(fetched.Status.History[0].ChartVersion).To(Equal("1.0.0"))
+			g.Expect(fetched.Status.History[0].Status).To(Equal("deployed"))
+			g.Expect(fetched.Status.History[0].DeployedAt).NotTo(BeNil())
+			g.Expect(fetched.Status.History[1].Status).To(Equal("failed"))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+	})
+})
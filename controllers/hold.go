@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Annotations that put a quick hold on upgrades without editing the spec in
+// Git, e.g. during an incident. Status keeps being updated while held.
+const (
+	// AnnotationHold blocks upgrades while set to "true".
+	AnnotationHold = "helm.example.com/hold"
+	// AnnotationHoldUntil blocks upgrades until an RFC 3339 timestamp.
+	AnnotationHoldUntil = "helm.example.com/hold-until"
+)
+
+const conditionHeld = "Held"
+
+// releaseHold reports whether upgrades of release are on hold and, for a
+// hold-until annotation, when the hold expires. An unparsable hold-until
+// holds indefinitely: whoever set it meant to stop upgrades.
+func releaseHold(release *helmv1alpha1.HelmRelease, now time.Time) (held bool, until time.Time, reason string) {
+	if strings.EqualFold(release.Annotations[AnnotationHold], "true") {
+		return true, time.Time{}, fmt.Sprintf("Upgrades are held by the %s annotation", AnnotationHold)
+	}
+	value, ok := release.Annotations[AnnotationHoldUntil]
+	if !ok {
+		return false, time.Time{}, ""
+	}
+	until, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return true, time.Time{}, fmt.Sprintf("Upgrades are held: %s %q is not an RFC 3339 timestamp", AnnotationHoldUntil, value)
+	}
+	if !now.Before(until) {
+		return false, time.Time{}, ""
+	}
+	return true, until, fmt.Sprintf("Upgrades are held until %s by the %s annotation", until.Format(time.RFC3339), AnnotationHoldUntil)
+}
+
+// setHeld records that a pending upgrade was skipped because of a hold.
+func setHeld(release *helmv1alpha1.HelmRelease, reason string) {
+	setCondition(release, metav1.Condition{
+		Type:               conditionHeld,
+		Status:             metav1.ConditionTrue,
+		Reason:             "HoldAnnotation",
+		Message:            reason,
+		ObservedGeneration: release.Generation,
+	})
+}
+
+// clearHeld sets Held to False if an upgrade was held.
+func clearHeld(release *helmv1alpha1.HelmRelease) {
+	for _, c := range release.Status.Conditions {
+		if c.Type == conditionHeld && c.Status != metav1.ConditionFalse {
+			setCondition(release, metav1.Condition{
+				Type:               conditionHeld,
+				Status:             metav1.ConditionFalse,
+				Reason:             "Released",
+				Message:            "Upgrades are not held",
+				ObservedGeneration: release.Generation,
+			})
+			return
+		}
+	}
+}
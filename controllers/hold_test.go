@@ -0,0 +1,91 @@
+package controllers_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/example/helm-operator/controllers"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Hold annotations", func() {
+	ctx := context.Background()
+
+	// installAndHold installs a release, then bumps its version while
+	// setting the given annotation.
+	installAndHold := func(mock *MockHelmClient, name, annotation, value string) {
+		hr := makeHR(name)
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+		Eventually(func() bool {
+			mock.mu.Lock()
+			defer mock.mu.Unlock()
+			return mock.InstallCalled
+		}).WithTimeout(timeout).WithPolling(polling).Should(BeTrue())
+		mock.mu.Lock()
+		mock.ReleaseExistsResult = true
+		mock.mu.Unlock()
+
+		fetched, err := getHR(ctx, name)
+		Expect(err).NotTo(HaveOccurred())
+		patch := client.MergeFrom(fetched.DeepCopy())
+		fetched.Annotations = map[string]string{annotation: value}
+		fetched.Spec.Version = "2.0.0"
+		Expect(k8sClient.Patch(ctx, fetched, patch)).To(Succeed())
+	}
+
+	upgraded := func(mock *MockHelmClient) func() bool {
+		return func() bool {
+			mock.mu.Lock()
+			defer mock.mu.Unlock()
+			return mock.UpgradeCalled
+		}
+	}
+
+	It("blocks upgrades until the hold annotation is removed", func() {
+		mock := &MockHelmClient{}
+		cancel := startManager(mock)
+		defer cancel()
+
+		installAndHold(mock, "test-hold", controllers.AnnotationHold, "true")
+		Eventually(func(g Gomega) {
+			fetched, err := getHR(ctx, "test-hold")
+			g.Expect(err).NotTo(HaveOccurred())
+			cond := findCondition(fetched, "Held")
+			g.Expect(cond).NotTo(BeNil())
+			g.Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+		Consistently(upgraded(mock)).WithTimeout(time.Second).WithPolling(polling).Should(BeFalse())
+
+		fetched, err := getHR(ctx, "test-hold")
+		Expect(err).NotTo(HaveOccurred())
+		patch := client.MergeFrom(fetched.DeepCopy())
+		delete(fetched.Annotations, controllers.AnnotationHold)
+		Expect(k8sClient.Patch(ctx, fetched, patch)).To(Succeed())
+
+		Eventually(upgraded(mock)).WithTimeout(timeout).WithPolling(polling).Should(BeTrue())
+		Eventually(func(g Gomega) {
+			fetched, err := getHR(ctx, "test-hold")
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(fetched.Status.DeployedVersion).To(Equal("2.0.0"))
+			cond := findCondition(fetched, "Held")
+			g.Expect(cond).NotTo(BeNil())
+			g.Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+	})
+
+	It("upgrades once hold-until has passed", func() {
+		mock := &MockHelmClient{}
+		cancel := startManager(mock)
+		defer cancel()
+
+		until := time.Now().Add(3 * time.Second).UTC().Format(time.RFC3339)
+		installAndHold(mock, "test-hold-until", controllers.AnnotationHoldUntil, until)
+		Consistently(upgraded(mock)).WithTimeout(time.Second).WithPolling(polling).Should(BeFalse())
+		Eventually(upgraded(mock)).WithTimeout(timeout).WithPolling(polling).Should(BeTrue())
+	})
+})
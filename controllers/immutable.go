@@ -0,0 +1,104 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const conditionImmutableFieldChange = "ImmutableFieldChange"
+
+// ImmutableFieldError reports an upgrade that would change fields the API
+// server refuses to update. It is never tolerated by the failure budget:
+// retrying cannot succeed until the values or spec.upgrade.force change.
+type ImmutableFieldError struct {
+	Objects []ObjectImpact
+}
+
+func (e *ImmutableFieldError) Error() string {
+	return "upgrade would change immutable fields of " + describeImmutable(e.Objects) +
+		"; set spec.upgrade.force to delete and recreate them"
+}
+
+// describeImmutable lists objects with their changed immutable fields.
+func describeImmutable(objects []ObjectImpact) string {
+	parts := make([]string, 0, len(objects))
+	for _, o := range objects {
+		parts = append(parts, fmt.Sprintf("%s (%s)", o, strings.Join(o.ImmutableFields, ", ")))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// checkImmutableFields previews an upgrade of the release and looks for
+// objects whose immutable fields would change, which the upgrade would fail
+// on halfway through. Without spec.upgrade.force it records the
+// ImmutableFieldChange condition and returns an *ImmutableFieldError. With
+// force it returns the objects, which the upgrade deletes so it can create
+// them again.
+//
+// The preview renders the chart without post-renderers or hooks, so changes
+// they make are not seen. A preview that fails is logged and skipped; the
+// upgrade then reports the problem itself.
+func (r *HelmReleaseReconciler) checkImmutableFields(ctx context.Context, release *helmv1alpha1.HelmRelease, helm HelmClientInterface,
+	releaseName, repoURL string, values map[string]interface{}, fetch ChartFetchOptions) ([]ObjectImpact, error) {
+	impact, err := helm.Impact(ctx, releaseName, release.Spec.Chart, repoURL, release.Spec.Version,
+		release.Spec.TargetNamespace, values, fetch)
+	if err != nil {
+		ctrl.LoggerFrom(ctx).Error(err, "Previewing the upgrade for immutable field changes")
+		return nil, nil
+	}
+	var replaced []ObjectImpact
+	for _, o := range impact.Objects {
+		if len(o.ImmutableFields) > 0 {
+			replaced = append(replaced, o)
+		}
+	}
+	if len(replaced) == 0 {
+		clearImmutableFieldChange(release)
+		return nil, nil
+	}
+
+	if release.Spec.Upgrade == nil || !release.Spec.Upgrade.Force {
+		err := &ImmutableFieldError{Objects: replaced}
+		setCondition(release, metav1.Condition{
+			Type:               conditionImmutableFieldChange,
+			Status:             metav1.ConditionTrue,
+			Reason:             "ImmutableFieldChange",
+			Message:            err.Error(),
+			ObservedGeneration: release.Generation,
+		})
+		return nil, err
+	}
+	message := "Deleting and recreating " + describeImmutable(replaced) + " because spec.upgrade.force is set"
+	r.event(release, corev1.EventTypeWarning, "RecreatingResources", message)
+	setCondition(release, metav1.Condition{
+		Type:               conditionImmutableFieldChange,
+		Status:             metav1.ConditionFalse,
+		Reason:             "Recreated",
+		Message:            message,
+		ObservedGeneration: release.Generation,
+	})
+	return replaced, nil
+}
+
+// clearImmutableFieldChange marks a release whose upgrade was blocked by an
+// immutable field change as no longer blocked.
+func clearImmutableFieldChange(release *helmv1alpha1.HelmRelease) {
+	for _, c := range release.Status.Conditions {
+		if c.Type == conditionImmutableFieldChange && c.Status != metav1.ConditionFalse {
+			setCondition(release, metav1.Condition{
+				Type:               conditionImmutableFieldChange,
+				Status:             metav1.ConditionFalse,
+				Reason:             "NoImmutableFieldChange",
+				Message:            "The upgrade changes no immutable fields",
+				ObservedGeneration: release.Generation,
+			})
+			return
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package controllers_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"github.com/example/helm-operator/controllers"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Immutable field changes", func() {
+	ctx := context.Background()
+
+	service := controllers.ObjectImpact{APIVersion: "v1", Kind: "Service", Namespace: "default", Name: "web",
+		Change: controllers.ChangeModify, Field: "spec.clusterIP", ImmutableFields: []string{"spec.clusterIP"}}
+
+	It("fails the upgrade with the ImmutableFieldChange condition", func() {
+		mock := &MockHelmClient{ReleaseExistsResult: true,
+			ImpactResult: &controllers.ImpactSummary{Modified: 1, Replacements: 1, Objects: []controllers.ObjectImpact{service}}}
+		cancel := startManager(mock)
+		defer cancel()
+
+		hr := makeHR("test-immutable-fail")
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+		Eventually(func(g Gomega) {
+			fetched, err := getHR(ctx, hr.Name)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(fetched.Status.Phase).To(Equal(helmv1alpha1.PhaseFailed))
+			cond := findCondition(fetched, "ImmutableFieldChange")
+			g.Expect(cond).NotTo(BeNil())
+			g.Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+			g.Expect(cond.Message).To(ContainSubstring("Service default/web (spec.clusterIP)"))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+
+		mock.mu.Lock()
+		defer mock.mu.Unlock()
+		Expect(mock.UpgradeCalled).To(BeFalse())
+	})
+
+	It("recreates the objects with spec.upgrade.force", func() {
+		mock := &MockHelmClient{ReleaseExistsResult: true,
+			ImpactResult: &controllers.ImpactSummary{Modified: 1, Replacements: 1, Objects: []controllers.ObjectImpact{service}}}
+		cancel := startManager(mock)
+		defer cancel()
+
+		hr := makeHR("test-immutable-force")
+		hr.Spec.Upgrade = &helmv1alpha1.UpgradeSpec{Force: true}
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+		Eventually(func(g Gomega) {
+			fetched, err := getHR(ctx, hr.Name)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(fetched.Status.Phase).To(Equal(helmv1alpha1.PhaseReady))
+			cond := findCondition(fetched, "ImmutableFieldChange")
+			g.Expect(cond).NotTo(BeNil())
+			g.Expect(cond.Reason).To(Equal("Recreated"))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+
+		mock.mu.Lock()
+		defer mock.mu.Unlock()
+		Expect(mock.UpgradeArgs.Opts.Force).To(BeTrue())
+		Expect(mock.UpgradeArgs.Opts.Recreate).To(ConsistOf(service))
+	})
+})
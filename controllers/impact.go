@@ -0,0 +1,253 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/resource"
+)
+
+// Changes an upgrade makes to an object, as reported in ObjectImpact.
+const (
+	ChangeCreate = "Create"
+	ChangeModify = "Modify"
+	ChangeDelete = "Delete"
+)
+
+// ImpactSummary describes what installing or upgrading a release would
+// change in the cluster: the rendered manifests compared with the live
+// objects and with the manifests of the deployed revision.
+type ImpactSummary struct {
+	Created   int `json:"created"`
+	Modified  int `json:"modified"`
+	Deleted   int `json:"deleted"`
+	Unchanged int `json:"unchanged"`
+	// Restarts counts the modified workloads whose pod template changes,
+	// so their pods are replaced.
+	Restarts int `json:"restarts"`
+	// Replacements counts the modified objects whose immutable fields
+	// change. The API server rejects such updates; the object has to be
+	// deleted and created again.
+	Replacements int `json:"replacements"`
+	// Objects lists the created, modified and deleted objects.
+	Objects []ObjectImpact `json:"objects"`
+}
+
+// ObjectImpact is the change to one object.
+type ObjectImpact struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+	Change     string `json:"change"`
+	// Field is the first changed field of a modified object.
+	Field string `json:"field,omitempty"`
+	// Restart is set for workloads whose pods are replaced.
+	Restart bool `json:"restart,omitempty"`
+	// ImmutableFields lists the changed fields that cannot be updated.
+	ImmutableFields []string `json:"immutableFields,omitempty"`
+}
+
+func (o ObjectImpact) String() string {
+	if o.Namespace == "" {
+		return o.Kind + " " + o.Name
+	}
+	return o.Kind + " " + o.Namespace + "/" + o.Name
+}
+
+// add counts o and lists it.
+func (s *ImpactSummary) add(o ObjectImpact) {
+	switch o.Change {
+	case ChangeCreate:
+		s.Created++
+	case ChangeModify:
+		s.Modified++
+		if o.Restart {
+			s.Restarts++
+		}
+		if len(o.ImmutableFields) > 0 {
+			s.Replacements++
+		}
+	case ChangeDelete:
+		s.Deleted++
+	}
+	s.Objects = append(s.Objects, o)
+}
+
+// immutableFields lists, per kind, the fields the API server refuses to
+// update. StatefulSets are stricter still: every spec field other than
+// statefulSetMutableFields is immutable.
+var immutableFields = map[string][]string{
+	"Service":               {"spec.clusterIP"},
+	"Deployment":            {"spec.selector"},
+	"DaemonSet":             {"spec.selector"},
+	"ReplicaSet":            {"spec.selector"},
+	"Job":                   {"spec.selector", "spec.template"},
+	"PersistentVolumeClaim": {"spec.storageClassName", "spec.accessModes", "spec.volumeMode", "spec.volumeName"},
+}
+
+var statefulSetMutableFields = map[string]bool{
+	"replicas": true, "template": true, "updateStrategy": true, "minReadySeconds": true,
+	"persistentVolumeClaimRetentionPolicy": true, "ordinals": true,
+}
+
+// podTemplateKinds are the workloads that replace their pods when
+// spec.template changes.
+var podTemplateKinds = map[string]bool{"Deployment": true, "StatefulSet": true, "DaemonSet": true}
+
+// classifyChange compares the rendered object desired of kind with its live
+// version. It returns the first changed field, "" when there is none,
+// whether the change replaces pods and which immutable fields change.
+func classifyChange(kind string, desired, live map[string]interface{}) (field string, restart bool, immutable []string) {
+	field = firstDifference(driftRelevant(desired), live, "")
+	if field == "" {
+		return "", false, nil
+	}
+	changed := func(path string) bool {
+		fields := strings.Split(path, ".")
+		d, found, _ := unstructured.NestedFieldNoCopy(desired, fields...)
+		if !found || d == nil || d == "" {
+			return false
+		}
+		l, _, _ := unstructured.NestedFieldNoCopy(live, fields...)
+		return firstDifference(d, l, path) != ""
+	}
+
+	if podTemplateKinds[kind] {
+		restart = changed("spec.template")
+	}
+	paths := immutableFields[kind]
+	switch kind {
+	case "StatefulSet":
+		spec, _, _ := unstructured.NestedMap(desired, "spec")
+		for key := range spec {
+			if !statefulSetMutableFields[key] {
+				paths = append(paths, "spec."+key)
+			}
+		}
+	case "ConfigMap", "Secret":
+		if immutable, _, _ := unstructured.NestedBool(live, "immutable"); immutable {
+			paths = []string{"data", "binaryData", "stringData"}
+		}
+	}
+	for _, path := range paths {
+		if changed(path) {
+			immutable = append(immutable, path)
+		}
+	}
+	sort.Strings(immutable)
+	return field, restart, immutable
+}
+
+// Impact renders the chart as an upgrade of releaseName would and compares
+// the result with the live objects and with the deployed revision. Hooks
+// are left out: they run on every upgrade. Nothing is changed.
+func (h *HelmClient) Impact(ctx context.Context, releaseName, chartName, repoURL, version, namespace string,
+	values map[string]interface{}, opts ChartFetchOptions) (*ImpactSummary, error) {
+	cfg, err := h.actionConfig(namespace)
+	if err != nil {
+		return nil, err
+	}
+	current, err := action.NewGet(cfg).Run(releaseName)
+	if errors.Is(err, driver.ErrReleaseNotFound) {
+		current, err = nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	rendered, err := h.render(ctx, releaseName, chartName, repoURL, version, namespace, values, opts, current != nil)
+	if err != nil {
+		return nil, err
+	}
+	desired, err := cfg.KubeClient.Build(bytes.NewBufferString(rendered.Manifest), false)
+	if err != nil {
+		return nil, fmt.Errorf("parsing rendered manifests: %w", err)
+	}
+
+	summary := &ImpactSummary{Objects: []ObjectImpact{}}
+	seen := map[string]bool{}
+	err = desired.Visit(func(info *resource.Info, err error) error {
+		if err != nil {
+			return err
+		}
+		obj := ObjectImpact{APIVersion: info.Mapping.GroupVersionKind.GroupVersion().String(),
+			Kind: info.Mapping.GroupVersionKind.Kind, Namespace: info.Namespace, Name: info.Name}
+		seen[obj.String()] = true
+		live, err := resource.NewHelper(info.Client, info.Mapping).Get(info.Namespace, info.Name)
+		if apierrors.IsNotFound(err) {
+			obj.Change = ChangeCreate
+			summary.add(obj)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		desiredObj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(info.Object)
+		if err != nil {
+			return err
+		}
+		liveObj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(live)
+		if err != nil {
+			return err
+		}
+		obj.Field, obj.Restart, obj.ImmutableFields = classifyChange(obj.Kind, desiredObj, liveObj)
+		if obj.Field == "" {
+			summary.Unchanged++
+			return nil
+		}
+		obj.Change = ChangeModify
+		summary.add(obj)
+		return nil
+	})
+	if err != nil || current == nil {
+		return summary, err
+	}
+
+	deployed, err := cfg.KubeClient.Build(bytes.NewBufferString(current.Manifest), false)
+	if err != nil {
+		return nil, fmt.Errorf("parsing deployed manifests: %w", err)
+	}
+	err = deployed.Visit(func(info *resource.Info, err error) error {
+		if err != nil {
+			return err
+		}
+		obj := ObjectImpact{APIVersion: info.Mapping.GroupVersionKind.GroupVersion().String(),
+			Kind: info.Mapping.GroupVersionKind.Kind, Namespace: info.Namespace, Name: info.Name, Change: ChangeDelete}
+		if !seen[obj.String()] {
+			summary.add(obj)
+		}
+		return nil
+	})
+	return summary, err
+}
+
+// deleteObjects deletes objects through cfg, ignoring those already gone.
+func deleteObjects(cfg *action.Configuration, objects []ObjectImpact) error {
+	if len(objects) == 0 {
+		return nil
+	}
+	var manifest strings.Builder
+	for _, o := range objects {
+		fmt.Fprintf(&manifest, "---\napiVersion: %s\nkind: %s\nmetadata:\n  name: %q\n", o.APIVersion, o.Kind, o.Name)
+		if o.Namespace != "" {
+			fmt.Fprintf(&manifest, "  namespace: %q\n", o.Namespace)
+		}
+	}
+	resources, err := cfg.KubeClient.Build(strings.NewReader(manifest.String()), false)
+	if err != nil {
+		return err
+	}
+	if _, errs := cfg.KubeClient.Delete(resources); len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package controllers
+
+import (
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+)
+
+// impersonatedUser returns the user Helm acts as for release: its
+// spec.serviceAccountName, or DefaultServiceAccount, in the HelmRelease's
+// namespace. Empty means the operator's own identity, or for a release with
+// spec.kubeConfig the kubeconfig's, as the ServiceAccounts of this cluster
+// do not exist in the remote one.
+func (r *HelmReleaseReconciler) impersonatedUser(release *helmv1alpha1.HelmRelease) string {
+	name := r.impersonatedServiceAccount(release)
+	if name == "" {
+		return ""
+	}
+	return "system:serviceaccount:" + release.Namespace + ":" + name
+}
+
+// impersonatedServiceAccount returns the name of the ServiceAccount in the
+// HelmRelease's namespace that impersonatedUser names, or "".
+func (r *HelmReleaseReconciler) impersonatedServiceAccount(release *helmv1alpha1.HelmRelease) string {
+	if release.Spec.KubeConfig != nil {
+		return ""
+	}
+	if name := release.Spec.ServiceAccountName; name != "" {
+		return name
+	}
+	return r.DefaultServiceAccount
+}
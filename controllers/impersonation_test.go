@@ -0,0 +1,55 @@
+package controllers_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/example/helm-operator/controllers"
+)
+
+var _ = Describe("Impersonation", func() {
+	ctx := context.Background()
+
+	It("runs Helm as the release's ServiceAccount", func() {
+		mock := &MockHelmClient{}
+		cancel := startManager(mock, func(r *controllers.HelmReleaseReconciler) {
+			r.DefaultServiceAccount = "helm-default"
+		})
+		defer cancel()
+
+		hr := makeHR("test-impersonate")
+		hr.Spec.ServiceAccountName = "tenant-deployer"
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		Eventually(func() string {
+			mock.mu.Lock()
+			defer mock.mu.Unlock()
+			return mock.InstallArgs.Opts.Impersonate
+		}).WithTimeout(timeout).WithPolling(polling).Should(Equal("system:serviceaccount:default:tenant-deployer"))
+
+		Expect(k8sClient.Delete(ctx, hr)).To(Succeed())
+		Eventually(func() string {
+			mock.mu.Lock()
+			defer mock.mu.Unlock()
+			return mock.UninstallArgs.Opts.Impersonate
+		}).WithTimeout(timeout).WithPolling(polling).Should(Equal("system:serviceaccount:default:tenant-deployer"))
+	})
+
+	It("falls back to the default ServiceAccount", func() {
+		mock := &MockHelmClient{}
+		cancel := startManager(mock, func(r *controllers.HelmReleaseReconciler) {
+			r.DefaultServiceAccount = "helm-default"
+		})
+		defer cancel()
+
+		hr := makeHR("test-impersonate-default")
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+		Eventually(func() string {
+			mock.mu.Lock()
+			defer mock.mu.Unlock()
+			return mock.InstallArgs.Opts.Impersonate
+		}).WithTimeout(timeout).WithPolling(polling).Should(Equal("system:serviceaccount:default:helm-default"))
+	})
+})
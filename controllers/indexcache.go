@@ -0,0 +1,228 @@
+package controllers
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"helm.sh/helm/v3/pkg/repo"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/yaml"
+)
+
+// DefaultIndexCacheSize bounds the repository indexes kept in memory when
+// IndexCache.MaxBytes is unset.
+const DefaultIndexCacheSize = 256 << 20
+
+// IndexCache keeps parsed repository indexes in memory and revalidates them
+// with ETag and If-Modified-Since, so an unchanged index is neither
+// downloaded nor parsed again. Large indexes take seconds to parse and tens
+// of megabytes to download; update checks and version resolution poll them
+// for every release. The least recently used indexes are dropped once their
+// downloaded sizes add up to more than MaxBytes.
+type IndexCache struct {
+	// MaxBytes bounds the total size of the cached index files. Defaults to
+	// DefaultIndexCacheSize.
+	MaxBytes int64
+
+	mu    sync.Mutex
+	size  int64
+	lru   *list.List // of *indexEntry, most recently used first
+	byKey map[indexKey]*list.Element
+}
+
+// indexKey identifies a cached index. The proxy is part of it since
+// different proxies may serve different content, and the user since the
+// index of a private repository is only for those who may read it.
+type indexKey struct {
+	url   string
+	proxy string
+	user  string
+}
+
+type indexEntry struct {
+	key          indexKey
+	index        *repo.IndexFile
+	etag         string
+	lastModified string
+	size         int64
+}
+
+func (c *IndexCache) maxBytes() int64 {
+	if c.MaxBytes <= 0 {
+		return DefaultIndexCacheSize
+	}
+	return c.MaxBytes
+}
+
+func (c *IndexCache) lookup(key indexKey) *indexEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.byKey[key]
+	if !ok {
+		return nil
+	}
+	c.lru.MoveToFront(el)
+	return el.Value.(*indexEntry)
+}
+
+func (c *IndexCache) store(e *indexEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.byKey == nil {
+		c.byKey = map[indexKey]*list.Element{}
+		c.lru = list.New()
+	}
+	if el, ok := c.byKey[e.key]; ok {
+		c.size -= el.Value.(*indexEntry).size
+		c.lru.Remove(el)
+	}
+	if e.size > c.maxBytes() {
+		delete(c.byKey, e.key)
+		return // would evict everything else and still not fit
+	}
+	c.byKey[e.key] = c.lru.PushFront(e)
+	c.size += e.size
+	for c.size > c.maxBytes() {
+		oldest := c.lru.Back()
+		evicted := oldest.Value.(*indexEntry)
+		c.lru.Remove(oldest)
+		delete(c.byKey, evicted.key)
+		c.size -= evicted.size
+	}
+}
+
+// repoIndex returns the parsed index of repoURL. With an IndexCache, a
+// cached index is revalidated with the repository instead of downloaded
+// again.
+func (h *HelmClient) repoIndex(ctx context.Context, repoURL string, opts ChartFetchOptions) (*repo.IndexFile, error) {
+	transport, err := h.repoTransport(opts)
+	if err != nil {
+		return nil, err
+	}
+	httpClient := &http.Client{Transport: transport, Timeout: h.Download.Timeout}
+	key := indexKey{url: strings.TrimSuffix(repoURL, "/") + "/index.yaml", proxy: opts.ProxyURL}
+	if opts.Credentials != nil {
+		key.user = opts.Credentials.Username
+	}
+
+	var cached *indexEntry
+	if h.Indexes != nil {
+		cached = h.Indexes.lookup(key)
+	}
+
+	backoff := h.Download.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		entry, err := fetchIndex(ctx, httpClient, key, cached, h.Download.userAgent(), opts.Credentials)
+		if err == nil {
+			if h.Indexes != nil && entry != cached {
+				h.Indexes.store(entry)
+			}
+			return entry.index, nil
+		}
+		if attempt >= h.Download.Retries || ctx.Err() != nil {
+			return nil, fmt.Errorf("downloading index: %w", err)
+		}
+		ctrl.Log.WithName("chart-fetch").V(1).Info("Retrying download", "url", key.url, "attempt", attempt+1, "error", err.Error())
+		if !waitBackoff(ctx, backoff) {
+			return nil, fmt.Errorf("downloading index: %w", err)
+		}
+		backoff *= 2
+	}
+}
+
+// fetchIndex downloads and parses the index, or returns cached when the
+// repository reports it unchanged.
+func fetchIndex(ctx context.Context, c *http.Client, key indexKey, cached *indexEntry, userAgent string,
+	creds *RepoCredentials) (*indexEntry, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, key.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	if creds != nil && creds.Username != "" {
+		req.SetBasicAuth(creds.Username, creds.Password)
+	}
+	if cached != nil {
+		if cached.etag != "" {
+			req.Header.Set("If-None-Match", cached.etag)
+		}
+		if cached.lastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.lastModified)
+		}
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cached != nil:
+		indexDownloads.WithLabelValues("not_modified").Inc()
+		return cached, nil
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("%s returned %s", key.url, resp.Status)
+	}
+	indexDownloads.WithLabelValues("downloaded").Inc()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	index, err := parseIndex(data)
+	if err != nil {
+		return nil, fmt.Errorf("loading index: %w", err)
+	}
+	return &indexEntry{
+		key:          key,
+		index:        index,
+		etag:         resp.Header.Get("ETag"),
+		lastModified: resp.Header.Get("Last-Modified"),
+		size:         int64(len(data)),
+	}, nil
+}
+
+// parseIndex parses an index.yaml the way repo.LoadIndexFile does, without
+// going through a file.
+func parseIndex(data []byte) (*repo.IndexFile, error) {
+	index := &repo.IndexFile{}
+	if err := yaml.Unmarshal(data, index); err != nil {
+		return nil, err
+	}
+	if index.APIVersion == "" {
+		return nil, repo.ErrNoAPIVersion
+	}
+	for name, versions := range index.Entries {
+		valid := versions[:0]
+		for _, cv := range versions {
+			if cv == nil || cv.Metadata == nil || cv.Validate() != nil {
+				continue
+			}
+			valid = append(valid, cv)
+		}
+		index.Entries[name] = valid
+	}
+	index.SortEntries()
+	return index, nil
+}
+
+// findChartInIndex resolves chartName and the version constraint to a chart
+// archive URL, like repo.FindChartInRepoURL but with the cached index.
+func (h *HelmClient) findChartInIndex(ctx context.Context, repoURL, chartName, version string, opts ChartFetchOptions) (string, error) {
+	index, err := h.repoIndex(ctx, repoURL, opts)
+	if err != nil {
+		return "", err
+	}
+	cv, err := index.Get(chartName, version)
+	if err != nil {
+		return "", fmt.Errorf("%s not found in %s repository: %w", chartName, repoURL, err)
+	}
+	if len(cv.URLs) == 0 {
+		return "", fmt.Errorf("%s %s has no downloadable URLs", chartName, cv.Version)
+	}
+	return repo.ResolveReferenceURL(repoURL, cv.URLs[0])
+}
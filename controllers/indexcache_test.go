@@ -0,0 +1,138 @@
+package controllers_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/example/helm-operator/controllers"
+)
+
+const testIndex = `apiVersion: v1
+entries:
+  nginx:
+  - name: nginx
+    version: 1.1.0
+    urls: [charts/nginx-1.1.0.tgz]
+  - name: nginx
+    version: 1.2.0
+    urls: [charts/nginx-1.2.0.tgz]
+  - name: nginx
+    version: 1.3.0-rc.1
+    urls: [charts/nginx-1.3.0-rc.1.tgz]
+`
+
+// indexServer serves testIndex with an ETag and counts full downloads.
+func indexServer(downloads *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		atomic.AddInt32(downloads, 1)
+		w.Write([]byte(testIndex))
+	}))
+}
+
+var _ = Describe("Repository index cache", func() {
+	ctx := context.Background()
+
+	It("revalidates a cached index instead of downloading it again", func() {
+		var downloads int32
+		repo := indexServer(&downloads)
+		defer repo.Close()
+
+		hc := controllers.NewHelmClient(cfg)
+		hc.Indexes = &controllers.IndexCache{}
+		for i := 0; i < 3; i++ {
+			latest, err := hc.LatestVersions(ctx, repo.URL, controllers.ChartFetchOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(latest).To(Equal(map[string]string{"nginx": "1.2.0"}))
+		}
+		Expect(atomic.LoadInt32(&downloads)).To(BeEquivalentTo(1))
+	})
+
+	It("downloads the index every time without a cache", func() {
+		var downloads int32
+		repo := indexServer(&downloads)
+		defer repo.Close()
+
+		hc := controllers.NewHelmClient(cfg)
+		for i := 0; i < 2; i++ {
+			_, err := hc.LatestVersions(ctx, repo.URL, controllers.ChartFetchOptions{})
+			Expect(err).NotTo(HaveOccurred())
+		}
+		Expect(atomic.LoadInt32(&downloads)).To(BeEquivalentTo(2))
+	})
+
+	It("evicts the least recently used index beyond its size bound", func() {
+		var downloadsA, downloadsB int32
+		repoA, repoB := indexServer(&downloadsA), indexServer(&downloadsB)
+		defer repoA.Close()
+		defer repoB.Close()
+
+		hc := controllers.NewHelmClient(cfg)
+		hc.Indexes = &controllers.IndexCache{MaxBytes: int64(len(testIndex)) + 1}
+		for _, url := range []string{repoA.URL, repoB.URL, repoA.URL} {
+			_, err := hc.LatestVersions(ctx, url, controllers.ChartFetchOptions{})
+			Expect(err).NotTo(HaveOccurred())
+		}
+		Expect(atomic.LoadInt32(&downloadsA)).To(BeEquivalentTo(2))
+		Expect(atomic.LoadInt32(&downloadsB)).To(BeEquivalentTo(1))
+	})
+})
+
+var _ = Describe("Download retries", func() {
+	// flakyServer serves testIndex unless failIndex is set, and fails every
+	// chart download, counting the requests that failed.
+	flakyServer := func(failIndex bool, failures *int32) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !failIndex && strings.HasSuffix(r.URL.Path, "/index.yaml") {
+				w.Write([]byte(testIndex))
+				return
+			}
+			atomic.AddInt32(failures, 1)
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		}))
+	}
+	newClient := func() *controllers.HelmClient {
+		hc := controllers.NewHelmClient(cfg)
+		hc.Download = controllers.DownloadConfig{Retries: 5, RetryBackoff: time.Hour}
+		return hc
+	}
+
+	It("stops retrying an index download when the context ends", func() {
+		var failures int32
+		repo := flakyServer(true, &failures)
+		defer repo.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		_, err := newClient().LatestVersions(ctx, repo.URL, controllers.ChartFetchOptions{})
+		Expect(err).To(MatchError(ContainSubstring("downloading index")))
+		Expect(time.Since(start)).To(BeNumerically("<", 5*time.Second))
+		Expect(atomic.LoadInt32(&failures)).To(BeEquivalentTo(1))
+	})
+
+	It("stops retrying a chart download when the context ends", func() {
+		var failures int32
+		repo := flakyServer(false, &failures)
+		defer repo.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		_, err := newClient().LoadChart(ctx, "nginx", repo.URL, "1.2.0", controllers.ChartFetchOptions{})
+		Expect(err).To(HaveOccurred())
+		Expect(time.Since(start)).To(BeNumerically("<", 5*time.Second))
+		Expect(atomic.LoadInt32(&failures)).To(BeEquivalentTo(1))
+	})
+})
@@ -0,0 +1,42 @@
+package controllers_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Reconcile interval", func() {
+	ctx := context.Background()
+
+	It("re-verifies a ready release every spec.interval", func() {
+		mock := &MockHelmClient{}
+		cancel := startManager(mock)
+		defer cancel()
+
+		hr := makeHR("test-interval")
+		hr.Spec.Interval = &metav1.Duration{Duration: 300 * time.Millisecond}
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+		Eventually(func(g Gomega) {
+			fetched, err := getHR(ctx, hr.Name)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(fetched.Status.Phase).To(Equal(helmv1alpha1.PhaseReady))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+
+		// The mock keeps reporting no release, as if it had been uninstalled
+		// behind the operator's back. Nothing about the HelmRelease changes,
+		// so only the interval brings the operator back to reinstall it.
+		Eventually(func() int {
+			mock.mu.Lock()
+			defer mock.mu.Unlock()
+			return len(mock.Installed)
+		}).WithTimeout(timeout).WithPolling(polling).Should(BeNumerically(">=", 2))
+	})
+})
@@ -0,0 +1,191 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"github.com/example/helm-operator/notify"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// AnnotationIncidentIssue is set by the IssueFiler to the URL of the ticket
+// it opened for a release that stayed Failed, and removed once the release
+// recovers so the next incident gets a ticket of its own.
+const AnnotationIncidentIssue = "helm.example.com/incident-issue"
+
+// IssueFiler is a controller-runtime Runnable that opens a ticket when a
+// HelmRelease stays Failed for longer than After. One ticket is opened per
+// incident: the release is annotated with the ticket's URL, and releases
+// carrying the annotation are skipped until they recover.
+type IssueFiler struct {
+	Client  client.Client
+	Tracker notify.Tracker
+	// After is how long a release must have been Failed.
+	After time.Duration
+	// Interval between scans. Defaults to a minute.
+	Interval time.Duration
+	// UIURL is the external URL of the web UI, linked from tickets when set.
+	UIURL string
+	// Diagnosis returns the latest diagnosis of a release, if any, to
+	// include in its ticket.
+	Diagnosis func(ctx context.Context, namespace, name string) (string, error)
+	// Now defaults to time.Now.
+	Now func() time.Time
+
+	mu sync.Mutex
+	// filed remembers tickets whose annotation could not be written, so a
+	// failing patch does not open a ticket on every scan.
+	filed map[types.NamespacedName]string
+}
+
+// Start implements manager.Runnable.
+func (f *IssueFiler) Start(ctx context.Context) error {
+	interval := f.Interval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		f.scan(ctx)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (f *IssueFiler) scan(ctx context.Context) {
+	log := ctrl.Log.WithName("issue-filer")
+
+	var list helmv1alpha1.HelmReleaseList
+	if err := f.Client.List(ctx, &list); err != nil {
+		log.Error(err, "listing HelmReleases")
+		return
+	}
+	now := time.Now()
+	if f.Now != nil {
+		now = f.Now()
+	}
+	for i := range list.Items {
+		hr := &list.Items[i]
+		key := types.NamespacedName{Namespace: hr.Namespace, Name: hr.Name}
+		_, annotated := hr.Annotations[AnnotationIncidentIssue]
+
+		since, failed := failedSince(hr)
+		if !failed {
+			f.forget(key)
+			if annotated {
+				if err := f.annotate(ctx, hr, nil); err != nil {
+					log.Error(err, "clearing incident issue", "name", hr.Name, "namespace", hr.Namespace)
+				}
+			}
+			continue
+		}
+		if annotated || now.Sub(since) < f.After {
+			continue
+		}
+
+		f.mu.Lock()
+		issueURL, filed := f.filed[key]
+		f.mu.Unlock()
+		if !filed {
+			var err error
+			issueURL, err = f.Tracker.Open(ctx, f.issue(ctx, hr, since))
+			if err != nil {
+				log.Error(err, "opening incident issue", "name", hr.Name, "namespace", hr.Namespace)
+				continue
+			}
+			log.Info("Opened incident issue", "name", hr.Name, "namespace", hr.Namespace, "issue", issueURL)
+			f.mu.Lock()
+			if f.filed == nil {
+				f.filed = map[types.NamespacedName]string{}
+			}
+			f.filed[key] = issueURL
+			f.mu.Unlock()
+		}
+		if err := f.annotate(ctx, hr, issueURL); err != nil {
+			log.Error(err, "annotating incident issue", "name", hr.Name, "namespace", hr.Namespace)
+			continue
+		}
+		f.forget(key)
+	}
+}
+
+// failedSince reports whether hr is Failed and since when.
+func failedSince(hr *helmv1alpha1.HelmRelease) (time.Time, bool) {
+	if hr.Status.Phase != helmv1alpha1.PhaseFailed {
+		return time.Time{}, false
+	}
+	ready := meta.FindStatusCondition(hr.Status.Conditions, "Ready")
+	if ready == nil || ready.Status != "False" {
+		return time.Time{}, false
+	}
+	return ready.LastTransitionTime.Time, true
+}
+
+// issue describes the incident of hr.
+func (f *IssueFiler) issue(ctx context.Context, hr *helmv1alpha1.HelmRelease, since time.Time) notify.Issue {
+	var b strings.Builder
+	fmt.Fprintf(&b, "HelmRelease %s/%s (chart %s %s) has been Failed since %s.\n\n",
+		hr.Namespace, hr.Name, hr.Spec.Chart, hr.Spec.Version, since.UTC().Format(time.RFC3339))
+	class := "Unknown"
+	if a := hr.Status.LastAttempt; a != nil && a.ErrorClass != "" {
+		class = a.ErrorClass
+	}
+	fmt.Fprintf(&b, "Failure class: %s\n", class)
+	fmt.Fprintf(&b, "Consecutive failures: %d\n", hr.Status.ConsecutiveFailures)
+	if ready := meta.FindStatusCondition(hr.Status.Conditions, "Ready"); ready != nil {
+		fmt.Fprintf(&b, "Error: %s\n", ready.Message)
+	}
+	if f.Diagnosis != nil {
+		text, err := f.Diagnosis(ctx, hr.Namespace, hr.Name)
+		if err != nil {
+			ctrl.Log.WithName("issue-filer").Error(err, "loading diagnosis", "name", hr.Name, "namespace", hr.Namespace)
+		} else if text != "" {
+			fmt.Fprintf(&b, "\nDiagnosis:\n%s\n", text)
+		}
+	}
+	if f.UIURL != "" {
+		q := url.Values{"ns": {hr.Namespace}, "name": {hr.Name}}.Encode()
+		base := strings.TrimSuffix(f.UIURL, "/")
+		fmt.Fprintf(&b, "\nUI: %s/\nHistory: %s/api/helmreleases/history?%s\n", base, base, q)
+	}
+	return notify.Issue{
+		Title:  fmt.Sprintf("HelmRelease %s/%s is failing", hr.Namespace, hr.Name),
+		Body:   b.String(),
+		Labels: []string{"helm-operator", class},
+	}
+}
+
+// annotate sets AnnotationIncidentIssue on hr, or removes it when value is
+// nil.
+func (f *IssueFiler) annotate(ctx context.Context, hr *helmv1alpha1.HelmRelease, value interface{}) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{AnnotationIncidentIssue: value},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	obj := &helmv1alpha1.HelmRelease{}
+	obj.Name, obj.Namespace = hr.Name, hr.Namespace
+	return client.IgnoreNotFound(f.Client.Patch(ctx, obj, client.RawPatch(types.MergePatchType, patch)))
+}
+
+func (f *IssueFiler) forget(key types.NamespacedName) {
+	f.mu.Lock()
+	delete(f.filed, key)
+	f.mu.Unlock()
+}
@@ -0,0 +1,118 @@
+package controllers_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"github.com/example/helm-operator/controllers"
+	"github.com/example/helm-operator/notify"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// fakeTracker records the issues it is asked to open.
+type fakeTracker struct {
+	mu     sync.Mutex
+	issues []notify.Issue
+}
+
+func (t *fakeTracker) Open(_ context.Context, issue notify.Issue) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.issues = append(t.issues, issue)
+	return fmt.Sprintf("https://tracker.example.com/%d", len(t.issues)), nil
+}
+
+func (t *fakeTracker) opened() []notify.Issue {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]notify.Issue(nil), t.issues...)
+}
+
+var _ = Describe("IssueFiler", func() {
+	ctx := context.Background()
+
+	// setPhase retries on conflicts with the filer's annotation patches.
+	setPhase := func(hr *helmv1alpha1.HelmRelease, phase helmv1alpha1.Phase, since time.Time) {
+		Eventually(func(g Gomega) {
+			fetched, err := getHR(ctx, hr.Name)
+			g.Expect(err).NotTo(HaveOccurred())
+			fetched.Status.Phase = phase
+			status := metav1.ConditionTrue
+			fetched.Status.LastAttempt = nil
+			if phase == helmv1alpha1.PhaseFailed {
+				status = metav1.ConditionFalse
+				fetched.Status.LastAttempt = &helmv1alpha1.LastAttempt{
+					Operation: "Upgrade", Outcome: "Failed", ErrorClass: "Permanent", Error: "image pull failed",
+					StartedAt: metav1.Now(),
+				}
+			}
+			meta.RemoveStatusCondition(&fetched.Status.Conditions, "Ready")
+			fetched.Status.Conditions = append(fetched.Status.Conditions, metav1.Condition{
+				Type: "Ready", Status: status, Reason: string(phase), Message: "image pull failed",
+				LastTransitionTime: metav1.NewTime(since),
+			})
+			g.Expect(k8sClient.Status().Update(ctx, fetched)).To(Succeed())
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+	}
+
+	It("opens one issue per incident of a release that stays Failed", func() {
+		hr := makeHR("test-issue-filer")
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+		tracker := &fakeTracker{}
+		filer := &controllers.IssueFiler{
+			Client:   k8sClient,
+			Tracker:  tracker,
+			After:    time.Hour,
+			Interval: 50 * time.Millisecond,
+			UIURL:    "https://helm.example.com",
+			Diagnosis: func(_ context.Context, ns, name string) (string, error) {
+				return "The image tag does not exist.", nil
+			},
+		}
+		filerCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		go func() {
+			defer GinkgoRecover()
+			Expect(filer.Start(filerCtx)).To(Succeed())
+		}()
+
+		By("ignoring failures younger than the threshold")
+		setPhase(hr, helmv1alpha1.PhaseFailed, time.Now())
+		Consistently(tracker.opened, "300ms", polling).Should(BeEmpty())
+
+		By("filing once the release has been Failed long enough")
+		setPhase(hr, helmv1alpha1.PhaseFailed, time.Now().Add(-2*time.Hour))
+		Eventually(func(g Gomega) {
+			fetched, err := getHR(ctx, hr.Name)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(fetched.Annotations).To(HaveKeyWithValue(controllers.AnnotationIncidentIssue, "https://tracker.example.com/1"))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+		Consistently(tracker.opened, "300ms", polling).Should(HaveLen(1))
+		issue := tracker.opened()[0]
+		Expect(issue.Title).To(ContainSubstring(hr.Name))
+		Expect(issue.Body).To(ContainSubstring("Failure class: Permanent"))
+		Expect(issue.Body).To(ContainSubstring("The image tag does not exist."))
+		Expect(issue.Body).To(ContainSubstring("https://helm.example.com/api/helmreleases/history?"))
+
+		By("clearing the annotation once the release recovers")
+		setPhase(hr, helmv1alpha1.PhaseReady, time.Now())
+		Eventually(func(g Gomega) {
+			fetched, err := getHR(ctx, hr.Name)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(fetched.Annotations).NotTo(HaveKey(controllers.AnnotationIncidentIssue))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+
+		By("filing a new issue for the next incident")
+		setPhase(hr, helmv1alpha1.PhaseFailed, time.Now().Add(-2*time.Hour))
+		Eventually(tracker.opened).WithTimeout(timeout).WithPolling(polling).Should(HaveLen(2))
+	})
+})
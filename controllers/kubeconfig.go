@@ -0,0 +1,137 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// defaultKubeConfigKey is the Secret key read when spec.kubeConfig.secretRef
+// names none.
+const defaultKubeConfigKey = "value"
+
+// helmClientFor returns the Helm client deploying release: HelmClient, or
+// for a release with spec.kubeConfig one for the remote cluster.
+func (r *HelmReleaseReconciler) helmClientFor(ctx context.Context, release *helmv1alpha1.HelmRelease) (HelmClientInterface, error) {
+	return r.helmClientForCluster(ctx, release.Namespace, release.Spec.KubeConfig)
+}
+
+// helmClientForCluster returns the Helm client for the cluster of kc, a
+// kubeconfig reference of a HelmRelease in namespace; HelmClient when kc is
+// nil.
+func (r *HelmReleaseReconciler) helmClientForCluster(ctx context.Context, namespace string, kc *helmv1alpha1.KubeConfigReference) (HelmClientInterface, error) {
+	if kc == nil {
+		return r.HelmClient, nil
+	}
+	if r.RemoteHelmClient == nil {
+		return nil, errors.New("spec.kubeConfig is not supported by this operator")
+	}
+	cfg, err := r.remoteConfig(ctx, namespace, kc)
+	if err != nil {
+		return nil, err
+	}
+	return r.RemoteHelmClient(cfg), nil
+}
+
+// lockerFor returns the locker guarding releases in the cluster of kc, a
+// kubeconfig reference of a HelmRelease in namespace: Locker for this
+// cluster, otherwise one keeping its Leases in the remote cluster, next to
+// Helm's release records there. Releases of the same name and namespace in
+// different clusters thus get different locks, and tools running helm
+// against the remote cluster find the Lease where they expect it.
+func (r *HelmReleaseReconciler) lockerFor(ctx context.Context, namespace string, kc *helmv1alpha1.KubeConfigReference) (*ReleaseLocker, error) {
+	if r.Locker == nil || kc == nil {
+		return r.Locker, nil
+	}
+	cfg, err := r.remoteConfig(ctx, namespace, kc)
+	if err != nil {
+		return nil, err
+	}
+	c, err := client.New(cfg, client.Options{Scheme: r.Scheme})
+	if err != nil {
+		return nil, fmt.Errorf("connecting to the cluster of kubeconfig Secret %s: %w", kc.SecretRef.Name, err)
+	}
+	return r.Locker.ForCluster(c), nil
+}
+
+// clusterKey identifies the cluster release deploys to: empty for this
+// cluster, otherwise its kubeconfig Secret. It keeps state kept per target
+// namespace, such as the upgrade barrier, apart between clusters.
+func clusterKey(release *helmv1alpha1.HelmRelease) string {
+	if release.Spec.KubeConfig == nil {
+		return ""
+	}
+	return "kubeconfig:" + release.Namespace + "/" + release.Spec.KubeConfig.SecretRef.Name + "/"
+}
+
+// sameCluster reports whether the kubeconfig references a and b select the
+// same Secret key, or are both nil for this cluster.
+func sameCluster(a, b *helmv1alpha1.KubeConfigReference) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.SecretRef.Name == b.SecretRef.Name && kubeConfigKey(a) == kubeConfigKey(b)
+}
+
+func kubeConfigKey(kc *helmv1alpha1.KubeConfigReference) string {
+	if kc.SecretRef.Key == "" {
+		return defaultKubeConfigKey
+	}
+	return kc.SecretRef.Key
+}
+
+// remoteConfig reads the kubeconfig kc refers to in namespace.
+func (r *HelmReleaseReconciler) remoteConfig(ctx context.Context, namespace string, kc *helmv1alpha1.KubeConfigReference) (*rest.Config, error) {
+	ref := kc.SecretRef
+	key := kubeConfigKey(kc)
+	var secret corev1.Secret
+	if err := r.Get(ctx, types.NamespacedName{Namespace: namespace, Name: ref.Name}, &secret); err != nil {
+		return nil, fmt.Errorf("reading kubeconfig Secret %s: %w", ref.Name, err)
+	}
+	data, ok := secret.Data[key]
+	if !ok {
+		return nil, fmt.Errorf("kubeconfig Secret %s has no key %s", ref.Name, key)
+	}
+	loaded, err := clientcmd.Load(data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing kubeconfig in Secret %s: %w", ref.Name, err)
+	}
+	if err := checkKubeConfig(loaded); err != nil {
+		return nil, fmt.Errorf("kubeconfig in Secret %s: %w", ref.Name, err)
+	}
+	cfg, err := clientcmd.NewDefaultClientConfig(*loaded, &clientcmd.ConfigOverrides{}).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("kubeconfig in Secret %s: %w", ref.Name, err)
+	}
+	return cfg, nil
+}
+
+// checkKubeConfig rejects kubeconfigs that would run commands or read files
+// in the operator's pod; whoever can write the Secret could otherwise use
+// the operator's identity and credentials.
+func checkKubeConfig(kc *clientcmdapi.Config) error {
+	for name, user := range kc.AuthInfos {
+		switch {
+		case user.Exec != nil:
+			return fmt.Errorf("user %s: exec credential plugins are not allowed", name)
+		case user.AuthProvider != nil:
+			return fmt.Errorf("user %s: auth providers are not allowed", name)
+		case user.TokenFile != "" || user.ClientCertificate != "" || user.ClientKey != "":
+			return fmt.Errorf("user %s: credentials must be inline, not files", name)
+		}
+	}
+	for name, cluster := range kc.Clusters {
+		if cluster.CertificateAuthority != "" {
+			return fmt.Errorf("cluster %s: the certificate authority must be inline, not a file", name)
+		}
+	}
+	return nil
+}
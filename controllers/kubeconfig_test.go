@@ -0,0 +1,192 @@
+package controllers_test
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"github.com/example/helm-operator/controllers"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const remoteKubeConfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: remote
+  cluster:
+    server: https://remote.example.com:6443
+users:
+- name: deployer
+  user:
+    token: secret-token
+contexts:
+- name: remote
+  context:
+    cluster: remote
+    user: deployer
+current-context: remote
+`
+
+var _ = Describe("Remote clusters", func() {
+	ctx := context.Background()
+
+	// withRemote makes the reconciler deploy releases with spec.kubeConfig
+	// through remote, recording the hosts it was given.
+	withRemote := func(remote *MockHelmClient, hosts *[]string, mu *sync.Mutex) func(*controllers.HelmReleaseReconciler) {
+		return func(r *controllers.HelmReleaseReconciler) {
+			r.RemoteHelmClient = func(cfg *rest.Config) controllers.HelmClientInterface {
+				mu.Lock()
+				defer mu.Unlock()
+				*hosts = append(*hosts, cfg.Host)
+				return remote
+			}
+		}
+	}
+
+	createKubeConfig := func(name, data string) {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Data:       map[string][]byte{"value": []byte(data)},
+		}
+		Expect(k8sClient.Create(ctx, secret)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, secret) })
+	}
+
+	It("installs and uninstalls the release in the remote cluster", func() {
+		local, remote := &MockHelmClient{}, &MockHelmClient{}
+		var mu sync.Mutex
+		var hosts []string
+		cancel := startManager(local, withRemote(remote, &hosts, &mu))
+		defer cancel()
+
+		createKubeConfig("remote-kubeconfig", remoteKubeConfig)
+		hr := makeHR("test-remote")
+		hr.Spec.ServiceAccountName = "tenant-deployer"
+		hr.Spec.KubeConfig = &helmv1alpha1.KubeConfigReference{
+			SecretRef: helmv1alpha1.KubeConfigSecretRef{Name: "remote-kubeconfig"},
+		}
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+
+		Eventually(func() bool {
+			remote.mu.Lock()
+			defer remote.mu.Unlock()
+			return remote.InstallCalled
+		}).WithTimeout(timeout).WithPolling(polling).Should(BeTrue())
+		remote.mu.Lock()
+		Expect(remote.InstallArgs.Opts.Impersonate).To(BeEmpty())
+		remote.mu.Unlock()
+		mu.Lock()
+		Expect(hosts).To(ContainElement("https://remote.example.com:6443"))
+		mu.Unlock()
+
+		Expect(k8sClient.Delete(ctx, hr)).To(Succeed())
+		Eventually(func() bool {
+			remote.mu.Lock()
+			defer remote.mu.Unlock()
+			return remote.UninstallCalled
+		}).WithTimeout(timeout).WithPolling(polling).Should(BeTrue())
+
+		local.mu.Lock()
+		defer local.mu.Unlock()
+		Expect(local.InstallCalled).To(BeFalse())
+		Expect(local.UninstallCalled).To(BeFalse())
+	})
+
+	It("uninstalls from the old cluster when kubeConfig changes with allowRelocation", func() {
+		local, remote := &MockHelmClient{}, &MockHelmClient{}
+		var mu sync.Mutex
+		var hosts []string
+		cancel := startManager(local, withRemote(remote, &hosts, &mu))
+		defer cancel()
+
+		createKubeConfig("moved-kubeconfig", remoteKubeConfig)
+		hr := makeHR("test-remote-moved")
+		hr.Spec.AllowRelocation = true
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+		Eventually(func(g Gomega) {
+			fetched, err := getHR(ctx, hr.Name)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(fetched.Status.Phase).To(Equal(helmv1alpha1.PhaseReady))
+			g.Expect(fetched.Status.ReleaseName).To(Equal(hr.Name))
+			g.Expect(fetched.Status.ReleaseKubeConfig).To(BeNil())
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+
+		fetched, err := getHR(ctx, hr.Name)
+		Expect(err).NotTo(HaveOccurred())
+		patch := client.MergeFrom(fetched.DeepCopy())
+		fetched.Spec.KubeConfig = &helmv1alpha1.KubeConfigReference{
+			SecretRef: helmv1alpha1.KubeConfigSecretRef{Name: "moved-kubeconfig"},
+		}
+		Expect(k8sClient.Patch(ctx, fetched, patch)).To(Succeed())
+
+		Eventually(func(g Gomega) {
+			fetched, err := getHR(ctx, hr.Name)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(fetched.Status.ReleaseKubeConfig).NotTo(BeNil())
+			g.Expect(fetched.Status.ReleaseKubeConfig.SecretRef.Name).To(Equal("moved-kubeconfig"))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+		local.mu.Lock()
+		Expect(local.UninstallArgs).To(Equal(UninstallCallArgs{ReleaseName: hr.Name, Namespace: testNS}))
+		local.mu.Unlock()
+		remote.mu.Lock()
+		defer remote.mu.Unlock()
+		Expect(remote.InstallCalled).To(BeTrue())
+		Expect(remote.UninstallCalled).To(BeFalse())
+	})
+
+	It("rejects kubeconfigs that run commands", func() {
+		local, remote := &MockHelmClient{}, &MockHelmClient{}
+		var mu sync.Mutex
+		var hosts []string
+		cancel := startManager(local, withRemote(remote, &hosts, &mu))
+		defer cancel()
+
+		createKubeConfig("exec-kubeconfig", `apiVersion: v1
+kind: Config
+clusters:
+- name: remote
+  cluster:
+    server: https://remote.example.com:6443
+users:
+- name: deployer
+  user:
+    exec:
+      apiVersion: client.authentication.k8s.io/v1
+      command: /bin/sh
+contexts:
+- name: remote
+  context:
+    cluster: remote
+    user: deployer
+current-context: remote
+`)
+		hr := makeHR("test-remote-exec")
+		hr.Spec.KubeConfig = &helmv1alpha1.KubeConfigReference{
+			SecretRef: helmv1alpha1.KubeConfigSecretRef{Name: "exec-kubeconfig"},
+		}
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+		Eventually(func(g Gomega) {
+			fetched, err := getHR(ctx, hr.Name)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(fetched.Status.Phase).To(Equal(helmv1alpha1.PhaseFailed))
+			ready := findCondition(fetched, "Ready")
+			g.Expect(ready).NotTo(BeNil())
+			g.Expect(ready.Message).To(ContainSubstring("exec credential plugins are not allowed"))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+		Consistently(func() bool {
+			remote.mu.Lock()
+			defer remote.mu.Unlock()
+			return remote.InstallCalled
+		}).WithTimeout(time.Second).WithPolling(polling).Should(BeFalse())
+	})
+})
@@ -0,0 +1,174 @@
+package controllers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/yaml"
+)
+
+// kustomizePostRenderer applies the patches of a spec.postRenderers
+// kustomize entry to the rendered manifests.
+type kustomizePostRenderer struct {
+	spec *helmv1alpha1.KustomizePostRenderer
+}
+
+// manifestObject is one document of the rendered manifests, as JSON.
+type manifestObject struct {
+	data []byte
+	gvk  schema.GroupVersionKind
+	name string
+	ns   string
+}
+
+func (k *kustomizePostRenderer) Run(rendered *bytes.Buffer) (*bytes.Buffer, error) {
+	objects, err := splitManifests(rendered.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	for i, patch := range k.spec.PatchesStrategicMerge {
+		if err := strategicMerge(objects, patch.Raw); err != nil {
+			return nil, fmt.Errorf("patchesStrategicMerge[%d]: %w", i, err)
+		}
+	}
+	for i, patch := range k.spec.PatchesJSON6902 {
+		if err := applyJSON6902(objects, patch); err != nil {
+			return nil, fmt.Errorf("patchesJson6902[%d]: %w", i, err)
+		}
+	}
+
+	out := &bytes.Buffer{}
+	for _, obj := range objects {
+		data, err := yaml.JSONToYAML(obj.data)
+		if err != nil {
+			return nil, err
+		}
+		out.WriteString("---\n")
+		out.Write(data)
+	}
+	return out, nil
+}
+
+// splitManifests parses a stream of YAML documents, skipping empty ones.
+func splitManifests(data []byte) ([]*manifestObject, error) {
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(data)))
+	var objects []*manifestObject
+	for {
+		doc, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return objects, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading rendered manifests: %w", err)
+		}
+		obj, err := parseManifestObject(doc)
+		if err != nil {
+			return nil, err
+		}
+		if obj != nil {
+			objects = append(objects, obj)
+		}
+	}
+}
+
+// parseManifestObject parses a YAML or JSON object. It returns nil for an
+// empty document.
+func parseManifestObject(doc []byte) (*manifestObject, error) {
+	data, err := yaml.YAMLToJSON(doc)
+	if err != nil {
+		return nil, fmt.Errorf("parsing rendered manifest: %w", err)
+	}
+	if len(bytes.TrimSpace(data)) == 0 || string(data) == "null" {
+		return nil, nil
+	}
+	var u unstructured.Unstructured
+	if err := u.UnmarshalJSON(data); err != nil {
+		return nil, fmt.Errorf("parsing rendered manifest: %w", err)
+	}
+	return &manifestObject{data: data, gvk: u.GroupVersionKind(), name: u.GetName(), ns: u.GetNamespace()}, nil
+}
+
+// strategicMerge merges patch into the object it names. Kinds client-go
+// knows are merged by their patch strategy; others, such as custom
+// resources, have none and are merged as JSON merge patches.
+func strategicMerge(objects []*manifestObject, patch []byte) error {
+	target, err := parseManifestObject(patch)
+	if err != nil {
+		return err
+	}
+	if target == nil || target.gvk.Kind == "" || target.name == "" {
+		return errors.New("a patch needs apiVersion, kind and metadata.name")
+	}
+	matched := false
+	for _, obj := range objects {
+		if obj.gvk != target.gvk || obj.name != target.name || target.ns != "" && obj.ns != target.ns {
+			continue
+		}
+		var merged []byte
+		if typed, err := clientgoscheme.Scheme.New(obj.gvk); err == nil {
+			merged, err = strategicpatch.StrategicMergePatch(obj.data, target.data, typed)
+			if err != nil {
+				return fmt.Errorf("patching %s %s: %w", obj.gvk.Kind, obj.name, err)
+			}
+		} else {
+			merged, err = jsonpatch.MergePatch(obj.data, target.data)
+			if err != nil {
+				return fmt.Errorf("patching %s %s: %w", obj.gvk.Kind, obj.name, err)
+			}
+		}
+		obj.data = merged
+		matched = true
+	}
+	if !matched {
+		return fmt.Errorf("no rendered %s %s to patch", target.gvk.Kind, target.name)
+	}
+	return nil
+}
+
+// applyJSON6902 applies p to every object its target selects.
+func applyJSON6902(objects []*manifestObject, p helmv1alpha1.JSON6902Patch) error {
+	ops, err := json.Marshal(p.Patch)
+	if err != nil {
+		return err
+	}
+	patch, err := jsonpatch.DecodePatch(ops)
+	if err != nil {
+		return fmt.Errorf("invalid patch: %w", err)
+	}
+	matched := false
+	for _, obj := range objects {
+		if !targetMatches(p.Target, obj) {
+			continue
+		}
+		patched, err := patch.Apply(obj.data)
+		if err != nil {
+			return fmt.Errorf("patching %s %s: %w", obj.gvk.Kind, obj.name, err)
+		}
+		obj.data = patched
+		matched = true
+	}
+	if !matched {
+		return errors.New("the target selects no rendered object")
+	}
+	return nil
+}
+
+// targetMatches reports whether t selects obj. Empty fields match anything.
+func targetMatches(t helmv1alpha1.PatchTarget, obj *manifestObject) bool {
+	return (t.Group == "" || t.Group == obj.gvk.Group) &&
+		(t.Version == "" || t.Version == obj.gvk.Version) &&
+		(t.Kind == "" || t.Kind == obj.gvk.Kind) &&
+		(t.Name == "" || t.Name == obj.name) &&
+		(t.Namespace == "" || t.Namespace == obj.ns)
+}
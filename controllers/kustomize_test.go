@@ -0,0 +1,122 @@
+package controllers_test
+
+import (
+	"bytes"
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"helm.sh/helm/v3/pkg/postrender"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+const kustomizeManifests = `---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: default
+spec:
+  template:
+    spec:
+      containers:
+      - name: web
+        image: nginx:1.25
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web-config
+  namespace: default
+data:
+  mode: prod
+`
+
+var _ = Describe("Kustomize post-renderer", func() {
+	ctx := context.Background()
+
+	// postRendererFor installs a release with the given kustomize entry and
+	// returns the post-renderer the controller passed to Helm.
+	postRendererFor := func(name string, spec *helmv1alpha1.KustomizePostRenderer) postrender.PostRenderer {
+		mock := &MockHelmClient{}
+		cancel := startManager(mock)
+		DeferCleanup(cancel)
+
+		hr := makeHR(name)
+		hr.Spec.PostRenderers = []helmv1alpha1.PostRenderer{{Kustomize: spec}}
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+		var pr postrender.PostRenderer
+		Eventually(func(g Gomega) {
+			mock.mu.Lock()
+			pr = mock.InstallArgs.Opts.PostRenderer
+			mock.mu.Unlock()
+			g.Expect(pr).NotTo(BeNil())
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+		return pr
+	}
+
+	run := func(pr postrender.PostRenderer) (string, error) {
+		out, err := pr.Run(bytes.NewBufferString(kustomizeManifests))
+		if err != nil {
+			return "", err
+		}
+		return out.String(), nil
+	}
+
+	It("merges strategic-merge patches by container name", func() {
+		pr := postRendererFor("test-kustomize-smp", &helmv1alpha1.KustomizePostRenderer{
+			PatchesStrategicMerge: []apiextensionsv1.JSON{{Raw: []byte(`{
+				"apiVersion": "apps/v1", "kind": "Deployment", "metadata": {"name": "web"},
+				"spec": {"template": {"spec": {"containers": [{"name": "proxy", "image": "envoy:1.28"}]}}}}`)}},
+		})
+
+		out, err := run(pr)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out).To(ContainSubstring("image: nginx:1.25"))
+		Expect(out).To(ContainSubstring("image: envoy:1.28"))
+		Expect(out).To(ContainSubstring("mode: prod"))
+	})
+
+	It("applies JSON6902 patches to the objects their target selects", func() {
+		pr := postRendererFor("test-kustomize-json6902", &helmv1alpha1.KustomizePostRenderer{
+			PatchesJSON6902: []helmv1alpha1.JSON6902Patch{{
+				Target: helmv1alpha1.PatchTarget{Kind: "ConfigMap", Name: "web-config"},
+				Patch: []helmv1alpha1.JSON6902{
+					{Op: "replace", Path: "/data/mode", Value: &apiextensionsv1.JSON{Raw: []byte(`"staging"`)}},
+					{Op: "add", Path: "/metadata/labels", Value: &apiextensionsv1.JSON{Raw: []byte(`{"team":"payments"}`)}},
+				},
+			}},
+		})
+
+		out, err := run(pr)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out).To(ContainSubstring("mode: staging"))
+		Expect(out).To(ContainSubstring("team: payments"))
+		Expect(out).To(ContainSubstring("image: nginx:1.25"))
+	})
+
+	It("fails when a target selects no rendered object", func() {
+		pr := postRendererFor("test-kustomize-nomatch", &helmv1alpha1.KustomizePostRenderer{
+			PatchesJSON6902: []helmv1alpha1.JSON6902Patch{{
+				Target: helmv1alpha1.PatchTarget{Kind: "Secret"},
+				Patch:  []helmv1alpha1.JSON6902{{Op: "remove", Path: "/data"}},
+			}},
+		})
+
+		_, err := run(pr)
+		Expect(err).To(MatchError(ContainSubstring("selects no rendered object")))
+	})
+
+	It("fails on a patch that cannot be applied", func() {
+		pr := postRendererFor("test-kustomize-invalid", &helmv1alpha1.KustomizePostRenderer{
+			PatchesStrategicMerge: []apiextensionsv1.JSON{{Raw: []byte(`{"metadata": {"labels": {"team": "payments"}}}`)}},
+		})
+
+		_, err := run(pr)
+		Expect(err).To(MatchError(ContainSubstring("patchesStrategicMerge[0]")))
+	})
+})
@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"time"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Operations and outcomes reported in status.lastAttempt.
+const (
+	operationInstall   = "Install"
+	operationUpgrade   = "Upgrade"
+	operationUninstall = "Uninstall"
+	operationReconcile = "Reconcile"
+
+	outcomeSucceeded = "Succeeded"
+	outcomeFailed    = "Failed"
+)
+
+// beginAttempt starts a new status.lastAttempt for the reconcile of release
+// that starts now.
+func beginAttempt(release *helmv1alpha1.HelmRelease, operation string, now time.Time) {
+	release.Status.LastAttempt = &helmv1alpha1.LastAttempt{Operation: operation, StartedAt: metav1.NewTime(now)}
+}
+
+// setAttemptOperation records that the attempt ran Helm.
+func setAttemptOperation(release *helmv1alpha1.HelmRelease, operation string) {
+	if release.Status.LastAttempt != nil {
+		release.Status.LastAttempt.Operation = operation
+	}
+}
+
+// failAttempt records that the attempt failed with err.
+func failAttempt(release *helmv1alpha1.HelmRelease, err error) {
+	if a := release.Status.LastAttempt; a != nil {
+		a.Outcome = outcomeFailed
+		a.ErrorClass = errorClass(err)
+		a.Error = err.Error()
+	}
+}
+
+// endAttempt completes the current attempt. An attempt no failure was
+// recorded for succeeded unless the reconcile returned err.
+func endAttempt(release *helmv1alpha1.HelmRelease, err error, now time.Time) {
+	a := release.Status.LastAttempt
+	if a == nil {
+		return
+	}
+	a.Duration = metav1.Duration{Duration: now.Sub(a.StartedAt.Time).Round(time.Millisecond)}
+	switch {
+	case a.Outcome != "":
+	case err != nil:
+		failAttempt(release, err)
+	default:
+		a.Outcome = outcomeSucceeded
+	}
+}
+
+// errorClass tells failures retrying may fix from those it will not.
+func errorClass(err error) string {
+	switch {
+	case apierrors.IsForbidden(err) || len(missingPermissions(err)) > 0:
+		return "Forbidden"
+	case isTransient(err):
+		return "Transient"
+	}
+	return "Permanent"
+}
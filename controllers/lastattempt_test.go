@@ -0,0 +1,58 @@
+package controllers_test
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+)
+
+var _ = Describe("Last attempt", func() {
+	ctx := context.Background()
+
+	lastAttempt := func(g Gomega, name string) *helmv1alpha1.LastAttempt {
+		fetched, err := getHR(ctx, name)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(fetched.Status.LastAttempt).NotTo(BeNil())
+		return fetched.Status.LastAttempt
+	}
+
+	It("records a successful install", func() {
+		mock := &MockHelmClient{}
+		cancel := startManager(mock)
+		defer cancel()
+
+		hr := makeHR("test-last-attempt-ok")
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+		Eventually(func(g Gomega) {
+			a := lastAttempt(g, hr.Name)
+			g.Expect(a.Operation).To(Equal("Install"))
+			g.Expect(a.Outcome).To(Equal("Succeeded"))
+			g.Expect(a.StartedAt.IsZero()).To(BeFalse())
+			g.Expect(a.ErrorClass).To(BeEmpty())
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+	})
+
+	It("records a failed install with its error class", func() {
+		mock := &MockHelmClient{InstallErr: errors.New("template: nginx/templates/deployment.yaml:12: nil pointer")}
+		cancel := startManager(mock)
+		defer cancel()
+
+		hr := makeHR("test-last-attempt-failed")
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+		Eventually(func(g Gomega) {
+			a := lastAttempt(g, hr.Name)
+			g.Expect(a.Operation).To(Equal("Install"))
+			g.Expect(a.Outcome).To(Equal("Failed"))
+			g.Expect(a.ErrorClass).To(Equal("Permanent"))
+			g.Expect(a.Error).To(ContainSubstring("nil pointer"))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+	})
+})
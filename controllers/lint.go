@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/lint/support"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const conditionLintWarnings = "LintWarnings"
+
+// Lint runs helm lint against the chart with the given values and returns
+// the warning and error findings, formatted like the helm CLI.
+func (h *HelmClient) Lint(ctx context.Context, chartName, repoURL, version string, values map[string]interface{},
+	opts ChartFetchOptions) ([]string, error) {
+	dest, err := os.MkdirTemp("", "helm-operator-lint-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dest)
+
+	path, _, err := h.downloadChart(ctx, chartName, repoURL, version, opts, dest)
+	if err != nil {
+		return nil, err
+	}
+	result := action.NewLint().Run([]string{path}, values)
+	var findings []string
+	for _, msg := range result.Messages {
+		if msg.Severity >= support.WarningSev {
+			findings = append(findings, msg.Error())
+		}
+	}
+	return findings, nil
+}
+
+// lintRelease lints the chart about to be installed or upgraded and records
+// the findings in the LintWarnings condition. Findings never block the
+// operation; a chart that cannot be linted leaves the condition unchanged.
+func (r *HelmReleaseReconciler) lintRelease(ctx context.Context, release *helmv1alpha1.HelmRelease, repoURL string,
+	values map[string]interface{}, fetch ChartFetchOptions) {
+	findings, err := r.HelmClient.Lint(ctx, release.Spec.Chart, repoURL, release.Spec.Version, values, fetch)
+	if err != nil {
+		ctrl.LoggerFrom(ctx).Info("Skipping chart lint", "error", err.Error())
+		return
+	}
+	condition := metav1.Condition{
+		Type:               conditionLintWarnings,
+		Status:             metav1.ConditionFalse,
+		Reason:             "LintPassed",
+		Message:            "helm lint reported no warnings",
+		ObservedGeneration: release.Generation,
+	}
+	if len(findings) > 0 {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "LintWarnings"
+		condition.Message = fmt.Sprintf("helm lint: %s", strings.Join(findings, "; "))
+	}
+	setCondition(release, condition)
+}
@@ -0,0 +1,35 @@
+package controllers_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Chart lint", func() {
+	ctx := context.Background()
+
+	It("records lint findings without blocking the install", func() {
+		mock := &MockHelmClient{LintResult: []string{"[WARNING] templates/: missing resources"}}
+		cancel := startManager(mock)
+		defer cancel()
+
+		hr := makeHR("test-lint-warnings")
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+		Eventually(func(g Gomega) {
+			fetched, err := getHR(ctx, hr.Name)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(fetched.Status.Phase).To(Equal(helmv1alpha1.PhaseReady))
+			cond := findCondition(fetched, "LintWarnings")
+			g.Expect(cond).NotTo(BeNil())
+			g.Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+			g.Expect(cond.Message).To(ContainSubstring("missing resources"))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+	})
+})
@@ -0,0 +1,221 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	conditionLockedByOther = "LockedByOther"
+
+	// LockLeasePrefix prefixes the name of the Lease guarding a Helm release.
+	// The Lease lives in the release's namespace, next to Helm's own storage.
+	LockLeasePrefix = "helm-release-"
+
+	defaultLockDuration = time.Minute
+	requeueWhenLocked   = 15 * time.Second
+)
+
+// ReleaseLocker serializes Helm operations on a release between this
+// operator, the helm CLI and CI pipelines through an advisory
+// coordination.k8s.io Lease per release. Other tools take part by holding
+// the Lease named LockLeasePrefix+<release> while they run helm. A Lease
+// whose renewTime is older than its leaseDurationSeconds is considered
+// abandoned and may be taken over.
+//
+// A nil *ReleaseLocker performs no locking.
+type ReleaseLocker struct {
+	// Client must read Leases from the API server, not a cache.
+	Client client.Client
+	// Identity is written to holderIdentity, typically the pod name.
+	Identity string
+	// Duration is the lease duration; the lease is renewed every third of it
+	// while the operation runs. Defaults to one minute.
+	Duration time.Duration
+}
+
+// ForCluster returns a locker keeping its Leases in the cluster of c, for
+// releases Helm deploys there.
+func (l *ReleaseLocker) ForCluster(c client.Client) *ReleaseLocker {
+	if l == nil {
+		return nil
+	}
+	remote := *l
+	remote.Client = c
+	return &remote
+}
+
+// LockedError reports that another holder owns the release lock.
+type LockedError struct {
+	Holder string
+	Until  time.Time
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("release is locked by %s until %s", e.Holder, e.Until.UTC().Format(time.RFC3339))
+}
+
+func (l *ReleaseLocker) duration() time.Duration {
+	if l.Duration <= 0 {
+		return defaultLockDuration
+	}
+	return l.Duration
+}
+
+// Lock acquires the lock of the release and keeps renewing it until the
+// returned unlock function is called. It returns a *LockedError when the
+// lock is held by someone else.
+func (l *ReleaseLocker) Lock(ctx context.Context, namespace, releaseName string) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	key := types.NamespacedName{Namespace: namespace, Name: LockLeasePrefix + releaseName}
+	if err := l.acquire(ctx, key); err != nil {
+		return nil, err
+	}
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(l.duration() / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if err := l.acquire(context.Background(), key); err != nil {
+					ctrl.Log.WithName("release-lock").Error(err, "Renewing release lock", "lease", key)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		wg.Wait()
+		l.release(context.Background(), key)
+	}, nil
+}
+
+// acquire creates the lease, or takes over or renews it when it is free,
+// expired or already ours. Conflicting writes mean another holder won.
+func (l *ReleaseLocker) acquire(ctx context.Context, key types.NamespacedName) error {
+	now := metav1.NewMicroTime(time.Now())
+	seconds := int32(l.duration() / time.Second)
+
+	var lease coordinationv1.Lease
+	err := l.Client.Get(ctx, key, &lease)
+	if apierrors.IsNotFound(err) {
+		lease = coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &l.Identity,
+				LeaseDurationSeconds: &seconds,
+				AcquireTime:          &now,
+				RenewTime:            &now,
+			},
+		}
+		err = l.Client.Create(ctx, &lease)
+		if apierrors.IsAlreadyExists(err) {
+			return &LockedError{Holder: "another client", Until: now.Add(l.duration())}
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	if holder, until, held := leaseHolder(&lease); held && holder != l.Identity {
+		return &LockedError{Holder: holder, Until: until}
+	}
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity != l.Identity {
+		lease.Spec.AcquireTime = &now
+	}
+	lease.Spec.HolderIdentity = &l.Identity
+	lease.Spec.LeaseDurationSeconds = &seconds
+	lease.Spec.RenewTime = &now
+	if err := l.Client.Update(ctx, &lease); apierrors.IsConflict(err) {
+		return &LockedError{Holder: "another client", Until: now.Add(l.duration())}
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Forget deletes the lock Lease of an uninstalled release.
+func (l *ReleaseLocker) Forget(ctx context.Context, namespace, releaseName string) error {
+	if l == nil {
+		return nil
+	}
+	lease := &coordinationv1.Lease{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: LockLeasePrefix + releaseName}}
+	return client.IgnoreNotFound(l.Client.Delete(ctx, lease))
+}
+
+// release gives up the lease so other tools need not wait for it to expire.
+func (l *ReleaseLocker) release(ctx context.Context, key types.NamespacedName) {
+	var lease coordinationv1.Lease
+	if err := l.Client.Get(ctx, key, &lease); err != nil {
+		return
+	}
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity != l.Identity {
+		return
+	}
+	lease.Spec.HolderIdentity = nil
+	if err := l.Client.Update(ctx, &lease); err != nil && !apierrors.IsNotFound(err) {
+		ctrl.Log.WithName("release-lock").Error(err, "Releasing release lock", "lease", key)
+	}
+}
+
+// leaseHolder returns the holder of an unexpired lease.
+func leaseHolder(lease *coordinationv1.Lease) (string, time.Time, bool) {
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity == "" || lease.Spec.RenewTime == nil {
+		return "", time.Time{}, false
+	}
+	duration := defaultLockDuration
+	if lease.Spec.LeaseDurationSeconds != nil {
+		duration = time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second
+	}
+	until := lease.Spec.RenewTime.Add(duration)
+	return *lease.Spec.HolderIdentity, until, time.Now().Before(until)
+}
+
+// setLockedByOther records that the release lock is held elsewhere.
+func setLockedByOther(release *helmv1alpha1.HelmRelease, err *LockedError) {
+	setCondition(release, metav1.Condition{
+		Type:               conditionLockedByOther,
+		Status:             metav1.ConditionTrue,
+		Reason:             "LeaseHeld",
+		Message:            err.Error(),
+		ObservedGeneration: release.Generation,
+	})
+}
+
+// clearLockedByOther marks the lock as acquired if it was previously held
+// elsewhere.
+func clearLockedByOther(release *helmv1alpha1.HelmRelease) {
+	for _, c := range release.Status.Conditions {
+		if c.Type == conditionLockedByOther && c.Status != metav1.ConditionFalse {
+			setCondition(release, metav1.Condition{
+				Type:               conditionLockedByOther,
+				Status:             metav1.ConditionFalse,
+				Reason:             "LeaseAcquired",
+				Message:            "The release lock is held by this operator",
+				ObservedGeneration: release.Generation,
+			})
+			return
+		}
+	}
+}
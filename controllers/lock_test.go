@@ -0,0 +1,81 @@
+package controllers_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/example/helm-operator/controllers"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ = Describe("Release locks", func() {
+	ctx := context.Background()
+
+	withLocker := func(r *controllers.HelmReleaseReconciler) {
+		r.Locker = &controllers.ReleaseLocker{Client: k8sClient, Identity: "helm-operator/test"}
+	}
+
+	It("defers Helm operations while another client holds the lease", func() {
+		holder := "ci-pipeline"
+		seconds := int32(3600)
+		now := metav1.NewMicroTime(time.Now())
+		lease := &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{Name: controllers.LockLeasePrefix + "test-lock-held", Namespace: testNS},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &holder,
+				LeaseDurationSeconds: &seconds,
+				RenewTime:            &now,
+			},
+		}
+		Expect(k8sClient.Create(ctx, lease)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, lease) })
+
+		mock := &MockHelmClient{}
+		cancel := startManager(mock, withLocker)
+		defer cancel()
+
+		hr := makeHR("test-lock-held")
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+		Eventually(func(g Gomega) {
+			fetched, err := getHR(ctx, hr.Name)
+			g.Expect(err).NotTo(HaveOccurred())
+			cond := findCondition(fetched, "LockedByOther")
+			g.Expect(cond).NotTo(BeNil())
+			g.Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+			g.Expect(cond.Message).To(ContainSubstring("ci-pipeline"))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+
+		mock.mu.Lock()
+		defer mock.mu.Unlock()
+		Expect(mock.InstallCalled).To(BeFalse())
+	})
+
+	It("takes and releases the lease around an install", func() {
+		mock := &MockHelmClient{}
+		cancel := startManager(mock, withLocker)
+		defer cancel()
+
+		hr := makeHR("test-lock-free")
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+		Eventually(func(g Gomega) {
+			var lease coordinationv1.Lease
+			key := types.NamespacedName{Name: controllers.LockLeasePrefix + hr.Name, Namespace: testNS}
+			g.Expect(k8sClient.Get(ctx, key, &lease)).To(Succeed())
+			g.Expect(lease.Spec.AcquireTime).NotTo(BeNil())
+			g.Expect(lease.Spec.HolderIdentity).To(BeNil())
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+
+		mock.mu.Lock()
+		defer mock.mu.Unlock()
+		Expect(mock.InstallCalled).To(BeTrue())
+	})
+})
@@ -3,6 +3,10 @@ package controllers_test
 import (
 	"context"
 	"sync"
+
+	"github.com/example/helm-operator/controllers"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
 )
 
 // InstallCallArgs captures arguments from the last Install call.
@@ -13,6 +17,7 @@ type InstallCallArgs struct {
 	Version     string
 	Namespace   string
 	Values      map[string]interface{}
+	Opts        controllers.InstallOptions
 }
 
 // UpgradeCallArgs captures arguments from the last Upgrade call.
@@ -23,12 +28,14 @@ type UpgradeCallArgs struct {
 	Version     string
 	Namespace   string
 	Values      map[string]interface{}
+	Opts        controllers.UpgradeOptions
 }
 
 // UninstallCallArgs captures arguments from the last Uninstall call.
 type UninstallCallArgs struct {
 	ReleaseName string
 	Namespace   string
+	Opts        controllers.UninstallOptions
 }
 
 // MockHelmClient is a thread-safe mock implementation of HelmClientInterface.
@@ -37,12 +44,33 @@ type UninstallCallArgs struct {
 type MockHelmClient struct {
 	mu sync.Mutex
 
-	// Configurable return values.
-	InstallErr          error
-	UpgradeErr          error
-	UninstallErr        error
-	ReleaseExistsResult bool
-	ReleaseExistsErr    error
+	// Configurable return values. A nil InstallResult or UpgradeResult
+	// reports the requested version as deployed.
+	InstallResult        *controllers.DeployedChart
+	InstallErr           error
+	UpgradeResult        *controllers.DeployedChart
+	UpgradeErr           error
+	UninstallErr         error
+	ReleaseExistsResult  bool
+	ReleaseExistsErr     error
+	GetReleaseResult     *release.Release
+	GetReleaseErr        error
+	LintResult           []string
+	LintErr              error
+	LatestVersionsResult map[string]string
+	LatestVersionsErr    error
+	DriftResult          []string
+	DriftErr             error
+	ResourceKindsResult  []controllers.ResourceKind
+	ResourceKindsErr     error
+	ImpactResult         *controllers.ImpactSummary // nil reports no changes
+	ImpactErr            error
+	HistoryResult        []*release.Release
+	HistoryErr           error
+	ListReleasesResult   []*release.Release
+	ListReleasesErr      error
+	LoadChartResult      *chart.Chart
+	LoadChartErr         error
 
 	// Call-tracking booleans (guarded by mu).
 	InstallCalled   bool
@@ -53,12 +81,21 @@ type MockHelmClient struct {
 	InstallArgs   InstallCallArgs
 	UpgradeArgs   UpgradeCallArgs
 	UninstallArgs UninstallCallArgs
+
+	// Release names in call order (guarded by mu).
+	Installed   []string
+	Uninstalled []string
+
+	// ReleaseExistsCalls counts ReleaseExists calls, one per reconcile that
+	// gets as far as Helm (guarded by mu).
+	ReleaseExistsCalls int
 }
 
-func (m *MockHelmClient) Install(_ context.Context, releaseName, chartName, repoURL, version, namespace string, values map[string]interface{}) error {
+func (m *MockHelmClient) Install(_ context.Context, releaseName, chartName, repoURL, version, namespace string, values map[string]interface{}, opts controllers.InstallOptions) (*controllers.DeployedChart, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.InstallCalled = true
+	m.Installed = append(m.Installed, releaseName)
 	m.InstallArgs = InstallCallArgs{
 		ReleaseName: releaseName,
 		ChartName:   chartName,
@@ -66,11 +103,12 @@ func (m *MockHelmClient) Install(_ context.Context, releaseName, chartName, repo
 		Version:     version,
 		Namespace:   namespace,
 		Values:      values,
+		Opts:        opts,
 	}
-	return m.InstallErr
+	return deployed(m.InstallResult, version, m.InstallErr)
 }
 
-func (m *MockHelmClient) Upgrade(_ context.Context, releaseName, chartName, repoURL, version, namespace string, values map[string]interface{}) error {
+func (m *MockHelmClient) Upgrade(_ context.Context, releaseName, chartName, repoURL, version, namespace string, values map[string]interface{}, opts controllers.UpgradeOptions) (*controllers.DeployedChart, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.UpgradeCalled = true
@@ -81,17 +119,31 @@ func (m *MockHelmClient) Upgrade(_ context.Context, releaseName, chartName, repo
 		Version:     version,
 		Namespace:   namespace,
 		Values:      values,
+		Opts:        opts,
 	}
-	return m.UpgradeErr
+	return deployed(m.UpgradeResult, version, m.UpgradeErr)
 }
 
-func (m *MockHelmClient) Uninstall(_ context.Context, releaseName, namespace string) error {
+// deployed returns what an install or upgrade of version reports.
+func deployed(result *controllers.DeployedChart, version string, err error) (*controllers.DeployedChart, error) {
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		return &controllers.DeployedChart{Version: version}, nil
+	}
+	return result, nil
+}
+
+func (m *MockHelmClient) Uninstall(_ context.Context, releaseName, namespace string, opts controllers.UninstallOptions) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.UninstallCalled = true
+	m.Uninstalled = append(m.Uninstalled, releaseName)
 	m.UninstallArgs = UninstallCallArgs{
 		ReleaseName: releaseName,
 		Namespace:   namespace,
+		Opts:        opts,
 	}
 	return m.UninstallErr
 }
@@ -99,5 +151,63 @@ func (m *MockHelmClient) Uninstall(_ context.Context, releaseName, namespace str
 func (m *MockHelmClient) ReleaseExists(releaseName, namespace string) (bool, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	m.ReleaseExistsCalls++
 	return m.ReleaseExistsResult, m.ReleaseExistsErr
 }
+
+func (m *MockHelmClient) GetRelease(releaseName, namespace string) (*release.Release, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.GetReleaseResult, m.GetReleaseErr
+}
+
+func (m *MockHelmClient) Lint(_ context.Context, chartName, repoURL, version string, values map[string]interface{}, opts controllers.ChartFetchOptions) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.LintResult, m.LintErr
+}
+
+func (m *MockHelmClient) LatestVersions(_ context.Context, repoURL string, opts controllers.ChartFetchOptions) (map[string]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.LatestVersionsResult, m.LatestVersionsErr
+}
+
+func (m *MockHelmClient) DetectDrift(_ context.Context, releaseName, namespace string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.DriftResult, m.DriftErr
+}
+
+func (m *MockHelmClient) Impact(_ context.Context, releaseName, chartName, repoURL, version, namespace string, values map[string]interface{}, opts controllers.ChartFetchOptions) (*controllers.ImpactSummary, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ImpactResult == nil && m.ImpactErr == nil {
+		return &controllers.ImpactSummary{}, nil
+	}
+	return m.ImpactResult, m.ImpactErr
+}
+
+func (m *MockHelmClient) ResourceKinds(_ context.Context, chartName, repoURL, version, namespace string, values map[string]interface{}, opts controllers.ChartFetchOptions) ([]controllers.ResourceKind, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.ResourceKindsResult, m.ResourceKindsErr
+}
+
+func (m *MockHelmClient) History(_ context.Context, releaseName, namespace string) ([]*release.Release, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.HistoryResult, m.HistoryErr
+}
+
+func (m *MockHelmClient) ListReleases(context.Context) ([]*release.Release, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.ListReleasesResult, m.ListReleasesErr
+}
+
+func (m *MockHelmClient) LoadChart(context.Context, string, string, string, controllers.ChartFetchOptions) (*chart.Chart, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.LoadChartResult, m.LoadChartErr
+}
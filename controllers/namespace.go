@@ -0,0 +1,169 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"github.com/example/helm-operator/ownership"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// checkTargetNamespace refuses a target namespace other than the
+// HelmRelease's own unless TargetNamespaces allows it. Otherwise anyone who
+// may create a HelmRelease could have the operator deploy, and provision
+// Roles, in any namespace.
+func (r *HelmReleaseReconciler) checkTargetNamespace(release *helmv1alpha1.HelmRelease) error {
+	target := release.Spec.TargetNamespace
+	if release.Spec.KubeConfig != nil || target == release.Namespace {
+		return nil
+	}
+	for _, allowed := range r.TargetNamespaces {
+		if allowed == "*" || allowed == target {
+			return nil
+		}
+	}
+	return fmt.Errorf("spec.targetNamespace %s is not the HelmRelease's namespace %s and not allowed by the operator's --allowed-target-namespaces",
+		target, release.Namespace)
+}
+
+// ensureTargetNamespace creates the target namespace of release when
+// spec.createNamespace is set and it does not exist yet. The namespace is
+// labelled with the HelmRelease and deleted with it by ownership.Cleanup;
+// namespaces that already exist are left alone. Releases with
+// spec.kubeConfig are skipped: Helm creates their namespace in the remote
+// cluster (see InstallOptions.CreateNamespace). So are releases Helm deploys
+// as a ServiceAccount, which must itself be allowed to create the namespace.
+func (r *HelmReleaseReconciler) ensureTargetNamespace(ctx context.Context, release *helmv1alpha1.HelmRelease) error {
+	if !release.Spec.CreateNamespace || release.Spec.KubeConfig != nil || r.impersonatedServiceAccount(release) != "" {
+		return nil
+	}
+	name := release.Spec.TargetNamespace
+	err := r.Client.Get(ctx, client.ObjectKey{Name: name}, &corev1.Namespace{})
+	switch {
+	case err == nil:
+		return nil
+	case !apierrors.IsNotFound(err):
+		return fmt.Errorf("looking up target namespace %s: %w", name, err)
+	}
+
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	if err := ownership.Own(release, ns, r.Scheme); err != nil {
+		return err
+	}
+	if err := r.Client.Create(ctx, ns); err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("creating target namespace %s: %w", name, err)
+	}
+	ctrl.LoggerFrom(ctx).Info("Created target namespace", "namespace", name)
+	r.event(release, corev1.EventTypeNormal, "NamespaceCreated", "Created target namespace "+name)
+	return nil
+}
+
+// keepNamespacesInUse disowns the namespaces created for release that still
+// hold objects it does not own, so ownership.Cleanup does not delete those
+// objects with them.
+func (r *HelmReleaseReconciler) keepNamespacesInUse(ctx context.Context, release *helmv1alpha1.HelmRelease) error {
+	var list corev1.NamespaceList
+	if err := r.Client.List(ctx, &list, client.MatchingLabels{
+		ownership.LabelOwnerName:      release.Name,
+		ownership.LabelOwnerNamespace: release.Namespace,
+	}); err != nil {
+		return fmt.Errorf("listing owned namespaces: %w", err)
+	}
+	for i := range list.Items {
+		ns := &list.Items[i]
+		if ns.DeletionTimestamp != nil {
+			continue
+		}
+		foreign, err := r.foreignObject(ctx, release, ns.Name)
+		if err != nil {
+			return err
+		}
+		if foreign == "" {
+			continue
+		}
+		patch := client.MergeFrom(ns.DeepCopy())
+		ownership.Disown(release, ns)
+		if err := r.Client.Patch(ctx, ns, patch); err != nil {
+			return fmt.Errorf("keeping namespace %s: %w", ns.Name, err)
+		}
+		ctrl.LoggerFrom(ctx).Info("Keeping target namespace that is still in use", "namespace", ns.Name, "object", foreign)
+		r.event(release, corev1.EventTypeWarning, "NamespaceKept",
+			fmt.Sprintf("Kept namespace %s instead of deleting it: it still holds %s", ns.Name, foreign))
+	}
+	return nil
+}
+
+// generatedResources are created in every namespace, or follow other
+// objects, and so do not keep a namespace in use.
+var generatedResources = map[string]bool{"events": true, "endpoints": true}
+
+// foreignObject names an object in namespace that release does not own, or
+// returns "" if there is none. Objects with owner references are skipped:
+// they go with their owner. Without discovery the namespace counts as in
+// use.
+func (r *HelmReleaseReconciler) foreignObject(ctx context.Context, release *helmv1alpha1.HelmRelease, namespace string) (string, error) {
+	if r.Discovery == nil {
+		return "objects the operator cannot list without API discovery", nil
+	}
+	// Groups that failed discovery are left out of resources; the rest are
+	// still checked.
+	resources, err := r.Discovery.ServerPreferredNamespacedResources()
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		return "", fmt.Errorf("discovering namespaced resources: %w", err)
+	}
+	for _, group := range resources {
+		gv, err := schema.ParseGroupVersion(group.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, res := range group.APIResources {
+			if generatedResources[res.Name] || !slices.Contains(res.Verbs, "list") {
+				continue
+			}
+			list := &unstructured.UnstructuredList{}
+			list.SetGroupVersionKind(gv.WithKind(res.Kind + "List"))
+			if err := r.Client.List(ctx, list, client.InNamespace(namespace)); err != nil {
+				return fmt.Sprintf("%s it cannot list (%v)", res.Name, err), nil
+			}
+			for i := range list.Items {
+				obj := &list.Items[i]
+				if len(obj.GetOwnerReferences()) > 0 || obj.GetDeletionTimestamp() != nil || ownership.IsOwnedBy(release, obj) ||
+					isNamespaceDefault(res.Name, obj.GetName()) {
+					continue
+				}
+				return fmt.Sprintf("%s %s", res.Kind, obj.GetName()), nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// isNamespaceDefault reports whether the named object is one Kubernetes
+// creates in every namespace.
+func isNamespaceDefault(resource, name string) bool {
+	return resource == "serviceaccounts" && name == "default" ||
+		resource == "configmaps" && name == "kube-root-ca.crt"
+}
+
+// explainInstallError points out a missing target namespace as the likely
+// cause of a failed install, which Helm reports only as a failure to create
+// the first of the chart's objects.
+func (r *HelmReleaseReconciler) explainInstallError(ctx context.Context, release *helmv1alpha1.HelmRelease, err error) error {
+	if release.Spec.CreateNamespace || release.Spec.KubeConfig != nil {
+		return err
+	}
+	name := release.Spec.TargetNamespace
+	if getErr := r.Client.Get(ctx, client.ObjectKey{Name: name}, &corev1.Namespace{}); apierrors.IsNotFound(getErr) {
+		return fmt.Errorf("target namespace %s does not exist; create it or set spec.createNamespace: %w", name, err)
+	}
+	return err
+}
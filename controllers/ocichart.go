@@ -0,0 +1,145 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/registry"
+)
+
+// downloadOCIChart pulls chartName from the OCI registry repository repoURL
+// into dest and returns the archive's path and manifest digest. With opts.ChartDigest the chart
+// is pulled by that manifest digest, so a re-pushed tag cannot change what is
+// deployed; the registry client verifies the content against it, and the
+// chart's version must still satisfy version.
+func (h *HelmClient) downloadOCIChart(chartName, repoURL, version string, opts ChartFetchOptions, dest string) (string, string, error) {
+	transport, err := h.Proxy.transport(opts.ProxyURL)
+	if err != nil {
+		return "", "", err
+	}
+	// A release with credentials of its own logs in with a credentials file
+	// of this pull alone, so they are neither shared with other releases nor
+	// mixed with the operator's.
+	credentials := cli.New().RegistryConfig
+	if opts.RegistryUsername != "" {
+		credentials = filepath.Join(dest, "registry.json")
+	}
+	client, err := registry.NewClient(
+		registry.ClientOptHTTPClient(&http.Client{Transport: transport, Timeout: h.Download.Timeout}),
+		registry.ClientOptCredentialsFile(credentials),
+		registry.ClientOptWriter(io.Discard),
+	)
+	if err != nil {
+		return "", "", fmt.Errorf("creating registry client: %w", err)
+	}
+
+	ref := strings.TrimSuffix(strings.TrimPrefix(repoURL, "oci://"), "/") + "/" + chartName
+	if opts.RegistryUsername != "" {
+		host, _, _ := strings.Cut(ref, "/")
+		if err := client.Login(host, registry.LoginOptBasicAuth(opts.RegistryUsername, opts.RegistryPassword)); err != nil {
+			return "", "", fmt.Errorf("logging in to %s: %w", host, err)
+		}
+	}
+	if opts.ChartDigest != "" {
+		ref += "@" + opts.ChartDigest
+	} else {
+		tag, err := resolveOCITag(client, ref, version)
+		if err != nil {
+			return "", "", err
+		}
+		ref += ":" + tag
+	}
+
+	// A chart pulled for verification must have a provenance layer.
+	result, err := client.Pull(ref, registry.PullOptWithChart(true), registry.PullOptWithProv(opts.Keyring != nil))
+	if err != nil {
+		return "", "", fmt.Errorf("pulling chart %s: %w", ref, err)
+	}
+	if opts.ChartDigest != "" {
+		if result.Manifest.Digest != opts.ChartDigest {
+			return "", "", fmt.Errorf("chart %s has digest %s, want %s", ref, result.Manifest.Digest, opts.ChartDigest)
+		}
+		if err := checkChartVersion(result.Chart.Meta.Version, version); err != nil {
+			return "", "", fmt.Errorf("chart pinned by digest %s: %w", opts.ChartDigest, err)
+		}
+	}
+
+	path := filepath.Join(dest, fmt.Sprintf("%s-%s.tgz", chartName, result.Chart.Meta.Version))
+	if err := os.WriteFile(path, result.Chart.Data, 0o600); err != nil {
+		return "", "", err
+	}
+	if opts.Keyring != nil {
+		if err := os.WriteFile(path+".prov", result.Prov.Data, 0o600); err != nil {
+			return "", "", err
+		}
+		if err := verifyChart(path, opts.Keyring); err != nil {
+			return "", "", err
+		}
+	}
+	return path, result.Manifest.Digest, nil
+}
+
+// resolveOCITag returns the registry tag for version: the version itself
+// when it is exact, otherwise the highest tag satisfying the constraint.
+// OCI tags cannot contain "+", which Helm stores as "_".
+func resolveOCITag(client *registry.Client, ref, version string) (string, error) {
+	if _, err := semver.StrictNewVersion(strings.TrimPrefix(version, "v")); err == nil {
+		return strings.ReplaceAll(version, "+", "_"), nil
+	}
+	constraint, err := semver.NewConstraint(version)
+	if err != nil {
+		return "", fmt.Errorf("invalid version %q: %w", version, err)
+	}
+	tags, err := client.Tags(ref)
+	if err != nil {
+		return "", fmt.Errorf("listing tags of %s: %w", ref, err)
+	}
+	// Tags are returned highest version first.
+	for _, tag := range tags {
+		v, err := semver.NewVersion(strings.ReplaceAll(tag, "_", "+"))
+		if err == nil && constraint.Check(v) {
+			return tag, nil
+		}
+	}
+	return "", fmt.Errorf("no tag of %s matches version %q", ref, version)
+}
+
+// checkChartVersion returns an error unless actual satisfies version, an
+// exact version or a constraint.
+func checkChartVersion(actual, version string) error {
+	constraint, err := semver.NewConstraint(version)
+	if err != nil {
+		return fmt.Errorf("invalid version %q: %w", version, err)
+	}
+	v, err := semver.NewVersion(actual)
+	if err != nil {
+		return fmt.Errorf("chart has invalid version %q: %w", actual, err)
+	}
+	if !constraint.Check(v) {
+		return fmt.Errorf("chart version %s does not match %q", actual, version)
+	}
+	return nil
+}
+
+// archiveDigest returns the sha256 digest of the chart archive at path, the
+// digest chart repository indexes list for each version.
+func archiveDigest(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	sum := sha256.New()
+	if _, err := io.Copy(sum, f); err != nil {
+		return "", err
+	}
+	return "sha256:" + hex.EncodeToString(sum.Sum(nil)), nil
+}
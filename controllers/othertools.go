@@ -0,0 +1,119 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const conditionManagedByOtherTool = "ManagedByOtherTool"
+
+// otherToolMarkers maps label and annotation prefixes that other release
+// managers put on Helm's storage Secrets to the tool's name.
+var otherToolMarkers = []struct{ prefix, tool string }{
+	{"helm.toolkit.fluxcd.io/", "Flux"},
+	{"argocd.argoproj.io/", "Argo CD"},
+}
+
+// ManagedByOtherToolError reports that another tool manages a Helm release.
+type ManagedByOtherToolError struct {
+	Tool string
+	// Marker is the label or annotation that identifies the tool.
+	Marker string
+}
+
+func (e *ManagedByOtherToolError) Error() string {
+	return fmt.Sprintf("the Helm release is managed by %s (%s on its storage Secret); set spec.force to take it over", e.Tool, e.Marker)
+}
+
+// otherToolOf returns which other tool, if any, marks the storage Secrets of
+// the Helm release releaseName in namespace as its own.
+func otherToolOf(ctx context.Context, c client.Reader, namespace, releaseName string) (*ManagedByOtherToolError, error) {
+	var secrets corev1.SecretList
+	if err := c.List(ctx, &secrets, client.InNamespace(namespace),
+		client.MatchingLabels{"owner": "helm", "name": releaseName}); err != nil {
+		return nil, fmt.Errorf("reading Helm release storage: %w", err)
+	}
+	for _, s := range secrets.Items {
+		if found := otherToolMarker(s.Labels); found != nil {
+			return found, nil
+		}
+		if found := otherToolMarker(s.Annotations); found != nil {
+			return found, nil
+		}
+	}
+	return nil, nil
+}
+
+// otherToolMarker returns the tool whose marker appears first among keys.
+func otherToolMarker(m map[string]string) *ManagedByOtherToolError {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, marker := range otherToolMarkers {
+			if strings.HasPrefix(k, marker.prefix) {
+				return &ManagedByOtherToolError{Tool: marker.tool, Marker: k}
+			}
+		}
+	}
+	return nil
+}
+
+// checkOtherTool returns an error if another tool manages the release and
+// spec.force is not set, and records the outcome in the ManagedByOtherTool
+// condition.
+func (r *HelmReleaseReconciler) checkOtherTool(ctx context.Context, release *helmv1alpha1.HelmRelease, releaseName string) error {
+	other, err := otherToolOf(ctx, r.Client, release.Spec.TargetNamespace, releaseName)
+	if err != nil {
+		return err
+	}
+	if other == nil {
+		clearManagedByOtherTool(release)
+		return nil
+	}
+	if release.Spec.Force {
+		setCondition(release, metav1.Condition{
+			Type:               conditionManagedByOtherTool,
+			Status:             metav1.ConditionTrue,
+			Reason:             "ForcedTakeover",
+			Message:            fmt.Sprintf("The Helm release is also managed by %s; spec.force is set, so the operator manages it anyway", other.Tool),
+			ObservedGeneration: release.Generation,
+		})
+		return nil
+	}
+	setCondition(release, metav1.Condition{
+		Type:               conditionManagedByOtherTool,
+		Status:             metav1.ConditionTrue,
+		Reason:             "OtherToolDetected",
+		Message:            other.Error(),
+		ObservedGeneration: release.Generation,
+	})
+	r.event(release, corev1.EventTypeWarning, conditionManagedByOtherTool, other.Error())
+	return other
+}
+
+// clearManagedByOtherTool marks the release as no longer managed by another
+// tool if it previously was.
+func clearManagedByOtherTool(release *helmv1alpha1.HelmRelease) {
+	for _, c := range release.Status.Conditions {
+		if c.Type == conditionManagedByOtherTool && c.Status != metav1.ConditionFalse {
+			setCondition(release, metav1.Condition{
+				Type:               conditionManagedByOtherTool,
+				Status:             metav1.ConditionFalse,
+				Reason:             "NoOtherTool",
+				Message:            "No other tool manages the Helm release",
+				ObservedGeneration: release.Generation,
+			})
+			return
+		}
+	}
+}
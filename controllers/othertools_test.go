@@ -0,0 +1,86 @@
+package controllers_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Releases managed by other tools", func() {
+	ctx := context.Background()
+
+	// createStorage creates a Helm storage Secret for releaseName as Flux
+	// labels it.
+	createStorage := func(releaseName string) {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "sh.helm.release.v1." + releaseName + ".v1",
+				Namespace: testNS,
+				Labels: map[string]string{
+					"owner":                            "helm",
+					"name":                             releaseName,
+					"helm.toolkit.fluxcd.io/name":      releaseName,
+					"helm.toolkit.fluxcd.io/namespace": "flux-system",
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, secret)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, secret) })
+	}
+
+	It("refuses to upgrade a release Flux manages", func() {
+		mock := &MockHelmClient{ReleaseExistsResult: true}
+		cancel := startManager(mock)
+		defer cancel()
+
+		hr := makeHR("test-flux-managed")
+		createStorage(hr.Name)
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+		Eventually(func(g Gomega) {
+			fetched, err := getHR(ctx, hr.Name)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(fetched.Status.Phase).To(Equal(helmv1alpha1.PhaseFailed))
+			cond := findCondition(fetched, "ManagedByOtherTool")
+			g.Expect(cond).NotTo(BeNil())
+			g.Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+			g.Expect(cond.Reason).To(Equal("OtherToolDetected"))
+			g.Expect(cond.Message).To(ContainSubstring("Flux"))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+
+		mock.mu.Lock()
+		defer mock.mu.Unlock()
+		Expect(mock.UpgradeCalled).To(BeFalse())
+	})
+
+	It("takes the release over with spec.force", func() {
+		mock := &MockHelmClient{ReleaseExistsResult: true}
+		cancel := startManager(mock)
+		defer cancel()
+
+		hr := makeHR("test-flux-forced")
+		hr.Spec.Force = true
+		createStorage(hr.Name)
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+		Eventually(func(g Gomega) {
+			fetched, err := getHR(ctx, hr.Name)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(fetched.Status.Phase).To(Equal(helmv1alpha1.PhaseReady))
+			cond := findCondition(fetched, "ManagedByOtherTool")
+			g.Expect(cond).NotTo(BeNil())
+			g.Expect(cond.Reason).To(Equal("ForcedTakeover"))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+
+		mock.mu.Lock()
+		defer mock.mu.Unlock()
+		Expect(mock.UpgradeCalled).To(BeTrue())
+	})
+})
@@ -0,0 +1,148 @@
+package controllers
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const conditionMissingPermissions = "MissingPermissions"
+
+// forbiddenPattern matches the API server's RBAC denial message, e.g.
+//
+//	deployments.apps "web" is forbidden: User "system:serviceaccount:ops:helm-operator"
+//	cannot create resource "deployments" in API group "apps" in the namespace "shop"
+//
+// Helm flattens the errors of a release into text, so the message is all
+// that survives; several denials may appear in one error.
+var forbiddenPattern = regexp.MustCompile(
+	`cannot (\S+) resource "([^"]+)" in API group "([^"]*)"(?: in the namespace "([^"]+)")?`)
+
+// missingPermissions extracts the denied requests from err, merging verbs
+// per resource and namespace.
+func missingPermissions(err error) []helmv1alpha1.PermissionRule {
+	type key struct{ group, resource, namespace string }
+	verbs := map[key]map[string]bool{}
+	for _, m := range forbiddenPattern.FindAllStringSubmatch(err.Error(), -1) {
+		k := key{group: m[3], resource: m[2], namespace: m[4]}
+		if verbs[k] == nil {
+			verbs[k] = map[string]bool{}
+		}
+		verbs[k][m[1]] = true
+	}
+
+	rules := make([]helmv1alpha1.PermissionRule, 0, len(verbs))
+	for k, vs := range verbs {
+		rules = append(rules, helmv1alpha1.PermissionRule{
+			APIGroup: k.group, Resource: k.resource, Namespace: k.namespace, Verbs: sortedKeys(vs),
+		})
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		a, b := rules[i], rules[j]
+		if a.APIGroup != b.APIGroup {
+			return a.APIGroup < b.APIGroup
+		}
+		if a.Resource != b.Resource {
+			return a.Resource < b.Resource
+		}
+		return a.Namespace < b.Namespace
+	})
+	return rules
+}
+
+// setMissingPermissions records the permissions err shows the operator
+// lacks, or clears a previous record when err is not an RBAC denial.
+func setMissingPermissions(release *helmv1alpha1.HelmRelease, err error) {
+	rules := missingPermissions(err)
+	if len(rules) == 0 {
+		clearMissingPermissions(release)
+		return
+	}
+	release.Status.MissingPermissions = rules
+
+	denied := make([]string, 0, len(rules))
+	for _, r := range rules {
+		resource := r.Resource
+		if r.APIGroup != "" {
+			resource += "." + r.APIGroup
+		}
+		scope := "cluster-wide"
+		if r.Namespace != "" {
+			scope = "in " + r.Namespace
+		}
+		denied = append(denied, fmt.Sprintf("%s %s %s", strings.Join(r.Verbs, ","), resource, scope))
+	}
+	setCondition(release, metav1.Condition{
+		Type:               conditionMissingPermissions,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Forbidden",
+		Message:            "The operator is not allowed to " + strings.Join(denied, "; "),
+		ObservedGeneration: release.Generation,
+	})
+}
+
+// clearMissingPermissions sets MissingPermissions to False if it was
+// previously reported.
+func clearMissingPermissions(release *helmv1alpha1.HelmRelease) {
+	release.Status.MissingPermissions = nil
+	for _, c := range release.Status.Conditions {
+		if c.Type == conditionMissingPermissions && c.Status != metav1.ConditionFalse {
+			setCondition(release, metav1.Condition{
+				Type:               conditionMissingPermissions,
+				Status:             metav1.ConditionFalse,
+				Reason:             "PermissionsGranted",
+				Message:            "The operator has the permissions the release needs",
+				ObservedGeneration: release.Generation,
+			})
+			return
+		}
+	}
+}
+
+// ClusterRoleRules merges missing permissions into ClusterRole rules, one per
+// API group and resource. Namespaces are dropped: a ClusterRole grants
+// access everywhere.
+func ClusterRoleRules(perms []helmv1alpha1.PermissionRule) []rbacv1.PolicyRule {
+	type key struct{ group, resource string }
+	verbs := map[key]map[string]bool{}
+	for _, p := range perms {
+		k := key{group: p.APIGroup, resource: p.Resource}
+		if verbs[k] == nil {
+			verbs[k] = map[string]bool{}
+		}
+		for _, v := range p.Verbs {
+			verbs[k][v] = true
+		}
+	}
+	keys := make([]key, 0, len(verbs))
+	for k := range verbs {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].group != keys[j].group {
+			return keys[i].group < keys[j].group
+		}
+		return keys[i].resource < keys[j].resource
+	})
+	rules := make([]rbacv1.PolicyRule, 0, len(keys))
+	for _, k := range keys {
+		rules = append(rules, rbacv1.PolicyRule{
+			APIGroups: []string{k.group}, Resources: []string{k.resource}, Verbs: sortedKeys(verbs[k]),
+		})
+	}
+	return rules
+}
+
+func sortedKeys(set map[string]bool) []string {
+	out := make([]string, 0, len(set))
+	for k := range set {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
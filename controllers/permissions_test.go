@@ -0,0 +1,56 @@
+package controllers_test
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"github.com/example/helm-operator/controllers"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Missing permissions", func() {
+	ctx := context.Background()
+
+	It("reports the rules behind Forbidden install errors", func() {
+		mock := &MockHelmClient{InstallErr: errors.New(`failed to create resource: ` +
+			`customresourcedefinitions.apiextensions.k8s.io "widgets.example.com" is forbidden: User "system:serviceaccount:ops:helm-operator" ` +
+			`cannot create resource "customresourcedefinitions" in API group "apiextensions.k8s.io" at the cluster scope && ` +
+			`ingresses.networking.k8s.io "web" is forbidden: User "system:serviceaccount:ops:helm-operator" ` +
+			`cannot create resource "ingresses" in API group "networking.k8s.io" in the namespace "default"`)}
+		cancel := startManager(mock)
+		defer cancel()
+
+		hr := makeHR("test-missing-permissions")
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+		Eventually(func(g Gomega) {
+			fetched, err := getHR(ctx, hr.Name)
+			g.Expect(err).NotTo(HaveOccurred())
+			cond := findCondition(fetched, "MissingPermissions")
+			g.Expect(cond).NotTo(BeNil())
+			g.Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+			g.Expect(fetched.Status.MissingPermissions).To(Equal([]helmv1alpha1.PermissionRule{
+				{APIGroup: "apiextensions.k8s.io", Resource: "customresourcedefinitions", Verbs: []string{"create"}},
+				{APIGroup: "networking.k8s.io", Resource: "ingresses", Verbs: []string{"create"}, Namespace: testNS},
+			}))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+	})
+
+	It("merges verbs per resource across namespaces for the ClusterRole patch", func() {
+		rules := controllers.ClusterRoleRules([]helmv1alpha1.PermissionRule{
+			{APIGroup: "apps", Resource: "deployments", Verbs: []string{"create"}, Namespace: "a"},
+			{APIGroup: "apps", Resource: "deployments", Verbs: []string{"patch", "create"}, Namespace: "b"},
+			{APIGroup: "", Resource: "services", Verbs: []string{"get"}},
+		})
+		Expect(rules).To(Equal([]rbacv1.PolicyRule{
+			{APIGroups: []string{""}, Resources: []string{"services"}, Verbs: []string{"get"}},
+			{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: []string{"create", "patch"}},
+		}))
+	})
+})
@@ -0,0 +1,80 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"github.com/example/helm-operator/extensions"
+	"helm.sh/helm/v3/pkg/postrender"
+)
+
+// buildPostRenderer assembles the post-renderers declared in
+// spec.postRenderers, then the Wasm modules, then the extensions' PostRender
+// hook. It returns nil when there is nothing to run.
+func (r *HelmReleaseReconciler) buildPostRenderer(ctx context.Context, release *helmv1alpha1.HelmRelease,
+	rel extensions.Release) (postrender.PostRenderer, error) {
+	var chain postRendererChain
+	for i, pr := range release.Spec.PostRenderers {
+		if pr.Exec != nil {
+			if !r.postRendererAllowed(pr.Exec.Command) {
+				return nil, fmt.Errorf("postRenderers[%d]: command %q is not allowed by the operator's --allowed-post-renderers flag",
+					i, pr.Exec.Command)
+			}
+			exec, err := postrender.NewExec(pr.Exec.Command, pr.Exec.Args...)
+			if err != nil {
+				return nil, fmt.Errorf("postRenderers[%d]: %w", i, err)
+			}
+			chain = append(chain, exec)
+		}
+		if pr.Kustomize != nil {
+			chain = append(chain, &kustomizePostRenderer{spec: pr.Kustomize})
+		}
+	}
+	wasmPR, err := r.wasmPostRenderer(ctx, release)
+	if err != nil {
+		return nil, err
+	}
+	if wasmPR != nil {
+		chain = append(chain, wasmPR)
+	}
+	if len(r.Extensions) > 0 {
+		chain = append(chain, &extensionPostRenderer{ctx: ctx, ext: r.Extensions, rel: rel})
+	}
+
+	switch len(chain) {
+	case 0:
+		return nil, nil
+	case 1:
+		return chain[0], nil
+	default:
+		return chain, nil
+	}
+}
+
+// postRendererAllowed reports whether command may be run as an exec
+// post-renderer. Tenants must not be able to run arbitrary binaries inside the
+// operator, so only commands listed by the cluster admin are accepted.
+func (r *HelmReleaseReconciler) postRendererAllowed(command string) bool {
+	for _, allowed := range r.AllowedPostRenderers {
+		if command == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// postRendererChain runs Helm post-renderers in sequence, feeding each one's
+// output into the next.
+type postRendererChain []postrender.PostRenderer
+
+func (c postRendererChain) Run(rendered *bytes.Buffer) (*bytes.Buffer, error) {
+	var err error
+	for _, pr := range c {
+		if rendered, err = pr.Run(rendered); err != nil {
+			return nil, err
+		}
+	}
+	return rendered, nil
+}
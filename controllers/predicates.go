@@ -0,0 +1,28 @@
+package controllers
+
+import (
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// reconcilePredicate filters out HelmRelease updates that cannot change the
+// outcome of a reconcile, notably the status writes made by the controller
+// itself. Reconciles still run when:
+//   - the spec changes (metadata.generation is bumped),
+//   - annotations change, so annotations can be used to request a reconcile,
+//   - deletion starts,
+//   - the informer resyncs (old and new objects are identical).
+func reconcilePredicate() predicate.Predicate {
+	return predicate.Or(
+		predicate.GenerationChangedPredicate{},
+		predicate.AnnotationChangedPredicate{},
+		predicate.Funcs{UpdateFunc: func(e event.UpdateEvent) bool {
+			if e.ObjectOld == nil || e.ObjectNew == nil {
+				return false
+			}
+			deleting := e.ObjectOld.GetDeletionTimestamp().IsZero() && !e.ObjectNew.GetDeletionTimestamp().IsZero()
+			resync := e.ObjectOld.GetResourceVersion() == e.ObjectNew.GetResourceVersion()
+			return deleting || resync
+		}},
+	)
+}
@@ -0,0 +1,60 @@
+package controllers_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Reconcile triggers", func() {
+	ctx := context.Background()
+
+	It("ignores status-only updates but reconciles on annotation changes", func() {
+		mock := &MockHelmClient{}
+		cancel := startManager(mock)
+		defer cancel()
+
+		hr := makeHR("test-predicates")
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+		Eventually(func(g Gomega) {
+			fetched, err := getHR(ctx, hr.Name)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(meta.IsStatusConditionTrue(fetched.Status.Conditions, "Ready")).To(BeTrue())
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+		calls := func() int {
+			mock.mu.Lock()
+			defer mock.mu.Unlock()
+			return mock.ReleaseExistsCalls
+		}
+		// Let the reconciles queued by the install settle.
+		Eventually(func() bool {
+			before := calls()
+			time.Sleep(time.Second)
+			return calls() == before
+		}).WithTimeout(timeout).WithPolling(polling).Should(BeTrue())
+		settled := calls()
+
+		fetched, err := getHR(ctx, hr.Name)
+		Expect(err).NotTo(HaveOccurred())
+		meta.SetStatusCondition(&fetched.Status.Conditions, metav1.Condition{
+			Type: "Annotated", Status: metav1.ConditionTrue, Reason: "Test", Message: "status-only update",
+		})
+		Expect(k8sClient.Status().Update(ctx, fetched)).To(Succeed())
+		Consistently(calls).WithTimeout(2 * time.Second).WithPolling(polling).Should(Equal(settled))
+
+		fetched, err = getHR(ctx, hr.Name)
+		Expect(err).NotTo(HaveOccurred())
+		patch := client.MergeFrom(fetched.DeepCopy())
+		fetched.Annotations = map[string]string{"example.com/reconcile-at": time.Now().String()}
+		Expect(k8sClient.Patch(ctx, fetched, patch)).To(Succeed())
+		Eventually(calls).WithTimeout(timeout).WithPolling(polling).Should(BeNumerically(">", settled))
+	})
+})
@@ -0,0 +1,223 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const (
+	// defaultProbeTimeout bounds the probes after a deploy without
+	// spec.verify.probeTimeout.
+	defaultProbeTimeout = 2 * time.Minute
+	// probeRetryInterval is the pause between rounds of failing probes.
+	probeRetryInterval = 5 * time.Second
+	// probeRequestTimeout bounds a single probe.
+	probeRequestTimeout = 10 * time.Second
+	// maxProbeBody is how much of a response is searched for expectedBody.
+	maxProbeBody = 64 << 10
+)
+
+// ProbeError reports spec.verify.probes that did not pass. It is never
+// tolerated by the failure budget: the deployed release is not working.
+type ProbeError struct {
+	Failed []helmv1alpha1.ProbeResult
+}
+
+func (e *ProbeError) Error() string {
+	msgs := make([]string, 0, len(e.Failed))
+	for _, p := range e.Failed {
+		msgs = append(msgs, p.Name+": "+p.Message)
+	}
+	return "verification probes failed: " + strings.Join(msgs, "; ")
+}
+
+// verifyProbes runs spec.verify.probes and records their results in
+// status.probes. After an install or upgrade, as given by applying, failing
+// probes are retried until spec.verify.probeTimeout, giving the new pods time
+// to start. Otherwise the probes run once, and only while some of them have
+// not passed yet; a release whose probes passed is not probed again until
+// its next deploy.
+func (r *HelmReleaseReconciler) verifyProbes(ctx context.Context, release *helmv1alpha1.HelmRelease, applying bool) error {
+	var probes []helmv1alpha1.Probe
+	if release.Spec.Verify != nil {
+		probes = release.Spec.Verify.Probes
+	}
+	if len(probes) == 0 {
+		release.Status.Probes = nil
+		return nil
+	}
+	if !applying && probesPassed(release) {
+		return nil
+	}
+
+	var deadline time.Time
+	if applying {
+		timeout := defaultProbeTimeout
+		if t := release.Spec.Verify.ProbeTimeout; t != nil && t.Duration > 0 {
+			timeout = t.Duration
+		}
+		deadline = time.Now().Add(timeout)
+	}
+	for {
+		release.Status.Probes = r.runProbes(ctx, release, probes)
+		var failed []helmv1alpha1.ProbeResult
+		for _, result := range release.Status.Probes {
+			if !result.Passed {
+				failed = append(failed, result)
+			}
+		}
+		if len(failed) == 0 {
+			ctrl.LoggerFrom(ctx).Info("Verification probes passed", "probes", len(probes))
+			return nil
+		}
+		if time.Until(deadline) < probeRetryInterval {
+			return &ProbeError{Failed: failed}
+		}
+		timer := time.NewTimer(probeRetryInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return &ProbeError{Failed: failed}
+		case <-timer.C:
+		}
+	}
+}
+
+// probesPassed reports whether status.probes holds a passing result for each
+// of spec.verify.probes.
+func probesPassed(release *helmv1alpha1.HelmRelease) bool {
+	passed := map[string]bool{}
+	for _, result := range release.Status.Probes {
+		passed[result.Name] = result.Passed
+	}
+	for _, probe := range release.Spec.Verify.Probes {
+		if !passed[probe.Name] {
+			return false
+		}
+	}
+	return true
+}
+
+// runProbes runs each probe once.
+func (r *HelmReleaseReconciler) runProbes(ctx context.Context, release *helmv1alpha1.HelmRelease, probes []helmv1alpha1.Probe) []helmv1alpha1.ProbeResult {
+	results := make([]helmv1alpha1.ProbeResult, 0, len(probes))
+	for _, probe := range probes {
+		result := helmv1alpha1.ProbeResult{Name: probe.Name, CheckedAt: metav1.Now()}
+		message, err := r.runProbe(ctx, release, probe)
+		if err != nil {
+			result.Message = err.Error()
+			r.event(release, corev1.EventTypeWarning, "ProbeFailed", probe.Name+": "+result.Message)
+		} else {
+			result.Passed = true
+			result.Message = message
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// runProbe runs probe against release and describes the outcome.
+func (r *HelmReleaseReconciler) runProbe(ctx context.Context, release *helmv1alpha1.HelmRelease, probe helmv1alpha1.Probe) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, probeRequestTimeout)
+	defer cancel()
+	switch {
+	case probe.HTTP != nil:
+		return r.probeHTTP(ctx, release, probe.HTTP)
+	case probe.TCP != nil:
+		return probeTCP(ctx, release, probe.TCP)
+	}
+	return "", errors.New("neither http nor tcp is set")
+}
+
+// probeHTTP fetches the URL of probe and checks the response.
+func (r *HelmReleaseReconciler) probeHTTP(ctx context.Context, release *helmv1alpha1.HelmRelease, probe *helmv1alpha1.HTTPProbe) (string, error) {
+	url := probe.URL
+	if url == "" {
+		host, err := serviceHost(release, probe.Service)
+		if err != nil {
+			return "", err
+		}
+		if probe.Port == 0 {
+			return "", errors.New("port is required with service")
+		}
+		path := probe.Path
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+		url = "http://" + net.JoinHostPort(host, strconv.Itoa(int(probe.Port))) + path
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	client := r.ProbeClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxProbeBody))
+	want := probe.ExpectedStatus
+	if want == 0 {
+		want = http.StatusOK
+	}
+	switch {
+	case resp.StatusCode != want:
+		return "", fmt.Errorf("GET %s returned status %d, expected %d", url, resp.StatusCode, want)
+	case err != nil:
+		return "", fmt.Errorf("GET %s: reading response: %w", url, err)
+	case probe.ExpectedBody != "" && !strings.Contains(string(body), probe.ExpectedBody):
+		return "", fmt.Errorf("GET %s: response does not contain %q", url, probe.ExpectedBody)
+	}
+	return fmt.Sprintf("GET %s returned status %d", url, resp.StatusCode), nil
+}
+
+// probeTCP connects to the address of probe.
+func probeTCP(ctx context.Context, release *helmv1alpha1.HelmRelease, probe *helmv1alpha1.TCPProbe) (string, error) {
+	host := probe.Host
+	if probe.Service != "" {
+		var err error
+		if host, err = serviceHost(release, probe.Service); err != nil {
+			return "", err
+		}
+	}
+	if host == "" {
+		return "", errors.New("service or host is required")
+	}
+	addr := net.JoinHostPort(host, strconv.Itoa(int(probe.Port)))
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return "", err
+	}
+	conn.Close()
+	return "connected to " + addr, nil
+}
+
+// serviceHost returns the cluster DNS name of service in the target
+// namespace of release. The name only resolves in the operator's own
+// cluster, so releases with spec.kubeConfig must probe a URL or host.
+func serviceHost(release *helmv1alpha1.HelmRelease, service string) (string, error) {
+	if service == "" {
+		return "", errors.New("url or service is required")
+	}
+	if release.Spec.KubeConfig != nil {
+		return "", errors.New("service probes are not supported with spec.kubeConfig; probe a url or host instead")
+	}
+	return service + "." + release.Spec.TargetNamespace + ".svc", nil
+}
@@ -0,0 +1,90 @@
+package controllers_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Verification probes", func() {
+	ctx := context.Background()
+
+	It("marks the release Ready once its probes pass", func() {
+		app := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			fmt.Fprint(w, `{"status":"ok"}`)
+		}))
+		DeferCleanup(app.Close)
+		u, err := url.Parse(app.URL)
+		Expect(err).NotTo(HaveOccurred())
+		port, err := strconv.Atoi(u.Port())
+		Expect(err).NotTo(HaveOccurred())
+
+		mock := &MockHelmClient{}
+		cancel := startManager(mock)
+		defer cancel()
+
+		hr := makeHR("test-probes-pass")
+		hr.Spec.Verify = &helmv1alpha1.VerifySpec{Probes: []helmv1alpha1.Probe{
+			{Name: "health", HTTP: &helmv1alpha1.HTTPProbe{URL: app.URL + "/healthz", ExpectedBody: `"ok"`}},
+			{Name: "port", TCP: &helmv1alpha1.TCPProbe{Host: u.Hostname(), Port: int32(port)}},
+		}}
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+		Eventually(func(g Gomega) {
+			fetched, err := getHR(ctx, "test-probes-pass")
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(fetched.Status.Phase).To(Equal(helmv1alpha1.PhaseReady))
+			g.Expect(fetched.Status.Probes).To(HaveLen(2))
+			for _, result := range fetched.Status.Probes {
+				g.Expect(result.Passed).To(BeTrue(), result.Message)
+			}
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+	})
+
+	It("fails the release without reinstalling it while a probe fails", func() {
+		app := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		DeferCleanup(app.Close)
+
+		mock := &MockHelmClient{}
+		cancel := startManager(mock)
+		defer cancel()
+
+		hr := makeHR("test-probes-fail")
+		hr.Spec.Verify = &helmv1alpha1.VerifySpec{
+			Probes:       []helmv1alpha1.Probe{{Name: "health", HTTP: &helmv1alpha1.HTTPProbe{URL: app.URL}}},
+			ProbeTimeout: &metav1.Duration{Duration: time.Second},
+		}
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+		Eventually(func(g Gomega) {
+			fetched, err := getHR(ctx, "test-probes-fail")
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(fetched.Status.Phase).To(Equal(helmv1alpha1.PhaseFailed))
+			g.Expect(fetched.Status.LastApplied).NotTo(BeNil())
+			g.Expect(fetched.Status.Probes).To(HaveLen(1))
+			g.Expect(fetched.Status.Probes[0].Passed).To(BeFalse())
+			g.Expect(fetched.Status.Probes[0].Message).To(ContainSubstring("returned status 503, expected 200"))
+			cond := findCondition(fetched, "Ready")
+			g.Expect(cond).NotTo(BeNil())
+			g.Expect(cond.Message).To(HavePrefix("verification probes failed: health:"))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+
+		mock.mu.Lock()
+		defer mock.mu.Unlock()
+		Expect(mock.UpgradeCalled).To(BeFalse())
+	})
+})
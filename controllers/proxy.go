@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// proxyDirect as a proxy URL disables proxying for the matching destination.
+const proxyDirect = "direct"
+
+// ProxyConfig selects the HTTP proxy for chart repository and registry
+// requests. When HTTPProxy, HTTPSProxy and NoProxy are all empty the usual
+// HTTP_PROXY/HTTPS_PROXY/NO_PROXY environment variables apply.
+type ProxyConfig struct {
+	HTTPProxy  string
+	HTTPSProxy string
+	NoProxy    string
+
+	// Overrides route requests whose URL starts with Prefix through a
+	// different proxy. The first matching override wins.
+	Overrides []ProxyOverride
+}
+
+// ProxyOverride is a per-destination proxy. A ProxyURL of "direct" connects
+// without a proxy.
+type ProxyOverride struct {
+	Prefix   string
+	ProxyURL string
+}
+
+// ParseProxyOverrides parses the --repo-proxies flag: a comma-separated list
+// of urlPrefix=proxyURL entries.
+func ParseProxyOverrides(s string) ([]ProxyOverride, error) {
+	var overrides []ProxyOverride
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		prefix, proxy, ok := strings.Cut(entry, "=")
+		if !ok || prefix == "" || proxy == "" {
+			return nil, fmt.Errorf("proxy override %q: expected urlPrefix=proxyURL", entry)
+		}
+		if _, err := parseProxyURL(proxy); err != nil {
+			return nil, fmt.Errorf("proxy override %q: %w", entry, err)
+		}
+		overrides = append(overrides, ProxyOverride{Prefix: prefix, ProxyURL: proxy})
+	}
+	return overrides, nil
+}
+
+// transport returns an HTTP transport using the proxy selected by proxyFunc.
+func (p ProxyConfig) transport(releaseProxy string) (*http.Transport, error) {
+	proxy, err := p.proxyFunc(releaseProxy)
+	if err != nil {
+		return nil, err
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = proxy
+	return t, nil
+}
+
+// proxyFunc returns an http.Transport Proxy function. releaseProxy, from
+// spec.proxyURL, takes precedence over everything else.
+func (p ProxyConfig) proxyFunc(releaseProxy string) (func(*http.Request) (*url.URL, error), error) {
+	if releaseProxy != "" {
+		u, err := parseProxyURL(releaseProxy)
+		if err != nil {
+			return nil, fmt.Errorf("spec.proxyURL: %w", err)
+		}
+		return func(*http.Request) (*url.URL, error) { return u, nil }, nil
+	}
+
+	cfg := httpproxy.FromEnvironment()
+	if p.HTTPProxy != "" || p.HTTPSProxy != "" || p.NoProxy != "" {
+		cfg = &httpproxy.Config{HTTPProxy: p.HTTPProxy, HTTPSProxy: p.HTTPSProxy, NoProxy: p.NoProxy}
+	}
+	fallback := cfg.ProxyFunc()
+
+	return func(req *http.Request) (*url.URL, error) {
+		for _, o := range p.Overrides {
+			if strings.HasPrefix(req.URL.String(), o.Prefix) {
+				return parseProxyURL(o.ProxyURL)
+			}
+		}
+		return fallback(req.URL)
+	}, nil
+}
+
+// parseProxyURL parses a proxy URL; "direct" yields nil (no proxy).
+func parseProxyURL(s string) (*url.URL, error) {
+	if s == proxyDirect {
+		return nil, nil
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL %q: %w", s, err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL %q: scheme and host are required", s)
+	}
+	return u, nil
+}
@@ -0,0 +1,226 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"github.com/example/helm-operator/ownership"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/release"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/cli-runtime/pkg/resource"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const conditionRBACProvisioned = "RBACProvisioned"
+
+// rbacNamePrefix names the Role and RoleBinding provisioned for a release.
+const rbacNamePrefix = "helm-release-"
+
+// rbacVerbs are granted on every resource kind a chart renders; Helm needs
+// all of them to install, upgrade, roll back and uninstall.
+var rbacVerbs = []string{"get", "list", "watch", "create", "update", "patch", "delete"}
+
+// ResourceKind is a resource type rendered by a chart.
+type ResourceKind struct {
+	Group      string
+	Resource   string
+	Kind       string
+	Namespaced bool
+}
+
+// ResourceKinds renders the chart without contacting the cluster and returns
+// the distinct resource kinds of its manifests and hooks.
+func (h *HelmClient) ResourceKinds(ctx context.Context, chartName, repoURL, version, namespace string,
+	values map[string]interface{}, opts ChartFetchOptions) ([]ResourceKind, error) {
+	rel, err := h.render(ctx, "rbac-preview", chartName, repoURL, version, namespace, values, opts, false)
+	if err != nil {
+		return nil, err
+	}
+	manifests := rel.Manifest
+	for _, hook := range rel.Hooks {
+		manifests += "\n---\n" + hook.Manifest
+	}
+
+	kubeCfg, err := h.actionConfig(namespace)
+	if err != nil {
+		return nil, err
+	}
+	resources, err := kubeCfg.KubeClient.Build(bytes.NewBufferString(manifests), false)
+	if err != nil {
+		return nil, fmt.Errorf("mapping rendered kinds: %w", err)
+	}
+
+	seen := map[ResourceKind]bool{}
+	var kinds []ResourceKind
+	err = resources.Visit(func(info *resource.Info, err error) error {
+		if err != nil {
+			return err
+		}
+		k := ResourceKind{
+			Group:      info.Mapping.Resource.Group,
+			Resource:   info.Mapping.Resource.Resource,
+			Kind:       info.Mapping.GroupVersionKind.Kind,
+			Namespaced: info.Mapping.Scope.Name() == meta.RESTScopeNameNamespace,
+		}
+		if !seen[k] {
+			seen[k] = true
+			kinds = append(kinds, k)
+		}
+		return nil
+	})
+	return kinds, err
+}
+
+// render renders the chart as releaseName without contacting the cluster,
+// as an upgrade when upgrade is set.
+func (h *HelmClient) render(ctx context.Context, releaseName, chartName, repoURL, version, namespace string,
+	values map[string]interface{}, opts ChartFetchOptions, upgrade bool) (*release.Release, error) {
+	// ClientOnly swaps the KubeClient of the configuration for a printer,
+	// so it gets a configuration of its own.
+	cfg, err := h.actionConfig(namespace)
+	if err != nil {
+		return nil, err
+	}
+	chart, _, err := h.loadChart(ctx, chartName, repoURL, version, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	install := action.NewInstall(cfg)
+	install.DryRun = true
+	install.ClientOnly = true
+	install.IsUpgrade = upgrade
+	install.ReleaseName = releaseName
+	install.Namespace = namespace
+	install.Version = version
+	rel, err := install.RunWithContext(ctx, chart, values)
+	if err != nil {
+		return nil, fmt.Errorf("rendering chart: %w", err)
+	}
+	return rel, nil
+}
+
+// rbacRules builds the Role rules covering the namespaced kinds and returns
+// the kinds a Role cannot grant. Helm's release records are Secrets in the
+// target namespace, so Secrets are always included.
+func rbacRules(kinds []ResourceKind) ([]rbacv1.PolicyRule, []string) {
+	byGroup := map[string]map[string]bool{"": {"secrets": true}}
+	var clusterScoped []string
+	for _, k := range kinds {
+		if !k.Namespaced {
+			clusterScoped = append(clusterScoped, k.Kind)
+			continue
+		}
+		if byGroup[k.Group] == nil {
+			byGroup[k.Group] = map[string]bool{}
+		}
+		byGroup[k.Group][k.Resource] = true
+	}
+
+	groups := make([]string, 0, len(byGroup))
+	for g := range byGroup {
+		groups = append(groups, g)
+	}
+	sort.Strings(groups)
+	rules := make([]rbacv1.PolicyRule, 0, len(groups))
+	for _, g := range groups {
+		resources := make([]string, 0, len(byGroup[g]))
+		for res := range byGroup[g] {
+			resources = append(resources, res)
+		}
+		sort.Strings(resources)
+		rules = append(rules, rbacv1.PolicyRule{APIGroups: []string{g}, Resources: resources, Verbs: rbacVerbs})
+	}
+	sort.Strings(clusterScoped)
+	return rules, clusterScoped
+}
+
+// provisionRBAC creates or updates the Role and RoleBinding that let the
+// operator manage the chart's resources in the target namespace, when
+// spec.rbac.autoProvision is set. Releases Helm deploys as an impersonated
+// ServiceAccount are refused. Both are owned by the HelmRelease and
+// removed by ownership.Cleanup on uninstall.
+func (r *HelmReleaseReconciler) provisionRBAC(ctx context.Context, release *helmv1alpha1.HelmRelease, releaseName, repoURL string,
+	values map[string]interface{}, fetch ChartFetchOptions) error {
+	if release.Spec.RBAC == nil || !release.Spec.RBAC.AutoProvision {
+		return nil
+	}
+	if release.Spec.KubeConfig != nil {
+		// The Role would be created in this cluster and bound to a subject
+		// of this cluster; in the remote one Helm acts with the kubeconfig's
+		// credentials.
+		setCondition(release, metav1.Condition{
+			Type:               conditionRBACProvisioned,
+			Status:             metav1.ConditionFalse,
+			Reason:             "RemoteCluster",
+			Message:            "Not provisioned: the release is deployed with the credentials of spec.kubeConfig",
+			ObservedGeneration: release.Generation,
+		})
+		return nil
+	}
+	// Impersonation exists to withhold permissions the ServiceAccount was
+	// not granted; the operator must not grant them itself.
+	if sa := r.impersonatedServiceAccount(release); sa != "" {
+		return fmt.Errorf("spec.rbac.autoProvision cannot be used while Helm acts as ServiceAccount %s/%s; grant it the chart's permissions instead",
+			release.Namespace, sa)
+	}
+	subject := r.RBACSubject
+	if subject == nil {
+		return errors.New("spec.rbac.autoProvision requires the operator's --rbac-service-account flag")
+	}
+
+	kinds, err := r.HelmClient.ResourceKinds(ctx, release.Spec.Chart, repoURL, release.Spec.Version,
+		release.Spec.TargetNamespace, values, fetch)
+	if err != nil {
+		return fmt.Errorf("determining chart resource kinds: %w", err)
+	}
+	rules, clusterScoped := rbacRules(kinds)
+
+	name := rbacNamePrefix + releaseName
+	role := &rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: release.Spec.TargetNamespace}}
+	if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, role, func() error {
+		role.Rules = rules
+		return ownership.Own(release, role, r.Scheme)
+	}); err != nil {
+		return fmt.Errorf("provisioning Role %s/%s: %w", role.Namespace, name, err)
+	}
+
+	binding := &rbacv1.RoleBinding{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: release.Spec.TargetNamespace}}
+	if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, binding, func() error {
+		// roleRef is immutable; it never changes for a given name.
+		binding.RoleRef = rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: name}
+		binding.Subjects = []rbacv1.Subject{*subject}
+		return ownership.Own(release, binding, r.Scheme)
+	}); err != nil {
+		return fmt.Errorf("provisioning RoleBinding %s/%s: %w", binding.Namespace, name, err)
+	}
+
+	message := fmt.Sprintf("Role %s/%s grants access to %d resource types", role.Namespace, name, countResources(rules))
+	if len(clusterScoped) > 0 {
+		message += "; cluster-scoped kinds need cluster-wide permissions: " + strings.Join(clusterScoped, ", ")
+	}
+	setCondition(release, metav1.Condition{
+		Type:               conditionRBACProvisioned,
+		Status:             metav1.ConditionTrue,
+		Reason:             "RoleProvisioned",
+		Message:            message,
+		ObservedGeneration: release.Generation,
+	})
+	return nil
+}
+
+func countResources(rules []rbacv1.PolicyRule) int {
+	n := 0
+	for _, rule := range rules {
+		n += len(rule.Resources)
+	}
+	return n
+}
@@ -0,0 +1,114 @@
+package controllers_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"github.com/example/helm-operator/controllers"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ = Describe("RBAC auto-provisioning", func() {
+	ctx := context.Background()
+
+	subject := rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Namespace: "helm-operator-system", Name: "helm-operator"}
+
+	It("creates a Role for the chart's namespaced kinds bound to the operator", func() {
+		mock := &MockHelmClient{ResourceKindsResult: []controllers.ResourceKind{
+			{Group: "apps", Resource: "deployments", Kind: "Deployment", Namespaced: true},
+			{Group: "", Resource: "services", Kind: "Service", Namespaced: true},
+			{Group: "rbac.authorization.k8s.io", Resource: "clusterroles", Kind: "ClusterRole"},
+		}}
+		cancel := startManager(mock, func(r *controllers.HelmReleaseReconciler) { r.RBACSubject = &subject })
+		defer cancel()
+
+		hr := makeHR("test-rbac-provision")
+		hr.Spec.RBAC = &helmv1alpha1.RBACSpec{AutoProvision: true}
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+		Eventually(func(g Gomega) {
+			fetched, err := getHR(ctx, hr.Name)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(fetched.Status.Phase).To(Equal(helmv1alpha1.PhaseReady))
+			cond := findCondition(fetched, "RBACProvisioned")
+			g.Expect(cond).NotTo(BeNil())
+			g.Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+			g.Expect(cond.Message).To(ContainSubstring("ClusterRole"))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+
+		key := types.NamespacedName{Name: "helm-release-" + hr.Name, Namespace: testNS}
+		var role rbacv1.Role
+		Expect(k8sClient.Get(ctx, key, &role)).To(Succeed())
+		Expect(role.Rules).To(HaveLen(2))
+		Expect(role.Rules[0].APIGroups).To(Equal([]string{""}))
+		Expect(role.Rules[0].Resources).To(Equal([]string{"secrets", "services"}))
+		Expect(role.Rules[1].APIGroups).To(Equal([]string{"apps"}))
+		Expect(role.Rules[1].Resources).To(Equal([]string{"deployments"}))
+
+		var binding rbacv1.RoleBinding
+		Expect(k8sClient.Get(ctx, key, &binding)).To(Succeed())
+		Expect(binding.RoleRef.Name).To(Equal(key.Name))
+		Expect(binding.Subjects).To(Equal([]rbacv1.Subject{subject}))
+	})
+
+	It("refuses to provision RBAC for an impersonated ServiceAccount", func() {
+		mock := &MockHelmClient{ResourceKindsResult: []controllers.ResourceKind{
+			{Group: "apps", Resource: "deployments", Kind: "Deployment", Namespaced: true},
+		}}
+		cancel := startManager(mock, func(r *controllers.HelmReleaseReconciler) { r.RBACSubject = &subject })
+		defer cancel()
+
+		hr := makeHR("test-rbac-impersonated")
+		hr.Spec.ServiceAccountName = "tenant-deployer"
+		hr.Spec.RBAC = &helmv1alpha1.RBACSpec{AutoProvision: true}
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+		Eventually(func(g Gomega) {
+			fetched, err := getHR(ctx, hr.Name)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(fetched.Status.Phase).To(Equal(helmv1alpha1.PhaseFailed))
+			cond := findCondition(fetched, "Ready")
+			g.Expect(cond).NotTo(BeNil())
+			g.Expect(cond.Message).To(ContainSubstring("tenant-deployer"))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+
+		key := types.NamespacedName{Name: "helm-release-" + hr.Name, Namespace: testNS}
+		var binding rbacv1.RoleBinding
+		Expect(apierrors.IsNotFound(k8sClient.Get(ctx, key, &binding))).To(BeTrue())
+		mock.mu.Lock()
+		defer mock.mu.Unlock()
+		Expect(mock.InstallCalled).To(BeFalse())
+	})
+
+	It("fails the release when the operator has no RBAC subject", func() {
+		mock := &MockHelmClient{}
+		cancel := startManager(mock)
+		defer cancel()
+
+		hr := makeHR("test-rbac-no-subject")
+		hr.Spec.RBAC = &helmv1alpha1.RBACSpec{AutoProvision: true}
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+		Eventually(func(g Gomega) {
+			fetched, err := getHR(ctx, hr.Name)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(fetched.Status.Phase).To(Equal(helmv1alpha1.PhaseFailed))
+			cond := findCondition(fetched, "Ready")
+			g.Expect(cond).NotTo(BeNil())
+			g.Expect(cond.Message).To(ContainSubstring("--rbac-service-account"))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+
+		mock.mu.Lock()
+		defer mock.mu.Unlock()
+		Expect(mock.InstallCalled).To(BeFalse())
+	})
+})
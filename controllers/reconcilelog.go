@@ -0,0 +1,144 @@
+package controllers
+
+import (
+	"sync"
+	"time"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DefaultReconcileLogSize is how many attempts a ReconcileLog keeps per
+// release unless told otherwise.
+const DefaultReconcileLogSize = 20
+
+// Triggers of a reconcile, as recorded in a ReconcileRecord.
+const (
+	// TriggerCreated is the first reconcile of a release.
+	TriggerCreated = "Created"
+	// TriggerSpecChange is a reconcile of a spec the controller has not
+	// acted on yet.
+	TriggerSpecChange = "SpecChange"
+	// TriggerRetry follows a failed attempt.
+	TriggerRetry = "Retry"
+	// TriggerResync covers periodic checks, requeues and operator restarts.
+	TriggerResync = "Resync"
+	// TriggerDeletion is the uninstall of a deleted release.
+	TriggerDeletion = "Deletion"
+)
+
+// ReconcileRecord is one reconcile attempt kept by a ReconcileLog.
+type ReconcileRecord struct {
+	StartedAt  time.Time       `json:"startedAt"`
+	Trigger    string          `json:"trigger"`
+	Generation int64           `json:"generation"`
+	Operation  string          `json:"operation"`
+	Outcome    string          `json:"outcome"`
+	Duration   metav1.Duration `json:"duration"`
+	ErrorClass string          `json:"errorClass,omitempty"`
+	Error      string          `json:"error,omitempty"`
+}
+
+// ReconcileLog keeps the latest reconcile attempts of each release in
+// memory. Unlike status.lastAttempt it shows a release that keeps
+// reconciling, and why. The zero value keeps DefaultReconcileLogSize
+// attempts per release.
+type ReconcileLog struct {
+	// Size is how many attempts are kept per release.
+	Size int
+
+	mu       sync.Mutex
+	releases map[types.NamespacedName]*reconcileRing
+}
+
+// reconcileRing holds the latest attempts of one release; next is where
+// the following attempt is written.
+type reconcileRing struct {
+	records []ReconcileRecord
+	next    int
+}
+
+// Records returns the attempts kept for the release key, newest first.
+func (l *ReconcileLog) Records(key types.NamespacedName) []ReconcileRecord {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	ring := l.releases[key]
+	if ring == nil {
+		return nil
+	}
+	out := make([]ReconcileRecord, 0, len(ring.records))
+	for i := 1; i <= len(ring.records); i++ {
+		out = append(out, ring.records[(ring.next-i+len(ring.records))%len(ring.records)])
+	}
+	return out
+}
+
+// record keeps the status.lastAttempt of release, started for trigger.
+func (l *ReconcileLog) record(release *helmv1alpha1.HelmRelease, trigger string) {
+	a := release.Status.LastAttempt
+	if l == nil || a == nil {
+		return
+	}
+	rec := ReconcileRecord{
+		StartedAt:  a.StartedAt.Time,
+		Trigger:    trigger,
+		Generation: release.Generation,
+		Operation:  a.Operation,
+		Outcome:    a.Outcome,
+		Duration:   a.Duration,
+		ErrorClass: a.ErrorClass,
+		Error:      a.Error,
+	}
+	size := l.Size
+	if size <= 0 {
+		size = DefaultReconcileLogSize
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.releases == nil {
+		l.releases = map[types.NamespacedName]*reconcileRing{}
+	}
+	key := client.ObjectKeyFromObject(release)
+	ring := l.releases[key]
+	if ring == nil {
+		ring = &reconcileRing{}
+		l.releases[key] = ring
+	}
+	if len(ring.records) < size {
+		ring.records = append(ring.records, rec)
+		ring.next = len(ring.records) % size
+		return
+	}
+	ring.records[ring.next] = rec
+	ring.next = (ring.next + 1) % size
+}
+
+// forget drops the attempts of a release that no longer exists.
+func (l *ReconcileLog) forget(key types.NamespacedName) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.releases, key)
+}
+
+// reconcileTrigger tells why release is being reconciled, judging from the
+// status left by the previous attempt.
+func reconcileTrigger(release *helmv1alpha1.HelmRelease) string {
+	switch {
+	case release.Status.LastAttempt == nil:
+		return TriggerCreated
+	case release.Status.ObservedGeneration != release.Generation:
+		return TriggerSpecChange
+	case release.Status.LastAttempt.Outcome == outcomeFailed:
+		return TriggerRetry
+	}
+	return TriggerResync
+}
@@ -0,0 +1,56 @@
+package controllers_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/example/helm-operator/controllers"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Reconcile log", func() {
+	ctx := context.Background()
+
+	It("keeps the latest attempts of each release with their trigger", func() {
+		log := &controllers.ReconcileLog{Size: 2}
+		mock := &MockHelmClient{}
+		cancel := startManager(mock, func(r *controllers.HelmReleaseReconciler) {
+			r.ReconcileLog = log
+		})
+		defer cancel()
+
+		hr := makeHR("test-reconcile-log")
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+		key := client.ObjectKeyFromObject(hr)
+
+		Eventually(func(g Gomega) {
+			records := log.Records(key)
+			g.Expect(records).NotTo(BeEmpty())
+			g.Expect(records[len(records)-1].Trigger).To(Equal(controllers.TriggerCreated))
+			g.Expect(records[len(records)-1].Operation).To(Equal("Install"))
+			g.Expect(records[len(records)-1].Outcome).To(Equal("Succeeded"))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+
+		mock.mu.Lock()
+		mock.ReleaseExistsResult = true
+		mock.mu.Unlock()
+		Eventually(func() error {
+			fetched, err := getHR(ctx, hr.Name)
+			if err != nil {
+				return err
+			}
+			fetched.Spec.Version = "2.0.0"
+			return k8sClient.Update(ctx, fetched)
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+
+		Eventually(func(g Gomega) {
+			records := log.Records(key)
+			g.Expect(len(records)).To(BeNumerically("<=", 2))
+			g.Expect(records[0].Trigger).To(Equal(controllers.TriggerSpecChange))
+			g.Expect(records[0].Operation).To(Equal("Upgrade"))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+	})
+})
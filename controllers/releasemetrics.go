@@ -0,0 +1,238 @@
+package controllers
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Per-release metrics exported by ReleaseMetrics.
+const (
+	metricReleaseReady         = "helm_operator_release_ready"
+	metricReleaseFailures      = "helm_operator_release_consecutive_failures"
+	metricReleaseDriftedObject = "helm_operator_release_drifted_objects"
+)
+
+// DefaultReleaseMetricLabels is the label set of per-release metrics unless
+// configured otherwise.
+var DefaultReleaseMetricLabels = []string{"namespace", "name"}
+
+// invalidLabelChars matches the characters of a Kubernetes label key that
+// are not allowed in a Prometheus label name.
+var invalidLabelChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// releaseLabel is one label of the per-release metrics.
+type releaseLabel struct {
+	name  string
+	value func(*helmv1alpha1.HelmRelease) string
+}
+
+// parseReleaseLabel resolves an entry of the label allowlist: namespace,
+// name, chart, version, targetNamespace, or label:<key> to pass a label of
+// the HelmRelease through as label_<key>.
+func parseReleaseLabel(entry string) (releaseLabel, error) {
+	switch entry {
+	case "namespace":
+		return releaseLabel{"namespace", func(hr *helmv1alpha1.HelmRelease) string { return hr.Namespace }}, nil
+	case "name":
+		return releaseLabel{"name", func(hr *helmv1alpha1.HelmRelease) string { return hr.Name }}, nil
+	case "chart":
+		return releaseLabel{"chart", func(hr *helmv1alpha1.HelmRelease) string { return hr.Spec.Chart }}, nil
+	case "version":
+		return releaseLabel{"version", func(hr *helmv1alpha1.HelmRelease) string { return hr.Spec.Version }}, nil
+	case "targetNamespace":
+		return releaseLabel{"target_namespace", func(hr *helmv1alpha1.HelmRelease) string { return hr.Spec.TargetNamespace }}, nil
+	}
+	if key, ok := strings.CutPrefix(entry, "label:"); ok && key != "" {
+		name := "label_" + invalidLabelChars.ReplaceAllString(key, "_")
+		return releaseLabel{name, func(hr *helmv1alpha1.HelmRelease) string { return hr.Labels[key] }}, nil
+	}
+	return releaseLabel{}, fmt.Errorf("unknown metric label %q: use namespace, name, chart, version, targetNamespace or label:<key>", entry)
+}
+
+// ReleaseMetrics exports gauges per HelmRelease with a configurable label
+// set. Releases that share all label values, such as every release of a
+// namespace when only namespace is exported, are summed into one series.
+// Each metric keeps at most MaxSeries series; releases that would add more
+// are left out and counted by helm_operator_release_metrics_dropped_series.
+// A nil *ReleaseMetrics exports nothing.
+type ReleaseMetrics struct {
+	labels    []releaseLabel
+	maxSeries int
+	gauges    map[string]*releaseGauge
+	dropped   *prometheus.GaugeVec
+
+	mu sync.Mutex
+}
+
+// releaseGauge is one metric of ReleaseMetrics.
+type releaseGauge struct {
+	vec *prometheus.GaugeVec
+	// releases maps each exported release to its series and value.
+	releases map[types.NamespacedName]releaseSample
+	// series holds the sum and the number of releases of each series, by
+	// the joined label values.
+	series  map[string]*seriesSum
+	dropped map[types.NamespacedName]bool
+}
+
+type releaseSample struct {
+	key   string
+	value float64
+}
+
+type seriesSum struct {
+	labels   []string
+	value    float64
+	releases int
+}
+
+// NewReleaseMetrics returns per-release metrics labelled with the entries
+// of allowlist, in order (see parseReleaseLabel), keeping at most maxSeries
+// series per metric. maxSeries 0 means no limit.
+func NewReleaseMetrics(allowlist []string, maxSeries int) (*ReleaseMetrics, error) {
+	m := &ReleaseMetrics{maxSeries: maxSeries, gauges: map[string]*releaseGauge{}}
+	seen := map[string]bool{}
+	var names []string
+	for _, entry := range allowlist {
+		label, err := parseReleaseLabel(strings.TrimSpace(entry))
+		if err != nil {
+			return nil, err
+		}
+		if seen[label.name] {
+			return nil, fmt.Errorf("metric label %s is listed twice", label.name)
+		}
+		seen[label.name] = true
+		m.labels = append(m.labels, label)
+		names = append(names, label.name)
+	}
+	for name, help := range map[string]string{
+		metricReleaseReady:         "Number of HelmReleases with Ready=True.",
+		metricReleaseFailures:      "Consecutive failed reconciles of HelmReleases.",
+		metricReleaseDriftedObject: "Number of objects of HelmReleases whose live state differs from the applied manifests.",
+	} {
+		m.gauges[name] = &releaseGauge{
+			vec:      prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: name, Help: help}, names),
+			releases: map[types.NamespacedName]releaseSample{},
+			series:   map[string]*seriesSum{},
+			dropped:  map[types.NamespacedName]bool{},
+		}
+	}
+	m.dropped = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "helm_operator_release_metrics_dropped_series",
+		Help: "Number of HelmReleases left out of a per-release metric because it reached its series limit.",
+	}, []string{"metric"})
+	return m, nil
+}
+
+// Register registers the metrics with reg.
+func (m *ReleaseMetrics) Register(reg prometheus.Registerer) error {
+	for _, g := range m.gauges {
+		if err := reg.Register(g.vec); err != nil {
+			return err
+		}
+	}
+	return reg.Register(m.dropped)
+}
+
+// observe records the readiness and failures of release after a reconcile.
+func (m *ReleaseMetrics) observe(release *helmv1alpha1.HelmRelease) {
+	if m == nil {
+		return
+	}
+	ready := 0.0
+	if meta.IsStatusConditionTrue(release.Status.Conditions, "Ready") {
+		ready = 1
+	}
+	m.set(metricReleaseReady, release, ready)
+	m.set(metricReleaseFailures, release, float64(release.Status.ConsecutiveFailures))
+}
+
+// set records value for release in metric.
+func (m *ReleaseMetrics) set(metric string, release *helmv1alpha1.HelmRelease, value float64) {
+	if m == nil {
+		return
+	}
+	labels := make([]string, len(m.labels))
+	for i, label := range m.labels {
+		labels[i] = label.value(release)
+	}
+	key := strings.Join(labels, "\x00")
+	id := types.NamespacedName{Namespace: release.Namespace, Name: release.Name}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	g := m.gauges[metric]
+	if old, ok := g.releases[id]; ok && old.key != key {
+		m.removeLocked(metric, g, id)
+	}
+	sum, ok := g.series[key]
+	if !ok {
+		if m.maxSeries > 0 && len(g.series) >= m.maxSeries {
+			g.dropped[id] = true
+			m.dropped.WithLabelValues(metric).Set(float64(len(g.dropped)))
+			return
+		}
+		sum = &seriesSum{labels: labels}
+		g.series[key] = sum
+	}
+	if old, ok := g.releases[id]; ok {
+		sum.value -= old.value
+	} else {
+		sum.releases++
+	}
+	sum.value += value
+	g.releases[id] = releaseSample{key: key, value: value}
+	if g.dropped[id] {
+		delete(g.dropped, id)
+		m.dropped.WithLabelValues(metric).Set(float64(len(g.dropped)))
+	}
+	g.vec.WithLabelValues(labels...).Set(sum.value)
+}
+
+// clear removes release from metric.
+func (m *ReleaseMetrics) clear(metric string, release *helmv1alpha1.HelmRelease) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.removeLocked(metric, m.gauges[metric], types.NamespacedName{Namespace: release.Namespace, Name: release.Name})
+}
+
+// forget removes a deleted release from every metric.
+func (m *ReleaseMetrics) forget(release *helmv1alpha1.HelmRelease) {
+	if m == nil {
+		return
+	}
+	for metric := range m.gauges {
+		m.clear(metric, release)
+	}
+}
+
+func (m *ReleaseMetrics) removeLocked(metric string, g *releaseGauge, id types.NamespacedName) {
+	if g.dropped[id] {
+		delete(g.dropped, id)
+		m.dropped.WithLabelValues(metric).Set(float64(len(g.dropped)))
+	}
+	old, ok := g.releases[id]
+	if !ok {
+		return
+	}
+	delete(g.releases, id)
+	sum := g.series[old.key]
+	sum.releases--
+	sum.value -= old.value
+	if sum.releases == 0 {
+		delete(g.series, old.key)
+		g.vec.DeleteLabelValues(sum.labels...)
+		return
+	}
+	g.vec.WithLabelValues(sum.labels...).Set(sum.value)
+}
@@ -0,0 +1,72 @@
+package controllers_test
+
+import (
+	"context"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/example/helm-operator/controllers"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var _ = Describe("Release metrics", func() {
+	ctx := context.Background()
+
+	// series returns the series of metric in reg, keyed by their label
+	// pairs such as "label_team=payments".
+	series := func(reg *prometheus.Registry, metric string) map[string]float64 {
+		families, err := reg.Gather()
+		Expect(err).NotTo(HaveOccurred())
+		out := map[string]float64{}
+		for _, family := range families {
+			if family.GetName() != metric {
+				continue
+			}
+			for _, m := range family.GetMetric() {
+				var pairs []string
+				for _, label := range m.GetLabel() {
+					pairs = append(pairs, label.GetName()+"="+label.GetValue())
+				}
+				out[strings.Join(pairs, ",")] = m.GetGauge().GetValue()
+			}
+		}
+		return out
+	}
+
+	It("rejects unknown labels", func() {
+		_, err := controllers.NewReleaseMetrics([]string{"namespace", "pod"}, 0)
+		Expect(err).To(MatchError(ContainSubstring(`unknown metric label "pod"`)))
+		_, err = controllers.NewReleaseMetrics([]string{"name", "name"}, 0)
+		Expect(err).To(MatchError(ContainSubstring("listed twice")))
+	})
+
+	It("exports only allowed labels and drops series beyond the cap", func() {
+		metrics, err := controllers.NewReleaseMetrics([]string{"label:team"}, 1)
+		Expect(err).NotTo(HaveOccurred())
+		reg := prometheus.NewRegistry()
+		Expect(metrics.Register(reg)).To(Succeed())
+
+		mock := &MockHelmClient{}
+		cancel := startManager(mock, func(r *controllers.HelmReleaseReconciler) { r.Metrics = metrics })
+		defer cancel()
+
+		first := makeHR("test-metrics-payments")
+		first.Labels = map[string]string{"team": "payments"}
+		Expect(k8sClient.Create(ctx, first)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, first) })
+		Eventually(func() map[string]float64 {
+			return series(reg, "helm_operator_release_ready")
+		}).WithTimeout(timeout).WithPolling(polling).Should(Equal(map[string]float64{"label_team=payments": 1}))
+
+		second := makeHR("test-metrics-search")
+		second.Labels = map[string]string{"team": "search"}
+		Expect(k8sClient.Create(ctx, second)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, second) })
+		Eventually(func() map[string]float64 {
+			return series(reg, "helm_operator_release_metrics_dropped_series")
+		}).WithTimeout(timeout).WithPolling(polling).Should(HaveKeyWithValue("metric=helm_operator_release_ready", 1.0))
+		Expect(series(reg, "helm_operator_release_ready")).To(Equal(map[string]float64{"label_team=payments": 1}))
+	})
+})
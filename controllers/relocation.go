@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// installedRelease returns the name and namespace of the Helm release the
+// controller last deployed, falling back to the spec for releases deployed
+// before the location was tracked in status.
+func installedRelease(release *helmv1alpha1.HelmRelease, releaseName string) (string, string) {
+	name, namespace := release.Status.ReleaseName, release.Status.ReleaseNamespace
+	if name == "" {
+		name = releaseName
+	}
+	if namespace == "" {
+		namespace = release.Spec.TargetNamespace
+	}
+	return name, namespace
+}
+
+// installedCluster returns the spec.kubeConfig the Helm release was last
+// deployed with, nil for this cluster. Releases deployed before the location
+// was tracked are taken to be in the cluster the spec names.
+func installedCluster(release *helmv1alpha1.HelmRelease) *helmv1alpha1.KubeConfigReference {
+	if release.Status.ReleaseName == "" {
+		return release.Spec.KubeConfig
+	}
+	return release.Status.ReleaseKubeConfig
+}
+
+// describeLocation names a Helm release and, unless it is in this cluster,
+// the kubeconfig Secret of its cluster.
+func describeLocation(namespace, name string, kc *helmv1alpha1.KubeConfigReference) string {
+	if kc == nil {
+		return namespace + "/" + name
+	}
+	return fmt.Sprintf("%s/%s in the cluster of kubeconfig Secret %s", namespace, name, kc.SecretRef.Name)
+}
+
+// relocation reports whether releaseName, targetNamespace or kubeConfig
+// changed after the release was deployed, so the old Helm release has to be
+// uninstalled before the new one is installed. Without spec.allowRelocation
+// such a change is an error, since the old release would silently be
+// orphaned.
+func relocation(release *helmv1alpha1.HelmRelease, releaseName string) (bool, error) {
+	oldName, oldNamespace := installedRelease(release, releaseName)
+	oldCluster := installedCluster(release)
+	if sameCluster(oldCluster, release.Spec.KubeConfig) && oldName == releaseName && oldNamespace == release.Spec.TargetNamespace {
+		return false, nil
+	}
+	if !release.Spec.AllowRelocation {
+		return false, fmt.Errorf("release is installed as %s; changing releaseName, targetNamespace or kubeConfig requires spec.allowRelocation, "+
+			"which uninstalls it before installing %s", describeLocation(oldNamespace, oldName, oldCluster),
+			describeLocation(release.Spec.TargetNamespace, releaseName, release.Spec.KubeConfig))
+	}
+	return true, nil
+}
+
+// relocate uninstalls the old Helm release of a release that relocation
+// reported as moved, in the cluster it was deployed to, so the new one can
+// be installed in its place. The caller holds the lock of the new location;
+// relocate takes the lock of the old one for the uninstall and returns a
+// *LockedError when another client holds it.
+func (r *HelmReleaseReconciler) relocate(ctx context.Context, release *helmv1alpha1.HelmRelease, helm HelmClientInterface, releaseName string) error {
+	oldName, oldNamespace := installedRelease(release, releaseName)
+	oldCluster := installedCluster(release)
+	from := describeLocation(oldNamespace, oldName, oldCluster)
+	to := describeLocation(release.Spec.TargetNamespace, releaseName, release.Spec.KubeConfig)
+
+	locker, err := r.lockerFor(ctx, release.Namespace, oldCluster)
+	if err != nil {
+		return fmt.Errorf("connecting to the cluster of %s for relocation: %w", from, err)
+	}
+	unlock, err := locker.Lock(ctx, oldNamespace, oldName)
+	var locked *LockedError
+	if errors.As(err, &locked) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("acquiring the release lock of %s: %w", from, err)
+	}
+	defer unlock()
+
+	ctrl.LoggerFrom(ctx).Info("Relocating Helm release", "from", from, "to", to)
+	if !sameCluster(oldCluster, release.Spec.KubeConfig) {
+		if helm, err = r.helmClientForCluster(ctx, release.Namespace, oldCluster); err != nil {
+			return fmt.Errorf("connecting to the cluster of %s for relocation: %w", from, err)
+		}
+	}
+	if err := helm.Uninstall(ctx, oldName, oldNamespace, r.uninstallOptions(release)); err != nil && !errors.Is(err, driver.ErrReleaseNotFound) {
+		return fmt.Errorf("uninstalling %s for relocation: %w", from, err)
+	}
+	if err := locker.Forget(ctx, oldNamespace, oldName); err != nil {
+		ctrl.LoggerFrom(ctx).Error(err, "Deleting the release lock of the old location", "location", from)
+	}
+	r.event(release, corev1.EventTypeNormal, "Relocated", fmt.Sprintf("Uninstalled %s to install it as %s", from, to))
+	release.Status.ReleaseName = ""
+	release.Status.ReleaseNamespace = ""
+	release.Status.ReleaseKubeConfig = nil
+	return nil
+}
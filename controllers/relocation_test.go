@@ -0,0 +1,132 @@
+package controllers_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"github.com/example/helm-operator/controllers"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Release relocation", func() {
+	ctx := context.Background()
+
+	// installAndMove creates hr, waits for it to be installed and then moves
+	// it to another target namespace.
+	installAndMove := func(hr *helmv1alpha1.HelmRelease) {
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+		Eventually(func(g Gomega) {
+			fetched, err := getHR(ctx, hr.Name)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(fetched.Status.Phase).To(Equal(helmv1alpha1.PhaseReady))
+			g.Expect(fetched.Status.ReleaseNamespace).To(Equal(testNS))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+
+		fetched, err := getHR(ctx, hr.Name)
+		Expect(err).NotTo(HaveOccurred())
+		patch := client.MergeFrom(fetched.DeepCopy())
+		fetched.Spec.TargetNamespace = "relocated"
+		Expect(k8sClient.Patch(ctx, fetched, patch)).To(Succeed())
+	}
+
+	It("refuses to change targetNamespace without allowRelocation", func() {
+		mock := &MockHelmClient{}
+		cancel := startManager(mock, allowAnyTarget)
+		defer cancel()
+
+		hr := makeHR("test-relocation-refused")
+		installAndMove(hr)
+
+		Eventually(func(g Gomega) {
+			fetched, err := getHR(ctx, hr.Name)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(fetched.Status.Phase).To(Equal(helmv1alpha1.PhaseFailed))
+			cond := findCondition(fetched, "Ready")
+			g.Expect(cond).NotTo(BeNil())
+			g.Expect(cond.Message).To(ContainSubstring("allowRelocation"))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+
+		mock.mu.Lock()
+		defer mock.mu.Unlock()
+		Expect(mock.UninstallCalled).To(BeFalse())
+	})
+
+	It("uninstalls the old release and installs the new one with allowRelocation", func() {
+		mock := &MockHelmClient{}
+		cancel := startManager(mock, allowAnyTarget)
+		defer cancel()
+
+		hr := makeHR("test-relocation-allowed")
+		hr.Spec.AllowRelocation = true
+		installAndMove(hr)
+
+		Eventually(func(g Gomega) {
+			fetched, err := getHR(ctx, hr.Name)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(fetched.Status.ReleaseNamespace).To(Equal("relocated"))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+
+		mock.mu.Lock()
+		defer mock.mu.Unlock()
+		Expect(mock.UninstallArgs).To(Equal(UninstallCallArgs{ReleaseName: hr.Name, Namespace: testNS}))
+		Expect(mock.InstallArgs.Namespace).To(Equal("relocated"))
+	})
+
+	It("defers the uninstall while another client holds the old release's lock", func() {
+		mock := &MockHelmClient{}
+		cancel := startManager(mock, func(r *controllers.HelmReleaseReconciler) {
+			r.Locker = &controllers.ReleaseLocker{Client: k8sClient, Identity: "helm-operator/test"}
+		})
+		defer cancel()
+
+		hr := makeHR("test-relocation-locked")
+		hr.Spec.AllowRelocation = true
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+		Eventually(func(g Gomega) {
+			fetched, err := getHR(ctx, hr.Name)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(fetched.Status.ReleaseName).To(Equal(hr.Name))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+
+		var lease coordinationv1.Lease
+		key := types.NamespacedName{Name: controllers.LockLeasePrefix + hr.Name, Namespace: testNS}
+		Expect(k8sClient.Get(ctx, key, &lease)).To(Succeed())
+		holder, seconds, now := "ci-pipeline", int32(3600), metav1.NewMicroTime(time.Now())
+		lease.Spec.HolderIdentity, lease.Spec.LeaseDurationSeconds, lease.Spec.RenewTime = &holder, &seconds, &now
+		Expect(k8sClient.Update(ctx, &lease)).To(Succeed())
+
+		fetched, err := getHR(ctx, hr.Name)
+		Expect(err).NotTo(HaveOccurred())
+		patch := client.MergeFrom(fetched.DeepCopy())
+		fetched.Spec.ReleaseName = hr.Name + "-v2"
+		Expect(k8sClient.Patch(ctx, fetched, patch)).To(Succeed())
+
+		Eventually(func(g Gomega) {
+			fetched, err := getHR(ctx, hr.Name)
+			g.Expect(err).NotTo(HaveOccurred())
+			cond := findCondition(fetched, "LockedByOther")
+			g.Expect(cond).NotTo(BeNil())
+			g.Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+			g.Expect(cond.Message).To(ContainSubstring("ci-pipeline"))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+
+		mock.mu.Lock()
+		Expect(mock.UninstallCalled).To(BeFalse())
+		mock.mu.Unlock()
+
+		// The new location's lock was released on the way out.
+		var newLease coordinationv1.Lease
+		newKey := types.NamespacedName{Name: controllers.LockLeasePrefix + hr.Name + "-v2", Namespace: testNS}
+		Expect(k8sClient.Get(ctx, newKey, &newLease)).To(Succeed())
+		Expect(newLease.Spec.HolderIdentity).To(BeNil())
+	})
+})
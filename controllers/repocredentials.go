@@ -0,0 +1,117 @@
+package controllers
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Keys of a spec.repoSecretRef Secret.
+const (
+	repoSecretUsername = "username"
+	repoSecretPassword = "password"
+	repoSecretCA       = "ca.crt"
+	repoSecretCert     = "tls.crt"
+	repoSecretKey      = "tls.key"
+)
+
+// RepoCredentials authenticate the operator to a private chart repository.
+type RepoCredentials struct {
+	// Username and Password are sent with HTTP basic auth, to the
+	// repository's host only.
+	Username string
+	Password string
+	// CA is a PEM bundle trusted in addition to the system roots.
+	CA []byte
+	// Cert and Key are a PEM client certificate and its key.
+	Cert []byte
+	Key  []byte
+	// InsecureSkipTLSVerify disables verification of the repository's
+	// certificate.
+	InsecureSkipTLSVerify bool
+}
+
+// repoCredentials reads the credentials of spec.repoSecretRef. It returns
+// nil for a release without them.
+func (r *HelmReleaseReconciler) repoCredentials(ctx context.Context, release *helmv1alpha1.HelmRelease) (*RepoCredentials, error) {
+	ref := release.Spec.RepoSecretRef
+	if ref == nil {
+		if release.Spec.InsecureSkipTLSVerify {
+			return &RepoCredentials{InsecureSkipTLSVerify: true}, nil
+		}
+		return nil, nil
+	}
+	var secret corev1.Secret
+	if err := r.Get(ctx, types.NamespacedName{Namespace: release.Namespace, Name: ref.Name}, &secret); err != nil {
+		return nil, fmt.Errorf("reading repository Secret %s: %w", ref.Name, err)
+	}
+	creds := &RepoCredentials{
+		Username:              string(secret.Data[repoSecretUsername]),
+		Password:              string(secret.Data[repoSecretPassword]),
+		CA:                    secret.Data[repoSecretCA],
+		Cert:                  secret.Data[repoSecretCert],
+		Key:                   secret.Data[repoSecretKey],
+		InsecureSkipTLSVerify: release.Spec.InsecureSkipTLSVerify,
+	}
+	if (creds.Username == "") != (creds.Password == "") {
+		return nil, fmt.Errorf("repository Secret %s needs both %s and %s, or neither", ref.Name, repoSecretUsername, repoSecretPassword)
+	}
+	if (len(creds.Cert) == 0) != (len(creds.Key) == 0) {
+		return nil, fmt.Errorf("repository Secret %s needs both %s and %s, or neither", ref.Name, repoSecretCert, repoSecretKey)
+	}
+	if _, err := creds.tlsConfig(); err != nil {
+		return nil, fmt.Errorf("repository Secret %s: %w", ref.Name, err)
+	}
+	return creds, nil
+}
+
+// tlsConfig returns the TLS settings for the repository, or nil when the
+// defaults apply.
+func (c *RepoCredentials) tlsConfig() (*tls.Config, error) {
+	if c == nil || (len(c.CA) == 0 && len(c.Cert) == 0 && !c.InsecureSkipTLSVerify) {
+		return nil, nil
+	}
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12, InsecureSkipVerify: c.InsecureSkipTLSVerify}
+	if len(c.CA) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(c.CA) {
+			return nil, errors.New(repoSecretCA + " holds no PEM certificates")
+		}
+		cfg.RootCAs = pool
+	}
+	if len(c.Cert) > 0 {
+		cert, err := tls.X509KeyPair(c.Cert, c.Key)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// repoTransport returns the transport for requests to the chart
+// repository: the proxy of opts, with the TLS settings of its credentials.
+func (h *HelmClient) repoTransport(opts ChartFetchOptions) (*http.Transport, error) {
+	transport, err := h.Proxy.transport(opts.ProxyURL)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := opts.Credentials.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	if cfg != nil {
+		transport.TLSClientConfig = cfg
+	}
+	return transport, nil
+}
@@ -0,0 +1,95 @@
+package controllers_test
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"github.com/example/helm-operator/controllers"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Private repositories", func() {
+	ctx := context.Background()
+
+	It("reads a private index over TLS with basic auth", func() {
+		repo := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if user, pass, ok := r.BasicAuth(); !ok || user != "ci" || pass != "s3cret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(testIndex))
+		}))
+		defer repo.Close()
+		ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: repo.Certificate().Raw})
+
+		hc := controllers.NewHelmClient(cfg)
+		_, err := hc.LatestVersions(ctx, repo.URL, controllers.ChartFetchOptions{})
+		Expect(err).To(HaveOccurred())
+
+		_, err = hc.LatestVersions(ctx, repo.URL, controllers.ChartFetchOptions{
+			Credentials: &controllers.RepoCredentials{CA: ca},
+		})
+		Expect(err).To(MatchError(ContainSubstring("401")))
+
+		latest, err := hc.LatestVersions(ctx, repo.URL, controllers.ChartFetchOptions{
+			Credentials: &controllers.RepoCredentials{Username: "ci", Password: "s3cret", CA: ca},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(latest).To(Equal(map[string]string{"nginx": "1.2.0"}))
+	})
+
+	It("passes spec.repoSecretRef to Helm", func() {
+		mock := &MockHelmClient{}
+		cancel := startManager(mock)
+		defer cancel()
+
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "charts-auth", Namespace: "default"},
+			Data:       map[string][]byte{"username": []byte("ci"), "password": []byte("s3cret")},
+		}
+		Expect(k8sClient.Create(ctx, secret)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, secret) })
+		hr := makeHR("test-repo-secret")
+		hr.Spec.RepoSecretRef = &helmv1alpha1.LocalSecretReference{Name: "charts-auth"}
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+		Eventually(func(g Gomega) {
+			mock.mu.Lock()
+			args := mock.InstallArgs
+			mock.mu.Unlock()
+			g.Expect(args.Opts.Fetch.Credentials).To(Equal(&controllers.RepoCredentials{Username: "ci", Password: "s3cret"}))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+	})
+
+	It("fails releases whose repository Secret is incomplete", func() {
+		mock := &MockHelmClient{}
+		cancel := startManager(mock)
+		defer cancel()
+
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "charts-half", Namespace: "default"},
+			Data:       map[string][]byte{"username": []byte("ci")},
+		}
+		Expect(k8sClient.Create(ctx, secret)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, secret) })
+		hr := makeHR("test-repo-secret-half")
+		hr.Spec.RepoSecretRef = &helmv1alpha1.LocalSecretReference{Name: "charts-half"}
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+		Eventually(func(g Gomega) {
+			fetched, err := getHR(ctx, hr.Name)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(fetched.Status.Phase).To(Equal(helmv1alpha1.PhaseFailed))
+			g.Expect(findCondition(fetched, "Ready").Message).To(ContainSubstring("needs both username and password"))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+	})
+})
@@ -0,0 +1,213 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// AnnotationRepositoryHealth is set by the RepositoryMonitor on HelmReleases
+// whose chart repository failed its last health check, and removed once the
+// repository recovers.
+const AnnotationRepositoryHealth = "helm.example.com/repository-health"
+
+var (
+	repositoryUpGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "helm_operator_repository_up",
+		Help: "Whether the last index check of a chart repository succeeded (1) or failed (0).",
+	}, []string{"repository"})
+	repositoryCheckSeconds = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "helm_operator_repository_check_duration_seconds",
+		Help: "Latency of the last index check of a chart repository.",
+	}, []string{"repository"})
+)
+
+func init() {
+	ctrlmetrics.Registry.MustRegister(repositoryUpGauge, repositoryCheckSeconds)
+}
+
+// RepositoryHealth is the result of the last health check of one repository.
+type RepositoryHealth struct {
+	URL       string        `json:"url"`
+	Healthy   bool          `json:"healthy"`
+	Latency   time.Duration `json:"latency"`
+	Error     string        `json:"error,omitempty"`
+	CheckedAt time.Time     `json:"checkedAt"`
+	Releases  int           `json:"releases"`
+}
+
+// RepositoryMonitor is a controller-runtime Runnable that periodically fetches
+// the index of every chart repository referenced by a HelmRelease, so outages
+// are visible before the next install or upgrade fails.
+type RepositoryMonitor struct {
+	Client   client.Client
+	Interval time.Duration
+	Timeout  time.Duration
+
+	// HTTPClient is used for index requests. Defaults to a client using Proxy.
+	HTTPClient *http.Client
+	Proxy      ProxyConfig
+	UserAgent  string
+
+	// Rewrites are applied to repository URLs before they are checked, as
+	// the reconciler does before installing.
+	Rewrites RewriteRules
+
+	mu      sync.Mutex
+	results map[string]RepositoryHealth
+}
+
+// Start implements manager.Runnable.
+func (m *RepositoryMonitor) Start(ctx context.Context) error {
+	ticker := time.NewTicker(m.Interval)
+	defer ticker.Stop()
+	for {
+		m.checkAll(ctx)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Health returns the latest result for every monitored repository.
+func (m *RepositoryMonitor) Health() []RepositoryHealth {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]RepositoryHealth, 0, len(m.results))
+	for _, h := range m.results {
+		out = append(out, h)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].URL < out[j].URL })
+	return out
+}
+
+func (m *RepositoryMonitor) checkAll(ctx context.Context) {
+	log := ctrl.Log.WithName("repository-monitor")
+
+	var list helmv1alpha1.HelmReleaseList
+	if err := m.Client.List(ctx, &list); err != nil {
+		log.Error(err, "listing HelmReleases")
+		return
+	}
+	byRepo := map[string][]helmv1alpha1.HelmRelease{}
+	for _, hr := range list.Items {
+		repo := m.Rewrites.Rewrite(hr.Spec.RepoURL)
+		if repo != "" && !strings.HasPrefix(repo, "oci://") {
+			byRepo[repo] = append(byRepo[repo], hr)
+		}
+	}
+
+	results := make(map[string]RepositoryHealth, len(byRepo))
+	for repo, releases := range byRepo {
+		h := m.check(ctx, repo)
+		h.Releases = len(releases)
+		results[repo] = h
+
+		up := 0.0
+		if h.Healthy {
+			up = 1
+		} else {
+			log.Info("Repository index check failed", "repoURL", repo, "error", h.Error)
+		}
+		repositoryUpGauge.WithLabelValues(repo).Set(up)
+		repositoryCheckSeconds.WithLabelValues(repo).Set(h.Latency.Seconds())
+
+		for i := range releases {
+			if err := m.annotate(ctx, &releases[i], h); err != nil {
+				log.Error(err, "annotating HelmRelease", "name", releases[i].Name, "namespace", releases[i].Namespace)
+			}
+		}
+	}
+
+	m.mu.Lock()
+	for repo := range m.results {
+		if _, ok := results[repo]; !ok {
+			repositoryUpGauge.DeleteLabelValues(repo)
+			repositoryCheckSeconds.DeleteLabelValues(repo)
+		}
+	}
+	m.results = results
+	m.mu.Unlock()
+}
+
+// check fetches <repo>/index.yaml and reports whether it was served.
+func (m *RepositoryMonitor) check(ctx context.Context, repo string) RepositoryHealth {
+	h := RepositoryHealth{URL: repo, CheckedAt: time.Now()}
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(repo, "/")+"/index.yaml", nil)
+	if err != nil {
+		h.Error = err.Error()
+		return h
+	}
+	req.Header.Set("User-Agent", DownloadConfig{UserAgent: m.UserAgent}.userAgent())
+	httpClient := m.HTTPClient
+	if httpClient == nil {
+		transport, err := m.Proxy.transport("")
+		if err != nil {
+			h.Error = err.Error()
+			return h
+		}
+		httpClient = &http.Client{Transport: transport}
+	}
+	resp, err := httpClient.Do(req)
+	h.Latency = time.Since(h.CheckedAt)
+	if err != nil {
+		h.Error = err.Error()
+		return h
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		h.Error = fmt.Sprintf("index.yaml returned %s", resp.Status)
+		return h
+	}
+	h.Healthy = true
+	return h
+}
+
+// annotate sets or clears AnnotationRepositoryHealth on hr to match h.
+func (m *RepositoryMonitor) annotate(ctx context.Context, hr *helmv1alpha1.HelmRelease, h RepositoryHealth) error {
+	current, has := hr.Annotations[AnnotationRepositoryHealth]
+	var value interface{} // nil removes the annotation in a merge patch
+	if !h.Healthy {
+		msg := fmt.Sprintf("%s: %s", h.CheckedAt.UTC().Format(time.RFC3339), h.Error)
+		// Keep the first failure time while the error is unchanged to avoid
+		// rewriting every release on every check.
+		if has && strings.HasSuffix(current, ": "+h.Error) {
+			return nil
+		}
+		value = msg
+	} else if !has {
+		return nil
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{AnnotationRepositoryHealth: value},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	obj := &helmv1alpha1.HelmRelease{}
+	obj.Name, obj.Namespace = hr.Name, hr.Namespace
+	return client.IgnoreNotFound(m.Client.Patch(ctx, obj, client.RawPatch(types.MergePatchType, patch)))
+}
@@ -0,0 +1,86 @@
+package controllers_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/example/helm-operator/controllers"
+)
+
+var _ = Describe("RepositoryMonitor", func() {
+	ctx := context.Background()
+
+	It("reports unhealthy repositories and annotates their releases", func() {
+		repo := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "down for maintenance", http.StatusServiceUnavailable)
+		}))
+		defer repo.Close()
+
+		hr := makeHR("test-repo-monitor")
+		hr.Spec.RepoURL = repo.URL
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+		monitor := &controllers.RepositoryMonitor{
+			Client:   k8sClient,
+			Interval: 100 * time.Millisecond,
+			Timeout:  time.Second,
+		}
+		monitorCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		go func() {
+			defer GinkgoRecover()
+			Expect(monitor.Start(monitorCtx)).To(Succeed())
+		}()
+
+		Eventually(func(g Gomega) {
+			var health *controllers.RepositoryHealth
+			for _, h := range monitor.Health() {
+				if h.URL == repo.URL {
+					h := h
+					health = &h
+				}
+			}
+			g.Expect(health).NotTo(BeNil())
+			g.Expect(health.Healthy).To(BeFalse())
+			g.Expect(health.Error).To(ContainSubstring("503"))
+			g.Expect(health.Releases).To(Equal(1))
+
+			fetched, err := getHR(ctx, hr.Name)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(fetched.Annotations).To(HaveKeyWithValue(controllers.AnnotationRepositoryHealth, ContainSubstring("503")))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+	})
+
+	It("identifies itself with the operator User-Agent", func() {
+		userAgents := make(chan string, 10)
+		repo := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case userAgents <- r.UserAgent():
+			default:
+			}
+			w.Write([]byte("apiVersion: v1\nentries: {}\n"))
+		}))
+		defer repo.Close()
+
+		hr := makeHR("test-repo-monitor-ua")
+		hr.Spec.RepoURL = repo.URL
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+		monitor := &controllers.RepositoryMonitor{Client: k8sClient, Interval: time.Hour, Timeout: time.Second}
+		monitorCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		go func() {
+			defer GinkgoRecover()
+			Expect(monitor.Start(monitorCtx)).To(Succeed())
+		}()
+
+		Eventually(userAgents).WithTimeout(timeout).Should(Receive(Equal(controllers.DefaultUserAgent)))
+	})
+})
@@ -0,0 +1,84 @@
+package controllers
+
+import (
+	"fmt"
+	"time"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	conditionStalled = "Stalled"
+
+	// maxFailureBackoff caps the wait between retries of a failing release.
+	maxFailureBackoff = 10 * time.Minute
+)
+
+// failureBackoff returns how long to wait after the given number of failed
+// attempts before retrying: requeueOnFailure, doubled for every failure
+// after the first, up to maxFailureBackoff.
+func failureBackoff(failures int) time.Duration {
+	wait := requeueOnFailure
+	for i := 1; i < failures && wait < maxFailureBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, maxFailureBackoff)
+}
+
+// recordFailure counts a failed attempt in status.failures and
+// status.lastFailureTime. The count restarts with the first failure of a new
+// generation, so a changed spec gets the whole of spec.maxRetries.
+func recordFailure(release *helmv1alpha1.HelmRelease, now time.Time) {
+	if release.Status.ObservedGeneration != release.Generation {
+		release.Status.Failures = 0
+	}
+	release.Status.Failures++
+	release.Status.LastFailureTime = &metav1.Time{Time: now}
+}
+
+// stallIfExhausted sets Stalled=True once a release has failed more than
+// spec.maxRetries times for its generation. Stalled releases are not
+// retried until the spec changes.
+func (r *HelmReleaseReconciler) stallIfExhausted(release *helmv1alpha1.HelmRelease, err error) {
+	maxRetries := release.Spec.MaxRetries
+	if maxRetries == nil || release.Status.Failures <= *maxRetries || stalled(release) {
+		return
+	}
+	message := fmt.Sprintf("Failed %d times, exceeding spec.maxRetries of %d; not retrying until the spec changes. Last error: %v",
+		release.Status.Failures, *maxRetries, err)
+	setCondition(release, metav1.Condition{
+		Type:               conditionStalled,
+		Status:             metav1.ConditionTrue,
+		Reason:             "RetriesExhausted",
+		Message:            message,
+		ObservedGeneration: release.Generation,
+	})
+	r.event(release, corev1.EventTypeWarning, "Stalled", message)
+}
+
+// stalled reports whether the current generation of release used up its
+// retries.
+func stalled(release *helmv1alpha1.HelmRelease) bool {
+	c := meta.FindStatusCondition(release.Status.Conditions, conditionStalled)
+	return c != nil && c.Status == metav1.ConditionTrue && c.ObservedGeneration == release.Generation
+}
+
+// clearStalled marks a stalled release as retrying again, after its spec
+// changed or it succeeded.
+func clearStalled(release *helmv1alpha1.HelmRelease) {
+	for _, c := range release.Status.Conditions {
+		if c.Type == conditionStalled && c.Status != metav1.ConditionFalse {
+			setCondition(release, metav1.Condition{
+				Type:               conditionStalled,
+				Status:             metav1.ConditionFalse,
+				Reason:             "Retrying",
+				Message:            "The spec changed since the retries were exhausted",
+				ObservedGeneration: release.Generation,
+			})
+			return
+		}
+	}
+}
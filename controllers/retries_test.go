@@ -0,0 +1,61 @@
+package controllers_test
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Retry budget", func() {
+	ctx := context.Background()
+
+	It("stalls a release after spec.maxRetries until its spec changes", func() {
+		mock := &MockHelmClient{InstallErr: errors.New("repo unavailable")}
+		cancel := startManager(mock)
+		defer cancel()
+
+		hr := makeHR("test-max-retries")
+		maxRetries := 0
+		hr.Spec.MaxRetries = &maxRetries
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+		Eventually(func(g Gomega) {
+			fetched, err := getHR(ctx, hr.Name)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(fetched.Status.Phase).To(Equal(helmv1alpha1.PhaseFailed))
+			g.Expect(fetched.Status.Failures).To(Equal(1))
+			g.Expect(fetched.Status.LastFailureTime).NotTo(BeNil())
+			cond := findCondition(fetched, "Stalled")
+			g.Expect(cond).NotTo(BeNil())
+			g.Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+			g.Expect(cond.Message).To(ContainSubstring("repo unavailable"))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+
+		mock.mu.Lock()
+		mock.InstallErr = nil
+		mock.mu.Unlock()
+
+		fetched, err := getHR(ctx, hr.Name)
+		Expect(err).NotTo(HaveOccurred())
+		patch := client.MergeFrom(fetched.DeepCopy())
+		fetched.Spec.Version = "1.0.1"
+		Expect(k8sClient.Patch(ctx, fetched, patch)).To(Succeed())
+
+		Eventually(func(g Gomega) {
+			fetched, err := getHR(ctx, hr.Name)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(fetched.Status.Phase).To(Equal(helmv1alpha1.PhaseReady))
+			g.Expect(fetched.Status.Failures).To(BeZero())
+			cond := findCondition(fetched, "Stalled")
+			g.Expect(cond).NotTo(BeNil())
+			g.Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+	})
+})
@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RewriteRule replaces the From prefix of a chart repository or image
+// reference with To, e.g. registry.example.com/ → mirror.internal/.
+type RewriteRule struct {
+	From string
+	To   string
+}
+
+// RewriteRules are operator-level rewrites applied to chart repository URLs
+// and to image references in release values, so air-gapped clusters can be
+// pointed at internal mirrors without editing every HelmRelease. The first
+// matching rule wins.
+type RewriteRules []RewriteRule
+
+// ParseRewriteRules parses the --rewrite flag: a comma-separated list of
+// from=to prefixes. A trailing "*" on either side is accepted and ignored, so
+// "registry.example.com/*=mirror.internal/*" works as expected.
+func ParseRewriteRules(s string) (RewriteRules, error) {
+	var rules RewriteRules
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		from, to, ok := strings.Cut(entry, "=")
+		from, to = strings.TrimSuffix(from, "*"), strings.TrimSuffix(to, "*")
+		if !ok || from == "" || to == "" {
+			return nil, fmt.Errorf("rewrite rule %q: expected from=to", entry)
+		}
+		rules = append(rules, RewriteRule{From: from, To: to})
+	}
+	return rules, nil
+}
+
+// Rewrite applies the first rule matching ref. A URL scheme such as https://
+// or oci:// is kept and not considered part of the prefix. A ref equal to a
+// rule's From without its trailing slash (a bare registry host) is rewritten
+// to To without its trailing slash.
+func (rules RewriteRules) Rewrite(ref string) string {
+	scheme, rest, ok := strings.Cut(ref, "://")
+	if !ok {
+		scheme, rest = "", ref
+	} else {
+		scheme += "://"
+	}
+	for _, rule := range rules {
+		if strings.HasPrefix(rest, rule.From) {
+			return scheme + rule.To + strings.TrimPrefix(rest, rule.From)
+		}
+		if trimmed := strings.TrimSuffix(rule.From, "/"); trimmed != rule.From && rest == trimmed {
+			return scheme + strings.TrimSuffix(rule.To, "/")
+		}
+	}
+	return ref
+}
+
+// RewriteValues rewrites, in place, every string in values that matches a
+// rule. This covers the common image.repository / image.registry / image
+// layouts without knowing each chart's schema.
+func (rules RewriteRules) RewriteValues(values map[string]interface{}) {
+	if len(rules) == 0 {
+		return
+	}
+	for k, v := range values {
+		values[k] = rules.rewriteValue(v)
+	}
+}
+
+func (rules RewriteRules) rewriteValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case string:
+		return rules.Rewrite(v)
+	case map[string]interface{}:
+		rules.RewriteValues(v)
+	case []interface{}:
+		for i := range v {
+			v[i] = rules.rewriteValue(v[i])
+		}
+	}
+	return v
+}
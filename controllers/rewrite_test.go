@@ -0,0 +1,52 @@
+package controllers_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/example/helm-operator/controllers"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+var _ = Describe("RewriteRules", func() {
+	ctx := context.Background()
+
+	It("parses and applies prefix rules", func() {
+		rules, err := controllers.ParseRewriteRules("registry.example.com/*=mirror.internal/*, charts.example.com/=mirror.internal/charts/")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rules.Rewrite("registry.example.com/team/app")).To(Equal("mirror.internal/team/app"))
+		Expect(rules.Rewrite("oci://registry.example.com/charts")).To(Equal("oci://mirror.internal/charts"))
+		Expect(rules.Rewrite("registry.example.com")).To(Equal("mirror.internal"))
+		Expect(rules.Rewrite("docker.io/library/nginx")).To(Equal("docker.io/library/nginx"))
+
+		_, err = controllers.ParseRewriteRules("missing-target")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("rewrites the repository URL and image values passed to Helm", func() {
+		mock := &MockHelmClient{}
+		cancel := startManager(mock, func(r *controllers.HelmReleaseReconciler) {
+			r.Rewrites = controllers.RewriteRules{
+				{From: "charts.example.com", To: "mirror.internal/charts"},
+				{From: "registry.example.com/", To: "mirror.internal/"},
+			}
+		})
+		defer cancel()
+
+		hr := makeHR("test-rewrite")
+		hr.Spec.Values = &apiextensionsv1.JSON{Raw: []byte(`{"image":{"repository":"registry.example.com/team/app"}}`)}
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+		Eventually(func(g Gomega) {
+			mock.mu.Lock()
+			args := mock.InstallArgs
+			mock.mu.Unlock()
+			g.Expect(args.RepoURL).To(Equal("https://mirror.internal/charts"))
+			g.Expect(args.Values).To(HaveKeyWithValue("image",
+				HaveKeyWithValue("repository", "mirror.internal/team/app")))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+	})
+})
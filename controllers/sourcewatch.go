@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"context"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// sourceKey identifies a ConfigMap or Secret.
+type sourceKey struct {
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+// sourceWaiters records which HelmReleases wait for which valuesFrom or
+// substituteFrom object, so creating the object reconciles them right away
+// instead of at their next retry.
+type sourceWaiters struct {
+	mu      sync.Mutex
+	sources map[sourceKey]map[types.NamespacedName]bool
+	// releases maps each waiting release to the object it waits for.
+	releases map[types.NamespacedName]sourceKey
+}
+
+// wait records that release waits for source, replacing what it waited for
+// before.
+func (w *sourceWaiters) wait(release types.NamespacedName, source sourceKey) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.doneLocked(release)
+	if w.sources == nil {
+		w.sources = map[sourceKey]map[types.NamespacedName]bool{}
+		w.releases = map[types.NamespacedName]sourceKey{}
+	}
+	if w.sources[source] == nil {
+		w.sources[source] = map[types.NamespacedName]bool{}
+	}
+	w.sources[source][release] = true
+	w.releases[release] = source
+}
+
+// done records that release no longer waits.
+func (w *sourceWaiters) done(release types.NamespacedName) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.doneLocked(release)
+}
+
+func (w *sourceWaiters) doneLocked(release types.NamespacedName) {
+	source, ok := w.releases[release]
+	if !ok {
+		return
+	}
+	delete(w.releases, release)
+	delete(w.sources[source], release)
+	if len(w.sources[source]) == 0 {
+		delete(w.sources, source)
+	}
+}
+
+// waiters returns the releases waiting for source.
+func (w *sourceWaiters) waiters(source sourceKey) []types.NamespacedName {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	out := make([]types.NamespacedName, 0, len(w.sources[source]))
+	for release := range w.sources[source] {
+		out = append(out, release)
+	}
+	return out
+}
+
+// sourceHandler reconciles the releases waiting for a ConfigMap or Secret,
+// as given by kind, when it is created or changed. The objects are watched
+// by metadata only, so their data is never cached.
+func (r *HelmReleaseReconciler) sourceHandler(kind string) handler.EventHandler {
+	return handler.EnqueueRequestsFromMapFunc(func(_ context.Context, obj client.Object) []reconcile.Request {
+		waiting := r.sources.waiters(sourceKey{Kind: kind, Namespace: obj.GetNamespace(), Name: obj.GetName()})
+		requests := make([]reconcile.Request, 0, len(waiting))
+		for _, key := range waiting {
+			requests = append(requests, ctrl.Request{NamespacedName: key})
+		}
+		return requests
+	})
+}
@@ -9,6 +9,7 @@ import (
 
 	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
 	"github.com/example/helm-operator/controllers"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
@@ -46,6 +47,7 @@ var _ = BeforeSuite(func() {
 
 	Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
 	Expect(helmv1alpha1.AddToScheme(scheme)).To(Succeed())
+	Expect(apiextensionsv1.AddToScheme(scheme)).To(Succeed())
 
 	k8sClient, err = client.New(cfg, client.Options{Scheme: scheme})
 	Expect(err).NotTo(HaveOccurred())
@@ -58,8 +60,9 @@ var _ = AfterSuite(func() {
 
 // startManager creates a manager with a fresh HelmReleaseReconciler backed by
 // the given mock, starts it in a goroutine, and returns a cancel function that
-// the caller must defer.
-func startManager(mock *MockHelmClient) context.CancelFunc {
+// the caller must defer. Optional configure funcs may adjust the reconciler
+// before it is registered.
+func startManager(mock *MockHelmClient, configure ...func(*controllers.HelmReleaseReconciler)) context.CancelFunc {
 	mgr, err := ctrl.NewManager(cfg, ctrl.Options{
 		Scheme: scheme,
 		Metrics: metricsserver.Options{
@@ -69,11 +72,15 @@ func startManager(mock *MockHelmClient) context.CancelFunc {
 	})
 	Expect(err).NotTo(HaveOccurred())
 
-	err = (&controllers.HelmReleaseReconciler{
+	reconciler := &controllers.HelmReleaseReconciler{
 		Client:     mgr.GetClient(),
 		Scheme:     mgr.GetScheme(),
 		HelmClient: mock,
-	}).SetupWithManager(mgr)
+	}
+	for _, fn := range configure {
+		fn(reconciler)
+	}
+	err = reconciler.SetupWithManager(mgr)
 	Expect(err).NotTo(HaveOccurred())
 
 	ctx, cancel := context.WithCancel(context.Background())
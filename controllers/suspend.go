@@ -0,0 +1,46 @@
+package controllers
+
+import (
+	"context"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const conditionSuspended = "Suspended"
+
+// reconcileSuspended records that the release is suspended and does nothing
+// else; the deployed release is left as it is.
+func (r *HelmReleaseReconciler) reconcileSuspended(ctx context.Context, release *helmv1alpha1.HelmRelease) (ctrl.Result, error) {
+	base := release.DeepCopy()
+	release.Status.ExpiresAt = ttlExpiry(release)
+	setCondition(release, metav1.Condition{
+		Type:               conditionSuspended,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Suspended",
+		Message:            "spec.suspend is set; Helm operations are paused",
+		ObservedGeneration: release.Generation,
+	})
+	if err := r.patchStatus(ctx, release, base); err != nil {
+		return ctrl.Result{}, err
+	}
+	ctrl.LoggerFrom(ctx).V(1).Info("Release suspended, skipping")
+	return ctrl.Result{}, nil
+}
+
+// clearSuspended sets Suspended to False if the release was suspended.
+func clearSuspended(release *helmv1alpha1.HelmRelease) {
+	for _, c := range release.Status.Conditions {
+		if c.Type == conditionSuspended && c.Status != metav1.ConditionFalse {
+			setCondition(release, metav1.Condition{
+				Type:               conditionSuspended,
+				Status:             metav1.ConditionFalse,
+				Reason:             "Resumed",
+				Message:            "Helm operations are enabled",
+				ObservedGeneration: release.Generation,
+			})
+			return
+		}
+	}
+}
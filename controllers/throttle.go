@@ -0,0 +1,46 @@
+package controllers
+
+import (
+	"context"
+	"net/url"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	clientmetrics "k8s.io/client-go/tools/metrics"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// throttledLatency is the client-side rate limiter wait above which a
+// request counts as throttled; client-go logs such waits from the same
+// threshold.
+const throttledLatency = 50 * time.Millisecond
+
+var (
+	rateLimiterSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "helm_operator_client_rate_limiter_seconds",
+		Help:    "Time API server requests waited for the client-side rate limiter (--kube-api-qps, --kube-api-burst), by verb.",
+		Buckets: []float64{0.005, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+	}, []string{"verb"})
+	throttledRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "helm_operator_client_throttled_requests_total",
+		Help: "API server requests delayed more than 50ms by the client-side rate limiter, by verb.",
+	}, []string{"verb"})
+)
+
+func init() {
+	ctrlmetrics.Registry.MustRegister(rateLimiterSeconds, throttledRequests)
+	// controller-runtime registers only the request result metric with
+	// client-go, so the rate limiter hook is still free.
+	clientmetrics.RateLimiterLatency = rateLimiterMetric{}
+}
+
+// rateLimiterMetric receives client-go's rate limiter waits for every
+// client built from a rest.Config, including Helm's.
+type rateLimiterMetric struct{}
+
+func (rateLimiterMetric) Observe(_ context.Context, verb string, _ url.URL, latency time.Duration) {
+	rateLimiterSeconds.WithLabelValues(verb).Observe(latency.Seconds())
+	if latency > throttledLatency {
+		throttledRequests.WithLabelValues(verb).Inc()
+	}
+}
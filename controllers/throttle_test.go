@@ -0,0 +1,42 @@
+package controllers_test
+
+import (
+	"context"
+	"net/url"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	clientmetrics "k8s.io/client-go/tools/metrics"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var _ = Describe("Client throttling metrics", func() {
+	throttled := func() float64 {
+		families, err := ctrlmetrics.Registry.Gather()
+		Expect(err).NotTo(HaveOccurred())
+		for _, f := range families {
+			if f.GetName() != "helm_operator_client_throttled_requests_total" {
+				continue
+			}
+			for _, m := range f.GetMetric() {
+				for _, l := range m.GetLabel() {
+					if l.GetName() == "verb" && l.GetValue() == "PATCH" {
+						return m.GetCounter().GetValue()
+					}
+				}
+			}
+		}
+		return 0
+	}
+
+	It("counts requests delayed by the client-side rate limiter", func() {
+		before := throttled()
+		u := url.URL{Path: "/apis/helm.example.com/v1alpha1"}
+		clientmetrics.RateLimiterLatency.Observe(context.Background(), "PATCH", u, time.Millisecond)
+		Expect(throttled()).To(Equal(before))
+		clientmetrics.RateLimiterLatency.Observe(context.Background(), "PATCH", u, 2*time.Second)
+		Expect(throttled()).To(Equal(before + 1))
+	})
+})
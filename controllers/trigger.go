@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/releaseutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// HelmRelease annotations describing who or what produced the current spec.
+// Tools that change the spec are expected to update them with it.
+const (
+	// AnnotationTriggeredBy is set by the web UI to the user making a change.
+	AnnotationTriggeredBy = "helm.example.com/triggered-by"
+	// AnnotationCommitSHA is set by GitOps pipelines to the source commit.
+	AnnotationCommitSHA = "helm.example.com/commit-sha"
+	// AnnotationCIJobURL is set by CI pipelines to the job that applied the spec.
+	AnnotationCIJobURL = "helm.example.com/ci-job-url"
+)
+
+// Causes of an install or upgrade, recorded in status.lastDeployTrigger.
+const (
+	CauseInstall         = "Install"
+	CauseSpecChange      = "SpecChange"
+	CauseDriftCorrection = "DriftCorrection"
+	CauseRetry           = "Retry"
+)
+
+// deployTrigger describes the operation about to run for cause.
+func deployTrigger(release *helmv1alpha1.HelmRelease, cause string) *helmv1alpha1.DeployTrigger {
+	return &helmv1alpha1.DeployTrigger{
+		Cause:     cause,
+		User:      release.Annotations[AnnotationTriggeredBy],
+		CommitSHA: release.Annotations[AnnotationCommitSHA],
+		CIJobURL:  release.Annotations[AnnotationCIJobURL],
+		Time:      metav1.Now(),
+	}
+}
+
+// description renders the trigger as a Helm revision description, e.g.
+// "SpecChange by alice, commit 3f2a9c1, job https://ci.example.com/42".
+func description(t *helmv1alpha1.DeployTrigger) string {
+	parts := []string{t.Cause}
+	if t.User != "" {
+		parts[0] += " by " + t.User
+	}
+	if t.CommitSHA != "" {
+		parts = append(parts, "commit "+t.CommitSHA)
+	}
+	if t.CIJobURL != "" {
+		parts = append(parts, "job "+t.CIJobURL)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// History returns the revisions of a Helm release, newest first.
+func (h *HelmClient) History(_ context.Context, releaseName, namespace string) ([]*release.Release, error) {
+	cfg, err := h.actionConfig(namespace)
+	if err != nil {
+		return nil, err
+	}
+	revisions, err := action.NewHistory(cfg).Run(releaseName)
+	if err != nil {
+		return nil, fmt.Errorf("reading release history: %w", err)
+	}
+	releaseutil.SortByRevision(revisions)
+	for i, j := 0, len(revisions)-1; i < j; i, j = i+1, j-1 {
+		revisions[i], revisions[j] = revisions[j], revisions[i]
+	}
+	return revisions, nil
+}
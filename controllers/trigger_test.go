@@ -0,0 +1,57 @@
+package controllers_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/example/helm-operator/controllers"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Deploy triggers", func() {
+	ctx := context.Background()
+
+	It("records the trigger annotations in the Helm description and status", func() {
+		mock := &MockHelmClient{}
+		cancel := startManager(mock)
+		defer cancel()
+
+		hr := makeHR("test-deploy-trigger")
+		hr.Annotations = map[string]string{
+			controllers.AnnotationTriggeredBy: "alice",
+			controllers.AnnotationCommitSHA:   "3f2a9c1",
+			controllers.AnnotationCIJobURL:    "https://ci.example.com/jobs/42",
+		}
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+		Eventually(func(g Gomega) {
+			fetched, err := getHR(ctx, hr.Name)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(fetched.Status.LastDeployTrigger).NotTo(BeNil())
+			g.Expect(fetched.Status.LastDeployTrigger.Cause).To(Equal(controllers.CauseInstall))
+			g.Expect(fetched.Status.LastDeployTrigger.User).To(Equal("alice"))
+			g.Expect(fetched.Status.LastDeployTrigger.CommitSHA).To(Equal("3f2a9c1"))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+
+		mock.mu.Lock()
+		Expect(mock.InstallArgs.Opts.Description).To(Equal("Install by alice, commit 3f2a9c1, job https://ci.example.com/jobs/42"))
+		mock.ReleaseExistsResult = true
+		mock.mu.Unlock()
+
+		fetched, err := getHR(ctx, hr.Name)
+		Expect(err).NotTo(HaveOccurred())
+		patch := client.MergeFrom(fetched.DeepCopy())
+		fetched.Spec.Version = "2.0.0"
+		delete(fetched.Annotations, controllers.AnnotationTriggeredBy)
+		Expect(k8sClient.Patch(ctx, fetched, patch)).To(Succeed())
+
+		Eventually(func(g Gomega) {
+			mock.mu.Lock()
+			defer mock.mu.Unlock()
+			g.Expect(mock.UpgradeArgs.Opts.Description).To(Equal("SpecChange, commit 3f2a9c1, job https://ci.example.com/jobs/42"))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+	})
+})
@@ -0,0 +1,40 @@
+package controllers
+
+import (
+	"time"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// ttlExpiry returns the time at which the release's TTL elapses, or nil if
+// the release has no TTL. The TTL is measured from the HelmRelease's creation
+// so that spec edits (e.g. a CI pipeline bumping the image tag) do not extend it.
+func ttlExpiry(release *helmv1alpha1.HelmRelease) *metav1.Time {
+	if release.Spec.TTL == nil {
+		return nil
+	}
+	expiresAt := metav1.NewTime(release.CreationTimestamp.Add(release.Spec.TTL.Duration))
+	return &expiresAt
+}
+
+// ttlExpired reports whether the release has a TTL that has already elapsed.
+func ttlExpired(release *helmv1alpha1.HelmRelease) bool {
+	expiresAt := ttlExpiry(release)
+	return expiresAt != nil && !time.Now().Before(expiresAt.Time)
+}
+
+// requeueBeforeExpiry shortens result.RequeueAfter so that the release is
+// reconciled again as soon as its TTL elapses.
+func requeueBeforeExpiry(release *helmv1alpha1.HelmRelease, result ctrl.Result) ctrl.Result {
+	expiresAt := ttlExpiry(release)
+	if expiresAt == nil {
+		return result
+	}
+	remaining := time.Until(expiresAt.Time)
+	if remaining > 0 && (result.RequeueAfter == 0 || remaining < result.RequeueAfter) {
+		result.RequeueAfter = remaining
+	}
+	return result
+}
@@ -0,0 +1,135 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"github.com/prometheus/client_golang/prometheus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const conditionUpdateAvailable = "UpdateAvailable"
+
+// IndexCacheRequestsMetric counts update cache lookups by result (hit or
+// miss). The UI admin page derives the cache hit ratio from it.
+const IndexCacheRequestsMetric = "helm_operator_index_cache_requests_total"
+
+var indexCacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: IndexCacheRequestsMetric,
+	Help: "Repository index cache lookups made by update checks, by result (hit or miss).",
+}, []string{"result"})
+
+// indexDownloads counts repository index requests by result: downloaded,
+// or not_modified when a cached index was still current.
+var indexDownloads = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "helm_operator_index_downloads_total",
+	Help: "Repository index requests, by result (downloaded or not_modified).",
+}, []string{"result"})
+
+func init() {
+	ctrlmetrics.Registry.MustRegister(indexCacheRequests, indexDownloads)
+}
+
+// LatestVersions fetches the index of repoURL and returns the newest stable
+// version of every chart in it.
+func (h *HelmClient) LatestVersions(ctx context.Context, repoURL string, opts ChartFetchOptions) (map[string]string, error) {
+	index, err := h.repoIndex(ctx, repoURL, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	latest := make(map[string]string, len(index.Entries))
+	for name := range index.Entries {
+		if cv, err := index.Get(name, ""); err == nil {
+			latest[name] = cv.Version
+		}
+	}
+	return latest, nil
+}
+
+// updateCache remembers each repository's latest chart versions for one
+// update check interval, so releases sharing a repository fetch its index once.
+type updateCache struct {
+	mu      sync.Mutex
+	entries map[string]updateCacheEntry
+}
+
+type updateCacheEntry struct {
+	fetched time.Time
+	latest  map[string]string
+}
+
+func (c *updateCache) get(repoURL string, maxAge time.Duration) (map[string]string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[repoURL]
+	if !ok || time.Since(e.fetched) > maxAge {
+		indexCacheRequests.WithLabelValues("miss").Inc()
+		return nil, false
+	}
+	indexCacheRequests.WithLabelValues("hit").Inc()
+	return e.latest, true
+}
+
+func (c *updateCache) put(repoURL string, latest map[string]string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = map[string]updateCacheEntry{}
+	}
+	c.entries[repoURL] = updateCacheEntry{fetched: time.Now(), latest: latest}
+}
+
+// checkForUpdate records the newest version of the release's chart in
+// status.latestVersion and sets the UpdateAvailable condition. Failures to
+// reach the repository are logged and leave the previous result in place.
+func (r *HelmReleaseReconciler) checkForUpdate(ctx context.Context, release *helmv1alpha1.HelmRelease, repoURL string,
+	fetch ChartFetchOptions) {
+	if !strings.HasPrefix(repoURL, "http://") && !strings.HasPrefix(repoURL, "https://") {
+		return // OCI registries have no index to compare against
+	}
+	latest, ok := r.updates.get(repoURL, r.UpdateCheckInterval)
+	if !ok {
+		var err error
+		if latest, err = r.HelmClient.LatestVersions(ctx, repoURL, fetch); err != nil {
+			ctrl.LoggerFrom(ctx).Info("Skipping update check", "repoURL", repoURL, "error", err.Error())
+			return
+		}
+		r.updates.put(repoURL, latest)
+	}
+
+	version, ok := latest[release.Spec.Chart]
+	if !ok {
+		return
+	}
+	release.Status.LatestVersion = version
+
+	condition := metav1.Condition{
+		Type:               conditionUpdateAvailable,
+		Status:             metav1.ConditionFalse,
+		Reason:             "UpToDate",
+		Message:            fmt.Sprintf("%s %s is the latest version", release.Spec.Chart, release.Status.DeployedVersion),
+		ObservedGeneration: release.Generation,
+	}
+	deployed, err := semver.NewVersion(release.Status.DeployedVersion)
+	available, availableErr := semver.NewVersion(version)
+	switch {
+	case err != nil || availableErr != nil:
+		condition.Status = metav1.ConditionUnknown
+		condition.Reason = "VersionNotComparable"
+		condition.Message = fmt.Sprintf("cannot compare deployed version %q with latest version %q",
+			release.Status.DeployedVersion, version)
+	case available.GreaterThan(deployed):
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "NewerVersionAvailable"
+		condition.Message = fmt.Sprintf("%s %s is available (deployed: %s)", release.Spec.Chart, version, release.Status.DeployedVersion)
+	}
+	setCondition(release, condition)
+}
@@ -0,0 +1,58 @@
+package controllers_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/example/helm-operator/controllers"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Update checks", func() {
+	ctx := context.Background()
+
+	It("reports a newer chart version in the UpdateAvailable condition", func() {
+		mock := &MockHelmClient{LatestVersionsResult: map[string]string{"nginx": "1.2.0"}}
+		cancel := startManager(mock, func(r *controllers.HelmReleaseReconciler) {
+			r.UpdateCheckInterval = time.Hour
+		})
+		defer cancel()
+
+		hr := makeHR("test-update-available")
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+		Eventually(func(g Gomega) {
+			fetched, err := getHR(ctx, hr.Name)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(fetched.Status.LatestVersion).To(Equal("1.2.0"))
+			cond := findCondition(fetched, "UpdateAvailable")
+			g.Expect(cond).NotTo(BeNil())
+			g.Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+			g.Expect(cond.Reason).To(Equal("NewerVersionAvailable"))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+	})
+
+	It("reports releases on the latest version as up to date", func() {
+		mock := &MockHelmClient{LatestVersionsResult: map[string]string{"nginx": "1.0.0"}}
+		cancel := startManager(mock, func(r *controllers.HelmReleaseReconciler) {
+			r.UpdateCheckInterval = time.Hour
+		})
+		defer cancel()
+
+		hr := makeHR("test-update-current")
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+		Eventually(func(g Gomega) {
+			fetched, err := getHR(ctx, hr.Name)
+			g.Expect(err).NotTo(HaveOccurred())
+			cond := findCondition(fetched, "UpdateAvailable")
+			g.Expect(cond).NotTo(BeNil())
+			g.Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+	})
+})
@@ -0,0 +1,133 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	conditionUpgradeGates = "UpgradeGates"
+
+	// defaultGateTimeout bounds a gate request without spec timeout.
+	defaultGateTimeout = 10 * time.Second
+	// requeueForUpgradeGates is how often a closed gate is checked again.
+	requeueForUpgradeGates = time.Minute
+	// maxGateBody is how much of a gate response is searched for
+	// expectedBody.
+	maxGateBody = 64 << 10
+)
+
+// gateResult is the outcome of one upgrade gate.
+type gateResult struct {
+	name string
+	open bool
+	// unavailable is set when the gate could not be checked; its failure
+	// policy decided open.
+	unavailable bool
+	detail      string
+}
+
+func (g gateResult) String() string {
+	return fmt.Sprintf("%s: %s", g.name, g.detail)
+}
+
+// checkUpgradeGates checks the upgrade gates of release, records the
+// results in the UpgradeGates condition and reports whether all passed.
+func (r *HelmReleaseReconciler) checkUpgradeGates(ctx context.Context, release *helmv1alpha1.HelmRelease) bool {
+	if len(release.Spec.UpgradeGates) == 0 {
+		meta.RemoveStatusCondition(&release.Status.Conditions, conditionUpgradeGates)
+		return true
+	}
+	var results, blocking []string
+	reason := "GatesOpen"
+	for _, gate := range release.Spec.UpgradeGates {
+		result := r.checkGate(ctx, gate)
+		results = append(results, result.String())
+		if result.open {
+			continue
+		}
+		blocking = append(blocking, result.String())
+		if result.unavailable && reason != "GateClosed" {
+			reason = "GateUnavailable"
+		} else {
+			reason = "GateClosed"
+		}
+	}
+	if len(blocking) == 0 {
+		setCondition(release, metav1.Condition{
+			Type:               conditionUpgradeGates,
+			Status:             metav1.ConditionTrue,
+			Reason:             reason,
+			Message:            strings.Join(results, "; "),
+			ObservedGeneration: release.Generation,
+		})
+		return true
+	}
+
+	previous := meta.FindStatusCondition(release.Status.Conditions, conditionUpgradeGates)
+	announced := previous != nil && previous.Status == metav1.ConditionFalse
+	setCondition(release, metav1.Condition{
+		Type:               conditionUpgradeGates,
+		Status:             metav1.ConditionFalse,
+		Reason:             reason,
+		Message:            strings.Join(results, "; "),
+		ObservedGeneration: release.Generation,
+	})
+	if !announced {
+		r.event(release, corev1.EventTypeWarning, "UpgradeGated",
+			"Upgrade held back by upgrade gates: "+strings.Join(blocking, "; "))
+	}
+	return false
+}
+
+// checkGate fetches the URL of gate.
+func (r *HelmReleaseReconciler) checkGate(ctx context.Context, gate helmv1alpha1.UpgradeGate) gateResult {
+	timeout := defaultGateTimeout
+	if gate.Timeout != nil && gate.Timeout.Duration > 0 {
+		timeout = gate.Timeout.Duration
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	unavailable := func(detail string) gateResult {
+		open := gate.FailurePolicy == helmv1alpha1.GateFailOpen
+		if open {
+			detail += " (failurePolicy Open)"
+		}
+		return gateResult{name: gate.Name, open: open, unavailable: true, detail: detail}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, gate.URL, nil)
+	if err != nil {
+		return gateResult{name: gate.Name, detail: err.Error()}
+	}
+	client := r.GateClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return unavailable(err.Error())
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxGateBody))
+	switch {
+	case resp.StatusCode >= 500:
+		return unavailable(fmt.Sprintf("returned %s", resp.Status))
+	case err != nil:
+		return unavailable(fmt.Sprintf("reading response: %v", err))
+	case resp.StatusCode != http.StatusOK:
+		return gateResult{name: gate.Name, detail: fmt.Sprintf("closed, returned %s", resp.Status)}
+	case gate.ExpectedBody != "" && !strings.Contains(string(body), gate.ExpectedBody):
+		return gateResult{name: gate.Name, detail: fmt.Sprintf("closed, response does not contain %q", gate.ExpectedBody)}
+	}
+	return gateResult{name: gate.Name, open: true, detail: "open"}
+}
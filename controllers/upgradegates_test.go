@@ -0,0 +1,96 @@
+package controllers_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Upgrade gates", func() {
+	ctx := context.Background()
+
+	// installWithGates installs a release, then bumps its version and sets
+	// its upgrade gates.
+	installWithGates := func(mock *MockHelmClient, name string, gates ...helmv1alpha1.UpgradeGate) {
+		hr := makeHR(name)
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+		Eventually(func() bool {
+			mock.mu.Lock()
+			defer mock.mu.Unlock()
+			return mock.InstallCalled
+		}).WithTimeout(timeout).WithPolling(polling).Should(BeTrue())
+		mock.mu.Lock()
+		mock.ReleaseExistsResult = true
+		mock.mu.Unlock()
+
+		fetched, err := getHR(ctx, name)
+		Expect(err).NotTo(HaveOccurred())
+		patch := client.MergeFrom(fetched.DeepCopy())
+		fetched.Spec.Version = "2.0.0"
+		fetched.Spec.UpgradeGates = gates
+		Expect(k8sClient.Patch(ctx, fetched, patch)).To(Succeed())
+	}
+
+	upgraded := func(mock *MockHelmClient) func() bool {
+		return func() bool {
+			mock.mu.Lock()
+			defer mock.mu.Unlock()
+			return mock.UpgradeCalled
+		}
+	}
+
+	It("holds upgrades back while a gate is closed", func() {
+		window := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			fmt.Fprint(w, `{"window":"closed"}`)
+		}))
+		DeferCleanup(window.Close)
+
+		mock := &MockHelmClient{}
+		cancel := startManager(mock)
+		defer cancel()
+		installWithGates(mock, "test-gate-closed",
+			helmv1alpha1.UpgradeGate{Name: "change-window", URL: window.URL, ExpectedBody: `"window":"open"`})
+
+		Eventually(func(g Gomega) {
+			fetched, err := getHR(ctx, "test-gate-closed")
+			g.Expect(err).NotTo(HaveOccurred())
+			cond := findCondition(fetched, "UpgradeGates")
+			g.Expect(cond).NotTo(BeNil())
+			g.Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+			g.Expect(cond.Reason).To(Equal("GateClosed"))
+			g.Expect(cond.Message).To(ContainSubstring("change-window: closed"))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+		Consistently(upgraded(mock)).WithTimeout(time.Second).WithPolling(polling).Should(BeFalse())
+	})
+
+	It("upgrades when an unreachable gate fails open", func() {
+		down := httptest.NewServer(http.NotFoundHandler())
+		down.Close()
+
+		mock := &MockHelmClient{}
+		cancel := startManager(mock)
+		defer cancel()
+		installWithGates(mock, "test-gate-fail-open",
+			helmv1alpha1.UpgradeGate{Name: "cab", URL: down.URL, FailurePolicy: helmv1alpha1.GateFailOpen})
+
+		Eventually(upgraded(mock)).WithTimeout(timeout).WithPolling(polling).Should(BeTrue())
+		Eventually(func(g Gomega) {
+			fetched, err := getHR(ctx, "test-gate-fail-open")
+			g.Expect(err).NotTo(HaveOccurred())
+			cond := findCondition(fetched, "UpgradeGates")
+			g.Expect(cond).NotTo(BeNil())
+			g.Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+			g.Expect(cond.Message).To(ContainSubstring("failurePolicy Open"))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+	})
+})
@@ -0,0 +1,55 @@
+package controllers
+
+import (
+	"strings"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"helm.sh/helm/v3/pkg/chart"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const conditionValidationRelaxed = "ValidationRelaxed"
+
+// dropSchemas removes the values schema of c and its subcharts, so that
+// values are not validated against them. Helm has no option for this.
+func dropSchemas(c *chart.Chart) {
+	c.Schema = nil
+	for _, dep := range c.Dependencies() {
+		dropSchemas(dep)
+	}
+}
+
+// setValidationRelaxed reports the validation escape hatches release uses in
+// the ValidationRelaxed condition, so they stay visible after the chart is
+// fixed. A release that stops using them has it set to False.
+func setValidationRelaxed(release *helmv1alpha1.HelmRelease) {
+	var skipped []string
+	if release.Spec.SkipSchemaValidation {
+		skipped = append(skipped, "values are not validated against the chart's values.schema.json")
+	}
+	if release.Spec.DisableOpenAPIValidation {
+		skipped = append(skipped, "manifests are not validated against the Kubernetes OpenAPI schema")
+	}
+	if len(skipped) > 0 {
+		setCondition(release, metav1.Condition{
+			Type:               conditionValidationRelaxed,
+			Status:             metav1.ConditionTrue,
+			Reason:             "ValidationSkipped",
+			Message:            "Validation is relaxed: " + strings.Join(skipped, "; "),
+			ObservedGeneration: release.Generation,
+		})
+		return
+	}
+	for _, c := range release.Status.Conditions {
+		if c.Type == conditionValidationRelaxed && c.Status != metav1.ConditionFalse {
+			setCondition(release, metav1.Condition{
+				Type:               conditionValidationRelaxed,
+				Status:             metav1.ConditionFalse,
+				Reason:             "FullValidation",
+				Message:            "Values and manifests are fully validated",
+				ObservedGeneration: release.Generation,
+			})
+			return
+		}
+	}
+}
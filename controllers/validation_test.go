@@ -0,0 +1,70 @@
+package controllers_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Validation escape hatches", func() {
+	ctx := context.Background()
+
+	expectRelaxed := func(name string, status metav1.ConditionStatus) {
+		Eventually(func(g Gomega) {
+			fetched, err := getHR(ctx, name)
+			g.Expect(err).NotTo(HaveOccurred())
+			cond := findCondition(fetched, "ValidationRelaxed")
+			g.Expect(cond).NotTo(BeNil())
+			g.Expect(cond.Status).To(Equal(status))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+	}
+
+	It("passes the flags to Helm and records them in a condition", func() {
+		mock := &MockHelmClient{}
+		cancel := startManager(mock)
+		defer cancel()
+
+		hr := makeHR("test-skip-validation")
+		hr.Spec.SkipSchemaValidation = true
+		hr.Spec.DisableOpenAPIValidation = true
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+		Eventually(func() bool {
+			mock.mu.Lock()
+			defer mock.mu.Unlock()
+			return mock.InstallArgs.Opts.SkipSchemaValidation && mock.InstallArgs.Opts.DisableOpenAPIValidation
+		}).WithTimeout(timeout).WithPolling(polling).Should(BeTrue())
+		expectRelaxed("test-skip-validation", metav1.ConditionTrue)
+
+		fetched, err := getHR(ctx, "test-skip-validation")
+		Expect(err).NotTo(HaveOccurred())
+		patch := client.MergeFrom(fetched.DeepCopy())
+		fetched.Spec.SkipSchemaValidation = false
+		fetched.Spec.DisableOpenAPIValidation = false
+		Expect(k8sClient.Patch(ctx, fetched, patch)).To(Succeed())
+		expectRelaxed("test-skip-validation", metav1.ConditionFalse)
+	})
+
+	It("sets no condition for releases that validate fully", func() {
+		mock := &MockHelmClient{}
+		cancel := startManager(mock)
+		defer cancel()
+
+		hr := makeHR("test-full-validation")
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+		Eventually(func(g Gomega) {
+			fetched, err := getHR(ctx, "test-full-validation")
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(fetched.Status.Phase).To(Equal(helmv1alpha1.PhaseReady))
+			g.Expect(findCondition(fetched, "ValidationRelaxed")).To(BeNil())
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+	})
+})
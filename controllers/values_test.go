@@ -0,0 +1,65 @@
+package controllers_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+var _ = Describe("Values types", func() {
+	ctx := context.Background()
+
+	It("passes integers and nulls to Helm unchanged through the API server", func() {
+		mock := &MockHelmClient{}
+		cancel := startManager(mock)
+		defer cancel()
+
+		hr := makeHR("test-values-types")
+		hr.Spec.Values = &apiextensionsv1.JSON{Raw: []byte(
+			`{"replicas":3,"maxBytes":9007199254740993,"ratio":0.5,"ports":[8080],"resources":{"limits":null}}`)}
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+		Eventually(func(g Gomega) {
+			mock.mu.Lock()
+			defer mock.mu.Unlock()
+			g.Expect(mock.InstallCalled).To(BeTrue())
+			g.Expect(mock.InstallArgs.Values).To(Equal(map[string]interface{}{
+				"replicas":  int64(3),
+				"maxBytes":  int64(9007199254740993),
+				"ratio":     0.5,
+				"ports":     []interface{}{int64(8080)},
+				"resources": map[string]interface{}{"limits": nil},
+			}))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+		mock.mu.Lock()
+		mock.ReleaseExistsResult = true
+		mock.mu.Unlock()
+
+		// An edit in the web UI sends the values text, which the server
+		// stores with an update; a merge patch would drop the new null.
+		fetched, err := getHR(ctx, hr.Name)
+		Expect(err).NotTo(HaveOccurred())
+		fetched.Spec.Values = &apiextensionsv1.JSON{Raw: []byte(`{
+  "replicas": 4,
+  "maxBytes": 9007199254740993,
+  "resources": {"limits": null},
+  "nodeSelector": null
+}`)}
+		Expect(k8sClient.Update(ctx, fetched)).To(Succeed())
+
+		Eventually(func(g Gomega) {
+			mock.mu.Lock()
+			defer mock.mu.Unlock()
+			g.Expect(mock.UpgradeArgs.Values).To(Equal(map[string]interface{}{
+				"replicas":     int64(4),
+				"maxBytes":     int64(9007199254740993),
+				"resources":    map[string]interface{}{"limits": nil},
+				"nodeSelector": nil,
+			}))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+	})
+})
@@ -0,0 +1,286 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	helmvalues "github.com/example/helm-operator/values"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+const conditionWaitingForValuesSource = "WaitingForValuesSource"
+
+// defaultValuesKey is read from valuesFrom objects without a valuesKey.
+const defaultValuesKey = "values.yaml"
+
+// requeueForValuesSource is how often a missing valuesFrom object is looked
+// for again. Creating or changing the object reconciles the waiting
+// releases at once (see sourceWaiters); this is only a fallback.
+const requeueForValuesSource = time.Minute
+
+// missingValuesSourceError reports a valuesFrom object or key, or a
+// substituteFrom object, that does not exist yet.
+type missingValuesSourceError struct {
+	ref       helmv1alpha1.ValuesReference
+	namespace string
+	// keyMissing is set when the object exists without the key.
+	keyMissing bool
+	// substitute is set for substituteFrom objects.
+	substitute bool
+}
+
+// source identifies the missing object.
+func (e *missingValuesSourceError) source() sourceKey {
+	return sourceKey{Kind: e.ref.Kind, Namespace: e.namespace, Name: e.ref.Name}
+}
+
+// field names the spec field of the missing source.
+func (e *missingValuesSourceError) field() string {
+	if e.substitute {
+		return "substituteFrom"
+	}
+	return "valuesFrom"
+}
+
+func (e *missingValuesSourceError) Error() string {
+	if e.keyMissing {
+		return fmt.Sprintf("%s %s/%s has no key %q", e.ref.Kind, e.namespace, e.ref.Name, valuesKey(e.ref))
+	}
+	return fmt.Sprintf("%s %s/%s does not exist", e.ref.Kind, e.namespace, e.ref.Name)
+}
+
+func valuesKey(ref helmv1alpha1.ValuesReference) string {
+	if ref.ValuesKey != "" {
+		return ref.ValuesKey
+	}
+	return defaultValuesKey
+}
+
+// composeValues merges the values of release's valuesFrom references, in
+// order, and spec.values on top. A required reference that is missing yields
+// a *missingValuesSourceError.
+func (r *HelmReleaseReconciler) composeValues(ctx context.Context, release *helmv1alpha1.HelmRelease) (map[string]interface{}, error) {
+	values, _, err := ComposeValues(ctx, r.Client, release)
+	return values, err
+}
+
+// ComposeValues merges the release's valuesFrom sources, valuesTemplate and
+// spec.values as the controller does before rewrites, and reports which
+// source set each value: "spec.values", "spec.valuesTemplate" or
+// "<Kind>/<name>:<key>". A template using variables from a Secret is
+// reported as "Secret/<name>:valuesTemplate", so its values are treated as
+// secret.
+func ComposeValues(ctx context.Context, c client.Reader, release *helmv1alpha1.HelmRelease) (map[string]interface{}, helmvalues.Provenance, error) {
+	values := map[string]interface{}{}
+	provenance := helmvalues.Provenance{}
+	for _, ref := range release.Spec.ValuesFrom {
+		data, err := readValuesSource(ctx, c, release.Namespace, ref)
+		var missing *missingValuesSourceError
+		if errors.As(err, &missing) && ref.Optional {
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		raw, err := yaml.YAMLToJSON(data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("valuesFrom %s %s key %q: %w", ref.Kind, ref.Name, valuesKey(ref), err)
+		}
+		layer, err := helmvalues.Parse(raw)
+		if err != nil {
+			return nil, nil, fmt.Errorf("valuesFrom %s %s key %q: %w", ref.Kind, ref.Name, valuesKey(ref), err)
+		}
+		provenance.Record(layer, fmt.Sprintf("%s/%s:%s", ref.Kind, ref.Name, valuesKey(ref)))
+		values = helmvalues.Merge(values, layer)
+	}
+	if release.Spec.ValuesTemplate != "" {
+		layer, source, err := renderValuesTemplate(ctx, c, release)
+		if err != nil {
+			return nil, nil, err
+		}
+		provenance.Record(layer, source)
+		values = helmvalues.Merge(values, layer)
+	}
+	if release.Spec.Values != nil {
+		layer, err := helmvalues.Parse(release.Spec.Values.Raw)
+		if err != nil {
+			return nil, nil, fmt.Errorf("parsing values: %w", err)
+		}
+		provenance.Record(layer, "spec.values")
+		values = helmvalues.Merge(values, layer)
+	}
+	return values, provenance, nil
+}
+
+// renderValuesTemplate substitutes the variables of release's
+// substituteFrom objects into spec.valuesTemplate and parses the result. It
+// also returns the provenance source of the rendered values.
+func renderValuesTemplate(ctx context.Context, c client.Reader, release *helmv1alpha1.HelmRelease) (map[string]interface{}, string, error) {
+	vars := map[string]string{}
+	secretVars := map[string]string{}
+	for _, ref := range release.Spec.SubstituteFrom {
+		data, err := readSubstitutions(ctx, c, release.Namespace, ref)
+		var missing *missingValuesSourceError
+		if errors.As(err, &missing) && ref.Optional {
+			continue
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		for k, v := range data {
+			vars[k] = v
+			if ref.Kind == "Secret" {
+				secretVars[k] = ref.Name
+			} else {
+				delete(secretVars, k)
+			}
+		}
+	}
+	source := "spec.valuesTemplate"
+	for _, name := range helmvalues.Variables(release.Spec.ValuesTemplate) {
+		if secret, ok := secretVars[name]; ok {
+			source = "Secret/" + secret + ":valuesTemplate"
+			break
+		}
+	}
+
+	rendered, err := helmvalues.Substitute(release.Spec.ValuesTemplate, vars)
+	if err != nil {
+		return nil, "", fmt.Errorf("valuesTemplate: %w", err)
+	}
+	raw, err := yaml.YAMLToJSON([]byte(rendered))
+	if err != nil {
+		return nil, "", fmt.Errorf("valuesTemplate: %w", err)
+	}
+	layer, err := helmvalues.Parse(raw)
+	if err != nil {
+		return nil, "", fmt.Errorf("valuesTemplate: %w", err)
+	}
+	return layer, source, nil
+}
+
+// readSubstitutions returns the keys of the object ref names as variables.
+func readSubstitutions(ctx context.Context, c client.Reader, namespace string, ref helmv1alpha1.SubstituteReference) (map[string]string, error) {
+	key := types.NamespacedName{Namespace: namespace, Name: ref.Name}
+	vars := map[string]string{}
+	var err error
+	switch ref.Kind {
+	case "Secret":
+		var secret corev1.Secret
+		if err = c.Get(ctx, key, &secret); err == nil {
+			for k, v := range secret.Data {
+				vars[k] = string(v)
+			}
+		}
+	case "ConfigMap":
+		var cm corev1.ConfigMap
+		if err = c.Get(ctx, key, &cm); err == nil {
+			for k, v := range cm.Data {
+				vars[k] = v
+			}
+		}
+	default:
+		return nil, fmt.Errorf("substituteFrom %s: unsupported kind %q (want ConfigMap or Secret)", ref.Name, ref.Kind)
+	}
+	switch {
+	case apierrors.IsNotFound(err):
+		return nil, &missingValuesSourceError{
+			ref:        helmv1alpha1.ValuesReference{Kind: ref.Kind, Name: ref.Name},
+			namespace:  namespace,
+			substitute: true,
+		}
+	case err != nil:
+		return nil, fmt.Errorf("substituteFrom %s %s: %w", ref.Kind, ref.Name, err)
+	}
+	return vars, nil
+}
+
+// IsMissingValuesSource reports whether err is about a valuesFrom object or
+// key that does not exist yet.
+func IsMissingValuesSource(err error) bool {
+	var missing *missingValuesSourceError
+	return errors.As(err, &missing)
+}
+
+// readValuesSource returns the values key of the object ref names.
+func readValuesSource(ctx context.Context, c client.Reader, namespace string, ref helmv1alpha1.ValuesReference) ([]byte, error) {
+	key := types.NamespacedName{Namespace: namespace, Name: ref.Name}
+	var (
+		data []byte
+		ok   bool
+		err  error
+	)
+	switch ref.Kind {
+	case "Secret":
+		var secret corev1.Secret
+		if err = c.Get(ctx, key, &secret); err == nil {
+			data, ok = secret.Data[valuesKey(ref)]
+		}
+	case "ConfigMap":
+		var cm corev1.ConfigMap
+		if err = c.Get(ctx, key, &cm); err == nil {
+			var s string
+			s, ok = cm.Data[valuesKey(ref)]
+			data = []byte(s)
+		}
+	default:
+		return nil, fmt.Errorf("valuesFrom %s: unsupported kind %q (want ConfigMap or Secret)", ref.Name, ref.Kind)
+	}
+	switch {
+	case apierrors.IsNotFound(err):
+		return nil, &missingValuesSourceError{ref: ref, namespace: namespace}
+	case err != nil:
+		return nil, fmt.Errorf("valuesFrom %s %s: %w", ref.Kind, ref.Name, err)
+	case !ok:
+		return nil, &missingValuesSourceError{ref: ref, namespace: namespace, keyMissing: true}
+	}
+	return data, nil
+}
+
+// setWaitingForValuesSource records that the release waits for a valuesFrom
+// object, emitting an event when the wait starts or moves to another object.
+func (r *HelmReleaseReconciler) setWaitingForValuesSource(release *helmv1alpha1.HelmRelease, missing *missingValuesSourceError) {
+	message := fmt.Sprintf("Waiting for %s: %s", missing.field(), missing.Error())
+	previous := meta.FindStatusCondition(release.Status.Conditions, conditionWaitingForValuesSource)
+	announced := previous != nil && previous.Status == metav1.ConditionTrue && previous.Message == message
+	reason := "SourceNotFound"
+	if missing.keyMissing {
+		reason = "KeyNotFound"
+	}
+	setCondition(release, metav1.Condition{
+		Type:               conditionWaitingForValuesSource,
+		Status:             metav1.ConditionTrue,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: release.Generation,
+	})
+	if !announced {
+		r.event(release, corev1.EventTypeNormal, "WaitingForValuesSource", message)
+	}
+}
+
+// clearWaitingForValuesSource sets WaitingForValuesSource to False if the
+// release was waiting.
+func clearWaitingForValuesSource(release *helmv1alpha1.HelmRelease) {
+	for _, c := range release.Status.Conditions {
+		if c.Type == conditionWaitingForValuesSource && c.Status != metav1.ConditionFalse {
+			setCondition(release, metav1.Condition{
+				Type:               conditionWaitingForValuesSource,
+				Status:             metav1.ConditionFalse,
+				Reason:             "SourcesAvailable",
+				Message:            "All valuesFrom sources are available",
+				ObservedGeneration: release.Generation,
+			})
+			return
+		}
+	}
+}
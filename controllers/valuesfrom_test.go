@@ -0,0 +1,157 @@
+package controllers_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("valuesFrom", func() {
+	ctx := context.Background()
+
+	installed := func(mock *MockHelmClient) func() bool {
+		return func() bool {
+			mock.mu.Lock()
+			defer mock.mu.Unlock()
+			return mock.InstallCalled
+		}
+	}
+
+	It("waits for a missing Secret, then merges its values beneath spec.values", func() {
+		mock := &MockHelmClient{}
+		cancel := startManager(mock)
+		defer cancel()
+
+		hr := makeHR("test-values-from")
+		hr.Spec.ValuesFrom = []helmv1alpha1.ValuesReference{
+			{Kind: "ConfigMap", Name: "test-values-defaults", Optional: true},
+			{Kind: "Secret", Name: "test-values-db", ValuesKey: "db.yaml"},
+		}
+		hr.Spec.Values = &apiextensionsv1.JSON{Raw: []byte(`{"db":{"port":5433}}`)}
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+		Eventually(func(g Gomega) {
+			fetched, err := getHR(ctx, "test-values-from")
+			g.Expect(err).NotTo(HaveOccurred())
+			cond := findCondition(fetched, "WaitingForValuesSource")
+			g.Expect(cond).NotTo(BeNil())
+			g.Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+			g.Expect(cond.Reason).To(Equal("SourceNotFound"))
+			g.Expect(cond.Message).To(ContainSubstring("Secret default/test-values-db"))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+		Consistently(installed(mock)).WithTimeout(time.Second).WithPolling(polling).Should(BeFalse())
+
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-values-db", Namespace: testNS},
+			StringData: map[string]string{"db.yaml": "db:\n  host: postgres\n  port: 5432\n"},
+		}
+		Expect(k8sClient.Create(ctx, secret)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, secret) })
+
+		// Well before the one-minute fallback requeue.
+		Eventually(installed(mock)).WithTimeout(timeout).WithPolling(polling).Should(BeTrue())
+		mock.mu.Lock()
+		Expect(mock.InstallArgs.Values).To(Equal(map[string]interface{}{
+			"db": map[string]interface{}{"host": "postgres", "port": int64(5433)},
+		}))
+		mock.mu.Unlock()
+		Eventually(func(g Gomega) {
+			fetched, err := getHR(ctx, "test-values-from")
+			g.Expect(err).NotTo(HaveOccurred())
+			cond := findCondition(fetched, "WaitingForValuesSource")
+			g.Expect(cond).NotTo(BeNil())
+			g.Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+	})
+
+	It("waits for a key the Secret does not have yet", func() {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-values-empty", Namespace: testNS},
+		}
+		Expect(k8sClient.Create(ctx, secret)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, secret) })
+
+		mock := &MockHelmClient{}
+		cancel := startManager(mock)
+		defer cancel()
+
+		hr := makeHR("test-values-key")
+		hr.Spec.ValuesFrom = []helmv1alpha1.ValuesReference{{Kind: "Secret", Name: "test-values-empty"}}
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+		Eventually(func(g Gomega) {
+			fetched, err := getHR(ctx, "test-values-key")
+			g.Expect(err).NotTo(HaveOccurred())
+			cond := findCondition(fetched, "WaitingForValuesSource")
+			g.Expect(cond).NotTo(BeNil())
+			g.Expect(cond.Reason).To(Equal("KeyNotFound"))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+		Expect(installed(mock)()).To(BeFalse())
+
+		secret.StringData = map[string]string{"values.yaml": "replicaCount: 2\n"}
+		Expect(k8sClient.Update(ctx, secret)).To(Succeed())
+		Eventually(installed(mock)).WithTimeout(timeout).WithPolling(polling).Should(BeTrue())
+	})
+
+	It("renders valuesTemplate with the variables of substituteFrom", func() {
+		cm := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-substitute-env", Namespace: testNS},
+			Data:       map[string]string{"ENV": "staging", "REPLICAS": "2"},
+		}
+		Expect(k8sClient.Create(ctx, cm)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, cm) })
+
+		mock := &MockHelmClient{}
+		cancel := startManager(mock)
+		defer cancel()
+
+		hr := makeHR("test-values-template")
+		hr.Spec.ValuesTemplate = "ingress:\n  host: web.${ENV}.example.com\nreplicaCount: ${REPLICAS}\nregion: ${REGION:=eu-west-1}\n"
+		hr.Spec.SubstituteFrom = []helmv1alpha1.SubstituteReference{
+			{Kind: "ConfigMap", Name: "test-substitute-env"},
+			{Kind: "Secret", Name: "test-substitute-absent", Optional: true},
+		}
+		hr.Spec.Values = &apiextensionsv1.JSON{Raw: []byte(`{"replicaCount":5}`)}
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+		Eventually(installed(mock)).WithTimeout(timeout).WithPolling(polling).Should(BeTrue())
+		mock.mu.Lock()
+		defer mock.mu.Unlock()
+		Expect(mock.InstallArgs.Values).To(Equal(map[string]interface{}{
+			"ingress":      map[string]interface{}{"host": "web.staging.example.com"},
+			"replicaCount": int64(5),
+			"region":       "eu-west-1",
+		}))
+	})
+
+	It("waits for a missing substituteFrom object", func() {
+		mock := &MockHelmClient{}
+		cancel := startManager(mock)
+		defer cancel()
+
+		hr := makeHR("test-values-template-wait")
+		hr.Spec.ValuesTemplate = "env: ${ENV}\n"
+		hr.Spec.SubstituteFrom = []helmv1alpha1.SubstituteReference{{Kind: "ConfigMap", Name: "test-substitute-missing"}}
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+		Eventually(func(g Gomega) {
+			fetched, err := getHR(ctx, hr.Name)
+			g.Expect(err).NotTo(HaveOccurred())
+			cond := findCondition(fetched, "WaitingForValuesSource")
+			g.Expect(cond).NotTo(BeNil())
+			g.Expect(cond.Message).To(Equal("Waiting for substituteFrom: ConfigMap default/test-substitute-missing does not exist"))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+		Expect(installed(mock)()).To(BeFalse())
+	})
+})
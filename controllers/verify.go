@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"helm.sh/helm/v3/pkg/downloader"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	conditionVerificationFailed = "VerificationFailed"
+
+	// defaultKeyringKey is the Secret key read when spec.verify.secretRef
+	// names none.
+	defaultKeyringKey = "pubring.gpg"
+)
+
+// VerificationError reports a chart whose provenance did not verify
+// against the keyring of spec.verify.
+type VerificationError struct {
+	Chart string
+	Err   error
+}
+
+func (e *VerificationError) Error() string {
+	return fmt.Sprintf("verifying chart %s: %v", e.Chart, e.Err)
+}
+
+func (e *VerificationError) Unwrap() error { return e.Err }
+
+// keyring reads the keyring of spec.verify. It returns nil for a release
+// without one.
+func (r *HelmReleaseReconciler) keyring(ctx context.Context, release *helmv1alpha1.HelmRelease) ([]byte, error) {
+	if release.Spec.Verify == nil || release.Spec.Verify.SecretRef == nil {
+		return nil, nil
+	}
+	ref := release.Spec.Verify.SecretRef
+	key := ref.Key
+	if key == "" {
+		key = defaultKeyringKey
+	}
+	var secret corev1.Secret
+	if err := r.Get(ctx, types.NamespacedName{Namespace: release.Namespace, Name: ref.Name}, &secret); err != nil {
+		return nil, fmt.Errorf("reading keyring Secret %s: %w", ref.Name, err)
+	}
+	keyring := secret.Data[key]
+	if len(keyring) == 0 {
+		return nil, fmt.Errorf("keyring Secret %s has no key %s", ref.Name, key)
+	}
+	return keyring, nil
+}
+
+// verifyChart checks the chart archive at path against the provenance file
+// next to it, path + ".prov", and keyring.
+func verifyChart(path string, keyring []byte) error {
+	keyringPath := filepath.Join(filepath.Dir(path), "keyring.gpg")
+	if err := os.WriteFile(keyringPath, keyring, 0o600); err != nil {
+		return err
+	}
+	if _, err := os.Stat(path + ".prov"); err != nil {
+		return &VerificationError{Chart: filepath.Base(path), Err: errors.New("the chart has no provenance file")}
+	}
+	if _, err := downloader.VerifyChart(path, keyringPath); err != nil {
+		return &VerificationError{Chart: filepath.Base(path), Err: err}
+	}
+	return nil
+}
+
+// setVerificationFailed sets the VerificationFailed condition when err is
+// a VerificationError.
+func setVerificationFailed(release *helmv1alpha1.HelmRelease, err error) {
+	var verr *VerificationError
+	if !errors.As(err, &verr) {
+		return
+	}
+	setCondition(release, metav1.Condition{
+		Type:               conditionVerificationFailed,
+		Status:             metav1.ConditionTrue,
+		Reason:             "SignatureInvalid",
+		Message:            verr.Error(),
+		ObservedGeneration: release.Generation,
+	})
+}
+
+// clearVerificationFailed marks the chart as verified if it failed
+// verification before.
+func clearVerificationFailed(release *helmv1alpha1.HelmRelease) {
+	for _, c := range release.Status.Conditions {
+		if c.Type == conditionVerificationFailed && c.Status != metav1.ConditionFalse {
+			setCondition(release, metav1.Condition{
+				Type:               conditionVerificationFailed,
+				Status:             metav1.ConditionFalse,
+				Reason:             "Verified",
+				Message:            "The chart's provenance verified against the keyring",
+				ObservedGeneration: release.Generation,
+			})
+			return
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package controllers_test
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"github.com/example/helm-operator/controllers"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Chart verification", func() {
+	ctx := context.Background()
+
+	It("passes the keyring of spec.verify to Helm", func() {
+		mock := &MockHelmClient{}
+		cancel := startManager(mock)
+		defer cancel()
+
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "charts-keyring", Namespace: "default"},
+			Data:       map[string][]byte{"pubring.gpg": []byte("keyring")},
+		}
+		Expect(k8sClient.Create(ctx, secret)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, secret) })
+		hr := makeHR("test-verify")
+		hr.Spec.Verify = &helmv1alpha1.VerifySpec{SecretRef: &helmv1alpha1.KeyringSecretRef{Name: "charts-keyring"}}
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+		Eventually(func(g Gomega) {
+			mock.mu.Lock()
+			args := mock.InstallArgs
+			mock.mu.Unlock()
+			g.Expect(args.Opts.Fetch.Keyring).To(Equal([]byte("keyring")))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+	})
+
+	It("fails releases whose keyring Secret lacks the key", func() {
+		mock := &MockHelmClient{}
+		cancel := startManager(mock)
+		defer cancel()
+
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "charts-keyring-other", Namespace: "default"},
+			Data:       map[string][]byte{"other.gpg": []byte("keyring")},
+		}
+		Expect(k8sClient.Create(ctx, secret)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, secret) })
+		hr := makeHR("test-verify-no-key")
+		hr.Spec.Verify = &helmv1alpha1.VerifySpec{SecretRef: &helmv1alpha1.KeyringSecretRef{Name: "charts-keyring-other"}}
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+		Eventually(func(g Gomega) {
+			fetched, err := getHR(ctx, hr.Name)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(fetched.Status.Phase).To(Equal(helmv1alpha1.PhaseFailed))
+			g.Expect(findCondition(fetched, "Ready").Message).To(ContainSubstring("has no key pubring.gpg"))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+		mock.mu.Lock()
+		defer mock.mu.Unlock()
+		Expect(mock.InstallCalled).To(BeFalse())
+	})
+
+	It("sets VerificationFailed when the chart does not verify", func() {
+		mock := &MockHelmClient{InstallErr: &controllers.VerificationError{
+			Chart: "nginx-1.0.0.tgz", Err: errors.New("openpgp: signature made by unknown entity"),
+		}}
+		cancel := startManager(mock)
+		defer cancel()
+
+		hr := makeHR("test-verify-failed")
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+		Eventually(func(g Gomega) {
+			fetched, err := getHR(ctx, hr.Name)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(fetched.Status.Phase).To(Equal(helmv1alpha1.PhaseFailed))
+			cond := findCondition(fetched, "VerificationFailed")
+			g.Expect(cond).NotTo(BeNil())
+			g.Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+			g.Expect(cond.Message).To(ContainSubstring("unknown entity"))
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+	})
+})
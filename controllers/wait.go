@@ -0,0 +1,124 @@
+package controllers
+
+import (
+	"time"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/kube"
+)
+
+// defaultWaitTimeout is how long an install or upgrade with spec.wait waits
+// for resources when spec.wait.timeout is unset, as helm --wait does.
+const defaultWaitTimeout = 5 * time.Minute
+
+// WaitOptions controls what an install or upgrade waits for.
+type WaitOptions struct {
+	// Wait waits for the release's resources to become ready.
+	Wait bool
+	// WaitForJobs also waits for Jobs to complete. Implies Wait.
+	WaitForJobs bool
+	// Timeout bounds the wait for resources and Jobs.
+	Timeout time.Duration
+	// HookTimeout bounds each hook; 0 means no limit.
+	HookTimeout time.Duration
+}
+
+// waitOptions returns the wait settings of release.
+func waitOptions(release *helmv1alpha1.HelmRelease) WaitOptions {
+	var opts WaitOptions
+	if w := release.Spec.Wait; w != nil {
+		opts.Wait = true
+		opts.WaitForJobs = w.WaitForJobs
+		opts.Timeout = defaultWaitTimeout
+		if w.Timeout != nil {
+			opts.Timeout = w.Timeout.Duration
+		}
+		opts.HookTimeout = opts.Timeout
+	}
+	if release.Spec.HookTimeout != nil {
+		opts.HookTimeout = release.Spec.HookTimeout.Duration
+	}
+	return opts
+}
+
+// installWaitOptions returns the wait settings for installing release:
+// spec.install on top of spec.wait.
+func installWaitOptions(release *helmv1alpha1.HelmRelease) WaitOptions {
+	opts := waitOptions(release)
+	in := release.Spec.Install
+	if in == nil {
+		return opts
+	}
+	if (in.Wait || in.WaitForJobs || in.Atomic) && !opts.Wait {
+		opts.Wait = true
+		opts.Timeout = defaultWaitTimeout
+	}
+	opts.WaitForJobs = opts.WaitForJobs || in.WaitForJobs
+	if in.Timeout != nil {
+		opts.Timeout = in.Timeout.Duration
+	}
+	if opts.Wait && release.Spec.HookTimeout == nil {
+		opts.HookTimeout = opts.Timeout
+	}
+	return opts
+}
+
+// uninstallOptions returns the settings for uninstalling release.
+func (r *HelmReleaseReconciler) uninstallOptions(release *helmv1alpha1.HelmRelease) UninstallOptions {
+	opts := UninstallOptions{Impersonate: r.impersonatedUser(release)}
+	if release.Spec.HookTimeout != nil {
+		opts.Timeout = release.Spec.HookTimeout.Duration
+	}
+	un := release.Spec.Uninstall
+	if un == nil {
+		return opts
+	}
+	opts.KeepHistory = un.KeepHistory
+	opts.DisableHooks = un.DisableHooks
+	opts.Wait = un.Wait
+	if opts.Wait {
+		opts.Timeout = defaultWaitTimeout
+	}
+	if un.Timeout != nil {
+		opts.Timeout = un.Timeout.Duration
+	}
+	return opts
+}
+
+// applyWait configures cfg for opts and returns the settings for the
+// action. Helm has a single timeout for hooks and the wait, so the action
+// gets the hook timeout and the wait timeout is substituted by the kube
+// client when Helm waits.
+func applyWait(cfg *action.Configuration, opts WaitOptions) (wait, waitForJobs bool, timeout time.Duration) {
+	wait = opts.Wait || opts.WaitForJobs
+	if wait && opts.Timeout != opts.HookTimeout {
+		if kc, ok := cfg.KubeClient.(*kube.Client); ok {
+			cfg.KubeClient = &waitTimeoutClient{Client: kc, hookTimeout: opts.HookTimeout, waitTimeout: opts.Timeout}
+		}
+	}
+	return wait, opts.WaitForJobs, opts.HookTimeout
+}
+
+// waitTimeoutClient replaces the action's timeout, which Helm passes to
+// every wait, with the wait timeout. Waits with other timeouts, such as
+// Helm's fixed one for CRDs, are left alone.
+type waitTimeoutClient struct {
+	*kube.Client
+	hookTimeout time.Duration
+	waitTimeout time.Duration
+}
+
+func (c *waitTimeoutClient) Wait(resources kube.ResourceList, timeout time.Duration) error {
+	if timeout == c.hookTimeout {
+		timeout = c.waitTimeout
+	}
+	return c.Client.Wait(resources, timeout)
+}
+
+func (c *waitTimeoutClient) WaitWithJobs(resources kube.ResourceList, timeout time.Duration) error {
+	if timeout == c.hookTimeout {
+		timeout = c.waitTimeout
+	}
+	return c.Client.WaitWithJobs(resources, timeout)
+}
@@ -0,0 +1,119 @@
+package controllers_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"github.com/example/helm-operator/controllers"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Wait and hook timeouts", func() {
+	ctx := context.Background()
+
+	installOpts := func(mock *MockHelmClient) func() controllers.WaitOptions {
+		return func() controllers.WaitOptions {
+			mock.mu.Lock()
+			defer mock.mu.Unlock()
+			return mock.InstallArgs.Opts.Wait
+		}
+	}
+
+	It("passes spec.wait and spec.hookTimeout to Helm", func() {
+		mock := &MockHelmClient{}
+		cancel := startManager(mock)
+		defer cancel()
+
+		hr := makeHR("test-wait-jobs")
+		hr.Spec.Wait = &helmv1alpha1.WaitSpec{Timeout: &metav1.Duration{Duration: 10 * time.Minute}, WaitForJobs: true}
+		hr.Spec.HookTimeout = &metav1.Duration{Duration: 45 * time.Minute}
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+		Eventually(installOpts(mock)).WithTimeout(timeout).WithPolling(polling).Should(Equal(controllers.WaitOptions{
+			Wait:        true,
+			WaitForJobs: true,
+			Timeout:     10 * time.Minute,
+			HookTimeout: 45 * time.Minute,
+		}))
+	})
+
+	It("defaults the hook timeout to the wait timeout", func() {
+		mock := &MockHelmClient{}
+		cancel := startManager(mock)
+		defer cancel()
+
+		hr := makeHR("test-wait-default")
+		hr.Spec.Wait = &helmv1alpha1.WaitSpec{}
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+		Eventually(installOpts(mock)).WithTimeout(timeout).WithPolling(polling).Should(Equal(controllers.WaitOptions{
+			Wait:        true,
+			Timeout:     5 * time.Minute,
+			HookTimeout: 5 * time.Minute,
+		}))
+	})
+
+	It("bounds hooks without waiting", func() {
+		mock := &MockHelmClient{}
+		cancel := startManager(mock)
+		defer cancel()
+
+		hr := makeHR("test-hook-timeout")
+		hr.Spec.HookTimeout = &metav1.Duration{Duration: time.Hour}
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+		Eventually(installOpts(mock)).WithTimeout(timeout).WithPolling(polling).Should(Equal(controllers.WaitOptions{
+			HookTimeout: time.Hour,
+		}))
+	})
+
+	It("applies spec.install on top of spec.wait for installs", func() {
+		mock := &MockHelmClient{}
+		cancel := startManager(mock)
+		defer cancel()
+
+		hr := makeHR("test-install-options")
+		hr.Spec.Wait = &helmv1alpha1.WaitSpec{}
+		hr.Spec.Install = &helmv1alpha1.InstallSpec{
+			WaitForJobs: true,
+			Timeout:     &metav1.Duration{Duration: 15 * time.Minute},
+			Atomic:      true,
+		}
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+		Eventually(installOpts(mock)).WithTimeout(timeout).WithPolling(polling).Should(Equal(controllers.WaitOptions{
+			Wait:        true,
+			WaitForJobs: true,
+			Timeout:     15 * time.Minute,
+			HookTimeout: 15 * time.Minute,
+		}))
+		mock.mu.Lock()
+		defer mock.mu.Unlock()
+		Expect(mock.InstallArgs.Opts.Atomic).To(BeTrue())
+	})
+
+	It("waits for an atomic install without spec.wait", func() {
+		mock := &MockHelmClient{}
+		cancel := startManager(mock)
+		defer cancel()
+
+		hr := makeHR("test-install-atomic")
+		hr.Spec.Install = &helmv1alpha1.InstallSpec{Atomic: true}
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+		Eventually(installOpts(mock)).WithTimeout(timeout).WithPolling(polling).Should(Equal(controllers.WaitOptions{
+			Wait:        true,
+			Timeout:     5 * time.Minute,
+			HookTimeout: 5 * time.Minute,
+		}))
+	})
+})
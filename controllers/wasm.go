@@ -0,0 +1,137 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"github.com/example/helm-operator/wasm"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const defaultWasmKey = "module.wasm"
+
+// GlobalWasmModule is a WasmModule applied to every HelmRelease. Its
+// ConfigMap lives in Namespace rather than the HelmRelease's namespace.
+type GlobalWasmModule struct {
+	Namespace string
+	helmv1alpha1.WasmModule
+}
+
+// ParseGlobalWasmModules parses the --wasm-modules flag: a comma-separated
+// list of namespace/configmap[:key][=Transform|Policy] entries.
+func ParseGlobalWasmModules(s string) ([]GlobalWasmModule, error) {
+	var modules []GlobalWasmModule
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		ref, mode, _ := strings.Cut(entry, "=")
+		ref, key, _ := strings.Cut(ref, ":")
+		ns, name, ok := strings.Cut(ref, "/")
+		if !ok || ns == "" || name == "" {
+			return nil, fmt.Errorf("wasm module %q: expected namespace/configmap[:key][=mode]", entry)
+		}
+		m := GlobalWasmModule{Namespace: ns, WasmModule: helmv1alpha1.WasmModule{
+			Name:         ns + "/" + name,
+			ConfigMapRef: helmv1alpha1.ConfigMapKeyRef{Name: name, Key: key},
+			Mode:         helmv1alpha1.WasmMode(mode),
+		}}
+		switch m.Mode {
+		case "":
+			m.Mode = helmv1alpha1.WasmModeTransform
+		case helmv1alpha1.WasmModeTransform, helmv1alpha1.WasmModePolicy:
+		default:
+			return nil, fmt.Errorf("wasm module %q: mode must be Transform or Policy", entry)
+		}
+		modules = append(modules, m)
+	}
+	return modules, nil
+}
+
+// loadedWasmModule is a WasmModule with its binary fetched from the ConfigMap.
+type loadedWasmModule struct {
+	name string
+	mode helmv1alpha1.WasmMode
+	code []byte
+}
+
+// wasmPostRenderer returns a post-renderer running the global modules
+// followed by the release's own, or nil if there are none.
+func (r *HelmReleaseReconciler) wasmPostRenderer(ctx context.Context, release *helmv1alpha1.HelmRelease) (*wasmRenderer, error) {
+	if len(r.GlobalWasmModules) == 0 && len(release.Spec.WasmModules) == 0 {
+		return nil, nil
+	}
+	if r.WasmRuntime == nil {
+		return nil, fmt.Errorf("wasm modules configured but the operator has no Wasm runtime: %w", wasm.ErrUnavailable)
+	}
+
+	var modules []loadedWasmModule
+	for _, m := range r.GlobalWasmModules {
+		lm, err := r.loadWasmModule(ctx, m.Namespace, m.WasmModule)
+		if err != nil {
+			return nil, err
+		}
+		modules = append(modules, lm)
+	}
+	for _, m := range release.Spec.WasmModules {
+		lm, err := r.loadWasmModule(ctx, release.Namespace, m)
+		if err != nil {
+			return nil, err
+		}
+		modules = append(modules, lm)
+	}
+	return &wasmRenderer{ctx: ctx, runtime: r.WasmRuntime, modules: modules}, nil
+}
+
+func (r *HelmReleaseReconciler) loadWasmModule(ctx context.Context, namespace string, m helmv1alpha1.WasmModule) (loadedWasmModule, error) {
+	key := m.ConfigMapRef.Key
+	if key == "" {
+		key = defaultWasmKey
+	}
+	var cm corev1.ConfigMap
+	if err := r.Get(ctx, types.NamespacedName{Name: m.ConfigMapRef.Name, Namespace: namespace}, &cm); err != nil {
+		return loadedWasmModule{}, fmt.Errorf("wasm module %q: %w", m.Name, err)
+	}
+	code, ok := cm.BinaryData[key]
+	if !ok {
+		return loadedWasmModule{}, fmt.Errorf("wasm module %q: ConfigMap %s/%s has no binaryData key %q",
+			m.Name, namespace, cm.Name, key)
+	}
+	mode := m.Mode
+	if mode == "" {
+		mode = helmv1alpha1.WasmModeTransform
+	}
+	return loadedWasmModule{name: m.Name, mode: mode, code: code}, nil
+}
+
+// wasmRenderer is a Helm post-renderer that pipes the manifests through Wasm
+// modules. Transform modules replace the manifests with their output; Policy
+// modules leave them untouched and reject the release by exiting non-zero.
+type wasmRenderer struct {
+	ctx     context.Context
+	runtime wasm.Runtime
+	modules []loadedWasmModule
+}
+
+func (p *wasmRenderer) Run(rendered *bytes.Buffer) (*bytes.Buffer, error) {
+	manifests := rendered.Bytes()
+	for _, m := range p.modules {
+		out, err := p.runtime.Run(p.ctx, m.code, manifests)
+		var exitErr *wasm.ExitError
+		switch {
+		case m.mode == helmv1alpha1.WasmModePolicy && errors.As(err, &exitErr):
+			return nil, fmt.Errorf("wasm policy %q rejected the manifests: %s", m.name, strings.TrimSpace(exitErr.Stdout))
+		case err != nil:
+			return nil, fmt.Errorf("wasm module %q: %w", m.name, err)
+		case m.mode == helmv1alpha1.WasmModeTransform:
+			manifests = out
+		}
+	}
+	return bytes.NewBuffer(manifests), nil
+}
@@ -0,0 +1,168 @@
+package controllers_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"github.com/example/helm-operator/controllers"
+	"github.com/example/helm-operator/wasm"
+	"helm.sh/helm/v3/pkg/postrender"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// fakeWasm interprets a module's code as the name of what it does: "upper"
+// transforms the manifests to upper case, "deny" rejects them. It records the
+// modules it ran.
+type fakeWasm struct {
+	mu  sync.Mutex
+	ran []string
+}
+
+func (f *fakeWasm) Run(_ context.Context, module, stdin []byte) ([]byte, error) {
+	f.mu.Lock()
+	f.ran = append(f.ran, string(module))
+	f.mu.Unlock()
+	switch string(module) {
+	case "upper":
+		return bytes.ToUpper(stdin), nil
+	case "deny":
+		return nil, &wasm.ExitError{Code: 1, Stdout: "image tag latest is not allowed\n"}
+	}
+	return nil, &wasm.ExitError{Code: 2, Stderr: "unknown module"}
+}
+
+var _ = Describe("Wasm modules", func() {
+	ctx := context.Background()
+
+	BeforeEach(func() {
+		cm := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "wasm-modules", Namespace: testNS},
+			BinaryData: map[string][]byte{"upper.wasm": []byte("upper"), "deny.wasm": []byte("deny")},
+		}
+		Expect(k8sClient.Create(ctx, cm)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, cm) })
+	})
+
+	// install creates hr and returns the post-renderer the controller
+	// passed to Helm.
+	install := func(mock *MockHelmClient, hr *helmv1alpha1.HelmRelease) postrender.PostRenderer {
+		Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+		DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+		var pr postrender.PostRenderer
+		Eventually(func(g Gomega) {
+			mock.mu.Lock()
+			pr = mock.InstallArgs.Opts.PostRenderer
+			mock.mu.Unlock()
+			g.Expect(pr).NotTo(BeNil())
+		}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+		return pr
+	}
+
+	DescribeTable("parses --wasm-modules",
+		func(flag string, want []controllers.GlobalWasmModule, wantErr string) {
+			modules, err := controllers.ParseGlobalWasmModules(flag)
+			if wantErr != "" {
+				Expect(err).To(MatchError(ContainSubstring(wantErr)))
+				return
+			}
+			Expect(err).NotTo(HaveOccurred())
+			Expect(modules).To(Equal(want))
+		},
+		Entry("empty", "", nil, ""),
+		Entry("modules with and without key and mode", "platform/policies:deny.wasm=Policy, platform/labels", []controllers.GlobalWasmModule{
+			{Namespace: "platform", WasmModule: helmv1alpha1.WasmModule{Name: "platform/policies",
+				ConfigMapRef: helmv1alpha1.ConfigMapKeyRef{Name: "policies", Key: "deny.wasm"}, Mode: helmv1alpha1.WasmModePolicy}},
+			{Namespace: "platform", WasmModule: helmv1alpha1.WasmModule{Name: "platform/labels",
+				ConfigMapRef: helmv1alpha1.ConfigMapKeyRef{Name: "labels"}, Mode: helmv1alpha1.WasmModeTransform}},
+		}, ""),
+		Entry("no namespace", "policies", nil, "expected namespace/configmap"),
+		Entry("unknown mode", "platform/policies=Audit", nil, "mode must be Transform or Policy"),
+	)
+
+	It("runs global modules before the release's own", func() {
+		mock, runtime := &MockHelmClient{}, &fakeWasm{}
+		cancel := startManager(mock, func(r *controllers.HelmReleaseReconciler) {
+			r.WasmRuntime = runtime
+			r.GlobalWasmModules = []controllers.GlobalWasmModule{{Namespace: testNS, WasmModule: helmv1alpha1.WasmModule{
+				Name: testNS + "/wasm-modules", ConfigMapRef: helmv1alpha1.ConfigMapKeyRef{Name: "wasm-modules", Key: "deny.wasm"},
+				Mode: helmv1alpha1.WasmModePolicy,
+			}}}
+		})
+		defer cancel()
+
+		hr := makeHR("test-wasm-global")
+		hr.Spec.WasmModules = []helmv1alpha1.WasmModule{
+			{Name: "upper", ConfigMapRef: helmv1alpha1.ConfigMapKeyRef{Name: "wasm-modules", Key: "upper.wasm"}},
+		}
+		_, err := install(mock, hr).Run(bytes.NewBufferString("image: nginx:latest\n"))
+		Expect(err).To(MatchError(ContainSubstring("rejected the manifests: image tag latest is not allowed")))
+		runtime.mu.Lock()
+		defer runtime.mu.Unlock()
+		Expect(runtime.ran).To(Equal([]string{"deny"}))
+	})
+
+	It("pipes the manifests through Transform modules", func() {
+		mock := &MockHelmClient{}
+		cancel := startManager(mock, func(r *controllers.HelmReleaseReconciler) { r.WasmRuntime = &fakeWasm{} })
+		defer cancel()
+
+		hr := makeHR("test-wasm-transform")
+		hr.Spec.WasmModules = []helmv1alpha1.WasmModule{
+			{Name: "upper", ConfigMapRef: helmv1alpha1.ConfigMapKeyRef{Name: "wasm-modules", Key: "upper.wasm"}},
+		}
+		out, err := install(mock, hr).Run(bytes.NewBufferString("kind: ConfigMap\n"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out.String()).To(Equal("KIND: CONFIGMAP\n"))
+	})
+
+	It("fails the render when a Transform module exits non-zero", func() {
+		mock := &MockHelmClient{}
+		cancel := startManager(mock, func(r *controllers.HelmReleaseReconciler) { r.WasmRuntime = &fakeWasm{} })
+		defer cancel()
+
+		hr := makeHR("test-wasm-transform-fails")
+		hr.Spec.WasmModules = []helmv1alpha1.WasmModule{
+			{Name: "broken", ConfigMapRef: helmv1alpha1.ConfigMapKeyRef{Name: "wasm-modules", Key: "deny.wasm"}},
+		}
+		_, err := install(mock, hr).Run(bytes.NewBufferString("kind: ConfigMap\n"))
+		Expect(err).To(MatchError(ContainSubstring(`wasm module "broken": wasm module exited with code 1`)))
+	})
+
+	DescribeTable("fails the release when a module cannot run",
+		func(configure func(*controllers.HelmReleaseReconciler), key, message string) {
+			mock := &MockHelmClient{}
+			cancel := startManager(mock, configure)
+			defer cancel()
+
+			hr := makeHR("test-wasm-" + strings.ToLower(strings.ReplaceAll(key, ".", "-")))
+			hr.Spec.WasmModules = []helmv1alpha1.WasmModule{
+				{Name: "upper", ConfigMapRef: helmv1alpha1.ConfigMapKeyRef{Name: "wasm-modules", Key: key}},
+			}
+			Expect(k8sClient.Create(ctx, hr)).To(Succeed())
+			DeferCleanup(func() { k8sClient.Delete(ctx, hr) })
+
+			Eventually(func(g Gomega) {
+				fetched, err := getHR(ctx, hr.Name)
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(fetched.Status.Phase).To(Equal(helmv1alpha1.PhaseFailed))
+				cond := findCondition(fetched, "Ready")
+				g.Expect(cond).NotTo(BeNil())
+				g.Expect(cond.Message).To(ContainSubstring(message))
+			}).WithTimeout(timeout).WithPolling(polling).Should(Succeed())
+
+			mock.mu.Lock()
+			defer mock.mu.Unlock()
+			Expect(mock.InstallCalled).To(BeFalse())
+		},
+		Entry("without a Wasm runtime", func(*controllers.HelmReleaseReconciler) {}, "upper.wasm", "wasm support is not available"),
+		Entry("with a missing key", func(r *controllers.HelmReleaseReconciler) { r.WasmRuntime = &fakeWasm{} }, "missing.wasm",
+			`has no binaryData key "missing.wasm"`),
+	)
+})
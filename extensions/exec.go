@@ -0,0 +1,125 @@
+package extensions
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Hook names passed to Exec binaries.
+const (
+	HookPreRender  = "preRender"
+	HookPostRender = "postRender"
+	HookPreApply   = "preApply"
+	HookPostApply  = "postApply"
+)
+
+const defaultExecTimeout = 30 * time.Second
+
+// Exec is an Extension implemented by an external binary, typically shipped in
+// the operator image or a sidecar-shared volume. For every hook the binary is
+// run with Args plus the hook name as its final argument, and receives a JSON
+// request on stdin:
+//
+//	{"hook":"postRender","release":{...},"values":{...},"manifests":"..."}
+//
+// It must exit 0 and may print a JSON response on stdout:
+//
+//	{"values":{...},"manifests":"...","error":"..."}
+//
+// Omitted response fields leave the input unchanged; a non-empty error fails
+// the hook. Binaries should ignore hooks they do not handle.
+type Exec struct {
+	Path    string
+	Args    []string
+	Timeout time.Duration // defaults to 30s
+}
+
+var _ Extension = (*Exec)(nil) // compile-time interface check
+
+type execRequest struct {
+	Hook      string                 `json:"hook"`
+	Release   Release                `json:"release"`
+	Values    map[string]interface{} `json:"values,omitempty"`
+	Manifests string                 `json:"manifests,omitempty"`
+}
+
+type execResponse struct {
+	Values    map[string]interface{} `json:"values,omitempty"`
+	Manifests *string                `json:"manifests,omitempty"`
+	Error     string                 `json:"error,omitempty"`
+}
+
+func (e *Exec) PreRender(ctx context.Context, rel Release, values map[string]interface{}) (map[string]interface{}, error) {
+	resp, err := e.run(ctx, execRequest{Hook: HookPreRender, Release: rel, Values: values})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Values != nil {
+		return resp.Values, nil
+	}
+	return values, nil
+}
+
+func (e *Exec) PostRender(ctx context.Context, rel Release, manifests []byte) ([]byte, error) {
+	resp, err := e.run(ctx, execRequest{Hook: HookPostRender, Release: rel, Manifests: string(manifests)})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Manifests != nil {
+		return []byte(*resp.Manifests), nil
+	}
+	return manifests, nil
+}
+
+func (e *Exec) PreApply(ctx context.Context, rel Release) error {
+	_, err := e.run(ctx, execRequest{Hook: HookPreApply, Release: rel})
+	return err
+}
+
+func (e *Exec) PostApply(ctx context.Context, rel Release) error {
+	_, err := e.run(ctx, execRequest{Hook: HookPostApply, Release: rel})
+	return err
+}
+
+// run invokes the binary for one hook and decodes its response.
+func (e *Exec) run(ctx context.Context, req execRequest) (*execResponse, error) {
+	timeout := e.Timeout
+	if timeout == 0 {
+		timeout = defaultExecTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	in, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("encoding %s request: %w", req.Hook, err)
+	}
+
+	name := filepath.Base(e.Path)
+	args := append(append([]string{}, e.Args...), req.Hook)
+	cmd := exec.CommandContext(ctx, e.Path, args...)
+	cmd.Stdin = bytes.NewReader(in)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("extension %s %s: %w: %s", name, req.Hook, err, strings.TrimSpace(stderr.String()))
+	}
+
+	resp := &execResponse{}
+	if out := bytes.TrimSpace(stdout.Bytes()); len(out) > 0 {
+		if err := json.Unmarshal(out, resp); err != nil {
+			return nil, fmt.Errorf("extension %s %s: decoding response: %w", name, req.Hook, err)
+		}
+	}
+	if resp.Error != "" {
+		return nil, fmt.Errorf("extension %s %s: %s", name, req.Hook, resp.Error)
+	}
+	return resp, nil
+}
@@ -0,0 +1,138 @@
+// Package extensions defines hooks that let organizations customize how the
+// operator renders and applies Helm releases without forking the reconciler.
+//
+// Extensions are either compiled into the operator binary and registered by
+// name with Register (typically from an init function in a package imported by
+// main), or run as external binaries speaking a JSON protocol over stdin/stdout
+// (see Exec). The operator enables them with the --extensions and
+// --exec-extensions flags.
+package extensions
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Release identifies the HelmRelease a hook is invoked for.
+type Release struct {
+	Name            string `json:"name"`
+	Namespace       string `json:"namespace"`
+	ReleaseName     string `json:"releaseName"`
+	TargetNamespace string `json:"targetNamespace"`
+	Chart           string `json:"chart"`
+	RepoURL         string `json:"repoURL"`
+	Version         string `json:"version"`
+}
+
+// Extension is implemented by operator plugins. Hooks run in this order for
+// every install or upgrade:
+//
+//   - PreRender may rewrite the values passed to the chart.
+//   - PostRender may rewrite the rendered manifests before they are applied.
+//   - PreApply runs just before the Helm operation; an error aborts it.
+//   - PostApply runs after the Helm operation succeeded.
+//
+// Embed Base to implement only the hooks you need.
+type Extension interface {
+	PreRender(ctx context.Context, rel Release, values map[string]interface{}) (map[string]interface{}, error)
+	PostRender(ctx context.Context, rel Release, manifests []byte) ([]byte, error)
+	PreApply(ctx context.Context, rel Release) error
+	PostApply(ctx context.Context, rel Release) error
+}
+
+// Base is a no-op Extension intended for embedding.
+type Base struct{}
+
+func (Base) PreRender(_ context.Context, _ Release, values map[string]interface{}) (map[string]interface{}, error) {
+	return values, nil
+}
+
+func (Base) PostRender(_ context.Context, _ Release, manifests []byte) ([]byte, error) {
+	return manifests, nil
+}
+
+func (Base) PreApply(context.Context, Release) error { return nil }
+
+func (Base) PostApply(context.Context, Release) error { return nil }
+
+// Chain runs a list of extensions in order, feeding each hook's output into
+// the next. The zero value is a valid, empty chain.
+type Chain []Extension
+
+var _ Extension = Chain(nil) // compile-time interface check
+
+func (c Chain) PreRender(ctx context.Context, rel Release, values map[string]interface{}) (map[string]interface{}, error) {
+	for _, ext := range c {
+		var err error
+		if values, err = ext.PreRender(ctx, rel, values); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+func (c Chain) PostRender(ctx context.Context, rel Release, manifests []byte) ([]byte, error) {
+	for _, ext := range c {
+		var err error
+		if manifests, err = ext.PostRender(ctx, rel, manifests); err != nil {
+			return nil, err
+		}
+	}
+	return manifests, nil
+}
+
+func (c Chain) PreApply(ctx context.Context, rel Release) error {
+	for _, ext := range c {
+		if err := ext.PreApply(ctx, rel); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c Chain) PostApply(ctx context.Context, rel Release) error {
+	for _, ext := range c {
+		if err := ext.PostApply(ctx, rel); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Extension{}
+)
+
+// Register makes a compiled-in extension available under name. It panics if
+// the name is already taken, mirroring database/sql.Register.
+func Register(name string, ext Extension) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, dup := registry[name]; dup {
+		panic(fmt.Sprintf("extensions: Register called twice for %q", name))
+	}
+	registry[name] = ext
+}
+
+// Lookup returns the extension registered under name.
+func Lookup(name string) (Extension, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	ext, ok := registry[name]
+	return ext, ok
+}
+
+// Names returns the sorted names of all registered extensions.
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -1,73 +1,634 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
+	"io/fs"
 	"os"
+	"strings"
+	"text/template"
+	"time"
 
 	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"github.com/example/helm-operator/config/crd"
 	"github.com/example/helm-operator/controllers"
+	"github.com/example/helm-operator/extensions"
+	"github.com/example/helm-operator/notify"
+	"github.com/example/helm-operator/store"
+	"github.com/example/helm-operator/wasm"
 	"github.com/example/helm-operator/web"
+	"github.com/example/helm-operator/webhooks"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 )
 
 var scheme = runtime.NewScheme()
 
+const leaderElectionID = "helm-operator-leader.helm.example.com"
+
+// issueTokenEnv holds the GitHub or Jira token of --issue-tracker.
+const issueTokenEnv = "ISSUE_TRACKER_TOKEN"
+
 func init() {
 	_ = clientgoscheme.AddToScheme(scheme)
 	_ = helmv1alpha1.AddToScheme(scheme)
+	_ = apiextensionsv1.AddToScheme(scheme)
 }
 
 func main() {
 	var (
 		metricsAddr          string
 		enableLeaderElection bool
+		enableWebhooks       bool
 		probeAddr            string
 		uiAddr               string
+		extensionNames       string
+		execExtensions       string
+		allowedPostRenderers string
+		wasmModules          string
+		wasmLimits           wasm.Limits
+		breakerThreshold     int
+		breakerWindow        time.Duration
+		breakerCooldown      time.Duration
+		repoHealthInterval   time.Duration
+		repoHealthTimeout    time.Duration
+		secretsEncryption    string
+		encryptionInterval   time.Duration
+		rewriteRules         string
+		proxy                controllers.ProxyConfig
+		repoProxies          string
+		download             controllers.DownloadConfig
+		helmBackend          string
+		fakeHelmLatency      time.Duration
+		concurrentReconciles int
+		syncPeriod           time.Duration
+		lintCharts           bool
+		uiStore              string
+		uiRetention          store.Retention
+		compactionInterval   time.Duration
+		maxHistory           int
+		uiLocale             string
+		uiAdmins             string
+		slackApprovers       string
+		previewNamespaces    string
+		previewRepoURLs      string
+		previewSA            string
+		diagnoseReview       bool
+		apiTokenSecret       string
+		authzWebhook         web.AuthzWebhook
+		clusterHealth        controllers.ClusterHealthMonitor
+		updateCheckInterval  time.Duration
+		releaseLocks         bool
+		releaseLockDuration  time.Duration
+		driftCheckInterval   time.Duration
+		rbacServiceAccount   string
+		serializeUpgrades    bool
+		notifyChannels       string
+		digestSchedule       string
+		digestTemplate       string
+		notifyTeamRoutes     string
+		notifyTeamLabel      string
+		issueTracker         string
+		issueAfter           time.Duration
+		uiExternalURL        string
+		reconcileHistory     int
+		defaultSA            string
+		targetNamespaces     string
+		installCRDs          bool
+		indexCacheSize       int
+		failureThreshold     int
+		metricLabels         string
+		maxReleaseSeries     int
+		statusHistory        int
+		kubeAPIQPS           float64
+		kubeAPIBurst         int
+		forceCRDConflicts    bool
 	)
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.StringVar(&uiAddr, "ui-bind-address", ":8082", "The address the web UI binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", true,
 		"Enable leader election for controller manager. Enabling this will ensure there is only one active controller manager.")
+	flag.BoolVar(&enableWebhooks, "enable-webhooks", false,
+		"Serve the HelmRelease admission webhooks. Requires a serving certificate in /tmp/k8s-webhook-server/serving-certs.")
+	flag.StringVar(&extensionNames, "extensions", "",
+		"Comma-separated names of compiled-in extensions to run around every install and upgrade.")
+	flag.StringVar(&execExtensions, "exec-extensions", "",
+		"Comma-separated paths of extension binaries implementing the JSON hook protocol.")
+	flag.StringVar(&allowedPostRenderers, "allowed-post-renderers", "",
+		"Comma-separated commands that HelmReleases may run via spec.postRenderers[].exec. Empty disables exec post-renderers.")
+	flag.StringVar(&wasmModules, "wasm-modules", "",
+		"Comma-separated Wasm modules applied to every release, as namespace/configmap[:key][=Transform|Policy].")
+	flag.DurationVar(&wasmLimits.Timeout, "wasm-timeout", 10*time.Second, "Maximum run time of a single Wasm module invocation.")
+	var wasmMemoryPages uint
+	flag.UintVar(&wasmMemoryPages, "wasm-memory-pages", 256, "Maximum linear memory of a Wasm module, in 64 KiB pages.")
+	flag.IntVar(&breakerThreshold, "repo-breaker-threshold", 5,
+		"Install/upgrade failures against one chart repository, within --repo-breaker-window, that open its circuit breaker. 0 disables the breaker.")
+	flag.DurationVar(&breakerWindow, "repo-breaker-window", 2*time.Minute, "Window over which repository failures are counted.")
+	flag.DurationVar(&breakerCooldown, "repo-breaker-cooldown", time.Minute,
+		"How long an open repository breaker blocks attempts; doubles on each consecutive trip.")
+	flag.DurationVar(&repoHealthInterval, "repo-health-interval", time.Minute,
+		"How often to check the index of every chart repository in use. 0 disables repository health monitoring.")
+	flag.DurationVar(&repoHealthTimeout, "repo-health-timeout", 10*time.Second, "Timeout of a single repository index check.")
+	flag.StringVar(&rewriteRules, "rewrite", "",
+		"Comma-separated from=to prefix rewrites applied to chart repository URLs and image references in values, e.g. registry.example.com/*=mirror.internal/*.")
+	flag.StringVar(&proxy.HTTPProxy, "http-proxy", "", "Proxy for plain-HTTP chart downloads. Overrides HTTP_PROXY when any proxy flag is set.")
+	flag.StringVar(&proxy.HTTPSProxy, "https-proxy", "", "Proxy for HTTPS chart downloads. Overrides HTTPS_PROXY when any proxy flag is set.")
+	flag.StringVar(&proxy.NoProxy, "no-proxy", "", "Hosts excluded from proxying, in NO_PROXY syntax.")
+	flag.StringVar(&repoProxies, "repo-proxies", "",
+		"Comma-separated urlPrefix=proxyURL overrides for specific repositories or registries. A proxyURL of \"direct\" disables proxying.")
+	flag.StringVar(&download.UserAgent, "user-agent", controllers.DefaultUserAgent, "User-Agent sent to chart repositories.")
+	flag.DurationVar(&download.Timeout, "download-timeout", 2*time.Minute, "Timeout of a single chart index or tarball request.")
+	flag.IntVar(&download.Retries, "download-retries", 3, "How many times a failed chart index or tarball request is retried.")
+	flag.DurationVar(&download.RetryBackoff, "download-retry-backoff", time.Second, "Delay before the first download retry; doubles on each further retry.")
+	flag.IntVar(&indexCacheSize, "index-cache-size", controllers.DefaultIndexCacheSize>>20,
+		"MiB of repository indexes kept in memory and revalidated with ETag/If-Modified-Since instead of downloaded again. 0 disables the cache.")
+	flag.StringVar(&helmBackend, "helm-backend", "helm",
+		"Helm implementation: helm, or fake for an in-memory backend used for scale testing with cmd/loadgen.")
+	flag.DurationVar(&fakeHelmLatency, "fake-helm-latency", 0, "Simulated duration of each operation with --helm-backend=fake.")
+	flag.IntVar(&concurrentReconciles, "max-concurrent-reconciles", 1, "Maximum number of HelmReleases reconciled in parallel.")
+	flag.DurationVar(&syncPeriod, "sync-period", 10*time.Hour,
+		"How often every HelmRelease is reconciled even without changes.")
+	flag.BoolVar(&lintCharts, "lint-charts", true,
+		"Run helm lint before each install and upgrade and report findings in the LintWarnings condition.")
+	flag.DurationVar(&updateCheckInterval, "update-check-interval", time.Hour,
+		"How often ready releases are checked for newer chart versions (UpdateAvailable condition). 0 disables.")
+	flag.DurationVar(&driftCheckInterval, "drift-check-interval", 5*time.Minute,
+		"How often releases with driftPolicy Correct or Warn are compared with the live cluster. 0 disables.")
+	flag.BoolVar(&releaseLocks, "release-locks", true,
+		"Hold a coordination.k8s.io Lease per release during Helm operations so other tools can avoid concurrent changes.")
+	flag.IntVar(&failureThreshold, "ready-failure-threshold", 3,
+		"Consecutive transient failures (network errors, timeouts, 5xx responses) a Ready release tolerates before it turns Ready=False. 1 degrades on the first failure.")
+	flag.StringVar(&metricLabels, "metrics-release-labels", strings.Join(controllers.DefaultReleaseMetricLabels, ","),
+		"Comma-separated labels of the per-release metrics: namespace, name, chart, version, targetNamespace and label:<key> to pass a HelmRelease label through. Releases with the same label values share a series.")
+	flag.IntVar(&maxReleaseSeries, "metrics-max-release-series", 10000,
+		"Maximum series of each per-release metric; further releases are counted by helm_operator_release_metrics_dropped_series instead. 0 means no limit.")
+	flag.DurationVar(&releaseLockDuration, "release-lock-duration", time.Minute,
+		"Duration of release lock Leases; they are renewed while an operation runs.")
+	flag.BoolVar(&serializeUpgrades, "serialize-namespace-upgrades", false,
+		"Run at most one upgrade at a time per target namespace; others wait their turn.")
+	flag.StringVar(&rbacServiceAccount, "rbac-service-account", "",
+		"The operator's ServiceAccount as namespace/name. Releases with spec.rbac.autoProvision bind their provisioned Role to it.")
+	flag.StringVar(&uiStore, "ui-store", "memory",
+		"Where the web UI keeps its audit log, diagnosis history and saved views: memory, sqlite:///path/ui.db or a postgres:// URL.")
+	flag.DurationVar(&uiRetention.MaxAge, "ui-store-max-age", 90*24*time.Hour,
+		"Audit entries and diagnoses older than this are removed from the web UI store. 0 keeps them forever.")
+	flag.IntVar(&uiRetention.MaxEntries, "ui-store-max-entries", 10000,
+		"Maximum number of audit entries, and of diagnoses, kept in the web UI store. 0 removes no matter how many.")
+	flag.DurationVar(&compactionInterval, "ui-store-compaction-interval", time.Hour,
+		"How often --ui-store-max-age and --ui-store-max-entries are applied to the web UI store.")
+	flag.IntVar(&maxHistory, "max-history", 10,
+		"Maximum number of Helm revisions kept per release unless spec.maxHistory says otherwise; older revision Secrets are deleted on upgrade. 0 keeps all.")
+	flag.IntVar(&statusHistory, "status-history", controllers.DefaultStatusHistory,
+		"Number of Helm revisions listed in each HelmRelease's status.history. 0 lists none.")
+	flag.StringVar(&uiLocale, "ui-default-locale", web.DefaultLocale,
+		"Web UI language for browsers that accept none of the bundled ones; also fills in messages missing from a language pack.")
+	flag.DurationVar(&clusterHealth.Interval, "cluster-health-interval", 30*time.Second,
+		"How often to check whether the cluster is under duress; upgrades pause while it is. 0 disables the check.")
+	flag.Float64Var(&clusterHealth.MaxNotReadyNodes, "freeze-not-ready-nodes", 0.2,
+		"Fraction of NotReady nodes above which upgrades pause. 0 disables this detector.")
+	flag.Float64Var(&clusterHealth.MaxAPIErrorRate, "freeze-api-error-rate", 0.25,
+		"Fraction of the operator's API server requests failing with 5xx or 429 above which upgrades pause. 0 disables this detector.")
+	flag.StringVar(&secretsEncryption, "secrets-encryption", controllers.SecretsEncryptionAuto,
+		"Whether Secrets are encrypted at rest: auto detects it from the kube-apiserver's flags, encrypted or unencrypted state it for clusters where that is not possible.")
+	flag.DurationVar(&encryptionInterval, "secrets-encryption-check-interval", 10*time.Minute,
+		"How often to check that values read from Secrets are stored in Secrets encrypted at rest. 0 disables the check.")
+	flag.StringVar(&uiAdmins, "ui-admins", "",
+		"Comma-separated users, as named by the authenticating proxy, who may manage API tokens.")
+	flag.StringVar(&slackApprovers, "slack-approvers", "",
+		"Comma-separated Slack user names or IDs who may approve /helmop changes. Empty requires RBAC permission, as the user slack:<name>, to update the affected HelmReleases.")
+	flag.StringVar(&previewNamespaces, "preview-namespaces", "",
+		"Comma-separated namespaces /api/ci/preview may create previews in, besides those annotated helm.example.com/previews=true.")
+	flag.StringVar(&previewRepoURLs, "preview-repo-urls", "",
+		"Comma-separated chart repository URLs previews may install from, besides those a namespace lists in helm.example.com/preview-repo-urls.")
+	flag.StringVar(&previewSA, "preview-service-account", "",
+		"ServiceAccount Helm impersonates for previews, unless the namespace names one in helm.example.com/preview-service-account. Previews are refused without either.")
+	flag.BoolVar(&diagnoseReview, "ui-diagnose-access-review", false,
+		"Require the custom verb diagnose on a HelmRelease, checked with a SubjectAccessReview, for users other than --ui-admins to diagnose it.")
+	flag.StringVar(&apiTokenSecret, "api-token-secret", "",
+		"Secret holding the hashed API tokens, as namespace/name. Defaults to "+web.DefaultTokenSecretName+" in the operator's namespace.")
+	flag.StringVar(&authzWebhook.URL, "authz-webhook-url", "",
+		"URL of an external authorization webhook that must allow every change made through the web API.")
+	flag.DurationVar(&authzWebhook.Timeout, "authz-webhook-timeout", 5*time.Second, "Timeout of a single authorization webhook call.")
+	flag.BoolVar(&authzWebhook.FailOpen, "authz-webhook-fail-open", false,
+		"Allow changes when the authorization webhook cannot be reached or fails, instead of denying them.")
+	flag.StringVar(&notifyChannels, "notify-channels", "",
+		"Comma-separated notification channels: Slack incoming webhook URLs and smtp://user:pw@host:port?from=&to= URLs.")
+	flag.StringVar(&digestSchedule, "digest-schedule", "",
+		"When to send the fleet digest to --notify-channels: \"daily HH:MM\" or \"weekly <day> HH:MM\", in UTC. Empty disables it.")
+	flag.StringVar(&digestTemplate, "digest-template", "",
+		"File with a Go text/template for the fleet digest body. Defaults to a built-in plain-text summary.")
+	flag.StringVar(&notifyTeamRoutes, "notify-team-routes", "",
+		"YAML file mapping team names, or \"*\" for any other team, to notification channels as in --notify-channels. Failed and drifted releases are reported to their team's channels. Empty disables the alerts.")
+	flag.StringVar(&notifyTeamLabel, "notify-team-label", controllers.DefaultTeamLabel,
+		"HelmRelease label naming the team whose --notify-team-routes channels receive its alerts.")
+	flag.StringVar(&issueTracker, "issue-tracker", "",
+		"Tracker for tickets about releases that stay Failed: github://owner/repo or jira://user@host/PROJECT. The token is read from $"+issueTokenEnv+". Empty disables tickets.")
+	flag.DurationVar(&issueAfter, "issue-after", time.Hour, "How long a release must stay Failed before --issue-tracker gets a ticket.")
+	flag.StringVar(&uiExternalURL, "ui-external-url", "", "External URL of the web UI, linked from tickets.")
+	flag.StringVar(&defaultSA, "default-service-account", "",
+		"ServiceAccount, in each HelmRelease's namespace, that Helm impersonates for releases without spec.serviceAccountName. Empty runs them with the operator's own permissions.")
+	flag.StringVar(&targetNamespaces, "allowed-target-namespaces", "",
+		"Comma-separated namespaces a HelmRelease may deploy into besides its own; \"*\" allows any. Releases with spec.kubeConfig are not restricted.")
+	flag.IntVar(&reconcileHistory, "reconcile-history", controllers.DefaultReconcileLogSize,
+		"Number of reconcile attempts kept in memory per release and served by /api/helmreleases/reconciles. 0 disables the history.")
 	opts := zap.Options{Development: true}
+	flag.BoolVar(&installCRDs, "install-crds", false,
+		"Server-side apply the operator's CRDs at startup and wait until they are established. Needs create and patch on customresourcedefinitions.")
+	flag.BoolVar(&forceCRDConflicts, "install-crds-force", false,
+		"With --install-crds, take over CRD fields owned by another field manager (such as a previous helm or kubectl apply) instead of failing.")
+	flag.Float64Var(&kubeAPIQPS, "kube-api-qps", 20,
+		"Sustained requests per second to the API server, shared by the controller and Helm. Requests beyond it wait client-side; see helm_operator_client_throttled_requests_total.")
+	flag.IntVar(&kubeAPIBurst, "kube-api-burst", 30, "Requests allowed above --kube-api-qps in a burst.")
 	opts.BindFlags(flag.CommandLine)
 	flag.Parse()
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+	wasmLimits.MemoryPages = uint32(wasmMemoryPages)
 
 	restConfig := ctrl.GetConfigOrDie()
+	restConfig.QPS = float32(kubeAPIQPS)
+	restConfig.Burst = kubeAPIBurst
+
+	// The CRDs must be served before the manager's caches start watching.
+	if installCRDs {
+		c, err := client.New(restConfig, client.Options{Scheme: scheme})
+		if err != nil {
+			ctrl.Log.Error(err, "unable to create client for CRD installation")
+			os.Exit(1)
+		}
+		bases, _ := fs.Sub(crd.Bases, "bases")
+		installer := &controllers.CRDInstaller{Client: c, CRDs: bases, Force: forceCRDConflicts}
+		if err := installer.Install(context.Background()); err != nil {
+			ctrl.Log.Error(err, "unable to install CRDs")
+			os.Exit(1)
+		}
+	}
 
 	mgr, err := ctrl.NewManager(restConfig, ctrl.Options{
 		Scheme: scheme,
 		Metrics: metricsserver.Options{
 			BindAddress: metricsAddr,
 		},
+		Cache: cache.Options{SyncPeriod: &syncPeriod},
+		// ConfigMaps and Secrets are read on demand (Wasm modules, owned
+		// object cleanup); caching them would watch every one in the cluster.
+		// Release lock Leases must be read fresh from the API server.
+		// Provisioned Roles are only touched while applying a release.
+		Client: client.Options{Cache: &client.CacheOptions{
+			DisableFor: []client.Object{&corev1.ConfigMap{}, &corev1.Secret{}, &coordinationv1.Lease{},
+				&rbacv1.Role{}, &rbacv1.RoleBinding{}},
+		}},
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
-		LeaderElectionID:       "helm-operator-leader.helm.example.com",
+		LeaderElectionID:       leaderElectionID,
 	})
 	if err != nil {
 		ctrl.Log.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 
-	helmClient := controllers.NewHelmClient(restConfig)
+	proxy.Overrides, err = controllers.ParseProxyOverrides(repoProxies)
+	if err != nil {
+		ctrl.Log.Error(err, "invalid --repo-proxies")
+		os.Exit(1)
+	}
+
+	var helmClient controllers.HelmClientInterface
+	var remoteHelmClient func(*rest.Config) controllers.HelmClientInterface
+	switch helmBackend {
+	case "helm":
+		hc := controllers.NewHelmClient(restConfig)
+		hc.Proxy = proxy
+		hc.Download = download
+		hc.MaxHistory = maxHistory
+		if indexCacheSize > 0 {
+			hc.Indexes = &controllers.IndexCache{MaxBytes: int64(indexCacheSize) << 20}
+		}
+		helmClient = hc
+		remoteHelmClient = func(cfg *rest.Config) controllers.HelmClientInterface { return hc.ForCluster(cfg) }
+	case "fake":
+		ctrl.Log.Info("Using the fake Helm backend; charts will not be installed")
+		fake := &controllers.FakeHelmClient{Latency: fakeHelmLatency}
+		helmClient = fake
+		remoteHelmClient = func(*rest.Config) controllers.HelmClientInterface { return fake }
+	default:
+		ctrl.Log.Error(fmt.Errorf("unknown backend %q", helmBackend), "invalid --helm-backend")
+		os.Exit(1)
+	}
+
+	exts, err := buildExtensions(extensionNames, execExtensions)
+	if err != nil {
+		ctrl.Log.Error(err, "unable to configure extensions")
+		os.Exit(1)
+	}
+
+	globalWasm, err := controllers.ParseGlobalWasmModules(wasmModules)
+	if err != nil {
+		ctrl.Log.Error(err, "invalid --wasm-modules")
+		os.Exit(1)
+	}
+
+	rewrites, err := controllers.ParseRewriteRules(rewriteRules)
+	if err != nil {
+		ctrl.Log.Error(err, "invalid --rewrite")
+		os.Exit(1)
+	}
+
+	releaseMetrics, err := controllers.NewReleaseMetrics(splitList(metricLabels), maxReleaseSeries)
+	if err == nil {
+		err = releaseMetrics.Register(ctrlmetrics.Registry)
+	}
+	if err != nil {
+		ctrl.Log.Error(err, "invalid --metrics-release-labels")
+		os.Exit(1)
+	}
+
+	var digest *controllers.FleetDigest
+	if digestSchedule != "" {
+		schedule, err := controllers.ParseDigestSchedule(digestSchedule)
+		if err != nil {
+			ctrl.Log.Error(err, "invalid --digest-schedule")
+			os.Exit(1)
+		}
+		if notifyChannels == "" {
+			ctrl.Log.Error(nil, "--digest-schedule requires --notify-channels")
+			os.Exit(1)
+		}
+		notifier, err := notify.OpenAll(notifyChannels)
+		if err != nil {
+			ctrl.Log.Error(err, "invalid --notify-channels")
+			os.Exit(1)
+		}
+		digest = &controllers.FleetDigest{Notifier: notifier, Schedule: schedule}
+		if digestTemplate != "" {
+			digest.Template, err = template.ParseFiles(digestTemplate)
+			if err != nil {
+				ctrl.Log.Error(err, "invalid --digest-template")
+				os.Exit(1)
+			}
+		}
+	}
+
+	var reconcileLog *controllers.ReconcileLog
+	if reconcileHistory > 0 {
+		reconcileLog = &controllers.ReconcileLog{Size: reconcileHistory}
+	}
+
+	var alerts *controllers.ReleaseAlerts
+	if notifyTeamRoutes != "" {
+		data, err := os.ReadFile(notifyTeamRoutes)
+		if err != nil {
+			ctrl.Log.Error(err, "unable to read --notify-team-routes")
+			os.Exit(1)
+		}
+		routes, err := notify.OpenRoutes(data)
+		if err != nil {
+			ctrl.Log.Error(err, "invalid --notify-team-routes")
+			os.Exit(1)
+		}
+		alerts = &controllers.ReleaseAlerts{Routes: routes, TeamLabel: notifyTeamLabel}
+	}
+
+	var issueTrackerClient notify.Tracker
+	if issueTracker != "" {
+		issueTrackerClient, err = notify.OpenTracker(issueTracker, os.Getenv(issueTokenEnv))
+		if err != nil {
+			ctrl.Log.Error(err, "invalid --issue-tracker")
+			os.Exit(1)
+		}
+	}
+
+	var breakers *controllers.RepositoryBreakers
+	if breakerThreshold > 0 {
+		breakers = controllers.NewRepositoryBreakers(breakerThreshold, breakerWindow, breakerCooldown)
+	}
+
+	var locker *controllers.ReleaseLocker
+	if releaseLocks {
+		identity, err := os.Hostname()
+		if err != nil {
+			ctrl.Log.Error(err, "unable to determine release lock identity")
+			os.Exit(1)
+		}
+		locker = &controllers.ReleaseLocker{Client: mgr.GetClient(), Identity: "helm-operator/" + identity, Duration: releaseLockDuration}
+	}
+
+	var upgradeBarrier *controllers.NamespaceBarrier
+	if serializeUpgrades {
+		upgradeBarrier = &controllers.NamespaceBarrier{}
+	}
+
+	var rbacSubject *rbacv1.Subject
+	if rbacServiceAccount != "" {
+		ns, name, ok := strings.Cut(rbacServiceAccount, "/")
+		if !ok || ns == "" || name == "" {
+			ctrl.Log.Error(nil, "invalid --rbac-service-account, expected namespace/name", "value", rbacServiceAccount)
+			os.Exit(1)
+		}
+		rbacSubject = &rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Namespace: ns, Name: name}
+	}
+
+	var healthMonitor *controllers.ClusterHealthMonitor
+	if clusterHealth.Interval > 0 {
+		healthMonitor = &clusterHealth
+		healthMonitor.Client = mgr.GetClient()
+		if err := mgr.Add(healthMonitor); err != nil {
+			ctrl.Log.Error(err, "unable to add cluster health monitor to manager")
+			os.Exit(1)
+		}
+	}
+
+	// A CRD older than the binary silently drops the fields it lacks; flag
+	// it on every release rather than refuse to start mid-upgrade.
+	crdStatus, err := controllers.CheckCRD(context.Background(), mgr.GetAPIReader())
+	switch {
+	case err != nil:
+		ctrl.Log.Error(err, "unable to check the HelmRelease CRD against the operator version")
+	case len(crdStatus.MissingFields) > 0:
+		ctrl.Log.Info("The HelmRelease CRD is older than the operator and drops fields; upgrade it",
+			"version", controllers.Version, "missingFields", crdStatus.MissingFields)
+	}
 
 	if err := (&controllers.HelmReleaseReconciler{
-		Client:     mgr.GetClient(),
-		Scheme:     mgr.GetScheme(),
-		HelmClient: helmClient,
+		Client:                  mgr.GetClient(),
+		Scheme:                  mgr.GetScheme(),
+		HelmClient:              helmClient,
+		Extensions:              exts,
+		AllowedPostRenderers:    splitList(allowedPostRenderers),
+		WasmRuntime:             wasm.New(wasmLimits),
+		GlobalWasmModules:       globalWasm,
+		Breakers:                breakers,
+		Rewrites:                rewrites,
+		MaxConcurrentReconciles: concurrentReconciles,
+		SkipLint:                !lintCharts,
+		UpdateCheckInterval:     updateCheckInterval,
+		DriftCheckInterval:      driftCheckInterval,
+		Locker:                  locker,
+		UpgradeBarrier:          upgradeBarrier,
+		ClusterHealth:           healthMonitor,
+		RBACSubject:             rbacSubject,
+		Discovery:               discovery.NewDiscoveryClientForConfigOrDie(restConfig),
+		Recorder:                mgr.GetEventRecorderFor("helmrelease-controller"),
+		CRD:                     crdStatus,
+		FailureThreshold:        failureThreshold,
+		Alerts:                  alerts,
+		ReconcileLog:            reconcileLog,
+		Metrics:                 releaseMetrics,
+		StatusHistory:           statusHistory,
+		DefaultServiceAccount:   defaultSA,
+		TargetNamespaces:        splitList(targetNamespaces),
+		RemoteHelmClient:        remoteHelmClient,
 	}).SetupWithManager(mgr); err != nil {
 		ctrl.Log.Error(err, "unable to create controller", "controller", "HelmRelease")
 		os.Exit(1)
 	}
 
-	if err := mgr.Add(&web.WebServer{Client: mgr.GetClient(), Addr: uiAddr}); err != nil {
+	if enableWebhooks {
+		if err := (&webhooks.HelmReleaseDefaulter{Client: mgr.GetClient(), MaxHistory: &maxHistory}).SetupWithManager(mgr); err != nil {
+			ctrl.Log.Error(err, "unable to create webhook", "webhook", "HelmRelease")
+			os.Exit(1)
+		}
+		if err := (&webhooks.HelmReleaseValidator{}).SetupWithManager(mgr); err != nil {
+			ctrl.Log.Error(err, "unable to create webhook", "webhook", "HelmRelease")
+			os.Exit(1)
+		}
+	}
+
+	var repoMonitor *controllers.RepositoryMonitor
+	if repoHealthInterval > 0 {
+		repoMonitor = &controllers.RepositoryMonitor{
+			Client:    mgr.GetClient(),
+			Interval:  repoHealthInterval,
+			Timeout:   repoHealthTimeout,
+			Rewrites:  rewrites,
+			Proxy:     proxy,
+			UserAgent: download.UserAgent,
+		}
+		if err := mgr.Add(repoMonitor); err != nil {
+			ctrl.Log.Error(err, "unable to add repository monitor to manager")
+			os.Exit(1)
+		}
+	}
+
+	var encryptionMonitor *controllers.EncryptionMonitor
+	if encryptionInterval > 0 {
+		switch secretsEncryption {
+		case controllers.SecretsEncryptionAuto, controllers.SecretsEncryptionEncrypted, controllers.SecretsEncryptionUnencrypted:
+		default:
+			ctrl.Log.Error(nil, "invalid --secrets-encryption, expected auto, encrypted or unencrypted", "value", secretsEncryption)
+			os.Exit(1)
+		}
+		encryptionMonitor = &controllers.EncryptionMonitor{
+			Client:   mgr.GetAPIReader(),
+			Interval: encryptionInterval,
+			Assume:   secretsEncryption,
+		}
+		if err := mgr.Add(encryptionMonitor); err != nil {
+			ctrl.Log.Error(err, "unable to add secrets encryption monitor to manager")
+			os.Exit(1)
+		}
+	}
+
+	if digest != nil {
+		digest.Client = mgr.GetClient()
+		if err := mgr.Add(digest); err != nil {
+			ctrl.Log.Error(err, "unable to add fleet digest to manager")
+			os.Exit(1)
+		}
+	}
+
+	uiData, err := store.Open(context.Background(), uiStore)
+	if err != nil {
+		ctrl.Log.Error(err, "unable to open web UI store")
+		os.Exit(1)
+	}
+	defer uiData.Close()
+	if compactionInterval > 0 {
+		compactor := &store.Compactor{Store: uiData, Retention: uiRetention, Interval: compactionInterval}
+		if err := mgr.Add(compactor); err != nil {
+			ctrl.Log.Error(err, "unable to add web UI store compactor to manager")
+			os.Exit(1)
+		}
+	}
+
+	if issueTrackerClient != nil {
+		filer := &controllers.IssueFiler{
+			Client:  mgr.GetClient(),
+			Tracker: issueTrackerClient,
+			After:   issueAfter,
+			UIURL:   uiExternalURL,
+			Diagnosis: func(ctx context.Context, namespace, name string) (string, error) {
+				diagnoses, err := uiData.ListDiagnoses(ctx, store.ListOptions{Namespace: namespace, Name: name, Limit: 1})
+				if err != nil || len(diagnoses) == 0 {
+					return "", err
+				}
+				return diagnoses[0].Text, nil
+			},
+		}
+		if err := mgr.Add(filer); err != nil {
+			ctrl.Log.Error(err, "unable to add issue filer to manager")
+			os.Exit(1)
+		}
+	}
+
+	var tokenSecret types.NamespacedName
+	if apiTokenSecret != "" {
+		ns, name, ok := strings.Cut(apiTokenSecret, "/")
+		if !ok || ns == "" || name == "" {
+			ctrl.Log.Error(nil, "invalid --api-token-secret, expected namespace/name", "value", apiTokenSecret)
+			os.Exit(1)
+		}
+		tokenSecret = types.NamespacedName{Namespace: ns, Name: name}
+	}
+
+	var uiAuthz *web.AuthzWebhook
+	if authzWebhook.URL != "" {
+		uiAuthz = &authzWebhook
+	}
+
+	var uiLeaderElectionID string
+	if enableLeaderElection {
+		uiLeaderElectionID = leaderElectionID
+	}
+	if err := mgr.Add(&web.WebServer{
+		Client:                mgr.GetClient(),
+		APIReader:             mgr.GetAPIReader(),
+		Addr:                  uiAddr,
+		HelmClient:            helmClient,
+		Breakers:              breakers,
+		RepositoryMonitor:     repoMonitor,
+		Store:                 uiData,
+		LeaderElectionID:      uiLeaderElectionID,
+		DefaultLocale:         uiLocale,
+		Admins:                splitList(uiAdmins),
+		SlackApprovers:        splitList(slackApprovers),
+		PreviewNamespaces:     splitList(previewNamespaces),
+		PreviewRepoURLs:       splitList(previewRepoURLs),
+		PreviewServiceAccount: previewSA,
+		DiagnoseReview:        diagnoseReview,
+		TokenSecret:           tokenSecret,
+		AuthzWebhook:          uiAuthz,
+		CRD:                   crdStatus,
+		Rewrites:              rewrites,
+		Encryption:            encryptionMonitor,
+		ReconcileLog:          reconcileLog,
+	}); err != nil {
 		ctrl.Log.Error(err, "unable to add web server to manager")
 		os.Exit(1)
 	}
@@ -87,3 +648,31 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// buildExtensions resolves the --extensions and --exec-extensions flags into
+// the chain run by the reconciler. Compiled-in extensions run first.
+func buildExtensions(names, execPaths string) (extensions.Chain, error) {
+	var chain extensions.Chain
+	for _, name := range splitList(names) {
+		ext, ok := extensions.Lookup(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown extension %q (registered: %s)", name, strings.Join(extensions.Names(), ", "))
+		}
+		chain = append(chain, ext)
+	}
+	for _, path := range splitList(execPaths) {
+		chain = append(chain, &extensions.Exec{Path: path})
+	}
+	return chain, nil
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries.
+func splitList(s string) []string {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
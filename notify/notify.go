@@ -0,0 +1,205 @@
+// Package notify delivers operator notifications, such as the fleet digest,
+// to chat and email channels, and opens tickets in issue trackers (see
+// Tracker). A channel is chosen with a URL:
+//
+//	https://hooks.slack.com/services/...            a Slack incoming webhook
+//	smtp://user:pw@mail:587?from=op@x&to=a@x,b@x    email through an SMTP relay
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Message is a notification. Body is plain text; chat channels render it
+// as-is and email sends it as text/plain.
+type Message struct {
+	Subject string
+	Body    string
+}
+
+// Notifier delivers messages to one or more channels.
+type Notifier interface {
+	Notify(ctx context.Context, msg Message) error
+}
+
+// Open returns the Notifier for a channel URL.
+func Open(channel string) (Notifier, error) {
+	u, err := url.Parse(channel)
+	if err != nil {
+		return nil, fmt.Errorf("channel %q: %w", redact(channel), err)
+	}
+	switch u.Scheme {
+	case "https", "http":
+		if u.Host == "" {
+			return nil, fmt.Errorf("channel %q: missing host", redact(channel))
+		}
+		return &Slack{WebhookURL: channel}, nil
+	case "smtp":
+		return openSMTP(u)
+	default:
+		return nil, fmt.Errorf("channel %q: unsupported scheme %q (want https or smtp)", redact(channel), u.Scheme)
+	}
+}
+
+// OpenAll returns a Notifier sending to every channel of a comma- or
+// whitespace-separated list. Commas inside a URL's query, such as several
+// smtp recipients, are kept.
+func OpenAll(channels string) (Notifier, error) {
+	var out Multi
+	for _, channel := range splitChannels(channels) {
+		n, err := Open(channel)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, n)
+	}
+	return out, nil
+}
+
+// splitChannels splits a channel list on whitespace and on commas that start
+// a new URL.
+func splitChannels(channels string) []string {
+	var out []string
+	for _, field := range strings.Fields(channels) {
+		for _, part := range strings.Split(field, ",") {
+			if part == "" {
+				continue
+			}
+			if len(out) > 0 && !strings.Contains(part, "://") {
+				out[len(out)-1] += "," + part
+				continue
+			}
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// Multi sends a message to every Notifier, continuing past failures.
+type Multi []Notifier
+
+// Notify implements Notifier.
+func (m Multi) Notify(ctx context.Context, msg Message) error {
+	var errs []error
+	for _, n := range m {
+		if err := n.Notify(ctx, msg); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Slack posts messages to a Slack incoming webhook.
+type Slack struct {
+	WebhookURL string
+	// HTTPClient defaults to a client with a 10 second timeout.
+	HTTPClient *http.Client
+}
+
+// Notify implements Notifier.
+func (s *Slack) Notify(ctx context.Context, msg Message) error {
+	text := msg.Body
+	if msg.Subject != "" {
+		text = "*" + msg.Subject + "*\n" + msg.Body
+	}
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	httpClient := s.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("posting to Slack webhook %s: %w", req.URL.Host, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("posting to Slack webhook %s: %s", req.URL.Host, resp.Status)
+	}
+	return nil
+}
+
+// SMTP sends messages as email through an SMTP relay. The connection is
+// upgraded with STARTTLS when the relay offers it.
+type SMTP struct {
+	// Addr is the relay's host:port.
+	Addr string
+	From string
+	To   []string
+	// Auth is nil for relays that accept mail without authentication.
+	Auth smtp.Auth
+}
+
+func openSMTP(u *url.URL) (*SMTP, error) {
+	q := u.Query()
+	s := &SMTP{Addr: u.Host, From: q.Get("from")}
+	for _, to := range strings.Split(q.Get("to"), ",") {
+		if to = strings.TrimSpace(to); to != "" {
+			s.To = append(s.To, to)
+		}
+	}
+	host, port, err := net.SplitHostPort(u.Host)
+	if err != nil {
+		host, port = u.Host, "25"
+		s.Addr = net.JoinHostPort(host, port)
+	}
+	switch {
+	case host == "":
+		return nil, fmt.Errorf("channel %q: missing host", u.Redacted())
+	case s.From == "":
+		return nil, fmt.Errorf("channel %q: missing from=", u.Redacted())
+	case len(s.To) == 0:
+		return nil, fmt.Errorf("channel %q: missing to=", u.Redacted())
+	}
+	if u.User != nil {
+		password, _ := u.User.Password()
+		s.Auth = smtp.PlainAuth("", u.User.Username(), password, host)
+	}
+	return s, nil
+}
+
+// Notify implements Notifier. net/smtp has no context support, so ctx is
+// only checked before sending.
+func (s *SMTP) Notify(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", s.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(s.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", strings.NewReplacer("\r", "", "\n", " ").Replace(msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	if err := smtp.SendMail(s.Addr, s.Auth, s.From, s.To, []byte(b.String())); err != nil {
+		return fmt.Errorf("sending mail through %s: %w", s.Addr, err)
+	}
+	return nil
+}
+
+// redact hides the password of a channel URL in error messages.
+func redact(channel string) string {
+	u, err := url.Parse(channel)
+	if err != nil {
+		return "<invalid URL>"
+	}
+	return u.Redacted()
+}
@@ -0,0 +1,64 @@
+package notify
+
+import (
+	"fmt"
+	"sort"
+
+	"sigs.k8s.io/yaml"
+)
+
+// DefaultRoute is the key of a routes file whose channels receive the
+// messages of teams without channels of their own.
+const DefaultRoute = "*"
+
+// Router picks the channels of the team a message concerns.
+type Router struct {
+	Teams map[string]Notifier
+	// Default receives the messages of teams without channels of their own.
+	// Optional.
+	Default Notifier
+}
+
+// OpenRoutes returns a Router for a YAML or JSON object mapping team names,
+// or DefaultRoute, to channel lists as OpenAll takes them:
+//
+//	payments: https://hooks.slack.com/services/T/B/payments
+//	search: smtp://mail:25?from=op@x&to=search@x
+//	"*": https://hooks.slack.com/services/T/B/platform
+func OpenRoutes(data []byte) (*Router, error) {
+	var routes map[string]string
+	if err := yaml.Unmarshal(data, &routes); err != nil {
+		return nil, fmt.Errorf("parsing routes: %w", err)
+	}
+	teams := make([]string, 0, len(routes))
+	for team := range routes {
+		teams = append(teams, team)
+	}
+	sort.Strings(teams)
+
+	r := &Router{Teams: map[string]Notifier{}}
+	for _, team := range teams {
+		n, err := OpenAll(routes[team])
+		if err != nil {
+			return nil, fmt.Errorf("team %s: %w", team, err)
+		}
+		if team == DefaultRoute {
+			r.Default = n
+			continue
+		}
+		r.Teams[team] = n
+	}
+	return r, nil
+}
+
+// For returns the Notifier of team, falling back to Default. It returns nil
+// when neither is set.
+func (r *Router) For(team string) Notifier {
+	if r == nil {
+		return nil
+	}
+	if n, ok := r.Teams[team]; ok && team != "" {
+		return n
+	}
+	return r.Default
+}
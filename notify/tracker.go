@@ -0,0 +1,181 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Issue is a ticket to open in an issue tracker.
+type Issue struct {
+	Title  string
+	Body   string
+	Labels []string
+}
+
+// Tracker opens tickets in an issue tracker. A tracker is chosen with a URL:
+//
+//	github://owner/repo?labels=helm,oncall          GitHub issues; api= selects a GitHub Enterprise API
+//	jira://user@jira.example.com/OPS?type=Bug       Jira issues in project OPS
+type Tracker interface {
+	// Open creates the issue and returns its web URL.
+	Open(ctx context.Context, issue Issue) (string, error)
+}
+
+// OpenTracker returns the Tracker for a tracker URL. token is a GitHub token
+// or a Jira API token, kept out of the URL so it can come from a Secret.
+func OpenTracker(tracker, token string) (Tracker, error) {
+	u, err := url.Parse(tracker)
+	if err != nil {
+		return nil, fmt.Errorf("tracker %q: %w", redact(tracker), err)
+	}
+	q := u.Query()
+	var labels []string
+	for _, l := range strings.Split(q.Get("labels"), ",") {
+		if l = strings.TrimSpace(l); l != "" {
+			labels = append(labels, l)
+		}
+	}
+	path := strings.Trim(u.Path, "/")
+	switch u.Scheme {
+	case "github":
+		if u.Host == "" || path == "" || strings.Contains(path, "/") {
+			return nil, fmt.Errorf("tracker %q: want github://owner/repo", redact(tracker))
+		}
+		api := q.Get("api")
+		if api == "" {
+			api = "https://api.github.com"
+		}
+		return &GitHub{API: strings.TrimSuffix(api, "/"), Owner: u.Host, Repo: path, Token: token, Labels: labels}, nil
+	case "jira":
+		if u.Host == "" || path == "" || u.User == nil {
+			return nil, fmt.Errorf("tracker %q: want jira://user@host/PROJECT", redact(tracker))
+		}
+		scheme := "https"
+		if q.Get("insecure") == "true" {
+			scheme = "http"
+		}
+		issueType := q.Get("type")
+		if issueType == "" {
+			issueType = "Bug"
+		}
+		return &Jira{
+			URL:       scheme + "://" + u.Host,
+			User:      u.User.Username(),
+			Token:     token,
+			Project:   path,
+			IssueType: issueType,
+			Labels:    labels,
+		}, nil
+	default:
+		return nil, fmt.Errorf("tracker %q: unsupported scheme %q (want github or jira)", redact(tracker), u.Scheme)
+	}
+}
+
+// GitHub opens issues in a GitHub repository.
+type GitHub struct {
+	// API is the REST API root, https://api.github.com for github.com.
+	API   string
+	Owner string
+	Repo  string
+	Token string
+	// Labels are added to every issue, after the issue's own.
+	Labels []string
+	// HTTPClient defaults to a client with a 10 second timeout.
+	HTTPClient *http.Client
+}
+
+// Open implements Tracker.
+func (g *GitHub) Open(ctx context.Context, issue Issue) (string, error) {
+	body := map[string]interface{}{
+		"title":  issue.Title,
+		"body":   issue.Body,
+		"labels": append(append([]string{}, issue.Labels...), g.Labels...),
+	}
+	var out struct {
+		HTMLURL string `json:"html_url"`
+	}
+	endpoint := fmt.Sprintf("%s/repos/%s/%s/issues", g.API, url.PathEscape(g.Owner), url.PathEscape(g.Repo))
+	err := postJSON(ctx, g.HTTPClient, endpoint, body, &out, func(req *http.Request) {
+		req.Header.Set("Accept", "application/vnd.github+json")
+		if g.Token != "" {
+			req.Header.Set("Authorization", "Bearer "+g.Token)
+		}
+	})
+	if err != nil {
+		return "", fmt.Errorf("opening GitHub issue in %s/%s: %w", g.Owner, g.Repo, err)
+	}
+	return out.HTMLURL, nil
+}
+
+// Jira opens issues in a Jira project through the REST API v2.
+type Jira struct {
+	// URL is the Jira base URL, such as https://jira.example.com.
+	URL       string
+	User      string
+	Token     string
+	Project   string
+	IssueType string
+	Labels    []string
+	// HTTPClient defaults to a client with a 10 second timeout.
+	HTTPClient *http.Client
+}
+
+// Open implements Tracker.
+func (j *Jira) Open(ctx context.Context, issue Issue) (string, error) {
+	labels := make([]string, 0, len(issue.Labels)+len(j.Labels))
+	for _, l := range append(append([]string{}, issue.Labels...), j.Labels...) {
+		// Jira labels cannot contain spaces.
+		labels = append(labels, strings.ReplaceAll(l, " ", "-"))
+	}
+	body := map[string]interface{}{
+		"fields": map[string]interface{}{
+			"project":     map[string]string{"key": j.Project},
+			"issuetype":   map[string]string{"name": j.IssueType},
+			"summary":     issue.Title,
+			"description": issue.Body,
+			"labels":      labels,
+		},
+	}
+	var out struct {
+		Key string `json:"key"`
+	}
+	err := postJSON(ctx, j.HTTPClient, j.URL+"/rest/api/2/issue", body, &out, func(req *http.Request) {
+		req.SetBasicAuth(j.User, j.Token)
+	})
+	if err != nil {
+		return "", fmt.Errorf("opening Jira issue in %s: %w", j.Project, err)
+	}
+	return j.URL + "/browse/" + out.Key, nil
+}
+
+// postJSON posts in as JSON to endpoint and decodes the response into out.
+func postJSON(ctx context.Context, httpClient *http.Client, endpoint string, in, out interface{}, authorize func(*http.Request)) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	authorize(req)
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
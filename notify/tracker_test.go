@@ -0,0 +1,107 @@
+package notify_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/example/helm-operator/notify"
+)
+
+var _ = Describe("OpenTracker", func() {
+	It("opens GitHub and Jira trackers", func() {
+		t, err := notify.OpenTracker("github://acme/platform?labels=helm,oncall", "tok")
+		Expect(err).NotTo(HaveOccurred())
+		gh := t.(*notify.GitHub)
+		Expect(gh.API).To(Equal("https://api.github.com"))
+		Expect(gh.Owner + "/" + gh.Repo).To(Equal("acme/platform"))
+		Expect(gh.Labels).To(Equal([]string{"helm", "oncall"}))
+
+		t, err = notify.OpenTracker("jira://ops-bot@jira.example.com/OPS?type=Incident", "tok")
+		Expect(err).NotTo(HaveOccurred())
+		j := t.(*notify.Jira)
+		Expect(j.URL).To(Equal("https://jira.example.com"))
+		Expect(j.User).To(Equal("ops-bot"))
+		Expect(j.Project).To(Equal("OPS"))
+		Expect(j.IssueType).To(Equal("Incident"))
+	})
+
+	It("rejects incomplete trackers", func() {
+		_, err := notify.OpenTracker("github://acme", "")
+		Expect(err).To(MatchError(ContainSubstring("want github://owner/repo")))
+		_, err = notify.OpenTracker("jira://jira.example.com/OPS", "")
+		Expect(err).To(MatchError(ContainSubstring("want jira://user@host/PROJECT")))
+		_, err = notify.OpenTracker("https://example.com", "")
+		Expect(err).To(MatchError(ContainSubstring("unsupported scheme")))
+	})
+})
+
+var _ = Describe("GitHub", func() {
+	It("creates an issue with the token and labels", func() {
+		var auth, path string
+		var body map[string]interface{}
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			auth, path = r.Header.Get("Authorization"), r.URL.Path
+			Expect(json.NewDecoder(r.Body).Decode(&body)).To(Succeed())
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"html_url": "https://github.com/acme/platform/issues/7"}`))
+		}))
+		defer srv.Close()
+
+		g := &notify.GitHub{API: srv.URL, Owner: "acme", Repo: "platform", Token: "tok", Labels: []string{"helm"}}
+		url, err := g.Open(context.Background(), notify.Issue{Title: "web failed", Body: "details", Labels: []string{"Permanent"}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(url).To(Equal("https://github.com/acme/platform/issues/7"))
+		Expect(auth).To(Equal("Bearer tok"))
+		Expect(path).To(Equal("/repos/acme/platform/issues"))
+		Expect(body).To(HaveKeyWithValue("title", "web failed"))
+		Expect(body["labels"]).To(ConsistOf("Permanent", "helm"))
+	})
+
+	It("reports API errors", func() {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "bad credentials", http.StatusUnauthorized)
+		}))
+		defer srv.Close()
+
+		g := &notify.GitHub{API: srv.URL, Owner: "acme", Repo: "platform"}
+		_, err := g.Open(context.Background(), notify.Issue{Title: "web failed"})
+		Expect(err).To(MatchError(ContainSubstring("401")))
+	})
+})
+
+var _ = Describe("Jira", func() {
+	It("creates an issue in the project and links to it", func() {
+		var user, pass string
+		var body struct {
+			Fields struct {
+				Project   map[string]string `json:"project"`
+				IssueType map[string]string `json:"issuetype"`
+				Summary   string            `json:"summary"`
+				Labels    []string          `json:"labels"`
+			} `json:"fields"`
+		}
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, pass, _ = r.BasicAuth()
+			Expect(r.URL.Path).To(Equal("/rest/api/2/issue"))
+			Expect(json.NewDecoder(r.Body).Decode(&body)).To(Succeed())
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"key": "OPS-42"}`))
+		}))
+		defer srv.Close()
+
+		j := &notify.Jira{URL: srv.URL, User: "ops-bot", Token: "tok", Project: "OPS", IssueType: "Bug"}
+		url, err := j.Open(context.Background(), notify.Issue{Title: "web failed", Labels: []string{"helm release"}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(url).To(Equal(srv.URL + "/browse/OPS-42"))
+		Expect(user + ":" + pass).To(Equal("ops-bot:tok"))
+		Expect(body.Fields.Project["key"]).To(Equal("OPS"))
+		Expect(body.Fields.IssueType["name"]).To(Equal("Bug"))
+		Expect(body.Fields.Summary).To(Equal("web failed"))
+		Expect(body.Fields.Labels).To(Equal([]string{"helm-release"}))
+	})
+})
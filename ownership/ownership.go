@@ -0,0 +1,92 @@
+// Package ownership ties auxiliary objects the operator creates on behalf of
+// a HelmRelease (namespaces, exported ConfigMaps, backups, ...) to that
+// release, so they are cleaned up when it is deleted.
+//
+// Objects in the HelmRelease's own namespace get an owner reference and are
+// removed by the Kubernetes garbage collector. Owner references cannot cross
+// namespaces or point from cluster-scoped objects to namespaced ones, so
+// everything else is labelled with the owner and deleted by Cleanup.
+package ownership
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// Tracking labels set on objects that cannot carry an owner reference.
+const (
+	LabelOwnerName      = "helm.example.com/owner-name"
+	LabelOwnerNamespace = "helm.example.com/owner-namespace"
+)
+
+// Own records owner as the owner of obj. It must be called before obj is
+// created or updated. Cluster-scoped objects must have an empty namespace.
+func Own(owner client.Object, obj client.Object, scheme *runtime.Scheme) error {
+	if obj.GetNamespace() != "" && obj.GetNamespace() == owner.GetNamespace() {
+		return controllerutil.SetOwnerReference(owner, obj, scheme)
+	}
+	labels := obj.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[LabelOwnerName] = owner.GetName()
+	labels[LabelOwnerNamespace] = owner.GetNamespace()
+	obj.SetLabels(labels)
+	return nil
+}
+
+// Disown removes the tracking labels Own set on obj, so Cleanup leaves it
+// alone. Owner references are not touched. The caller updates obj.
+func Disown(owner client.Object, obj client.Object) {
+	if !IsOwnedBy(owner, obj) {
+		return
+	}
+	labels := obj.GetLabels()
+	delete(labels, LabelOwnerName)
+	delete(labels, LabelOwnerNamespace)
+	obj.SetLabels(labels)
+}
+
+// IsOwnedBy reports whether obj was tied to owner by Own.
+func IsOwnedBy(owner client.Object, obj client.Object) bool {
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.UID == owner.GetUID() {
+			return true
+		}
+	}
+	labels := obj.GetLabels()
+	return labels[LabelOwnerName] == owner.GetName() && labels[LabelOwnerNamespace] == owner.GetNamespace()
+}
+
+// Cleanup deletes the objects of each list type that carry owner's tracking
+// labels. Objects with owner references are left to the garbage collector.
+func Cleanup(ctx context.Context, c client.Client, owner client.Object, lists ...client.ObjectList) error {
+	selector := client.MatchingLabels{
+		LabelOwnerName:      owner.GetName(),
+		LabelOwnerNamespace: owner.GetNamespace(),
+	}
+	for _, list := range lists {
+		if err := c.List(ctx, list, selector); err != nil {
+			return fmt.Errorf("listing owned %T: %w", list, err)
+		}
+		items, err := meta.ExtractList(list)
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			obj, ok := item.(client.Object)
+			if !ok {
+				continue
+			}
+			if err := c.Delete(ctx, obj, client.PropagationPolicy("Background")); client.IgnoreNotFound(err) != nil {
+				return fmt.Errorf("deleting owned %T %s: %w", obj, client.ObjectKeyFromObject(obj), err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package ownership_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"github.com/example/helm-operator/ownership"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Ownership", func() {
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = helmv1alpha1.AddToScheme(scheme)
+
+	owner := &helmv1alpha1.HelmRelease{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "team-a", UID: "uid-1"}}
+
+	It("uses an owner reference within the owner's namespace", func() {
+		cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "exported", Namespace: "team-a"}}
+		Expect(ownership.Own(owner, cm, scheme)).To(Succeed())
+		Expect(cm.OwnerReferences).To(HaveLen(1))
+		Expect(cm.Labels).NotTo(HaveKey(ownership.LabelOwnerName))
+		Expect(ownership.IsOwnedBy(owner, cm)).To(BeTrue())
+	})
+
+	It("labels and cleans up cluster-scoped and cross-namespace objects", func() {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "app-target"}}
+		cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "exported", Namespace: "app-target"}}
+		other := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "unrelated", Namespace: "app-target"}}
+		Expect(ownership.Own(owner, ns, scheme)).To(Succeed())
+		Expect(ownership.Own(owner, cm, scheme)).To(Succeed())
+		Expect(ns.OwnerReferences).To(BeEmpty())
+		Expect(ownership.IsOwnedBy(owner, ns)).To(BeTrue())
+		Expect(ownership.IsOwnedBy(owner, other)).To(BeFalse())
+
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(ns, cm, other).Build()
+		Expect(ownership.Cleanup(ctx, c, owner, &corev1.NamespaceList{}, &corev1.ConfigMapList{})).To(Succeed())
+
+		Expect(apierrors.IsNotFound(c.Get(ctx, client.ObjectKeyFromObject(ns), &corev1.Namespace{}))).To(BeTrue())
+		Expect(apierrors.IsNotFound(c.Get(ctx, client.ObjectKeyFromObject(cm), &corev1.ConfigMap{}))).To(BeTrue())
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(other), &corev1.ConfigMap{})).To(Succeed())
+	})
+
+	It("stops tracking disowned objects", func() {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "app-kept"}}
+		Expect(ownership.Own(owner, ns, scheme)).To(Succeed())
+		ownership.Disown(owner, ns)
+		Expect(ownership.IsOwnedBy(owner, ns)).To(BeFalse())
+
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(ns).Build()
+		Expect(ownership.Cleanup(ctx, c, owner, &corev1.NamespaceList{})).To(Succeed())
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(ns), &corev1.Namespace{})).To(Succeed())
+	})
+})
@@ -0,0 +1,142 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+const defaultMemoryEntries = 1000
+
+// Memory is a Store kept in process memory. It keeps only the most recent
+// audit entries and diagnoses, and loses everything on restart.
+type Memory struct {
+	max int
+
+	mu        sync.Mutex
+	nextID    int64
+	audit     []AuditEntry
+	diagnoses []Diagnosis
+	views     map[string]View
+}
+
+var _ Store = (*Memory)(nil) // compile-time interface check
+
+// NewMemory returns an empty Memory store retaining up to maxEntries audit
+// entries and diagnoses each. maxEntries <= 0 selects a default of 1000.
+func NewMemory(maxEntries int) *Memory {
+	if maxEntries <= 0 {
+		maxEntries = defaultMemoryEntries
+	}
+	return &Memory{max: maxEntries, views: map[string]View{}}
+}
+
+func (m *Memory) AppendAudit(_ context.Context, entry AuditEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nextID++
+	entry.ID = m.nextID
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	m.audit = append(m.audit, entry)
+	if len(m.audit) > m.max {
+		m.audit = m.audit[len(m.audit)-m.max:]
+	}
+	return nil
+}
+
+func (m *Memory) ListAudit(_ context.Context, opts ListOptions) ([]AuditEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []AuditEntry
+	for i := len(m.audit) - 1; i >= 0 && len(out) < opts.limit(); i-- {
+		if e := m.audit[i]; opts.matches(e.Namespace, e.Name) {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+func (m *Memory) SaveDiagnosis(_ context.Context, d Diagnosis) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nextID++
+	d.ID = m.nextID
+	if d.Time.IsZero() {
+		d.Time = time.Now()
+	}
+	m.diagnoses = append(m.diagnoses, d)
+	if len(m.diagnoses) > m.max {
+		m.diagnoses = m.diagnoses[len(m.diagnoses)-m.max:]
+	}
+	return nil
+}
+
+func (m *Memory) ListDiagnoses(_ context.Context, opts ListOptions) ([]Diagnosis, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []Diagnosis
+	for i := len(m.diagnoses) - 1; i >= 0 && len(out) < opts.limit(); i-- {
+		if d := m.diagnoses[i]; opts.matches(d.Namespace, d.Name) {
+			out = append(out, d)
+		}
+	}
+	return out, nil
+}
+
+func (m *Memory) SaveView(_ context.Context, v View) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if v.UpdatedAt.IsZero() {
+		v.UpdatedAt = time.Now()
+	}
+	m.views[v.Name] = v
+	return nil
+}
+
+func (m *Memory) ListViews(_ context.Context) ([]View, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]View, 0, len(m.views))
+	for _, v := range m.views {
+		out = append(out, v)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+func (m *Memory) DeleteView(_ context.Context, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.views[name]; !ok {
+		return ErrNotFound
+	}
+	delete(m.views, name)
+	return nil
+}
+
+func (m *Memory) Compact(_ context.Context, r Retention, now time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	before := len(m.audit) + len(m.diagnoses)
+	m.audit = retain(m.audit, r, now, func(e AuditEntry) time.Time { return e.Time })
+	m.diagnoses = retain(m.diagnoses, r, now, func(d Diagnosis) time.Time { return d.Time })
+	return before - len(m.audit) - len(m.diagnoses), nil
+}
+
+// retain returns the suffix of entries, oldest first, that r retains.
+func retain[T any](entries []T, r Retention, now time.Time, at func(T) time.Time) []T {
+	if r.MaxEntries > 0 && len(entries) > r.MaxEntries {
+		entries = entries[len(entries)-r.MaxEntries:]
+	}
+	if r.MaxAge > 0 {
+		cutoff := now.Add(-r.MaxAge)
+		i := sort.Search(len(entries), func(i int) bool { return !at(entries[i]).Before(cutoff) })
+		entries = entries[i:]
+	}
+	return entries
+}
+
+func (m *Memory) Close() error { return nil }
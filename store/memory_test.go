@@ -0,0 +1,70 @@
+package store_test
+
+import (
+	"context"
+	"fmt"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/example/helm-operator/store"
+)
+
+var _ = Describe("Memory", func() {
+	ctx := context.Background()
+
+	It("lists audit entries newest first, filtered by object", func() {
+		s := store.NewMemory(0)
+		Expect(s.AppendAudit(ctx, store.AuditEntry{Action: "created", Namespace: "a", Name: "web"})).To(Succeed())
+		Expect(s.AppendAudit(ctx, store.AuditEntry{Action: "created", Namespace: "b", Name: "web"})).To(Succeed())
+		Expect(s.AppendAudit(ctx, store.AuditEntry{Action: "updated", Namespace: "a", Name: "web"})).To(Succeed())
+
+		entries, err := s.ListAudit(ctx, store.ListOptions{Namespace: "a", Name: "web"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(HaveLen(2))
+		Expect(entries[0].Action).To(Equal("updated"))
+		Expect(entries[1].Action).To(Equal("created"))
+		Expect(entries[0].Time).NotTo(BeZero())
+	})
+
+	It("keeps only the most recent diagnoses", func() {
+		s := store.NewMemory(3)
+		for i := 0; i < 5; i++ {
+			Expect(s.SaveDiagnosis(ctx, store.Diagnosis{Namespace: "a", Name: "web", Text: fmt.Sprint(i)})).To(Succeed())
+		}
+		diagnoses, err := s.ListDiagnoses(ctx, store.ListOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(diagnoses).To(HaveLen(3))
+		Expect(diagnoses[0].Text).To(Equal("4"))
+		Expect(diagnoses[2].Text).To(Equal("2"))
+	})
+
+	It("replaces and deletes views by name", func() {
+		s := store.NewMemory(0)
+		Expect(s.SaveView(ctx, store.View{Name: "failing", Query: `{"phase":"Failed"}`})).To(Succeed())
+		Expect(s.SaveView(ctx, store.View{Name: "failing", Query: `{"phase":"Failed","ns":"a"}`})).To(Succeed())
+
+		views, err := s.ListViews(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(views).To(HaveLen(1))
+		Expect(views[0].Query).To(ContainSubstring(`"ns":"a"`))
+
+		Expect(s.DeleteView(ctx, "failing")).To(Succeed())
+		Expect(s.DeleteView(ctx, "failing")).To(MatchError(store.ErrNotFound))
+	})
+})
+
+var _ = Describe("Open", func() {
+	ctx := context.Background()
+
+	It("defaults to the memory store", func() {
+		s, err := store.Open(ctx, "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(s).To(BeAssignableToTypeOf(&store.Memory{}))
+	})
+
+	It("rejects unknown schemes", func() {
+		_, err := store.Open(ctx, "mysql://localhost/ui")
+		Expect(err).To(MatchError(ContainSubstring("unsupported scheme")))
+	})
+})
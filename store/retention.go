@@ -0,0 +1,60 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// Retention bounds how much audit log and diagnosis history a Store keeps.
+// Views are never compacted.
+type Retention struct {
+	// MaxAge removes entries older than this. 0 keeps entries of any age.
+	MaxAge time.Duration
+	// MaxEntries keeps at most this many of the newest audit entries, and as
+	// many diagnoses. 0 keeps any number.
+	MaxEntries int
+}
+
+func (r Retention) enabled() bool {
+	return r.MaxAge > 0 || r.MaxEntries > 0
+}
+
+// Compactor is a controller-runtime Runnable that periodically applies a
+// Retention to a Store, so long-running operators do not accumulate
+// unbounded history.
+type Compactor struct {
+	Store     Store
+	Retention Retention
+	Interval  time.Duration
+}
+
+// Start implements manager.Runnable.
+func (c *Compactor) Start(ctx context.Context) error {
+	if !c.Retention.enabled() {
+		return nil
+	}
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+	for {
+		c.compact(ctx)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *Compactor) compact(ctx context.Context) {
+	log := ctrl.Log.WithName("store-compactor")
+	removed, err := c.Store.Compact(ctx, c.Retention, time.Now())
+	if err != nil {
+		log.Error(err, "Compacting the web UI store")
+		return
+	}
+	if removed > 0 {
+		log.Info("Compacted the web UI store", "removed", removed)
+	}
+}
@@ -0,0 +1,63 @@
+package store_test
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/example/helm-operator/store"
+)
+
+var _ = Describe("Compact", func() {
+	ctx := context.Background()
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	fill := func(s store.Store) {
+		for i := 0; i < 5; i++ {
+			at := now.Add(-time.Duration(5-i) * 24 * time.Hour)
+			Expect(s.AppendAudit(ctx, store.AuditEntry{Time: at, Action: fmt.Sprint(i), Namespace: "a", Name: "web"})).To(Succeed())
+			Expect(s.SaveDiagnosis(ctx, store.Diagnosis{Time: at, Namespace: "a", Name: "web", Text: fmt.Sprint(i)})).To(Succeed())
+		}
+	}
+
+	It("removes entries older than MaxAge", func() {
+		s := store.NewMemory(0)
+		fill(s)
+		removed, err := s.Compact(ctx, store.Retention{MaxAge: 60 * time.Hour}, now)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(removed).To(Equal(6))
+
+		entries, err := s.ListAudit(ctx, store.ListOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(HaveLen(2))
+		Expect(entries[1].Action).To(Equal("3"))
+	})
+
+	It("keeps only the newest MaxEntries", func() {
+		s := store.NewMemory(0)
+		fill(s)
+		Expect(s.SaveView(ctx, store.View{Name: "all"})).To(Succeed())
+		removed, err := s.Compact(ctx, store.Retention{MaxEntries: 4}, now)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(removed).To(Equal(2))
+
+		diagnoses, err := s.ListDiagnoses(ctx, store.ListOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(diagnoses).To(HaveLen(4))
+		Expect(diagnoses[3].Text).To(Equal("1"))
+		views, err := s.ListViews(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(views).To(HaveLen(1))
+	})
+
+	It("removes nothing without limits", func() {
+		s := store.NewMemory(0)
+		fill(s)
+		removed, err := s.Compact(ctx, store.Retention{}, now)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(removed).To(BeZero())
+	})
+})
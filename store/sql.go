@@ -0,0 +1,270 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/lib/pq" // registers the "postgres" driver
+)
+
+// dialect captures the differences between the databases the SQL store supports.
+type dialect struct {
+	driver string
+	// serial is the column type of an auto-incrementing primary key.
+	serial string
+	// numbered reports whether placeholders are $1, $2, ... rather than ?.
+	numbered bool
+}
+
+var (
+	sqliteDialect   = dialect{driver: "sqlite", serial: "INTEGER PRIMARY KEY AUTOINCREMENT"}
+	postgresDialect = dialect{driver: "postgres", serial: "BIGSERIAL PRIMARY KEY", numbered: true}
+)
+
+// rebind rewrites the ? placeholders in query for the dialect.
+func (d dialect) rebind(query string) string {
+	if !d.numbered {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// SQL is a Store backed by a SQLite or Postgres database. Timestamps are
+// stored as Unix milliseconds so both databases share one schema.
+type SQL struct {
+	db      *sql.DB
+	dialect dialect
+}
+
+var _ Store = (*SQL)(nil) // compile-time interface check
+
+func openSQL(ctx context.Context, d dialect, dsn string) (*SQL, error) {
+	db, err := sql.Open(d.driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("opening %s store: %w", d.driver, err)
+	}
+	if d.driver == sqliteDialect.driver {
+		// SQLite allows a single writer; serialize rather than fail with SQLITE_BUSY.
+		db.SetMaxOpenConns(1)
+	}
+	s := &SQL{db: db, dialect: d}
+	if err := s.migrate(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *SQL) migrate(ctx context.Context) error {
+	stmts := []string{
+		`CREATE TABLE IF NOT EXISTS audit_log (
+			id ` + s.dialect.serial + `,
+			at BIGINT NOT NULL,
+			username TEXT NOT NULL,
+			action TEXT NOT NULL,
+			namespace TEXT NOT NULL,
+			name TEXT NOT NULL,
+			detail TEXT NOT NULL)`,
+		`CREATE INDEX IF NOT EXISTS audit_log_object ON audit_log (namespace, name)`,
+		`CREATE TABLE IF NOT EXISTS diagnoses (
+			id ` + s.dialect.serial + `,
+			at BIGINT NOT NULL,
+			namespace TEXT NOT NULL,
+			name TEXT NOT NULL,
+			phase TEXT NOT NULL,
+			text TEXT NOT NULL)`,
+		`CREATE INDEX IF NOT EXISTS diagnoses_object ON diagnoses (namespace, name)`,
+		`CREATE TABLE IF NOT EXISTS views (
+			name TEXT PRIMARY KEY,
+			query TEXT NOT NULL,
+			updated_at BIGINT NOT NULL)`,
+	}
+	for _, stmt := range stmts {
+		if _, err := s.db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("migrating %s store: %w", s.dialect.driver, err)
+		}
+	}
+	return nil
+}
+
+func (s *SQL) exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return s.db.ExecContext(ctx, s.dialect.rebind(query), args...)
+}
+
+func (s *SQL) query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return s.db.QueryContext(ctx, s.dialect.rebind(query), args...)
+}
+
+// filter appends the WHERE clause, ordering and limit for opts to query.
+func filter(query string, opts ListOptions) (string, []interface{}) {
+	var (
+		where []string
+		args  []interface{}
+	)
+	if opts.Namespace != "" {
+		where = append(where, "namespace = ?")
+		args = append(args, opts.Namespace)
+	}
+	if opts.Name != "" {
+		where = append(where, "name = ?")
+		args = append(args, opts.Name)
+	}
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	return query + " ORDER BY id DESC LIMIT ?", append(args, opts.limit())
+}
+
+func (s *SQL) AppendAudit(ctx context.Context, e AuditEntry) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	_, err := s.exec(ctx, `INSERT INTO audit_log (at, username, action, namespace, name, detail) VALUES (?, ?, ?, ?, ?, ?)`,
+		e.Time.UnixMilli(), e.User, e.Action, e.Namespace, e.Name, e.Detail)
+	return err
+}
+
+func (s *SQL) ListAudit(ctx context.Context, opts ListOptions) ([]AuditEntry, error) {
+	query, args := filter(`SELECT id, at, username, action, namespace, name, detail FROM audit_log`, opts)
+	rows, err := s.query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []AuditEntry
+	for rows.Next() {
+		var (
+			e  AuditEntry
+			at int64
+		)
+		if err := rows.Scan(&e.ID, &at, &e.User, &e.Action, &e.Namespace, &e.Name, &e.Detail); err != nil {
+			return nil, err
+		}
+		e.Time = time.UnixMilli(at)
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+func (s *SQL) SaveDiagnosis(ctx context.Context, d Diagnosis) error {
+	if d.Time.IsZero() {
+		d.Time = time.Now()
+	}
+	_, err := s.exec(ctx, `INSERT INTO diagnoses (at, namespace, name, phase, text) VALUES (?, ?, ?, ?, ?)`,
+		d.Time.UnixMilli(), d.Namespace, d.Name, d.Phase, d.Text)
+	return err
+}
+
+func (s *SQL) ListDiagnoses(ctx context.Context, opts ListOptions) ([]Diagnosis, error) {
+	query, args := filter(`SELECT id, at, namespace, name, phase, text FROM diagnoses`, opts)
+	rows, err := s.query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Diagnosis
+	for rows.Next() {
+		var (
+			d  Diagnosis
+			at int64
+		)
+		if err := rows.Scan(&d.ID, &at, &d.Namespace, &d.Name, &d.Phase, &d.Text); err != nil {
+			return nil, err
+		}
+		d.Time = time.UnixMilli(at)
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+func (s *SQL) SaveView(ctx context.Context, v View) error {
+	if v.UpdatedAt.IsZero() {
+		v.UpdatedAt = time.Now()
+	}
+	_, err := s.exec(ctx, `INSERT INTO views (name, query, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT (name) DO UPDATE SET query = excluded.query, updated_at = excluded.updated_at`,
+		v.Name, v.Query, v.UpdatedAt.UnixMilli())
+	return err
+}
+
+func (s *SQL) ListViews(ctx context.Context) ([]View, error) {
+	rows, err := s.query(ctx, `SELECT name, query, updated_at FROM views ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []View{}
+	for rows.Next() {
+		var (
+			v  View
+			at int64
+		)
+		if err := rows.Scan(&v.Name, &v.Query, &at); err != nil {
+			return nil, err
+		}
+		v.UpdatedAt = time.UnixMilli(at)
+		out = append(out, v)
+	}
+	return out, rows.Err()
+}
+
+func (s *SQL) DeleteView(ctx context.Context, name string) error {
+	res, err := s.exec(ctx, `DELETE FROM views WHERE name = ?`, name)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *SQL) Compact(ctx context.Context, r Retention, now time.Time) (int, error) {
+	var removed int64
+	for _, table := range []string{"audit_log", "diagnoses"} {
+		if r.MaxAge > 0 {
+			n, err := s.delete(ctx, `DELETE FROM `+table+` WHERE at < ?`, now.Add(-r.MaxAge).UnixMilli())
+			removed += n
+			if err != nil {
+				return int(removed), fmt.Errorf("compacting %s: %w", table, err)
+			}
+		}
+		if r.MaxEntries > 0 {
+			// The subquery yields NULL, deleting nothing, while the table
+			// holds no more than MaxEntries rows.
+			n, err := s.delete(ctx, `DELETE FROM `+table+` WHERE id <= (
+				SELECT id FROM `+table+` ORDER BY id DESC LIMIT 1 OFFSET ?)`, r.MaxEntries)
+			removed += n
+			if err != nil {
+				return int(removed), fmt.Errorf("compacting %s: %w", table, err)
+			}
+		}
+	}
+	return int(removed), nil
+}
+
+// delete runs a DELETE statement and returns the number of rows removed.
+func (s *SQL) delete(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	res, err := s.exec(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+	return n, nil
+}
+
+func (s *SQL) Close() error { return s.db.Close() }
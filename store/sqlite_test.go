@@ -0,0 +1,73 @@
+package store_test
+
+import (
+	"context"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/example/helm-operator/store"
+)
+
+var _ = Describe("SQLite", func() {
+	ctx := context.Background()
+
+	var dsn string
+	open := func() store.Store {
+		s, err := store.Open(ctx, dsn)
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(s.Close)
+		return s
+	}
+	BeforeEach(func() {
+		dsn = "sqlite://" + filepath.Join(GinkgoT().TempDir(), "ui.db")
+	})
+
+	It("keeps audit entries, diagnoses and views across restarts", func() {
+		s := open()
+		Expect(s.AppendAudit(ctx, store.AuditEntry{Action: "created", Namespace: "a", Name: "web", User: "alice"})).To(Succeed())
+		Expect(s.AppendAudit(ctx, store.AuditEntry{Action: "created", Namespace: "b", Name: "web"})).To(Succeed())
+		Expect(s.AppendAudit(ctx, store.AuditEntry{Action: "updated", Namespace: "a", Name: "web"})).To(Succeed())
+		Expect(s.SaveDiagnosis(ctx, store.Diagnosis{Namespace: "a", Name: "web", Phase: "Failed", Text: "image pull"})).To(Succeed())
+		Expect(s.SaveView(ctx, store.View{Name: "failing", Query: `{"phase":"Failed"}`})).To(Succeed())
+		Expect(s.SaveView(ctx, store.View{Name: "failing", Query: `{"phase":"Failed","ns":"a"}`})).To(Succeed())
+		Expect(s.Close()).To(Succeed())
+
+		s = open()
+		entries, err := s.ListAudit(ctx, store.ListOptions{Namespace: "a", Name: "web"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(HaveLen(2))
+		Expect(entries[0].Action).To(Equal("updated"))
+		Expect(entries[1].User).To(Equal("alice"))
+		diagnoses, err := s.ListDiagnoses(ctx, store.ListOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(diagnoses).To(HaveLen(1))
+		Expect(diagnoses[0].Text).To(Equal("image pull"))
+		views, err := s.ListViews(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(views).To(HaveLen(1))
+		Expect(views[0].Query).To(ContainSubstring(`"ns":"a"`))
+
+		Expect(s.DeleteView(ctx, "failing")).To(Succeed())
+		Expect(s.DeleteView(ctx, "failing")).To(MatchError(store.ErrNotFound))
+	})
+
+	It("compacts by age and count", func() {
+		s := open()
+		now := time.Now()
+		for i := 0; i < 5; i++ {
+			Expect(s.AppendAudit(ctx, store.AuditEntry{Action: "updated", Time: now.Add(time.Duration(i-5) * time.Hour)})).To(Succeed())
+		}
+		removed, err := s.Compact(ctx, store.Retention{MaxAge: 150 * time.Minute}, now)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(removed).To(Equal(3))
+		removed, err = s.Compact(ctx, store.Retention{MaxEntries: 1}, now)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(removed).To(Equal(1))
+		removed, err = s.Compact(ctx, store.Retention{MaxEntries: 1}, now)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(removed).To(BeZero())
+	})
+})
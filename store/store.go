@@ -0,0 +1,110 @@
+// Package store persists the web UI's own data: the audit log of changes made
+// through the UI, the history of AI diagnoses, and saved views. This data has
+// no business in etcd, so it lives in a Store chosen with a DSN:
+//
+//	memory                       in-process, lost on restart (default)
+//	sqlite:///data/ui.db         a SQLite file, typically on a PVC
+//	postgres://user:pw@host/db   a shared Postgres database
+//
+// SQLite is provided by modernc.org/sqlite, which is pure Go, so the operator
+// still builds with CGO_ENABLED=0.
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned when a named object does not exist.
+var ErrNotFound = errors.New("not found")
+
+// AuditEntry records one change made through the web UI.
+type AuditEntry struct {
+	ID        int64     `json:"id"`
+	Time      time.Time `json:"time"`
+	User      string    `json:"user,omitempty"`
+	Action    string    `json:"action"`
+	Namespace string    `json:"namespace"`
+	Name      string    `json:"name"`
+	Detail    string    `json:"detail,omitempty"`
+}
+
+// Diagnosis is a completed AI diagnosis of a HelmRelease.
+type Diagnosis struct {
+	ID        int64     `json:"id"`
+	Time      time.Time `json:"time"`
+	Namespace string    `json:"namespace"`
+	Name      string    `json:"name"`
+	Phase     string    `json:"phase,omitempty"`
+	Text      string    `json:"text"`
+}
+
+// View is a named UI filter. Query is opaque to the store.
+type View struct {
+	Name      string    `json:"name"`
+	Query     string    `json:"query"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// ListOptions filters audit and diagnosis listings. Empty fields match
+// everything; results are newest first.
+type ListOptions struct {
+	Namespace string
+	Name      string
+	// Limit caps the number of results. Defaults to 100.
+	Limit int
+}
+
+func (o ListOptions) limit() int {
+	if o.Limit <= 0 {
+		return 100
+	}
+	return o.Limit
+}
+
+func (o ListOptions) matches(namespace, name string) bool {
+	return (o.Namespace == "" || o.Namespace == namespace) && (o.Name == "" || o.Name == name)
+}
+
+// Store is the storage backend for web UI data. Implementations must be safe
+// for concurrent use.
+type Store interface {
+	AppendAudit(ctx context.Context, entry AuditEntry) error
+	ListAudit(ctx context.Context, opts ListOptions) ([]AuditEntry, error)
+
+	SaveDiagnosis(ctx context.Context, d Diagnosis) error
+	ListDiagnoses(ctx context.Context, opts ListOptions) ([]Diagnosis, error)
+
+	// SaveView creates or replaces the view with the same name.
+	SaveView(ctx context.Context, v View) error
+	ListViews(ctx context.Context) ([]View, error)
+	DeleteView(ctx context.Context, name string) error
+
+	// Compact removes the audit entries and diagnoses r does not retain as
+	// of now, returning how many it removed.
+	Compact(ctx context.Context, r Retention, now time.Time) (int, error)
+
+	Close() error
+}
+
+// Open returns the Store described by dsn. See the package documentation for
+// the accepted forms.
+func Open(ctx context.Context, dsn string) (Store, error) {
+	scheme, rest, _ := strings.Cut(dsn, "://")
+	switch scheme {
+	case "", "memory":
+		return NewMemory(0), nil
+	case "sqlite":
+		if rest == "" {
+			return nil, fmt.Errorf("store %q: missing database path", dsn)
+		}
+		return openSQL(ctx, sqliteDialect, rest)
+	case "postgres", "postgresql":
+		return openSQL(ctx, postgresDialect, dsn)
+	default:
+		return nil, fmt.Errorf("store %q: unsupported scheme %q (want memory, sqlite or postgres)", dsn, scheme)
+	}
+}
@@ -0,0 +1,36 @@
+package values
+
+import "strings"
+
+// Provenance maps the dotted path of every leaf of merged values, such as
+// "image.tag", to the source that set it. Lists are leaves: a layer that
+// sets one replaces it whole.
+type Provenance map[string]string
+
+// Record notes that source set every leaf of overlay, as it is merged with
+// Merge into the values p describes. Whatever overlay replaces, such as
+// everything under a map it sets to a scalar or null, is attributed to
+// source from then on.
+func (p Provenance) Record(overlay map[string]interface{}, source string) {
+	p.record("", overlay, source)
+}
+
+func (p Provenance) record(prefix string, overlay map[string]interface{}, source string) {
+	for k, v := range overlay {
+		path := k
+		if prefix != "" {
+			path = prefix + "." + k
+		}
+		if m, ok := v.(map[string]interface{}); ok {
+			delete(p, path) // a scalar replaced by a map
+			p.record(path, m, source)
+			continue
+		}
+		for existing := range p {
+			if strings.HasPrefix(existing, path+".") {
+				delete(p, existing)
+			}
+		}
+		p[path] = source
+	}
+}
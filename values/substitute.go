@@ -0,0 +1,59 @@
+package values
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// placeholder matches $${NAME} (an escaped placeholder), ${NAME} and
+// ${NAME:=default}.
+var placeholder = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)(:=([^}]*))?\}`)
+
+// Substitute replaces the ${NAME} placeholders of template with vars[NAME].
+// ${NAME:=default} falls back to default when NAME is not set, and $${NAME}
+// is kept as the literal ${NAME}. Placeholders without a variable or a
+// default are an error naming all of them.
+func Substitute(template string, vars map[string]string) (string, error) {
+	var missing []string
+	seen := map[string]bool{}
+	out := placeholder.ReplaceAllStringFunc(template, func(m string) string {
+		if strings.HasPrefix(m, "$$") {
+			return m[1:]
+		}
+		sub := placeholder.FindStringSubmatch(m)
+		name, hasDefault, def := sub[1], sub[2] != "", sub[3]
+		if v, ok := vars[name]; ok {
+			return v
+		}
+		if hasDefault {
+			return def
+		}
+		if !seen[name] {
+			seen[name] = true
+			missing = append(missing, name)
+		}
+		return m
+	})
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return "", fmt.Errorf("undefined variables: %s", strings.Join(missing, ", "))
+	}
+	return out, nil
+}
+
+// Variables returns the names of the variables template refers to, in
+// order of first use. Escaped placeholders are not included.
+func Variables(template string) []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, sub := range placeholder.FindAllStringSubmatch(template, -1) {
+		if strings.HasPrefix(sub[0], "$$") || seen[sub[1]] {
+			continue
+		}
+		seen[sub[1]] = true
+		names = append(names, sub[1])
+	}
+	return names
+}
@@ -0,0 +1,92 @@
+// Package values implements the Helm values handling shared by the
+// controller, the admission webhooks, and the web API.
+package values
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+)
+
+// Merge deep-merges overlay into base and returns base. Nested maps are merged
+// key by key; any other value in overlay replaces the one in base.
+//
+// An explicit null in overlay unsets the key: whatever base holds for it,
+// including a whole nested map, is dropped. The null itself is kept in the
+// result, so that when the values reach Helm it also unsets the chart's own
+// default; Helm removes null keys while coalescing, so templates never see
+// them. Maps taken over from overlay are copied, so merging further layers
+// into the result never modifies overlay.
+func Merge(base, overlay map[string]interface{}) map[string]interface{} {
+	if base == nil {
+		base = map[string]interface{}{}
+	}
+	for k, v := range overlay {
+		if overlayMap, ok := v.(map[string]interface{}); ok {
+			if baseMap, ok := base[k].(map[string]interface{}); ok {
+				base[k] = Merge(baseMap, overlayMap)
+			} else {
+				base[k] = Merge(nil, overlayMap)
+			}
+			continue
+		}
+		base[k] = v
+	}
+	return base
+}
+
+// Parse decodes Helm values from a JSON object. Numbers are kept exact:
+// integers become int64 (uint64 above its range) and only numbers with a
+// fraction or exponent become float64. encoding/json alone would turn every
+// number into float64, which templates render as e.g. 1e+06 and which loses
+// precision beyond 2^53. Explicit nulls are kept, so that Helm removes the
+// key from the chart's defaults. Empty input and "null" yield an empty map.
+func Parse(raw []byte) (map[string]interface{}, error) {
+	out := map[string]interface{}{}
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return out, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var decoded map[string]interface{}
+	if err := dec.Decode(&decoded); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("unexpected data after values object")
+	}
+	for k, v := range decoded {
+		out[k] = convertNumbers(v)
+	}
+	return out, nil
+}
+
+// convertNumbers replaces the json.Numbers in v with int64, uint64 or
+// float64 values.
+func convertNumbers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = convertNumbers(e)
+		}
+		return v
+	case []interface{}:
+		for i, e := range v {
+			v[i] = convertNumbers(e)
+		}
+		return v
+	case json.Number:
+		if i, err := strconv.ParseInt(v.String(), 10, 64); err == nil {
+			return i
+		}
+		if u, err := strconv.ParseUint(v.String(), 10, 64); err == nil {
+			return u
+		}
+		f, _ := v.Float64()
+		return f
+	default:
+		return v
+	}
+}
@@ -0,0 +1,170 @@
+package values_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/example/helm-operator/values"
+)
+
+func TestParsePreservesTypes(t *testing.T) {
+	raw := `{"big":9007199254740993,"huge":18446744073709551615,"limits":{"memory":null},"ports":[8080,8443],"ratio":0.5,"replicas":3,"scale":1e3,"tag":"1.0"}`
+	got, err := values.Parse([]byte(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"big":      int64(9007199254740993),
+		"huge":     uint64(18446744073709551615),
+		"limits":   map[string]interface{}{"memory": nil},
+		"ports":    []interface{}{int64(8080), int64(8443)},
+		"ratio":    0.5,
+		"replicas": int64(3),
+		"scale":    float64(1000),
+		"tag":      "1.0",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Parse() = %#v, want %#v", got, want)
+	}
+
+	// Re-encoding, as the webhooks and the web API do, keeps every value;
+	// only the exponent notation of 1e3 is normalized.
+	out, err := json.Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"big":9007199254740993,"huge":18446744073709551615,"limits":{"memory":null},"ports":[8080,8443],"ratio":0.5,"replicas":3,"scale":1000,"tag":"1.0"}`; string(out) != want {
+		t.Fatalf("round trip = %s, want %s", out, want)
+	}
+}
+
+func TestParseEmptyAndInvalid(t *testing.T) {
+	for _, raw := range []string{"", " ", "null"} {
+		got, err := values.Parse([]byte(raw))
+		if err != nil || got == nil || len(got) != 0 {
+			t.Errorf("Parse(%q) = %v, %v; want empty map", raw, got, err)
+		}
+	}
+	for _, raw := range []string{"[1]", `{"a":1} {"b":2}`, `{"a":`} {
+		if _, err := values.Parse([]byte(raw)); err == nil {
+			t.Errorf("Parse(%q) succeeded, want error", raw)
+		}
+	}
+}
+
+func BenchmarkMerge(b *testing.B) {
+	base := map[string]interface{}{}
+	overlay := map[string]interface{}{}
+	for i := 0; i < 200; i++ {
+		key := fmt.Sprintf("component%d", i)
+		base[key] = map[string]interface{}{
+			"image":     map[string]interface{}{"repository": "registry.example.com/app", "tag": "1.0.0"},
+			"resources": map[string]interface{}{"limits": map[string]interface{}{"cpu": "500m"}},
+		}
+		if i%2 == 0 {
+			overlay[key] = map[string]interface{}{"image": map[string]interface{}{"tag": "2.0.0"}}
+		}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		values.Merge(base, overlay)
+	}
+}
+
+func TestMergeNullUnsetsLowerLayers(t *testing.T) {
+	defaults := map[string]interface{}{
+		"resources": map[string]interface{}{
+			"limits":   map[string]interface{}{"cpu": "1", "memory": "256Mi"},
+			"requests": map[string]interface{}{"cpu": "100m"},
+		},
+		"nodeSelector": map[string]interface{}{"pool": "general"},
+		"replicas":     int64(2),
+	}
+	overlay := map[string]interface{}{
+		"resources":    map[string]interface{}{"limits": nil},
+		"nodeSelector": nil,
+		"tolerations":  map[string]interface{}{"gpu": nil},
+	}
+	got := values.Merge(defaults, overlay)
+	want := map[string]interface{}{
+		"resources": map[string]interface{}{
+			"limits":   nil,
+			"requests": map[string]interface{}{"cpu": "100m"},
+		},
+		"nodeSelector": nil,
+		"replicas":     int64(2),
+		"tolerations":  map[string]interface{}{"gpu": nil},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Merge() = %#v, want %#v", got, want)
+	}
+
+	// A higher layer can set the key again.
+	got = values.Merge(got, map[string]interface{}{"nodeSelector": map[string]interface{}{"pool": "batch"}})
+	if !reflect.DeepEqual(got["nodeSelector"], map[string]interface{}{"pool": "batch"}) {
+		t.Fatalf("nodeSelector = %#v after re-setting it", got["nodeSelector"])
+	}
+
+	// Merging into the result must not reach back into overlay.
+	values.Merge(got, map[string]interface{}{"tolerations": map[string]interface{}{"spot": "true"}})
+	if !reflect.DeepEqual(overlay["tolerations"], map[string]interface{}{"gpu": nil}) {
+		t.Fatalf("overlay modified: %#v", overlay["tolerations"])
+	}
+}
+
+func TestProvenanceRecord(t *testing.T) {
+	base := map[string]interface{}{
+		"image":     map[string]interface{}{"repository": "nginx", "tag": "1.25"},
+		"resources": map[string]interface{}{"limits": map[string]interface{}{"memory": "1Gi"}},
+		"replicas":  int64(1),
+	}
+	overlay := map[string]interface{}{
+		"image":     map[string]interface{}{"tag": "1.26"},
+		"resources": nil,
+		"replicas":  map[string]interface{}{"min": int64(2)},
+	}
+	p := values.Provenance{}
+	p.Record(base, "ConfigMap/defaults")
+	p.Record(overlay, "spec.values")
+	want := values.Provenance{
+		"image.repository": "ConfigMap/defaults",
+		"image.tag":        "spec.values",
+		"resources":        "spec.values",
+		"replicas.min":     "spec.values",
+	}
+	if !reflect.DeepEqual(p, want) {
+		t.Fatalf("Provenance = %v, want %v", p, want)
+	}
+}
+
+func TestSubstitute(t *testing.T) {
+	vars := map[string]string{"ENV": "prod", "REPLICAS": "3"}
+	for _, tc := range []struct {
+		template, want string
+	}{
+		{"env: ${ENV}\nreplicas: ${REPLICAS}", "env: prod\nreplicas: 3"},
+		{"region: ${REGION:=eu-west-1}", "region: eu-west-1"},
+		{"env: ${ENV:=dev}", "env: prod"},
+		{"literal: $${ENV}", "literal: ${ENV}"},
+		{"host: ${ENV}.${ENV}.example.com", "host: prod.prod.example.com"},
+		{"no placeholders: $ENV {ENV}", "no placeholders: $ENV {ENV}"},
+	} {
+		got, err := values.Substitute(tc.template, vars)
+		if err != nil || got != tc.want {
+			t.Errorf("Substitute(%q) = %q, %v; want %q", tc.template, got, err, tc.want)
+		}
+	}
+
+	_, err := values.Substitute("a: ${ZONE}\nb: ${CLUSTER}\nc: ${ZONE}", vars)
+	if err == nil || err.Error() != "undefined variables: CLUSTER, ZONE" {
+		t.Errorf("Substitute() error = %v, want undefined variables: CLUSTER, ZONE", err)
+	}
+
+	if got, want := values.Variables("a: ${ZONE}\nb: $${ESCAPED}\nc: ${ENV:=dev}-${ZONE}"), []string{"ZONE", "ENV"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Variables() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,73 @@
+package wasm
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// New returns a wazero-backed Runtime enforcing limits. Compiled modules are
+// cached in memory, so repeated runs of the same module are cheap.
+func New(limits Limits) Runtime {
+	return &wazeroRuntime{
+		limits: limits.withDefaults(),
+		cache:  wazero.NewCompilationCache(),
+	}
+}
+
+type wazeroRuntime struct {
+	limits Limits
+	cache  wazero.CompilationCache
+}
+
+func (w *wazeroRuntime) Run(ctx context.Context, module, stdin []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, w.limits.Timeout)
+	defer cancel()
+
+	// A fresh runtime per invocation keeps modules fully isolated from each
+	// other; the shared compilation cache avoids recompiling them.
+	rt := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().
+		WithMemoryLimitPages(w.limits.MemoryPages).
+		WithCloseOnContextDone(true).
+		WithCompilationCache(w.cache))
+	defer rt.Close(context.Background())
+
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, rt); err != nil {
+		return nil, fmt.Errorf("instantiating WASI: %w", err)
+	}
+	compiled, err := rt.CompileModule(ctx, module)
+	if err != nil {
+		return nil, fmt.Errorf("compiling wasm module: %w", err)
+	}
+
+	stdout := &limitedBuffer{max: w.limits.MaxOutputBytes}
+	var stderr bytes.Buffer
+	cfg := wazero.NewModuleConfig().
+		WithStdin(bytes.NewReader(stdin)).
+		WithStdout(stdout).
+		WithStderr(&stderr)
+
+	_, err = rt.InstantiateModule(ctx, compiled, cfg)
+	if stdout.err != nil {
+		return nil, stdout.err
+	}
+	var exitErr *sys.ExitError
+	if errors.As(err, &exitErr) {
+		if exitErr.ExitCode() == 0 {
+			return stdout.buf, nil
+		}
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("wasm module exceeded %s time limit", w.limits.Timeout)
+		}
+		return nil, &ExitError{Code: exitErr.ExitCode(), Stdout: string(stdout.buf), Stderr: stderr.String()}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("running wasm module: %w", err)
+	}
+	return stdout.buf, nil
+}
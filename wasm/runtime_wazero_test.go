@@ -0,0 +1,76 @@
+package wasm_test
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/example/helm-operator/wasm"
+)
+
+// Hand-assembled WASI command modules, each exporting memory and _start.
+var (
+	// echoModule copies up to 4 KiB of stdin to stdout:
+	//
+	//	(i32.store (i32.const 0) (i32.const 16))   ;; iovec buf
+	//	(i32.store (i32.const 4) (i32.const 4096)) ;; iovec len
+	//	(drop (call $fd_read (i32.const 0) (i32.const 0) (i32.const 1) (i32.const 8)))
+	//	(i32.store (i32.const 4) (i32.load (i32.const 8)))
+	//	(drop (call $fd_write (i32.const 1) (i32.const 0) (i32.const 1) (i32.const 8)))
+	echoModule = mustDecode("0061736d01000000010c0260047f7f7f7f017f60000002440216776173695f736e617073686f745f70726576696577310766645f72656164000016776173695f736e617073686f745f70726576696577310866645f77726974650000030201010503010001071302066d656d6f72790200065f737461727400020a33013100410041103602004104418020360200410041004101410810001a41044108280200360200410141004101410810011a0b")
+	// exitModule calls (proc_exit 3).
+	exitModule = mustDecode("0061736d0100000001080260017f0060000002240116776173695f736e617073686f745f70726576696577310970726f635f657869740000030201010503010001071302066d656d6f72790200065f737461727400010a08010600410310000b")
+	// loopModule never returns: (loop (br 0)).
+	loopModule = mustDecode("0061736d01000000010401600000030201000503010001071302066d656d6f72790200065f737461727400000a0901070003400c000b0b")
+	// bigModule declares 512 pages (32 MiB) of memory and does nothing.
+	bigModule = mustDecode("0061736d0100000001040160000003020100050401008004071302066d656d6f72790200065f737461727400000a040102000b")
+)
+
+func mustDecode(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func TestRunPassesStdinToStdout(t *testing.T) {
+	out, err := wasm.New(wasm.Limits{}).Run(context.Background(), echoModule, []byte("kind: ConfigMap\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "kind: ConfigMap\n" {
+		t.Fatalf("Run() = %q, want the input back", out)
+	}
+}
+
+func TestRunLimits(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		module []byte
+		limits wasm.Limits
+		want   string
+	}{
+		{"time", loopModule, wasm.Limits{Timeout: 100 * time.Millisecond}, "exceeded 100ms time limit"},
+		{"memory", bigModule, wasm.Limits{MemoryPages: 256}, "compiling wasm module"},
+		{"output", echoModule, wasm.Limits{MaxOutputBytes: 4}, "output exceeds 4 bytes"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := wasm.New(tc.limits).Run(context.Background(), tc.module, []byte("kind: ConfigMap\n"))
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("Run() error = %v, want one containing %q", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestRunReportsExitStatus(t *testing.T) {
+	_, err := wasm.New(wasm.Limits{}).Run(context.Background(), exitModule, nil)
+	var exitErr *wasm.ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 3 {
+		t.Fatalf("Run() error = %v, want an ExitError with code 3", err)
+	}
+}
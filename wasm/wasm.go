@@ -0,0 +1,81 @@
+// Package wasm runs user-supplied WebAssembly modules in a sandbox with strict
+// memory, time, and output limits. It is a safer alternative to exec
+// post-renderers: a module can only read the manifests it is given on stdin
+// and write its result to stdout; it has no filesystem, network, or clock
+// access beyond what WASI preview1 exposes by default.
+//
+// Modules must be WASI command modules (e.g. built with TinyGo or Rust's
+// wasm32-wasi target), stored in a ConfigMap; OCI artifacts are not
+// supported. The engine is wazero, which is pure Go and always compiled in.
+package wasm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrUnavailable is returned when the operator has no Wasm runtime.
+var ErrUnavailable = errors.New("wasm support is not available")
+
+// Limits bound the resources a single module invocation may use.
+type Limits struct {
+	// MemoryPages caps linear memory in 64 KiB pages. Defaults to 256 (16 MiB).
+	MemoryPages uint32
+	// Timeout caps wall-clock execution time. Defaults to 10s.
+	Timeout time.Duration
+	// MaxOutputBytes caps what the module may write to stdout. Defaults to 32 MiB.
+	MaxOutputBytes int
+}
+
+func (l Limits) withDefaults() Limits {
+	if l.MemoryPages == 0 {
+		l.MemoryPages = 256
+	}
+	if l.Timeout == 0 {
+		l.Timeout = 10 * time.Second
+	}
+	if l.MaxOutputBytes == 0 {
+		l.MaxOutputBytes = 32 << 20
+	}
+	return l
+}
+
+// Runtime executes WASI command modules.
+type Runtime interface {
+	// Run executes module with stdin as its standard input and returns what it
+	// wrote to standard output. A non-zero exit status is reported as *ExitError.
+	Run(ctx context.Context, module, stdin []byte) ([]byte, error)
+}
+
+// ExitError reports a module that exited with a non-zero status.
+type ExitError struct {
+	Code   uint32
+	Stdout string
+	Stderr string
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("wasm module exited with code %d: %s", e.Code, e.Stderr)
+}
+
+// limitedBuffer is an io.Writer that fails once more than max bytes are
+// written. The module sees only an errno and may carry on regardless, so the
+// failure is also kept in err for Run to report.
+type limitedBuffer struct {
+	buf []byte
+	max int
+	err error
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.err == nil && len(b.buf)+len(p) > b.max {
+		b.err = fmt.Errorf("wasm module output exceeds %d bytes", b.max)
+	}
+	if b.err != nil {
+		return 0, b.err
+	}
+	b.buf = append(b.buf, p...)
+	return len(p), nil
+}
@@ -0,0 +1,289 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"github.com/example/helm-operator/controllers"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// controllerName is the name controller-runtime gives the HelmRelease
+// controller; its workqueue and reconcile metrics are labelled with it.
+const controllerName = "helmrelease"
+
+const (
+	statsSampleInterval = 10 * time.Second
+	// statsWindow is the period reconcile and error rates are averaged over.
+	statsWindow = time.Minute
+)
+
+// serviceAccountNamespaceFile holds the pod's namespace, which is where the
+// manager creates its leader election Lease unless told otherwise.
+const serviceAccountNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// adminStats is the body of GET /api/admin/stats.
+type adminStats struct {
+	Leader     *leaderStats    `json:"leader,omitempty"`
+	Workqueue  workqueueStats  `json:"workqueue"`
+	Reconciles reconcileStats  `json:"reconciles"`
+	Cache      cacheStats      `json:"cache"`
+	IndexCache indexCacheStats `json:"indexCache"`
+	Sampled    time.Time       `json:"sampled"`
+	Errors     []string        `json:"errors,omitempty"`
+
+	// SecretsEncryption is the latest encryption at rest check, if enabled.
+	SecretsEncryption *controllers.SecretsEncryption `json:"secretsEncryption,omitempty"`
+}
+
+type leaderStats struct {
+	Identity  string     `json:"identity"`
+	RenewedAt *time.Time `json:"renewedAt,omitempty"`
+}
+
+type workqueueStats struct {
+	Depth                 float64 `json:"depth"`
+	Adds                  float64 `json:"adds"`
+	Retries               float64 `json:"retries"`
+	LongestRunningSeconds float64 `json:"longestRunningSeconds"`
+}
+
+type reconcileStats struct {
+	// Total counts reconciles since the operator started, by result
+	// (success, error, requeue, requeue_after).
+	Total map[string]float64 `json:"total"`
+	// PerMinute is the reconcile rate by result over the last minute.
+	PerMinute       map[string]float64 `json:"perMinute"`
+	ErrorsPerMinute float64            `json:"errorsPerMinute"`
+	// ErrorRatio is the share of reconciles in the last minute that failed.
+	ErrorRatio float64 `json:"errorRatio"`
+}
+
+type cacheStats struct {
+	HelmReleases int `json:"helmReleases"`
+}
+
+// indexCacheStats covers the repository index cache used by update checks.
+// Chart archives are not cached; every install or upgrade downloads them.
+type indexCacheStats struct {
+	Hits     float64 `json:"hits"`
+	Misses   float64 `json:"misses"`
+	HitRatio float64 `json:"hitRatio"`
+}
+
+// reconcileSample is a snapshot of the reconcile counters.
+type reconcileSample struct {
+	at      time.Time
+	results map[string]float64
+}
+
+// statsHistory keeps a minute of reconcile counter samples so the admin page
+// can show rates without a Prometheus server to compute them.
+type statsHistory struct {
+	mu      sync.Mutex
+	samples []reconcileSample
+}
+
+func (h *statsHistory) add(s reconcileSample) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.samples = append(h.samples, s)
+	for len(h.samples) > 1 && s.at.Sub(h.samples[0].at) > statsWindow {
+		h.samples = h.samples[1:]
+	}
+}
+
+// oldest returns the earliest sample still inside the window.
+func (h *statsHistory) oldest() (reconcileSample, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.samples) == 0 {
+		return reconcileSample{}, false
+	}
+	return h.samples[0], true
+}
+
+// sampleStats records the reconcile counters every statsSampleInterval until
+// ctx is cancelled.
+func (s *WebServer) sampleStats(ctx context.Context) {
+	ticker := time.NewTicker(statsSampleInterval)
+	defer ticker.Stop()
+	for {
+		if families, err := s.gatherer().Gather(); err == nil {
+			s.stats.add(reconcileSample{at: time.Now(), results: reconcileTotals(families)})
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *WebServer) gatherer() prometheus.Gatherer {
+	if s.Metrics != nil {
+		return s.Metrics
+	}
+	return ctrlmetrics.Registry
+}
+
+// handleAdminStats serves GET /api/admin/stats: a summary of the operator's
+// health for users without access to Prometheus. Sections that cannot be read
+// are reported in "errors" rather than failing the whole response.
+func (s *WebServer) handleAdminStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	families, err := s.gatherer().Gather()
+	if err != nil {
+		httpError(w, "gathering metrics: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	now := time.Now()
+	stats := adminStats{
+		Sampled: now,
+		Workqueue: workqueueStats{
+			Depth:                 metricValue(families, "workqueue_depth", "name", controllerName),
+			Adds:                  metricValue(families, "workqueue_adds_total", "name", controllerName),
+			Retries:               metricValue(families, "workqueue_retries_total", "name", controllerName),
+			LongestRunningSeconds: metricValue(families, "workqueue_longest_running_processor_seconds", "name", controllerName),
+		},
+		Reconciles: reconcileStats{Total: reconcileTotals(families), PerMinute: map[string]float64{}},
+		IndexCache: indexCacheStats{
+			Hits:   metricValue(families, controllers.IndexCacheRequestsMetric, "result", "hit"),
+			Misses: metricValue(families, controllers.IndexCacheRequestsMetric, "result", "miss"),
+		},
+		SecretsEncryption: s.Encryption.Status(),
+	}
+	if lookups := stats.IndexCache.Hits + stats.IndexCache.Misses; lookups > 0 {
+		stats.IndexCache.HitRatio = stats.IndexCache.Hits / lookups
+	}
+
+	if prev, ok := s.stats.oldest(); ok {
+		if minutes := now.Sub(prev.at).Minutes(); minutes > 0 {
+			var all, failed float64
+			for result, total := range stats.Reconciles.Total {
+				delta := total - prev.results[result]
+				stats.Reconciles.PerMinute[result] = delta / minutes
+				all += delta
+				if result == "error" {
+					failed = delta
+				}
+			}
+			stats.Reconciles.ErrorsPerMinute = failed / minutes
+			if all > 0 {
+				stats.Reconciles.ErrorRatio = failed / all
+			}
+		}
+	}
+
+	var list helmv1alpha1.HelmReleaseList
+	if err := s.Client.List(r.Context(), &list); err != nil {
+		stats.Errors = append(stats.Errors, "listing HelmReleases: "+err.Error())
+	} else {
+		stats.Cache.HelmReleases = len(list.Items)
+	}
+
+	if s.LeaderElectionID != "" {
+		leader, err := s.leader(r.Context())
+		if err != nil {
+			stats.Errors = append(stats.Errors, "reading leader election lease: "+err.Error())
+		}
+		stats.Leader = leader
+	}
+
+	writeJSON(w, stats)
+}
+
+// podNamespace returns the namespace the operator runs in.
+func podNamespace() (string, error) {
+	raw, err := os.ReadFile(serviceAccountNamespaceFile)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(raw)), nil
+}
+
+// leader reads the holder of the manager's leader election Lease.
+func (s *WebServer) leader(ctx context.Context) (*leaderStats, error) {
+	ns := s.LeaderElectionNamespace
+	if ns == "" {
+		var err error
+		if ns, err = podNamespace(); err != nil {
+			return nil, err
+		}
+	}
+	var lease coordinationv1.Lease
+	if err := s.reader().Get(ctx, types.NamespacedName{Namespace: ns, Name: s.LeaderElectionID}, &lease); err != nil {
+		return nil, err
+	}
+	leader := &leaderStats{}
+	if lease.Spec.HolderIdentity != nil {
+		leader.Identity = *lease.Spec.HolderIdentity
+	}
+	if lease.Spec.RenewTime != nil {
+		t := lease.Spec.RenewTime.Time
+		leader.RenewedAt = &t
+	}
+	return leader, nil
+}
+
+// reconcileTotals returns the HelmRelease controller's reconcile counts by
+// result.
+func reconcileTotals(families []*dto.MetricFamily) map[string]float64 {
+	totals := map[string]float64{}
+	for _, m := range metricsOf(families, "controller_runtime_reconcile_total", "controller", controllerName) {
+		totals[labelValue(m, "result")] += m.GetCounter().GetValue()
+	}
+	return totals
+}
+
+// metricValue sums the counter or gauge samples of the named metric family
+// whose label has the given value.
+func metricValue(families []*dto.MetricFamily, name, label, value string) float64 {
+	var sum float64
+	for _, m := range metricsOf(families, name, label, value) {
+		switch {
+		case m.Counter != nil:
+			sum += m.Counter.GetValue()
+		case m.Gauge != nil:
+			sum += m.Gauge.GetValue()
+		}
+	}
+	return sum
+}
+
+func metricsOf(families []*dto.MetricFamily, name, label, value string) []*dto.Metric {
+	var out []*dto.Metric
+	for _, f := range families {
+		if f.GetName() != name {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			if labelValue(m, label) == value {
+				out = append(out, m)
+			}
+		}
+	}
+	return out
+}
+
+func labelValue(m *dto.Metric, name string) string {
+	for _, l := range m.GetLabel() {
+		if l.GetName() == name {
+			return l.GetValue()
+		}
+	}
+	return ""
+}
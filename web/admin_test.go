@@ -0,0 +1,92 @@
+package web_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/example/helm-operator/controllers"
+	"github.com/example/helm-operator/web"
+	"github.com/prometheus/client_golang/prometheus"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Admin statistics", func() {
+	It("summarises the controller's metrics, cache and leader", func() {
+		reg := prometheus.NewRegistry()
+		depth := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "workqueue_depth"}, []string{"name"})
+		reconciles := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "controller_runtime_reconcile_total"},
+			[]string{"controller", "result"})
+		index := prometheus.NewCounterVec(prometheus.CounterOpts{Name: controllers.IndexCacheRequestsMetric}, []string{"result"})
+		reg.MustRegister(depth, reconciles, index)
+		depth.WithLabelValues("helmrelease").Set(4)
+		depth.WithLabelValues("other").Set(100)
+		reconciles.WithLabelValues("helmrelease", "success").Add(9)
+		reconciles.WithLabelValues("helmrelease", "error").Add(1)
+		index.WithLabelValues("hit").Add(3)
+		index.WithLabelValues("miss").Add(1)
+
+		holder := "operator-7d9f"
+		renewed := metav1.NewMicroTime(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC))
+		lease := &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "operator", Name: "helm-operator-leader"},
+			Spec:       coordinationv1.LeaseSpec{HolderIdentity: &holder, RenewTime: &renewed},
+		}
+		ts := startServer([]client.Object{lease, makeHR("team-a", "web"), makeHR("team-b", "api")}, func(s *web.WebServer) {
+			s.Metrics = reg
+			s.LeaderElectionID = "helm-operator-leader"
+			s.LeaderElectionNamespace = "operator"
+		})
+
+		resp, body := ts.do(http.MethodGet, "/api/admin/stats", nil)
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		var stats struct {
+			Leader struct {
+				Identity  string    `json:"identity"`
+				RenewedAt time.Time `json:"renewedAt"`
+			} `json:"leader"`
+			Workqueue struct {
+				Depth float64 `json:"depth"`
+			} `json:"workqueue"`
+			Reconciles struct {
+				Total map[string]float64 `json:"total"`
+			} `json:"reconciles"`
+			Cache struct {
+				HelmReleases int `json:"helmReleases"`
+			} `json:"cache"`
+			IndexCache struct {
+				HitRatio float64 `json:"hitRatio"`
+			} `json:"indexCache"`
+			Errors []string `json:"errors"`
+		}
+		Expect(json.Unmarshal(body, &stats)).To(Succeed())
+		Expect(stats.Errors).To(BeEmpty())
+		Expect(stats.Leader.Identity).To(Equal(holder))
+		Expect(stats.Leader.RenewedAt).To(BeTemporally("==", renewed.Time))
+		Expect(stats.Workqueue.Depth).To(Equal(4.0))
+		Expect(stats.Reconciles.Total).To(Equal(map[string]float64{"success": 9, "error": 1}))
+		Expect(stats.Cache.HelmReleases).To(Equal(2))
+		Expect(stats.IndexCache.HitRatio).To(Equal(0.75))
+	})
+
+	It("reports sections it cannot read instead of failing", func() {
+		ts := startServer(nil, func(s *web.WebServer) {
+			s.Metrics = prometheus.NewRegistry()
+			s.LeaderElectionID = "helm-operator-leader"
+			s.LeaderElectionNamespace = "operator"
+		})
+
+		resp, body := ts.do(http.MethodGet, "/api/admin/stats", nil)
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		var stats struct {
+			Errors []string `json:"errors"`
+		}
+		Expect(json.Unmarshal(body, &stats)).To(Succeed())
+		Expect(stats.Errors).To(ConsistOf(ContainSubstring("reading leader election lease")))
+	})
+})
@@ -0,0 +1,112 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// AuthzWebhook asks an external policy service whether a change made through
+// the web API may go ahead. It is called before every create, update, patch
+// and delete of a Kubernetes object, once the target namespace and release
+// are known.
+type AuthzWebhook struct {
+	URL     string
+	Timeout time.Duration
+	// FailOpen allows changes when the webhook cannot be reached or answers
+	// with an error. By default such changes are denied.
+	FailOpen bool
+	// HTTPClient defaults to http.DefaultClient; Timeout applies either way.
+	HTTPClient *http.Client
+}
+
+// AuthzReview is the body POSTed to the authorization webhook. The webhook
+// answers with the same object with Response filled in.
+type AuthzReview struct {
+	APIVersion string         `json:"apiVersion"`
+	Kind       string         `json:"kind"`
+	Request    AuthzRequest   `json:"request"`
+	Response   *AuthzResponse `json:"response,omitempty"`
+}
+
+// AuthzRequest describes the change to authorize.
+type AuthzRequest struct {
+	// User is as named by the authenticating proxy, "token:<name>" for API
+	// tokens or "slack:<user>" for Slack; empty for anonymous requests.
+	User string `json:"user"`
+	// Verb is create, update, patch or delete.
+	Verb string `json:"verb"`
+	// Kind is the kind of the object, usually HelmRelease.
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	// Release is the name of the object.
+	Release string `json:"release"`
+	// Method and Path are those of the web API call.
+	Method string `json:"method"`
+	Path   string `json:"path"`
+}
+
+// AuthzResponse is the webhook's decision.
+type AuthzResponse struct {
+	Allowed bool `json:"allowed"`
+	// Reason is shown to the caller when the change is denied.
+	Reason string `json:"reason,omitempty"`
+}
+
+// authorize asks the webhook about req, returning whether it is allowed and
+// why not.
+func (a *AuthzWebhook) authorize(ctx context.Context, req AuthzRequest) (bool, string) {
+	allowed, reason, err := a.call(ctx, req)
+	if err == nil {
+		return allowed, reason
+	}
+	ctrl.Log.Error(err, "Calling the authorization webhook", "failOpen", a.FailOpen,
+		"user", req.User, "verb", req.Verb, "namespace", req.Namespace, "release", req.Release)
+	if a.FailOpen {
+		return true, ""
+	}
+	return false, "the authorization webhook is unavailable"
+}
+
+func (a *AuthzWebhook) call(ctx context.Context, req AuthzRequest) (bool, string, error) {
+	body, err := json.Marshal(AuthzReview{APIVersion: "helm.example.com/v1alpha1", Kind: "AuthorizationReview", Request: req})
+	if err != nil {
+		return false, "", err
+	}
+	timeout := a.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, a.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, "", err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpClient := a.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(httpReq)
+	if err != nil {
+		return false, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, "", fmt.Errorf("authorization webhook returned %s", resp.Status)
+	}
+	var review AuthzReview
+	if err := json.NewDecoder(resp.Body).Decode(&review); err != nil {
+		return false, "", fmt.Errorf("decoding authorization webhook response: %w", err)
+	}
+	if review.Response == nil {
+		return false, "", fmt.Errorf("authorization webhook response has no response field")
+	}
+	return review.Response.Allowed, review.Response.Reason, nil
+}
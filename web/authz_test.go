@@ -0,0 +1,107 @@
+package web_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/example/helm-operator/web"
+)
+
+var _ = Describe("Authorization webhook", func() {
+	It("denies changes the webhook rejects", func() {
+		policy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var review web.AuthzReview
+			if err := json.NewDecoder(r.Body).Decode(&review); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			review.Response = &web.AuthzResponse{Allowed: review.Request.Namespace != "prod", Reason: "prod is frozen"}
+			_ = json.NewEncoder(w).Encode(review)
+		}))
+		DeferCleanup(policy.Close)
+		ts := startServer(nil, func(s *web.WebServer) { s.AuthzWebhook = &web.AuthzWebhook{URL: policy.URL} })
+
+		resp, _ := ts.do(http.MethodPost, "/api/helmreleases", createBody)
+		Expect(resp.StatusCode).To(Equal(http.StatusCreated))
+		resp, body := ts.do(http.MethodPost, "/api/helmreleases", withField(createBody, "namespace", "prod"))
+		Expect(resp.StatusCode).To(Equal(http.StatusForbidden))
+		Expect(string(body)).To(ContainSubstring("prod is frozen"))
+	})
+
+	It("fails closed unless failOpen is set", func() {
+		down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		}))
+		DeferCleanup(down.Close)
+
+		ts := startServer(nil, func(s *web.WebServer) { s.AuthzWebhook = &web.AuthzWebhook{URL: down.URL} })
+		resp, _ := ts.do(http.MethodPost, "/api/helmreleases", createBody)
+		Expect(resp.StatusCode).To(Equal(http.StatusForbidden))
+
+		ts = startServer(nil, func(s *web.WebServer) { s.AuthzWebhook = &web.AuthzWebhook{URL: down.URL, FailOpen: true} })
+		resp, _ = ts.do(http.MethodPost, "/api/helmreleases", createBody)
+		Expect(resp.StatusCode).To(Equal(http.StatusCreated))
+	})
+
+	It("describes the change and the caller", func() {
+		var (
+			mu       sync.Mutex
+			requests []web.AuthzRequest
+		)
+		policy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var review web.AuthzReview
+			if err := json.NewDecoder(r.Body).Decode(&review); err != nil || review.Kind != "AuthorizationReview" {
+				http.Error(w, "not an AuthorizationReview", http.StatusBadRequest)
+				return
+			}
+			mu.Lock()
+			requests = append(requests, review.Request)
+			mu.Unlock()
+			review.Response = &web.AuthzResponse{Allowed: true}
+			_ = json.NewEncoder(w).Encode(review)
+		}))
+		DeferCleanup(policy.Close)
+		ts := startServer(nil, func(s *web.WebServer) { s.AuthzWebhook = &web.AuthzWebhook{URL: policy.URL} })
+
+		resp, _ := ts.do(http.MethodPost, "/api/helmreleases", createBody, "X-Forwarded-User", "alice")
+		Expect(resp.StatusCode).To(Equal(http.StatusCreated))
+		mu.Lock()
+		defer mu.Unlock()
+		Expect(requests).To(ContainElement(web.AuthzRequest{
+			User: "alice", Verb: "create", Kind: "HelmRelease", Namespace: "team-a", Release: "web",
+			Method: http.MethodPost, Path: "/api/helmreleases",
+		}))
+	})
+
+	DescribeTable("denies changes when the webhook misbehaves",
+		func(handler http.HandlerFunc) {
+			policy := httptest.NewServer(handler)
+			DeferCleanup(policy.Close)
+			ts := startServer(nil, func(s *web.WebServer) {
+				s.AuthzWebhook = &web.AuthzWebhook{URL: policy.URL, Timeout: 100 * time.Millisecond}
+			})
+
+			resp, body := ts.do(http.MethodPost, "/api/helmreleases", createBody)
+			Expect(resp.StatusCode).To(Equal(http.StatusForbidden))
+			Expect(string(body)).To(ContainSubstring("the authorization webhook is unavailable"))
+		},
+		Entry("no response", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(`{"apiVersion":"helm.example.com/v1alpha1","kind":"AuthorizationReview"}`))
+		})),
+		Entry("not JSON", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("allowed"))
+		})),
+		Entry("too slow", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+		})),
+	)
+})
@@ -0,0 +1,105 @@
+package web
+
+import (
+	"net/http"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/example/helm-operator/controllers"
+	"helm.sh/helm/v3/pkg/chart"
+)
+
+// maxInspectedFile is the largest part of a chart file returned by
+// GET /api/charts/inspect?file=.
+const maxInspectedFile = 256 << 10
+
+// chartInspection is the body of GET /api/charts/inspect.
+type chartInspection struct {
+	Metadata *chart.Metadata `json:"metadata"`
+	// Files lists every file in the chart archive, subcharts included.
+	Files []chartFile `json:"files"`
+}
+
+// chartFile is a file in a chart archive.
+type chartFile struct {
+	Path string `json:"path"`
+	Size int    `json:"size"`
+}
+
+// chartFileContent is the body of GET /api/charts/inspect?file=.
+type chartFileContent struct {
+	Path string `json:"path"`
+	Size int    `json:"size"`
+	// Content is empty for binary files, such as packaged subcharts.
+	Content string `json:"content,omitempty"`
+	Binary  bool   `json:"binary,omitempty"`
+	// Truncated is set when Content holds only the first
+	// maxInspectedFile bytes.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// handleChartInspect serves GET /api/charts/inspect?repoURL=&chart=&version=:
+// the chart's metadata and file tree, or with &file= the contents of one
+// file, so a chart can be looked into before a HelmRelease is created.
+// &proxyURL= downloads it through a proxy, as spec.proxyURL does.
+func (s *WebServer) handleChartInspect(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.HelmClient == nil {
+		httpError(w, "chart inspection is not available", http.StatusServiceUnavailable)
+		return
+	}
+	q := r.URL.Query()
+	repoURL, name, version := q.Get("repoURL"), q.Get("chart"), q.Get("version")
+	if repoURL == "" || name == "" || version == "" {
+		httpError(w, "query params 'repoURL', 'chart' and 'version' are required", http.StatusBadRequest)
+		return
+	}
+
+	c, err := s.HelmClient.LoadChart(r.Context(), name, repoURL, version,
+		controllers.ChartFetchOptions{ProxyURL: q.Get("proxyURL")})
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	if path := q.Get("file"); path != "" {
+		for _, f := range c.Raw {
+			if f.Name == path {
+				writeJSON(w, inspectedFile(f))
+				return
+			}
+		}
+		httpError(w, "no file "+path+" in the chart", http.StatusNotFound)
+		return
+	}
+
+	out := chartInspection{Metadata: c.Metadata, Files: make([]chartFile, 0, len(c.Raw))}
+	for _, f := range c.Raw {
+		out.Files = append(out.Files, chartFile{Path: f.Name, Size: len(f.Data)})
+	}
+	sort.Slice(out.Files, func(i, j int) bool { return out.Files[i].Path < out.Files[j].Path })
+	writeJSON(w, out)
+}
+
+// inspectedFile returns the contents of f, cut at maxInspectedFile.
+func inspectedFile(f *chart.File) chartFileContent {
+	out := chartFileContent{Path: f.Name, Size: len(f.Data)}
+	data := f.Data
+	if len(data) > maxInspectedFile {
+		data = data[:maxInspectedFile]
+		// The cut may split a UTF-8 sequence.
+		for i := 0; i < utf8.UTFMax && !utf8.Valid(data); i++ {
+			data = data[:len(data)-1]
+		}
+		out.Truncated = true
+	}
+	if !utf8.Valid(data) {
+		out.Binary, out.Truncated = true, false
+		return out
+	}
+	out.Content = string(data)
+	return out
+}
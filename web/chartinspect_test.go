@@ -0,0 +1,69 @@
+package web_test
+
+import (
+	"encoding/json"
+	"net/http"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/example/helm-operator/controllers"
+	"github.com/example/helm-operator/web"
+)
+
+var _ = Describe("Chart inspection API", func() {
+	var ts *testServer
+	BeforeEach(func() {
+		ts = startServer(nil, func(s *web.WebServer) {
+			s.HelmClient = &controllers.FakeHelmClient{}
+		})
+	})
+
+	const chartQuery = "/api/charts/inspect?repoURL=https://charts.example.com&chart=nginx&version=1.0.0"
+
+	It("returns the chart's metadata and files", func() {
+		resp, body := ts.do(http.MethodGet, chartQuery, nil)
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		var out struct {
+			Metadata struct {
+				Name    string `json:"name"`
+				Version string `json:"version"`
+			} `json:"metadata"`
+			Files []struct {
+				Path string `json:"path"`
+				Size int    `json:"size"`
+			} `json:"files"`
+		}
+		Expect(json.Unmarshal(body, &out)).To(Succeed())
+		Expect(out.Metadata.Name).To(Equal("nginx"))
+		Expect(out.Metadata.Version).To(Equal("1.0.0"))
+		paths := []string{}
+		for _, f := range out.Files {
+			paths = append(paths, f.Path)
+			Expect(f.Size).To(BeNumerically(">", 0))
+		}
+		Expect(paths).To(Equal([]string{"Chart.yaml", "templates/configmap.yaml", "values.yaml"}))
+	})
+
+	It("returns the contents of one file", func() {
+		resp, body := ts.do(http.MethodGet, chartQuery+"&file=templates/configmap.yaml", nil)
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		var out struct {
+			Path      string `json:"path"`
+			Content   string `json:"content"`
+			Truncated bool   `json:"truncated"`
+		}
+		Expect(json.Unmarshal(body, &out)).To(Succeed())
+		Expect(out.Path).To(Equal("templates/configmap.yaml"))
+		Expect(out.Content).To(ContainSubstring("kind: ConfigMap"))
+		Expect(out.Truncated).To(BeFalse())
+
+		resp, _ = ts.do(http.MethodGet, chartQuery+"&file=templates/missing.yaml", nil)
+		Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+	})
+
+	It("requires the chart coordinates", func() {
+		resp, _ := ts.do(http.MethodGet, "/api/charts/inspect?chart=nginx", nil)
+		Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+	})
+})
@@ -4,12 +4,15 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strings"
 
 	anthropic "github.com/anthropics/anthropic-sdk-go"
 	"github.com/anthropics/anthropic-sdk-go/option"
 )
 
-func streamDiagnosis(ctx context.Context, apiKey, prompt string, w http.ResponseWriter, flusher http.Flusher) error {
+// streamDiagnosis streams the model's answer to w as SSE chunks and returns
+// the complete text.
+func streamDiagnosis(ctx context.Context, apiKey, prompt string, w http.ResponseWriter, flusher http.Flusher) (string, error) {
 	client := anthropic.NewClient(option.WithAPIKey(apiKey))
 
 	stream := client.Messages.NewStreaming(ctx, anthropic.MessageNewParams{
@@ -20,6 +23,7 @@ func streamDiagnosis(ctx context.Context, apiKey, prompt string, w http.Response
 		},
 	})
 
+	var text strings.Builder
 	for stream.Next() {
 		ev := stream.Current()
 		switch event := ev.AsAny().(type) {
@@ -28,6 +32,7 @@ func streamDiagnosis(ctx context.Context, apiKey, prompt string, w http.Response
 			case anthropic.TextDelta:
 				chunk := delta.Text
 				if chunk != "" {
+					text.WriteString(chunk)
 					fmt.Fprintf(w, "data: {\"chunk\":%q}\n\n", chunk)
 					flusher.Flush()
 				}
@@ -36,10 +41,10 @@ func streamDiagnosis(ctx context.Context, apiKey, prompt string, w http.Response
 	}
 
 	if err := stream.Err(); err != nil {
-		return err
+		return "", err
 	}
 
 	fmt.Fprintf(w, "data: {\"done\":true}\n\n")
 	flusher.Flush()
-	return nil
+	return text.String(), nil
 }
@@ -0,0 +1,291 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"github.com/example/helm-operator/controllers"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// commandUsage documents the command language, served on GET /api/command
+// for the UI's command palette and chat-ops integrations.
+var commandUsage = []struct {
+	Verb        string `json:"verb"`
+	Usage       string `json:"usage"`
+	Description string `json:"description"`
+}{
+	{"upgrade", "upgrade <namespace>/<name> to <version>", "Set spec.version of a release."},
+	{"suspend", "suspend <namespace>/<name> | <selector> [in <namespace>]", "Pause Helm operations of the matching releases."},
+	{"resume", "resume <namespace>/<name> | <selector> [in <namespace>]", "Resume Helm operations of the matching releases."},
+	{"hold", "hold <namespace>/<name> [until <RFC 3339 time>]", "Block upgrades of a release with the hold annotations."},
+	{"unhold", "unhold <namespace>/<name>", "Remove the hold annotations of a release."},
+}
+
+// usage returns the usage line of verb.
+func usage(verb string) string {
+	for _, u := range commandUsage {
+		if u.Verb == verb {
+			return u.Usage
+		}
+	}
+	return ""
+}
+
+// command is a parsed /api/command command. Exactly one of Release and
+// Selector is set.
+type command struct {
+	Verb      string
+	Release   *types.NamespacedName
+	Selector  labels.Selector
+	Namespace string // limits Selector
+	Version   string // upgrade
+	Until     string // hold, RFC 3339
+}
+
+// String returns the canonical form of c.
+func (c command) String() string {
+	var b strings.Builder
+	b.WriteString(c.Verb + " ")
+	if c.Release != nil {
+		b.WriteString(c.Release.String())
+	} else {
+		b.WriteString(c.Selector.String())
+		if c.Namespace != "" {
+			b.WriteString(" in " + c.Namespace)
+		}
+	}
+	if c.Version != "" {
+		b.WriteString(" to " + c.Version)
+	}
+	if c.Until != "" {
+		b.WriteString(" until " + c.Until)
+	}
+	return b.String()
+}
+
+// parseCommand parses and validates one command, such as
+// "upgrade payments/redis to 17.3.2" or "suspend team=web".
+func parseCommand(text string) (command, error) {
+	fields := strings.Fields(text)
+	if len(fields) < 2 {
+		return command{}, fmt.Errorf("expected a verb and a target, e.g. %q", usage("upgrade"))
+	}
+	c := command{Verb: strings.ToLower(fields[0])}
+	args := fields[1:]
+
+	single := func() error {
+		if len(args) == 0 {
+			return fmt.Errorf("%s needs a release as <namespace>/<name>", c.Verb)
+		}
+		ns, name, ok := strings.Cut(args[0], "/")
+		if !ok || ns == "" || name == "" || strings.Contains(name, "/") {
+			return fmt.Errorf("%s needs a release as <namespace>/<name>, got %q", c.Verb, args[0])
+		}
+		c.Release = &types.NamespacedName{Namespace: ns, Name: name}
+		args = args[1:]
+		return nil
+	}
+
+	switch c.Verb {
+	case "upgrade":
+		if err := single(); err != nil {
+			return command{}, err
+		}
+		if len(args) != 2 || args[0] != "to" {
+			return command{}, fmt.Errorf("usage: %s", usage(c.Verb))
+		}
+		c.Version = args[1]
+		if _, err := semver.NewVersion(c.Version); err != nil {
+			if _, err := semver.NewConstraint(c.Version); err != nil {
+				return command{}, fmt.Errorf("invalid version %q: %w", c.Version, err)
+			}
+		}
+	case "suspend", "resume":
+		// A trailing "in <namespace>" limits a selector; "team in (a,b)" is
+		// itself a selector, so only a valid namespace name counts.
+		if n := len(args); n >= 3 && args[n-2] == "in" && len(validation.IsDNS1123Label(args[n-1])) == 0 {
+			c.Namespace = args[n-1]
+			args = args[:n-2]
+		}
+		target := strings.Join(args, " ")
+		if strings.Contains(target, "/") && !strings.ContainsAny(target, "=!(") {
+			if c.Namespace != "" {
+				return command{}, fmt.Errorf("\"in <namespace>\" only applies to a selector")
+			}
+			if err := single(); err != nil {
+				return command{}, err
+			}
+			if len(args) > 0 {
+				return command{}, fmt.Errorf("unexpected %q after the release", strings.Join(args, " "))
+			}
+			break
+		}
+		selector, err := labels.Parse(target)
+		if err != nil {
+			return command{}, fmt.Errorf("invalid selector %q: %w", target, err)
+		}
+		// As with the bulk endpoints, every release must not be one typo away.
+		if selector.Empty() {
+			return command{}, fmt.Errorf("%s needs a release or a non-empty selector", c.Verb)
+		}
+		c.Selector = selector
+	case "hold":
+		if err := single(); err != nil {
+			return command{}, err
+		}
+		switch {
+		case len(args) == 0:
+		case len(args) == 2 && args[0] == "until":
+			if _, err := time.Parse(time.RFC3339, args[1]); err != nil {
+				return command{}, fmt.Errorf("invalid time %q, expected RFC 3339 such as 2024-05-01T18:00:00Z", args[1])
+			}
+			c.Until = args[1]
+		default:
+			return command{}, fmt.Errorf("usage: %s", usage(c.Verb))
+		}
+	case "unhold":
+		if err := single(); err != nil {
+			return command{}, err
+		}
+		if len(args) > 0 {
+			return command{}, fmt.Errorf("usage: %s", usage(c.Verb))
+		}
+	default:
+		return command{}, fmt.Errorf("unknown verb %q", fields[0])
+	}
+	return c, nil
+}
+
+// commandRequest is the body of POST /api/command.
+type commandRequest struct {
+	Command string `json:"command"`
+	// DryRun reports what the command would change without changing it.
+	DryRun bool `json:"dryRun"`
+}
+
+// commandResult is the body returned by POST /api/command.
+type commandResult struct {
+	// Command is the parsed command in canonical form.
+	Command string `json:"command"`
+	changeResult
+}
+
+// handleCommand serves /api/command: GET returns the command language,
+// POST parses, validates and runs one command.
+func (s *WebServer) handleCommand(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, commandUsage)
+		return
+	case http.MethodPost:
+	default:
+		httpError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req commandRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpError(w, "invalid JSON body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	cmd, err := parseCommand(req.Command)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := s.runCommand(r, cmd, req.DryRun, "command: "+cmd.String())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, commandResult{Command: cmd.String(), changeResult: result})
+}
+
+// runCommand runs cmd, recording detail in the audit log. Errors are those
+// of looking up the releases; failed changes are reported in the result.
+func (s *WebServer) runCommand(r *http.Request, cmd command, dryRun bool, detail string) (changeResult, error) {
+	var releases []helmv1alpha1.HelmRelease
+	if cmd.Release != nil {
+		var hr helmv1alpha1.HelmRelease
+		if err := s.Client.Get(r.Context(), *cmd.Release, &hr); err != nil {
+			return changeResult{}, err
+		}
+		releases = []helmv1alpha1.HelmRelease{hr}
+	} else {
+		var list helmv1alpha1.HelmReleaseList
+		if err := s.Client.List(r.Context(), &list, selectorListOptions(cmd.Selector, cmd.Namespace)...); err != nil {
+			return changeResult{}, err
+		}
+		releases = list.Items
+	}
+
+	switch cmd.Verb {
+	case "suspend", "resume":
+		return s.suspendReleases(r, releases, cmd.Verb == "suspend", dryRun, detail), nil
+	default:
+		return s.applyCommand(r, cmd, &releases[0], dryRun, detail), nil
+	}
+}
+
+// applyCommand runs a single-release command other than suspend and resume.
+func (s *WebServer) applyCommand(r *http.Request, cmd command, hr *helmv1alpha1.HelmRelease, dryRun bool, detail string) changeResult {
+	result := changeResult{DryRun: dryRun, Changed: []releaseRef{}, Unchanged: []releaseRef{}}
+	ref := releaseRef{Namespace: hr.Namespace, Name: hr.Name}
+
+	base := hr.DeepCopy()
+	action := "updated"
+	annotations := hr.Annotations
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	switch cmd.Verb {
+	case "upgrade":
+		hr.Spec.Version = cmd.Version
+	case "hold":
+		action = "held"
+		delete(annotations, controllers.AnnotationHold)
+		delete(annotations, controllers.AnnotationHoldUntil)
+		if cmd.Until != "" {
+			annotations[controllers.AnnotationHoldUntil] = cmd.Until
+		} else {
+			annotations[controllers.AnnotationHold] = "true"
+		}
+	case "unhold":
+		action = "unheld"
+		delete(annotations, controllers.AnnotationHold)
+		delete(annotations, controllers.AnnotationHoldUntil)
+	}
+	hr.Annotations = annotations
+	if hr.Spec.Version == base.Spec.Version &&
+		hr.Annotations[controllers.AnnotationHold] == base.Annotations[controllers.AnnotationHold] &&
+		hr.Annotations[controllers.AnnotationHoldUntil] == base.Annotations[controllers.AnnotationHoldUntil] {
+		result.Unchanged = append(result.Unchanged, ref)
+		return result
+	}
+	if dryRun {
+		result.Changed = append(result.Changed, ref)
+		return result
+	}
+
+	if cmd.Verb == "upgrade" {
+		setTriggeredBy(r, hr)
+	}
+	if err := s.Client.Patch(r.Context(), hr, client.MergeFrom(base)); err != nil {
+		result.fail(hr, err)
+		return result
+	}
+	s.broadcastEvent("updated", hr)
+	s.audit(r, action, hr, detail)
+	result.Changed = append(result.Changed, ref)
+	return result
+}
@@ -0,0 +1,104 @@
+package web_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"github.com/example/helm-operator/controllers"
+	"github.com/example/helm-operator/store"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Command API", func() {
+	ctx := context.Background()
+
+	type result struct {
+		Command string `json:"command"`
+		DryRun  bool   `json:"dryRun"`
+		Changed []struct {
+			Namespace string `json:"namespace"`
+			Name      string `json:"name"`
+		} `json:"changed"`
+	}
+
+	var ts *testServer
+	BeforeEach(func() {
+		mine := makeHR("team-a", "web")
+		mine.Labels = map[string]string{"team": "web"}
+		other := makeHR("team-b", "web")
+		other.Labels = map[string]string{"team": "web"}
+		ts = startServer([]client.Object{mine, other})
+	})
+
+	run := func(text string, dryRun bool) (*http.Response, result) {
+		resp, body := ts.do(http.MethodPost, "/api/command", map[string]interface{}{"command": text, "dryRun": dryRun})
+		var out result
+		if resp.StatusCode == http.StatusOK {
+			Expect(json.Unmarshal(body, &out)).To(Succeed())
+		}
+		return resp, out
+	}
+	get := func(ns string) *helmv1alpha1.HelmRelease {
+		var hr helmv1alpha1.HelmRelease
+		Expect(ts.K8s.Get(ctx, types.NamespacedName{Namespace: ns, Name: "web"}, &hr)).To(Succeed())
+		return &hr
+	}
+
+	It("upgrades a release and audits the command", func() {
+		resp, out := run("Upgrade team-a/web to 2.0.0", false)
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(out.Command).To(Equal("upgrade team-a/web to 2.0.0"))
+		Expect(out.Changed).To(HaveLen(1))
+		Expect(get("team-a").Spec.Version).To(Equal("2.0.0"))
+
+		entries, err := ts.Store.ListAudit(ctx, store.ListOptions{Namespace: "team-a", Name: "web"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(HaveLen(1))
+		Expect(entries[0].Detail).To(Equal("command: upgrade team-a/web to 2.0.0"))
+	})
+
+	It("limits selectors to a namespace and changes nothing in a dry run", func() {
+		resp, out := run("suspend team=web in team-b", true)
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(out.DryRun).To(BeTrue())
+		Expect(out.Changed).To(HaveLen(1))
+		Expect(out.Changed[0].Namespace).To(Equal("team-b"))
+		Expect(get("team-b").Spec.Suspend).To(BeFalse())
+
+		resp, out = run("suspend team=web", false)
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(out.Changed).To(HaveLen(2))
+		Expect(get("team-a").Spec.Suspend).To(BeTrue())
+		Expect(get("team-b").Spec.Suspend).To(BeTrue())
+	})
+
+	It("holds and unholds a release", func() {
+		resp, _ := run("hold team-a/web until 2030-01-01T00:00:00Z", false)
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(get("team-a").Annotations).To(HaveKeyWithValue(controllers.AnnotationHoldUntil, "2030-01-01T00:00:00Z"))
+
+		resp, _ = run("unhold team-a/web", false)
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(get("team-a").Annotations).NotTo(HaveKey(controllers.AnnotationHoldUntil))
+	})
+
+	DescribeTable("rejects invalid commands",
+		func(text string, status int) {
+			resp, _ := run(text, false)
+			Expect(resp.StatusCode).To(Equal(status))
+		},
+		Entry("no target", "upgrade", http.StatusBadRequest),
+		Entry("unknown verb", "restart team-a/web", http.StatusBadRequest),
+		Entry("bad version", "upgrade team-a/web to latest!", http.StatusBadRequest),
+		Entry("bad selector", "suspend team in (a", http.StatusBadRequest),
+		Entry("bad hold time", "hold team-a/web until tomorrow", http.StatusBadRequest),
+		Entry("namespace with a release", "resume team-a/web in team-a", http.StatusBadRequest),
+		Entry("missing release", "upgrade team-c/web to 2.0.0", http.StatusNotFound),
+	)
+})
@@ -7,27 +7,37 @@ import (
 	"strings"
 
 	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"github.com/example/helm-operator/store"
+	authorizationv1 "k8s.io/api/authorization/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 func (s *WebServer) handleDiagnose(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	name := r.URL.Query().Get("name")
 	ns := r.URL.Query().Get("ns")
 	if name == "" || ns == "" {
-		http.Error(w, "query params 'name' and 'ns' are required", http.StatusBadRequest)
+		httpError(w, "query params 'name' and 'ns' are required", http.StatusBadRequest)
+		return
+	}
+	if denied, err := s.diagnoseDenied(r, ns, name); err != nil {
+		writeError(w, err)
+		return
+	} else if denied != "" {
+		httpError(w, denied, http.StatusForbidden)
 		return
 	}
 
 	apiKey := os.Getenv("ANTHROPIC_API_KEY")
 	if apiKey == "" {
-		http.Error(w, "ANTHROPIC_API_KEY not set", http.StatusServiceUnavailable)
+		httpError(w, "ANTHROPIC_API_KEY not set", http.StatusServiceUnavailable)
 		return
 	}
 
@@ -37,7 +47,7 @@ func (s *WebServer) handleDiagnose(w http.ResponseWriter, r *http.Request) {
 
 	flusher, ok := w.(http.Flusher)
 	if !ok {
-		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		httpError(w, "streaming not supported", http.StatusInternalServerError)
 		return
 	}
 
@@ -69,8 +79,54 @@ func (s *WebServer) handleDiagnose(w http.ResponseWriter, r *http.Request) {
 	}
 	sb.WriteString("\nProvide a concise diagnosis (2-3 sentences) and a concrete suggested fix.")
 
-	if err := streamDiagnosis(r.Context(), apiKey, sb.String(), w, flusher); err != nil {
+	text, err := streamDiagnosis(r.Context(), apiKey, sb.String(), w, flusher)
+	if err != nil {
 		fmt.Fprintf(w, "data: {\"error\":%q}\n\n", err.Error())
 		flusher.Flush()
+		return
+	}
+	d := store.Diagnosis{Namespace: ns, Name: name, Phase: string(hr.Status.Phase), Text: text}
+	if err := s.Store.SaveDiagnosis(r.Context(), d); err != nil {
+		ctrl.Log.Error(err, "Saving diagnosis", "namespace", ns, "name", name)
+	}
+}
+
+// AnnotationDiagnose set to "disabled" on a Namespace keeps its releases out
+// of AI diagnoses, which send their status and events to an external service.
+const AnnotationDiagnose = "helm.example.com/diagnose"
+
+// diagnoseDenied returns why r may not diagnose the release name in
+// namespace, or "" if it may. A Namespace can opt out of diagnoses; with
+// DiagnoseReview, users named by the authenticating proxy also need
+// the custom verb "diagnose" on the HelmRelease. API tokens need the
+// diagnose scope instead, which authenticate checks.
+func (s *WebServer) diagnoseDenied(r *http.Request, namespace, name string) (string, error) {
+	var ns corev1.Namespace
+	if err := s.reader().Get(r.Context(), types.NamespacedName{Name: namespace}, &ns); client.IgnoreNotFound(err) != nil {
+		return "", err
+	}
+	if ns.Annotations[AnnotationDiagnose] == "disabled" {
+		return fmt.Sprintf("diagnoses are disabled for namespace %s", namespace), nil
+	}
+	if !s.DiagnoseReview || s.isAdmin(r) || requestToken(r.Context()) != nil {
+		return "", nil
+	}
+	user := requestUser(r)
+	if user == "" {
+		return "diagnoses require a user named by the authenticating proxy", nil
+	}
+	allowed, err := s.accessReview(r.Context(), user, authorizationv1.ResourceAttributes{
+		Namespace: namespace,
+		Verb:      "diagnose",
+		Group:     helmv1alpha1.GroupVersion.Group,
+		Resource:  "helmreleases",
+		Name:      name,
+	})
+	if err != nil {
+		return "", err
+	}
+	if !allowed {
+		return fmt.Sprintf("user %s may not diagnose helmreleases in namespace %s", user, namespace), nil
 	}
+	return "", nil
 }
@@ -0,0 +1,42 @@
+package web_test
+
+import (
+	"net/http"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/example/helm-operator/web"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Diagnose API permissions", func() {
+	namespace := func(name string, annotations map[string]string) *corev1.Namespace {
+		return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Annotations: annotations}}
+	}
+
+	It("refuses releases in namespaces that opted out", func() {
+		ts := startServer([]client.Object{
+			namespace("payments", map[string]string{web.AnnotationDiagnose: "disabled"}),
+			makeHR("payments", "api"),
+		}, func(s *web.WebServer) {
+			s.Admins = []string{"alice"}
+		})
+
+		resp, body := ts.do(http.MethodPost, "/api/diagnose?ns=payments&name=api", nil, "X-Forwarded-User", "alice")
+		Expect(resp.StatusCode).To(Equal(http.StatusForbidden))
+		Expect(string(body)).To(ContainSubstring("diagnoses are disabled for namespace payments"))
+	})
+
+	It("requires a user with the access review enabled", func() {
+		ts := startServer([]client.Object{namespace("team-a", nil), makeHR("team-a", "web")}, func(s *web.WebServer) {
+			s.DiagnoseReview = true
+		})
+
+		resp, body := ts.do(http.MethodPost, "/api/diagnose?ns=team-a&name=web", nil)
+		Expect(resp.StatusCode).To(Equal(http.StatusForbidden))
+		Expect(string(body)).To(ContainSubstring("authenticating proxy"))
+	})
+})
@@ -0,0 +1,300 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"helm.sh/helm/v3/pkg/release"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// discoveredRelease is one entry of GET /api/discover/releases.
+type discoveredRelease struct {
+	Name         string    `json:"name"`
+	Namespace    string    `json:"namespace"`
+	Revision     int       `json:"revision"`
+	Status       string    `json:"status"`
+	Chart        string    `json:"chart"`
+	ChartVersion string    `json:"chartVersion"`
+	AppVersion   string    `json:"appVersion,omitempty"`
+	Updated      time.Time `json:"updated"`
+	// RepoURL is the repository other HelmReleases install the chart from.
+	// Helm does not record where a chart came from, so it is empty when no
+	// HelmRelease uses the chart.
+	RepoURL string `json:"repoURL,omitempty"`
+}
+
+// adoptRequest is the body of POST /api/discover/adopt.
+type adoptRequest struct {
+	// DryRun returns the HelmReleases without creating them.
+	DryRun   bool          `json:"dryRun"`
+	Releases []adoptTarget `json:"releases"`
+}
+
+// adoptTarget selects a discovered release to adopt.
+type adoptTarget struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	// RepoURL overrides the inferred repository; required when none is
+	// inferred.
+	RepoURL string `json:"repoURL,omitempty"`
+}
+
+// adoptResult is the body returned by POST /api/discover/adopt.
+type adoptResult struct {
+	DryRun bool `json:"dryRun"`
+	// Adopted lists the HelmReleases that were (or, in a dry run, would be)
+	// created.
+	Adopted []helmv1alpha1.HelmRelease `json:"adopted"`
+	// Failed maps releases that could not be adopted to the error.
+	Failed map[string]string `json:"failed,omitempty"`
+}
+
+// handleDiscoverReleases serves GET /api/discover/releases: the Helm
+// releases, optionally limited to ?ns=, that no HelmRelease manages, such as
+// those installed with the helm CLI. Helm keeps releases in Secrets, so only
+// those in namespaces whose Secrets the requester may read are listed.
+func (s *WebServer) handleDiscoverReleases(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.HelmClient == nil {
+		httpError(w, "release discovery is not available", http.StatusServiceUnavailable)
+		return
+	}
+	unmanaged, repos, err := s.unmanagedReleases(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	ns := r.URL.Query().Get("ns")
+	readable := s.secretsReadable(r)
+	out := []discoveredRelease{}
+	for _, rel := range unmanaged {
+		if ns != "" && rel.Namespace != ns {
+			continue
+		}
+		allowed, err := readable(rel.Namespace)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if !allowed {
+			continue
+		}
+		d := discoveredRelease{Name: rel.Name, Namespace: rel.Namespace, Revision: rel.Version}
+		if rel.Chart != nil && rel.Chart.Metadata != nil {
+			d.Chart, d.ChartVersion, d.AppVersion = rel.Chart.Metadata.Name, rel.Chart.Metadata.Version, rel.Chart.Metadata.AppVersion
+			d.RepoURL = repos[d.Chart]
+		}
+		if rel.Info != nil {
+			d.Status = rel.Info.Status.String()
+			d.Updated = rel.Info.LastDeployed.Time
+		}
+		out = append(out, d)
+	}
+	writeJSON(w, out)
+}
+
+// handleAdopt serves POST /api/discover/adopt, creating a HelmRelease for
+// each selected unmanaged release. Each HelmRelease pins the deployed chart
+// version and carries the release's values, so the controller's first
+// upgrade changes nothing in the cluster. The values may hold secrets, so
+// the requester must be allowed to read Secrets in the release's namespace,
+// and secret-like keys are redacted in the response.
+func (s *WebServer) handleAdopt(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		httpError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.HelmClient == nil {
+		httpError(w, "release discovery is not available", http.StatusServiceUnavailable)
+		return
+	}
+	var req adoptRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpError(w, "invalid JSON body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(req.Releases) == 0 {
+		httpError(w, "releases is required", http.StatusBadRequest)
+		return
+	}
+	unmanaged, repos, err := s.unmanagedReleases(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	byKey := make(map[string]*release.Release, len(unmanaged))
+	for _, rel := range unmanaged {
+		byKey[rel.Namespace+"/"+rel.Name] = rel
+	}
+
+	result := adoptResult{DryRun: req.DryRun, Adopted: []helmv1alpha1.HelmRelease{}}
+	fail := func(key string, err error) {
+		if result.Failed == nil {
+			result.Failed = map[string]string{}
+		}
+		result.Failed[key] = err.Error()
+	}
+	readable := s.secretsReadable(r)
+	for _, target := range req.Releases {
+		key := target.Namespace + "/" + target.Name
+		allowed, err := readable(target.Namespace)
+		if err != nil {
+			fail(key, err)
+			continue
+		}
+		if !allowed {
+			fail(key, fmt.Errorf("adopting requires permission to get secrets in %s", target.Namespace))
+			continue
+		}
+		rel, ok := byKey[key]
+		if !ok {
+			fail(key, fmt.Errorf("no unmanaged Helm release %s", key))
+			continue
+		}
+		hr, err := adoptedRelease(rel, target.RepoURL, repos)
+		if err != nil {
+			fail(key, err)
+			continue
+		}
+		if !req.DryRun {
+			setTriggeredBy(r, hr)
+			if err := s.Client.Create(r.Context(), hr); err != nil {
+				fail(key, err)
+				continue
+			}
+			s.broadcastEvent("created", hr)
+			s.audit(r, "adopted", hr, releaseSummary(hr))
+		}
+		adopted, err := redactedSpec(hr)
+		if err != nil {
+			fail(key, err)
+			continue
+		}
+		result.Adopted = append(result.Adopted, *adopted)
+	}
+	writeJSON(w, result)
+}
+
+// secretsReadable returns canReadSecrets for r, asking once per namespace.
+func (s *WebServer) secretsReadable(r *http.Request) func(namespace string) (bool, error) {
+	known := map[string]bool{}
+	return func(namespace string) (bool, error) {
+		if allowed, ok := known[namespace]; ok {
+			return allowed, nil
+		}
+		allowed, err := s.canReadSecrets(r, namespace)
+		if err != nil {
+			return false, err
+		}
+		known[namespace] = allowed
+		return allowed, nil
+	}
+}
+
+// unmanagedReleases returns the Helm releases no HelmRelease manages, and
+// for each chart name the repository HelmReleases install it from.
+func (s *WebServer) unmanagedReleases(r *http.Request) ([]*release.Release, map[string]string, error) {
+	var list helmv1alpha1.HelmReleaseList
+	if err := s.Client.List(r.Context(), &list); err != nil {
+		return nil, nil, err
+	}
+	managed := make(map[string]bool, len(list.Items))
+	repos := map[string]string{}
+	// Sorted so the inferred repository does not change between requests
+	// when HelmReleases disagree.
+	sort.Slice(list.Items, func(i, j int) bool {
+		a, b := list.Items[i], list.Items[j]
+		return a.Namespace+"/"+a.Name < b.Namespace+"/"+b.Name
+	})
+	for i := range list.Items {
+		hr := &list.Items[i]
+		name, ns := helmReleaseOf(hr)
+		managed[ns+"/"+name] = true
+		if _, ok := repos[hr.Spec.Chart]; !ok && hr.Spec.RepoURL != "" {
+			repos[hr.Spec.Chart] = hr.Spec.RepoURL
+		}
+	}
+
+	releases, err := s.HelmClient.ListReleases(r.Context())
+	if err != nil {
+		return nil, nil, err
+	}
+	var unmanaged []*release.Release
+	for _, rel := range releases {
+		if !managed[rel.Namespace+"/"+rel.Name] {
+			unmanaged = append(unmanaged, rel)
+		}
+	}
+	sort.Slice(unmanaged, func(i, j int) bool {
+		a, b := unmanaged[i], unmanaged[j]
+		return a.Namespace+"/"+a.Name < b.Namespace+"/"+b.Name
+	})
+	return unmanaged, repos, nil
+}
+
+// adoptedRelease builds the HelmRelease that takes over rel, in the
+// release's namespace and named after it.
+func adoptedRelease(rel *release.Release, repoURL string, repos map[string]string) (*helmv1alpha1.HelmRelease, error) {
+	if rel.Chart == nil || rel.Chart.Metadata == nil {
+		return nil, fmt.Errorf("release %s/%s records no chart", rel.Namespace, rel.Name)
+	}
+	chart := rel.Chart.Metadata
+	if repoURL == "" {
+		repoURL = repos[chart.Name]
+	}
+	if repoURL == "" {
+		return nil, fmt.Errorf("repoURL is required: no HelmRelease installs chart %s", chart.Name)
+	}
+	hr := &helmv1alpha1.HelmRelease{
+		TypeMeta: metav1.TypeMeta{APIVersion: helmv1alpha1.GroupVersion.String(), Kind: "HelmRelease"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      rel.Name,
+			Namespace: rel.Namespace,
+		},
+		Spec: helmv1alpha1.HelmReleaseSpec{
+			Chart:           chart.Name,
+			RepoURL:         repoURL,
+			Version:         chart.Version,
+			TargetNamespace: rel.Namespace,
+			ReleaseName:     rel.Name,
+		},
+	}
+	if len(rel.Config) > 0 {
+		raw, err := json.Marshal(rel.Config)
+		if err != nil {
+			return nil, fmt.Errorf("encoding values of %s/%s: %w", rel.Namespace, rel.Name, err)
+		}
+		hr.Spec.Values = &apiextensionsv1.JSON{Raw: raw}
+	}
+	return hr, nil
+}
+
+// redactedSpec returns a copy of hr whose spec.values has its secret-like
+// keys redacted. Helm records no provenance for a release's values, so
+// only the keys show which are secret.
+func redactedSpec(hr *helmv1alpha1.HelmRelease) (*helmv1alpha1.HelmRelease, error) {
+	out := hr.DeepCopy()
+	if out.Spec.Values == nil {
+		return out, nil
+	}
+	var values map[string]interface{}
+	if err := json.Unmarshal(out.Spec.Values.Raw, &values); err != nil {
+		return nil, fmt.Errorf("decoding values of %s/%s: %w", hr.Namespace, hr.Name, err)
+	}
+	redact("", values, nil)
+	raw, err := json.Marshal(values)
+	if err != nil {
+		return nil, fmt.Errorf("encoding values of %s/%s: %w", hr.Namespace, hr.Name, err)
+	}
+	out.Spec.Values.Raw = raw
+	return out, nil
+}
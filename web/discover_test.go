@@ -0,0 +1,121 @@
+package web_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"github.com/example/helm-operator/controllers"
+	"github.com/example/helm-operator/web"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Release discovery API", func() {
+	ctx := context.Background()
+
+	var ts *testServer
+	BeforeEach(func() {
+		helm := &controllers.FakeHelmClient{}
+		// Managed by the HelmRelease below.
+		_, err := helm.Install(ctx, "web", "nginx", "", "1.0.0", "team-a", nil, controllers.InstallOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		// Installed with the helm CLI.
+		_, err = helm.Install(ctx, "proxy", "nginx", "", "1.2.0", "team-b", map[string]interface{}{
+			"replicaCount": 3,
+			"auth":         map[string]interface{}{"password": "hunter2"},
+		}, controllers.InstallOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		_, err = helm.Install(ctx, "cache", "redis", "", "18.0.0", "team-b", nil, controllers.InstallOptions{})
+		Expect(err).NotTo(HaveOccurred())
+
+		ts = startServer([]client.Object{makeHR("team-a", "web")}, func(s *web.WebServer) {
+			s.HelmClient = helm
+			s.Admins = []string{"alice"}
+		})
+	})
+
+	It("lists releases no HelmRelease manages", func() {
+		resp, body := ts.do(http.MethodGet, "/api/discover/releases", nil, "X-Forwarded-User", "alice")
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		var releases []map[string]interface{}
+		Expect(json.Unmarshal(body, &releases)).To(Succeed())
+		Expect(releases).To(HaveLen(2))
+		Expect(releases[0]).To(HaveKeyWithValue("name", "cache"))
+		Expect(releases[0]).NotTo(HaveKey("repoURL"))
+		Expect(releases[1]).To(HaveKeyWithValue("name", "proxy"))
+		Expect(releases[1]).To(HaveKeyWithValue("chartVersion", "1.2.0"))
+		Expect(releases[1]).To(HaveKeyWithValue("repoURL", "https://charts.example.com"))
+	})
+
+	It("hides releases from users who may not read their Secrets", func() {
+		resp, body := ts.do(http.MethodGet, "/api/discover/releases", nil)
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(body).To(MatchJSON(`[]`))
+	})
+
+	It("adopts selected releases", func() {
+		req := map[string]interface{}{"releases": []map[string]string{
+			{"namespace": "team-b", "name": "proxy"},
+			{"namespace": "team-b", "name": "cache"},
+			{"namespace": "team-a", "name": "web"},
+		}}
+		resp, body := ts.do(http.MethodPost, "/api/discover/adopt", req, "X-Forwarded-User", "alice")
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		var result struct {
+			Adopted []helmv1alpha1.HelmRelease `json:"adopted"`
+			Failed  map[string]string          `json:"failed"`
+		}
+		Expect(json.Unmarshal(body, &result)).To(Succeed())
+		Expect(result.Adopted).To(HaveLen(1))
+		Expect(result.Adopted[0].Spec.Values.Raw).To(MatchJSON(`{"replicaCount":3,"auth":{"password":"<redacted>"}}`))
+		Expect(result.Failed).To(HaveKeyWithValue("team-b/cache", ContainSubstring("repoURL is required")))
+		Expect(result.Failed).To(HaveKey("team-a/web"))
+
+		var hr helmv1alpha1.HelmRelease
+		Expect(ts.K8s.Get(ctx, types.NamespacedName{Namespace: "team-b", Name: "proxy"}, &hr)).To(Succeed())
+		Expect(hr.Spec.Chart).To(Equal("nginx"))
+		Expect(hr.Spec.Version).To(Equal("1.2.0"))
+		Expect(hr.Spec.RepoURL).To(Equal("https://charts.example.com"))
+		Expect(hr.Spec.ReleaseName).To(Equal("proxy"))
+		Expect(hr.Spec.TargetNamespace).To(Equal("team-b"))
+		Expect(hr.Spec.Values.Raw).To(MatchJSON(`{"replicaCount":3,"auth":{"password":"hunter2"}}`))
+		Expect(hr.Annotations).To(HaveKeyWithValue(controllers.AnnotationTriggeredBy, "alice"))
+	})
+
+	It("only returns the HelmReleases in a dry run", func() {
+		req := map[string]interface{}{"dryRun": true, "releases": []map[string]string{
+			{"namespace": "team-b", "name": "cache", "repoURL": "oci://registry.example.com/charts"},
+		}}
+		resp, body := ts.do(http.MethodPost, "/api/discover/adopt", req, "X-Forwarded-User", "alice")
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		var result struct {
+			Adopted []helmv1alpha1.HelmRelease `json:"adopted"`
+		}
+		Expect(json.Unmarshal(body, &result)).To(Succeed())
+		Expect(result.Adopted).To(HaveLen(1))
+		Expect(result.Adopted[0].Spec.RepoURL).To(Equal("oci://registry.example.com/charts"))
+
+		var hr helmv1alpha1.HelmRelease
+		Expect(ts.K8s.Get(ctx, types.NamespacedName{Namespace: "team-b", Name: "cache"}, &hr)).NotTo(Succeed())
+	})
+
+	It("refuses to adopt for users who may not read the release's Secrets", func() {
+		req := map[string]interface{}{"dryRun": true, "releases": []map[string]string{
+			{"namespace": "team-b", "name": "proxy"},
+		}}
+		resp, body := ts.do(http.MethodPost, "/api/discover/adopt", req)
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		var result struct {
+			Adopted []helmv1alpha1.HelmRelease `json:"adopted"`
+			Failed  map[string]string          `json:"failed"`
+		}
+		Expect(json.Unmarshal(body, &result)).To(Succeed())
+		Expect(result.Adopted).To(BeEmpty())
+		Expect(result.Failed).To(HaveKeyWithValue("team-b/proxy", ContainSubstring("permission to get secrets in team-b")))
+	})
+})
@@ -0,0 +1,147 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"github.com/example/helm-operator/controllers"
+	helmvalues "github.com/example/helm-operator/values"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// redacted replaces values hidden by /api/helmreleases/effective-values.
+const redacted = "<redacted>"
+
+// secretKey matches the value keys whose values are hidden even when they
+// come from spec.values or a ConfigMap.
+var secretKey = regexp.MustCompile(`(?i)(passw(or)?d|secret|token|api_?key|access_?key|private_?key|credentials?)`)
+
+// handleEffectiveValues serves GET /api/helmreleases/effective-values: the
+// values the controller passes to Helm, after merging valuesFrom and
+// spec.values and applying the operator's rewrites. Values from Secrets and
+// under secret-like keys are redacted unless ?reveal=true is given by an
+// admin or a user Kubernetes RBAC allows to read Secrets in the namespace.
+func (s *WebServer) handleEffectiveValues(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	name, ns := r.URL.Query().Get("name"), r.URL.Query().Get("ns")
+	if name == "" || ns == "" {
+		httpError(w, "query params 'name' and 'ns' are required", http.StatusBadRequest)
+		return
+	}
+	reveal := r.URL.Query().Get("reveal") == "true"
+	if reveal {
+		allowed, err := s.canReadSecrets(r, ns)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if !allowed {
+			httpError(w, fmt.Sprintf("revealing values requires permission to get secrets in %s", ns), http.StatusForbidden)
+			return
+		}
+	}
+
+	var hr helmv1alpha1.HelmRelease
+	if err := s.Client.Get(r.Context(), types.NamespacedName{Name: name, Namespace: ns}, &hr); err != nil {
+		writeError(w, err)
+		return
+	}
+	values, provenance, err := controllers.ComposeValues(r.Context(), s.Client, &hr)
+	switch {
+	case controllers.IsMissingValuesSource(err):
+		httpError(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		writeError(w, err)
+		return
+	}
+	s.Rewrites.RewriteValues(values)
+
+	if reveal {
+		s.audit(r, "reveal-values", &hr, "")
+	} else {
+		redact("", values, provenance)
+	}
+	writeJSON(w, values)
+}
+
+// redact replaces, in place, the values set by a Secret and those under a
+// secret-like key, including keys of objects in lists.
+func redact(prefix string, values map[string]interface{}, provenance helmvalues.Provenance) {
+	for k, v := range values {
+		path := k
+		if prefix != "" {
+			path = prefix + "." + k
+		}
+		if secretKey.MatchString(k) && v != nil {
+			values[k] = redacted
+			continue
+		}
+		if m, ok := v.(map[string]interface{}); ok {
+			redact(path, m, provenance)
+			continue
+		}
+		if strings.HasPrefix(provenance[path], "Secret/") {
+			values[k] = redacted
+			continue
+		}
+		if list, ok := v.([]interface{}); ok {
+			redactList(path, list)
+		}
+	}
+}
+
+// redactList redacts the secret-like keys of the objects in list. Lists are
+// set whole, so their items have no provenance of their own.
+func redactList(path string, list []interface{}) {
+	for i, item := range list {
+		itemPath := fmt.Sprintf("%s[%d]", path, i)
+		switch item := item.(type) {
+		case map[string]interface{}:
+			redact(itemPath, item, nil)
+		case []interface{}:
+			redactList(itemPath, item)
+		}
+	}
+}
+
+// canReadSecrets reports whether the requester may see values from Secrets
+// in namespace: an admin, or a user, as named by the authenticating proxy,
+// whom a SubjectAccessReview allows to get Secrets there. API tokens carry
+// no Kubernetes identity and need the admin scope.
+func (s *WebServer) canReadSecrets(r *http.Request, namespace string) (bool, error) {
+	if s.isAdmin(r) {
+		return true, nil
+	}
+	user := requestUser(r)
+	if user == "" || requestToken(r.Context()) != nil {
+		return false, nil
+	}
+	return s.accessReview(r.Context(), user, authorizationv1.ResourceAttributes{
+		Namespace: namespace,
+		Verb:      "get",
+		Resource:  "secrets",
+	})
+}
+
+// accessReview asks the API server whether user may act as attrs describe.
+func (s *WebServer) accessReview(ctx context.Context, user string, attrs authorizationv1.ResourceAttributes) (bool, error) {
+	review := &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:               user,
+			ResourceAttributes: &attrs,
+		},
+	}
+	if err := s.reviews.Create(ctx, review); err != nil {
+		return false, fmt.Errorf("checking access to %s: %w", attrs.Resource, err)
+	}
+	return review.Status.Allowed, nil
+}
@@ -0,0 +1,100 @@
+package web_test
+
+import (
+	"encoding/json"
+	"net/http"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"github.com/example/helm-operator/web"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Effective values API", func() {
+	const path = "/api/helmreleases/effective-values?ns=team-a&name=web"
+
+	var ts *testServer
+	BeforeEach(func() {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "team-a"},
+			Data:       map[string][]byte{"values.yaml": []byte("db:\n  host: db.internal\n")},
+		}
+		hr := makeHR("team-a", "web")
+		hr.Spec.ValuesFrom = []helmv1alpha1.ValuesReference{{Kind: "Secret", Name: "db"}}
+		hr.Spec.Values = &apiextensionsv1.JSON{Raw: []byte(`{"replicaCount":2,"auth":{"adminPassword":"hunter2","user":"admin"}}`)}
+		ts = startServer([]client.Object{secret, hr}, func(s *web.WebServer) {
+			s.Admins = []string{"alice"}
+		})
+	})
+
+	effective := func(path string, headers ...string) (int, map[string]interface{}) {
+		resp, body := ts.do(http.MethodGet, path, nil, headers...)
+		var values map[string]interface{}
+		if resp.StatusCode == http.StatusOK {
+			Expect(json.Unmarshal(body, &values)).To(Succeed())
+		}
+		return resp.StatusCode, values
+	}
+
+	It("redacts values from Secrets and under secret-like keys", func() {
+		status, values := effective(path)
+		Expect(status).To(Equal(http.StatusOK))
+		Expect(values).To(Equal(map[string]interface{}{
+			"replicaCount": float64(2),
+			"auth":         map[string]interface{}{"adminPassword": "<redacted>", "user": "admin"},
+			"db":           map[string]interface{}{"host": "<redacted>"},
+		}))
+	})
+
+	It("redacts secret-like keys of objects in lists", func() {
+		hr := makeHR("team-a", "listed")
+		hr.Spec.Values = &apiextensionsv1.JSON{Raw: []byte(
+			`{"users":[{"name":"ci","token":"t0k3n"},{"name":"ops"}],"matrix":[[{"apiKey":"k3y"}]],"hosts":["a","b"]}`)}
+		ts = startServer([]client.Object{hr})
+
+		status, values := effective("/api/helmreleases/effective-values?ns=team-a&name=listed")
+		Expect(status).To(Equal(http.StatusOK))
+		Expect(values).To(Equal(map[string]interface{}{
+			"users": []interface{}{
+				map[string]interface{}{"name": "ci", "token": "<redacted>"},
+				map[string]interface{}{"name": "ops"},
+			},
+			"matrix": []interface{}{[]interface{}{map[string]interface{}{"apiKey": "<redacted>"}}},
+			"hosts":  []interface{}{"a", "b"},
+		}))
+	})
+
+	It("reveals values to admins", func() {
+		status, values := effective(path+"&reveal=true", "X-Forwarded-User", "alice")
+		Expect(status).To(Equal(http.StatusOK))
+		Expect(values).To(HaveKeyWithValue("db", map[string]interface{}{"host": "db.internal"}))
+		Expect(values).To(HaveKeyWithValue("auth", HaveKeyWithValue("adminPassword", "hunter2")))
+	})
+
+	It("refuses to reveal values to anonymous users", func() {
+		status, _ := effective(path + "&reveal=true")
+		Expect(status).To(Equal(http.StatusForbidden))
+	})
+
+	It("redacts values templated with variables from Secrets", func() {
+		vars := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "vars", Namespace: "team-a"},
+			Data:       map[string][]byte{"DSN": []byte("postgres://app:hunter2@db")},
+		}
+		hr := makeHR("team-a", "templated")
+		hr.Spec.ValuesTemplate = "database:\n  url: ${DSN}\n"
+		hr.Spec.SubstituteFrom = []helmv1alpha1.SubstituteReference{{Kind: "Secret", Name: "vars"}}
+		ts = startServer([]client.Object{vars, hr})
+
+		status, values := effective("/api/helmreleases/effective-values?ns=team-a&name=templated")
+		Expect(status).To(Equal(http.StatusOK))
+		Expect(values).To(Equal(map[string]interface{}{
+			"database": map[string]interface{}{"url": "<redacted>"},
+		}))
+	})
+})
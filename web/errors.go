@@ -0,0 +1,140 @@
+package web
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// RequestIDHeader carries the ID of an API request. A client or proxy may
+// set it; otherwise the server assigns one. Either way it is returned in the
+// response and in error bodies, and logged.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds request IDs taken from clients.
+const maxRequestIDLength = 128
+
+// apiProblem is the body of every API error response.
+type apiProblem struct {
+	// Code is the HTTP status in snake case, such as "not_found".
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// Details describes a failed Kubernetes API call: its reason and the
+	// causes the API server gave.
+	Details   *problemDetails `json:"details,omitempty"`
+	RequestID string          `json:"requestID,omitempty"`
+}
+
+type problemDetails struct {
+	Reason metav1.StatusReason  `json:"reason,omitempty"`
+	Causes []metav1.StatusCause `json:"causes,omitempty"`
+}
+
+// httpError writes message as an apiProblem with the status code. It takes
+// the arguments of http.Error.
+func httpError(w http.ResponseWriter, message string, code int) {
+	writeProblem(w, apiProblem{Message: message}, code)
+}
+
+// writeError writes err as an apiProblem, with the status and details of a
+// Kubernetes API error.
+func writeError(w http.ResponseWriter, err error) {
+	problem := apiProblem{Message: err.Error()}
+	var status apierrors.APIStatus
+	if errors.As(err, &status) {
+		s := status.Status()
+		problem.Details = &problemDetails{Reason: s.Reason}
+		if s.Details != nil {
+			problem.Details.Causes = s.Details.Causes
+		}
+	}
+	writeProblem(w, problem, apiErrorStatus(err))
+}
+
+func writeProblem(w http.ResponseWriter, problem apiProblem, code int) {
+	problem.Code = strings.ToLower(strings.ReplaceAll(http.StatusText(code), " ", "_"))
+	problem.RequestID = w.Header().Get(RequestIDHeader)
+	h := w.Header()
+	h.Del("Content-Length")
+	h.Set("Content-Type", "application/json")
+	h.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(problem)
+}
+
+// withRequestID assigns every request an ID, returns it in RequestIDHeader,
+// adds it to the request's logger and logs the request once it is served.
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		log := ctrl.Log.WithName("web").WithValues("requestID", id)
+		r = r.WithContext(ctrl.LoggerInto(r.Context(), log))
+
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		if strings.HasPrefix(r.URL.Path, "/api/") {
+			log := log.WithValues("method", r.Method, "path", r.URL.Path, "status", rec.status,
+				"duration", time.Since(start).Round(time.Millisecond))
+			if rec.status >= http.StatusInternalServerError {
+				log.Info("API request failed")
+			} else {
+				log.V(1).Info("API request")
+			}
+		}
+	})
+}
+
+// validRequestID reports whether id, taken from a client, is safe to log
+// and return.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if c < '!' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// statusRecorder records the status of a response. It keeps the
+// http.Flusher of the writer it wraps for the streaming endpoints.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.status = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
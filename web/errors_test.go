@@ -0,0 +1,86 @@
+package web_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/example/helm-operator/web"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+var _ = Describe("API errors", func() {
+	type problem struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+		Details *struct {
+			Reason string `json:"reason"`
+		} `json:"details"`
+		RequestID string `json:"requestID"`
+	}
+	decode := func(body []byte) problem {
+		var p problem
+		Expect(json.Unmarshal(body, &p)).To(Succeed())
+		return p
+	}
+
+	It("returns errors as JSON with a request ID", func() {
+		ts := startServer(nil)
+
+		resp, body := ts.do(http.MethodGet, "/api/helmreleases/values?ns=default", nil)
+		Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+		Expect(resp.Header.Get("Content-Type")).To(Equal("application/json"))
+		p := decode(body)
+		Expect(p.Code).To(Equal("bad_request"))
+		Expect(p.Message).To(ContainSubstring("required"))
+		Expect(p.RequestID).NotTo(BeEmpty())
+		Expect(resp.Header.Get(web.RequestIDHeader)).To(Equal(p.RequestID))
+	})
+
+	It("keeps the request ID a client sends", func() {
+		ts := startServer(nil)
+
+		resp, body := ts.do(http.MethodGet, "/api/helmreleases/values-provenance?ns=default&name=missing", nil,
+			web.RequestIDHeader, "support-4711")
+		Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+		Expect(resp.Header.Get(web.RequestIDHeader)).To(Equal("support-4711"))
+		p := decode(body)
+		Expect(p.Code).To(Equal("not_found"))
+		Expect(p.RequestID).To(Equal("support-4711"))
+		Expect(p.Details).NotTo(BeNil())
+		Expect(p.Details.Reason).To(Equal("NotFound"))
+	})
+
+	It("keeps the status of Kubernetes API errors", func() {
+		ts := startServer(nil, func(s *web.WebServer) {
+			s.Client = interceptor.NewClient(s.Client.(client.WithWatch), interceptor.Funcs{
+				Get: func(context.Context, client.WithWatch, client.ObjectKey, client.Object, ...client.GetOption) error {
+					return apierrors.NewForbidden(schema.GroupResource{Group: "helm.example.com", Resource: "helmreleases"},
+						"web", errors.New("access denied"))
+				},
+			})
+		})
+
+		resp, body := ts.do(http.MethodGet, "/api/helmreleases/values?ns=default&name=web", nil)
+		Expect(resp.StatusCode).To(Equal(http.StatusForbidden))
+		p := decode(body)
+		Expect(p.Code).To(Equal("forbidden"))
+		Expect(p.Details).NotTo(BeNil())
+		Expect(p.Details.Reason).To(Equal("Forbidden"))
+	})
+
+	It("replaces request IDs that are not printable", func() {
+		ts := startServer(nil)
+
+		resp, _ := ts.do(http.MethodGet, "/api/version", nil, web.RequestIDHeader, "a b")
+		Expect(resp.Header.Get(web.RequestIDHeader)).NotTo(Equal("a b"))
+		Expect(resp.Header.Get(web.RequestIDHeader)).To(HaveLen(32))
+	})
+})
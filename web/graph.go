@@ -0,0 +1,124 @@
+package web
+
+import (
+	"net/http"
+	"sort"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"github.com/example/helm-operator/controllers"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Node kinds and edge types of GET /api/graph.
+const (
+	nodeRelease   = "HelmRelease"
+	nodeNamespace = "Namespace"
+
+	edgeDependsOn       = "dependsOn"
+	edgeTargetNamespace = "targetNamespace"
+)
+
+// graphNode is a HelmRelease, or a target namespace shared by releases.
+type graphNode struct {
+	// ID is "<namespace>/<name>" for releases and "ns:<name>" for namespaces.
+	ID        string `json:"id"`
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+	Phase     string `json:"phase,omitempty"`
+	Chart     string `json:"chart,omitempty"`
+	// Missing marks a release named in dependsOn that does not exist.
+	Missing bool `json:"missing,omitempty"`
+}
+
+// graphEdge points from a release to what it depends on or installs into.
+type graphEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Type string `json:"type"`
+}
+
+// graph is the body of GET /api/graph.
+type graph struct {
+	Nodes []graphNode `json:"nodes"`
+	Edges []graphEdge `json:"edges"`
+	// Cycles lists dependsOn loops, each as release IDs in dependency order.
+	Cycles [][]string `json:"cycles"`
+	// Errors holds a validation message per cycle.
+	Errors []string `json:"errors"`
+}
+
+// handleGraph serves GET /api/graph: the HelmReleases as a graph, with an
+// edge for every dependsOn reference and a namespace node linking releases
+// that install into the same target namespace. ?ns= limits the releases to
+// one namespace; dependencies outside it still appear as nodes.
+func (s *WebServer) handleGraph(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var opts []client.ListOption
+	if ns := r.URL.Query().Get("ns"); ns != "" {
+		opts = append(opts, client.InNamespace(ns))
+	}
+	var list helmv1alpha1.HelmReleaseList
+	if err := s.Client.List(r.Context(), &list, opts...); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, buildGraph(list.Items))
+}
+
+// buildGraph lays out releases as a graph; see handleGraph.
+func buildGraph(releases []helmv1alpha1.HelmRelease) graph {
+	sort.Slice(releases, func(i, j int) bool {
+		if releases[i].Namespace != releases[j].Namespace {
+			return releases[i].Namespace < releases[j].Namespace
+		}
+		return releases[i].Name < releases[j].Name
+	})
+
+	g := graph{Nodes: []graphNode{}, Edges: []graphEdge{}, Cycles: [][]string{}, Errors: []string{}}
+	nodes := map[string]bool{}
+	for _, hr := range releases {
+		id := types.NamespacedName{Namespace: hr.Namespace, Name: hr.Name}.String()
+		nodes[id] = true
+		g.Nodes = append(g.Nodes, graphNode{
+			ID: id, Kind: nodeRelease, Name: hr.Name, Namespace: hr.Namespace,
+			Phase: string(hr.Status.Phase), Chart: hr.Spec.Chart,
+		})
+	}
+
+	for i := range releases {
+		hr := &releases[i]
+		id := types.NamespacedName{Namespace: hr.Namespace, Name: hr.Name}.String()
+		for _, dep := range controllers.Dependencies(hr) {
+			if !nodes[dep.String()] {
+				nodes[dep.String()] = true
+				g.Nodes = append(g.Nodes, graphNode{
+					ID: dep.String(), Kind: nodeRelease, Name: dep.Name, Namespace: dep.Namespace, Missing: true,
+				})
+			}
+			g.Edges = append(g.Edges, graphEdge{From: id, To: dep.String(), Type: edgeDependsOn})
+		}
+		if ns := hr.Spec.TargetNamespace; ns != "" {
+			nsID := "ns:" + ns
+			if !nodes[nsID] {
+				nodes[nsID] = true
+				g.Nodes = append(g.Nodes, graphNode{ID: nsID, Kind: nodeNamespace, Name: ns})
+			}
+			g.Edges = append(g.Edges, graphEdge{From: id, To: nsID, Type: edgeTargetNamespace})
+		}
+	}
+
+	for _, cycle := range controllers.DependencyCycles(releases) {
+		ids := make([]string, 0, len(cycle))
+		for _, key := range cycle {
+			ids = append(ids, key.String())
+		}
+		g.Cycles = append(g.Cycles, ids)
+		g.Errors = append(g.Errors, controllers.DependencyCycleError(cycle))
+	}
+	return g
+}
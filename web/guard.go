@@ -0,0 +1,176 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/example/helm-operator/store"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type requestContextKey struct{}
+
+// contextRequest returns the web API request ctx belongs to, as recorded by
+// authenticate; nil outside a request.
+func contextRequest(ctx context.Context) *http.Request {
+	r, _ := ctx.Value(requestContextKey{}).(*http.Request)
+	return r
+}
+
+// guardedClient checks every write the web API makes: an API token needs a
+// write scope for the object's namespace, and the authorization webhook, if
+// configured, must allow the change. Writes outside a request, such as the
+// token Secret's, are not checked.
+type guardedClient struct {
+	client.Client
+	authz *AuthzWebhook
+}
+
+func (c guardedClient) check(ctx context.Context, verb string, obj client.Object) error {
+	r := contextRequest(ctx)
+	if r == nil {
+		return nil
+	}
+	gvk, _ := c.GroupVersionKindFor(obj)
+	forbidden := func(reason string) error {
+		resource := schema.GroupResource{Group: gvk.Group, Resource: strings.ToLower(gvk.Kind)}
+		return apierrors.NewForbidden(resource, obj.GetName(), fmt.Errorf("%s", reason))
+	}
+	if t := requestToken(ctx); t != nil && !t.canWrite(obj.GetNamespace()) {
+		return forbidden(fmt.Sprintf("token %q has no write scope for namespace %q", t.Name, obj.GetNamespace()))
+	}
+	if c.authz != nil {
+		allowed, reason := c.authz.authorize(ctx, AuthzRequest{
+			User:      requestUser(r),
+			Verb:      verb,
+			Kind:      gvk.Kind,
+			Namespace: obj.GetNamespace(),
+			Release:   obj.GetName(),
+			Method:    r.Method,
+			Path:      r.URL.Path,
+		})
+		if !allowed {
+			if reason == "" {
+				reason = "denied by the authorization webhook"
+			}
+			return forbidden(reason)
+		}
+	}
+	return nil
+}
+
+func (c guardedClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	if err := c.check(ctx, "create", obj); err != nil {
+		return err
+	}
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func (c guardedClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	if err := c.check(ctx, "update", obj); err != nil {
+		return err
+	}
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func (c guardedClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if err := c.check(ctx, "patch", obj); err != nil {
+		return err
+	}
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+func (c guardedClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	if err := c.check(ctx, "delete", obj); err != nil {
+		return err
+	}
+	return c.Client.Delete(ctx, obj, opts...)
+}
+
+// DeleteAllOf is refused within requests: there is no single object to
+// check. The web API does not use it.
+func (c guardedClient) DeleteAllOf(ctx context.Context, obj client.Object, opts ...client.DeleteAllOfOption) error {
+	if contextRequest(ctx) != nil {
+		return apierrors.NewForbidden(schema.GroupResource{}, "", fmt.Errorf("deleting collections is not allowed through the web API"))
+	}
+	return c.Client.DeleteAllOf(ctx, obj, opts...)
+}
+
+func (c guardedClient) Status() client.SubResourceWriter {
+	return guardedSubResourceWriter{c.Client.Status(), c}
+}
+
+func (c guardedClient) SubResource(subResource string) client.SubResourceClient {
+	return guardedSubResourceClient{c.Client.SubResource(subResource), c}
+}
+
+type guardedSubResourceWriter struct {
+	client.SubResourceWriter
+	c guardedClient
+}
+
+func (w guardedSubResourceWriter) Create(ctx context.Context, obj client.Object, subResource client.Object, opts ...client.SubResourceCreateOption) error {
+	if err := w.c.check(ctx, "create", obj); err != nil {
+		return err
+	}
+	return w.SubResourceWriter.Create(ctx, obj, subResource, opts...)
+}
+
+func (w guardedSubResourceWriter) Update(ctx context.Context, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+	if err := w.c.check(ctx, "update", obj); err != nil {
+		return err
+	}
+	return w.SubResourceWriter.Update(ctx, obj, opts...)
+}
+
+func (w guardedSubResourceWriter) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+	if err := w.c.check(ctx, "patch", obj); err != nil {
+		return err
+	}
+	return w.SubResourceWriter.Patch(ctx, obj, patch, opts...)
+}
+
+type guardedSubResourceClient struct {
+	client.SubResourceClient
+	c guardedClient
+}
+
+func (sc guardedSubResourceClient) Create(ctx context.Context, obj client.Object, subResource client.Object, opts ...client.SubResourceCreateOption) error {
+	if err := sc.c.check(ctx, "create", obj); err != nil {
+		return err
+	}
+	return sc.SubResourceClient.Create(ctx, obj, subResource, opts...)
+}
+
+func (sc guardedSubResourceClient) Update(ctx context.Context, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+	if err := sc.c.check(ctx, "update", obj); err != nil {
+		return err
+	}
+	return sc.SubResourceClient.Update(ctx, obj, opts...)
+}
+
+func (sc guardedSubResourceClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+	if err := sc.c.check(ctx, "patch", obj); err != nil {
+		return err
+	}
+	return sc.SubResourceClient.Patch(ctx, obj, patch, opts...)
+}
+
+// apiErrorStatus returns the HTTP status for an error from the Kubernetes
+// API, such as 403 for a change the guarded client refused, or from the
+// store. Other errors are 500.
+func apiErrorStatus(err error) int {
+	var status apierrors.APIStatus
+	if errors.As(err, &status) && status.Status().Code != 0 {
+		return int(status.Status().Code)
+	}
+	if errors.Is(err, store.ErrNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,240 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/release"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/yaml"
+)
+
+// helmCommandRequest is the body of POST /api/admin/helm.
+type helmCommandRequest struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	// Command is a read-only helm command without the release name, such
+	// as "get values --all" or "status --revision 3".
+	Command string `json:"command"`
+}
+
+// helmCommand is a parsed helmCommandRequest.Command.
+type helmCommand struct {
+	name     string // "status", "history", "get values" or "get manifest"
+	all      bool   // get values --all
+	revision int    // --revision; 0 for the latest
+}
+
+// parseHelmCommand parses one of the read-only commands the break-glass
+// endpoint runs. Everything else is refused.
+func parseHelmCommand(line string) (helmCommand, error) {
+	args := strings.Fields(line)
+	if len(args) > 0 && args[0] == "helm" {
+		args = args[1:]
+	}
+	var cmd helmCommand
+	switch {
+	case len(args) >= 1 && (args[0] == "status" || args[0] == "history"):
+		cmd.name, args = args[0], args[1:]
+	case len(args) >= 2 && args[0] == "get" && (args[1] == "values" || args[1] == "manifest"):
+		cmd.name, args = "get "+args[1], args[2:]
+	default:
+		return cmd, errors.New("command must be one of: status, history, get values, get manifest")
+	}
+	for len(args) > 0 {
+		flag, value, hasValue := strings.Cut(args[0], "=")
+		args = args[1:]
+		switch {
+		case (flag == "--all" || flag == "-a") && !hasValue && cmd.name == "get values":
+			cmd.all = true
+		case flag == "--revision" && cmd.name != "history":
+			if !hasValue {
+				if len(args) == 0 {
+					return cmd, errors.New("--revision needs a value")
+				}
+				value, args = args[0], args[1:]
+			}
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 {
+				return cmd, fmt.Errorf("invalid revision %q", value)
+			}
+			cmd.revision = n
+		default:
+			return cmd, fmt.Errorf("unsupported argument %q for %s", flag, cmd.name)
+		}
+	}
+	return cmd, nil
+}
+
+// handleHelmCommand serves POST /api/admin/helm: it runs a read-only helm
+// command against the release of a HelmRelease with the operator's own Helm
+// configuration and writes the output as text, like the helm CLI would.
+// It is meant for break-glass debugging without shell access to the
+// cluster, so it is limited to admins and every command is audited.
+func (s *WebServer) handleHelmCommand(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		httpError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.isAdmin(r) {
+		httpError(w, "forbidden: helm commands require an admin", http.StatusForbidden)
+		return
+	}
+	if s.HelmClient == nil {
+		httpError(w, "helm commands are not available", http.StatusServiceUnavailable)
+		return
+	}
+	var req helmCommandRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpError(w, "invalid JSON body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Namespace == "" || req.Name == "" {
+		httpError(w, "namespace and name are required", http.StatusBadRequest)
+		return
+	}
+	cmd, err := parseHelmCommand(req.Command)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var hr helmv1alpha1.HelmRelease
+	if err := s.Client.Get(r.Context(), types.NamespacedName{Namespace: req.Namespace, Name: req.Name}, &hr); err != nil {
+		writeError(w, err)
+		return
+	}
+	if hr.Spec.KubeConfig != nil {
+		httpError(w, "helm commands are not available for releases in remote clusters", http.StatusBadRequest)
+		return
+	}
+	releaseName, releaseNS := helmReleaseOf(&hr)
+	history, err := s.HelmClient.History(r.Context(), releaseName, releaseNS)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if len(history) == 0 {
+		httpError(w, fmt.Sprintf("release %s/%s has no revisions", releaseNS, releaseName), http.StatusNotFound)
+		return
+	}
+	rel := history[0]
+	for _, h := range history {
+		if cmd.revision == 0 && h.Version > rel.Version || cmd.revision != 0 && h.Version == cmd.revision {
+			rel = h
+		}
+	}
+	if cmd.revision != 0 && rel.Version != cmd.revision {
+		httpError(w, fmt.Sprintf("release %s/%s has no revision %d", releaseNS, releaseName, cmd.revision), http.StatusNotFound)
+		return
+	}
+	s.audit(r, "helm", &hr, "helm "+cmd.name+" "+releaseName)
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	out := &flushWriter{w: w}
+	switch cmd.name {
+	case "status":
+		err = writeHelmStatus(out, rel)
+	case "history":
+		err = writeHelmHistory(out, history)
+	case "get values":
+		err = writeHelmValues(out, rel, cmd.all)
+	case "get manifest":
+		_, err = io.WriteString(out, rel.Manifest)
+	}
+	if err != nil {
+		// The status is sent; end the output with the error instead.
+		fmt.Fprintf(out, "\nError: %v\n", err)
+	}
+}
+
+// flushWriter flushes every write, so long output reaches the client as it
+// is written.
+type flushWriter struct {
+	w http.ResponseWriter
+}
+
+func (f *flushWriter) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	if fl, ok := f.w.(http.Flusher); ok {
+		fl.Flush()
+	}
+	return n, err
+}
+
+// writeHelmStatus writes rel like helm status.
+func writeHelmStatus(w io.Writer, rel *release.Release) error {
+	fmt.Fprintf(w, "NAME: %s\n", rel.Name)
+	if rel.Info != nil {
+		fmt.Fprintf(w, "LAST DEPLOYED: %s\n", rel.Info.LastDeployed.Format(time.ANSIC))
+	}
+	fmt.Fprintf(w, "NAMESPACE: %s\n", rel.Namespace)
+	if rel.Info != nil {
+		fmt.Fprintf(w, "STATUS: %s\n", rel.Info.Status)
+	}
+	fmt.Fprintf(w, "REVISION: %d\n", rel.Version)
+	if rel.Info != nil {
+		if rel.Info.Description != "" {
+			fmt.Fprintf(w, "DESCRIPTION: %s\n", rel.Info.Description)
+		}
+		if rel.Info.Notes != "" {
+			fmt.Fprintf(w, "NOTES:\n%s\n", strings.TrimSpace(rel.Info.Notes))
+		}
+	}
+	return nil
+}
+
+// writeHelmHistory writes the revisions like helm history, oldest first.
+func writeHelmHistory(w io.Writer, history []*release.Release) error {
+	sorted := append([]*release.Release(nil), history...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+	tw := tabwriter.NewWriter(w, 0, 0, 1, ' ', 0)
+	fmt.Fprintln(tw, "REVISION\tUPDATED\tSTATUS\tCHART\tAPP VERSION\tDESCRIPTION")
+	for _, rel := range sorted {
+		var updated, status, description, chart, appVersion string
+		if rel.Info != nil {
+			updated = rel.Info.LastDeployed.Format(time.ANSIC)
+			status, description = rel.Info.Status.String(), rel.Info.Description
+		}
+		if rel.Chart != nil && rel.Chart.Metadata != nil {
+			chart = rel.Chart.Metadata.Name + "-" + rel.Chart.Metadata.Version
+			appVersion = rel.Chart.Metadata.AppVersion
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\n", rel.Version, updated, status, chart, appVersion, description)
+	}
+	return tw.Flush()
+}
+
+// writeHelmValues writes the values of rel like helm get values: the
+// user-supplied ones, or with all the chart's defaults merged in.
+func writeHelmValues(w io.Writer, rel *release.Release, all bool) error {
+	values, header := rel.Config, "USER-SUPPLIED VALUES:"
+	if all && rel.Chart != nil {
+		merged, err := chartutil.CoalesceValues(rel.Chart, rel.Config)
+		if err != nil {
+			return err
+		}
+		values, header = merged, "COMPUTED VALUES:"
+	}
+	out, err := yaml.Marshal(values)
+	if err != nil {
+		return err
+	}
+	if len(values) == 0 {
+		out = []byte("null\n")
+	}
+	fmt.Fprintln(w, header)
+	_, err = w.Write(out)
+	return err
+}
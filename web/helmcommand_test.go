@@ -0,0 +1,81 @@
+package web_test
+
+import (
+	"context"
+	"net/http"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/example/helm-operator/controllers"
+	"github.com/example/helm-operator/store"
+	"github.com/example/helm-operator/web"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Helm command API", func() {
+	ctx := context.Background()
+
+	var ts *testServer
+	BeforeEach(func() {
+		helm := &controllers.FakeHelmClient{}
+		_, err := helm.Install(ctx, "web", "nginx", "", "1.0.0", "team-a", map[string]interface{}{"replicaCount": 3}, controllers.InstallOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		ts = startServer([]client.Object{makeHR("team-a", "web")}, func(s *web.WebServer) {
+			s.HelmClient = helm
+			s.Admins = []string{"alice"}
+		})
+	})
+
+	run := func(command string, user string) (*http.Response, string) {
+		resp, body := ts.do(http.MethodPost, "/api/admin/helm",
+			map[string]string{"namespace": "team-a", "name": "web", "command": command}, "X-Forwarded-User", user)
+		return resp, string(body)
+	}
+
+	It("runs read-only helm commands for admins", func() {
+		resp, out := run("get values", "alice")
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(resp.Header.Get("Content-Type")).To(HavePrefix("text/plain"))
+		Expect(out).To(Equal("USER-SUPPLIED VALUES:\nreplicaCount: 3\n"))
+
+		resp, out = run("helm status", "alice")
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(out).To(ContainSubstring("NAME: web\n"))
+		Expect(out).To(ContainSubstring("REVISION: 1\n"))
+
+		resp, out = run("history", "alice")
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(out).To(HavePrefix("REVISION"))
+		Expect(out).To(ContainSubstring("nginx-1.0.0"))
+
+		entries, err := ts.Store.ListAudit(ctx, store.ListOptions{Namespace: "team-a", Name: "web"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(HaveLen(3))
+		Expect(entries[0].Action).To(Equal("helm"))
+		Expect(entries[0].User).To(Equal("alice"))
+	})
+
+	It("refuses users who are not admins", func() {
+		resp, _ := run("status", "bob")
+		Expect(resp.StatusCode).To(Equal(http.StatusForbidden))
+	})
+
+	DescribeTable("refuses commands outside the allowlist",
+		func(command string) {
+			resp, _ := run(command, "alice")
+			Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+		},
+		Entry("uninstall", "uninstall"),
+		Entry("rollback", "rollback 1"),
+		Entry("get all", "get all"),
+		Entry("unknown flag", "status --kube-context other"),
+		Entry("all for manifests", "get manifest --all"),
+		Entry("invalid revision", "get manifest --revision=abc"),
+	)
+
+	It("reports revisions the release does not have", func() {
+		resp, _ := run("get manifest --revision 7", "alice")
+		Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+	})
+})
@@ -0,0 +1,158 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"github.com/example/helm-operator/controllers"
+	"github.com/example/helm-operator/store"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// userHeaders are set by authenticating proxies (oauth2-proxy, kube-rbac-proxy,
+// ...) in front of the UI and identify who made a change.
+var userHeaders = []string{"X-Forwarded-User", "X-Remote-User", "X-Auth-Request-User"}
+
+// audit records a change made through the UI. Failures are logged only; the
+// change itself has already been applied.
+func (s *WebServer) audit(r *http.Request, action string, hr *helmv1alpha1.HelmRelease, detail string) {
+	entry := store.AuditEntry{User: requestUser(r), Action: action, Namespace: hr.Namespace, Name: hr.Name, Detail: detail}
+	if err := s.Store.AppendAudit(r.Context(), entry); err != nil {
+		ctrl.Log.Error(err, "Recording audit entry", "action", action, "namespace", hr.Namespace, "name", hr.Name)
+	}
+}
+
+// requestUser returns the user named by the authenticating proxy, if any.
+func requestUser(r *http.Request) string {
+	for _, h := range userHeaders {
+		if user := r.Header.Get(h); user != "" {
+			return user
+		}
+	}
+	return ""
+}
+
+// setTriggeredBy records the requesting user on hr so the controller can
+// attribute the resulting Helm revision. Anonymous changes remove a user
+// left by an earlier change.
+func setTriggeredBy(r *http.Request, hr *helmv1alpha1.HelmRelease) {
+	setTriggeredByUser(hr, requestUser(r))
+}
+
+// setTriggeredByUser records user on hr, or removes the annotation if user
+// is empty.
+func setTriggeredByUser(hr *helmv1alpha1.HelmRelease, user string) {
+	if user == "" {
+		delete(hr.Annotations, controllers.AnnotationTriggeredBy)
+		return
+	}
+	if hr.Annotations == nil {
+		hr.Annotations = map[string]string{}
+	}
+	hr.Annotations[controllers.AnnotationTriggeredBy] = user
+}
+
+// listOptions reads the ?ns=&name=&limit= filters shared by the history endpoints.
+func listOptions(r *http.Request) (store.ListOptions, error) {
+	q := r.URL.Query()
+	opts := store.ListOptions{Namespace: q.Get("ns"), Name: q.Get("name")}
+	if l := q.Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 {
+			return opts, errors.New("invalid limit: must be a positive integer")
+		}
+		opts.Limit = n
+	}
+	return opts, nil
+}
+
+// handleAudit lists changes made through the UI, newest first.
+func (s *WebServer) handleAudit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	opts, err := listOptions(r)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	entries, err := s.Store.ListAudit(r.Context(), opts)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if entries == nil {
+		entries = []store.AuditEntry{}
+	}
+	writeJSON(w, entries)
+}
+
+// handleDiagnoses lists past AI diagnoses, newest first.
+func (s *WebServer) handleDiagnoses(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	opts, err := listOptions(r)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	diagnoses, err := s.Store.ListDiagnoses(r.Context(), opts)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if diagnoses == nil {
+		diagnoses = []store.Diagnosis{}
+	}
+	writeJSON(w, diagnoses)
+}
+
+// handleViews routes GET/PUT/DELETE for /api/views. PUT takes a View body and
+// replaces any view with the same name; DELETE takes ?name=.
+func (s *WebServer) handleViews(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		views, err := s.Store.ListViews(r.Context())
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, views)
+	case http.MethodPut:
+		var v store.View
+		if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+			httpError(w, "invalid JSON body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if v.Name == "" {
+			httpError(w, "name is required", http.StatusBadRequest)
+			return
+		}
+		v.UpdatedAt = time.Time{}
+		if err := s.Store.SaveView(r.Context(), v); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		name := r.URL.Query().Get("name")
+		if name == "" {
+			httpError(w, "query param 'name' is required", http.StatusBadRequest)
+			return
+		}
+		if err := s.Store.DeleteView(r.Context(), name); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		httpError(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
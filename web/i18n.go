@@ -0,0 +1,135 @@
+package web
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultLocale is the locale served when the browser accepts none of the
+// bundled ones.
+const DefaultLocale = "en"
+
+// localeFS holds one message bundle per locale, named <locale>.json. A
+// bundle maps message keys to text; keys it lacks fall back to the default
+// locale, so a language pack can be added incrementally.
+//
+//go:embed locales/*.json
+var localeFS embed.FS
+
+// locales are the parsed bundles, keyed by lower-case locale tag.
+var locales = mustLoadLocales()
+
+func mustLoadLocales() map[string]map[string]string {
+	files, err := localeFS.ReadDir("locales")
+	if err != nil {
+		panic(err)
+	}
+	out := map[string]map[string]string{}
+	for _, f := range files {
+		data, err := localeFS.ReadFile(path.Join("locales", f.Name()))
+		if err != nil {
+			panic(err)
+		}
+		var messages map[string]string
+		if err := json.Unmarshal(data, &messages); err != nil {
+			panic(fmt.Sprintf("web: locale bundle %s: %v", f.Name(), err))
+		}
+		out[strings.ToLower(strings.TrimSuffix(f.Name(), ".json"))] = messages
+	}
+	return out
+}
+
+// availableLocales returns the bundled locale tags, sorted.
+func availableLocales() []string {
+	tags := make([]string, 0, len(locales))
+	for tag := range locales {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// negotiateLocale picks the bundled locale that best matches an
+// Accept-Language header, in order of quality. A regional tag such as de-AT
+// also matches its language's bundle. fallback is returned when nothing
+// matches.
+func negotiateLocale(acceptLanguage, fallback string) string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	var prefs []weighted
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if tag == "" || tag == "*" || q <= 0 {
+			continue
+		}
+		prefs = append(prefs, weighted{strings.ToLower(tag), q})
+	}
+	sort.SliceStable(prefs, func(i, j int) bool { return prefs[i].q > prefs[j].q })
+
+	for _, p := range prefs {
+		if _, ok := locales[p.tag]; ok {
+			return p.tag
+		}
+		if base, _, ok := strings.Cut(p.tag, "-"); ok {
+			if _, ok := locales[base]; ok {
+				return base
+			}
+		}
+	}
+	return fallback
+}
+
+// defaultLocale returns DefaultLocale of s, falling back to the package's.
+func (s *WebServer) defaultLocale() string {
+	if s.DefaultLocale == "" {
+		return DefaultLocale
+	}
+	return strings.ToLower(s.DefaultLocale)
+}
+
+// handleI18n serves GET /api/i18n: the message bundle for ?lang=, or else
+// for the browser's Accept-Language, merged over the default locale's.
+func (s *WebServer) handleI18n(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	fallback := s.defaultLocale()
+	locale := negotiateLocale(r.Header.Get("Accept-Language"), fallback)
+	if lang := r.URL.Query().Get("lang"); lang != "" {
+		locale = negotiateLocale(lang, fallback)
+	}
+
+	messages := map[string]string{}
+	for k, v := range locales[fallback] {
+		messages[k] = v
+	}
+	for k, v := range locales[locale] {
+		messages[k] = v
+	}
+
+	w.Header().Set("Content-Language", locale)
+	w.Header().Set("Vary", "Accept-Language")
+	writeJSON(w, map[string]interface{}{
+		"locale":    locale,
+		"default":   fallback,
+		"available": availableLocales(),
+		"messages":  messages,
+	})
+}
@@ -0,0 +1,61 @@
+package web_test
+
+import (
+	"encoding/json"
+	"net/http"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/example/helm-operator/web"
+)
+
+var _ = Describe("UI language packs", func() {
+	type bundle struct {
+		Locale    string            `json:"locale"`
+		Default   string            `json:"default"`
+		Available []string          `json:"available"`
+		Messages  map[string]string `json:"messages"`
+	}
+	fetch := func(ts *testServer, path string, headers ...string) (*http.Response, bundle) {
+		resp, body := ts.do(http.MethodGet, path, nil, headers...)
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		var b bundle
+		Expect(json.Unmarshal(body, &b)).To(Succeed())
+		return resp, b
+	}
+
+	DescribeTable("negotiates the locale",
+		func(path, acceptLanguage, locale string) {
+			ts := startServer(nil)
+			resp, b := fetch(ts, path, "Accept-Language", acceptLanguage)
+			Expect(b.Locale).To(Equal(locale))
+			Expect(resp.Header.Get("Content-Language")).To(Equal(locale))
+			Expect(resp.Header.Get("Vary")).To(Equal("Accept-Language"))
+		},
+		Entry("exact match", "/api/i18n", "de", "de"),
+		Entry("regional tag", "/api/i18n", "de-AT", "de"),
+		Entry("by quality", "/api/i18n", "en;q=0.4, fr, de-CH;q=0.8", "de"),
+		Entry("nothing bundled", "/api/i18n", "fr, ja;q=0.9", "en"),
+		Entry("refused languages", "/api/i18n", "de;q=0, *", "en"),
+		Entry("lang overrides the header", "/api/i18n?lang=en", "de", "en"),
+	)
+
+	It("fills in messages from the default locale", func() {
+		ts := startServer(nil)
+		_, en := fetch(ts, "/api/i18n?lang=en")
+		_, de := fetch(ts, "/api/i18n?lang=de")
+		Expect(en.Available).To(ContainElements("de", "en"))
+		Expect(de.Messages).To(HaveKeyWithValue("header.admin", "Verwaltung"))
+		for key := range en.Messages {
+			Expect(de.Messages).To(HaveKey(key))
+		}
+	})
+
+	It("uses the configured default locale", func() {
+		ts := startServer(nil, func(s *web.WebServer) { s.DefaultLocale = "DE" })
+		_, b := fetch(ts, "/api/i18n", "Accept-Language", "fr")
+		Expect(b.Locale).To(Equal("de"))
+		Expect(b.Default).To(Equal("de"))
+	})
+})
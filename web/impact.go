@@ -0,0 +1,79 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"github.com/example/helm-operator/controllers"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// handleImpact serves POST /api/helmreleases/impact?ns=&name=: what saving
+// the release with the changes in the body, as accepted by PUT
+// /api/helmreleases, would change in the cluster. An empty body previews the
+// current spec, for example a valuesFrom source that changed. The chart is
+// rendered and compared with the live objects; nothing is changed.
+func (s *WebServer) handleImpact(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		httpError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.HelmClient == nil {
+		httpError(w, "impact preview is not available", http.StatusServiceUnavailable)
+		return
+	}
+	name, ns := r.URL.Query().Get("name"), r.URL.Query().Get("ns")
+	if name == "" || ns == "" {
+		httpError(w, "query params 'name' and 'ns' are required", http.StatusBadRequest)
+		return
+	}
+	var hr helmv1alpha1.HelmRelease
+	if err := s.Client.Get(r.Context(), types.NamespacedName{Name: name, Namespace: ns}, &hr); err != nil {
+		writeError(w, err)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		httpError(w, "reading body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(bytes.TrimSpace(body)) > 0 {
+		var in ReleaseInput
+		if err := json.Unmarshal(body, &in); err != nil {
+			httpError(w, "invalid JSON body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := in.update(&hr); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+
+	values, _, err := controllers.ComposeValues(r.Context(), s.Client, &hr)
+	switch {
+	case controllers.IsMissingValuesSource(err):
+		httpError(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		writeError(w, err)
+		return
+	}
+	s.Rewrites.RewriteValues(values)
+	releaseName := hr.Name
+	if hr.Spec.ReleaseName != "" {
+		releaseName = hr.Spec.ReleaseName
+	}
+	summary, err := s.HelmClient.Impact(r.Context(), releaseName, hr.Spec.Chart, s.Rewrites.Rewrite(hr.Spec.RepoURL),
+		hr.Spec.Version, hr.Spec.TargetNamespace, values, controllers.ChartFetchOptions{
+			ProxyURL:        hr.Spec.ProxyURL,
+			AllowPrerelease: hr.Spec.AllowPrerelease,
+		})
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, summary)
+}
@@ -0,0 +1,61 @@
+package web_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/example/helm-operator/controllers"
+	"github.com/example/helm-operator/web"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Impact API", func() {
+	ctx := context.Background()
+
+	var ts *testServer
+	BeforeEach(func() {
+		helm := &controllers.FakeHelmClient{}
+		_, err := helm.Install(ctx, "web", "nginx", "", "1.0.0", "team-a", nil, controllers.InstallOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		ts = startServer([]client.Object{makeHR("team-a", "web"), makeHR("team-a", "new")}, func(s *web.WebServer) {
+			s.HelmClient = helm
+		})
+	})
+
+	impact := func(path string, body interface{}) controllers.ImpactSummary {
+		resp, data := ts.do(http.MethodPost, path, body)
+		Expect(resp.StatusCode).To(Equal(http.StatusOK), string(data))
+		var summary controllers.ImpactSummary
+		Expect(json.Unmarshal(data, &summary)).To(Succeed())
+		return summary
+	}
+
+	It("summarizes the objects a change would modify", func() {
+		summary := impact("/api/helmreleases/impact?ns=team-a&name=web", map[string]string{"version": "2.0.0"})
+		Expect(summary.Modified).To(Equal(1))
+		Expect(summary.Objects).To(ConsistOf(controllers.ObjectImpact{
+			APIVersion: "v1", Kind: "ConfigMap", Namespace: "team-a", Name: "web", Change: controllers.ChangeModify, Field: "data",
+		}))
+
+		summary = impact("/api/helmreleases/impact?ns=team-a&name=web", nil)
+		Expect(summary.Unchanged).To(Equal(1))
+		Expect(summary.Objects).To(BeEmpty())
+	})
+
+	It("reports the objects of a release not installed yet as created", func() {
+		summary := impact("/api/helmreleases/impact?ns=team-a&name=new", nil)
+		Expect(summary.Created).To(Equal(1))
+		Expect(summary.Objects[0].Change).To(Equal(controllers.ChangeCreate))
+	})
+
+	It("rejects invalid changes and unknown releases", func() {
+		resp, _ := ts.do(http.MethodPost, "/api/helmreleases/impact?ns=team-a&name=web", map[string]string{"values": "{"})
+		Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+		resp, _ = ts.do(http.MethodPost, "/api/helmreleases/impact?ns=team-a&name=missing", nil)
+		Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+	})
+})
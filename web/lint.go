@@ -0,0 +1,81 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"github.com/example/helm-operator/controllers"
+	helmvalues "github.com/example/helm-operator/values"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// lintRequest is the body accepted by POST /api/lint, to lint a chart before
+// a HelmRelease is created for it.
+type lintRequest struct {
+	Chart    string `json:"chart"`
+	RepoURL  string `json:"repoURL"`
+	Version  string `json:"version"`
+	Values   string `json:"values"` // raw JSON string, may be empty
+	ProxyURL string `json:"proxyURL"`
+}
+
+type lintResponse struct {
+	Findings []string `json:"findings"`
+}
+
+// handleLint runs helm lint. GET ?name=&ns= lints an existing HelmRelease's
+// chart and values; POST lints the chart described in the body.
+func (s *WebServer) handleLint(w http.ResponseWriter, r *http.Request) {
+	if s.HelmClient == nil {
+		httpError(w, "lint is not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	var req lintRequest
+	switch r.Method {
+	case http.MethodGet:
+		name, ns := r.URL.Query().Get("name"), r.URL.Query().Get("ns")
+		if name == "" || ns == "" {
+			httpError(w, "query params 'name' and 'ns' are required", http.StatusBadRequest)
+			return
+		}
+		var hr helmv1alpha1.HelmRelease
+		if err := s.Client.Get(r.Context(), types.NamespacedName{Name: name, Namespace: ns}, &hr); err != nil {
+			writeError(w, err)
+			return
+		}
+		req = lintRequest{Chart: hr.Spec.Chart, RepoURL: hr.Spec.RepoURL, Version: hr.Spec.Version, ProxyURL: hr.Spec.ProxyURL}
+		if hr.Spec.Values != nil {
+			req.Values = string(hr.Spec.Values.Raw)
+		}
+	case http.MethodPost:
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			httpError(w, "invalid JSON body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.Chart == "" || req.Version == "" {
+			httpError(w, "chart and version are required", http.StatusBadRequest)
+			return
+		}
+	default:
+		httpError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	values, err := helmvalues.Parse([]byte(req.Values))
+	if err != nil {
+		httpError(w, "invalid values: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	findings, err := s.HelmClient.Lint(r.Context(), req.Chart, req.RepoURL, req.Version, values,
+		controllers.ChartFetchOptions{ProxyURL: req.ProxyURL})
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	if findings == nil {
+		findings = []string{}
+	}
+	writeJSON(w, lintResponse{Findings: findings})
+}
@@ -0,0 +1,62 @@
+package web
+
+import (
+	"net/http"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"github.com/example/helm-operator/controllers"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// jsonPatchOp is one RFC 6902 operation.
+type jsonPatchOp struct {
+	Op    string            `json:"op"`
+	Path  string            `json:"path"`
+	Value rbacv1.PolicyRule `json:"value"`
+}
+
+// handlePermissionsPatch serves GET /api/permissions/patch: a JSON patch that
+// adds the permissions HelmReleases reported missing to the operator's
+// ClusterRole, for
+//
+//	kubectl patch clusterrole <name> --type=json --patch-file=patch.json
+//
+// With ?name=&ns= only that release is considered; otherwise all are.
+func (s *WebServer) handlePermissionsPatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var releases []helmv1alpha1.HelmRelease
+	if name, ns := r.URL.Query().Get("name"), r.URL.Query().Get("ns"); name != "" || ns != "" {
+		if name == "" || ns == "" {
+			httpError(w, "query params 'name' and 'ns' must be given together", http.StatusBadRequest)
+			return
+		}
+		var hr helmv1alpha1.HelmRelease
+		if err := s.Client.Get(r.Context(), types.NamespacedName{Name: name, Namespace: ns}, &hr); err != nil {
+			writeError(w, err)
+			return
+		}
+		releases = append(releases, hr)
+	} else {
+		var list helmv1alpha1.HelmReleaseList
+		if err := s.Client.List(r.Context(), &list); err != nil {
+			writeError(w, err)
+			return
+		}
+		releases = list.Items
+	}
+
+	var missing []helmv1alpha1.PermissionRule
+	for _, hr := range releases {
+		missing = append(missing, hr.Status.MissingPermissions...)
+	}
+	patch := []jsonPatchOp{}
+	for _, rule := range controllers.ClusterRoleRules(missing) {
+		patch = append(patch, jsonPatchOp{Op: "add", Path: "/rules/-", Value: rule})
+	}
+	writeJSON(w, patch)
+}
@@ -0,0 +1,438 @@
+package web
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	helmvalues "github.com/example/helm-operator/values"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ciTokenEnv names the environment variable holding the bearer token CI
+// systems must present to /api/ci/preview. The endpoint is disabled when unset.
+const ciTokenEnv = "HELM_OPERATOR_CI_TOKEN"
+
+// Labels identifying HelmReleases created through the CI preview endpoint.
+const (
+	previewLabel           = "helm.example.com/preview"
+	previewRepositoryLabel = "helm.example.com/preview-repository"
+	previewPRLabel         = "helm.example.com/preview-pr"
+)
+
+// previewRepositoryAnnotation holds the untruncated repository of a preview,
+// which previewRepositoryLabel may not fit.
+const previewRepositoryAnnotation = "helm.example.com/preview-repository"
+
+// Annotations of a Namespace that configure previews in it, on top of the
+// WebServer's Preview settings.
+const (
+	// previewsAnnotation set to "true" allows previews in the namespace.
+	previewsAnnotation = "helm.example.com/previews"
+	// previewRepoURLsAnnotation lists, comma-separated, further chart
+	// repositories previews in the namespace may install from.
+	previewRepoURLsAnnotation = "helm.example.com/preview-repo-urls"
+	// previewServiceAccountAnnotation names the ServiceAccount Helm
+	// impersonates for previews in the namespace.
+	previewServiceAccountAnnotation = "helm.example.com/preview-service-account"
+)
+
+// helmReleaseNameMax is Helm's limit on release name length.
+const helmReleaseNameMax = 53
+
+var (
+	nonDNSChars = regexp.MustCompile(`[^a-z0-9-]+`)
+	notesURL    = regexp.MustCompile(`https?://[^\s"'<>]+`)
+)
+
+// previewRequest is the body expected by POST /api/ci/preview. GET and DELETE
+// identify the preview with the repository, pr and namespace query params.
+type previewRequest struct {
+	Repository string `json:"repository"` // e.g. "acme/shop"
+	PR         int    `json:"pr"`
+	Namespace  string `json:"namespace"` // namespace of the HelmRelease and the release itself
+	Chart      string `json:"chart"`
+	RepoURL    string `json:"repoURL"`
+	Version    string `json:"version"`
+	ImageTag   string `json:"imageTag"` // set as image.tag in the chart values
+	TTL        string `json:"ttl"`      // Go duration string, may be empty
+	Values     string `json:"values"`   // raw JSON string, may be empty
+}
+
+// previewResponse describes a preview environment to the calling CI job.
+type previewResponse struct {
+	Name      string             `json:"name"`
+	Namespace string             `json:"namespace"`
+	Phase     helmv1alpha1.Phase `json:"phase"`
+	ExpiresAt *metav1.Time       `json:"expiresAt,omitempty"`
+	URLs      []string           `json:"urls"`
+}
+
+// handleCIPreview lets CI pipelines create, update, inspect, and destroy a
+// per-pull-request preview HelmRelease.
+func (s *WebServer) handleCIPreview(w http.ResponseWriter, r *http.Request) {
+	token := os.Getenv(ciTokenEnv)
+	if token == "" {
+		httpError(w, ciTokenEnv+" not set", http.StatusServiceUnavailable)
+		return
+	}
+	presented := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+		httpError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		s.upsertPreview(w, r)
+	case http.MethodGet:
+		s.getPreview(w, r)
+	case http.MethodDelete:
+		s.deletePreview(w, r)
+	default:
+		httpError(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *WebServer) upsertPreview(w http.ResponseWriter, r *http.Request) {
+	var req previewRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpError(w, "invalid JSON body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Repository == "" || req.PR <= 0 || req.Namespace == "" || req.Chart == "" || req.RepoURL == "" || req.Version == "" {
+		httpError(w, "repository, pr, namespace, chart, repoURL, and version are required", http.StatusBadRequest)
+		return
+	}
+
+	values, err := helmvalues.Parse([]byte(req.Values))
+	if err != nil {
+		httpError(w, "invalid values: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.ImageTag != "" {
+		values = helmvalues.Merge(values, map[string]interface{}{"image": map[string]interface{}{"tag": req.ImageTag}})
+	}
+	rawValues, err := json.Marshal(values)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	ttl, err := parseTTL(req.TTL)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	policy, err := s.previewPolicy(r, req.Namespace)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if !policy.allowsRepoURL(req.RepoURL) {
+		writeError(w, previewForbidden("previews in namespace %s may not install charts from %s", req.Namespace, req.RepoURL))
+		return
+	}
+
+	name := previewName(req.Repository, req.PR)
+	var hr helmv1alpha1.HelmRelease
+	err = s.Client.Get(r.Context(), types.NamespacedName{Name: name, Namespace: req.Namespace}, &hr)
+	if err != nil && !apierrors.IsNotFound(err) {
+		writeError(w, err)
+		return
+	}
+	created := apierrors.IsNotFound(err)
+	if !created {
+		if err := checkPreviewOwner(&hr, req.Repository, req.PR); err != nil {
+			writeError(w, err)
+			return
+		}
+		// spec.kubeConfig turns impersonation off; previews only deploy
+		// to this cluster, as the preview ServiceAccount.
+		if hr.Spec.KubeConfig != nil {
+			writeError(w, previewForbidden("preview %s/%s sets spec.kubeConfig; previews cannot deploy to other clusters", hr.Namespace, hr.Name))
+			return
+		}
+	}
+
+	if created {
+		hr = helmv1alpha1.HelmRelease{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: req.Namespace,
+				Labels: map[string]string{
+					previewLabel:           "true",
+					previewRepositoryLabel: dnsLabel(req.Repository, 63),
+					previewPRLabel:         strconv.Itoa(req.PR),
+				},
+				Annotations: map[string]string{previewRepositoryAnnotation: req.Repository},
+			},
+		}
+	}
+	patch := client.MergeFrom(hr.DeepCopy())
+	hr.Spec.Chart = req.Chart
+	hr.Spec.RepoURL = req.RepoURL
+	hr.Spec.Version = req.Version
+	hr.Spec.TargetNamespace = req.Namespace
+	hr.Spec.Values = &apiextensionsv1.JSON{Raw: rawValues}
+	hr.Spec.TTL = ttl
+	// The preview always runs as the namespace's preview ServiceAccount,
+	// whatever was set on the HelmRelease since.
+	hr.Spec.ServiceAccountName = policy.serviceAccount
+
+	if created {
+		err = s.Client.Create(r.Context(), &hr)
+	} else {
+		err = s.Client.Patch(r.Context(), &hr, patch)
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if created {
+		s.broadcastEvent("created", &hr)
+		w.WriteHeader(http.StatusCreated)
+	} else {
+		s.broadcastEvent("updated", &hr)
+	}
+	writeJSON(w, s.describePreview(r, &hr))
+}
+
+func (s *WebServer) getPreview(w http.ResponseWriter, r *http.Request) {
+	key, pr, ok := previewKey(w, r)
+	if !ok {
+		return
+	}
+	var hr helmv1alpha1.HelmRelease
+	if err := s.Client.Get(r.Context(), key, &hr); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := checkPreviewOwner(&hr, r.URL.Query().Get("repository"), pr); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, s.describePreview(r, &hr))
+}
+
+func (s *WebServer) deletePreview(w http.ResponseWriter, r *http.Request) {
+	key, pr, ok := previewKey(w, r)
+	if !ok {
+		return
+	}
+	hr := &helmv1alpha1.HelmRelease{}
+	if err := s.Client.Get(r.Context(), key, hr); err != nil {
+		if apierrors.IsNotFound(err) {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		writeError(w, err)
+		return
+	}
+	if err := checkPreviewOwner(hr, r.URL.Query().Get("repository"), pr); err != nil {
+		writeError(w, err)
+		return
+	}
+	// The UID precondition makes sure a HelmRelease recreated under the
+	// name since is not deleted.
+	if err := s.Client.Delete(r.Context(), hr, client.Preconditions{UID: &hr.UID}); client.IgnoreNotFound(err) != nil {
+		writeError(w, err)
+		return
+	}
+	s.broadcastEvent("deleted", hr)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checkPreviewOwner returns a Conflict error unless hr is the preview of
+// repository's pull request pr. Repositories whose names agree in the
+// characters previewName keeps share a HelmRelease name, and a preview must
+// not take over, show or delete another repository's preview or a
+// HelmRelease that is no preview at all. The repository is compared in full
+// with previewRepositoryAnnotation, as the label is truncated too.
+func checkPreviewOwner(hr *helmv1alpha1.HelmRelease, repository string, pr int) error {
+	sameRepository := hr.Labels[previewRepositoryLabel] == dnsLabel(repository, 63)
+	// Previews created before the annotation only have the label.
+	if owner, ok := hr.Annotations[previewRepositoryAnnotation]; ok {
+		sameRepository = owner == repository
+	}
+	if hr.Labels[previewLabel] == "true" && sameRepository && hr.Labels[previewPRLabel] == strconv.Itoa(pr) {
+		return nil
+	}
+	return apierrors.NewConflict(helmReleasesResource, hr.Name,
+		fmt.Errorf("it is not the preview of %s pull request %d", repository, pr))
+}
+
+// helmReleasesResource names HelmReleases in API errors.
+var helmReleasesResource = schema.GroupResource{Group: helmv1alpha1.GroupVersion.Group, Resource: "helmreleases"}
+
+// previewForbidden returns a Forbidden error for a preview request.
+func previewForbidden(format string, args ...interface{}) error {
+	return apierrors.NewForbidden(helmReleasesResource, "", fmt.Errorf(format, args...))
+}
+
+// previewPolicy is what previews in a namespace may do.
+type previewPolicy struct {
+	repoURLs       []string
+	serviceAccount string
+}
+
+// previewPolicy returns the preview settings of namespace, combining the
+// WebServer's with the namespace's annotations. It returns a Forbidden error
+// when previews are not allowed in namespace, or when there is no
+// ServiceAccount to run them as: without one Helm would act with the
+// operator's own permissions, or the --default-service-account chosen for
+// HelmReleases written by cluster users, not by CI.
+func (s *WebServer) previewPolicy(r *http.Request, namespace string) (previewPolicy, error) {
+	var ns corev1.Namespace
+	if err := s.Client.Get(r.Context(), types.NamespacedName{Name: namespace}, &ns); err != nil {
+		if apierrors.IsNotFound(err) {
+			return previewPolicy{}, previewForbidden("namespace %s does not exist", namespace)
+		}
+		return previewPolicy{}, err
+	}
+	allowed := ns.Annotations[previewsAnnotation] == "true"
+	for _, n := range s.PreviewNamespaces {
+		allowed = allowed || n == namespace
+	}
+	if !allowed {
+		return previewPolicy{}, previewForbidden("previews are not allowed in namespace %s", namespace)
+	}
+
+	policy := previewPolicy{
+		repoURLs:       append([]string{}, s.PreviewRepoURLs...),
+		serviceAccount: s.PreviewServiceAccount,
+	}
+	for _, u := range strings.Split(ns.Annotations[previewRepoURLsAnnotation], ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			policy.repoURLs = append(policy.repoURLs, u)
+		}
+	}
+	if sa := ns.Annotations[previewServiceAccountAnnotation]; sa != "" {
+		policy.serviceAccount = sa
+	}
+	if policy.serviceAccount == "" {
+		return previewPolicy{}, previewForbidden("no ServiceAccount for previews in namespace %s: set --preview-service-account or the %s annotation",
+			namespace, previewServiceAccountAnnotation)
+	}
+	return policy, nil
+}
+
+// allowsRepoURL reports whether previews may install charts from repoURL.
+func (p previewPolicy) allowsRepoURL(repoURL string) bool {
+	for _, u := range p.repoURLs {
+		if strings.TrimSuffix(u, "/") == strings.TrimSuffix(repoURL, "/") {
+			return true
+		}
+	}
+	return false
+}
+
+// previewKey resolves the repository, pr and namespace query params to the
+// preview HelmRelease's name and the pull request number, writing a 400
+// response if any are missing.
+func previewKey(w http.ResponseWriter, r *http.Request) (types.NamespacedName, int, bool) {
+	q := r.URL.Query()
+	pr, err := strconv.Atoi(q.Get("pr"))
+	if q.Get("repository") == "" || q.Get("namespace") == "" || err != nil || pr <= 0 {
+		httpError(w, "query params 'repository', 'pr', and 'namespace' are required", http.StatusBadRequest)
+		return types.NamespacedName{}, 0, false
+	}
+	return types.NamespacedName{Name: previewName(q.Get("repository"), pr), Namespace: q.Get("namespace")}, pr, true
+}
+
+// describePreview collects the preview's state and the URLs it is reachable
+// at, taken from the chart's rendered NOTES and from Ingresses the release owns.
+func (s *WebServer) describePreview(r *http.Request, hr *helmv1alpha1.HelmRelease) previewResponse {
+	resp := previewResponse{
+		Name:      hr.Name,
+		Namespace: hr.Namespace,
+		Phase:     hr.Status.Phase,
+		ExpiresAt: hr.Status.ExpiresAt,
+		URLs:      []string{},
+	}
+	releaseName := hr.Name
+	if hr.Spec.ReleaseName != "" {
+		releaseName = hr.Spec.ReleaseName
+	}
+
+	seen := map[string]bool{}
+	add := func(u string) {
+		u = strings.TrimRight(u, ".,;:)")
+		if !seen[u] {
+			seen[u] = true
+			resp.URLs = append(resp.URLs, u)
+		}
+	}
+
+	var ingresses networkingv1.IngressList
+	if err := s.Client.List(r.Context(), &ingresses, client.InNamespace(hr.Spec.TargetNamespace),
+		client.MatchingLabels{"app.kubernetes.io/instance": releaseName}); err == nil {
+		for _, ing := range ingresses.Items {
+			for _, u := range ingressURLs(&ing) {
+				add(u)
+			}
+		}
+	}
+
+	if s.HelmClient != nil && hr.Status.Phase == helmv1alpha1.PhaseReady {
+		if rel, err := s.HelmClient.GetRelease(releaseName, hr.Spec.TargetNamespace); err == nil && rel.Info != nil {
+			for _, u := range notesURL.FindAllString(rel.Info.Notes, -1) {
+				add(u)
+			}
+		}
+	}
+	return resp
+}
+
+// ingressURLs returns one URL per rule host, using https when a TLS block covers the host.
+func ingressURLs(ing *networkingv1.Ingress) []string {
+	tlsHosts := map[string]bool{}
+	for _, t := range ing.Spec.TLS {
+		for _, h := range t.Hosts {
+			tlsHosts[h] = true
+		}
+	}
+	var urls []string
+	for _, rule := range ing.Spec.Rules {
+		if rule.Host == "" {
+			continue
+		}
+		scheme := "http"
+		if tlsHosts[rule.Host] {
+			scheme = "https"
+		}
+		urls = append(urls, fmt.Sprintf("%s://%s", scheme, rule.Host))
+	}
+	return urls
+}
+
+// previewName derives a stable HelmRelease name for a repository's pull
+// request, short enough to be used as the Helm release name.
+func previewName(repository string, pr int) string {
+	prefix := fmt.Sprintf("pr-%d-", pr)
+	return prefix + dnsLabel(repository, helmReleaseNameMax-len(prefix))
+}
+
+// dnsLabel lowercases s and replaces anything outside [a-z0-9-] so it is a
+// valid DNS-1123 label of at most maxLen characters.
+func dnsLabel(s string, maxLen int) string {
+	s = nonDNSChars.ReplaceAllString(strings.ToLower(s), "-")
+	if len(s) > maxLen {
+		s = s[:maxLen]
+	}
+	return strings.Trim(s, "-")
+}
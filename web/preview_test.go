@@ -0,0 +1,161 @@
+package web_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"github.com/example/helm-operator/web"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("CI preview API", func() {
+	const token = "ci-token"
+	ctx := context.Background()
+
+	var (
+		ts   *testServer
+		objs []client.Object
+	)
+	BeforeEach(func() {
+		os.Setenv("HELM_OPERATOR_CI_TOKEN", token)
+		DeferCleanup(os.Unsetenv, "HELM_OPERATOR_CI_TOKEN")
+		objs = []client.Object{
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "previews", Annotations: map[string]string{
+				"helm.example.com/previews":                "true",
+				"helm.example.com/preview-repo-urls":       "https://charts.acme.dev/",
+				"helm.example.com/preview-service-account": "preview-deployer",
+			}}},
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}},
+		}
+	})
+	JustBeforeEach(func() {
+		ts = startServer(objs, func(s *web.WebServer) {
+			s.PreviewNamespaces = []string{"team-a"}
+			s.PreviewRepoURLs = []string{"https://charts.example.com"}
+		})
+	})
+
+	body := func(repository, namespace, repoURL string) map[string]interface{} {
+		return map[string]interface{}{
+			"repository": repository, "pr": 42, "namespace": namespace,
+			"chart": "shop", "repoURL": repoURL, "version": "1.4.0", "imageTag": "sha-1",
+		}
+	}
+	upsert := func(b map[string]interface{}) (*http.Response, map[string]interface{}) {
+		resp, data := ts.do(http.MethodPost, "/api/ci/preview", b, "Authorization", "Bearer "+token)
+		var out map[string]interface{}
+		Expect(json.Unmarshal(data, &out)).To(Succeed())
+		return resp, out
+	}
+	get := func(name string) *helmv1alpha1.HelmRelease {
+		var hr helmv1alpha1.HelmRelease
+		Expect(ts.K8s.Get(ctx, types.NamespacedName{Namespace: "previews", Name: name}, &hr)).To(Succeed())
+		return &hr
+	}
+
+	It("creates previews as the namespace's preview ServiceAccount", func() {
+		resp, out := upsert(body("acme/shop", "previews", "https://charts.acme.dev"))
+		Expect(resp.StatusCode).To(Equal(http.StatusCreated))
+		Expect(out).To(HaveKeyWithValue("name", "pr-42-acme-shop"))
+
+		hr := get("pr-42-acme-shop")
+		Expect(hr.Spec.ServiceAccountName).To(Equal("preview-deployer"))
+		Expect(hr.Labels).To(HaveKeyWithValue("helm.example.com/preview-repository", "acme-shop"))
+		Expect(hr.Labels).To(HaveKeyWithValue("helm.example.com/preview-pr", "42"))
+		Expect(hr.Annotations).To(HaveKeyWithValue("helm.example.com/preview-repository", "acme/shop"))
+
+		// Updates put the ServiceAccount back.
+		hr.Spec.ServiceAccountName = ""
+		Expect(ts.K8s.Update(ctx, hr)).To(Succeed())
+		resp, _ = upsert(body("acme/shop", "previews", "https://charts.acme.dev"))
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(get("pr-42-acme-shop").Spec.ServiceAccountName).To(Equal("preview-deployer"))
+	})
+
+	DescribeTable("refuses previews outside the allow-lists",
+		func(namespace, repoURL, reason string) {
+			resp, out := upsert(body("acme/shop", namespace, repoURL))
+			Expect(resp.StatusCode).To(Equal(http.StatusForbidden))
+			Expect(out["message"]).To(ContainSubstring(reason))
+		},
+		Entry("missing namespace", "default", "https://charts.acme.dev", "namespace default does not exist"),
+		Entry("unknown repository", "previews", "https://evil.example.com", "may not install charts from"),
+		Entry("no ServiceAccount", "team-a", "https://charts.example.com", "no ServiceAccount for previews"),
+	)
+
+	When("the namespace is not allowed", func() {
+		BeforeEach(func() {
+			objs = append(objs, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "prod"}})
+		})
+
+		It("refuses previews there", func() {
+			resp, out := upsert(body("acme/shop", "prod", "https://charts.example.com"))
+			Expect(resp.StatusCode).To(Equal(http.StatusForbidden))
+			Expect(out["message"]).To(ContainSubstring("previews are not allowed in namespace prod"))
+		})
+	})
+
+	When("a HelmRelease of the same name exists", func() {
+		long := "acme/" + strings.Repeat("x", 60)
+
+		BeforeEach(func() {
+			hr := makeHR("previews", "pr-42-acme-"+strings.Repeat("x", 42))
+			hr.Labels = map[string]string{
+				"helm.example.com/preview":            "true",
+				"helm.example.com/preview-repository": "acme-" + strings.Repeat("x", 58),
+				"helm.example.com/preview-pr":         "42",
+			}
+			hr.Annotations = map[string]string{"helm.example.com/preview-repository": long}
+			plain := makeHR("previews", "pr-42-acme-shop")
+			objs = append(objs, hr, plain)
+		})
+
+		It("refuses to take over another repository's preview", func() {
+			resp, _ := upsert(body(long+"-other", "previews", "https://charts.acme.dev"))
+			Expect(resp.StatusCode).To(Equal(http.StatusConflict))
+
+			resp, _ = ts.do(http.MethodDelete, "/api/ci/preview?pr=42&namespace=previews&repository="+long+"-other", nil,
+				"Authorization", "Bearer "+token)
+			Expect(resp.StatusCode).To(Equal(http.StatusConflict))
+			get("pr-42-acme-" + strings.Repeat("x", 42))
+		})
+
+		It("refuses to touch HelmReleases that are not previews", func() {
+			resp, _ := upsert(body("acme/shop", "previews", "https://charts.acme.dev"))
+			Expect(resp.StatusCode).To(Equal(http.StatusConflict))
+
+			resp, _ = ts.do(http.MethodGet, "/api/ci/preview?pr=42&namespace=previews&repository=acme/shop", nil,
+				"Authorization", "Bearer "+token)
+			Expect(resp.StatusCode).To(Equal(http.StatusConflict))
+		})
+	})
+
+	When("a preview was given a kubeConfig", func() {
+		BeforeEach(func() {
+			hr := makeHR("previews", "pr-42-acme-shop")
+			hr.Labels = map[string]string{
+				"helm.example.com/preview":            "true",
+				"helm.example.com/preview-repository": "acme-shop",
+				"helm.example.com/preview-pr":         "42",
+			}
+			hr.Spec.KubeConfig = &helmv1alpha1.KubeConfigReference{SecretRef: helmv1alpha1.KubeConfigSecretRef{Name: "remote"}}
+			objs = append(objs, hr)
+		})
+
+		It("refuses to update it", func() {
+			resp, out := upsert(body("acme/shop", "previews", "https://charts.acme.dev"))
+			Expect(resp.StatusCode).To(Equal(http.StatusForbidden))
+			Expect(out["message"]).To(ContainSubstring("spec.kubeConfig"))
+		})
+	})
+})
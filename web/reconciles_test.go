@@ -0,0 +1,32 @@
+package web_test
+
+import (
+	"net/http"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/example/helm-operator/controllers"
+	"github.com/example/helm-operator/web"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Reconcile history API", func() {
+	It("is unavailable without a reconcile log", func() {
+		ts := startServer([]client.Object{makeHR("apps", "web")})
+		resp, _ := ts.do(http.MethodGet, "/api/helmreleases/reconciles?name=web&ns=apps", nil)
+		Expect(resp.StatusCode).To(Equal(http.StatusServiceUnavailable))
+	})
+
+	It("returns an empty list for a release not reconciled yet, and 404 for unknown ones", func() {
+		ts := startServer([]client.Object{makeHR("apps", "web")}, func(s *web.WebServer) {
+			s.ReconcileLog = &controllers.ReconcileLog{}
+		})
+		resp, body := ts.do(http.MethodGet, "/api/helmreleases/reconciles?name=web&ns=apps", nil)
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(string(body)).To(MatchJSON(`[]`))
+
+		resp, _ = ts.do(http.MethodGet, "/api/helmreleases/reconciles?name=missing&ns=apps", nil)
+		Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+	})
+})
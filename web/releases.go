@@ -0,0 +1,160 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ReleaseInput describes a HelmRelease to create, or the changes to make to
+// one, independent of the transport. It is the body of POST and PUT
+// /api/helmreleases.
+type ReleaseInput struct {
+	Name            string `json:"name"`
+	Namespace       string `json:"namespace"`
+	Chart           string `json:"chart"`
+	RepoURL         string `json:"repoURL"`
+	Version         string `json:"version"`
+	TargetNamespace string `json:"targetNamespace"`
+	ReleaseName     string `json:"releaseName"`
+	Values          string `json:"values"` // raw JSON string, may be empty
+	TTL             string `json:"ttl"`    // Go duration string, may be empty
+}
+
+// ReleaseService manages HelmReleases on behalf of a user, so the REST API
+// and other frontends share validation and defaulting. Invalid input is
+// reported as a Kubernetes BadRequest error, missing releases as NotFound;
+// see apiErrorStatus.
+type ReleaseService interface {
+	List(ctx context.Context) ([]helmv1alpha1.HelmRelease, error)
+	// Create creates the release described by in. user, if set, is
+	// recorded as having triggered the resulting Helm revision.
+	Create(ctx context.Context, in ReleaseInput, user string) (*helmv1alpha1.HelmRelease, error)
+	// Update applies in to the named release. Empty chart coordinates are
+	// left unchanged; releaseName, values and ttl are replaced.
+	Update(ctx context.Context, namespace, name string, in ReleaseInput, user string) (*helmv1alpha1.HelmRelease, error)
+	Delete(ctx context.Context, namespace, name string) error
+}
+
+// NewReleaseService returns a ReleaseService backed by c.
+func NewReleaseService(c client.Client) ReleaseService {
+	return &clientReleaseService{client: c}
+}
+
+type clientReleaseService struct {
+	client client.Client
+}
+
+func (s *clientReleaseService) List(ctx context.Context) ([]helmv1alpha1.HelmRelease, error) {
+	var list helmv1alpha1.HelmReleaseList
+	if err := s.client.List(ctx, &list); err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+func (s *clientReleaseService) Create(ctx context.Context, in ReleaseInput, user string) (*helmv1alpha1.HelmRelease, error) {
+	if in.Name == "" || in.Namespace == "" || in.Chart == "" || in.RepoURL == "" || in.Version == "" || in.TargetNamespace == "" {
+		return nil, apierrors.NewBadRequest("name, namespace, chart, repoURL, version, and targetNamespace are required")
+	}
+	hr := &helmv1alpha1.HelmRelease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      in.Name,
+			Namespace: in.Namespace,
+		},
+		Spec: helmv1alpha1.HelmReleaseSpec{
+			Chart:           in.Chart,
+			RepoURL:         in.RepoURL,
+			Version:         in.Version,
+			TargetNamespace: in.TargetNamespace,
+		},
+	}
+	if err := in.apply(hr); err != nil {
+		return nil, err
+	}
+	setTriggeredByUser(hr, user)
+	if err := s.client.Create(ctx, hr); err != nil {
+		return nil, err
+	}
+	return hr, nil
+}
+
+func (s *clientReleaseService) Update(ctx context.Context, namespace, name string, in ReleaseInput, user string) (*helmv1alpha1.HelmRelease, error) {
+	if namespace == "" || name == "" {
+		return nil, apierrors.NewBadRequest("namespace and name are required")
+	}
+	var hr helmv1alpha1.HelmRelease
+	if err := s.client.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, &hr); err != nil {
+		return nil, err
+	}
+	if err := in.update(&hr); err != nil {
+		return nil, err
+	}
+	setTriggeredByUser(&hr, user)
+
+	// Update rather than a merge patch: in a merge patch a null in the new
+	// values would delete the key instead of being stored, and Helm needs
+	// the null to drop the chart's default.
+	if err := s.client.Update(ctx, &hr); err != nil {
+		return nil, err
+	}
+	return &hr, nil
+}
+
+func (s *clientReleaseService) Delete(ctx context.Context, namespace, name string) error {
+	if namespace == "" || name == "" {
+		return apierrors.NewBadRequest("namespace and name are required")
+	}
+	hr := &helmv1alpha1.HelmRelease{}
+	hr.Name = name
+	hr.Namespace = namespace
+	return s.client.Delete(ctx, hr)
+}
+
+// update applies in to hr as Update does: empty chart coordinates are left
+// unchanged.
+func (in ReleaseInput) update(hr *helmv1alpha1.HelmRelease) error {
+	if in.Chart != "" {
+		hr.Spec.Chart = in.Chart
+	}
+	if in.RepoURL != "" {
+		hr.Spec.RepoURL = in.RepoURL
+	}
+	if in.Version != "" {
+		hr.Spec.Version = in.Version
+	}
+	if in.TargetNamespace != "" {
+		hr.Spec.TargetNamespace = in.TargetNamespace
+	}
+	return in.apply(hr)
+}
+
+// apply sets the fields of hr that create and update both replace.
+func (in ReleaseInput) apply(hr *helmv1alpha1.HelmRelease) error {
+	hr.Spec.ReleaseName = in.ReleaseName
+	hr.Spec.Values = nil
+	if in.Values != "" {
+		if !json.Valid([]byte(in.Values)) {
+			return apierrors.NewBadRequest("values must be JSON")
+		}
+		hr.Spec.Values = &apiextensionsv1.JSON{Raw: json.RawMessage(in.Values)}
+	}
+	ttl, err := parseTTL(in.TTL)
+	if err != nil {
+		return apierrors.NewBadRequest(err.Error())
+	}
+	hr.Spec.TTL = ttl
+	return nil
+}
+
+// releaseSummary is the audit detail of a created or updated release.
+func releaseSummary(hr *helmv1alpha1.HelmRelease) string {
+	return fmt.Sprintf("%s %s from %s", hr.Spec.Chart, hr.Spec.Version, hr.Spec.RepoURL)
+}
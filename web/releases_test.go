@@ -0,0 +1,81 @@
+package web_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"github.com/example/helm-operator/controllers"
+	"github.com/example/helm-operator/web"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("ReleaseService", func() {
+	ctx := context.Background()
+
+	newService := func(objs ...client.Object) web.ReleaseService {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(helmv1alpha1.AddToScheme(scheme)).To(Succeed())
+		return web.NewReleaseService(fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build())
+	}
+
+	input := web.ReleaseInput{
+		Name: "web", Namespace: "team-a", Chart: "nginx", RepoURL: "https://charts.example.com",
+		Version: "1.0.0", TargetNamespace: "team-a", Values: `{"replicaCount":2}`,
+	}
+
+	It("creates a release attributed to the user", func() {
+		svc := newService()
+		hr, err := svc.Create(ctx, input, "alice")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(hr.Spec.Values.Raw).To(MatchJSON(`{"replicaCount":2}`))
+		Expect(hr.Annotations).To(HaveKeyWithValue(controllers.AnnotationTriggeredBy, "alice"))
+
+		releases, err := svc.List(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(releases).To(HaveLen(1))
+	})
+
+	It("reports invalid input as BadRequest", func() {
+		svc := newService()
+		_, err := svc.Create(ctx, web.ReleaseInput{Name: "web"}, "")
+		Expect(apierrors.IsBadRequest(err)).To(BeTrue())
+
+		bad := input
+		bad.Values = "{replicaCount"
+		_, err = svc.Create(ctx, bad, "")
+		Expect(apierrors.IsBadRequest(err)).To(BeTrue())
+
+		bad = input
+		bad.TTL = "-1h"
+		_, err = svc.Create(ctx, bad, "")
+		Expect(apierrors.IsBadRequest(err)).To(BeTrue())
+	})
+
+	It("keeps unset chart coordinates on update and drops the previous user", func() {
+		svc := newService()
+		_, err := svc.Create(ctx, input, "alice")
+		Expect(err).NotTo(HaveOccurred())
+
+		hr, err := svc.Update(ctx, "team-a", "web", web.ReleaseInput{Version: "2.0.0"}, "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(hr.Spec.Version).To(Equal("2.0.0"))
+		Expect(hr.Spec.Chart).To(Equal("nginx"))
+		Expect(hr.Spec.Values).To(BeNil())
+		Expect(hr.Annotations).NotTo(HaveKey(controllers.AnnotationTriggeredBy))
+	})
+
+	It("reports missing releases as NotFound", func() {
+		svc := newService()
+		_, err := svc.Update(ctx, "team-a", "missing", input, "")
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		Expect(apierrors.IsNotFound(svc.Delete(ctx, "team-a", "missing"))).To(BeTrue())
+	})
+})
@@ -0,0 +1,56 @@
+package web_test
+
+import (
+	"encoding/csv"
+	"net/http"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Release report", func() {
+	var ts *testServer
+	BeforeEach(func() {
+		deployed := makeHR("team-a", "web")
+		deployed.Status.DeployedVersion = "1.0.0"
+		deployed.Status.HelmRevision = 3
+		broken := makeHR("team-b", "api")
+		meta.SetStatusCondition(&broken.Status.Conditions, metav1.Condition{
+			Type: "Ready", Status: metav1.ConditionFalse, Reason: "InstallFailed", Message: `chart "api", version 2.0.0 not found`,
+		})
+		ts = startServer([]client.Object{deployed, broken})
+	})
+
+	It("lists every release as CSV", func() {
+		resp, body := ts.do(http.MethodGet, "/api/reports/releases", nil)
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(resp.Header.Get("Content-Type")).To(Equal("text/csv; charset=utf-8"))
+		Expect(resp.Header.Get("Content-Disposition")).To(MatchRegexp(`^attachment; filename="helmreleases-\d{8}-\d{6}\.csv"$`))
+
+		rows, err := csv.NewReader(strings.NewReader(string(body))).ReadAll()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rows).To(HaveLen(3))
+		Expect(rows[0][:3]).To(Equal([]string{"namespace", "name", "chart"}))
+		byName := map[string][]string{}
+		for _, row := range rows[1:] {
+			Expect(row).To(HaveLen(len(rows[0])))
+			byName[row[1]] = row
+		}
+		Expect(byName["web"]).To(ContainElements("team-a", "1.0.0", "3"))
+		Expect(byName["api"][len(rows[0])-1]).To(Equal(`chart "api", version 2.0.0 not found`))
+	})
+
+	It("marks Excel reports as UTF-8", func() {
+		resp, body := ts.do(http.MethodGet, "/api/reports/releases?format=excel", nil)
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(string(body)).To(HavePrefix("\xef\xbb\xbfnamespace,name,"))
+
+		resp, _ = ts.do(http.MethodGet, "/api/reports/releases?format=xlsx", nil)
+		Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+	})
+})
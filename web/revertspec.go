@@ -0,0 +1,52 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// handleRevertSpec serves POST /api/helmreleases/revert-spec?ns=&name=:
+// it restores status.lastKnownGoodSpec, the spec of the last generation that
+// reconciled to Ready, and returns the updated release. spec.suspend is kept,
+// so reverting never resumes or suspends a release by surprise.
+func (s *WebServer) handleRevertSpec(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		httpError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	name, ns := r.URL.Query().Get("name"), r.URL.Query().Get("ns")
+	if name == "" || ns == "" {
+		httpError(w, "query params 'name' and 'ns' are required", http.StatusBadRequest)
+		return
+	}
+	var hr helmv1alpha1.HelmRelease
+	if err := s.Client.Get(r.Context(), types.NamespacedName{Name: name, Namespace: ns}, &hr); err != nil {
+		writeError(w, err)
+		return
+	}
+	if hr.Status.LastKnownGoodSpec == nil {
+		httpError(w, "the release has not been Ready yet; there is no spec to revert to", http.StatusConflict)
+		return
+	}
+	var good helmv1alpha1.HelmReleaseSpec
+	if err := json.Unmarshal(hr.Status.LastKnownGoodSpec.Raw, &good); err != nil {
+		httpError(w, "invalid status.lastKnownGoodSpec: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	good.Suspend = hr.Spec.Suspend
+
+	patch := client.MergeFrom(hr.DeepCopy())
+	from := releaseSummary(&hr)
+	hr.Spec = good
+	if err := s.Client.Patch(r.Context(), &hr, patch); err != nil {
+		writeError(w, err)
+		return
+	}
+	s.broadcastEvent("updated", &hr)
+	s.audit(r, "reverted-spec", &hr, "from "+from+" to "+releaseSummary(&hr))
+	writeJSON(w, hr)
+}
@@ -0,0 +1,46 @@
+package web_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Revert spec API", func() {
+	It("restores the last known good spec but keeps spec.suspend", func() {
+		hr := makeHR("team-a", "web")
+		hr.Spec.Values = &apiextensionsv1.JSON{Raw: []byte(`{"replicaCount":2}`)}
+		good, err := json.Marshal(hr.Spec)
+		Expect(err).NotTo(HaveOccurred())
+		hr.Status.LastKnownGoodSpec = &apiextensionsv1.JSON{Raw: good}
+		hr.Spec.Version = "2.0.0"
+		hr.Spec.Values = &apiextensionsv1.JSON{Raw: []byte(`{"replicaCount":"two"}`)}
+		hr.Spec.Suspend = true
+		ts := startServer([]client.Object{hr})
+
+		resp, body := ts.do(http.MethodPost, "/api/helmreleases/revert-spec?ns=team-a&name=web", nil)
+		Expect(resp.StatusCode).To(Equal(http.StatusOK), string(body))
+
+		var fetched helmv1alpha1.HelmRelease
+		Expect(ts.K8s.Get(context.Background(), client.ObjectKey{Namespace: "team-a", Name: "web"}, &fetched)).To(Succeed())
+		Expect(fetched.Spec.Version).To(Equal("1.0.0"))
+		Expect(string(fetched.Spec.Values.Raw)).To(MatchJSON(`{"replicaCount":2}`))
+		Expect(fetched.Spec.Suspend).To(BeTrue())
+	})
+
+	It("is a conflict for releases that were never Ready", func() {
+		ts := startServer([]client.Object{makeHR("team-a", "web")})
+
+		resp, _ := ts.do(http.MethodPost, "/api/helmreleases/revert-spec?ns=team-a&name=web", nil)
+		Expect(resp.StatusCode).To(Equal(http.StatusConflict))
+		resp, _ = ts.do(http.MethodPost, "/api/helmreleases/revert-spec?ns=team-a&name=missing", nil)
+		Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+	})
+})
@@ -0,0 +1,113 @@
+package web
+
+import (
+	"net/http"
+	"time"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"github.com/example/helm-operator/controllers"
+	"helm.sh/helm/v3/pkg/release"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// revision is one entry of GET /api/helmreleases/history.
+type revision struct {
+	Revision     int       `json:"revision"`
+	Status       string    `json:"status"`
+	Chart        string    `json:"chart"`
+	ChartVersion string    `json:"chartVersion"`
+	Updated      time.Time `json:"updated"`
+	// Description names the cause of the revision; see status.lastDeployTrigger.
+	Description string `json:"description"`
+}
+
+// handleReleaseHistory lists the Helm revisions of a HelmRelease, newest
+// first, with the description recording what triggered each.
+func (s *WebServer) handleReleaseHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.HelmClient == nil {
+		httpError(w, "release history is not available", http.StatusServiceUnavailable)
+		return
+	}
+	name, ns := r.URL.Query().Get("name"), r.URL.Query().Get("ns")
+	if name == "" || ns == "" {
+		httpError(w, "query params 'name' and 'ns' are required", http.StatusBadRequest)
+		return
+	}
+
+	var hr helmv1alpha1.HelmRelease
+	if err := s.Client.Get(r.Context(), types.NamespacedName{Name: name, Namespace: ns}, &hr); err != nil {
+		writeError(w, err)
+		return
+	}
+	releaseName, releaseNS := helmReleaseOf(&hr)
+	revisions, err := s.HelmClient.History(r.Context(), releaseName, releaseNS)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	out := make([]revision, 0, len(revisions))
+	for _, rel := range revisions {
+		out = append(out, revisionOf(rel))
+	}
+	writeJSON(w, out)
+}
+
+// revisionOf describes a Helm revision.
+func revisionOf(rel *release.Release) revision {
+	rev := revision{Revision: rel.Version}
+	if rel.Chart != nil && rel.Chart.Metadata != nil {
+		rev.Chart, rev.ChartVersion = rel.Chart.Metadata.Name, rel.Chart.Metadata.Version
+	}
+	if rel.Info != nil {
+		rev.Status = rel.Info.Status.String()
+		rev.Updated = rel.Info.LastDeployed.Time
+		rev.Description = rel.Info.Description
+	}
+	return rev
+}
+
+// handleReconciles serves GET /api/helmreleases/reconciles?name=&ns=: the
+// latest reconcile attempts of a HelmRelease, newest first.
+func (s *WebServer) handleReconciles(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.ReconcileLog == nil {
+		httpError(w, "reconcile history is not available", http.StatusServiceUnavailable)
+		return
+	}
+	name, ns := r.URL.Query().Get("name"), r.URL.Query().Get("ns")
+	if name == "" || ns == "" {
+		httpError(w, "query params 'name' and 'ns' are required", http.StatusBadRequest)
+		return
+	}
+
+	key := types.NamespacedName{Name: name, Namespace: ns}
+	var hr helmv1alpha1.HelmRelease
+	if err := s.Client.Get(r.Context(), key, &hr); err != nil {
+		writeError(w, err)
+		return
+	}
+	out := s.ReconcileLog.Records(key)
+	if out == nil {
+		out = []controllers.ReconcileRecord{}
+	}
+	writeJSON(w, out)
+}
+
+// helmReleaseOf returns the name and namespace of the Helm release managed
+// by hr: the deployed one, or the one it will install.
+func helmReleaseOf(hr *helmv1alpha1.HelmRelease) (string, string) {
+	if hr.Status.ReleaseName != "" {
+		return hr.Status.ReleaseName, hr.Status.ReleaseNamespace
+	}
+	if hr.Spec.ReleaseName != "" {
+		return hr.Spec.ReleaseName, hr.Spec.TargetNamespace
+	}
+	return hr.Name, hr.Spec.TargetNamespace
+}
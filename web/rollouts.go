@@ -0,0 +1,546 @@
+package web
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"github.com/example/helm-operator/store"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/labels"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// defaultRolloutPollInterval is how often a rollout checks its members when
+// WebServer.RolloutPollInterval is unset.
+const defaultRolloutPollInterval = 10 * time.Second
+
+// Rollout states.
+const (
+	rolloutRunning   = "Running"
+	rolloutPaused    = "Paused"
+	rolloutSucceeded = "Succeeded"
+	rolloutHalted    = "Halted"
+	rolloutAborted   = "Aborted"
+)
+
+// Rollout member states.
+const (
+	memberPending   = "Pending"
+	memberUpgrading = "Upgrading"
+	memberReady     = "Ready"
+	memberFailed    = "Failed"
+)
+
+// rolloutRequest is the body of POST /api/rollouts.
+type rolloutRequest struct {
+	// Selector picks the HelmReleases to roll out; Namespace limits it.
+	Selector  string `json:"selector"`
+	Namespace string `json:"namespace,omitempty"`
+	// Version is set as spec.version of every member.
+	Version string `json:"version"`
+	// Waves are cumulative percentages of the members, such as [10, 50,
+	// 100]. The last must be 100. Defaults to [10, 50, 100].
+	Waves []int `json:"waves,omitempty"`
+	// Soak is how long a wave must stay Ready before the next one starts.
+	Soak string `json:"soak,omitempty"`
+	// Timeout bounds how long a wave may take to become Ready. Defaults to
+	// 15m.
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// rolloutStatus is the state of a rollout as served by /api/rollouts.
+type rolloutStatus struct {
+	ID        string `json:"id"`
+	Selector  string `json:"selector"`
+	Namespace string `json:"namespace,omitempty"`
+	Version   string `json:"version"`
+	Waves     []int  `json:"waves"`
+	Soak      string `json:"soak"`
+	Timeout   string `json:"timeout"`
+	// State is Running, Paused, Succeeded, Halted or Aborted.
+	State string `json:"state"`
+	// Wave is the index of the wave in progress, or of the last one.
+	Wave       int             `json:"wave"`
+	Message    string          `json:"message,omitempty"`
+	Members    []rolloutMember `json:"members"`
+	StartedBy  string          `json:"startedBy,omitempty"`
+	StartedAt  time.Time       `json:"startedAt"`
+	FinishedAt *time.Time      `json:"finishedAt,omitempty"`
+}
+
+type rolloutMember struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Wave      int    `json:"wave"`
+	// PreviousVersion is spec.version before the rollout changed it.
+	PreviousVersion string `json:"previousVersion,omitempty"`
+	// State is Pending, Upgrading, Ready or Failed.
+	State   string `json:"state"`
+	Message string `json:"message,omitempty"`
+}
+
+// rollout is a rollout in progress or finished. Its goroutine is the only
+// writer of status apart from pause, resume and abort, all under mu.
+type rollout struct {
+	mu      sync.Mutex
+	status  rolloutStatus
+	soak    time.Duration
+	timeout time.Duration
+	// resume is closed and replaced when a paused rollout is resumed.
+	resume chan struct{}
+	cancel context.CancelFunc
+}
+
+// rollouts keeps the rollouts started since the server started. They are not
+// persisted: a restart stops them, leaving members at the version they had
+// reached.
+type rollouts struct {
+	mu    sync.Mutex
+	items map[string]*rollout
+}
+
+func (rs *rollouts) add(ro *rollout) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if rs.items == nil {
+		rs.items = map[string]*rollout{}
+	}
+	rs.items[ro.status.ID] = ro
+}
+
+func (rs *rollouts) get(id string) *rollout {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	return rs.items[id]
+}
+
+// list returns every rollout, newest first.
+func (rs *rollouts) list() []rolloutStatus {
+	rs.mu.Lock()
+	items := make([]*rollout, 0, len(rs.items))
+	for _, ro := range rs.items {
+		items = append(items, ro)
+	}
+	rs.mu.Unlock()
+	out := make([]rolloutStatus, 0, len(items))
+	for _, ro := range items {
+		out = append(out, ro.snapshot())
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].StartedAt.After(out[j].StartedAt) })
+	return out
+}
+
+// snapshot returns a copy of the rollout's status.
+func (ro *rollout) snapshot() rolloutStatus {
+	ro.mu.Lock()
+	defer ro.mu.Unlock()
+	st := ro.status
+	st.Members = append([]rolloutMember(nil), ro.status.Members...)
+	return st
+}
+
+// finish ends the rollout in state, unless it already ended.
+func (ro *rollout) finish(state, message string) {
+	ro.mu.Lock()
+	defer ro.mu.Unlock()
+	if ro.done() {
+		return
+	}
+	now := time.Now()
+	ro.status.State, ro.status.Message, ro.status.FinishedAt = state, message, &now
+}
+
+// done reports whether the rollout has ended. Callers hold mu.
+func (ro *rollout) done() bool {
+	switch ro.status.State {
+	case rolloutSucceeded, rolloutHalted, rolloutAborted:
+		return true
+	}
+	return false
+}
+
+// setMember updates the state of member i.
+func (ro *rollout) setMember(i int, state, message string) {
+	ro.mu.Lock()
+	defer ro.mu.Unlock()
+	ro.status.Members[i].State, ro.status.Members[i].Message = state, message
+}
+
+// waitUntilResumed blocks while the rollout is paused. It returns false if
+// ctx ends first.
+func (ro *rollout) waitUntilResumed(ctx context.Context) bool {
+	for {
+		ro.mu.Lock()
+		paused, resume := ro.status.State == rolloutPaused, ro.resume
+		ro.mu.Unlock()
+		if !paused {
+			return true
+		}
+		select {
+		case <-resume:
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// handleRollouts serves GET /api/rollouts, which lists rollouts (or returns
+// the one named by ?id=), and POST /api/rollouts, which starts one.
+func (s *WebServer) handleRollouts(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		if id := r.URL.Query().Get("id"); id != "" {
+			ro := s.rollouts.get(id)
+			if ro == nil {
+				httpError(w, "rollout "+id+" not found", http.StatusNotFound)
+				return
+			}
+			writeJSON(w, ro.snapshot())
+			return
+		}
+		writeJSON(w, s.rollouts.list())
+	case http.MethodPost:
+		s.startRollout(w, r)
+	default:
+		httpError(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *WebServer) startRollout(w http.ResponseWriter, r *http.Request) {
+	var req rolloutRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpError(w, "invalid JSON body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	ro, err := newRollout(req)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	selector, err := labels.Parse(req.Selector)
+	if err != nil {
+		httpError(w, "invalid selector: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	var list helmv1alpha1.HelmReleaseList
+	if err := s.Client.List(r.Context(), &list, selectorListOptions(selector, req.Namespace)...); err != nil {
+		writeError(w, err)
+		return
+	}
+	if len(list.Items) == 0 {
+		httpError(w, "no HelmRelease matches selector "+selector.String(), http.StatusBadRequest)
+		return
+	}
+	ro.status.Selector = selector.String()
+	ro.status.Members = assignWaves(list.Items, ro.status.Waves)
+	ro.status.StartedBy = requestUser(r)
+
+	// The rollout outlives the request, but keeps its values: patches are
+	// checked by guardedClient against the token and user that started it.
+	ctx, cancel := context.WithCancel(context.WithoutCancel(r.Context()))
+	ro.cancel = cancel
+	s.rollouts.add(ro)
+	s.auditRollout(ctx, "rollout started", ro, fmt.Sprintf("version %s to %d releases in waves %v", req.Version, len(list.Items), ro.status.Waves))
+	go s.runRollout(ctx, ro)
+
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, ro.snapshot())
+}
+
+// newRollout validates req and returns a rollout without members.
+func newRollout(req rolloutRequest) (*rollout, error) {
+	if req.Selector == "" || req.Version == "" {
+		return nil, fmt.Errorf("selector and version are required")
+	}
+	waves := req.Waves
+	if len(waves) == 0 {
+		waves = []int{10, 50, 100}
+	}
+	for i, pct := range waves {
+		if pct <= 0 || pct > 100 || (i > 0 && pct <= waves[i-1]) {
+			return nil, fmt.Errorf("waves must be increasing percentages between 1 and 100")
+		}
+	}
+	if waves[len(waves)-1] != 100 {
+		return nil, fmt.Errorf("the last wave must be 100")
+	}
+	ro := &rollout{timeout: 15 * time.Minute, resume: make(chan struct{})}
+	var err error
+	if req.Soak != "" {
+		if ro.soak, err = time.ParseDuration(req.Soak); err != nil || ro.soak < 0 {
+			return nil, fmt.Errorf("invalid soak %q", req.Soak)
+		}
+	}
+	if req.Timeout != "" {
+		if ro.timeout, err = time.ParseDuration(req.Timeout); err != nil || ro.timeout <= 0 {
+			return nil, fmt.Errorf("invalid timeout %q", req.Timeout)
+		}
+	}
+	ro.status = rolloutStatus{
+		ID:        newRolloutID(),
+		Namespace: req.Namespace,
+		Version:   req.Version,
+		Waves:     waves,
+		Soak:      ro.soak.String(),
+		Timeout:   ro.timeout.String(),
+		State:     rolloutRunning,
+		StartedAt: time.Now(),
+	}
+	return ro, nil
+}
+
+func newRolloutID() string {
+	b := make([]byte, 6)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// assignWaves orders releases by namespace and name and assigns each to the
+// first wave whose cumulative percentage covers it. With few members a wave
+// may get none.
+func assignWaves(releases []helmv1alpha1.HelmRelease, waves []int) []rolloutMember {
+	sort.Slice(releases, func(i, j int) bool {
+		if releases[i].Namespace != releases[j].Namespace {
+			return releases[i].Namespace < releases[j].Namespace
+		}
+		return releases[i].Name < releases[j].Name
+	})
+	members := make([]rolloutMember, 0, len(releases))
+	wave := 0
+	for i, hr := range releases {
+		for wave < len(waves)-1 && i >= waveEnd(len(releases), waves[wave]) {
+			wave++
+		}
+		members = append(members, rolloutMember{Namespace: hr.Namespace, Name: hr.Name, Wave: wave, State: memberPending})
+	}
+	return members
+}
+
+// waveEnd is the number of the n members covered by a wave of pct percent,
+// rounded up.
+func waveEnd(n, pct int) int {
+	return (n*pct + 99) / 100
+}
+
+// runRollout upgrades the rollout's members wave by wave. A wave is done
+// once all of its members are Ready with the new version and stayed so for
+// the soak time. A member that fails, or a wave that times out, halts the
+// rollout; members already upgraded keep the new version.
+func (s *WebServer) runRollout(ctx context.Context, ro *rollout) {
+	log := ctrl.LoggerFrom(ctx).WithValues("rollout", ro.status.ID)
+	defer ro.cancel()
+	for wave := range ro.status.Waves {
+		if ctx.Err() != nil || !ro.waitUntilResumed(ctx) {
+			return
+		}
+		ro.mu.Lock()
+		ro.status.Wave = wave
+		ro.mu.Unlock()
+		log.Info("Starting rollout wave", "wave", wave)
+		msg := s.upgradeWave(ctx, ro, wave)
+		if ctx.Err() != nil {
+			return
+		}
+		if msg != "" {
+			ro.finish(rolloutHalted, msg)
+			s.auditRollout(ctx, "rollout halted", ro, msg)
+			return
+		}
+	}
+	ro.finish(rolloutSucceeded, "")
+	s.auditRollout(ctx, "rollout succeeded", ro, "")
+}
+
+// upgradeWave sets the version of the members of wave and waits for them
+// to become Ready and soak. It returns why the rollout must halt, or "".
+func (s *WebServer) upgradeWave(ctx context.Context, ro *rollout, wave int) string {
+	version := ro.status.Version
+	var indexes []int
+	for i, m := range ro.snapshot().Members {
+		if m.Wave != wave {
+			continue
+		}
+		indexes = append(indexes, i)
+		var hr helmv1alpha1.HelmRelease
+		if err := s.Client.Get(ctx, client.ObjectKey{Namespace: m.Namespace, Name: m.Name}, &hr); err != nil {
+			ro.setMember(i, memberFailed, err.Error())
+			return fmt.Sprintf("%s/%s: %v", m.Namespace, m.Name, err)
+		}
+		ro.mu.Lock()
+		ro.status.Members[i].PreviousVersion = hr.Spec.Version
+		ro.mu.Unlock()
+		if hr.Spec.Version != version {
+			patch := client.MergeFrom(hr.DeepCopy())
+			hr.Spec.Version = version
+			if err := s.Client.Patch(ctx, &hr, patch); err != nil {
+				ro.setMember(i, memberFailed, err.Error())
+				return fmt.Sprintf("%s/%s: %v", m.Namespace, m.Name, err)
+			}
+			s.broadcastEvent("updated", &hr)
+		}
+		ro.setMember(i, memberUpgrading, "")
+	}
+	if len(indexes) == 0 {
+		return ""
+	}
+
+	deadline := time.Now().Add(ro.timeout)
+	var soakUntil time.Time
+	ticker := time.NewTicker(s.rolloutPollInterval())
+	defer ticker.Stop()
+	for {
+		ready, msg := s.checkWave(ctx, ro, indexes)
+		if msg != "" {
+			return msg
+		}
+		now := time.Now()
+		switch {
+		case !ready && !soakUntil.IsZero():
+			return fmt.Sprintf("wave %d stopped being Ready while soaking", wave)
+		case !ready && now.After(deadline):
+			return fmt.Sprintf("wave %d did not become Ready within %s", wave, ro.timeout)
+		case ready && soakUntil.IsZero():
+			soakUntil = now.Add(ro.soak)
+		}
+		if ready && !now.Before(soakUntil) {
+			return ""
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ""
+		}
+	}
+}
+
+// checkWave reads the members at indexes and records their state. It
+// reports whether all are Ready with the rollout's version, or why the
+// rollout must halt.
+func (s *WebServer) checkWave(ctx context.Context, ro *rollout, indexes []int) (bool, string) {
+	members := ro.snapshot().Members
+	ready := true
+	for _, i := range indexes {
+		m := members[i]
+		var hr helmv1alpha1.HelmRelease
+		if err := s.Client.Get(ctx, client.ObjectKey{Namespace: m.Namespace, Name: m.Name}, &hr); err != nil {
+			ro.setMember(i, memberFailed, err.Error())
+			return false, fmt.Sprintf("%s/%s: %v", m.Namespace, m.Name, err)
+		}
+		current := hr.Status.ObservedGeneration == hr.Generation
+		switch {
+		case hr.Spec.Version != ro.status.Version:
+			ro.setMember(i, memberFailed, "spec.version was changed to "+hr.Spec.Version)
+			return false, fmt.Sprintf("%s/%s: spec.version was changed outside the rollout", m.Namespace, m.Name)
+		case current && hr.Status.Phase == helmv1alpha1.PhaseFailed:
+			msg := "release failed"
+			if c := meta.FindStatusCondition(hr.Status.Conditions, "Ready"); c != nil {
+				msg = c.Message
+			}
+			ro.setMember(i, memberFailed, msg)
+			return false, fmt.Sprintf("%s/%s failed: %s", m.Namespace, m.Name, msg)
+		case current && hr.Status.Phase == helmv1alpha1.PhaseReady &&
+			hr.Status.LastApplied != nil && hr.Status.LastApplied.Version == ro.status.Version:
+			ro.setMember(i, memberReady, "")
+		default:
+			ro.setMember(i, memberUpgrading, "")
+			ready = false
+		}
+	}
+	return ready, ""
+}
+
+func (s *WebServer) rolloutPollInterval() time.Duration {
+	if s.RolloutPollInterval > 0 {
+		return s.RolloutPollInterval
+	}
+	return defaultRolloutPollInterval
+}
+
+// handleRolloutPause serves POST /api/rollouts/pause?id=. The wave in
+// progress finishes; the next one waits for a resume.
+func (s *WebServer) handleRolloutPause(w http.ResponseWriter, r *http.Request) {
+	s.controlRollout(w, r, "rollout paused", func(ro *rollout) error {
+		if ro.status.State != rolloutRunning {
+			return fmt.Errorf("rollout is %s", ro.status.State)
+		}
+		ro.status.State = rolloutPaused
+		return nil
+	})
+}
+
+// handleRolloutResume serves POST /api/rollouts/resume?id=.
+func (s *WebServer) handleRolloutResume(w http.ResponseWriter, r *http.Request) {
+	s.controlRollout(w, r, "rollout resumed", func(ro *rollout) error {
+		if ro.status.State != rolloutPaused {
+			return fmt.Errorf("rollout is %s", ro.status.State)
+		}
+		ro.status.State = rolloutRunning
+		close(ro.resume)
+		ro.resume = make(chan struct{})
+		return nil
+	})
+}
+
+// handleRolloutAbort serves POST /api/rollouts/abort?id=. Members keep the
+// version they have reached.
+func (s *WebServer) handleRolloutAbort(w http.ResponseWriter, r *http.Request) {
+	s.controlRollout(w, r, "rollout aborted", func(ro *rollout) error {
+		if ro.done() {
+			return fmt.Errorf("rollout is %s", ro.status.State)
+		}
+		now := time.Now()
+		ro.status.State, ro.status.FinishedAt = rolloutAborted, &now
+		ro.status.Message = "aborted by " + requestUser(r)
+		ro.cancel()
+		return nil
+	})
+}
+
+// controlRollout applies change to the rollout named by ?id= under its lock.
+// An error from change is a conflict with the rollout's state.
+func (s *WebServer) controlRollout(w http.ResponseWriter, r *http.Request, action string, change func(*rollout) error) {
+	if r.Method != http.MethodPost {
+		httpError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id := r.URL.Query().Get("id")
+	ro := s.rollouts.get(id)
+	if ro == nil {
+		httpError(w, "rollout "+id+" not found", http.StatusNotFound)
+		return
+	}
+	ro.mu.Lock()
+	err := change(ro)
+	ro.mu.Unlock()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusConflict)
+		return
+	}
+	s.auditRollout(r.Context(), action, ro, "")
+	writeJSON(w, ro.snapshot())
+}
+
+// auditRollout records action on ro in the audit log.
+func (s *WebServer) auditRollout(ctx context.Context, action string, ro *rollout, detail string) {
+	st := ro.snapshot()
+	user := st.StartedBy
+	if r := contextRequest(ctx); r != nil {
+		user = requestUser(r)
+	}
+	if detail == "" {
+		detail = st.Message
+	}
+	entry := store.AuditEntry{User: user, Action: action, Namespace: st.Namespace, Name: "rollout/" + st.ID, Detail: detail}
+	if err := s.Store.AppendAudit(ctx, entry); err != nil {
+		ctrl.Log.Error(err, "Recording audit entry", "action", action, "rollout", st.ID)
+	}
+}
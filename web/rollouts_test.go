@@ -0,0 +1,144 @@
+package web_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"github.com/example/helm-operator/web"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Rollouts", func() {
+	ctx := context.Background()
+
+	type rollout struct {
+		ID      string `json:"id"`
+		State   string `json:"state"`
+		Wave    int    `json:"wave"`
+		Message string `json:"message"`
+		Members []struct {
+			Name            string `json:"name"`
+			Wave            int    `json:"wave"`
+			PreviousVersion string `json:"previousVersion"`
+			State           string `json:"state"`
+		} `json:"members"`
+	}
+
+	shop := func(name string) *helmv1alpha1.HelmRelease {
+		hr := makeHR("team-a", name)
+		hr.Labels = map[string]string{"stack": "shop"}
+		return hr
+	}
+
+	start := func(ts *testServer, body map[string]interface{}) rollout {
+		resp, data := ts.do(http.MethodPost, "/api/rollouts", body)
+		Expect(resp.StatusCode).To(Equal(http.StatusCreated), string(data))
+		var ro rollout
+		Expect(json.Unmarshal(data, &ro)).To(Succeed())
+		return ro
+	}
+
+	get := func(ts *testServer, id string) rollout {
+		resp, data := ts.do(http.MethodGet, "/api/rollouts?id="+id, nil)
+		Expect(resp.StatusCode).To(Equal(http.StatusOK), string(data))
+		var ro rollout
+		Expect(json.Unmarshal(data, &ro)).To(Succeed())
+		return ro
+	}
+
+	version := func(ts *testServer, name string) string {
+		var hr helmv1alpha1.HelmRelease
+		Expect(ts.K8s.Get(ctx, client.ObjectKey{Namespace: "team-a", Name: name}, &hr)).To(Succeed())
+		return hr.Spec.Version
+	}
+
+	// reconcile reports name as reconciled by the controller with phase.
+	reconcile := func(ts *testServer, name string, phase helmv1alpha1.Phase) {
+		var hr helmv1alpha1.HelmRelease
+		Expect(ts.K8s.Get(ctx, client.ObjectKey{Namespace: "team-a", Name: name}, &hr)).To(Succeed())
+		hr.Status.Phase = phase
+		hr.Status.ObservedGeneration = hr.Generation
+		hr.Status.LastApplied = &helmv1alpha1.AppliedSpec{Version: hr.Spec.Version}
+		Expect(ts.K8s.Update(ctx, &hr)).To(Succeed())
+	}
+
+	fast := func(s *web.WebServer) { s.RolloutPollInterval = 10 * time.Millisecond }
+
+	It("upgrades members wave by wave once the previous wave is Ready", func() {
+		ts := startServer([]client.Object{shop("a"), shop("b"), shop("c"), shop("d"), makeHR("team-a", "other")}, fast)
+
+		ro := start(ts, map[string]interface{}{"selector": "stack=shop", "version": "2.0.0", "waves": []int{25, 100}})
+		Expect(ro.Members).To(HaveLen(4))
+		Eventually(func() string { return version(ts, "a") }).Should(Equal("2.0.0"))
+		Consistently(func() string { return version(ts, "b") }, "100ms").Should(Equal("1.0.0"))
+
+		reconcile(ts, "a", helmv1alpha1.PhaseReady)
+		Eventually(func() string { return version(ts, "d") }).Should(Equal("2.0.0"))
+		for _, name := range []string{"b", "c", "d"} {
+			reconcile(ts, name, helmv1alpha1.PhaseReady)
+		}
+		Eventually(func() string { return get(ts, ro.ID).State }).Should(Equal("Succeeded"))
+		Expect(version(ts, "other")).To(Equal("1.0.0"))
+		Expect(get(ts, ro.ID).Members[0].PreviousVersion).To(Equal("1.0.0"))
+	})
+
+	It("halts when a member fails", func() {
+		ts := startServer([]client.Object{shop("a"), shop("b")}, fast)
+
+		ro := start(ts, map[string]interface{}{"selector": "stack=shop", "version": "2.0.0", "waves": []int{50, 100}})
+		Eventually(func() string { return version(ts, "a") }).Should(Equal("2.0.0"))
+		reconcile(ts, "a", helmv1alpha1.PhaseFailed)
+
+		Eventually(func() string { return get(ts, ro.ID).State }).Should(Equal("Halted"))
+		Expect(get(ts, ro.ID).Message).To(ContainSubstring("team-a/a failed"))
+		Expect(version(ts, "b")).To(Equal("1.0.0"))
+	})
+
+	It("pauses before the next wave and resumes", func() {
+		ts := startServer([]client.Object{shop("a"), shop("b")}, fast)
+
+		ro := start(ts, map[string]interface{}{"selector": "stack=shop", "version": "2.0.0", "waves": []int{50, 100}})
+		resp, _ := ts.do(http.MethodPost, "/api/rollouts/pause?id="+ro.ID, nil)
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		reconcile(ts, "a", helmv1alpha1.PhaseReady)
+		Consistently(func() string { return version(ts, "b") }, "100ms").Should(Equal("1.0.0"))
+
+		resp, _ = ts.do(http.MethodPost, "/api/rollouts/resume?id="+ro.ID, nil)
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Eventually(func() string { return version(ts, "b") }).Should(Equal("2.0.0"))
+	})
+
+	It("aborts, leaving members at the version they reached", func() {
+		ts := startServer([]client.Object{shop("a"), shop("b")}, fast)
+
+		ro := start(ts, map[string]interface{}{"selector": "stack=shop", "version": "2.0.0", "waves": []int{50, 100}})
+		Eventually(func() string { return version(ts, "a") }).Should(Equal("2.0.0"))
+		resp, _ := ts.do(http.MethodPost, "/api/rollouts/abort?id="+ro.ID, nil)
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		reconcile(ts, "a", helmv1alpha1.PhaseReady)
+
+		Consistently(func() string { return version(ts, "b") }, "100ms").Should(Equal("1.0.0"))
+		Expect(get(ts, ro.ID).State).To(Equal("Aborted"))
+		resp, _ = ts.do(http.MethodPost, "/api/rollouts/resume?id="+ro.ID, nil)
+		Expect(resp.StatusCode).To(Equal(http.StatusConflict))
+	})
+
+	DescribeTable("rejects invalid rollouts",
+		func(body map[string]interface{}) {
+			ts := startServer([]client.Object{shop("a")})
+			resp, _ := ts.do(http.MethodPost, "/api/rollouts", body)
+			Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+		},
+		Entry("without version", map[string]interface{}{"selector": "stack=shop"}),
+		Entry("without selector", map[string]interface{}{"version": "2.0.0"}),
+		Entry("waves not ending at 100", map[string]interface{}{"selector": "stack=shop", "version": "2.0.0", "waves": []int{10, 50}}),
+		Entry("decreasing waves", map[string]interface{}{"selector": "stack=shop", "version": "2.0.0", "waves": []int{50, 10, 100}}),
+		Entry("no matching release", map[string]interface{}{"selector": "stack=none", "version": "2.0.0"}),
+	)
+})
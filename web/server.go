@@ -7,11 +7,14 @@ import (
 	"fmt"
 	"io/fs"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
 	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
-	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"github.com/example/helm-operator/controllers"
+	"github.com/example/helm-operator/store"
+	"github.com/prometheus/client_golang/prometheus"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -65,21 +68,12 @@ func (b *broker) broadcast(payload string) {
 }
 
 // sseEvent wraps an event type and a HelmRelease resource into an SSE payload.
+// The "snapshot" event that opens every stream carries all releases in
+// Resources instead.
 type sseEvent struct {
-	Type     string                    `json:"type"`
-	Resource *helmv1alpha1.HelmRelease `json:"resource,omitempty"`
-}
-
-// createRequest is the body expected by POST /api/helmreleases.
-type createRequest struct {
-	Name            string `json:"name"`
-	Namespace       string `json:"namespace"`
-	Chart           string `json:"chart"`
-	RepoURL         string `json:"repoURL"`
-	Version         string `json:"version"`
-	TargetNamespace string `json:"targetNamespace"`
-	ReleaseName     string `json:"releaseName"`
-	Values          string `json:"values"` // raw JSON string, may be empty
+	Type      string                     `json:"type"`
+	Resource  *helmv1alpha1.HelmRelease  `json:"resource,omitempty"`
+	Resources []helmv1alpha1.HelmRelease `json:"resources,omitempty"`
 }
 
 // WebServer is a controller-runtime Runnable that serves the web UI and REST API.
@@ -87,26 +81,126 @@ type WebServer struct {
 	Client client.Client
 	Addr   string
 
-	broker *broker
+	// APIReader reads directly from the API server. Reports use it to page
+	// through large lists, which the cache-backed Client cannot do.
+	// Optional; defaults to Client.
+	APIReader client.Reader
+
+	// HelmClient gives read access to Helm release data (e.g. rendered NOTES).
+	// Optional; endpoints that need it degrade gracefully when nil.
+	HelmClient controllers.HelmClientInterface
+
+	// Breakers exposes the reconciler's per-repository circuit breakers on
+	// /api/repositories. Optional.
+	Breakers *controllers.RepositoryBreakers
+
+	// RepositoryMonitor serves /api/repositories/health. Optional.
+	RepositoryMonitor *controllers.RepositoryMonitor
+
+	// Encryption reports on /api/admin/stats whether release Secrets are
+	// encrypted at rest. Optional.
+	Encryption *controllers.EncryptionMonitor
+
+	// ReconcileLog serves /api/helmreleases/reconciles. Optional.
+	ReconcileLog *controllers.ReconcileLog
+
+	// Store keeps the audit log, diagnosis history and saved views.
+	// Defaults to an in-memory store.
+	Store store.Store
+
+	// LeaderElectionID and LeaderElectionNamespace locate the manager's
+	// leader election Lease for /api/admin/stats. The namespace defaults to
+	// the pod's own. Leave the ID empty when leader election is disabled.
+	LeaderElectionID        string
+	LeaderElectionNamespace string
+
+	// Metrics is read for the admin page statistics. Defaults to the
+	// controller-runtime metrics registry.
+	Metrics prometheus.Gatherer
+
+	// DefaultLocale is the UI language for browsers that accept none of the
+	// bundled ones, and fills in messages a language pack lacks. Defaults to
+	// DefaultLocale.
+	DefaultLocale string
+
+	// Admins are the users, as named by the authenticating proxy, who may
+	// manage API tokens.
+	Admins []string
+
+	// SlackApprovers are the Slack users, by name or ID, who may approve
+	// changes requested with /helmop. When empty, an approver needs RBAC
+	// permission, as the user slack:<name>, to update the HelmReleases the
+	// change touches. Requesters never approve their own changes.
+	SlackApprovers []string
+
+	// PreviewNamespaces are the namespaces /api/ci/preview may create
+	// previews in, besides those annotated helm.example.com/previews=true.
+	PreviewNamespaces []string
+	// PreviewRepoURLs are the chart repositories previews may install
+	// from, besides those a namespace lists in its
+	// helm.example.com/preview-repo-urls annotation.
+	PreviewRepoURLs []string
+	// PreviewServiceAccount is the ServiceAccount, in the preview's
+	// namespace, that Helm impersonates for previews unless the namespace
+	// names another in helm.example.com/preview-service-account. Previews
+	// are refused in namespaces without either.
+	PreviewServiceAccount string
+
+	// DiagnoseReview requires users other than Admins to be allowed
+	// the custom verb "diagnose" on a HelmRelease, by a SubjectAccessReview,
+	// to send it to the diagnosis service.
+	DiagnoseReview bool
+
+	// TokenSecret holds the hashed API tokens. Defaults to
+	// DefaultTokenSecretName in the operator's namespace.
+	TokenSecret types.NamespacedName
+
+	// AuthzWebhook, if set, must allow every change made through the API.
+	AuthzWebhook *AuthzWebhook
+
+	// CRD is the installed HelmRelease CRD as checked at startup, reported
+	// by /api/version. Optional.
+	CRD *controllers.CRDStatus
+
+	// Rewrites are applied to the values served by
+	// /api/helmreleases/effective-values, as the controller applies them.
+	Rewrites controllers.RewriteRules
+
+	// RolloutPollInterval is how often a rollout started with
+	// /api/rollouts checks its members. Defaults to 10s.
+	RolloutPollInterval time.Duration
+
+	// Releases serves the /api/helmreleases endpoints. Defaults to a
+	// ReleaseService backed by Client, with its writes checked like the
+	// other endpoints'.
+	Releases ReleaseService
+
+	broker   *broker
+	stats    statsHistory
+	rollouts rollouts
+	tokens   *tokenStore
+	// reviews creates SubjectAccessReviews, bypassing guardedClient.
+	reviews client.Client
+}
+
+// reader returns APIReader, falling back to the cache-backed Client.
+func (s *WebServer) reader() client.Reader {
+	if s.APIReader != nil {
+		return s.APIReader
+	}
+	return s.Client
 }
 
 // Start implements manager.Runnable.
 // The manager calls this after the cache is synced and cancels ctx on shutdown.
 func (s *WebServer) Start(ctx context.Context) error {
-	s.broker = newBroker()
-
-	sub, err := fs.Sub(staticFS, "static")
+	handler, err := s.Handler()
 	if err != nil {
-		return fmt.Errorf("web: embed sub: %w", err)
+		return err
 	}
+	srv := &http.Server{Addr: s.Addr, Handler: handler}
 
-	mux := http.NewServeMux()
-	mux.Handle("/", http.FileServer(http.FS(sub)))
-	mux.HandleFunc("/api/helmreleases", s.handleHelmReleases)
-	mux.HandleFunc("/api/events", s.handleSSE)
-	mux.HandleFunc("/api/diagnose", s.handleDiagnose)
-
-	srv := &http.Server{Addr: s.Addr, Handler: mux}
+	go s.sampleStats(ctx)
 
 	go func() {
 		<-ctx.Done()
@@ -122,6 +216,88 @@ func (s *WebServer) Start(ctx context.Context) error {
 	return nil
 }
 
+// Handler fills in defaults and returns the handler serving the UI and the
+// API. Start calls it; tests serve it with httptest instead. Call it once:
+// it wraps Client.
+func (s *WebServer) Handler() (http.Handler, error) {
+	s.broker = newBroker()
+	if s.Store == nil {
+		s.Store = store.NewMemory(0)
+	}
+
+	if _, ok := locales[s.defaultLocale()]; !ok {
+		return nil, fmt.Errorf("web: no locale bundle for default locale %q (have %s)",
+			s.defaultLocale(), strings.Join(availableLocales(), ", "))
+	}
+
+	if s.TokenSecret.Name == "" {
+		s.TokenSecret.Name = DefaultTokenSecretName
+	}
+	if s.TokenSecret.Namespace == "" {
+		if ns, err := podNamespace(); err == nil {
+			s.TokenSecret.Namespace = ns
+		} else {
+			ctrl.Log.Info("API tokens disabled: cannot determine the operator's namespace; set the token Secret explicitly", "error", err.Error())
+		}
+	}
+	if s.TokenSecret.Namespace != "" {
+		s.tokens = &tokenStore{client: s.Client, key: s.TokenSecret}
+	}
+	s.reviews = s.Client
+	s.Client = guardedClient{Client: s.Client, authz: s.AuthzWebhook}
+	if s.Releases == nil {
+		s.Releases = NewReleaseService(s.Client)
+	}
+
+	sub, err := fs.Sub(staticFS, "static")
+	if err != nil {
+		return nil, fmt.Errorf("web: embed sub: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/", http.FileServer(http.FS(sub)))
+	mux.HandleFunc("/api/helmreleases", s.handleHelmReleases)
+	mux.HandleFunc("/api/helmreleases/history", s.handleReleaseHistory)
+	mux.HandleFunc("/api/helmreleases/reconciles", s.handleReconciles)
+	mux.HandleFunc("/api/helmreleases/values", s.handleReleaseValues)
+	mux.HandleFunc("/api/helmreleases/values-provenance", s.handleValuesProvenance)
+	mux.HandleFunc("/api/helmreleases/effective-values", s.handleEffectiveValues)
+	mux.HandleFunc("/api/helmreleases/suspend", s.handleSuspend)
+	mux.HandleFunc("/api/helmreleases/resume", s.handleResume)
+	mux.HandleFunc("/api/helmreleases/revert-spec", s.handleRevertSpec)
+	mux.HandleFunc("/api/helmreleases/impact", s.handleImpact)
+	mux.HandleFunc("/api/helmreleases/at", s.handleReleaseAt)
+	mux.HandleFunc("/api/rollouts", s.handleRollouts)
+	mux.HandleFunc("/api/rollouts/pause", s.handleRolloutPause)
+	mux.HandleFunc("/api/rollouts/resume", s.handleRolloutResume)
+	mux.HandleFunc("/api/rollouts/abort", s.handleRolloutAbort)
+	mux.HandleFunc("/api/events", s.handleSSE)
+	mux.HandleFunc("/api/diagnose", s.handleDiagnose)
+	mux.HandleFunc("/api/ci/preview", s.handleCIPreview)
+	mux.HandleFunc("/api/repositories", s.handleRepositories)
+	mux.HandleFunc("/api/repositories/health", s.handleRepositoryHealth)
+	mux.HandleFunc("/api/lint", s.handleLint)
+	mux.HandleFunc("/api/charts/inspect", s.handleChartInspect)
+	mux.HandleFunc("/api/audit", s.handleAudit)
+	mux.HandleFunc("/api/diagnoses", s.handleDiagnoses)
+	mux.HandleFunc("/api/views", s.handleViews)
+	mux.HandleFunc("/api/reports/releases", s.handleReleaseReport)
+	mux.HandleFunc("/api/admin/stats", s.handleAdminStats)
+	mux.HandleFunc("/api/admin/helm", s.handleHelmCommand)
+	mux.HandleFunc("/api/permissions/patch", s.handlePermissionsPatch)
+	mux.HandleFunc("/api/graph", s.handleGraph)
+	mux.HandleFunc("/api/discover/releases", s.handleDiscoverReleases)
+	mux.HandleFunc("/api/discover/adopt", s.handleAdopt)
+	mux.HandleFunc("/api/i18n", s.handleI18n)
+	mux.HandleFunc("/api/command", s.handleCommand)
+	mux.HandleFunc("/api/slack/command", s.handleSlackCommand)
+	mux.HandleFunc("/api/slack/interactions", s.handleSlackInteraction)
+	mux.HandleFunc("/api/tokens", s.handleTokens)
+	mux.HandleFunc("/api/tokens/rotate", s.handleRotateToken)
+	mux.HandleFunc("/api/version", s.handleVersion)
+	return withRequestID(s.authenticate(mux)), nil
+}
+
 // handleHelmReleases routes GET/POST/PUT/DELETE for /api/helmreleases.
 func (s *WebServer) handleHelmReleases(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
@@ -134,53 +310,33 @@ func (s *WebServer) handleHelmReleases(w http.ResponseWriter, r *http.Request) {
 	case http.MethodDelete:
 		s.deleteRelease(w, r)
 	default:
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
 func (s *WebServer) listReleases(w http.ResponseWriter, r *http.Request) {
-	var list helmv1alpha1.HelmReleaseList
-	if err := s.Client.List(r.Context(), &list); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	releases, err := s.Releases.List(r.Context())
+	if err != nil {
+		writeError(w, err)
 		return
 	}
-	writeJSON(w, list.Items)
+	writeJSON(w, releases)
 }
 
 func (s *WebServer) createRelease(w http.ResponseWriter, r *http.Request) {
-	var req createRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "invalid JSON body: "+err.Error(), http.StatusBadRequest)
-		return
-	}
-	if req.Name == "" || req.Namespace == "" || req.Chart == "" || req.RepoURL == "" || req.Version == "" || req.TargetNamespace == "" {
-		http.Error(w, "name, namespace, chart, repoURL, version, and targetNamespace are required", http.StatusBadRequest)
+	var in ReleaseInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		httpError(w, "invalid JSON body: "+err.Error(), http.StatusBadRequest)
 		return
 	}
-
-	hr := &helmv1alpha1.HelmRelease{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      req.Name,
-			Namespace: req.Namespace,
-		},
-		Spec: helmv1alpha1.HelmReleaseSpec{
-			Chart:           req.Chart,
-			RepoURL:         req.RepoURL,
-			Version:         req.Version,
-			TargetNamespace: req.TargetNamespace,
-			ReleaseName:     req.ReleaseName,
-		},
-	}
-	if req.Values != "" {
-		hr.Spec.Values = &apiextensionsv1.JSON{Raw: json.RawMessage(req.Values)}
-	}
-
-	if err := s.Client.Create(r.Context(), hr); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	hr, err := s.Releases.Create(r.Context(), in, requestUser(r))
+	if err != nil {
+		writeError(w, err)
 		return
 	}
 
 	s.broadcastEvent("created", hr)
+	s.audit(r, "created", hr, releaseSummary(hr))
 	w.WriteHeader(http.StatusCreated)
 	writeJSON(w, hr)
 }
@@ -189,48 +345,22 @@ func (s *WebServer) updateRelease(w http.ResponseWriter, r *http.Request) {
 	name := r.URL.Query().Get("name")
 	ns := r.URL.Query().Get("ns")
 	if name == "" || ns == "" {
-		http.Error(w, "query params 'name' and 'ns' are required", http.StatusBadRequest)
+		httpError(w, "query params 'name' and 'ns' are required", http.StatusBadRequest)
 		return
 	}
-
-	var req createRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "invalid JSON body: "+err.Error(), http.StatusBadRequest)
+	var in ReleaseInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		httpError(w, "invalid JSON body: "+err.Error(), http.StatusBadRequest)
 		return
 	}
-
-	var hr helmv1alpha1.HelmRelease
-	if err := s.Client.Get(r.Context(), types.NamespacedName{Name: name, Namespace: ns}, &hr); err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
-		return
-	}
-
-	patch := client.MergeFrom(hr.DeepCopy())
-	if req.Chart != "" {
-		hr.Spec.Chart = req.Chart
-	}
-	if req.RepoURL != "" {
-		hr.Spec.RepoURL = req.RepoURL
-	}
-	if req.Version != "" {
-		hr.Spec.Version = req.Version
-	}
-	if req.TargetNamespace != "" {
-		hr.Spec.TargetNamespace = req.TargetNamespace
-	}
-	hr.Spec.ReleaseName = req.ReleaseName
-	if req.Values != "" {
-		hr.Spec.Values = &apiextensionsv1.JSON{Raw: json.RawMessage(req.Values)}
-	} else {
-		hr.Spec.Values = nil
-	}
-
-	if err := s.Client.Patch(r.Context(), &hr, patch); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	hr, err := s.Releases.Update(r.Context(), ns, name, in, requestUser(r))
+	if err != nil {
+		writeError(w, err)
 		return
 	}
 
-	s.broadcastEvent("updated", &hr)
+	s.broadcastEvent("updated", hr)
+	s.audit(r, "updated", hr, releaseSummary(hr))
 	writeJSON(w, hr)
 }
 
@@ -238,40 +368,52 @@ func (s *WebServer) deleteRelease(w http.ResponseWriter, r *http.Request) {
 	name := r.URL.Query().Get("name")
 	ns := r.URL.Query().Get("ns")
 	if name == "" || ns == "" {
-		http.Error(w, "query params 'name' and 'ns' are required", http.StatusBadRequest)
+		httpError(w, "query params 'name' and 'ns' are required", http.StatusBadRequest)
+		return
+	}
+	if err := s.Releases.Delete(r.Context(), ns, name); err != nil {
+		writeError(w, err)
 		return
 	}
 
 	hr := &helmv1alpha1.HelmRelease{}
 	hr.Name = name
 	hr.Namespace = ns
-
-	if err := s.Client.Delete(r.Context(), hr); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
 	s.broadcastEvent("deleted", hr)
+	s.audit(r, "deleted", hr, "")
 	w.WriteHeader(http.StatusNoContent)
 }
 
 // handleSSE streams HelmRelease events to the browser via Server-Sent Events.
+// The stream opens with a "snapshot" event listing every release, followed
+// by the changes since. The client subscribes before the list is read, so no
+// change falls between the two; a change the snapshot already contains may
+// be sent again, which is harmless since events carry whole resources.
 func (s *WebServer) handleSSE(w http.ResponseWriter, r *http.Request) {
 	flusher, ok := w.(http.Flusher)
 	if !ok {
-		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		httpError(w, "streaming not supported", http.StatusInternalServerError)
 		return
 	}
 
-	w.Header().Set("Content-Type", "text/event-stream")
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
-
 	sub := s.broker.subscribe()
 	defer s.broker.unsubscribe(sub)
 
-	// Send a ping immediately so the browser knows it is connected.
-	fmt.Fprintf(w, "data: {\"type\":\"ping\"}\n\n")
+	releases, err := s.Releases.List(r.Context())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	snapshot, err := json.Marshal(sseEvent{Type: "snapshot", Resources: releases})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	fmt.Fprintf(w, "data: %s\n\n", snapshot)
 	flusher.Flush()
 
 	ticker := time.NewTicker(15 * time.Second)
@@ -303,6 +445,48 @@ func (s *WebServer) broadcastEvent(eventType string, hr *helmv1alpha1.HelmReleas
 	s.broker.broadcast(string(data))
 }
 
+// handleRepositories lists the circuit breaker state of every chart
+// repository with recent install/upgrade failures.
+func (s *WebServer) handleRepositories(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	states := s.Breakers.Snapshot()
+	if states == nil {
+		states = []controllers.RepositoryState{}
+	}
+	writeJSON(w, states)
+}
+
+// handleRepositoryHealth returns the RepositoryMonitor's latest index checks.
+func (s *WebServer) handleRepositoryHealth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	health := s.RepositoryMonitor.Health()
+	if health == nil {
+		health = []controllers.RepositoryHealth{}
+	}
+	writeJSON(w, health)
+}
+
+// parseTTL converts the optional TTL form field into a spec duration.
+func parseTTL(s string) (*metav1.Duration, error) {
+	if s == "" {
+		return nil, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ttl: %w", err)
+	}
+	if d <= 0 {
+		return nil, fmt.Errorf("invalid ttl: must be positive")
+	}
+	return &metav1.Duration{Duration: d}, nil
+}
+
 func writeJSON(w http.ResponseWriter, v interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	enc := json.NewEncoder(w)
@@ -0,0 +1,210 @@
+package web_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func makeHR(namespace, name string) *helmv1alpha1.HelmRelease {
+	return &helmv1alpha1.HelmRelease{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec: helmv1alpha1.HelmReleaseSpec{
+			Chart:           "nginx",
+			RepoURL:         "https://charts.example.com",
+			Version:         "1.0.0",
+			TargetNamespace: namespace,
+		},
+	}
+}
+
+var createBody = map[string]string{
+	"name":            "web",
+	"namespace":       "team-a",
+	"chart":           "nginx",
+	"repoURL":         "https://charts.example.com",
+	"version":         "1.0.0",
+	"targetNamespace": "team-a",
+	"values":          `{"replicaCount":2}`,
+	"ttl":             "1h",
+}
+
+var _ = Describe("HelmRelease API", func() {
+	ctx := context.Background()
+
+	It("creates, lists, updates and deletes releases", func() {
+		ts := startServer(nil)
+
+		resp, _ := ts.do(http.MethodPost, "/api/helmreleases", createBody, "X-Forwarded-User", "alice")
+		Expect(resp.StatusCode).To(Equal(http.StatusCreated))
+		var hr helmv1alpha1.HelmRelease
+		Expect(ts.K8s.Get(ctx, types.NamespacedName{Namespace: "team-a", Name: "web"}, &hr)).To(Succeed())
+		Expect(hr.Spec.Values.Raw).To(MatchJSON(`{"replicaCount":2}`))
+		Expect(hr.Spec.TTL.Duration).To(Equal(time.Hour))
+
+		resp, body := ts.do(http.MethodGet, "/api/helmreleases", nil)
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		var list []helmv1alpha1.HelmRelease
+		Expect(json.Unmarshal(body, &list)).To(Succeed())
+		Expect(list).To(HaveLen(1))
+		Expect(list[0].Name).To(Equal("web"))
+
+		update := map[string]string{"version": "2.0.0"}
+		resp, _ = ts.do(http.MethodPut, "/api/helmreleases?ns=team-a&name=web", update)
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(ts.K8s.Get(ctx, types.NamespacedName{Namespace: "team-a", Name: "web"}, &hr)).To(Succeed())
+		Expect(hr.Spec.Version).To(Equal("2.0.0"))
+		Expect(hr.Spec.Chart).To(Equal("nginx"))
+		Expect(hr.Spec.Values).To(BeNil())
+
+		resp, _ = ts.do(http.MethodDelete, "/api/helmreleases?ns=team-a&name=web", nil)
+		Expect(resp.StatusCode).To(Equal(http.StatusNoContent))
+		Expect(ts.K8s.Get(ctx, types.NamespacedName{Namespace: "team-a", Name: "web"}, &hr)).NotTo(Succeed())
+
+		resp, body = ts.do(http.MethodGet, "/api/audit?ns=team-a&name=web", nil)
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		var audit []map[string]interface{}
+		Expect(json.Unmarshal(body, &audit)).To(Succeed())
+		Expect(audit).To(HaveLen(3))
+		Expect(audit[0]["action"]).To(Equal("deleted"))
+		Expect(audit[2]["action"]).To(Equal("created"))
+		Expect(audit[2]["user"]).To(Equal("alice"))
+	})
+
+	DescribeTable("rejects bad requests",
+		func(method, path string, body interface{}, status int) {
+			ts := startServer([]client.Object{makeHR("team-a", "existing")})
+			resp, _ := ts.do(method, path, body)
+			Expect(resp.StatusCode).To(Equal(status))
+		},
+		Entry("invalid JSON", http.MethodPost, "/api/helmreleases", "not an object", http.StatusBadRequest),
+		Entry("missing fields", http.MethodPost, "/api/helmreleases", map[string]string{"name": "web"}, http.StatusBadRequest),
+		Entry("invalid ttl", http.MethodPost, "/api/helmreleases", withField(createBody, "ttl", "soon"), http.StatusBadRequest),
+		Entry("duplicate name", http.MethodPost, "/api/helmreleases",
+			withField(createBody, "name", "existing"), http.StatusConflict),
+		Entry("update without name", http.MethodPut, "/api/helmreleases?ns=team-a", createBody, http.StatusBadRequest),
+		Entry("update of a missing release", http.MethodPut, "/api/helmreleases?ns=team-a&name=missing", createBody, http.StatusNotFound),
+		Entry("delete of a missing release", http.MethodDelete, "/api/helmreleases?ns=team-a&name=missing", nil, http.StatusNotFound),
+		Entry("unsupported method", http.MethodPatch, "/api/helmreleases", nil, http.StatusMethodNotAllowed),
+		Entry("invalid list limit", http.MethodGet, "/api/audit?limit=-1", nil, http.StatusBadRequest),
+		Entry("suspend without selector", http.MethodPost, "/api/helmreleases/suspend", nil, http.StatusBadRequest),
+	)
+
+	It("suspends only the releases matching the selector", func() {
+		frontend := makeHR("team-a", "frontend")
+		frontend.Labels = map[string]string{"stack": "shop"}
+		other := makeHR("team-a", "other")
+		ts := startServer([]client.Object{frontend, other})
+
+		resp, body := ts.do(http.MethodPost, "/api/helmreleases/suspend?selector=stack%3Dshop", nil)
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(string(body)).To(ContainSubstring(`"name": "frontend"`))
+
+		var hr helmv1alpha1.HelmRelease
+		Expect(ts.K8s.Get(ctx, client.ObjectKeyFromObject(frontend), &hr)).To(Succeed())
+		Expect(hr.Spec.Suspend).To(BeTrue())
+		Expect(ts.K8s.Get(ctx, client.ObjectKeyFromObject(other), &hr)).To(Succeed())
+		Expect(hr.Spec.Suspend).To(BeFalse())
+	})
+
+	It("streams changes as server-sent events", func() {
+		ts := startServer(nil)
+		reqCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		req, err := http.NewRequestWithContext(reqCtx, http.MethodGet, ts.URL+"/api/events", nil)
+		Expect(err).NotTo(HaveOccurred())
+		resp, err := ts.Server.Client().Do(req)
+		Expect(err).NotTo(HaveOccurred())
+		defer resp.Body.Close()
+		Expect(resp.Header.Get("Content-Type")).To(Equal("text/event-stream"))
+
+		events := bufio.NewReader(resp.Body)
+		readEvent := func() string {
+			line, err := events.ReadString('\n')
+			Expect(err).NotTo(HaveOccurred())
+			blank, err := events.ReadString('\n')
+			Expect(err).NotTo(HaveOccurred())
+			Expect(blank).To(Equal("\n"), "events end with a blank line")
+			data, ok := strings.CutPrefix(strings.TrimSuffix(line, "\n"), "data: ")
+			Expect(ok).To(BeTrue(), "event line %q", line)
+			return data
+		}
+		Expect(readEvent()).To(MatchJSON(`{"type":"snapshot"}`))
+
+		created, _ := ts.do(http.MethodPost, "/api/helmreleases", createBody)
+		Expect(created.StatusCode).To(Equal(http.StatusCreated))
+		var event struct {
+			Type     string                    `json:"type"`
+			Resource *helmv1alpha1.HelmRelease `json:"resource"`
+		}
+		Expect(json.Unmarshal([]byte(readEvent()), &event)).To(Succeed())
+		Expect(event.Type).To(Equal("created"))
+		Expect(event.Resource.Name).To(Equal("web"))
+	})
+
+	It("opens the event stream with a snapshot of all releases", func() {
+		ts := startServer([]client.Object{makeHR("team-a", "frontend"), makeHR("team-b", "backend")})
+		reqCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		req, err := http.NewRequestWithContext(reqCtx, http.MethodGet, ts.URL+"/api/events", nil)
+		Expect(err).NotTo(HaveOccurred())
+		resp, err := ts.Server.Client().Do(req)
+		Expect(err).NotTo(HaveOccurred())
+		defer resp.Body.Close()
+
+		line, err := bufio.NewReader(resp.Body).ReadString('\n')
+		Expect(err).NotTo(HaveOccurred())
+		var snapshot struct {
+			Type      string                     `json:"type"`
+			Resources []helmv1alpha1.HelmRelease `json:"resources"`
+		}
+		Expect(json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &snapshot)).To(Succeed())
+		Expect(snapshot.Type).To(Equal("snapshot"))
+		names := []string{}
+		for _, hr := range snapshot.Resources {
+			names = append(names, hr.Namespace+"/"+hr.Name)
+		}
+		Expect(names).To(ConsistOf("team-a/frontend", "team-b/backend"))
+	})
+})
+
+var _ = Describe("Views API", func() {
+	It("saves, lists and deletes views", func() {
+		ts := startServer(nil)
+
+		resp, _ := ts.do(http.MethodPut, "/api/views", map[string]string{"name": "failing", "query": `{"phase":"Failed"}`})
+		Expect(resp.StatusCode).To(Equal(http.StatusNoContent))
+		resp, body := ts.do(http.MethodGet, "/api/views", nil)
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(string(body)).To(ContainSubstring(`"name": "failing"`))
+
+		resp, _ = ts.do(http.MethodPut, "/api/views", map[string]string{"query": "{}"})
+		Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+
+		resp, _ = ts.do(http.MethodDelete, "/api/views?name=failing", nil)
+		Expect(resp.StatusCode).To(Equal(http.StatusNoContent))
+		resp, _ = ts.do(http.MethodDelete, "/api/views?name=failing", nil)
+		Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+	})
+})
+
+// withField returns a copy of body with key set to value.
+func withField(body map[string]string, key, value string) map[string]string {
+	out := map[string]string{}
+	for k, v := range body {
+		out[k] = v
+	}
+	out[key] = value
+	return out
+}
@@ -0,0 +1,523 @@
+package web
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// slackSecretEnv names the environment variable holding the Slack app's
+// signing secret. The Slack endpoints are disabled while it is unset.
+const slackSecretEnv = "SLACK_SIGNING_SECRET"
+
+// slackMaxSkew is how old a signed Slack request may be, against replays.
+const slackMaxSkew = 5 * time.Minute
+
+// slackListLimit caps the releases listed in one reply.
+const slackListLimit = 50
+
+// slackUsage is the reply to /helmop help.
+var slackUsage = strings.Join([]string{
+	"`/helmop list [selector]` lists releases",
+	"`/helmop status <namespace>/<name>` shows a release",
+	"`/helmop rollback <namespace>/<name> <revision>` reverts a release to the chart version of a Helm revision",
+	"`/helmop upgrade|suspend|resume|hold|unhold ...` runs a UI command",
+	"Changes are previewed and applied once someone clicks Approve.",
+}, "\n")
+
+// verifySlackRequest checks Slack's v0 request signature over body.
+func verifySlackRequest(secret string, header http.Header, body []byte, now time.Time) error {
+	ts := header.Get("X-Slack-Request-Timestamp")
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return fmt.Errorf("missing request timestamp")
+	}
+	if skew := now.Sub(time.Unix(sec, 0)); skew > slackMaxSkew || skew < -slackMaxSkew {
+		return fmt.Errorf("request timestamp too far from now")
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + ts + ":"))
+	mac.Write(body)
+	want := "v0=" + hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(want), []byte(header.Get("X-Slack-Signature"))) {
+		return fmt.Errorf("invalid signature")
+	}
+	return nil
+}
+
+// slackForm reads and verifies a signed Slack request and returns its form
+// values. It writes the error response itself and returns false on failure.
+func slackForm(w http.ResponseWriter, r *http.Request) (url.Values, bool) {
+	if r.Method != http.MethodPost {
+		httpError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return nil, false
+	}
+	secret := os.Getenv(slackSecretEnv)
+	if secret == "" {
+		httpError(w, slackSecretEnv+" not set", http.StatusServiceUnavailable)
+		return nil, false
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	if err := verifySlackRequest(secret, r.Header, body, time.Now()); err != nil {
+		httpError(w, "unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return nil, false
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	return form, true
+}
+
+// asSlackUser returns r attributed to a Slack user. Slack calls the operator
+// directly rather than through the authenticating proxy, so the verified
+// Slack user takes the place of the proxy's user header.
+func asSlackUser(r *http.Request, user string) *http.Request {
+	r = r.Clone(r.Context())
+	for _, h := range userHeaders {
+		r.Header.Del(h)
+	}
+	r.Header.Set(userHeaders[0], "slack:"+user)
+	return r
+}
+
+// slackMessage is a Slack message: a reply to a slash command or an update
+// sent to a response_url.
+type slackMessage struct {
+	ResponseType    string        `json:"response_type,omitempty"`
+	ReplaceOriginal bool          `json:"replace_original,omitempty"`
+	Text            string        `json:"text"`
+	Blocks          []interface{} `json:"blocks,omitempty"`
+}
+
+func slackText(text string) slackMessage {
+	return slackMessage{ResponseType: "ephemeral", Text: text}
+}
+
+// slackAction is what an Approve button applies, carried in its value.
+type slackAction struct {
+	Requester string `json:"requester"`
+	// RequesterID is the Slack user ID of the requester. Unlike the user
+	// name it cannot be changed, so it is what self-approval is checked on.
+	RequesterID string `json:"requesterID,omitempty"`
+	// Command is a canonical /api/command command.
+	Command string `json:"command,omitempty"`
+	// Release and Revision describe a rollback.
+	Release  string `json:"release,omitempty"`
+	Revision int    `json:"revision,omitempty"`
+}
+
+// slackApproval posts summary to the channel with Approve and Cancel
+// buttons, so a change requested in chat is applied only once confirmed.
+func slackApproval(summary string, action slackAction) slackMessage {
+	value, _ := json.Marshal(action)
+	button := func(text, id, style string) map[string]interface{} {
+		b := map[string]interface{}{
+			"type":      "button",
+			"text":      map[string]string{"type": "plain_text", "text": text},
+			"action_id": id,
+			"value":     string(value),
+		}
+		if style != "" {
+			b["style"] = style
+		}
+		return b
+	}
+	return slackMessage{
+		ResponseType: "in_channel",
+		Text:         summary,
+		Blocks: []interface{}{
+			map[string]interface{}{"type": "section", "text": map[string]string{"type": "mrkdwn", "text": summary}},
+			map[string]interface{}{"type": "actions", "elements": []interface{}{
+				button("Approve", "approve", "primary"),
+				button("Cancel", "cancel", "danger"),
+			}},
+		},
+	}
+}
+
+// handleSlackCommand serves the /helmop slash command.
+func (s *WebServer) handleSlackCommand(w http.ResponseWriter, r *http.Request) {
+	form, ok := slackForm(w, r)
+	if !ok {
+		return
+	}
+	user, userID := form.Get("user_name"), form.Get("user_id")
+	fields := strings.Fields(form.Get("text"))
+	if len(fields) == 0 {
+		writeJSON(w, slackText(slackUsage))
+		return
+	}
+	args := fields[1:]
+
+	switch strings.ToLower(fields[0]) {
+	case "help":
+		writeJSON(w, slackText(slackUsage))
+	case "list":
+		writeJSON(w, s.slackList(r, strings.Join(args, " ")))
+	case "status":
+		if len(args) != 1 {
+			writeJSON(w, slackText("Usage: `/helmop status <namespace>/<name>`"))
+			return
+		}
+		writeJSON(w, s.slackStatus(r, args[0]))
+	case "rollback":
+		writeJSON(w, s.slackRollbackPlan(r, user, userID, args))
+	default:
+		cmd, err := parseCommand(form.Get("text"))
+		if err != nil {
+			writeJSON(w, slackText(err.Error()+"\n"+slackUsage))
+			return
+		}
+		plan, err := s.runCommand(r, cmd, true, "")
+		if err != nil {
+			writeJSON(w, slackText(err.Error()))
+			return
+		}
+		if len(plan.Changed) == 0 {
+			writeJSON(w, slackText(fmt.Sprintf("`%s` changes nothing.", cmd)))
+			return
+		}
+		writeJSON(w, slackApproval(
+			fmt.Sprintf("@%s wants to run `%s`, changing %s.", user, cmd, refList(plan.Changed)),
+			slackAction{Requester: user, RequesterID: userID, Command: cmd.String()}))
+	}
+}
+
+// refList formats releases for a chat message.
+func refList(refs []releaseRef) string {
+	names := make([]string, len(refs))
+	for i, ref := range refs {
+		names[i] = ref.Namespace + "/" + ref.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+func (s *WebServer) slackList(r *http.Request, selector string) slackMessage {
+	sel, err := labels.Parse(selector)
+	if err != nil {
+		return slackText("Invalid selector: " + err.Error())
+	}
+	var list helmv1alpha1.HelmReleaseList
+	if err := s.Client.List(r.Context(), &list, client.MatchingLabelsSelector{Selector: sel}); err != nil {
+		return slackText(err.Error())
+	}
+	if len(list.Items) == 0 {
+		return slackText("No HelmReleases found.")
+	}
+	sort.Slice(list.Items, func(i, j int) bool {
+		a, b := list.Items[i], list.Items[j]
+		return a.Namespace+"/"+a.Name < b.Namespace+"/"+b.Name
+	})
+	var b strings.Builder
+	for i, hr := range list.Items {
+		if i == slackListLimit {
+			fmt.Fprintf(&b, "… and %d more; narrow the list with a selector.\n", len(list.Items)-i)
+			break
+		}
+		fmt.Fprintf(&b, "`%s/%s` %s %s — %s\n", hr.Namespace, hr.Name, hr.Spec.Chart, hr.Spec.Version, phaseOf(&hr))
+	}
+	return slackText(b.String())
+}
+
+func (s *WebServer) slackStatus(r *http.Request, ref string) slackMessage {
+	key, err := parseReleaseRef(ref)
+	if err != nil {
+		return slackText(err.Error())
+	}
+	var hr helmv1alpha1.HelmRelease
+	if err := s.Client.Get(r.Context(), key, &hr); err != nil {
+		return slackText(err.Error())
+	}
+	releaseName, releaseNS := helmReleaseOf(&hr)
+	lines := []string{
+		fmt.Sprintf("*%s* — %s", key, phaseOf(&hr)),
+		fmt.Sprintf("Chart: %s %s (deployed: %s)", hr.Spec.Chart, hr.Spec.Version, orNone(hr.Status.DeployedVersion)),
+		fmt.Sprintf("Helm release: %s/%s, revision %d", releaseNS, releaseName, hr.Status.HelmRevision),
+	}
+	if hr.Status.LastDeployedAt != nil {
+		lines = append(lines, "Last deployed: "+hr.Status.LastDeployedAt.UTC().Format(time.RFC3339))
+	}
+	if ready := meta.FindStatusCondition(hr.Status.Conditions, "Ready"); ready != nil {
+		lines = append(lines, fmt.Sprintf("Ready: %s (%s) %s", ready.Status, ready.Reason, ready.Message))
+	}
+	if hr.Spec.Suspend {
+		lines = append(lines, "Suspended")
+	}
+	return slackText(strings.Join(lines, "\n"))
+}
+
+// slackRollbackPlan validates a rollback request and asks for approval.
+func (s *WebServer) slackRollbackPlan(r *http.Request, user, userID string, args []string) slackMessage {
+	const usage = "Usage: `/helmop rollback <namespace>/<name> <revision>`"
+	if len(args) != 2 {
+		return slackText(usage)
+	}
+	key, err := parseReleaseRef(args[0])
+	if err != nil {
+		return slackText(err.Error())
+	}
+	revision, err := strconv.Atoi(args[1])
+	if err != nil || revision <= 0 {
+		return slackText(usage)
+	}
+	hr, version, err := s.rollbackTarget(r, key, revision)
+	if err != nil {
+		return slackText(err.Error())
+	}
+	return slackApproval(
+		fmt.Sprintf("@%s wants to roll back `%s` from chart %s %s to %s, as deployed by revision %d. Values are kept.",
+			user, key, hr.Spec.Chart, hr.Spec.Version, version, revision),
+		slackAction{Requester: user, RequesterID: userID, Release: key.String(), Revision: revision})
+}
+
+// rollbackTarget returns the release and the chart version of its Helm
+// revision.
+func (s *WebServer) rollbackTarget(r *http.Request, key types.NamespacedName, revision int) (*helmv1alpha1.HelmRelease, string, error) {
+	if s.HelmClient == nil {
+		return nil, "", fmt.Errorf("release history is not available")
+	}
+	var hr helmv1alpha1.HelmRelease
+	if err := s.Client.Get(r.Context(), key, &hr); err != nil {
+		return nil, "", err
+	}
+	releaseName, releaseNS := helmReleaseOf(&hr)
+	history, err := s.HelmClient.History(r.Context(), releaseName, releaseNS)
+	if err != nil {
+		return nil, "", err
+	}
+	for _, rel := range history {
+		if rel.Version == revision && rel.Chart != nil && rel.Chart.Metadata != nil {
+			return &hr, rel.Chart.Metadata.Version, nil
+		}
+	}
+	return nil, "", fmt.Errorf("%s has no revision %d", key, revision)
+}
+
+// rollback pins spec.version to the chart version of a Helm revision; the
+// controller then upgrades to it like to any other spec change. spec.values
+// and valuesFrom are left alone: the values Helm received are the merged
+// result of them, namespace defaults and rewrites, and may hold values read
+// from Secrets, so copying them into the spec would both freeze and expose
+// them.
+func (s *WebServer) rollback(r *http.Request, key types.NamespacedName, revision int, detail string) error {
+	hr, version, err := s.rollbackTarget(r, key, revision)
+	if err != nil {
+		return err
+	}
+	patch := client.MergeFrom(hr.DeepCopy())
+	hr.Spec.Version = version
+	setTriggeredBy(r, hr)
+	if err := s.Client.Patch(r.Context(), hr, patch); err != nil {
+		return err
+	}
+	s.broadcastEvent("updated", hr)
+	s.audit(r, "rolled back", hr, detail)
+	return nil
+}
+
+// slackInteraction is the part of a Slack block_actions payload used here.
+type slackInteraction struct {
+	Type string `json:"type"`
+	User struct {
+		ID       string `json:"id"`
+		Username string `json:"username"`
+	} `json:"user"`
+	ResponseURL string `json:"response_url"`
+	Actions     []struct {
+		ActionID string `json:"action_id"`
+		Value    string `json:"value"`
+	} `json:"actions"`
+}
+
+// handleSlackInteraction serves clicks on the Approve and Cancel buttons.
+// The outcome replaces the approval message.
+func (s *WebServer) handleSlackInteraction(w http.ResponseWriter, r *http.Request) {
+	form, ok := slackForm(w, r)
+	if !ok {
+		return
+	}
+	var payload slackInteraction
+	if err := json.Unmarshal([]byte(form.Get("payload")), &payload); err != nil {
+		httpError(w, "invalid payload: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if payload.Type != "block_actions" || len(payload.Actions) == 0 {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	var action slackAction
+	if err := json.Unmarshal([]byte(payload.Actions[0].Value), &action); err != nil {
+		httpError(w, "invalid action: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	approver := payload.User.Username
+	w.WriteHeader(http.StatusOK)
+
+	what := "`" + action.Command + "`"
+	if action.Release != "" {
+		what = fmt.Sprintf("rollback of `%s` to revision %d", action.Release, action.Revision)
+	}
+	if payload.Actions[0].ActionID != "approve" {
+		text := fmt.Sprintf("@%s cancelled %s.", approver, what)
+		s.respondSlack(payload.ResponseURL, slackMessage{ResponseType: "in_channel", ReplaceOriginal: true, Text: text})
+		return
+	}
+	r = asSlackUser(r, approver)
+	if err := s.checkSlackApprover(r, action, payload.User.ID, approver); err != nil {
+		// The request stays open for someone else to approve.
+		s.respondSlack(payload.ResponseURL, slackText(fmt.Sprintf("You cannot approve %s: %v", what, err)))
+		return
+	}
+	text := s.applySlackAction(r, action, approver)
+	s.respondSlack(payload.ResponseURL, slackMessage{ResponseType: "in_channel", ReplaceOriginal: true, Text: text})
+}
+
+// checkSlackApprover returns why the Slack user approverID, named approver,
+// may not approve action, or nil if they may. Nobody approves their own
+// request. With SlackApprovers set, only the users listed there approve;
+// otherwise the approver, as the Kubernetes user slack:<name>, must be
+// allowed by RBAC to update every HelmRelease the action changes.
+func (s *WebServer) checkSlackApprover(r *http.Request, action slackAction, approverID, approver string) error {
+	if (action.RequesterID != "" && action.RequesterID == approverID) || (action.RequesterID == "" && action.Requester == approver) {
+		return errors.New("changes must be approved by someone other than the requester")
+	}
+	if len(s.SlackApprovers) > 0 {
+		for _, allowed := range s.SlackApprovers {
+			if allowed == approver || allowed == approverID {
+				return nil
+			}
+		}
+		return errors.New("you are not one of the configured Slack approvers")
+	}
+
+	var refs []releaseRef
+	if action.Release != "" {
+		key, err := parseReleaseRef(action.Release)
+		if err != nil {
+			return err
+		}
+		refs = []releaseRef{{Namespace: key.Namespace, Name: key.Name}}
+	} else {
+		cmd, err := parseCommand(action.Command)
+		if err != nil {
+			return err
+		}
+		plan, err := s.runCommand(r, cmd, true, "")
+		if err != nil {
+			return err
+		}
+		refs = plan.Changed
+	}
+	for _, ref := range refs {
+		allowed, err := s.accessReview(r.Context(), requestUser(r), authorizationv1.ResourceAttributes{
+			Namespace: ref.Namespace,
+			Verb:      "update",
+			Group:     helmv1alpha1.GroupVersion.Group,
+			Resource:  "helmreleases",
+			Name:      ref.Name,
+		})
+		if err != nil {
+			return err
+		}
+		if !allowed {
+			return fmt.Errorf("%s may not update HelmRelease %s/%s", requestUser(r), ref.Namespace, ref.Name)
+		}
+	}
+	return nil
+}
+
+// applySlackAction applies an approved action and describes the outcome.
+func (s *WebServer) applySlackAction(r *http.Request, action slackAction, approver string) string {
+	detail := fmt.Sprintf("approved in Slack by %s, requested by %s", approver, action.Requester)
+	if action.Release != "" {
+		key, err := parseReleaseRef(action.Release)
+		if err == nil {
+			err = s.rollback(r, key, action.Revision, fmt.Sprintf("to revision %d, %s", action.Revision, detail))
+		}
+		if err != nil {
+			return fmt.Sprintf("Rollback of `%s` failed: %v", action.Release, err)
+		}
+		return fmt.Sprintf("@%s approved: `%s` is rolling back to revision %d.", approver, action.Release, action.Revision)
+	}
+
+	cmd, err := parseCommand(action.Command)
+	if err != nil {
+		return fmt.Sprintf("`%s` failed: %v", action.Command, err)
+	}
+	result, err := s.runCommand(r, cmd, false, "command: "+cmd.String()+", "+detail)
+	if err != nil {
+		return fmt.Sprintf("`%s` failed: %v", cmd, err)
+	}
+	text := fmt.Sprintf("@%s approved `%s`.", approver, cmd)
+	if len(result.Changed) > 0 {
+		text += " Changed " + refList(result.Changed) + "."
+	}
+	for ref, msg := range result.Failed {
+		text += fmt.Sprintf("\n%s failed: %s", ref, msg)
+	}
+	return text
+}
+
+// respondSlack posts msg to a Slack response_url.
+func (s *WebServer) respondSlack(responseURL string, msg slackMessage) {
+	if !strings.HasPrefix(responseURL, "https://hooks.slack.com/") {
+		ctrl.Log.Info("Ignoring Slack response URL outside hooks.slack.com", "url", responseURL)
+		return
+	}
+	body, _ := json.Marshal(msg)
+	resp, err := (&http.Client{Timeout: 10 * time.Second}).Post(responseURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		ctrl.Log.Error(err, "Responding to Slack")
+		return
+	}
+	resp.Body.Close()
+}
+
+// parseReleaseRef parses <namespace>/<name>.
+func parseReleaseRef(ref string) (types.NamespacedName, error) {
+	ns, name, ok := strings.Cut(ref, "/")
+	if !ok || ns == "" || name == "" {
+		return types.NamespacedName{}, fmt.Errorf("expected <namespace>/<name>, got %q", ref)
+	}
+	return types.NamespacedName{Namespace: ns, Name: name}, nil
+}
+
+func phaseOf(hr *helmv1alpha1.HelmRelease) string {
+	if hr.Status.Phase == "" {
+		return "Unknown"
+	}
+	return string(hr.Status.Phase)
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return s
+}
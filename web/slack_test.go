@@ -0,0 +1,212 @@
+package web_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"github.com/example/helm-operator/controllers"
+	"github.com/example/helm-operator/store"
+	"github.com/example/helm-operator/web"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Slack integration", func() {
+	const signingSecret = "test-signing-secret"
+	ctx := context.Background()
+
+	var (
+		ts        *testServer
+		approvers []string
+	)
+	BeforeEach(func() {
+		os.Setenv("SLACK_SIGNING_SECRET", signingSecret)
+		DeferCleanup(os.Unsetenv, "SLACK_SIGNING_SECRET")
+		approvers = []string{"bob"}
+	})
+	JustBeforeEach(func() {
+		helm := &controllers.FakeHelmClient{}
+		_, err := helm.Install(ctx, "web", "nginx", "", "0.9.0", "team-a", nil, controllers.InstallOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		_, err = helm.Upgrade(ctx, "web", "nginx", "", "1.0.0", "team-a", nil, controllers.UpgradeOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		hr := makeHR("team-a", "web")
+		hr.Spec.Values = &apiextensionsv1.JSON{Raw: []byte(`{"replicaCount":3}`)}
+		ts = startServer([]client.Object{hr}, func(s *web.WebServer) {
+			s.HelmClient = helm
+			s.SlackApprovers = approvers
+		})
+	})
+
+	// signed sends form to path with a Slack signature made with key at the
+	// given time.
+	signed := func(path string, form url.Values, key string, at time.Time) (*http.Response, []byte) {
+		body := form.Encode()
+		timestamp := strconv.FormatInt(at.Unix(), 10)
+		mac := hmac.New(sha256.New, []byte(key))
+		mac.Write([]byte("v0:" + timestamp + ":" + body))
+		req, err := http.NewRequest(http.MethodPost, ts.URL+path, strings.NewReader(body))
+		Expect(err).NotTo(HaveOccurred())
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("X-Slack-Request-Timestamp", timestamp)
+		req.Header.Set("X-Slack-Signature", "v0="+hex.EncodeToString(mac.Sum(nil)))
+		resp, err := ts.Server.Client().Do(req)
+		Expect(err).NotTo(HaveOccurred())
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		Expect(err).NotTo(HaveOccurred())
+		return resp, data
+	}
+
+	// command runs /helmop text as the Slack user name with ID id.
+	command := func(text, name, id string) map[string]interface{} {
+		resp, data := signed("/api/slack/command",
+			url.Values{"text": {text}, "user_name": {name}, "user_id": {id}}, signingSecret, time.Now())
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		var msg map[string]interface{}
+		Expect(json.Unmarshal(data, &msg)).To(Succeed())
+		return msg
+	}
+
+	// click clicks the button actionID of the approval message msg as the
+	// Slack user name with ID id.
+	click := func(msg map[string]interface{}, actionID, name, id string) {
+		blocks := msg["blocks"].([]interface{})
+		buttons := blocks[1].(map[string]interface{})["elements"].([]interface{})
+		value := buttons[0].(map[string]interface{})["value"].(string)
+		payload, err := json.Marshal(map[string]interface{}{
+			"type":         "block_actions",
+			"user":         map[string]string{"id": id, "username": name},
+			"response_url": "http://localhost/slack-response",
+			"actions":      []map[string]string{{"action_id": actionID, "value": value}},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		resp, _ := signed("/api/slack/interactions", url.Values{"payload": {string(payload)}}, signingSecret, time.Now())
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+	}
+
+	release := func() *helmv1alpha1.HelmRelease {
+		var hr helmv1alpha1.HelmRelease
+		Expect(ts.K8s.Get(ctx, types.NamespacedName{Namespace: "team-a", Name: "web"}, &hr)).To(Succeed())
+		return &hr
+	}
+
+	DescribeTable("rejects requests without a valid signature",
+		func(key string, age time.Duration) {
+			resp, _ := signed("/api/slack/command", url.Values{"text": {"help"}}, key, time.Now().Add(-age))
+			Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+		},
+		Entry("wrong secret", "other-secret", time.Duration(0)),
+		Entry("stale timestamp", signingSecret, 10*time.Minute),
+		Entry("timestamp in the future", signingSecret, -10*time.Minute),
+	)
+
+	It("rejects tampered bodies", func() {
+		body := "text=help"
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		mac := hmac.New(sha256.New, []byte(signingSecret))
+		mac.Write([]byte("v0:" + timestamp + ":" + body))
+		req, err := http.NewRequest(http.MethodPost, ts.URL+"/api/slack/command", strings.NewReader(body+"&user_name=bob"))
+		Expect(err).NotTo(HaveOccurred())
+		req.Header.Set("X-Slack-Request-Timestamp", timestamp)
+		req.Header.Set("X-Slack-Signature", "v0="+hex.EncodeToString(mac.Sum(nil)))
+		resp, err := ts.Server.Client().Do(req)
+		Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+	})
+
+	It("is disabled without a signing secret", func() {
+		os.Unsetenv("SLACK_SIGNING_SECRET")
+		resp, _ := signed("/api/slack/command", url.Values{"text": {"help"}}, signingSecret, time.Now())
+		Expect(resp.StatusCode).To(Equal(http.StatusServiceUnavailable))
+	})
+
+	DescribeTable("answers commands",
+		func(text, responseType, reply string) {
+			msg := command(text, "alice", "U1")
+			Expect(msg).To(HaveKeyWithValue("response_type", responseType))
+			Expect(msg["text"]).To(ContainSubstring(reply))
+		},
+		Entry("no command", "", "ephemeral", "`/helmop list [selector]`"),
+		Entry("help", "help", "ephemeral", "`/helmop list [selector]`"),
+		Entry("list", "list", "ephemeral", "`team-a/web` nginx 1.0.0"),
+		Entry("list with a bad selector", "list a in (b", "ephemeral", "Invalid selector"),
+		Entry("status", "status team-a/web", "ephemeral", "Chart: nginx 1.0.0"),
+		Entry("status without a release", "status", "ephemeral", "Usage: `/helmop status"),
+		Entry("status of a bad reference", "status web", "ephemeral", "expected <namespace>/<name>"),
+		Entry("unknown verb", "frobnicate team-a/web", "ephemeral", "`/helmop list [selector]`"),
+		Entry("command without changes", "upgrade team-a/web to 1.0.0", "ephemeral", "changes nothing"),
+		Entry("upgrade", "upgrade team-a/web to 2.0.0", "in_channel", "@alice wants to run `upgrade team-a/web to 2.0.0`"),
+		Entry("rollback", "rollback team-a/web 1", "in_channel", "to 0.9.0, as deployed by revision 1"),
+		Entry("rollback without a revision", "rollback team-a/web", "ephemeral", "Usage: `/helmop rollback"),
+		Entry("rollback to a bad revision", "rollback team-a/web x", "ephemeral", "Usage: `/helmop rollback"),
+		Entry("rollback to a missing revision", "rollback team-a/web 9", "ephemeral", "has no revision 9"),
+	)
+
+	It("applies a change once another user approves it", func() {
+		msg := command("upgrade team-a/web to 2.0.0", "alice", "U1")
+		Expect(release().Spec.Version).To(Equal("1.0.0"))
+
+		click(msg, "approve", "bob", "U2")
+		Expect(release().Spec.Version).To(Equal("2.0.0"))
+		entries, err := ts.Store.ListAudit(ctx, store.ListOptions{Namespace: "team-a", Name: "web"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(HaveLen(1))
+		Expect(entries[0].User).To(Equal("slack:bob"))
+		Expect(entries[0].Detail).To(ContainSubstring("approved in Slack by bob, requested by alice"))
+	})
+
+	It("rolls back only the chart version", func() {
+		msg := command("rollback team-a/web 1", "alice", "U1")
+		click(msg, "approve", "bob", "U2")
+		hr := release()
+		Expect(hr.Spec.Version).To(Equal("0.9.0"))
+		Expect(string(hr.Spec.Values.Raw)).To(MatchJSON(`{"replicaCount":3}`))
+	})
+
+	It("does nothing when cancelled", func() {
+		msg := command("upgrade team-a/web to 2.0.0", "alice", "U1")
+		click(msg, "cancel", "bob", "U2")
+		Expect(release().Spec.Version).To(Equal("1.0.0"))
+	})
+
+	DescribeTable("refuses approvals",
+		func(name, id string) {
+			msg := command("upgrade team-a/web to 2.0.0", "alice", "U1")
+			click(msg, "approve", name, id)
+			Expect(release().Spec.Version).To(Equal("1.0.0"))
+			entries, err := ts.Store.ListAudit(ctx, store.ListOptions{Namespace: "team-a", Name: "web"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(entries).To(BeEmpty())
+		},
+		Entry("by the requester", "alice", "U1"),
+		Entry("by the requester under another name", "bob", "U1"),
+		Entry("by a user who is not an approver", "carol", "U3"),
+	)
+
+	When("no approvers are configured", func() {
+		BeforeEach(func() { approvers = nil })
+
+		It("refuses approvers without RBAC permission to update the release", func() {
+			msg := command("upgrade team-a/web to 2.0.0", "alice", "U1")
+			click(msg, "approve", "bob", "U2")
+			Expect(release().Spec.Version).To(Equal("1.0.0"))
+		})
+	})
+})
@@ -0,0 +1,79 @@
+package web_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"github.com/example/helm-operator/store"
+	"github.com/example/helm-operator/web"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestWeb(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Web Suite")
+}
+
+// testServer serves a WebServer backed by a fake client.
+type testServer struct {
+	*httptest.Server
+	K8s   client.Client
+	Store store.Store
+}
+
+// startServer serves a WebServer over objs, after configure adjusts it. It
+// is closed when the spec ends.
+func startServer(objs []client.Object, configure ...func(*web.WebServer)) *testServer {
+	scheme := runtime.NewScheme()
+	Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+	Expect(helmv1alpha1.AddToScheme(scheme)).To(Succeed())
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()
+
+	s := &web.WebServer{
+		Client:      c,
+		Store:       store.NewMemory(0),
+		TokenSecret: types.NamespacedName{Namespace: "operator", Name: web.DefaultTokenSecretName},
+	}
+	for _, f := range configure {
+		f(s)
+	}
+	handler, err := s.Handler()
+	Expect(err).NotTo(HaveOccurred())
+	srv := httptest.NewServer(handler)
+	DeferCleanup(srv.Close)
+	return &testServer{Server: srv, K8s: c, Store: s.Store}
+}
+
+// do sends a request with an optional JSON body and headers given as
+// name, value pairs, returning the response and its body.
+func (ts *testServer) do(method, path string, body interface{}, headers ...string) (*http.Response, []byte) {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		Expect(err).NotTo(HaveOccurred())
+		r = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, ts.URL+path, r)
+	Expect(err).NotTo(HaveOccurred())
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	resp, err := ts.Server.Client().Do(req)
+	Expect(err).NotTo(HaveOccurred())
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	Expect(err).NotTo(HaveOccurred())
+	return resp, data
+}
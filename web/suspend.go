@@ -0,0 +1,120 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// changeResult is the body returned by the bulk suspend and resume
+// endpoints and by /api/command.
+type changeResult struct {
+	DryRun bool `json:"dryRun"`
+	// Changed lists the releases that were (or, in a dry run, would be)
+	// modified; Unchanged those already in the requested state.
+	Changed   []releaseRef `json:"changed"`
+	Unchanged []releaseRef `json:"unchanged"`
+	// Failed maps releases that could not be patched to the error.
+	Failed map[string]string `json:"failed,omitempty"`
+}
+
+type releaseRef struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+// handleSuspend serves POST /api/helmreleases/suspend.
+func (s *WebServer) handleSuspend(w http.ResponseWriter, r *http.Request) {
+	s.setSuspend(w, r, true)
+}
+
+// handleResume serves POST /api/helmreleases/resume.
+func (s *WebServer) handleResume(w http.ResponseWriter, r *http.Request) {
+	s.setSuspend(w, r, false)
+}
+
+// setSuspend sets spec.suspend on every HelmRelease matching the required
+// ?selector= label selector, optionally limited to ?ns=. With ?dryRun=true it
+// only reports what would change.
+func (s *WebServer) setSuspend(w http.ResponseWriter, r *http.Request, suspend bool) {
+	if r.Method != http.MethodPost {
+		httpError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	// An empty selector would match every release in the cluster; freezing
+	// other teams' stacks must not be one typo away.
+	if q.Get("selector") == "" {
+		httpError(w, "query param 'selector' is required", http.StatusBadRequest)
+		return
+	}
+	selector, err := labels.Parse(q.Get("selector"))
+	if err != nil {
+		httpError(w, "invalid selector: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	dryRun := false
+	if v := q.Get("dryRun"); v != "" {
+		if dryRun, err = strconv.ParseBool(v); err != nil {
+			httpError(w, "invalid dryRun: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	var list helmv1alpha1.HelmReleaseList
+	if err := s.Client.List(r.Context(), &list, selectorListOptions(selector, q.Get("ns"))...); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, s.suspendReleases(r, list.Items, suspend, dryRun, "selector "+selector.String()))
+}
+
+// selectorListOptions lists the HelmReleases matching selector, in ns if set.
+func selectorListOptions(selector labels.Selector, ns string) []client.ListOption {
+	opts := []client.ListOption{client.MatchingLabelsSelector{Selector: selector}}
+	if ns != "" {
+		opts = append(opts, client.InNamespace(ns))
+	}
+	return opts
+}
+
+// suspendReleases sets spec.suspend on releases, recording detail in the
+// audit log. With dryRun it only reports what would change.
+func (s *WebServer) suspendReleases(r *http.Request, releases []helmv1alpha1.HelmRelease, suspend, dryRun bool, detail string) changeResult {
+	action := "resumed"
+	if suspend {
+		action = "suspended"
+	}
+	result := changeResult{DryRun: dryRun, Changed: []releaseRef{}, Unchanged: []releaseRef{}}
+	for i := range releases {
+		hr := &releases[i]
+		ref := releaseRef{Namespace: hr.Namespace, Name: hr.Name}
+		if hr.Spec.Suspend == suspend {
+			result.Unchanged = append(result.Unchanged, ref)
+			continue
+		}
+		if !dryRun {
+			patch := client.MergeFrom(hr.DeepCopy())
+			hr.Spec.Suspend = suspend
+			if err := s.Client.Patch(r.Context(), hr, patch); err != nil {
+				result.fail(hr, err)
+				continue
+			}
+			s.broadcastEvent("updated", hr)
+			s.audit(r, action, hr, detail)
+		}
+		result.Changed = append(result.Changed, ref)
+	}
+	return result
+}
+
+// fail records that hr could not be changed.
+func (c *changeResult) fail(hr *helmv1alpha1.HelmRelease, err error) {
+	if c.Failed == nil {
+		c.Failed = map[string]string{}
+	}
+	c.Failed[hr.Namespace+"/"+hr.Name] = err.Error()
+}
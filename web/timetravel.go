@@ -0,0 +1,218 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/releaseutil"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/yaml"
+)
+
+// releaseAt is the body of GET /api/helmreleases/at.
+type releaseAt struct {
+	// Time is the requested moment.
+	Time time.Time `json:"time"`
+	// Revision is the Helm revision in effect at Time: the newest one
+	// deployed at or before it.
+	Revision revision `json:"revision"`
+	// Current reports whether Revision is still the latest revision.
+	Current bool `json:"current"`
+	// Spec holds the HelmRelease spec fields the revision records. Other
+	// fields, such as intervals and policies, are not kept per revision.
+	Spec specAt `json:"spec"`
+	// Values are the values Helm deployed the revision with, after merging
+	// valuesFrom and spec.values.
+	Values map[string]interface{} `json:"values"`
+	// Manifest is the rendered manifest of the revision.
+	Manifest string `json:"manifest"`
+	// Redacted reports whether secret values were hidden.
+	Redacted bool `json:"redacted"`
+}
+
+type specAt struct {
+	Chart           string `json:"chart"`
+	Version         string `json:"version"`
+	ReleaseName     string `json:"releaseName"`
+	TargetNamespace string `json:"targetNamespace"`
+}
+
+// handleReleaseAt serves GET /api/helmreleases/at?ns=&name=&time=: the chart,
+// values and manifest of a HelmRelease as deployed at an RFC 3339 time,
+// reconstructed from its Helm revisions. As with effective-values, values
+// under secret-like keys and the data of Secrets in the manifest are
+// redacted unless ?reveal=true is given by a user allowed to read Secrets.
+// Helm does not record where a revision's values came from, so all of them
+// are redacted when the HelmRelease takes values from a Secret.
+// Only revisions Helm still keeps (see spec.maxHistory) can be shown.
+func (s *WebServer) handleReleaseAt(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.HelmClient == nil {
+		httpError(w, "release history is not available", http.StatusServiceUnavailable)
+		return
+	}
+	q := r.URL.Query()
+	name, ns := q.Get("name"), q.Get("ns")
+	if name == "" || ns == "" {
+		httpError(w, "query params 'name' and 'ns' are required", http.StatusBadRequest)
+		return
+	}
+	at, err := time.Parse(time.RFC3339, q.Get("time"))
+	if err != nil {
+		httpError(w, "query param 'time' must be an RFC 3339 time, such as 2024-03-01T03:12:00Z", http.StatusBadRequest)
+		return
+	}
+	reveal := q.Get("reveal") == "true"
+	if reveal {
+		allowed, err := s.canReadSecrets(r, ns)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if !allowed {
+			httpError(w, fmt.Sprintf("revealing values requires permission to get secrets in %s", ns), http.StatusForbidden)
+			return
+		}
+	}
+
+	var hr helmv1alpha1.HelmRelease
+	if err := s.Client.Get(r.Context(), types.NamespacedName{Name: name, Namespace: ns}, &hr); err != nil {
+		writeError(w, err)
+		return
+	}
+	releaseName, releaseNS := helmReleaseOf(&hr)
+	revisions, err := s.HelmClient.History(r.Context(), releaseName, releaseNS)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var rel *release.Release
+	for _, candidate := range revisions {
+		if candidate.Info != nil && !candidate.Info.LastDeployed.After(at) {
+			rel = candidate
+			break
+		}
+	}
+	if rel == nil {
+		httpError(w, fmt.Sprintf("no revision of %s/%s was deployed at %s", releaseNS, releaseName, at.Format(time.RFC3339)),
+			http.StatusNotFound)
+		return
+	}
+
+	out := releaseAt{
+		Time:     at,
+		Revision: revisionOf(rel),
+		Current:  rel == revisions[0],
+		Spec:     specAt{Version: rel.Chart.Metadata.Version, ReleaseName: rel.Name, TargetNamespace: rel.Namespace},
+		Values:   rel.Config,
+		Manifest: rel.Manifest,
+		Redacted: !reveal,
+	}
+	out.Spec.Chart = out.Revision.Chart
+	if out.Values == nil {
+		out.Values = map[string]interface{}{}
+	}
+	if reveal {
+		s.audit(r, "reveal-values", &hr, fmt.Sprintf("revision %d", rel.Version))
+	} else {
+		// The revision's values are shared with Helm's storage; redact a copy.
+		out.Values = copyValues(out.Values)
+		if readsSecrets(&hr) {
+			redactAll(out.Values)
+		} else {
+			redact("", out.Values, nil)
+		}
+		if out.Manifest, err = redactManifest(out.Manifest); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+	writeJSON(w, out)
+}
+
+// readsSecrets reports whether hr's values may come from a Secret, through
+// valuesFrom or the variables of valuesTemplate.
+func readsSecrets(hr *helmv1alpha1.HelmRelease) bool {
+	for _, ref := range hr.Spec.ValuesFrom {
+		if ref.Kind == "Secret" {
+			return true
+		}
+	}
+	for _, ref := range hr.Spec.SubstituteFrom {
+		if ref.Kind == "Secret" {
+			return true
+		}
+	}
+	return false
+}
+
+// redactAll replaces, in place, every value that is not a map.
+func redactAll(values map[string]interface{}) {
+	for k, v := range values {
+		if m, ok := v.(map[string]interface{}); ok {
+			redactAll(m)
+			continue
+		}
+		if v != nil {
+			values[k] = redacted
+		}
+	}
+}
+
+// copyValues returns a deep copy of the maps of values.
+func copyValues(values map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		if m, ok := v.(map[string]interface{}); ok {
+			v = copyValues(m)
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// redactManifest replaces the data and stringData values of the Secrets in
+// manifest. Other documents are kept as they are.
+func redactManifest(manifest string) (string, error) {
+	docs := releaseutil.SplitManifests(manifest)
+	keys := make([]string, 0, len(docs))
+	for k := range docs {
+		keys = append(keys, k)
+	}
+	sort.Sort(releaseutil.BySplitManifestsOrder(keys))
+
+	var out strings.Builder
+	for _, k := range keys {
+		doc := docs[k]
+		var obj map[string]interface{}
+		if err := yaml.Unmarshal([]byte(doc), &obj); err != nil {
+			return "", fmt.Errorf("parsing manifest: %w", err)
+		}
+		if obj["kind"] == "Secret" {
+			for _, field := range []string{"data", "stringData"} {
+				if data, ok := obj[field].(map[string]interface{}); ok {
+					for key := range data {
+						data[key] = redacted
+					}
+				}
+			}
+			b, err := yaml.Marshal(obj)
+			if err != nil {
+				return "", err
+			}
+			doc = string(b)
+		}
+		out.WriteString("---\n")
+		out.WriteString(strings.TrimSuffix(doc, "\n"))
+		out.WriteString("\n")
+	}
+	return out.String(), nil
+}
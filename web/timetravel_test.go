@@ -0,0 +1,90 @@
+package web_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"github.com/example/helm-operator/controllers"
+	"github.com/example/helm-operator/web"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Release at a past time", func() {
+	ctx := context.Background()
+
+	var (
+		ts                          *testServer
+		beforeInstall, afterInstall time.Time
+	)
+	BeforeEach(func() {
+		helm := &controllers.FakeHelmClient{}
+		beforeInstall = time.Now().Add(-time.Second)
+		_, err := helm.Install(ctx, "web", "nginx", "", "1.0.0", "team-a",
+			map[string]interface{}{"image": map[string]interface{}{"tag": "1.25"}, "password": "s3cret"}, controllers.InstallOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		time.Sleep(10 * time.Millisecond)
+		afterInstall = time.Now()
+		time.Sleep(10 * time.Millisecond)
+		_, err = helm.Upgrade(ctx, "web", "nginx", "", "2.0.0", "team-a", nil, controllers.UpgradeOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		ts = startServer([]client.Object{makeHR("team-a", "web")}, func(s *web.WebServer) {
+			s.HelmClient = helm
+		})
+	})
+
+	at := func(t time.Time) (*http.Response, map[string]interface{}) {
+		resp, data := ts.do(http.MethodGet, "/api/helmreleases/at?ns=team-a&name=web&time="+url.QueryEscape(t.Format(time.RFC3339Nano)), nil)
+		var out map[string]interface{}
+		if resp.StatusCode == http.StatusOK {
+			Expect(json.Unmarshal(data, &out)).To(Succeed())
+		}
+		return resp, out
+	}
+
+	It("returns the revision deployed at the time, with secret values redacted", func() {
+		resp, out := at(afterInstall)
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(out["revision"]).To(HaveKeyWithValue("revision", BeNumerically("==", 1)))
+		Expect(out["current"]).To(BeFalse())
+		Expect(out["spec"]).To(HaveKeyWithValue("version", "1.0.0"))
+		Expect(out["values"]).To(Equal(map[string]interface{}{
+			"image": map[string]interface{}{"tag": "1.25"}, "password": "<redacted>",
+		}))
+		Expect(out["manifest"]).To(ContainSubstring("kind: ConfigMap"))
+
+		resp, out = at(time.Now())
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(out["revision"]).To(HaveKeyWithValue("revision", BeNumerically("==", 2)))
+		Expect(out["current"]).To(BeTrue())
+		Expect(out["spec"]).To(HaveKeyWithValue("version", "2.0.0"))
+	})
+
+	It("redacts every value when the HelmRelease reads values from a Secret", func() {
+		var hr helmv1alpha1.HelmRelease
+		Expect(ts.K8s.Get(ctx, types.NamespacedName{Namespace: "team-a", Name: "web"}, &hr)).To(Succeed())
+		hr.Spec.ValuesFrom = []helmv1alpha1.ValuesReference{{Kind: "Secret", Name: "web-credentials"}}
+		Expect(ts.K8s.Update(ctx, &hr)).To(Succeed())
+
+		resp, out := at(afterInstall)
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(out["values"]).To(Equal(map[string]interface{}{
+			"image": map[string]interface{}{"tag": "<redacted>"}, "password": "<redacted>",
+		}))
+	})
+
+	It("rejects times before the first revision and invalid times", func() {
+		resp, _ := at(beforeInstall)
+		Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+
+		resp, _ = ts.do(http.MethodGet, "/api/helmreleases/at?ns=team-a&name=web&time=yesterday", nil)
+		Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+	})
+})
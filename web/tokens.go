@@ -0,0 +1,472 @@
+package web
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/example/helm-operator/store"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/util/retry"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// apiTokenPrefix starts every API token, which tells them apart from the CI
+// preview token in the Authorization header.
+const apiTokenPrefix = "hop_"
+
+// DefaultTokenSecretName is the Secret, in the operator's namespace, holding
+// the hashed API tokens unless WebServer.TokenSecret names another.
+const DefaultTokenSecretName = "helm-operator-api-tokens"
+
+// tokenCacheTTL bounds how long a revoked token keeps working on replicas
+// other than the one that revoked it.
+const tokenCacheTTL = 10 * time.Second
+
+// Token scopes. write:namespace=<ns> limits write to one namespace.
+const (
+	scopeRead     = "read"
+	scopeWrite    = "write"
+	scopeDiagnose = "diagnose"
+	scopeAdmin    = "admin"
+
+	writeNamespacePrefix = "write:namespace="
+)
+
+// apiToken is one API token as stored in the token Secret, under its ID.
+// Only the SHA-256 of the token's secret part is kept.
+type apiToken struct {
+	ID        string       `json:"id"`
+	Name      string       `json:"name"`
+	Scopes    []string     `json:"scopes"`
+	Hash      string       `json:"hash,omitempty"`
+	CreatedBy string       `json:"createdBy,omitempty"`
+	CreatedAt metav1.Time  `json:"createdAt"`
+	RotatedAt *metav1.Time `json:"rotatedAt,omitempty"`
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+}
+
+// validateScope reports whether scope is one apiToken understands.
+func validateScope(scope string) error {
+	switch scope {
+	case scopeRead, scopeWrite, scopeDiagnose, scopeAdmin:
+		return nil
+	}
+	if ns, ok := strings.CutPrefix(scope, writeNamespacePrefix); ok {
+		if errs := validation.IsDNS1123Label(ns); len(errs) > 0 {
+			return fmt.Errorf("scope %q: invalid namespace: %s", scope, strings.Join(errs, "; "))
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown scope %q (want read, write, write:namespace=<ns>, diagnose or admin)", scope)
+}
+
+func (t *apiToken) has(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope || s == scopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// canWriteSome reports whether t may write to at least one namespace.
+func (t *apiToken) canWriteSome() bool {
+	for _, s := range t.Scopes {
+		if strings.HasPrefix(s, writeNamespacePrefix) {
+			return true
+		}
+	}
+	return t.has(scopeWrite)
+}
+
+// canWrite reports whether t may change objects in namespace.
+func (t *apiToken) canWrite(namespace string) bool {
+	return t.has(scopeWrite) || (namespace != "" && t.has(writeNamespacePrefix+namespace))
+}
+
+// allows reports whether t may call the endpoint of r. Namespaces are checked
+// when objects are written; see guardedClient. A write scope implies read.
+func (t *apiToken) allows(r *http.Request) bool {
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/api/tokens"):
+		return t.has(scopeAdmin)
+	case r.URL.Path == "/api/diagnose":
+		return t.has(scopeDiagnose)
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		return t.has(scopeRead) || t.canWriteSome()
+	default:
+		return t.canWriteSome()
+	}
+}
+
+func (t *apiToken) expired(now time.Time) bool {
+	return t.ExpiresAt != nil && !now.Before(t.ExpiresAt.Time)
+}
+
+// newTokenSecret returns a random token secret and its stored hash.
+func newTokenSecret() (secret, hash string, err error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", err
+	}
+	secret = base64.RawURLEncoding.EncodeToString(raw)
+	return secret, hashTokenSecret(secret), nil
+}
+
+func hashTokenSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// tokenStore reads and writes the token Secret, caching its contents for
+// tokenCacheTTL.
+type tokenStore struct {
+	client client.Client
+	key    types.NamespacedName
+
+	mu     sync.Mutex
+	tokens map[string]apiToken
+	loaded time.Time
+}
+
+// list returns the stored tokens by ID.
+func (ts *tokenStore) list(ctx context.Context) (map[string]apiToken, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if ts.tokens != nil && time.Since(ts.loaded) < tokenCacheTTL {
+		return ts.tokens, nil
+	}
+	var secret corev1.Secret
+	err := ts.client.Get(ctx, ts.key, &secret)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("reading token Secret %s: %w", ts.key, err)
+	}
+	tokens, err := decodeTokens(&secret)
+	if err != nil {
+		return nil, err
+	}
+	ts.tokens, ts.loaded = tokens, time.Now()
+	return tokens, nil
+}
+
+func decodeTokens(secret *corev1.Secret) (map[string]apiToken, error) {
+	tokens := map[string]apiToken{}
+	for id, raw := range secret.Data {
+		var t apiToken
+		if err := json.Unmarshal(raw, &t); err != nil {
+			return nil, fmt.Errorf("token Secret %s/%s: key %s: %w", secret.Namespace, secret.Name, id, err)
+		}
+		tokens[id] = t
+	}
+	return tokens, nil
+}
+
+// update applies change to the stored tokens, creating the Secret if needed.
+func (ts *tokenStore) update(ctx context.Context, change func(tokens map[string]apiToken) error) error {
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		var secret corev1.Secret
+		err := ts.client.Get(ctx, ts.key, &secret)
+		create := apierrors.IsNotFound(err)
+		if err != nil && !create {
+			return err
+		}
+		tokens, err := decodeTokens(&secret)
+		if err != nil {
+			return err
+		}
+		if err := change(tokens); err != nil {
+			return err
+		}
+		secret.Data = map[string][]byte{}
+		for id, t := range tokens {
+			if secret.Data[id], err = json.Marshal(t); err != nil {
+				return err
+			}
+		}
+		if create {
+			secret.ObjectMeta = metav1.ObjectMeta{Name: ts.key.Name, Namespace: ts.key.Namespace}
+			return ts.client.Create(ctx, &secret)
+		}
+		return ts.client.Update(ctx, &secret)
+	})
+	ts.mu.Lock()
+	ts.tokens = nil
+	ts.mu.Unlock()
+	return err
+}
+
+// errInvalidToken is returned for unknown, revoked, rotated and expired tokens.
+var errInvalidToken = errors.New("invalid API token")
+
+// verify returns the token presented as hop_<id>_<secret>.
+func (ts *tokenStore) verify(ctx context.Context, presented string) (*apiToken, error) {
+	id, secret, ok := strings.Cut(strings.TrimPrefix(presented, apiTokenPrefix), "_")
+	if !ok {
+		return nil, errInvalidToken
+	}
+	tokens, err := ts.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+	t, ok := tokens[id]
+	if !ok || t.expired(time.Now()) ||
+		subtle.ConstantTimeCompare([]byte(hashTokenSecret(secret)), []byte(t.Hash)) != 1 {
+		return nil, errInvalidToken
+	}
+	return &t, nil
+}
+
+type tokenContextKey struct{}
+
+// requestToken returns the API token that authenticated ctx's request, if any.
+func requestToken(ctx context.Context) *apiToken {
+	t, _ := ctx.Value(tokenContextKey{}).(*apiToken)
+	return t
+}
+
+// authenticate checks API tokens presented as bearer tokens, rejects
+// requests outside the token's scopes, and attributes the rest to
+// "token:<name>". Requests without an API token pass through unchanged.
+// Either way the request is recorded in its context for guardedClient.
+func (s *WebServer) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = r.WithContext(context.WithValue(r.Context(), requestContextKey{}, r))
+		presented, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "+apiTokenPrefix)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if s.tokens == nil {
+			httpError(w, "API tokens are disabled", http.StatusServiceUnavailable)
+			return
+		}
+		t, err := s.tokens.verify(r.Context(), apiTokenPrefix+presented)
+		if errors.Is(err, errInvalidToken) {
+			httpError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if !t.allows(r) {
+			httpError(w, fmt.Sprintf("token %q lacks the scope for %s %s", t.Name, r.Method, r.URL.Path), http.StatusForbidden)
+			return
+		}
+		r = r.WithContext(context.WithValue(r.Context(), tokenContextKey{}, t))
+		for _, h := range userHeaders {
+			r.Header.Del(h)
+		}
+		r.Header.Set(userHeaders[0], "token:"+t.Name)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isAdmin reports whether r may manage API tokens: it carries an admin
+// token, or the authenticating proxy names one of WebServer.Admins.
+func (s *WebServer) isAdmin(r *http.Request) bool {
+	if t := requestToken(r.Context()); t != nil {
+		return t.has(scopeAdmin)
+	}
+	user := requestUser(r)
+	for _, admin := range s.Admins {
+		if user != "" && user == admin {
+			return true
+		}
+	}
+	return false
+}
+
+// tokenRequest is the body of POST /api/tokens.
+type tokenRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	TTL    string   `json:"ttl"` // Go duration string, may be empty
+}
+
+// tokenResponse describes a token. Token, the only copy of the secret, is
+// set when a token is created or rotated.
+type tokenResponse struct {
+	apiToken
+	Token string `json:"token,omitempty"`
+}
+
+// handleTokens serves /api/tokens: GET lists tokens, POST creates one and
+// DELETE ?id= revokes one. Admins only.
+func (s *WebServer) handleTokens(w http.ResponseWriter, r *http.Request) {
+	if s.tokens == nil {
+		httpError(w, "API tokens are disabled", http.StatusServiceUnavailable)
+		return
+	}
+	if !s.isAdmin(r) {
+		httpError(w, "forbidden: managing API tokens requires an admin", http.StatusForbidden)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		tokens, err := s.tokens.list(r.Context())
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		out := make([]apiToken, 0, len(tokens))
+		for _, t := range tokens {
+			t.Hash = ""
+			out = append(out, t)
+		}
+		sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+		writeJSON(w, out)
+	case http.MethodPost:
+		s.createToken(w, r)
+	case http.MethodDelete:
+		id := r.URL.Query().Get("id")
+		var revoked apiToken
+		err := s.tokens.update(r.Context(), func(tokens map[string]apiToken) error {
+			var ok bool
+			if revoked, ok = tokens[id]; !ok {
+				return apierrors.NewNotFound(schema.GroupResource{Resource: "tokens"}, id)
+			}
+			delete(tokens, id)
+			return nil
+		})
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		s.auditToken(r, "token-revoked", revoked)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		httpError(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *WebServer) createToken(w http.ResponseWriter, r *http.Request) {
+	var req tokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpError(w, "invalid JSON body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Name == "" || len(req.Scopes) == 0 {
+		httpError(w, "name and scopes are required", http.StatusBadRequest)
+		return
+	}
+	for _, scope := range req.Scopes {
+		if err := validateScope(scope); err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	ttl, err := parseTTL(req.TTL)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	idBytes := make([]byte, 6)
+	if _, err := rand.Read(idBytes); err != nil {
+		writeError(w, err)
+		return
+	}
+	secret, hash, err := newTokenSecret()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	t := apiToken{
+		ID:        hex.EncodeToString(idBytes),
+		Name:      req.Name,
+		Scopes:    req.Scopes,
+		Hash:      hash,
+		CreatedBy: requestUser(r),
+		CreatedAt: metav1.Now(),
+	}
+	if ttl != nil {
+		expiresAt := metav1.NewTime(t.CreatedAt.Add(ttl.Duration))
+		t.ExpiresAt = &expiresAt
+	}
+	err = s.tokens.update(r.Context(), func(tokens map[string]apiToken) error {
+		for _, existing := range tokens {
+			if existing.Name == t.Name {
+				return apierrors.NewAlreadyExists(schema.GroupResource{Resource: "tokens"}, t.Name)
+			}
+		}
+		tokens[t.ID] = t
+		return nil
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	s.auditToken(r, "token-created", t)
+	t.Hash = ""
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, tokenResponse{apiToken: t, Token: apiTokenPrefix + t.ID + "_" + secret})
+}
+
+// handleRotateToken serves POST /api/tokens/rotate?id=: the token gets a new
+// secret, keeping its ID, name and scopes, and the old secret stops working.
+func (s *WebServer) handleRotateToken(w http.ResponseWriter, r *http.Request) {
+	if s.tokens == nil {
+		httpError(w, "API tokens are disabled", http.StatusServiceUnavailable)
+		return
+	}
+	if !s.isAdmin(r) {
+		httpError(w, "forbidden: managing API tokens requires an admin", http.StatusForbidden)
+		return
+	}
+	if r.Method != http.MethodPost {
+		httpError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id := r.URL.Query().Get("id")
+	secret, hash, err := newTokenSecret()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var rotated apiToken
+	err = s.tokens.update(r.Context(), func(tokens map[string]apiToken) error {
+		t, ok := tokens[id]
+		if !ok {
+			return apierrors.NewNotFound(schema.GroupResource{Resource: "tokens"}, id)
+		}
+		now := metav1.Now()
+		t.Hash, t.RotatedAt = hash, &now
+		tokens[id] = t
+		rotated = t
+		return nil
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	s.auditToken(r, "token-rotated", rotated)
+	rotated.Hash = ""
+	writeJSON(w, tokenResponse{apiToken: rotated, Token: apiTokenPrefix + rotated.ID + "_" + secret})
+}
+
+// auditToken records a change to an API token in the audit log.
+func (s *WebServer) auditToken(r *http.Request, action string, t apiToken) {
+	entry := store.AuditEntry{User: requestUser(r), Action: action, Name: t.Name, Detail: "scopes: " + strings.Join(t.Scopes, ", ")}
+	if err := s.Store.AppendAudit(r.Context(), entry); err != nil {
+		ctrl.Log.Error(err, "Recording audit entry", "action", action, "token", t.Name)
+	}
+}
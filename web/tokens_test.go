@@ -0,0 +1,148 @@
+package web_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/example/helm-operator/web"
+)
+
+var _ = Describe("API tokens", func() {
+	admin := func(s *web.WebServer) { s.Admins = []string{"alice"} }
+
+	createToken := func(ts *testServer, name string, scopes ...string) string {
+		resp, body := ts.do(http.MethodPost, "/api/tokens",
+			map[string]interface{}{"name": name, "scopes": scopes}, "X-Forwarded-User", "alice")
+		Expect(resp.StatusCode).To(Equal(http.StatusCreated), string(body))
+		var created struct {
+			Token string `json:"token"`
+		}
+		Expect(json.Unmarshal(body, &created)).To(Succeed())
+		Expect(created.Token).To(HavePrefix("hop_"))
+		return created.Token
+	}
+
+	It("lets only admins manage tokens", func() {
+		ts := startServer(nil, admin)
+		resp, _ := ts.do(http.MethodGet, "/api/tokens", nil, "X-Forwarded-User", "bob")
+		Expect(resp.StatusCode).To(Equal(http.StatusForbidden))
+		resp, _ = ts.do(http.MethodPost, "/api/tokens",
+			map[string]interface{}{"name": "ci", "scopes": []string{"root"}}, "X-Forwarded-User", "alice")
+		Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+
+		createToken(ts, "ci", "read")
+		resp, _ = ts.do(http.MethodPost, "/api/tokens",
+			map[string]interface{}{"name": "ci", "scopes": []string{"read"}}, "X-Forwarded-User", "alice")
+		Expect(resp.StatusCode).To(Equal(http.StatusConflict))
+	})
+
+	It("enforces token scopes", func() {
+		ts := startServer(nil, admin)
+		read := createToken(ts, "dashboard", "read")
+		teamA := createToken(ts, "team-a-ci", "write:namespace=team-a")
+
+		resp, _ := ts.do(http.MethodGet, "/api/helmreleases", nil, "Authorization", "Bearer "+read)
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		resp, _ = ts.do(http.MethodPost, "/api/helmreleases", createBody, "Authorization", "Bearer "+read)
+		Expect(resp.StatusCode).To(Equal(http.StatusForbidden))
+
+		resp, _ = ts.do(http.MethodPost, "/api/helmreleases", createBody, "Authorization", "Bearer "+teamA)
+		Expect(resp.StatusCode).To(Equal(http.StatusCreated))
+		resp, _ = ts.do(http.MethodPost, "/api/helmreleases", withField(createBody, "namespace", "team-b"),
+			"Authorization", "Bearer "+teamA)
+		Expect(resp.StatusCode).To(Equal(http.StatusForbidden))
+
+		resp, _ = ts.do(http.MethodGet, "/api/helmreleases", nil, "Authorization", "Bearer hop_unknown_secret")
+		Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+	})
+
+	It("rejects wrong secrets and expired, rotated and revoked tokens", func() {
+		ts := startServer(nil, admin)
+		valid := func(token string) bool {
+			resp, _ := ts.do(http.MethodGet, "/api/helmreleases", nil, "Authorization", "Bearer "+token)
+			Expect(resp.StatusCode).To(BeElementOf(http.StatusOK, http.StatusUnauthorized))
+			return resp.StatusCode == http.StatusOK
+		}
+		idOf := func(token string) string {
+			id, _, _ := strings.Cut(strings.TrimPrefix(token, "hop_"), "_")
+			return id
+		}
+
+		token := createToken(ts, "dashboard", "read")
+		Expect(valid(token)).To(BeTrue())
+		Expect(valid(token + "x")).To(BeFalse())
+		Expect(valid("hop_" + idOf(token) + "_")).To(BeFalse())
+		Expect(valid("hop_" + idOf(token))).To(BeFalse())
+
+		// A nanosecond has passed by the time the token is presented.
+		resp, body := ts.do(http.MethodPost, "/api/tokens",
+			map[string]interface{}{"name": "short-lived", "scopes": []string{"read"}, "ttl": "1ns"}, "X-Forwarded-User", "alice")
+		Expect(resp.StatusCode).To(Equal(http.StatusCreated))
+		var expired struct {
+			Token string `json:"token"`
+		}
+		Expect(json.Unmarshal(body, &expired)).To(Succeed())
+		Expect(valid(expired.Token)).To(BeFalse())
+
+		resp, body = ts.do(http.MethodPost, "/api/tokens/rotate?id="+idOf(token), nil, "X-Forwarded-User", "alice")
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		var rotated struct {
+			Token string `json:"token"`
+		}
+		Expect(json.Unmarshal(body, &rotated)).To(Succeed())
+		Expect(valid(token)).To(BeFalse())
+		Expect(valid(rotated.Token)).To(BeTrue())
+
+		resp, _ = ts.do(http.MethodDelete, "/api/tokens?id="+idOf(token), nil, "X-Forwarded-User", "alice")
+		Expect(resp.StatusCode).To(Equal(http.StatusNoContent))
+		Expect(valid(rotated.Token)).To(BeFalse())
+	})
+
+	DescribeTable("limits tokens to their scopes",
+		func(scope, method, path string, allowed bool) {
+			ts := startServer(nil, admin)
+			token := createToken(ts, "scoped", scope)
+			resp, _ := ts.do(method, path, nil, "Authorization", "Bearer "+token)
+			if allowed {
+				Expect(resp.StatusCode).NotTo(BeElementOf(http.StatusUnauthorized, http.StatusForbidden))
+			} else {
+				Expect(resp.StatusCode).To(Equal(http.StatusForbidden))
+			}
+		},
+		Entry("read lists releases", "read", http.MethodGet, "/api/helmreleases", true),
+		Entry("read cannot delete", "read", http.MethodDelete, "/api/helmreleases?ns=team-a&name=web", false),
+		Entry("read cannot diagnose", "read", http.MethodPost, "/api/diagnose", false),
+		Entry("diagnose cannot list releases", "diagnose", http.MethodGet, "/api/helmreleases", false),
+		Entry("write reads", "write:namespace=team-a", http.MethodGet, "/api/helmreleases", true),
+		Entry("write cannot manage tokens", "write", http.MethodGet, "/api/tokens", false),
+		Entry("admin manages tokens", "admin", http.MethodGet, "/api/tokens", true),
+	)
+
+	It("decides who is an admin by the token, not the proxy headers", func() {
+		ts := startServer(nil, admin)
+		writer := createToken(ts, "deployer", "write")
+		root := createToken(ts, "root", "admin")
+
+		resp, _ := ts.do(http.MethodGet, "/api/tokens", nil, "Authorization", "Bearer "+writer, "X-Forwarded-User", "alice")
+		Expect(resp.StatusCode).To(Equal(http.StatusForbidden))
+		resp, _ = ts.do(http.MethodGet, "/api/tokens", nil, "Authorization", "Bearer "+root, "X-Forwarded-User", "bob")
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		resp, _ = ts.do(http.MethodGet, "/api/tokens", nil, "X-Forwarded-User", "bob")
+		Expect(resp.StatusCode).To(Equal(http.StatusForbidden))
+	})
+
+	It("attributes changes to the token", func() {
+		ts := startServer(nil, admin)
+		token := createToken(ts, "deployer", "write")
+
+		resp, _ := ts.do(http.MethodPost, "/api/helmreleases", createBody,
+			"Authorization", "Bearer "+token, "X-Forwarded-User", "mallory")
+		Expect(resp.StatusCode).To(Equal(http.StatusCreated))
+		_, body := ts.do(http.MethodGet, "/api/audit?ns=team-a&name=web", nil)
+		Expect(string(body)).To(ContainSubstring(`"user": "token:deployer"`))
+	})
+})
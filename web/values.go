@@ -0,0 +1,76 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"github.com/example/helm-operator/controllers"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// handleReleaseValues serves GET /api/helmreleases/values: spec.values
+// exactly as stored, indented. The UI edits this text rather than
+// re-serializing the release it parsed, because JavaScript numbers cannot
+// hold integers above 2^53.
+func (s *WebServer) handleReleaseValues(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	name, ns := r.URL.Query().Get("name"), r.URL.Query().Get("ns")
+	if name == "" || ns == "" {
+		httpError(w, "query params 'name' and 'ns' are required", http.StatusBadRequest)
+		return
+	}
+	var hr helmv1alpha1.HelmRelease
+	if err := s.Client.Get(r.Context(), types.NamespacedName{Name: name, Namespace: ns}, &hr); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if hr.Spec.Values == nil {
+		_, _ = w.Write([]byte("{}\n"))
+		return
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, hr.Spec.Values.Raw, "", "  "); err != nil {
+		writeError(w, err)
+		return
+	}
+	out.WriteByte('\n')
+	_, _ = w.Write(out.Bytes())
+}
+
+// handleValuesProvenance serves GET /api/helmreleases/values-provenance:
+// the path of every merged value, such as "image.tag", mapped to the
+// valuesFrom source or spec.values that set it. Values themselves are not
+// returned, since they may come from Secrets.
+func (s *WebServer) handleValuesProvenance(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	name, ns := r.URL.Query().Get("name"), r.URL.Query().Get("ns")
+	if name == "" || ns == "" {
+		httpError(w, "query params 'name' and 'ns' are required", http.StatusBadRequest)
+		return
+	}
+	var hr helmv1alpha1.HelmRelease
+	if err := s.Client.Get(r.Context(), types.NamespacedName{Name: name, Namespace: ns}, &hr); err != nil {
+		writeError(w, err)
+		return
+	}
+	_, provenance, err := controllers.ComposeValues(r.Context(), s.Client, &hr)
+	switch {
+	case controllers.IsMissingValuesSource(err):
+		httpError(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, provenance)
+}
@@ -0,0 +1,56 @@
+package web_test
+
+import (
+	"encoding/json"
+	"net/http"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Values provenance API", func() {
+	It("attributes every value to the source that set it", func() {
+		defaults := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "team-defaults", Namespace: "team-a"},
+			Data:       map[string]string{"values.yaml": "image:\n  repository: nginx\n  tag: \"1.25\"\nreplicaCount: 1\n"},
+		}
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "team-a"},
+			Data:       map[string][]byte{"creds": []byte("db:\n  password: hunter2\n")},
+		}
+		hr := makeHR("team-a", "web")
+		hr.Spec.ValuesFrom = []helmv1alpha1.ValuesReference{
+			{Kind: "ConfigMap", Name: "team-defaults"},
+			{Kind: "Secret", Name: "db", ValuesKey: "creds"},
+		}
+		hr.Spec.Values = &apiextensionsv1.JSON{Raw: []byte(`{"image":{"tag":"1.26"}}`)}
+		ts := startServer([]client.Object{defaults, secret, hr})
+
+		resp, body := ts.do(http.MethodGet, "/api/helmreleases/values-provenance?ns=team-a&name=web", nil)
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(string(body)).NotTo(ContainSubstring("hunter2"))
+		var provenance map[string]string
+		Expect(json.Unmarshal(body, &provenance)).To(Succeed())
+		Expect(provenance).To(Equal(map[string]string{
+			"image.repository": "ConfigMap/team-defaults:values.yaml",
+			"image.tag":        "spec.values",
+			"replicaCount":     "ConfigMap/team-defaults:values.yaml",
+			"db.password":      "Secret/db:creds",
+		}))
+	})
+
+	It("reports a missing source as a conflict", func() {
+		hr := makeHR("team-a", "web")
+		hr.Spec.ValuesFrom = []helmv1alpha1.ValuesReference{{Kind: "ConfigMap", Name: "not-yet"}}
+		ts := startServer([]client.Object{hr})
+
+		resp, _ := ts.do(http.MethodGet, "/api/helmreleases/values-provenance?ns=team-a&name=web", nil)
+		Expect(resp.StatusCode).To(Equal(http.StatusConflict))
+	})
+})
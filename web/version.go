@@ -0,0 +1,25 @@
+package web
+
+import (
+	"net/http"
+
+	"github.com/example/helm-operator/controllers"
+)
+
+// versionResponse is the body of GET /api/version.
+type versionResponse struct {
+	Version string `json:"version"`
+	// CRD is the installed HelmRelease CRD as checked at startup; nil if it
+	// could not be read.
+	CRD *controllers.CRDStatus `json:"crd"`
+}
+
+// handleVersion reports the operator's version and whether the installed
+// CRD matches it.
+func (s *WebServer) handleVersion(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, versionResponse{Version: controllers.Version, CRD: s.CRD})
+}
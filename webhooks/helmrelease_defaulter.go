@@ -0,0 +1,112 @@
+// Package webhooks contains the admission webhooks for HelmRelease resources.
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	helmvalues "github.com/example/helm-operator/values"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// Namespace annotations holding organization defaults for the HelmReleases
+// created in that namespace.
+const (
+	// AnnotationDefaultRepoURLPrefix is prepended to spec.repoURL when it is
+	// not an absolute URL, and used as the repoURL when none is given.
+	AnnotationDefaultRepoURLPrefix = "helm.example.com/default-repo-url-prefix"
+	// AnnotationDefaultValues is a JSON object merged underneath spec.values;
+	// keys set on the HelmRelease win.
+	AnnotationDefaultValues = "helm.example.com/default-values"
+	// AnnotationDefaultServiceAccount is the spec.serviceAccountName of
+	// HelmReleases that set none.
+	AnnotationDefaultServiceAccount = "helm.example.com/default-service-account"
+)
+
+// HelmReleaseDefaulter applies namespace-level defaults to HelmReleases when
+// they are created.
+//
+// +kubebuilder:webhook:path=/mutate-helm-example-com-v1alpha1-helmrelease,mutating=true,failurePolicy=fail,sideEffects=None,groups=helm.example.com,resources=helmreleases,verbs=create,versions=v1alpha1,name=mhelmrelease.helm.example.com,admissionReviewVersions=v1
+type HelmReleaseDefaulter struct {
+	Client client.Reader
+
+	// MaxHistory, if set, is the spec.maxHistory of HelmReleases that leave
+	// it unset.
+	MaxHistory *int
+}
+
+var _ admission.CustomDefaulter = (*HelmReleaseDefaulter)(nil) // compile-time interface check
+
+// SetupWithManager registers the defaulting webhook with the manager's webhook server.
+func (d *HelmReleaseDefaulter) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&helmv1alpha1.HelmRelease{}).
+		WithDefaulter(d).
+		Complete()
+}
+
+// Default implements admission.CustomDefaulter.
+func (d *HelmReleaseDefaulter) Default(ctx context.Context, obj runtime.Object) error {
+	hr, ok := obj.(*helmv1alpha1.HelmRelease)
+	if !ok {
+		return fmt.Errorf("expected a HelmRelease but got %T", obj)
+	}
+	// Updates keep what was defaulted on create. Defaulting them again
+	// would fail on an annotation broken since then, and with it the
+	// finalizer removal that completes a deletion.
+	if req, err := admission.RequestFromContext(ctx); err == nil && req.Operation != admissionv1.Create {
+		return nil
+	}
+
+	if hr.Spec.MaxHistory == nil && d.MaxHistory != nil {
+		maxHistory := *d.MaxHistory
+		hr.Spec.MaxHistory = &maxHistory
+	}
+
+	var ns corev1.Namespace
+	if err := d.Client.Get(ctx, types.NamespacedName{Name: hr.Namespace}, &ns); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	if prefix := ns.Annotations[AnnotationDefaultRepoURLPrefix]; prefix != "" {
+		switch {
+		case hr.Spec.RepoURL == "":
+			hr.Spec.RepoURL = prefix
+		case !strings.Contains(hr.Spec.RepoURL, "://"):
+			hr.Spec.RepoURL = strings.TrimSuffix(prefix, "/") + "/" + strings.TrimPrefix(hr.Spec.RepoURL, "/")
+		}
+	}
+
+	if sa := ns.Annotations[AnnotationDefaultServiceAccount]; sa != "" && hr.Spec.ServiceAccountName == "" {
+		hr.Spec.ServiceAccountName = sa
+	}
+
+	if raw := ns.Annotations[AnnotationDefaultValues]; raw != "" {
+		defaults, err := helmvalues.Parse([]byte(raw))
+		if err != nil {
+			return fmt.Errorf("namespace %s: invalid %s annotation: %w", ns.Name, AnnotationDefaultValues, err)
+		}
+		own := map[string]interface{}{}
+		if hr.Spec.Values != nil {
+			if own, err = helmvalues.Parse(hr.Spec.Values.Raw); err != nil {
+				return fmt.Errorf("parsing spec.values: %w", err)
+			}
+		}
+		merged, err := json.Marshal(helmvalues.Merge(defaults, own))
+		if err != nil {
+			return err
+		}
+		hr.Spec.Values = &apiextensionsv1.JSON{Raw: merged}
+	}
+	return nil
+}
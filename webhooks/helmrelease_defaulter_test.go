@@ -0,0 +1,136 @@
+package webhooks_test
+
+import (
+	"context"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"github.com/example/helm-operator/webhooks"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+var _ = Describe("HelmReleaseDefaulter", func() {
+	newDefaulter := func(annotations map[string]string) *webhooks.HelmReleaseDefaulter {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a", Annotations: annotations}}
+		return &webhooks.HelmReleaseDefaulter{Client: fake.NewClientBuilder().WithObjects(ns).Build()}
+	}
+
+	newRelease := func(repoURL, values string) *helmv1alpha1.HelmRelease {
+		hr := &helmv1alpha1.HelmRelease{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "team-a"},
+			Spec:       helmv1alpha1.HelmReleaseSpec{Chart: "app", RepoURL: repoURL},
+		}
+		if values != "" {
+			hr.Spec.Values = &apiextensionsv1.JSON{Raw: []byte(values)}
+		}
+		return hr
+	}
+
+	It("fills in and prefixes the repoURL", func() {
+		d := newDefaulter(map[string]string{webhooks.AnnotationDefaultRepoURLPrefix: "https://charts.example.com/"})
+
+		hr := newRelease("", "")
+		Expect(d.Default(context.Background(), hr)).To(Succeed())
+		Expect(hr.Spec.RepoURL).To(Equal("https://charts.example.com/"))
+
+		hr = newRelease("stable", "")
+		Expect(d.Default(context.Background(), hr)).To(Succeed())
+		Expect(hr.Spec.RepoURL).To(Equal("https://charts.example.com/stable"))
+
+		hr = newRelease("https://other.example.com", "")
+		Expect(d.Default(context.Background(), hr)).To(Succeed())
+		Expect(hr.Spec.RepoURL).To(Equal("https://other.example.com"))
+	})
+
+	It("merges default values underneath spec.values", func() {
+		d := newDefaulter(map[string]string{
+			webhooks.AnnotationDefaultValues: `{"resources":{"limits":{"cpu":"500m","memory":"256Mi"}},"team":"a"}`,
+		})
+		hr := newRelease("https://charts.example.com", `{"resources":{"limits":{"cpu":"1"}}}`)
+		Expect(d.Default(context.Background(), hr)).To(Succeed())
+
+		var values map[string]interface{}
+		Expect(json.Unmarshal(hr.Spec.Values.Raw, &values)).To(Succeed())
+		Expect(values).To(Equal(map[string]interface{}{
+			"resources": map[string]interface{}{"limits": map[string]interface{}{"cpu": "1", "memory": "256Mi"}},
+			"team":      "a",
+		}))
+	})
+
+	It("unsets a default value set to null in spec.values", func() {
+		d := newDefaulter(map[string]string{
+			webhooks.AnnotationDefaultValues: `{"resources":{"limits":{"cpu":"500m"},"requests":{"cpu":"100m"}}}`,
+		})
+		hr := newRelease("https://charts.example.com", `{"resources":{"limits":null}}`)
+		Expect(d.Default(context.Background(), hr)).To(Succeed())
+		Expect(string(hr.Spec.Values.Raw)).To(Equal(`{"resources":{"limits":null,"requests":{"cpu":"100m"}}}`))
+
+		// Defaulting again on update keeps the key unset.
+		Expect(d.Default(context.Background(), hr)).To(Succeed())
+		Expect(string(hr.Spec.Values.Raw)).To(Equal(`{"resources":{"limits":null,"requests":{"cpu":"100m"}}}`))
+	})
+
+	It("rejects malformed default values", func() {
+		d := newDefaulter(map[string]string{webhooks.AnnotationDefaultValues: "not json"})
+		Expect(d.Default(context.Background(), newRelease("", ""))).NotTo(Succeed())
+	})
+
+	It("fills in an empty serviceAccountName", func() {
+		d := newDefaulter(map[string]string{webhooks.AnnotationDefaultServiceAccount: "team-a-deployer"})
+
+		hr := newRelease("stable", "")
+		Expect(d.Default(context.Background(), hr)).To(Succeed())
+		Expect(hr.Spec.ServiceAccountName).To(Equal("team-a-deployer"))
+
+		hr = newRelease("stable", "")
+		hr.Spec.ServiceAccountName = "own"
+		Expect(d.Default(context.Background(), hr)).To(Succeed())
+		Expect(hr.Spec.ServiceAccountName).To(Equal("own"))
+	})
+
+	It("leaves updates alone, even with malformed annotations", func() {
+		d := newDefaulter(map[string]string{
+			webhooks.AnnotationDefaultValues:         "not json",
+			webhooks.AnnotationDefaultServiceAccount: "team-a-deployer",
+		})
+		ctx := admission.NewContextWithRequest(context.Background(), admission.Request{
+			AdmissionRequest: admissionv1.AdmissionRequest{Operation: admissionv1.Update},
+		})
+		hr := newRelease("stable", `{"a":1}`)
+		Expect(d.Default(ctx, hr)).To(Succeed())
+		Expect(hr.Spec.ServiceAccountName).To(BeEmpty())
+		Expect(string(hr.Spec.Values.Raw)).To(Equal(`{"a":1}`))
+	})
+
+	It("defaults spec.maxHistory", func() {
+		d := newDefaulter(nil)
+		ten := 10
+		d.MaxHistory = &ten
+
+		hr := newRelease("stable", "")
+		Expect(d.Default(context.Background(), hr)).To(Succeed())
+		Expect(hr.Spec.MaxHistory).To(HaveValue(Equal(10)))
+
+		zero := 0
+		hr = newRelease("stable", "")
+		hr.Spec.MaxHistory = &zero
+		Expect(d.Default(context.Background(), hr)).To(Succeed())
+		Expect(hr.Spec.MaxHistory).To(HaveValue(Equal(0)))
+	})
+
+	It("leaves the release alone when the namespace has no annotations", func() {
+		d := newDefaulter(nil)
+		hr := newRelease("stable", `{"a":1}`)
+		Expect(d.Default(context.Background(), hr)).To(Succeed())
+		Expect(hr.Spec.RepoURL).To(Equal("stable"))
+		Expect(string(hr.Spec.Values.Raw)).To(Equal(`{"a":1}`))
+	})
+})
@@ -0,0 +1,111 @@
+package webhooks
+
+import (
+	"context"
+	"fmt"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// HelmReleaseValidator rejects changes to the releaseName, targetNamespace or
+// kubeConfig of an installed HelmRelease. The controller cannot move a Helm release, so
+// such an edit would leave the old release orphaned; spec.allowRelocation
+// opts into uninstalling it and installing the new one instead.
+//
+// +kubebuilder:webhook:path=/validate-helm-example-com-v1alpha1-helmrelease,mutating=false,failurePolicy=fail,sideEffects=None,groups=helm.example.com,resources=helmreleases,verbs=update,versions=v1alpha1,name=vhelmrelease.helm.example.com,admissionReviewVersions=v1
+type HelmReleaseValidator struct{}
+
+var _ admission.CustomValidator = (*HelmReleaseValidator)(nil) // compile-time interface check
+
+// SetupWithManager registers the validating webhook with the manager's webhook server.
+func (v *HelmReleaseValidator) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&helmv1alpha1.HelmRelease{}).
+		WithValidator(v).
+		Complete()
+}
+
+// ValidateCreate implements admission.CustomValidator.
+func (v *HelmReleaseValidator) ValidateCreate(context.Context, runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// ValidateUpdate implements admission.CustomValidator.
+func (v *HelmReleaseValidator) ValidateUpdate(_ context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	old, ok := oldObj.(*helmv1alpha1.HelmRelease)
+	if !ok {
+		return nil, fmt.Errorf("expected a HelmRelease but got %T", oldObj)
+	}
+	hr, ok := newObj.(*helmv1alpha1.HelmRelease)
+	if !ok {
+		return nil, fmt.Errorf("expected a HelmRelease but got %T", newObj)
+	}
+	// Until the controller has recorded where it installed the release there
+	// is nothing to orphan, and a release being deleted is uninstalled where
+	// it is. Edits back to the recorded location are always allowed.
+	installedName, installedNamespace := old.Status.ReleaseName, old.Status.ReleaseNamespace
+	if hr.Spec.AllowRelocation || installedName == "" || !hr.DeletionTimestamp.IsZero() {
+		return nil, nil
+	}
+
+	var errs field.ErrorList
+	if name := releaseName(hr); name != installedName && name != releaseName(old) {
+		errs = append(errs, field.Forbidden(field.NewPath("spec", "releaseName"), fmt.Sprintf(
+			"the release is installed as %s; set spec.allowRelocation to uninstall it and install %s", installedName, name)))
+	}
+	if ns := hr.Spec.TargetNamespace; ns != installedNamespace && ns != old.Spec.TargetNamespace {
+		errs = append(errs, field.Forbidden(field.NewPath("spec", "targetNamespace"), fmt.Sprintf(
+			"the release is installed in %s; set spec.allowRelocation to uninstall it and install it in %s", installedNamespace, ns)))
+	}
+	if kc := hr.Spec.KubeConfig; !sameCluster(kc, old.Status.ReleaseKubeConfig) && !sameCluster(kc, old.Spec.KubeConfig) {
+		errs = append(errs, field.Forbidden(field.NewPath("spec", "kubeConfig"), fmt.Sprintf(
+			"the release is installed in %s; set spec.allowRelocation to uninstall it there and install it in %s",
+			clusterOf(old.Status.ReleaseKubeConfig), clusterOf(kc))))
+	}
+	if len(errs) == 0 {
+		return nil, nil
+	}
+	return nil, apierrors.NewInvalid(helmv1alpha1.GroupVersion.WithKind("HelmRelease").GroupKind(), hr.Name, errs)
+}
+
+// ValidateDelete implements admission.CustomValidator.
+func (v *HelmReleaseValidator) ValidateDelete(context.Context, runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// releaseName returns the Helm release name of hr, which defaults to the
+// HelmRelease's name.
+func releaseName(hr *helmv1alpha1.HelmRelease) string {
+	if hr.Spec.ReleaseName != "" {
+		return hr.Spec.ReleaseName
+	}
+	return hr.Name
+}
+
+// sameCluster reports whether the kubeconfig references a and b select the
+// same Secret key, or are both nil for the operator's cluster.
+func sameCluster(a, b *helmv1alpha1.KubeConfigReference) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	key := func(kc *helmv1alpha1.KubeConfigReference) string {
+		if kc.SecretRef.Key == "" {
+			return "value"
+		}
+		return kc.SecretRef.Key
+	}
+	return a.SecretRef.Name == b.SecretRef.Name && key(a) == key(b)
+}
+
+// clusterOf describes the cluster of a kubeconfig reference.
+func clusterOf(kc *helmv1alpha1.KubeConfigReference) string {
+	if kc == nil {
+		return "this cluster"
+	}
+	return "the cluster of kubeconfig Secret " + kc.SecretRef.Name
+}
@@ -0,0 +1,82 @@
+package webhooks_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	helmv1alpha1 "github.com/example/helm-operator/api/v1alpha1"
+	"github.com/example/helm-operator/webhooks"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("HelmReleaseValidator", func() {
+	v := &webhooks.HelmReleaseValidator{}
+
+	installed := func() *helmv1alpha1.HelmRelease {
+		return &helmv1alpha1.HelmRelease{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "team-a"},
+			Spec:       helmv1alpha1.HelmReleaseSpec{Chart: "app", TargetNamespace: "apps"},
+			Status:     helmv1alpha1.HelmReleaseStatus{ReleaseName: "app", ReleaseNamespace: "apps"},
+		}
+	}
+
+	It("rejects changing releaseName or targetNamespace of an installed release", func() {
+		old := installed()
+		hr := old.DeepCopy()
+		hr.Spec.ReleaseName = "app-v2"
+		hr.Spec.TargetNamespace = "apps-v2"
+		_, err := v.ValidateUpdate(context.Background(), old, hr)
+		Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("spec.releaseName"))
+		Expect(err.Error()).To(ContainSubstring("spec.targetNamespace"))
+	})
+
+	It("rejects moving an installed release to another cluster", func() {
+		old := installed()
+		hr := old.DeepCopy()
+		hr.Spec.KubeConfig = &helmv1alpha1.KubeConfigReference{SecretRef: helmv1alpha1.KubeConfigSecretRef{Name: "prod"}}
+		_, err := v.ValidateUpdate(context.Background(), old, hr)
+		Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("spec.kubeConfig"))
+
+		// The same Secret with its default key is the same cluster.
+		old.Status.ReleaseKubeConfig = hr.Spec.KubeConfig.DeepCopy()
+		old.Spec.KubeConfig = old.Status.ReleaseKubeConfig
+		hr.Spec.KubeConfig.SecretRef.Key = "value"
+		_, err = v.ValidateUpdate(context.Background(), old, hr)
+		Expect(err).NotTo(HaveOccurred())
+
+		hr.Spec.KubeConfig = nil
+		_, err = v.ValidateUpdate(context.Background(), old, hr)
+		Expect(apierrors.IsInvalid(err)).To(BeTrue())
+	})
+
+	It("allows other changes and changes back to the installed location", func() {
+		old := installed()
+		hr := old.DeepCopy()
+		hr.Spec.Version = "2.0.0"
+		_, err := v.ValidateUpdate(context.Background(), old, hr)
+		Expect(err).NotTo(HaveOccurred())
+
+		old.Spec.TargetNamespace = "elsewhere"
+		_, err = v.ValidateUpdate(context.Background(), old, hr)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("allows the change with allowRelocation or before the release is installed", func() {
+		old := installed()
+		hr := old.DeepCopy()
+		hr.Spec.TargetNamespace = "apps-v2"
+		hr.Spec.AllowRelocation = true
+		_, err := v.ValidateUpdate(context.Background(), old, hr)
+		Expect(err).NotTo(HaveOccurred())
+
+		old.Status = helmv1alpha1.HelmReleaseStatus{}
+		hr.Spec.AllowRelocation = false
+		_, err = v.ValidateUpdate(context.Background(), old, hr)
+		Expect(err).NotTo(HaveOccurred())
+	})
+})